@@ -153,8 +153,8 @@ Flatten Settings Map using "." syntax
   {{- if $label -}}
     {{- $sublabel = list $label $key | join "." -}}
   {{- end -}}
-  {{/* Special-case "tags" since we want this to be a JSON object */}}
-  {{- if eq $key "tags" -}}
+  {{/* Special-case "tags", "vmMemoryOverheadPercentPerInstanceType", "endpoints" and "assumeRoleSessionTags" since we want these to be JSON objects */}}
+  {{- if or (eq $key "tags") (eq $key "vmMemoryOverheadPercentPerInstanceType") (eq $key "endpoints") (eq $key "assumeRoleSessionTags") -}}
     {{- if not (kindIs "invalid" $val) -}}
       {{- $sublabel | quote | nindent 2 }}: {{ $val | toJson | quote }}
     {{- end -}}
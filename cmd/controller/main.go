@@ -39,9 +39,12 @@ func main() {
 		op.AMIProvider,
 		op.SecurityGroupProvider,
 		op.SubnetProvider,
+		op.QuotaProvider,
+		op.NotificationProvider,
 	)
 	lo.Must0(op.AddHealthzCheck("cloud-provider", awsCloudProvider.LivenessProbe))
 	cloudProvider := metrics.Decorate(awsCloudProvider)
+	cluster := state.NewCluster(op.Clock, op.GetClient(), cloudProvider)
 
 	op.
 		WithControllers(ctx, corecontrollers.NewControllers(
@@ -49,9 +52,9 @@ func main() {
 			op.Clock,
 			op.GetClient(),
 			op.KubernetesInterface,
-			state.NewCluster(op.Clock, op.GetClient(), cloudProvider),
+			cluster,
 			op.EventRecorder,
-			cloudProvider,
+			cloudprovider.NewConsolidationCloudProvider(cloudProvider),
 		)...).
 		WithWebhooks(ctx, corewebhooks.NewWebhooks()...).
 		WithControllers(ctx, controllers.NewControllers(
@@ -61,11 +64,18 @@ func main() {
 			op.GetClient(),
 			op.EventRecorder,
 			op.UnavailableOfferingsCache,
+			op.ObservedMemoryCapacities,
 			awsCloudProvider,
 			op.SubnetProvider,
 			op.SecurityGroupProvider,
 			op.PricingProvider,
+			op.SpotAdvisorProvider,
 			op.AMIProvider,
+			op.LaunchTemplateProvider,
+			op.InstanceTypesProvider,
+			op.InstanceProfileProvider,
+			op.InstanceProvider,
+			op.AWSConfigurationCache,
 		)...).
 		WithWebhooks(ctx, webhooks.NewWebhooks()...).
 		Start(ctx)
@@ -118,8 +118,14 @@ func getInstanceTypeInfo(info *ec2.InstanceTypeInfo) string {
 	fmt.Fprintf(src, "BurstablePerformanceSupported: aws.Bool(%t),\n", lo.FromPtr(info.BurstablePerformanceSupported))
 	fmt.Fprintf(src, "BareMetal: aws.Bool(%t),\n", lo.FromPtr(info.BareMetal))
 	fmt.Fprintf(src, "Hypervisor: aws.String(\"%s\"),\n", lo.FromPtr(info.Hypervisor))
+	fmt.Fprintf(src, "NitroEnclavesSupport: aws.String(\"%s\"),\n", lo.FromPtr(info.NitroEnclavesSupport))
+	fmt.Fprintf(src, "NitroTpmSupport: aws.String(\"%s\"),\n", lo.FromPtr(info.NitroTpmSupport))
+	fmt.Fprintf(src, "SupportedBootModes: aws.StringSlice([]string{%s}),\n", getStringSliceData(info.SupportedBootModes))
 	fmt.Fprintf(src, "ProcessorInfo: &ec2.ProcessorInfo{\n")
 	fmt.Fprintf(src, "SupportedArchitectures: aws.StringSlice([]string{%s}),\n", getStringSliceData(info.ProcessorInfo.SupportedArchitectures))
+	if len(info.ProcessorInfo.SupportedFeatures) > 0 {
+		fmt.Fprintf(src, "SupportedFeatures: aws.StringSlice([]string{%s}),\n", getStringSliceData(info.ProcessorInfo.SupportedFeatures))
+	}
 	fmt.Fprintf(src, "},\n")
 	fmt.Fprintf(src, "VCpuInfo: &ec2.VCpuInfo{\n")
 	fmt.Fprintf(src, "DefaultCores: aws.Int64(%d),\n", lo.FromPtr(info.VCpuInfo.DefaultCores))
@@ -89,7 +89,7 @@ func main() {
 		KubernetesInterface: kubernetes.NewForConfigOrDie(&rest.Config{}),
 	})
 	cp := awscloudprovider.New(op.InstanceTypesProvider, op.InstanceProvider,
-		op.EventRecorder, op.GetClient(), op.AMIProvider, op.SecurityGroupProvider, op.SubnetProvider)
+		op.EventRecorder, op.GetClient(), op.AMIProvider, op.SecurityGroupProvider, op.SubnetProvider, op.QuotaProvider, op.NotificationProvider)
 
 	provider := v1alpha1.AWS{SubnetSelector: map[string]string{
 		"*": "*",
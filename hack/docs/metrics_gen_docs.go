@@ -278,6 +278,7 @@ func getIdentMapping(identName string) (string, error) {
 		"consistencySubsystem":    "consistency",
 		"batcherSubsystem":        "cloudprovider_batcher",
 		"cloudProviderSubsystem":  "cloudprovider",
+		"awsSubsystem":            "aws",
 	}
 	if v, ok := identMapping[identName]; ok {
 		return v, nil
@@ -50,9 +50,12 @@ var (
 	AWSNodeTemplateCRD []byte
 	//go:embed crds/compute.k8s.aws_nodeclasses.yaml
 	NodeClassCRD []byte
-	CRDs         = append(apis.CRDs,
+	//go:embed crds/compute.k8s.aws_awsconfigurations.yaml
+	AWSConfigurationCRD []byte
+	CRDs                = append(apis.CRDs,
 		lo.Must(functional.Unmarshal[v1.CustomResourceDefinition](AWSNodeTemplateCRD)),
 		lo.Must(functional.Unmarshal[v1.CustomResourceDefinition](NodeClassCRD)),
+		lo.Must(functional.Unmarshal[v1.CustomResourceDefinition](AWSConfigurationCRD)),
 	)
 )
 
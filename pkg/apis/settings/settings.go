@@ -18,8 +18,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/samber/lo"
 	v1 "k8s.io/api/core/v1"
 	"knative.dev/pkg/configmap"
 )
@@ -28,37 +30,161 @@ type settingsKeyType struct{}
 
 var ContextKey = settingsKeyType{}
 
+// The sources that the controller's credentials can be resolved from with aws.credentialsSource
+const (
+	// CredentialsSourceAuto resolves credentials with the SDK's default chain
+	CredentialsSourceAuto = "auto"
+	// CredentialsSourceIRSA exchanges the service account token for credentials of the role that the service account is
+	// annotated with
+	CredentialsSourceIRSA = "irsa"
+	// CredentialsSourcePodIdentity gets credentials of the role that the service account is associated with from the
+	// EKS Pod Identity Agent
+	CredentialsSourcePodIdentity = "podIdentity"
+	// CredentialsSourceInstanceRole gets credentials of the node's instance profile from IMDS
+	CredentialsSourceInstanceRole = "instanceRole"
+)
+
+var CredentialsSources = []string{CredentialsSourceAuto, CredentialsSourceIRSA, CredentialsSourcePodIdentity, CredentialsSourceInstanceRole}
+
+// The strategies that aws.subnetSelectionStrategy selects between the subnets of a zone with
+const (
+	// SubnetSelectionStrategyMostAvailableIPs launches into the subnet with the most available IP addresses
+	SubnetSelectionStrategyMostAvailableIPs = "mostAvailableIPs"
+	// SubnetSelectionStrategyRoundRobin launches into each subnet in turn
+	SubnetSelectionStrategyRoundRobin = "roundRobin"
+	// SubnetSelectionStrategyRandom launches into a random subnet
+	SubnetSelectionStrategyRandom = "random"
+)
+
+var SubnetSelectionStrategies = []string{SubnetSelectionStrategyMostAvailableIPs, SubnetSelectionStrategyRoundRobin, SubnetSelectionStrategyRandom}
+
+// The sources that aws.kubernetesVersionSource reads the version of the control plane from
+const (
+	// KubernetesVersionSourceAPIServer reads the version from the /version endpoint of the API server
+	KubernetesVersionSourceAPIServer = "apiServer"
+	// KubernetesVersionSourceEKS reads the version of the cluster from EKS DescribeCluster
+	KubernetesVersionSourceEKS = "eks"
+)
+
+var KubernetesVersionSources = []string{KubernetesVersionSourceAPIServer, KubernetesVersionSourceEKS}
+
 var defaultSettings = &Settings{
-	AssumeRoleARN:              "",
-	AssumeRoleDuration:         time.Minute * 15,
-	ClusterCABundle:            "",
-	ClusterName:                "",
-	ClusterEndpoint:            "",
-	DefaultInstanceProfile:     "",
-	EnablePodENI:               false,
-	EnableENILimitedPodDensity: true,
-	IsolatedVPC:                false,
-	VMMemoryOverheadPercent:    0.075,
-	InterruptionQueueName:      "",
-	Tags:                       map[string]string{},
-	ReservedENIs:               0,
+	AssumeRoleARN:                          "",
+	AssumeRoleDuration:                     time.Minute * 15,
+	AssumeRoleExternalID:                   "",
+	AssumeRoleSessionTags:                  map[string]string{},
+	AssumeRoleSourceIdentity:               "",
+	ClusterCABundle:                        "",
+	ClusterName:                            "",
+	ClusterEndpoint:                        "",
+	ClusterDNSServiceName:                  "kube-dns",
+	ClusterDNSServiceNamespace:             "kube-system",
+	DefaultInstanceProfile:                 "",
+	EnablePodENI:                           false,
+	EnableENILimitedPodDensity:             true,
+	IsolatedVPC:                            false,
+	VMMemoryOverheadPercent:                0.075,
+	VMMemoryOverheadPercentPerInstanceType: map[string]float64{},
+	EnableVMMemoryOverheadLearning:         false,
+	InterruptionQueueName:                  "",
+	Tags:                                   map[string]string{},
+	ReservedENIs:                           0,
+	EnableAttributeBasedInstanceSelection:  false,
+	EnableLaunchDryRun:                     false,
+	ConsolidationPriceThreshold:            0,
+	ConsolidationPriceThresholdPercent:     0,
+	AllowedInstanceFamilies:                []string{},
+	ExcludedInstanceTypes:                  []string{},
+	MinimumInstanceGeneration:              0,
+	UseFIPSEndpoint:                        false,
+	UseDualStackEndpoint:                   false,
+	Endpoints:                              map[string]string{},
+	EnableNodeTemplateMigration:            false,
+	StandbyRefreshInterval:                 0,
+	GarbageCollectionGracePeriod:           30 * time.Second,
+	EnableResourceGarbageCollection:        false,
+	ResourceGarbageCollectionDryRun:        false,
+	EnableStopBasedConsolidation:           false,
+	StoppedInstanceTTL:                     time.Hour,
+	EnableNodeRoleRegistration:             false,
+	CredentialsSource:                      CredentialsSourceAuto,
+	MaxConcurrentLaunchesPerNodePool:       0,
+	LaunchesPerSecondPerNodePool:           0,
+	LaunchBurstPerNodePool:                 10,
+	EnableSpotPlacementScores:              false,
+	EnableWeightedCapacity:                 false,
+	SubnetSelectionStrategy:                SubnetSelectionStrategyMostAvailableIPs,
+	MaxSecurityGroupsPerNetworkInterface:   5,
+	KubernetesVersionSource:                KubernetesVersionSourceAPIServer,
+	EnableInstanceTypeCatalog:              false,
+	InterruptionDeadLetterQueueName:        "",
+	InterruptionMaxReceiveCount:            5,
+	PreTerminationTopicARN:                 "",
+	PreTerminationWebhookURL:               "",
+	EnableInstanceStatusChecks:             false,
+	ReplaceImpairedInstances:               false,
+	CloudWatchMetricsNamespace:             "",
+	TracingEndpoint:                        "",
 }
 
 // +k8s:deepcopy-gen=true
 type Settings struct {
-	AssumeRoleARN              string
-	AssumeRoleDuration         time.Duration
-	ClusterCABundle            string
-	ClusterName                string
-	ClusterEndpoint            string
-	DefaultInstanceProfile     string
-	EnablePodENI               bool
-	EnableENILimitedPodDensity bool
-	IsolatedVPC                bool
-	VMMemoryOverheadPercent    float64
-	InterruptionQueueName      string
-	Tags                       map[string]string
-	ReservedENIs               int
+	AssumeRoleARN                          string
+	AssumeRoleDuration                     time.Duration
+	AssumeRoleExternalID                   string
+	AssumeRoleSessionTags                  map[string]string
+	AssumeRoleSourceIdentity               string
+	ClusterCABundle                        string
+	ClusterName                            string
+	ClusterEndpoint                        string
+	ClusterDNSServiceName                  string
+	ClusterDNSServiceNamespace             string
+	DefaultInstanceProfile                 string
+	EnablePodENI                           bool
+	EnableENILimitedPodDensity             bool
+	IsolatedVPC                            bool
+	VMMemoryOverheadPercent                float64
+	VMMemoryOverheadPercentPerInstanceType map[string]float64
+	EnableVMMemoryOverheadLearning         bool
+	InterruptionQueueName                  string
+	Tags                                   map[string]string
+	ReservedENIs                           int
+	EnableAttributeBasedInstanceSelection  bool
+	EnableLaunchDryRun                     bool
+	ConsolidationPriceThreshold            float64
+	ConsolidationPriceThresholdPercent     float64
+	AllowedInstanceFamilies                []string
+	ExcludedInstanceTypes                  []string
+	MinimumInstanceGeneration              int
+	UseFIPSEndpoint                        bool
+	UseDualStackEndpoint                   bool
+	Endpoints                              map[string]string
+	EnableNodeTemplateMigration            bool
+	StandbyRefreshInterval                 time.Duration
+	GarbageCollectionGracePeriod           time.Duration
+	EnableResourceGarbageCollection        bool
+	ResourceGarbageCollectionDryRun        bool
+	EnableStopBasedConsolidation           bool
+	StoppedInstanceTTL                     time.Duration
+	EnableNodeRoleRegistration             bool
+	CredentialsSource                      string
+	MaxConcurrentLaunchesPerNodePool       int
+	LaunchesPerSecondPerNodePool           float64
+	LaunchBurstPerNodePool                 int
+	EnableSpotPlacementScores              bool
+	EnableWeightedCapacity                 bool
+	SubnetSelectionStrategy                string
+	MaxSecurityGroupsPerNetworkInterface   int
+	KubernetesVersionSource                string
+	EnableInstanceTypeCatalog              bool
+	InterruptionDeadLetterQueueName        string
+	InterruptionMaxReceiveCount            int
+	PreTerminationTopicARN                 string
+	PreTerminationWebhookURL               string
+	EnableInstanceStatusChecks             bool
+	ReplaceImpairedInstances               bool
+	CloudWatchMetricsNamespace             string
+	TracingEndpoint                        string
 }
 
 func (*Settings) ConfigMap() string {
@@ -67,22 +193,83 @@ func (*Settings) ConfigMap() string {
 
 // Inject creates a Settings from the supplied ConfigMap
 func (*Settings) Inject(ctx context.Context, cm *v1.ConfigMap) (context.Context, error) {
-	s := defaultSettings.DeepCopy()
+	return inject(ctx, cm.Data)
+}
 
-	if err := configmap.Parse(cm.Data,
+// Overridden injects the Settings from the karpenter-global-settings ConfigMap with its Overrides, which are keyed the
+// same as the ConfigMap, taking precedence over the ConfigMap. It's registered with the settings store in place of
+// Settings, so that the overrides are kept whenever the settings are injected into a context.
+type Overridden struct {
+	Overrides map[string]string
+}
+
+func (*Overridden) ConfigMap() string {
+	return (&Settings{}).ConfigMap()
+}
+
+func (o *Overridden) Inject(ctx context.Context, cm *v1.ConfigMap) (context.Context, error) {
+	return inject(ctx, lo.Assign(cm.Data, o.Overrides))
+}
+
+func inject(ctx context.Context, data map[string]string) (context.Context, error) {
+	s := defaultSettings.DeepCopy()
+	if err := configmap.Parse(data,
 		configmap.AsString("aws.assumeRoleARN", &s.AssumeRoleARN),
 		configmap.AsDuration("aws.assumeRoleDuration", &s.AssumeRoleDuration),
+		configmap.AsString("aws.assumeRoleExternalID", &s.AssumeRoleExternalID),
+		AsStringMap("aws.assumeRoleSessionTags", &s.AssumeRoleSessionTags),
+		configmap.AsString("aws.assumeRoleSourceIdentity", &s.AssumeRoleSourceIdentity),
 		configmap.AsString("aws.clusterCABundle", &s.ClusterCABundle),
 		configmap.AsString("aws.clusterName", &s.ClusterName),
 		configmap.AsString("aws.clusterEndpoint", &s.ClusterEndpoint),
+		configmap.AsString("aws.clusterDNSServiceName", &s.ClusterDNSServiceName),
+		configmap.AsString("aws.clusterDNSServiceNamespace", &s.ClusterDNSServiceNamespace),
 		configmap.AsString("aws.defaultInstanceProfile", &s.DefaultInstanceProfile),
 		configmap.AsBool("aws.enablePodENI", &s.EnablePodENI),
 		configmap.AsBool("aws.enableENILimitedPodDensity", &s.EnableENILimitedPodDensity),
 		configmap.AsBool("aws.isolatedVPC", &s.IsolatedVPC),
 		configmap.AsFloat64("aws.vmMemoryOverheadPercent", &s.VMMemoryOverheadPercent),
+		AsFloat64Map("aws.vmMemoryOverheadPercentPerInstanceType", &s.VMMemoryOverheadPercentPerInstanceType),
+		configmap.AsBool("aws.enableVMMemoryOverheadLearning", &s.EnableVMMemoryOverheadLearning),
 		configmap.AsString("aws.interruptionQueueName", &s.InterruptionQueueName),
 		AsStringMap("aws.tags", &s.Tags),
 		configmap.AsInt("aws.reservedENIs", &s.ReservedENIs),
+		configmap.AsBool("aws.enableAttributeBasedInstanceSelection", &s.EnableAttributeBasedInstanceSelection),
+		configmap.AsBool("aws.enableLaunchDryRun", &s.EnableLaunchDryRun),
+		configmap.AsFloat64("aws.consolidationPriceThreshold", &s.ConsolidationPriceThreshold),
+		configmap.AsFloat64("aws.consolidationPriceThresholdPercent", &s.ConsolidationPriceThresholdPercent),
+		AsStringSlice("aws.allowedInstanceFamilies", &s.AllowedInstanceFamilies),
+		AsStringSlice("aws.excludedInstanceTypes", &s.ExcludedInstanceTypes),
+		configmap.AsInt("aws.minimumInstanceGeneration", &s.MinimumInstanceGeneration),
+		configmap.AsBool("aws.useFIPSEndpoint", &s.UseFIPSEndpoint),
+		configmap.AsBool("aws.useDualStackEndpoint", &s.UseDualStackEndpoint),
+		AsStringMap("aws.endpoints", &s.Endpoints),
+		configmap.AsBool("aws.enableNodeTemplateMigration", &s.EnableNodeTemplateMigration),
+		configmap.AsDuration("aws.standbyRefreshInterval", &s.StandbyRefreshInterval),
+		configmap.AsDuration("aws.garbageCollectionGracePeriod", &s.GarbageCollectionGracePeriod),
+		configmap.AsBool("aws.enableResourceGarbageCollection", &s.EnableResourceGarbageCollection),
+		configmap.AsBool("aws.resourceGarbageCollectionDryRun", &s.ResourceGarbageCollectionDryRun),
+		configmap.AsBool("aws.enableStopBasedConsolidation", &s.EnableStopBasedConsolidation),
+		configmap.AsDuration("aws.stoppedInstanceTTL", &s.StoppedInstanceTTL),
+		configmap.AsBool("aws.enableNodeRoleRegistration", &s.EnableNodeRoleRegistration),
+		configmap.AsString("aws.credentialsSource", &s.CredentialsSource),
+		configmap.AsInt("aws.maxConcurrentLaunchesPerNodePool", &s.MaxConcurrentLaunchesPerNodePool),
+		configmap.AsFloat64("aws.launchesPerSecondPerNodePool", &s.LaunchesPerSecondPerNodePool),
+		configmap.AsInt("aws.launchBurstPerNodePool", &s.LaunchBurstPerNodePool),
+		configmap.AsBool("aws.enableSpotPlacementScores", &s.EnableSpotPlacementScores),
+		configmap.AsBool("aws.enableWeightedCapacity", &s.EnableWeightedCapacity),
+		configmap.AsString("aws.subnetSelectionStrategy", &s.SubnetSelectionStrategy),
+		configmap.AsInt("aws.maxSecurityGroupsPerNetworkInterface", &s.MaxSecurityGroupsPerNetworkInterface),
+		configmap.AsString("aws.kubernetesVersionSource", &s.KubernetesVersionSource),
+		configmap.AsBool("aws.enableInstanceTypeCatalog", &s.EnableInstanceTypeCatalog),
+		configmap.AsString("aws.interruptionDeadLetterQueueName", &s.InterruptionDeadLetterQueueName),
+		configmap.AsInt("aws.interruptionMaxReceiveCount", &s.InterruptionMaxReceiveCount),
+		configmap.AsString("aws.preTerminationTopicARN", &s.PreTerminationTopicARN),
+		configmap.AsString("aws.preTerminationWebhookURL", &s.PreTerminationWebhookURL),
+		configmap.AsBool("aws.enableInstanceStatusChecks", &s.EnableInstanceStatusChecks),
+		configmap.AsBool("aws.replaceImpairedInstances", &s.ReplaceImpairedInstances),
+		configmap.AsString("aws.cloudWatchMetricsNamespace", &s.CloudWatchMetricsNamespace),
+		configmap.AsString("aws.tracingEndpoint", &s.TracingEndpoint),
 	); err != nil {
 		return ctx, fmt.Errorf("parsing settings, %w", err)
 	}
@@ -128,3 +315,27 @@ func AsStringMap(key string, target *map[string]string) configmap.ParseFunc {
 		return nil
 	}
 }
+
+// AsStringSlice parses a value as a comma separated list of strings, ignoring empty entries.
+func AsStringSlice(key string, target *[]string) configmap.ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok {
+			*target = lo.Compact(lo.Map(strings.Split(raw, ","), func(s string, _ int) string { return strings.TrimSpace(s) }))
+		}
+		return nil
+	}
+}
+
+// AsFloat64Map parses a value as a JSON map of map[string]float64.
+func AsFloat64Map(key string, target *map[string]float64) configmap.ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok {
+			m := map[string]float64{}
+			if err := json.Unmarshal([]byte(raw), &m); err != nil {
+				return err
+			}
+			*target = m
+		}
+		return nil
+	}
+}
@@ -17,24 +17,68 @@ package settings
 import (
 	"fmt"
 	"net/url"
+	"path"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"knative.dev/pkg/apis"
 
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 )
 
+// EndpointServices are the services whose endpoints can be overridden with aws.endpoints
+var EndpointServices = []string{"ec2", "eks", "iam", "pricing", "servicequotas", "sns", "sqs", "ssm", "sts"}
+
+var (
+	roleARNRegex  = regexp.MustCompile(`^arn:aws[a-z-]*:iam::[0-9]{12}:role/.+$`)
+	topicARNRegex = regexp.MustCompile(`^arn:aws[a-z-]*:sns:[a-z0-9-]+:[0-9]{12}:[\w-]+(\.fifo)?$`)
+	// The characters that STS allows in AssumeRole requests
+	externalIDRegex     = regexp.MustCompile(`^[\w+=,.@:/-]*$`)
+	sourceIdentityRegex = regexp.MustCompile(`^[\w+=,.@-]*$`)
+	sessionTagRegex     = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+)
+
 func (s Settings) Validate() (errs *apis.FieldError) {
 	return errs.Also(
 		s.validateEndpoint(),
 		s.validateTags(),
 		s.validateClusterName(),
+		s.validateClusterDNSService(),
 		s.validateVMMemoryOverheadPercent(),
 		s.validateReservedENIs(),
+		s.validateAssumeRoleARN(),
 		s.validateAssumeRoleDuration(),
+		s.validateAssumeRoleOptions(),
+		s.validateConsolidationPriceThresholds(),
+		s.validateInstanceTypeGlobs(),
+		s.validateMinimumInstanceGeneration(),
+		s.validateEndpoints(),
+		s.validateStandbyRefreshInterval(),
+		s.validateGarbageCollectionGracePeriod(),
+		s.validateStoppedInstanceTTL(),
+		s.validateCredentialsSource(),
+		s.validateLaunchLimits(),
+		s.validateSubnetSelectionStrategy(),
+		s.validateMaxSecurityGroupsPerNetworkInterface(),
+		s.validateKubernetesVersionSource(),
+		s.validateInterruptionMaxReceiveCount(),
+		s.validatePreTermination(),
+		s.validateReplaceImpairedInstances(),
+		s.validateCloudWatchMetricsNamespace(),
+		s.validateTracingEndpoint(),
 	).ViaField("aws")
 }
 
+func (s Settings) validateAssumeRoleARN() (errs *apis.FieldError) {
+	if s.AssumeRoleARN != "" && !roleARNRegex.MatchString(s.AssumeRoleARN) {
+		return errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q not the ARN of an IAM role", s.AssumeRoleARN), "assumeRoleARN"))
+	}
+	return nil
+}
+
 func (s Settings) validateAssumeRoleDuration() (errs *apis.FieldError) {
 	if s.AssumeRoleDuration < time.Minute*15 {
 		return errs.Also(apis.ErrInvalidValue("assumeRoleDuration cannot be less than 15 Minutes", "assumeRoleDuration"))
@@ -42,6 +86,28 @@ func (s Settings) validateAssumeRoleDuration() (errs *apis.FieldError) {
 	return nil
 }
 
+func (s Settings) validateAssumeRoleOptions() (errs *apis.FieldError) {
+	if id := s.AssumeRoleExternalID; id != "" && (len(id) < 2 || len(id) > 1224 || !externalIDRegex.MatchString(id)) {
+		errs = errs.Also(apis.ErrInvalidValue("must be 2 to 1224 letters, digits or the characters +=,.@:/-", "assumeRoleExternalID"))
+	}
+	if id := s.AssumeRoleSourceIdentity; id != "" && (len(id) < 2 || len(id) > 64 || !sourceIdentityRegex.MatchString(id)) {
+		errs = errs.Also(apis.ErrInvalidValue("must be 2 to 64 letters, digits or the characters +=,.@-", "assumeRoleSourceIdentity"))
+	}
+	if len(s.AssumeRoleSessionTags) > 50 {
+		errs = errs.Also(apis.ErrInvalidValue("cannot have more than 50 tags", "assumeRoleSessionTags"))
+	}
+	for k, v := range s.AssumeRoleSessionTags {
+		if len(k) == 0 || len(k) > 128 || !sessionTagRegex.MatchString(k) || strings.HasPrefix(strings.ToLower(k), "aws:") {
+			errs = errs.Also(apis.ErrInvalidKeyName(k, "assumeRoleSessionTags", "must be 1 to 128 characters and can't start with aws:"))
+			continue
+		}
+		if len(v) > 256 || !sessionTagRegex.MatchString(v) {
+			errs = errs.Also(apis.ErrInvalidValue("must be at most 256 characters", "assumeRoleSessionTags").ViaKey(k))
+		}
+	}
+	return errs
+}
+
 func (s Settings) validateClusterName() (errs *apis.FieldError) {
 	if s.ClusterName == "" {
 		return errs.Also(apis.ErrMissingField("clusterName is required", "clusterName"))
@@ -49,6 +115,16 @@ func (s Settings) validateClusterName() (errs *apis.FieldError) {
 	return nil
 }
 
+func (s Settings) validateClusterDNSService() (errs *apis.FieldError) {
+	for _, msg := range validation.IsDNS1035Label(s.ClusterDNSServiceName) {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q not a valid service name, %s", s.ClusterDNSServiceName, msg), "clusterDNSServiceName"))
+	}
+	for _, msg := range validation.IsDNS1123Label(s.ClusterDNSServiceNamespace) {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q not a valid namespace, %s", s.ClusterDNSServiceNamespace, msg), "clusterDNSServiceNamespace"))
+	}
+	return errs
+}
+
 func (s Settings) validateEndpoint() (errs *apis.FieldError) {
 	if s.ClusterEndpoint == "" {
 		return nil
@@ -62,6 +138,123 @@ func (s Settings) validateEndpoint() (errs *apis.FieldError) {
 	return nil
 }
 
+func (s Settings) validateEndpoints() (errs *apis.FieldError) {
+	for service, rawURL := range s.Endpoints {
+		if !lo.Contains(EndpointServices, service) {
+			errs = errs.Also(apis.ErrInvalidKeyName(service, "endpoints", fmt.Sprintf("must be one of %v", EndpointServices)))
+			continue
+		}
+		endpoint, err := url.Parse(rawURL)
+		if err != nil || !endpoint.IsAbs() || endpoint.Hostname() == "" {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q not a valid endpoint URL", rawURL), "endpoints").ViaKey(service))
+		}
+	}
+	return errs
+}
+
+func (s Settings) validateLaunchLimits() (errs *apis.FieldError) {
+	if s.MaxConcurrentLaunchesPerNodePool < 0 {
+		errs = errs.Also(apis.ErrInvalidValue("cannot be negative", "maxConcurrentLaunchesPerNodePool"))
+	}
+	if s.LaunchesPerSecondPerNodePool < 0 {
+		errs = errs.Also(apis.ErrInvalidValue("cannot be negative", "launchesPerSecondPerNodePool"))
+	}
+	if s.LaunchesPerSecondPerNodePool > 0 && s.LaunchBurstPerNodePool < 1 {
+		errs = errs.Also(apis.ErrInvalidValue("must be at least 1 when launchesPerSecondPerNodePool is set", "launchBurstPerNodePool"))
+	}
+	return errs
+}
+
+func (s Settings) validateStandbyRefreshInterval() (errs *apis.FieldError) {
+	if s.StandbyRefreshInterval != 0 && s.StandbyRefreshInterval < time.Minute {
+		return errs.Also(apis.ErrInvalidValue("must be at least 1m when set", "standbyRefreshInterval"))
+	}
+	return nil
+}
+
+func (s Settings) validateGarbageCollectionGracePeriod() (errs *apis.FieldError) {
+	// Instances are launched before their NodeClaim is updated with their provider ID, so a shorter grace period risks
+	// terminating instances that are still being launched
+	if s.GarbageCollectionGracePeriod < 30*time.Second {
+		return errs.Also(apis.ErrInvalidValue("must be at least 30s", "garbageCollectionGracePeriod"))
+	}
+	return nil
+}
+
+func (s Settings) validateStoppedInstanceTTL() (errs *apis.FieldError) {
+	if s.StoppedInstanceTTL < time.Minute {
+		return errs.Also(apis.ErrInvalidValue("must be at least 1m", "stoppedInstanceTTL"))
+	}
+	return nil
+}
+
+func (s Settings) validateCredentialsSource() (errs *apis.FieldError) {
+	if !lo.Contains(CredentialsSources, s.CredentialsSource) {
+		return errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q must be one of %v", s.CredentialsSource, CredentialsSources), "credentialsSource"))
+	}
+	return nil
+}
+
+func (s Settings) validateSubnetSelectionStrategy() (errs *apis.FieldError) {
+	if !lo.Contains(SubnetSelectionStrategies, s.SubnetSelectionStrategy) {
+		return errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q must be one of %v", s.SubnetSelectionStrategy, SubnetSelectionStrategies), "subnetSelectionStrategy"))
+	}
+	return nil
+}
+
+func (s Settings) validateKubernetesVersionSource() (errs *apis.FieldError) {
+	if !lo.Contains(KubernetesVersionSources, s.KubernetesVersionSource) {
+		return errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q must be one of %v", s.KubernetesVersionSource, KubernetesVersionSources), "kubernetesVersionSource"))
+	}
+	return nil
+}
+
+// validateMaxSecurityGroupsPerNetworkInterface checks the limit against the range of EC2's adjustable quota
+func (s Settings) validateMaxSecurityGroupsPerNetworkInterface() (errs *apis.FieldError) {
+	if s.MaxSecurityGroupsPerNetworkInterface < 1 || s.MaxSecurityGroupsPerNetworkInterface > 16 {
+		return errs.Also(apis.ErrOutOfBoundsValue(s.MaxSecurityGroupsPerNetworkInterface, 1, 16, "maxSecurityGroupsPerNetworkInterface"))
+	}
+	return nil
+}
+
+func (s Settings) validateInterruptionMaxReceiveCount() (errs *apis.FieldError) {
+	if s.InterruptionMaxReceiveCount < 1 {
+		return errs.Also(apis.ErrInvalidValue("must be at least 1", "interruptionMaxReceiveCount"))
+	}
+	return nil
+}
+
+func (s Settings) validatePreTermination() (errs *apis.FieldError) {
+	if s.PreTerminationTopicARN != "" && !topicARNRegex.MatchString(s.PreTerminationTopicARN) {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q not the ARN of an SNS topic", s.PreTerminationTopicARN), "preTerminationTopicARN"))
+	}
+	if s.PreTerminationWebhookURL != "" {
+		webhook, err := url.Parse(s.PreTerminationWebhookURL)
+		if err != nil || !lo.Contains([]string{"http", "https"}, webhook.Scheme) || webhook.Hostname() == "" {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q not a valid webhook URL", s.PreTerminationWebhookURL), "preTerminationWebhookURL"))
+		}
+	}
+	return errs
+}
+
+func (s Settings) validateReplaceImpairedInstances() (errs *apis.FieldError) {
+	if s.ReplaceImpairedInstances && !s.EnableInstanceStatusChecks {
+		return errs.Also(apis.ErrGeneric("replaceImpairedInstances requires enableInstanceStatusChecks", "replaceImpairedInstances"))
+	}
+	return nil
+}
+
+func (s Settings) validateCloudWatchMetricsNamespace() (errs *apis.FieldError) {
+	// Namespaces that start with "AWS/" are reserved for metrics published by AWS services
+	if strings.HasPrefix(s.CloudWatchMetricsNamespace, "AWS/") {
+		return errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q is reserved for AWS services", s.CloudWatchMetricsNamespace), "cloudWatchMetricsNamespace"))
+	}
+	if len(s.CloudWatchMetricsNamespace) > 255 {
+		return errs.Also(apis.ErrInvalidValue("must be at most 255 characters", "cloudWatchMetricsNamespace"))
+	}
+	return nil
+}
+
 func (s Settings) validateTags() (errs *apis.FieldError) {
 	for k := range s.Tags {
 		for _, pattern := range v1alpha1.RestrictedTagPatterns {
@@ -75,9 +268,17 @@ func (s Settings) validateTags() (errs *apis.FieldError) {
 
 func (s Settings) validateVMMemoryOverheadPercent() (errs *apis.FieldError) {
 	if s.VMMemoryOverheadPercent < 0 {
-		return errs.Also(apis.ErrInvalidValue("cannot be negative", "vmMemoryOverheadPercent"))
+		errs = errs.Also(apis.ErrInvalidValue("cannot be negative", "vmMemoryOverheadPercent"))
 	}
-	return nil
+	for pattern, percent := range s.VMMemoryOverheadPercentPerInstanceType {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = errs.Also(apis.ErrInvalidKeyName(pattern, "vmMemoryOverheadPercentPerInstanceType", "not a valid instance type glob"))
+		}
+		if percent < 0 || percent >= 1 {
+			errs = errs.Also(apis.ErrInvalidValue("must be at least 0 and less than 1", "vmMemoryOverheadPercentPerInstanceType").ViaKey(pattern))
+		}
+	}
+	return errs
 }
 
 func (s Settings) validateReservedENIs() (errs *apis.FieldError) {
@@ -86,3 +287,46 @@ func (s Settings) validateReservedENIs() (errs *apis.FieldError) {
 	}
 	return nil
 }
+
+func (s Settings) validateConsolidationPriceThresholds() (errs *apis.FieldError) {
+	if s.ConsolidationPriceThreshold < 0 {
+		errs = errs.Also(apis.ErrInvalidValue("cannot be negative", "consolidationPriceThreshold"))
+	}
+	if s.ConsolidationPriceThresholdPercent < 0 || s.ConsolidationPriceThresholdPercent >= 1 {
+		errs = errs.Also(apis.ErrInvalidValue("must be at least 0 and less than 1", "consolidationPriceThresholdPercent"))
+	}
+	return errs
+}
+
+func (s Settings) validateMinimumInstanceGeneration() (errs *apis.FieldError) {
+	if s.MinimumInstanceGeneration < 0 {
+		return errs.Also(apis.ErrInvalidValue("cannot be negative", "minimumInstanceGeneration"))
+	}
+	return nil
+}
+
+func (s Settings) validateInstanceTypeGlobs() (errs *apis.FieldError) {
+	for field, patterns := range map[string][]string{
+		"allowedInstanceFamilies": s.AllowedInstanceFamilies,
+		"excludedInstanceTypes":   s.ExcludedInstanceTypes,
+	} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q not a valid glob", pattern), field))
+			}
+		}
+	}
+	return errs
+}
+
+func (s Settings) validateTracingEndpoint() (errs *apis.FieldError) {
+	if s.TracingEndpoint == "" {
+		return nil
+	}
+	// Spans are exported with OTLP over gRPC, which is in plaintext for http endpoints and over TLS for https endpoints
+	endpoint, err := url.Parse(s.TracingEndpoint)
+	if err != nil || !lo.Contains([]string{"http", "https"}, endpoint.Scheme) || endpoint.Host == "" {
+		return errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%q not a valid http or https URL", s.TracingEndpoint), "tracingEndpoint"))
+	}
+	return nil
+}
@@ -16,6 +16,7 @@ package settings_test
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -48,30 +49,116 @@ var _ = Describe("Validation", func() {
 		s := settings.FromContext(ctx)
 		Expect(s.AssumeRoleARN).To(Equal(""))
 		Expect(s.AssumeRoleDuration).To(Equal(time.Duration(15) * time.Minute))
+		Expect(s.AssumeRoleExternalID).To(Equal(""))
+		Expect(s.AssumeRoleSessionTags).To(BeEmpty())
+		Expect(s.AssumeRoleSourceIdentity).To(Equal(""))
 		Expect(s.ClusterCABundle).To(Equal(""))
+		Expect(s.ClusterDNSServiceName).To(Equal("kube-dns"))
+		Expect(s.ClusterDNSServiceNamespace).To(Equal("kube-system"))
 		Expect(s.DefaultInstanceProfile).To(Equal(""))
 		Expect(s.EnablePodENI).To(BeFalse())
 		Expect(s.EnableENILimitedPodDensity).To(BeTrue())
 		Expect(s.IsolatedVPC).To(BeFalse())
 		Expect(s.VMMemoryOverheadPercent).To(Equal(0.075))
+		Expect(s.VMMemoryOverheadPercentPerInstanceType).To(BeEmpty())
+		Expect(s.EnableVMMemoryOverheadLearning).To(BeFalse())
 		Expect(len(s.Tags)).To(BeZero())
 		Expect(s.ReservedENIs).To(Equal(0))
+		Expect(s.EnableAttributeBasedInstanceSelection).To(BeFalse())
+		Expect(s.EnableLaunchDryRun).To(BeFalse())
+		Expect(s.ConsolidationPriceThreshold).To(BeZero())
+		Expect(s.ConsolidationPriceThresholdPercent).To(BeZero())
+		Expect(s.AllowedInstanceFamilies).To(BeEmpty())
+		Expect(s.ExcludedInstanceTypes).To(BeEmpty())
+		Expect(s.MinimumInstanceGeneration).To(Equal(0))
+		Expect(s.SubnetSelectionStrategy).To(Equal(settings.SubnetSelectionStrategyMostAvailableIPs))
+		Expect(s.MaxSecurityGroupsPerNetworkInterface).To(Equal(5))
+		Expect(s.KubernetesVersionSource).To(Equal(settings.KubernetesVersionSourceAPIServer))
+		Expect(s.EnableInstanceTypeCatalog).To(BeFalse())
+		Expect(s.InterruptionDeadLetterQueueName).To(Equal(""))
+		Expect(s.InterruptionMaxReceiveCount).To(Equal(5))
+		Expect(s.PreTerminationTopicARN).To(Equal(""))
+		Expect(s.PreTerminationWebhookURL).To(Equal(""))
+		Expect(s.EnableInstanceStatusChecks).To(BeFalse())
+		Expect(s.ReplaceImpairedInstances).To(BeFalse())
+		Expect(s.CloudWatchMetricsNamespace).To(Equal(""))
+		Expect(s.TracingEndpoint).To(Equal(""))
+		Expect(s.UseFIPSEndpoint).To(BeFalse())
+		Expect(s.UseDualStackEndpoint).To(BeFalse())
+		Expect(s.Endpoints).To(BeEmpty())
+		Expect(s.EnableNodeTemplateMigration).To(BeFalse())
+		Expect(s.StandbyRefreshInterval).To(BeZero())
+		Expect(s.GarbageCollectionGracePeriod).To(Equal(30 * time.Second))
+		Expect(s.EnableResourceGarbageCollection).To(BeFalse())
+		Expect(s.ResourceGarbageCollectionDryRun).To(BeFalse())
+		Expect(s.EnableStopBasedConsolidation).To(BeFalse())
+		Expect(s.StoppedInstanceTTL).To(Equal(time.Hour))
+		Expect(s.EnableNodeRoleRegistration).To(BeFalse())
+		Expect(s.CredentialsSource).To(Equal("auto"))
+		Expect(s.MaxConcurrentLaunchesPerNodePool).To(BeZero())
+		Expect(s.LaunchesPerSecondPerNodePool).To(BeZero())
+		Expect(s.LaunchBurstPerNodePool).To(Equal(10))
+		Expect(s.EnableSpotPlacementScores).To(BeFalse())
+		Expect(s.EnableWeightedCapacity).To(BeFalse())
 	})
 	It("should succeed to set custom values", func() {
 		cm := &v1.ConfigMap{
 			Data: map[string]string{
-				"aws.assumeRoleARN":              "arn:aws:iam::111222333444:role/testrole",
-				"aws.assumeRoleDuration":         "27m",
-				"aws.clusterCABundle":            "ca-bundle",
-				"aws.clusterEndpoint":            "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
-				"aws.clusterName":                "my-cluster",
-				"aws.defaultInstanceProfile":     "karpenter",
-				"aws.enablePodENI":               "true",
-				"aws.enableENILimitedPodDensity": "false",
-				"aws.isolatedVPC":                "true",
-				"aws.vmMemoryOverheadPercent":    "0.1",
-				"aws.tags":                       `{"tag1": "value1", "tag2": "value2", "example.com/tag": "my-value"}`,
-				"aws.reservedENIs":               "1",
+				"aws.assumeRoleARN":                          "arn:aws:iam::111222333444:role/testrole",
+				"aws.assumeRoleDuration":                     "27m",
+				"aws.assumeRoleExternalID":                   "karpenter-external-id",
+				"aws.assumeRoleSessionTags":                  `{"team": "platform", "cost-center": "1234"}`,
+				"aws.assumeRoleSourceIdentity":               "karpenter@my-cluster",
+				"aws.clusterCABundle":                        "ca-bundle",
+				"aws.clusterEndpoint":                        "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":                            "my-cluster",
+				"aws.clusterDNSServiceName":                  "coredns",
+				"aws.clusterDNSServiceNamespace":             "dns-system",
+				"aws.defaultInstanceProfile":                 "karpenter",
+				"aws.enablePodENI":                           "true",
+				"aws.enableENILimitedPodDensity":             "false",
+				"aws.isolatedVPC":                            "true",
+				"aws.vmMemoryOverheadPercent":                "0.1",
+				"aws.vmMemoryOverheadPercentPerInstanceType": `{"m5.*": 0.06, "m5.large": 0.08}`,
+				"aws.enableVMMemoryOverheadLearning":         "true",
+				"aws.tags":                                   `{"tag1": "value1", "tag2": "value2", "example.com/tag": "my-value"}`,
+				"aws.reservedENIs":                           "1",
+				"aws.enableAttributeBasedInstanceSelection":  "true",
+				"aws.enableLaunchDryRun":                     "true",
+				"aws.consolidationPriceThreshold":            "0.01",
+				"aws.consolidationPriceThresholdPercent":     "0.05",
+				"aws.allowedInstanceFamilies":                "m5, c6*,",
+				"aws.excludedInstanceTypes":                  "*.metal,t*",
+				"aws.minimumInstanceGeneration":              "5",
+				"aws.useFIPSEndpoint":                        "true",
+				"aws.useDualStackEndpoint":                   "true",
+				"aws.endpoints":                              `{"ec2": "https://vpce-0123.ec2.us-west-2.vpce.amazonaws.com"}`,
+				"aws.enableNodeTemplateMigration":            "true",
+				"aws.standbyRefreshInterval":                 "30m",
+				"aws.garbageCollectionGracePeriod":           "10m",
+				"aws.enableResourceGarbageCollection":        "true",
+				"aws.resourceGarbageCollectionDryRun":        "true",
+				"aws.enableStopBasedConsolidation":           "true",
+				"aws.stoppedInstanceTTL":                     "6h",
+				"aws.enableNodeRoleRegistration":             "true",
+				"aws.credentialsSource":                      "podIdentity",
+				"aws.maxConcurrentLaunchesPerNodePool":       "4",
+				"aws.launchesPerSecondPerNodePool":           "0.5",
+				"aws.launchBurstPerNodePool":                 "20",
+				"aws.enableSpotPlacementScores":              "true",
+				"aws.enableWeightedCapacity":                 "true",
+				"aws.subnetSelectionStrategy":                "roundRobin",
+				"aws.maxSecurityGroupsPerNetworkInterface":   "10",
+				"aws.kubernetesVersionSource":                "eks",
+				"aws.enableInstanceTypeCatalog":              "true",
+				"aws.interruptionDeadLetterQueueName":        "karpenter-cluster-dlq",
+				"aws.interruptionMaxReceiveCount":            "3",
+				"aws.preTerminationTopicARN":                 "arn:aws:sns:us-west-2:111122223333:karpenter-termination",
+				"aws.preTerminationWebhookURL":               "https://cmdb.example.com/termination",
+				"aws.enableInstanceStatusChecks":             "true",
+				"aws.replaceImpairedInstances":               "true",
+				"aws.cloudWatchMetricsNamespace":             "Karpenter",
+				"aws.tracingEndpoint":                        "http://otel-collector.observability:4317",
 			},
 		}
 		ctx, err := (&settings.Settings{}).Inject(ctx, cm)
@@ -79,17 +166,60 @@ var _ = Describe("Validation", func() {
 		s := settings.FromContext(ctx)
 		Expect(s.AssumeRoleARN).To(Equal("arn:aws:iam::111222333444:role/testrole"))
 		Expect(s.AssumeRoleDuration).To(Equal(time.Duration(27) * time.Minute))
+		Expect(s.AssumeRoleExternalID).To(Equal("karpenter-external-id"))
+		Expect(s.AssumeRoleSessionTags).To(Equal(map[string]string{"team": "platform", "cost-center": "1234"}))
+		Expect(s.AssumeRoleSourceIdentity).To(Equal("karpenter@my-cluster"))
 		Expect(s.ClusterCABundle).To(Equal("ca-bundle"))
+		Expect(s.ClusterDNSServiceName).To(Equal("coredns"))
+		Expect(s.ClusterDNSServiceNamespace).To(Equal("dns-system"))
 		Expect(s.DefaultInstanceProfile).To(Equal("karpenter"))
 		Expect(s.EnablePodENI).To(BeTrue())
 		Expect(s.EnableENILimitedPodDensity).To(BeFalse())
 		Expect(s.IsolatedVPC).To(BeTrue())
 		Expect(s.VMMemoryOverheadPercent).To(Equal(0.1))
+		Expect(s.VMMemoryOverheadPercentPerInstanceType).To(Equal(map[string]float64{"m5.*": 0.06, "m5.large": 0.08}))
+		Expect(s.EnableVMMemoryOverheadLearning).To(BeTrue())
 		Expect(len(s.Tags)).To(Equal(3))
 		Expect(s.Tags).To(HaveKeyWithValue("tag1", "value1"))
 		Expect(s.Tags).To(HaveKeyWithValue("tag2", "value2"))
 		Expect(s.Tags).To(HaveKeyWithValue("example.com/tag", "my-value"))
 		Expect(s.ReservedENIs).To(Equal(1))
+		Expect(s.EnableAttributeBasedInstanceSelection).To(BeTrue())
+		Expect(s.EnableLaunchDryRun).To(BeTrue())
+		Expect(s.ConsolidationPriceThreshold).To(Equal(0.01))
+		Expect(s.ConsolidationPriceThresholdPercent).To(Equal(0.05))
+		Expect(s.AllowedInstanceFamilies).To(Equal([]string{"m5", "c6*"}))
+		Expect(s.ExcludedInstanceTypes).To(Equal([]string{"*.metal", "t*"}))
+		Expect(s.MinimumInstanceGeneration).To(Equal(5))
+		Expect(s.UseFIPSEndpoint).To(BeTrue())
+		Expect(s.UseDualStackEndpoint).To(BeTrue())
+		Expect(s.Endpoints).To(Equal(map[string]string{"ec2": "https://vpce-0123.ec2.us-west-2.vpce.amazonaws.com"}))
+		Expect(s.EnableNodeTemplateMigration).To(BeTrue())
+		Expect(s.StandbyRefreshInterval).To(Equal(30 * time.Minute))
+		Expect(s.GarbageCollectionGracePeriod).To(Equal(10 * time.Minute))
+		Expect(s.EnableResourceGarbageCollection).To(BeTrue())
+		Expect(s.ResourceGarbageCollectionDryRun).To(BeTrue())
+		Expect(s.EnableStopBasedConsolidation).To(BeTrue())
+		Expect(s.StoppedInstanceTTL).To(Equal(6 * time.Hour))
+		Expect(s.EnableNodeRoleRegistration).To(BeTrue())
+		Expect(s.CredentialsSource).To(Equal("podIdentity"))
+		Expect(s.MaxConcurrentLaunchesPerNodePool).To(Equal(4))
+		Expect(s.LaunchesPerSecondPerNodePool).To(Equal(0.5))
+		Expect(s.LaunchBurstPerNodePool).To(Equal(20))
+		Expect(s.EnableSpotPlacementScores).To(BeTrue())
+		Expect(s.EnableWeightedCapacity).To(BeTrue())
+		Expect(s.SubnetSelectionStrategy).To(Equal(settings.SubnetSelectionStrategyRoundRobin))
+		Expect(s.MaxSecurityGroupsPerNetworkInterface).To(Equal(10))
+		Expect(s.KubernetesVersionSource).To(Equal(settings.KubernetesVersionSourceEKS))
+		Expect(s.EnableInstanceTypeCatalog).To(BeTrue())
+		Expect(s.InterruptionDeadLetterQueueName).To(Equal("karpenter-cluster-dlq"))
+		Expect(s.InterruptionMaxReceiveCount).To(Equal(3))
+		Expect(s.PreTerminationTopicARN).To(Equal("arn:aws:sns:us-west-2:111122223333:karpenter-termination"))
+		Expect(s.PreTerminationWebhookURL).To(Equal("https://cmdb.example.com/termination"))
+		Expect(s.EnableInstanceStatusChecks).To(BeTrue())
+		Expect(s.ReplaceImpairedInstances).To(BeTrue())
+		Expect(s.CloudWatchMetricsNamespace).To(Equal("Karpenter"))
+		Expect(s.TracingEndpoint).To(Equal("http://otel-collector.observability:4317"))
 	})
 	It("should succeed when setting values that no longer exist (backwards compatibility)", func() {
 		cm := &v1.ConfigMap{
@@ -155,6 +285,26 @@ var _ = Describe("Validation", func() {
 		_, err := (&settings.Settings{}).Inject(ctx, cm)
 		Expect(err).To(HaveOccurred())
 	})
+	It("should fail validation when clusterDNSServiceName isn't a valid service name", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterName":           "my-cluster",
+				"aws.clusterDNSServiceName": "CoreDNS",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when clusterDNSServiceNamespace is empty", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterName":                "my-cluster",
+				"aws.clusterDNSServiceNamespace": "",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
 	It("should fail validation with panic when vmMemoryOverheadPercent is negative", func() {
 		cm := &v1.ConfigMap{
 			Data: map[string]string{
@@ -166,6 +316,28 @@ var _ = Describe("Validation", func() {
 		_, err := (&settings.Settings{}).Inject(ctx, cm)
 		Expect(err).To(HaveOccurred())
 	})
+	It("should fail validation when vmMemoryOverheadPercentPerInstanceType has an invalid glob", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":                        "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":                            "my-cluster",
+				"aws.vmMemoryOverheadPercentPerInstanceType": `{"m5.[": 0.05}`,
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when vmMemoryOverheadPercentPerInstanceType isn't less than 1", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":                        "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":                            "my-cluster",
+				"aws.vmMemoryOverheadPercentPerInstanceType": `{"m5.*": 1}`,
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
 	It("should fail validation when tags have keys that are in the restricted set of keys", func() {
 		cm := &v1.ConfigMap{
 			Data: map[string]string{
@@ -207,6 +379,120 @@ var _ = Describe("Validation", func() {
 		_, err := (&settings.Settings{}).Inject(ctx, cm)
 		Expect(err).To(HaveOccurred())
 	})
+	It("should fail validation with minimumInstanceGeneration is negative", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.minimumInstanceGeneration": "-1",
+				"aws.clusterName":               "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when subnetSelectionStrategy is unknown", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.subnetSelectionStrategy": "leastAvailableIPs",
+				"aws.clusterName":             "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when kubernetesVersionSource is unknown", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.kubernetesVersionSource": "discovery",
+				"aws.clusterName":             "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when maxSecurityGroupsPerNetworkInterface is out of range", func() {
+		for _, limit := range []string{"0", "17"} {
+			cm := &v1.ConfigMap{
+				Data: map[string]string{
+					"aws.maxSecurityGroupsPerNetworkInterface": limit,
+					"aws.clusterName":                          "my-cluster",
+				},
+			}
+			_, err := (&settings.Settings{}).Inject(ctx, cm)
+			Expect(err).To(HaveOccurred())
+		}
+	})
+	It("should fail validation when interruptionMaxReceiveCount is less than 1", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.interruptionMaxReceiveCount": "0",
+				"aws.clusterName":                 "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when preTerminationTopicARN isn't the ARN of an SNS topic", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.preTerminationTopicARN": "arn:aws:sqs:us-west-2:111122223333:karpenter-termination",
+				"aws.clusterName":            "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when preTerminationWebhookURL isn't an http URL", func() {
+		for _, webhookURL := range []string{"cmdb.example.com/termination", "ftp://cmdb.example.com/termination"} {
+			cm := &v1.ConfigMap{
+				Data: map[string]string{
+					"aws.preTerminationWebhookURL": webhookURL,
+					"aws.clusterName":              "my-cluster",
+				},
+			}
+			_, err := (&settings.Settings{}).Inject(ctx, cm)
+			Expect(err).To(HaveOccurred())
+		}
+	})
+	It("should fail validation when replaceImpairedInstances is enabled without instance status checks", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.replaceImpairedInstances": "true",
+				"aws.clusterName":              "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when cloudWatchMetricsNamespace is reserved for AWS services", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.cloudWatchMetricsNamespace": "AWS/Karpenter",
+				"aws.clusterName":                "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when tracingEndpoint isn't an http or https URL", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterName":     "my-cluster",
+				"aws.tracingEndpoint": "otel-collector.observability:4317",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when assumeRoleARN isn't the ARN of an IAM role", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.assumeRoleARN": "arn:aws:iam::111222333444:user/testuser",
+				"aws.clusterName":   "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
 	It("should fail validation with assumeDurationRole is less then 15m", func() {
 		cm := &v1.ConfigMap{
 			Data: map[string]string{
@@ -217,4 +503,192 @@ var _ = Describe("Validation", func() {
 		_, err := (&settings.Settings{}).Inject(ctx, cm)
 		Expect(err).To(HaveOccurred())
 	})
+	It("should fail validation when assumeRoleExternalID has characters that STS doesn't allow", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.assumeRoleExternalID": "external id",
+				"aws.clusterName":          "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when assumeRoleSourceIdentity is longer than 64 characters", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.assumeRoleSourceIdentity": strings.Repeat("a", 65),
+				"aws.clusterName":              "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when an assumeRoleSessionTags key has the aws: prefix", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.assumeRoleSessionTags": `{"aws:team": "platform"}`,
+				"aws.clusterName":           "my-cluster",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when consolidationPriceThreshold is negative", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterName":                 "my-cluster",
+				"aws.consolidationPriceThreshold": "-0.01",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when consolidationPriceThresholdPercent isn't less than 1", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterName":                        "my-cluster",
+				"aws.consolidationPriceThresholdPercent": "1",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when allowedInstanceFamilies has an invalid glob", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":         "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":             "my-cluster",
+				"aws.allowedInstanceFamilies": "m5,c[",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when excludedInstanceTypes has an invalid glob", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":       "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":           "my-cluster",
+				"aws.excludedInstanceTypes": "[.metal",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when endpoints has a service that can't be overridden", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint": "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":     "my-cluster",
+				"aws.endpoints":       `{"s3": "https://s3.us-west-2.amazonaws.com"}`,
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when endpoints has an invalid URL", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint": "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":     "my-cluster",
+				"aws.endpoints":       `{"ec2": "vpce-0123.ec2.us-west-2.vpce.amazonaws.com"}`,
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when standbyRefreshInterval is less than 1m", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterName":            "my-cluster",
+				"aws.standbyRefreshInterval": "30s",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when garbageCollectionGracePeriod is less than 30s", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterName":                  "my-cluster",
+				"aws.garbageCollectionGracePeriod": "10s",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when stoppedInstanceTTL is less than 1m", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterName":        "my-cluster",
+				"aws.stoppedInstanceTTL": "30s",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when credentialsSource isn't a known source", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterName":       "my-cluster",
+				"aws.credentialsSource": "webIdentity",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when maxConcurrentLaunchesPerNodePool is negative", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterName":                      "my-cluster",
+				"aws.maxConcurrentLaunchesPerNodePool": "-1",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+	It("should fail validation when launchBurstPerNodePool is less than 1", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterName":                  "my-cluster",
+				"aws.launchesPerSecondPerNodePool": "1",
+				"aws.launchBurstPerNodePool":       "0",
+			},
+		}
+		_, err := (&settings.Settings{}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Overrides", func() {
+	It("should take precedence over the ConfigMap", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterName":             "my-cluster",
+				"aws.vmMemoryOverheadPercent": "0.1",
+				"aws.tags":                    `{"team": "my-team"}`,
+			},
+		}
+		ctx, err := (&settings.Overridden{Overrides: map[string]string{
+			"aws.clusterName": "my-other-cluster",
+			"aws.tags":        `{"team": "my-other-team"}`,
+		}}).Inject(ctx, cm)
+		Expect(err).ToNot(HaveOccurred())
+		s := settings.FromContext(ctx)
+		Expect(s.ClusterName).To(Equal("my-other-cluster"))
+		Expect(s.VMMemoryOverheadPercent).To(Equal(0.1))
+		Expect(s.Tags).To(Equal(map[string]string{"team": "my-other-team"}))
+		Expect(cm.Data["aws.clusterName"]).To(Equal("my-cluster"))
+	})
+	It("should fail validation when an override is invalid", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterName": "my-cluster",
+			},
+		}
+		_, err := (&settings.Overridden{Overrides: map[string]string{
+			"aws.vmMemoryOverheadPercent": "-1",
+		}}).Inject(ctx, cm)
+		Expect(err).To(HaveOccurred())
+	})
 })
@@ -23,6 +23,20 @@ import ()
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Settings) DeepCopyInto(out *Settings) {
 	*out = *in
+	if in.AssumeRoleSessionTags != nil {
+		in, out := &in.AssumeRoleSessionTags, &out.AssumeRoleSessionTags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.VMMemoryOverheadPercentPerInstanceType != nil {
+		in, out := &in.VMMemoryOverheadPercentPerInstanceType, &out.VMMemoryOverheadPercentPerInstanceType
+		*out = make(map[string]float64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.Tags != nil {
 		in, out := &in.Tags, &out.Tags
 		*out = make(map[string]string, len(*in))
@@ -30,6 +44,23 @@ func (in *Settings) DeepCopyInto(out *Settings) {
 			(*out)[key] = val
 		}
 	}
+	if in.AllowedInstanceFamilies != nil {
+		in, out := &in.AllowedInstanceFamilies, &out.AllowedInstanceFamilies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludedInstanceTypes != nil {
+		in, out := &in.ExcludedInstanceTypes, &out.ExcludedInstanceTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Settings.
@@ -16,10 +16,14 @@ package v1alpha1
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/mitchellh/hashstructure/v2"
+	"github.com/samber/lo"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
 )
 
 // Subnet contains resolved Subnet selector values utilized for node launch
@@ -30,6 +34,12 @@ type Subnet struct {
 	// The associated availability zone
 	// +required
 	Zone string `json:"zone"`
+	// The associated availability zone ID, which identifies the same physical zone across accounts
+	// +optional
+	ZoneID string `json:"zoneID,omitempty"`
+	// AvailableIPAddressCount is the number of unused private IPv4 addresses in the subnet
+	// +optional
+	AvailableIPAddressCount int64 `json:"availableIPAddressCount,omitempty"`
 }
 
 // SecurityGroup contains resolved SecurityGroup selector values utilized for node launch
@@ -69,6 +79,15 @@ type AWSNodeTemplateStatus struct {
 	// cluster under the AMI selectors.
 	// +optional
 	AMIs []AMI `json:"amis,omitempty"`
+	// InstanceProfile contains the resolved instance profile that is attached to launched nodes
+	// +optional
+	InstanceProfile string `json:"instanceProfile,omitempty"`
+	// UserDataHash is the hash of the UserData that userDataRef references, which nodes drift from when it changes
+	// +optional
+	UserDataHash string `json:"userDataHash,omitempty"`
+	// Conditions contains signals for whether the resolved state is usable for launches
+	// +optional
+	Conditions apis.Conditions `json:"conditions,omitempty"`
 }
 
 // AWSNodeTemplateSpec is the top level specification for the AWS Karpenter Provider.
@@ -79,13 +98,354 @@ type AWSNodeTemplateSpec struct {
 	// this UserData to ensure nodes are being provisioned with the correct configuration.
 	// +optional
 	UserData *string `json:"userData,omitempty"`
-	AWS      `json:",inline"`
+	// UserDataTemplating renders the UserData as a Go template before it's merged, substituting the {{ .ClusterName }},
+	// {{ .ClusterEndpoint }}, {{ .CABundle }} and {{ .NodePool }} variables, so that the UserData can be shared by clusters.
+	// +optional
+	UserDataTemplating *bool `json:"userDataTemplating,omitempty"`
+	// UserDataRef reads the UserData from a ConfigMap or Secret in Karpenter's namespace rather than from this resource,
+	// so that bootstrap secrets aren't stored in it. Nodes drift when the referenced UserData changes.
+	// +optional
+	UserDataRef *UserDataReference `json:"userDataRef,omitempty"`
+	// UserDataMergeOrder controls whether the custom UserData runs before or after the generated bootstrap script on AL2,
+	// so that agents can start either before the kubelet or once the node has joined the cluster. Defaults to PreBootstrap.
+	// +kubebuilder:validation:Enum:={PreBootstrap,PostBootstrap}
+	// +optional
+	UserDataMergeOrder *string `json:"userDataMergeOrder,omitempty"`
+	// UserDataCompression gzip compresses the merged UserData on AL2, which cloud-init decompresses, so that UserData
+	// larger than the 16KB that EC2 allows can be passed to nodes. Defaults to None.
+	// +kubebuilder:validation:Enum:={None,Gzip}
+	// +optional
+	UserDataCompression *string `json:"userDataCompression,omitempty"`
+	AWS                 `json:",inline"`
 	// AMISelector discovers AMIs to be used by Amazon EC2 tags.
 	// +optional
 	AMISelector map[string]string `json:"amiSelector,omitempty" hash:"ignore"`
+	// AMIMaxAge is the age that the AMI of a node can reach once a newer AMI is selected before the node is drifted,
+	// so that nodes are replaced on a predictable cadence rather than every time an AMI is released. If not set,
+	// nodes are drifted as soon as a newer AMI is selected.
+	// +optional
+	AMIMaxAge *metav1.Duration `json:"amiMaxAge,omitempty" hash:"ignore"`
 	// DetailedMonitoring controls if detailed monitoring is enabled for instances that are launched
 	// +optional
 	DetailedMonitoring *bool `json:"detailedMonitoring,omitempty"`
+	// CPUCreditSpecification is the credit option for CPU usage of burstable (T family) instance types. "unlimited"
+	// instances are charged for surplus CPU credits, which is added to the price of their offerings. Defaults to the
+	// EC2 default of the instance type.
+	// +kubebuilder:validation:Enum:={standard,unlimited}
+	// +optional
+	CPUCreditSpecification *string `json:"cpuCreditSpecification,omitempty"`
+	// EnclaveOptions enable AWS Nitro Enclaves on instances that are launched, for confidential-computing workloads.
+	// Only instance types that support enclaves are launched when they're enabled.
+	// +optional
+	EnclaveOptions *EnclaveOptions `json:"enclaveOptions,omitempty"`
+	// CPUOptions configure the processor of instances that are launched. Only instance types that support AMD SEV-SNP
+	// are launched when it's enabled.
+	// +optional
+	CPUOptions *CPUOptions `json:"cpuOptions,omitempty"`
+	// RequireEncryptionInTransit only launches instance types that encrypt traffic between instances in transit with
+	// their ENA, e.g. for compliance. Such instance types have the instance-encryption-in-transit-supported label.
+	// +optional
+	RequireEncryptionInTransit *bool `json:"requireEncryptionInTransit,omitempty"`
+	// InstanceInitiatedShutdownBehavior is what happens to instances that are launched when they're shut down from the
+	// operating system. "stop" keeps the instance and its volumes so that it can be started again. Defaults to the EC2
+	// default of "stop", but instances that were launched as spot are always terminated.
+	// +kubebuilder:validation:Enum:={stop,terminate}
+	// +optional
+	InstanceInitiatedShutdownBehavior *string `json:"instanceInitiatedShutdownBehavior,omitempty"`
+	// StartupTaints are registered on every node that is launched with this AWSNodeTemplate, in addition to the
+	// Provisioner's startup taints, e.g. node.cilium.io/agent-not-ready for a CNI agent that removes the taint once it's ready.
+	// +optional
+	StartupTaints []v1.Taint `json:"startupTaints,omitempty"`
+	// RequireSSMAgent holds nodes that are launched with this AWSNodeTemplate uninitialized until their SSM agent has
+	// registered the instance with Systems Manager, so that SSM operations such as patching can reach every node.
+	// +optional
+	RequireSSMAgent *bool `json:"requireSSMAgent,omitempty" hash:"ignore"`
+	// RegistrationTTL is how long an instance that is launched with this AWSNodeTemplate has to join the cluster before it's
+	// terminated and relaunched, with its offering marked unavailable so that the relaunch uses a different one. It
+	// must be at most 15m, after which instances that haven't joined the cluster are always terminated.
+	// +optional
+	RegistrationTTL *metav1.Duration `json:"registrationTTL,omitempty" hash:"ignore"`
+	// Bottlerocket settings are merged into the Bottlerocket settings that Karpenter generates, so that host containers,
+	// bootstrap containers, kernel sysctls and container registries can be configured without a full TOML userData.
+	// Settings from userData are overridden by these, which are in turn overridden by Karpenter's kubernetes settings.
+	// +optional
+	Bottlerocket *BottlerocketSettings `json:"bottlerocket,omitempty"`
+	// ContainerRegistries configures containerd on AL2 nodes to pull images from registries through mirrors, e.g. a
+	// pull-through cache, without custom userData.
+	// +optional
+	ContainerRegistries []ContainerRegistry `json:"containerRegistries,omitempty"`
+	// Kubelet configures kubelet settings that aren't part of the Provisioner's kubelet configuration. They're merged into
+	// the kubelet config file on AL2 nodes and into settings.kubernetes on Bottlerocket nodes.
+	// +optional
+	Kubelet *KubeletConfiguration `json:"kubelet,omitempty"`
+	// MaxPodsPerInstanceType overrides the maxPods of the instance types whose names match a glob, e.g. "m5.*" or
+	// "*.metal", taking precedence over the Provisioner's maxPods and the ENI limited pod density. When several globs
+	// match an instance type, the longest one is used.
+	// +optional
+	MaxPodsPerInstanceType map[string]int32 `json:"maxPodsPerInstanceType,omitempty"`
+	// NVIDIA configures the NVIDIA driver variant of the accelerated AMIs and the NVIDIA container runtime.
+	// +optional
+	NVIDIA *NVIDIAConfiguration `json:"nvidia,omitempty"`
+	// Windows configures the CSI proxy and gMSA on nodes of the Windows AMIFamilies.
+	// +optional
+	Windows *WindowsConfiguration `json:"windows,omitempty"`
+	// InstanceRequirements lets EC2 select the instance type of each launch from these attributes, rather than from
+	// overrides for each of the instance types that Karpenter considers, which keeps the fleet requests of very flexible
+	// Provisioners small. Karpenter only considers the instance types that match the attributes.
+	// +optional
+	InstanceRequirements *InstanceRequirements `json:"instanceRequirements,omitempty"`
+	// AssumeRoleARN is the ARN of an IAM role that is assumed to discover the subnets, security groups and AMIs of this
+	// AWSNodeTemplate, e.g. to select resources that are owned by a shared-services account. The role is assumed with the
+	// controller's credentials. Instances are still launched with the controller's credentials.
+	// +kubebuilder:validation:Pattern:="^arn:aws[a-z-]*:iam::[0-9]{12}:role/.+$"
+	// +optional
+	AssumeRoleARN *string `json:"assumeRoleARN,omitempty" hash:"ignore"`
+	// LaunchDryRun overrides the aws.enableLaunchDryRun setting for this AWSNodeTemplate. When enabled, a DryRun CreateFleet
+	// is made before launching so that broken credentials fail fast with an authorization error.
+	// +optional
+	LaunchDryRun *bool `json:"launchDryRun,omitempty" hash:"ignore"`
+	// DeletionPolicy controls what happens to Machines that reference this AWSNodeTemplate when it is deleted.
+	// "block" holds the AWSNodeTemplate in deletion until the Machines are gone, "cascade" deletes them.
+	// +kubebuilder:validation:Enum:={block,cascade}
+	// +optional
+	DeletionPolicy *string `json:"deletionPolicy,omitempty" hash:"ignore"`
+	// EphemeralStorageSizing sizes the volume that backs ephemeral storage, e.g. the Bottlerocket data volume,
+	// from the ephemeral-storage requests of the pods that a node is launched for.
+	// +optional
+	EphemeralStorageSizing *EphemeralStorageSizing `json:"ephemeralStorageSizing,omitempty"`
+}
+
+// EphemeralStorageSizing is the formula used to size the volume that backs ephemeral storage. The volume is sized to
+// the ephemeral-storage requests of the node plus Overhead, bounded by MinSize and MaxSize.
+type EphemeralStorageSizing struct {
+	// MinSize is the smallest volume that is launched. Defaults to the size of the ephemeral block device
+	// in BlockDeviceMappings, or to the AMIFamily's default.
+	// +optional
+	MinSize *resource.Quantity `json:"minSize,omitempty" hash:"string"`
+	// MaxSize is the largest volume that is launched. Instance types advertise this as their ephemeral-storage
+	// capacity so that pods with large ephemeral-storage requests can be scheduled, and launched NodeClaims
+	// advertise the size of their volume.
+	// +required
+	MaxSize resource.Quantity `json:"maxSize" hash:"string"`
+	// Overhead is added to the ephemeral-storage requests of the node to leave room for container images and logs.
+	// +optional
+	Overhead *resource.Quantity `json:"overhead,omitempty" hash:"string"`
+}
+
+// EnclaveOptions configure AWS Nitro Enclaves (https://docs.aws.amazon.com/enclaves/latest/user/nitro-enclave.html)
+type EnclaveOptions struct {
+	// Enabled launches instances with Nitro Enclaves enabled.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// CPUOptions configure the processor of instances
+type CPUOptions struct {
+	// AMDSEVSNP turns AMD SEV-SNP (https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/sev-snp.html) on or off.
+	// Defaults to the EC2 default, which is disabled.
+	// +kubebuilder:validation:Enum:={enabled,disabled}
+	// +optional
+	AMDSEVSNP *string `json:"amdSevSnp,omitempty"`
+}
+
+// UserDataReference references the key of a ConfigMap or Secret in Karpenter's namespace that holds the UserData
+type UserDataReference struct {
+	// Kind of the referenced object.
+	// +kubebuilder:validation:Enum:={ConfigMap,Secret}
+	// +required
+	Kind string `json:"kind"`
+	// Name of the ConfigMap or Secret.
+	// +required
+	Name string `json:"name"`
+	// Key of the UserData in the ConfigMap or Secret.
+	// +required
+	Key string `json:"key"`
+}
+
+// WindowsConfiguration configures the Windows features that the generated PowerShell sets up before bootstrapping
+type WindowsConfiguration struct {
+	// Variant selects between the EKS optimized Windows Server Core and Full AMIs. Defaults to Core.
+	// +kubebuilder:validation:Enum:={Core,Full}
+	// +optional
+	Variant *string `json:"variant,omitempty"`
+	// CSIProxy runs the CSI proxy as a Windows service, which CSI node plugins use to manage SMB and block storage on
+	// the host.
+	// +optional
+	CSIProxy *WindowsCSIProxy `json:"csiProxy,omitempty"`
+	// GMSA registers a Container Credential Guard plugin, which retrieves the gMSA credentials of the credential specs
+	// that pods reference on nodes that aren't joined to the domain.
+	// +optional
+	GMSA *WindowsGMSA `json:"gmsa,omitempty"`
+}
+
+// WindowsCSIProxy configures the CSI proxy, see https://github.com/kubernetes-csi/csi-proxy
+type WindowsCSIProxy struct {
+	// BinaryURL downloads csi-proxy.exe from the HTTPS URL. If not specified, the binary in the AMI is used.
+	// +optional
+	BinaryURL *string `json:"binaryURL,omitempty"`
+}
+
+// WindowsGMSA configures gMSA for pods on nodes that aren't joined to the domain, see
+// https://learn.microsoft.com/en-us/virtualization/windowscontainers/manage-containers/manage-serviceaccounts
+type WindowsGMSA struct {
+	// PluginCLSID is the COM class ID of the Container Credential Guard plugin that the AMI includes.
+	// +required
+	PluginCLSID string `json:"pluginCLSID"`
+}
+
+// NVIDIAConfiguration configures the NVIDIA GPU support of the nodes
+type NVIDIAConfiguration struct {
+	// Driver selects between the accelerated AMIs with NVIDIA's open GPU kernel modules and the ones with the
+	// proprietary kernel modules. Defaults to proprietary. It's supported by the AL2 and Bottlerocket AMIFamilies.
+	// +kubebuilder:validation:Enum:={open,proprietary}
+	// +optional
+	Driver *string `json:"driver,omitempty"`
+	// ContainerRuntime configures containerd to use the NVIDIA container runtime by default before the custom userData
+	// runs. It's supported by the Custom AMIFamily, whose AMIs must include the NVIDIA container toolkit.
+	// +optional
+	ContainerRuntime *bool `json:"containerRuntime,omitempty"`
+}
+
+// InstanceRequirements are the attributes of the instance types that EC2 selects from, see
+// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/ec2-fleet-attribute-based-instance-type-selection.html
+type InstanceRequirements struct {
+	// VCPU is the range of the number of vCPUs.
+	// +optional
+	VCPU *InstanceRequirementsRange `json:"vcpu,omitempty"`
+	// MemoryMiB is the range of the amount of memory, in MiB.
+	// +optional
+	MemoryMiB *InstanceRequirementsRange `json:"memoryMiB,omitempty"`
+	// AcceleratorCount is the range of the number of GPUs, FPGAs and inference accelerators. A maximum of 0 excludes
+	// instance types with accelerators.
+	// +optional
+	AcceleratorCount *InstanceRequirementsRange `json:"acceleratorCount,omitempty"`
+	// AcceleratorTypes are the types of accelerators, one of which the instance types must have.
+	// +optional
+	AcceleratorTypes []string `json:"acceleratorTypes,omitempty"`
+	// AcceleratorManufacturers are the manufacturers of accelerators, one of which the instance types must have.
+	// +optional
+	AcceleratorManufacturers []string `json:"acceleratorManufacturers,omitempty"`
+	// ExcludedInstanceTypes are the instance types that EC2 doesn't select, which can include * wildcards, e.g. "t2.*"
+	// or "m5a*" to exclude instance families.
+	// +kubebuilder:validation:MaxItems:=400
+	// +optional
+	ExcludedInstanceTypes []string `json:"excludedInstanceTypes,omitempty"`
+}
+
+// InstanceRequirementsRange is an inclusive range, which is unbounded on the side whose limit isn't set
+type InstanceRequirementsRange struct {
+	// +kubebuilder:validation:Minimum:=0
+	// +optional
+	Min *int64 `json:"min,omitempty"`
+	// +kubebuilder:validation:Minimum:=0
+	// +optional
+	Max *int64 `json:"max,omitempty"`
+}
+
+// KubeletConfiguration is a subset of the kubelet's configuration, see
+// https://kubernetes.io/docs/reference/config-api/kubelet-config.v1beta1/
+type KubeletConfiguration struct {
+	// ShutdownGracePeriod is how long the node delays shutdown by to terminate its pods.
+	// +optional
+	ShutdownGracePeriod *metav1.Duration `json:"shutdownGracePeriod,omitempty"`
+	// ShutdownGracePeriodCriticalPods is the part of ShutdownGracePeriod that is reserved for terminating critical pods.
+	// +optional
+	ShutdownGracePeriodCriticalPods *metav1.Duration `json:"shutdownGracePeriodCriticalPods,omitempty"`
+	// RegistryPullQPS limits the image pulls per second, 0 is unlimited.
+	// +optional
+	RegistryPullQPS *int32 `json:"registryPullQPS,omitempty"`
+	// RegistryBurst is the burst of image pulls that RegistryPullQPS allows.
+	// +optional
+	RegistryBurst *int32 `json:"registryBurst,omitempty"`
+	// CPUManagerPolicy is the kubelet's CPU manager policy. The static policy gives Guaranteed pods with integer CPU
+	// requests exclusive CPUs.
+	// +kubebuilder:validation:Enum:={none,static}
+	// +optional
+	CPUManagerPolicy *string `json:"cpuManagerPolicy,omitempty"`
+	// TopologyManagerPolicy is the kubelet's topology manager policy, which aligns the CPUs, memory and devices of a
+	// pod to NUMA nodes.
+	// +kubebuilder:validation:Enum:={none,best-effort,restricted,single-numa-node}
+	// +optional
+	TopologyManagerPolicy *string `json:"topologyManagerPolicy,omitempty"`
+	// MemoryManagerPolicy is the kubelet's memory manager policy. The Static policy pins the memory of Guaranteed pods
+	// to NUMA nodes.
+	// +kubebuilder:validation:Enum:={None,Static}
+	// +optional
+	MemoryManagerPolicy *string `json:"memoryManagerPolicy,omitempty"`
+}
+
+// ContainerRegistry is rendered into a containerd hosts.toml for the registry, see
+// https://github.com/containerd/containerd/blob/main/docs/hosts.md
+type ContainerRegistry struct {
+	// Registry is the registry host that is mirrored, e.g. docker.io, or "_default" for every registry.
+	// +required
+	Registry string `json:"registry"`
+	// Mirrors are the endpoints that images are pulled through, in the order that they're tried before the registry.
+	// +kubebuilder:validation:MinItems:=1
+	// +required
+	Mirrors []string `json:"mirrors"`
+	// Auth is the base64 encoded "username:password" that is sent to the mirrors. It's stored in plain text in the
+	// AWSNodeTemplate and in the instance's userData, so prefer short-lived or read-only credentials.
+	// +optional
+	Auth *string `json:"auth,omitempty"`
+}
+
+// BottlerocketSettings are the sections of the Bottlerocket settings API, beyond kubernetes, that can be configured
+// from an AWSNodeTemplate. See https://bottlerocket.dev/en/os/latest/#/api/settings/ for the meaning of each setting.
+type BottlerocketSettings struct {
+	// HostContainers configures host containers by name, e.g. the admin and control containers.
+	// +optional
+	HostContainers map[string]BottlerocketHostContainer `json:"hostContainers,omitempty"`
+	// BootstrapContainers configures bootstrap containers by name, which run before kubelet starts.
+	// +optional
+	BootstrapContainers map[string]BottlerocketBootstrapContainer `json:"bootstrapContainers,omitempty"`
+	// KernelSysctl are kernel parameters that are set on boot, e.g. net.core.somaxconn.
+	// +optional
+	KernelSysctl map[string]string `json:"kernelSysctl,omitempty"`
+	// RegistryMirrors are the endpoints that images from a registry are pulled through.
+	// +optional
+	RegistryMirrors []BottlerocketRegistryMirror `json:"registryMirrors,omitempty"`
+}
+
+type BottlerocketHostContainer struct {
+	// Source is the image of the host container.
+	// +optional
+	Source *string `json:"source,omitempty"`
+	// Enabled controls if the host container runs.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+	// Superpowered controls if the host container runs with elevated privileges.
+	// +optional
+	Superpowered *bool `json:"superpowered,omitempty"`
+	// UserData is base64 encoded data that is passed to the host container.
+	// +optional
+	UserData *string `json:"userData,omitempty"`
+}
+
+type BottlerocketBootstrapContainer struct {
+	// Source is the image of the bootstrap container.
+	// +optional
+	Source *string `json:"source,omitempty"`
+	// Mode controls if the bootstrap container runs on every boot, on the next boot only, or not at all.
+	// +kubebuilder:validation:Enum:={always,once,off}
+	// +optional
+	Mode *string `json:"mode,omitempty"`
+	// Essential controls if the boot fails when the bootstrap container fails.
+	// +optional
+	Essential *bool `json:"essential,omitempty"`
+	// UserData is base64 encoded data that is passed to the bootstrap container.
+	// +optional
+	UserData *string `json:"userData,omitempty"`
+}
+
+type BottlerocketRegistryMirror struct {
+	// Registry is the registry that is mirrored, e.g. docker.io, or "*" for every registry.
+	// +required
+	Registry string `json:"registry"`
+	// Endpoints are the mirror endpoints, in the order that they're tried.
+	// +kubebuilder:validation:MinItems:=1
+	// +required
+	Endpoints []string `json:"endpoints"`
 }
 
 // AWSNodeTemplate is the Schema for the AWSNodeTemplate API
@@ -110,6 +470,54 @@ func (a *AWSNodeTemplate) Hash() string {
 	return fmt.Sprint(hash)
 }
 
+// HashWithoutTags is the static-field hash of the AWSNodeTemplate without its tags, which nodes are compared with
+// rather than the static-field hash when the drift policy of the tags is InPlace
+func (a *AWSNodeTemplate) HashWithoutTags() string {
+	spec := a.Spec.DeepCopy()
+	spec.Tags = nil
+	hash, _ := hashstructure.Hash(spec, hashstructure.FormatV2, &hashstructure.HashOptions{
+		SlicesAsSets:    true,
+		IgnoreZeroValue: true,
+		ZeroNil:         true,
+	})
+
+	return fmt.Sprint(hash)
+}
+
+const (
+	// DriftPolicyReplace replaces the nodes whose field differs from the AWSNodeTemplate, which is the policy of every
+	// field by default
+	DriftPolicyReplace = "Replace"
+	// DriftPolicyInPlace updates the field of the nodes in place rather than replacing them
+	DriftPolicyInPlace = "InPlace"
+)
+
+// DriftPolicies are the policies that the fields support in the drift-policy annotation
+var DriftPolicies = map[string][]string{
+	"tags": {DriftPolicyReplace, DriftPolicyInPlace},
+}
+
+// ParseDriftPolicies parses the drift-policy annotation, a comma separated list of field=policy pairs such as
+// "tags=InPlace"
+func ParseDriftPolicies(annotation string) (map[string]string, error) {
+	policies := map[string]string{}
+	for _, pair := range strings.Split(annotation, ",") {
+		field, policy, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found {
+			return nil, fmt.Errorf("%q must be a field=policy pair", pair)
+		}
+		supported, ok := DriftPolicies[field]
+		if !ok {
+			return nil, fmt.Errorf("field %q must be one of %v", field, lo.Keys(DriftPolicies))
+		}
+		if !lo.Contains(supported, policy) {
+			return nil, fmt.Errorf("policy %q of field %q must be one of %v", policy, field, supported)
+		}
+		policies[field] = policy
+	}
+	return policies, nil
+}
+
 // AWSNodeTemplateList contains a list of AWSNodeTemplate
 // +kubebuilder:object:root=true
 type AWSNodeTemplateList struct {
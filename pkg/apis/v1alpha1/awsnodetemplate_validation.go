@@ -16,22 +16,70 @@ package v1alpha1
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/url"
+	"path"
 	"regexp"
+	"strings"
+	"text/template"
+	"time"
 
+	"github.com/pelletier/go-toml/v2"
+	"github.com/samber/lo"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"knative.dev/pkg/apis"
 
 	"github.com/aws/karpenter-core/pkg/utils/functional"
 )
 
 const (
-	userDataPath    = "userData"
-	amiSelectorPath = "amiSelector"
+	userDataPath               = "userData"
+	userDataTemplatingPath     = "userDataTemplating"
+	userDataRefPath            = "userDataRef"
+	userDataMergeOrderPath     = "userDataMergeOrder"
+	userDataCompressionPath    = "userDataCompression"
+	amiSelectorPath            = "amiSelector"
+	ephemeralStorageSizingPath = "ephemeralStorageSizing"
+	deletionPolicyPath         = "deletionPolicy"
+	startupTaintsPath          = "startupTaints"
+	bottlerocketPath           = "bottlerocket"
+	containerRegistriesPath    = "containerRegistries"
+	kubeletPath                = "kubelet"
+	maxPodsPerInstanceTypePath = "maxPodsPerInstanceType"
+	cpuCreditSpecificationPath = "cpuCreditSpecification"
+	enclaveOptionsPath         = "enclaveOptions"
+	cpuOptionsPath             = "cpuOptions"
+	shutdownBehaviorPath       = "instanceInitiatedShutdownBehavior"
+	assumeRoleARNPath          = "assumeRoleARN"
+	nvidiaPath                 = "nvidia"
+	windowsPath                = "windows"
+	instanceRequirementsPath   = "instanceRequirements"
+	encryptionInTransitPath    = "requireEncryptionInTransit"
+	amiMaxAgePath              = "amiMaxAge"
+	registrationTTLPath        = "registrationTTL"
 )
 
 var (
 	amiRegex = regexp.MustCompile("ami-[0-9a-z]+")
+	// registryRegex matches the registry hosts that containerd looks up in its hosts directory
+	registryRegex = regexp.MustCompile(`^[a-zA-Z0-9._-]+(:[0-9]+)?$`)
+	// clsidRegex matches a COM class ID without braces
+	clsidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-([0-9a-fA-F]{4}-){3}[0-9a-fA-F]{12}$`)
+	// roleARNRegex matches the ARN of an IAM role in any partition
+	roleARNRegex = regexp.MustCompile(`^arn:aws[a-z-]*:iam::[0-9]{12}:role/.+$`)
+	// excludedInstanceTypeRegex matches instance type names and the * wildcards that EC2 accepts in them
+	excludedInstanceTypeRegex = regexp.MustCompile(`^[a-z0-9.*-]+$`)
+	// maxRegistrationTTL is the registration TTL after which karpenter-core terminates instances that haven't joined the
+	// cluster, regardless of the NodeClass
+	maxRegistrationTTL = 15 * time.Minute
 )
 
 func (a *AWSNodeTemplate) SupportedVerbs() []admissionregistrationv1.OperationType {
@@ -44,17 +92,48 @@ func (a *AWSNodeTemplate) SupportedVerbs() []admissionregistrationv1.OperationTy
 func (a *AWSNodeTemplate) Validate(ctx context.Context) (errs *apis.FieldError) {
 	return errs.Also(
 		apis.ValidateObjectMetadata(a).ViaField("metadata"),
+		a.validateDriftPolicy().ViaField("metadata"),
 		a.Spec.validate(ctx).ViaField("spec"),
 	)
 }
 
+func (a *AWSNodeTemplate) validateDriftPolicy() (errs *apis.FieldError) {
+	if policy, ok := a.Annotations[AnnotationDriftPolicy]; ok {
+		if _, err := ParseDriftPolicies(policy); err != nil {
+			return apis.ErrInvalidValue(policy, "", err.Error()).ViaKey(AnnotationDriftPolicy).ViaField("annotations")
+		}
+	}
+	return nil
+}
+
 func (a *AWSNodeTemplateSpec) validate(_ context.Context) (errs *apis.FieldError) {
 	return errs.Also(
 		a.AWS.Validate(),
 		a.validateUserData(),
+		a.validateUserDataRef(),
+		a.validateUserDataMergeOrder(),
+		a.validateUserDataCompression(),
 		a.validateAMISelector(),
 		a.validateAMIFamily(),
 		a.validateTags(),
+		a.validateEphemeralStorageSizing(),
+		a.validateDeletionPolicy(),
+		a.validateStartupTaints().ViaField(startupTaintsPath),
+		a.validateBottlerocket().ViaField(bottlerocketPath),
+		a.validateContainerRegistries().ViaField(containerRegistriesPath),
+		a.validateKubelet().ViaField(kubeletPath),
+		a.validateMaxPodsPerInstanceType().ViaField(maxPodsPerInstanceTypePath),
+		a.validateCPUCreditSpecification(),
+		a.validateEnclaveOptions(),
+		a.validateCPUOptions(),
+		a.validateInstanceInitiatedShutdownBehavior(),
+		a.validateAssumeRoleARN(),
+		a.validateNVIDIA().ViaField(nvidiaPath),
+		a.validateWindows().ViaField(windowsPath),
+		a.validateInstanceRequirements().ViaField(instanceRequirementsPath),
+		a.validateRequireEncryptionInTransit(),
+		a.validateAMIMaxAge(),
+		a.validateRegistrationTTL(),
 	)
 }
 
@@ -65,6 +144,145 @@ func (a *AWSNodeTemplateSpec) validateUserData() (errs *apis.FieldError) {
 	if a.LaunchTemplateName != nil {
 		errs = errs.Also(apis.ErrMultipleOneOf(userDataPath, launchTemplatePath))
 	}
+	if lo.Contains([]string{AMIFamilyWindows2019, AMIFamilyWindows2022}, lo.FromPtr(a.AMIFamily)) {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("%s AMIFamily is not currently supported with custom userData", lo.FromPtr(a.AMIFamily)), userDataPath))
+	}
+	if lo.FromPtr(a.UserDataTemplating) {
+		if _, err := template.New(userDataPath).Parse(*a.UserData); err != nil {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("must be a valid template with %s enabled, %s", userDataTemplatingPath, err), userDataPath))
+		}
+		// Templated userData is only valid once it's rendered, so it's validated when it's resolved
+		return errs
+	}
+	switch lo.FromPtr(a.AMIFamily) {
+	case AMIFamilyBottlerocket:
+		errs = errs.Also(validateBottlerocketUserData(*a.UserData).ViaField(userDataPath))
+	case "", AMIFamilyAL2, AMIFamilyUbuntu:
+		errs = errs.Also(validateMIMEUserData(*a.UserData).ViaField(userDataPath))
+	}
+	// Karpenter merges its own bootstrap configuration into the userData, so the userData that's launched is larger
+	// still and is checked against the limit again when it's resolved
+	if size := base64.StdEncoding.EncodedLen(len(*a.UserData)); size > UserDataMaxSize && lo.FromPtr(a.UserDataCompression) != UserDataCompressionGzip {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("is %d bytes once base64 encoded, exceeding the EC2 limit of %d bytes", size, UserDataMaxSize), userDataPath))
+	}
+	return errs
+}
+
+// validateMIMEUserData validates that userData in the MIME multi-part format can be read, since it's merged with the
+// bootstrap script part by part. UserData in any other format is wrapped in a single part when it's merged.
+func validateMIMEUserData(userData string) *apis.FieldError {
+	if !strings.HasPrefix(strings.TrimSpace(userData), "MIME-Version:") && !strings.HasPrefix(strings.TrimSpace(userData), "Content-Type:") {
+		return nil
+	}
+	if err := readMIMEParts(userData); err != nil {
+		return apis.ErrGeneric(fmt.Sprintf("must be a valid MIME multi-part archive, %s", err))
+	}
+	return nil
+}
+
+func readMIMEParts(userData string) error {
+	message, err := mail.ReadMessage(strings.NewReader(userData))
+	if err != nil {
+		return err
+	}
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("parsing content type, %w", err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return fmt.Errorf("content type %s is not multipart", mediaType)
+	}
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := io.ReadAll(part); err != nil {
+			return err
+		}
+	}
+}
+
+// validateBottlerocketUserData validates that the userData is TOML that doesn't set the settings that Karpenter always
+// sets for the cluster, which would otherwise only fail or be overwritten once a node is launched
+func validateBottlerocketUserData(userData string) *apis.FieldError {
+	config := map[string]interface{}{}
+	if err := toml.Unmarshal([]byte(userData), &config); err != nil {
+		return apis.ErrGeneric(fmt.Sprintf("must be valid TOML with the %s AMIFamily, %s", AMIFamilyBottlerocket, err))
+	}
+	settings, _ := config["settings"].(map[string]interface{})
+	kubernetes, _ := settings["kubernetes"].(map[string]interface{})
+	var errs *apis.FieldError
+	for _, key := range BottlerocketClusterSettings {
+		if _, ok := kubernetes[key]; ok {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("settings.kubernetes.%s is set by Karpenter and can't be set in userData", key)))
+		}
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateUserDataRef() (errs *apis.FieldError) {
+	if a.UserDataRef == nil {
+		return nil
+	}
+	if a.UserData != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(userDataPath, userDataRefPath))
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(userDataRefPath, launchTemplatePath))
+	}
+	if lo.Contains([]string{AMIFamilyWindows2019, AMIFamilyWindows2022}, lo.FromPtr(a.AMIFamily)) {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("%s AMIFamily is not currently supported with custom userData", lo.FromPtr(a.AMIFamily)), userDataRefPath))
+	}
+	return errs.Also(a.UserDataRef.validate().ViaField(userDataRefPath))
+}
+
+func (a *AWSNodeTemplateSpec) validateUserDataMergeOrder() (errs *apis.FieldError) {
+	if a.UserDataMergeOrder == nil {
+		return nil
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(userDataMergeOrderPath, launchTemplatePath))
+	}
+	if a.AMIFamily != nil && *a.AMIFamily != AMIFamilyAL2 {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("can only be set with the %s AMIFamily", AMIFamilyAL2), userDataMergeOrderPath))
+	}
+	if !lo.Contains(UserDataMergeOrders, *a.UserDataMergeOrder) {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s not in %v", *a.UserDataMergeOrder, strings.Join(UserDataMergeOrders, ", ")), userDataMergeOrderPath))
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateUserDataCompression() (errs *apis.FieldError) {
+	if a.UserDataCompression == nil {
+		return nil
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(userDataCompressionPath, launchTemplatePath))
+	}
+	if a.AMIFamily != nil && *a.AMIFamily != AMIFamilyAL2 {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("can only be set with the %s AMIFamily", AMIFamilyAL2), userDataCompressionPath))
+	}
+	if !lo.Contains(UserDataCompressions, *a.UserDataCompression) {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s not in %v", *a.UserDataCompression, strings.Join(UserDataCompressions, ", ")), userDataCompressionPath))
+	}
+	return errs
+}
+
+func (in *UserDataReference) validate() (errs *apis.FieldError) {
+	if !lo.Contains(UserDataRefKinds, in.Kind) {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s not in %v", in.Kind, strings.Join(UserDataRefKinds, ", ")), "kind"))
+	}
+	if in.Name == "" {
+		errs = errs.Also(apis.ErrMissingField("name"))
+	}
+	if in.Key == "" {
+		errs = errs.Also(apis.ErrMissingField("key"))
+	}
 	return errs
 }
 
@@ -78,6 +296,391 @@ func (a *AWSNodeTemplateSpec) validateAMIFamily() (errs *apis.FieldError) {
 	return errs
 }
 
+func (a *AWSNodeTemplateSpec) validateEphemeralStorageSizing() (errs *apis.FieldError) {
+	if a.EphemeralStorageSizing == nil {
+		return nil
+	}
+	if a.AMIFamily != nil && *a.AMIFamily == AMIFamilyCustom {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("%s AMIFamily has no known ephemeral block device to size", AMIFamilyCustom), ephemeralStorageSizingPath))
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(ephemeralStorageSizingPath, launchTemplatePath))
+	}
+	return errs.Also(a.EphemeralStorageSizing.validate().ViaField(ephemeralStorageSizingPath))
+}
+
+func (a *AWSNodeTemplateSpec) validateStartupTaints() (errs *apis.FieldError) {
+	if len(a.StartupTaints) == 0 {
+		return nil
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("cannot be set with %s", launchTemplatePath)))
+	}
+	if a.AMIFamily != nil && *a.AMIFamily == AMIFamilyCustom {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("%s AMIFamily doesn't generate the bootstrap configuration that registers taints", AMIFamilyCustom)))
+	}
+	existing := map[string]struct{}{}
+	for i, taint := range a.StartupTaints {
+		for _, err := range validation.IsQualifiedName(taint.Key) {
+			errs = errs.Also(apis.ErrInvalidArrayValue(err, "key", i))
+		}
+		if taint.Value != "" {
+			for _, err := range validation.IsQualifiedName(taint.Value) {
+				errs = errs.Also(apis.ErrInvalidArrayValue(err, "value", i))
+			}
+		}
+		switch taint.Effect {
+		case v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute:
+		default:
+			errs = errs.Also(apis.ErrInvalidArrayValue(taint.Effect, "effect", i))
+		}
+		if _, ok := existing[taint.Key+string(taint.Effect)]; ok {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("duplicate taint Key/Effect pair %s=%s", taint.Key, taint.Effect)).ViaIndex(i))
+		}
+		existing[taint.Key+string(taint.Effect)] = struct{}{}
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateBottlerocket() (errs *apis.FieldError) {
+	if a.Bottlerocket == nil {
+		return nil
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("cannot be set with %s", launchTemplatePath)))
+	}
+	if a.AMIFamily == nil || *a.AMIFamily != AMIFamilyBottlerocket {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("can only be set with the %s AMIFamily", AMIFamilyBottlerocket)))
+	}
+	return errs.Also(a.Bottlerocket.validate())
+}
+
+func (a *AWSNodeTemplateSpec) validateContainerRegistries() (errs *apis.FieldError) {
+	if len(a.ContainerRegistries) == 0 {
+		return nil
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("cannot be set with %s", launchTemplatePath)))
+	}
+	if a.AMIFamily != nil && *a.AMIFamily != AMIFamilyAL2 {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("can only be set with the %s AMIFamily", AMIFamilyAL2)))
+	}
+	registries := map[string]struct{}{}
+	for i, registry := range a.ContainerRegistries {
+		errs = errs.Also(registry.validate().ViaIndex(i))
+		if _, ok := registries[registry.Registry]; ok {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("duplicate registry %s", registry.Registry)).ViaIndex(i))
+		}
+		registries[registry.Registry] = struct{}{}
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateKubelet() (errs *apis.FieldError) {
+	if a.Kubelet == nil {
+		return nil
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("cannot be set with %s", launchTemplatePath)))
+	}
+	if !lo.Contains([]string{"", AMIFamilyAL2, AMIFamilyBottlerocket}, lo.FromPtr(a.AMIFamily)) {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("can only be set with the %s or %s AMIFamily", AMIFamilyAL2, AMIFamilyBottlerocket)))
+	}
+	return errs.Also(a.Kubelet.validate())
+}
+
+func (a *AWSNodeTemplateSpec) validateAssumeRoleARN() (errs *apis.FieldError) {
+	if a.AssumeRoleARN != nil && !roleARNRegex.MatchString(*a.AssumeRoleARN) {
+		return apis.ErrInvalidValue(*a.AssumeRoleARN, assumeRoleARNPath, "must be the ARN of an IAM role")
+	}
+	return nil
+}
+
+func (a *AWSNodeTemplateSpec) validateCPUCreditSpecification() (errs *apis.FieldError) {
+	if a.CPUCreditSpecification == nil {
+		return nil
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(cpuCreditSpecificationPath, launchTemplatePath))
+	}
+	if !lo.Contains(CPUCreditSpecifications, *a.CPUCreditSpecification) {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s not in %v", *a.CPUCreditSpecification, strings.Join(CPUCreditSpecifications, ", ")), cpuCreditSpecificationPath))
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateEnclaveOptions() (errs *apis.FieldError) {
+	if a.EnclaveOptions != nil && a.LaunchTemplateName != nil {
+		return apis.ErrMultipleOneOf(enclaveOptionsPath, launchTemplatePath)
+	}
+	return nil
+}
+
+func (a *AWSNodeTemplateSpec) validateCPUOptions() (errs *apis.FieldError) {
+	if a.CPUOptions == nil {
+		return nil
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(cpuOptionsPath, launchTemplatePath))
+	}
+	if a.CPUOptions.AMDSEVSNP != nil && !lo.Contains(AMDSEVSNPs, *a.CPUOptions.AMDSEVSNP) {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s not in %v", *a.CPUOptions.AMDSEVSNP, strings.Join(AMDSEVSNPs, ", ")), "amdSevSnp").ViaField(cpuOptionsPath))
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateInstanceInitiatedShutdownBehavior() (errs *apis.FieldError) {
+	if a.InstanceInitiatedShutdownBehavior == nil {
+		return nil
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(shutdownBehaviorPath, launchTemplatePath))
+	}
+	if !lo.Contains(InstanceInitiatedShutdownBehaviors, *a.InstanceInitiatedShutdownBehavior) {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s not in %v", *a.InstanceInitiatedShutdownBehavior, strings.Join(InstanceInitiatedShutdownBehaviors, ", ")), shutdownBehaviorPath))
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateMaxPodsPerInstanceType() (errs *apis.FieldError) {
+	if len(a.MaxPodsPerInstanceType) == 0 {
+		return nil
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("cannot be set with %s", launchTemplatePath)))
+	}
+	for pattern, maxPods := range a.MaxPodsPerInstanceType {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = errs.Also(apis.ErrInvalidKeyName(pattern, apis.CurrentField, "must be a valid glob"))
+		}
+		if maxPods <= 0 {
+			errs = errs.Also(apis.ErrInvalidValue(maxPods, apis.CurrentField, "must be greater than 0").ViaKey(pattern))
+		}
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateNVIDIA() (errs *apis.FieldError) {
+	if a.NVIDIA == nil {
+		return nil
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("cannot be set with %s", launchTemplatePath)))
+	}
+	if a.NVIDIA.Driver != nil {
+		if !lo.Contains(NVIDIADrivers, *a.NVIDIA.Driver) {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s not in %v", *a.NVIDIA.Driver, strings.Join(NVIDIADrivers, ", ")), "driver"))
+		}
+		if !lo.Contains([]string{"", AMIFamilyAL2, AMIFamilyBottlerocket}, lo.FromPtr(a.AMIFamily)) {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("can only be set with the %s or %s AMIFamily", AMIFamilyAL2, AMIFamilyBottlerocket), "driver"))
+		}
+		if len(a.AMISelector) > 0 {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("cannot be set with %s", amiSelectorPath), "driver"))
+		}
+	}
+	if lo.FromPtr(a.NVIDIA.ContainerRuntime) && lo.FromPtr(a.AMIFamily) != AMIFamilyCustom {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("can only be enabled with the %s AMIFamily", AMIFamilyCustom), "containerRuntime"))
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateWindows() (errs *apis.FieldError) {
+	if a.Windows == nil {
+		return nil
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("cannot be set with %s", launchTemplatePath)))
+	}
+	if !lo.Contains([]string{AMIFamilyWindows2019, AMIFamilyWindows2022}, lo.FromPtr(a.AMIFamily)) {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("can only be set with the %s or %s AMIFamily", AMIFamilyWindows2019, AMIFamilyWindows2022)))
+	}
+	if a.Windows.Variant != nil && len(a.AMISelector) > 0 {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("cannot be set with %s", amiSelectorPath), "variant"))
+	}
+	return errs.Also(a.Windows.validate())
+}
+
+func (a *AWSNodeTemplateSpec) validateInstanceRequirements() *apis.FieldError {
+	if a.InstanceRequirements == nil {
+		return nil
+	}
+	return a.InstanceRequirements.validate()
+}
+
+// validateRequireEncryptionInTransit rejects instance requirements alongside requireEncryptionInTransit, since EC2
+// can't select instance types by their support for encryption in transit
+func (a *AWSNodeTemplateSpec) validateRequireEncryptionInTransit() (errs *apis.FieldError) {
+	if lo.FromPtr(a.RequireEncryptionInTransit) && a.InstanceRequirements != nil {
+		return errs.Also(apis.ErrMultipleOneOf(encryptionInTransitPath, instanceRequirementsPath))
+	}
+	return nil
+}
+
+func (a *AWSNodeTemplateSpec) validateAMIMaxAge() (errs *apis.FieldError) {
+	if a.AMIMaxAge != nil && a.AMIMaxAge.Duration <= 0 {
+		return errs.Also(apis.ErrInvalidValue(a.AMIMaxAge.Duration.String(), amiMaxAgePath, "must be positive"))
+	}
+	return nil
+}
+
+func (a *AWSNodeTemplateSpec) validateRegistrationTTL() (errs *apis.FieldError) {
+	if a.RegistrationTTL != nil && (a.RegistrationTTL.Duration <= 0 || a.RegistrationTTL.Duration > maxRegistrationTTL) {
+		return errs.Also(apis.ErrOutOfBoundsValue(a.RegistrationTTL.Duration.String(), "0s", maxRegistrationTTL.String(), registrationTTLPath))
+	}
+	return nil
+}
+
+func (in *InstanceRequirements) validate() (errs *apis.FieldError) {
+	errs = errs.Also(in.VCPU.validate().ViaField("vcpu"), in.MemoryMiB.validate().ViaField("memoryMiB"), in.AcceleratorCount.validate().ViaField("acceleratorCount"))
+	for i, acceleratorType := range in.AcceleratorTypes {
+		if !lo.Contains(AcceleratorTypes, acceleratorType) {
+			errs = errs.Also(apis.ErrInvalidArrayValue(fmt.Sprintf("%s not in %v", acceleratorType, strings.Join(AcceleratorTypes, ", ")), "acceleratorTypes", i))
+		}
+	}
+	for i, manufacturer := range in.AcceleratorManufacturers {
+		if !lo.Contains(AcceleratorManufacturers, manufacturer) {
+			errs = errs.Also(apis.ErrInvalidArrayValue(fmt.Sprintf("%s not in %v", manufacturer, strings.Join(AcceleratorManufacturers, ", ")), "acceleratorManufacturers", i))
+		}
+	}
+	if in.AcceleratorCount != nil && in.AcceleratorCount.Max != nil && *in.AcceleratorCount.Max == 0 && (len(in.AcceleratorTypes) > 0 || len(in.AcceleratorManufacturers) > 0) {
+		errs = errs.Also(apis.ErrGeneric("acceleratorTypes and acceleratorManufacturers can't be set when acceleratorCount excludes accelerators"))
+	}
+	for i, instanceType := range in.ExcludedInstanceTypes {
+		if _, err := path.Match(instanceType, ""); err != nil || !excludedInstanceTypeRegex.MatchString(instanceType) {
+			errs = errs.Also(apis.ErrInvalidArrayValue(instanceType, "excludedInstanceTypes", i))
+		}
+	}
+	return errs
+}
+
+func (in *InstanceRequirementsRange) validate() *apis.FieldError {
+	if in != nil && in.Min != nil && in.Max != nil && *in.Min > *in.Max {
+		return apis.ErrGeneric(fmt.Sprintf("min %d is greater than max %d", *in.Min, *in.Max))
+	}
+	return nil
+}
+
+func (a *AWSNodeTemplateSpec) validateDeletionPolicy() *apis.FieldError {
+	if a.DeletionPolicy == nil {
+		return nil
+	}
+	return a.AWS.validateStringEnum(*a.DeletionPolicy, deletionPolicyPath, SupportedDeletionPolicies)
+}
+
+func (in *EphemeralStorageSizing) validate() (errs *apis.FieldError) {
+	if in.MaxSize.Cmp(minVolumeSize) == -1 || in.MaxSize.Cmp(maxVolumeSize) == 1 {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(in.MaxSize.String(), minVolumeSize.String(), maxVolumeSize.String(), "maxSize"))
+	}
+	if in.MinSize != nil && (in.MinSize.Cmp(minVolumeSize) == -1 || in.MinSize.Cmp(in.MaxSize) == 1) {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(in.MinSize.String(), minVolumeSize.String(), in.MaxSize.String(), "minSize"))
+	}
+	if in.Overhead != nil && in.Overhead.Sign() < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(in.Overhead.String(), "overhead", "must not be negative"))
+	}
+	return errs
+}
+
+func (in *BottlerocketSettings) validate() (errs *apis.FieldError) {
+	for name := range in.HostContainers {
+		if name == "" {
+			errs = errs.Also(apis.ErrInvalidKeyName(`""`, "hostContainers"))
+		}
+	}
+	for name, container := range in.BootstrapContainers {
+		if name == "" {
+			errs = errs.Also(apis.ErrInvalidKeyName(`""`, "bootstrapContainers"))
+		}
+		if container.Mode != nil && !lo.Contains(BottlerocketBootstrapContainerModes, *container.Mode) {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s not in %v", *container.Mode, strings.Join(BottlerocketBootstrapContainerModes, ", ")), "mode").ViaKey(name).ViaField("bootstrapContainers"))
+		}
+	}
+	for key := range in.KernelSysctl {
+		if key == "" {
+			errs = errs.Also(apis.ErrInvalidKeyName(`""`, "kernelSysctl"))
+		}
+	}
+	mirrors := map[string]struct{}{}
+	for i, mirror := range in.RegistryMirrors {
+		if mirror.Registry == "" {
+			errs = errs.Also(apis.ErrMissingField("registry").ViaFieldIndex("registryMirrors", i))
+		}
+		if len(mirror.Endpoints) == 0 {
+			errs = errs.Also(apis.ErrMissingField("endpoints").ViaFieldIndex("registryMirrors", i))
+		}
+		if _, ok := mirrors[mirror.Registry]; ok {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("duplicate registry %s", mirror.Registry)).ViaFieldIndex("registryMirrors", i))
+		}
+		mirrors[mirror.Registry] = struct{}{}
+	}
+	return errs
+}
+
+func (in *ContainerRegistry) validate() (errs *apis.FieldError) {
+	if in.Registry == "" {
+		errs = errs.Also(apis.ErrMissingField("registry"))
+	} else if !registryRegex.MatchString(in.Registry) {
+		errs = errs.Also(apis.ErrInvalidValue(in.Registry, "registry", "must be a registry host, optionally with a port, without a scheme or path"))
+	}
+	if len(in.Mirrors) == 0 {
+		errs = errs.Also(apis.ErrMissingField("mirrors"))
+	}
+	for i, mirror := range in.Mirrors {
+		if u, err := url.Parse(mirror); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = errs.Also(apis.ErrInvalidArrayValue(mirror, "mirrors", i))
+		}
+	}
+	if in.Auth != nil {
+		if _, err := base64.StdEncoding.DecodeString(*in.Auth); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue("<redacted>", "auth", "must be base64 encoded"))
+		}
+	}
+	return errs
+}
+
+func (in *WindowsConfiguration) validate() (errs *apis.FieldError) {
+	if in.Variant != nil && !lo.Contains(WindowsVariants, *in.Variant) {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s not in %v", *in.Variant, strings.Join(WindowsVariants, ", ")), "variant"))
+	}
+	if in.CSIProxy != nil && in.CSIProxy.BinaryURL != nil {
+		if u, err := url.Parse(*in.CSIProxy.BinaryURL); err != nil || u.Scheme != "https" || u.Host == "" || strings.ContainsAny(*in.CSIProxy.BinaryURL, "'\"`") {
+			errs = errs.Also(apis.ErrInvalidValue(*in.CSIProxy.BinaryURL, "binaryURL", "must be an https URL").ViaField("csiProxy"))
+		}
+	}
+	if in.GMSA != nil && !clsidRegex.MatchString(in.GMSA.PluginCLSID) {
+		errs = errs.Also(apis.ErrInvalidValue(in.GMSA.PluginCLSID, "pluginCLSID", "must be a COM class ID, e.g. 01234567-89ab-cdef-0123-456789abcdef").ViaField("gmsa"))
+	}
+	return errs
+}
+
+func (in *KubeletConfiguration) validate() (errs *apis.FieldError) {
+	if in.ShutdownGracePeriod != nil && in.ShutdownGracePeriod.Duration < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(in.ShutdownGracePeriod.Duration.String(), "shutdownGracePeriod", "must not be negative"))
+	}
+	if in.ShutdownGracePeriodCriticalPods != nil {
+		if in.ShutdownGracePeriod == nil {
+			errs = errs.Also(apis.ErrGeneric("shutdownGracePeriod must be set with shutdownGracePeriodCriticalPods", "shutdownGracePeriodCriticalPods"))
+		} else if in.ShutdownGracePeriodCriticalPods.Duration < 0 || in.ShutdownGracePeriodCriticalPods.Duration > in.ShutdownGracePeriod.Duration {
+			errs = errs.Also(apis.ErrOutOfBoundsValue(in.ShutdownGracePeriodCriticalPods.Duration.String(), "0s", in.ShutdownGracePeriod.Duration.String(), "shutdownGracePeriodCriticalPods"))
+		}
+	}
+	if in.RegistryPullQPS != nil && *in.RegistryPullQPS < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(*in.RegistryPullQPS, "registryPullQPS", "must not be negative"))
+	}
+	if in.RegistryBurst != nil && *in.RegistryBurst < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(*in.RegistryBurst, "registryBurst", "must not be negative"))
+	}
+	if in.CPUManagerPolicy != nil && !lo.Contains(CPUManagerPolicies, *in.CPUManagerPolicy) {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s not in %v", *in.CPUManagerPolicy, strings.Join(CPUManagerPolicies, ", ")), "cpuManagerPolicy"))
+	}
+	if in.TopologyManagerPolicy != nil && !lo.Contains(TopologyManagerPolicies, *in.TopologyManagerPolicy) {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s not in %v", *in.TopologyManagerPolicy, strings.Join(TopologyManagerPolicies, ", ")), "topologyManagerPolicy"))
+	}
+	if in.MemoryManagerPolicy != nil && !lo.Contains(MemoryManagerPolicies, *in.MemoryManagerPolicy) {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s not in %v", *in.MemoryManagerPolicy, strings.Join(MemoryManagerPolicies, ", ")), "memoryManagerPolicy"))
+	}
+	return errs
+}
+
 //nolint:gocyclo
 func (a *AWSNodeTemplateSpec) validateAMISelector() (errs *apis.FieldError) {
 	if a.AMISelector == nil {
@@ -105,6 +708,10 @@ func (a *AWSNodeTemplateSpec) validateAMISelector() (errs *apis.FieldError) {
 	if idFilterKeyUsed != "" && len(a.AMISelector) > 1 {
 		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("%q filter is mutually exclusive, cannot be set with a combination of other filters in", idFilterKeyUsed), amiSelectorPath))
 	}
+	// Owners only narrow the AMIs that the other filters select, so they can't select AMIs on their own
+	if _, ok := a.AMISelector["aws::owners"]; ok && len(a.AMISelector) == 1 {
+		errs = errs.Also(apis.ErrGeneric(`"aws::owners" filter must be set with a combination of other filters in`, amiSelectorPath))
+	}
 	return errs
 }
 
@@ -38,6 +38,11 @@ type AWS struct {
 	// SubnetSelector discovers subnets by tags. A value of "" is a wildcard.
 	// +optional
 	SubnetSelector map[string]string `json:"subnetSelector,omitempty" hash:"ignore"`
+	// SubnetPolicy restricts which of the subnets matched by SubnetSelector may be used for launches.
+	// "privateOnly" excludes subnets that map public IPs on launch or that route to an internet gateway.
+	// +kubebuilder:validation:Enum:={any,privateOnly}
+	// +optional
+	SubnetPolicy *string `json:"subnetPolicy,omitempty" hash:"ignore"`
 	// SecurityGroups specify the names of the security groups.
 	// +optional
 	SecurityGroupSelector map[string]string `json:"securityGroupSelector,omitempty" hash:"ignore"`
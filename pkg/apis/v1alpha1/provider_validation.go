@@ -16,10 +16,12 @@ package v1alpha1
 
 import (
 	"fmt"
+	"math"
 	"regexp"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/samber/lo"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"knative.dev/pkg/apis"
 
@@ -30,6 +32,7 @@ const (
 	launchTemplatePath          = "launchTemplate"
 	securityGroupSelectorPath   = "securityGroupSelector"
 	fieldPathSubnetSelectorPath = "subnetSelector"
+	subnetPolicyPath            = "subnetPolicy"
 	amiFamilyPath               = "amiFamily"
 	metadataOptionsPath         = "metadataOptions"
 	instanceProfilePath         = "instanceProfile"
@@ -41,8 +44,27 @@ var (
 	maxVolumeSize      = *resource.NewScaledQuantity(64, resource.Tera)
 	subnetRegex        = regexp.MustCompile("subnet-[0-9a-z]+")
 	securityGroupRegex = regexp.MustCompile("sg-[0-9a-z]+")
+	// maxBlockDeviceMappings is the number of EBS attachments left on a Nitro instance after the primary network interface
+	maxBlockDeviceMappings = 27
+	// volumeTypeLimits are the size, IOPS and throughput bounds that EBS enforces for each volume type
+	volumeTypeLimits = map[string]ebsLimits{
+		ec2.VolumeTypeStandard: {minSizeGiB: 1, maxSizeGiB: 1024},
+		ec2.VolumeTypeGp2:      {minSizeGiB: 1, maxSizeGiB: 16384},
+		ec2.VolumeTypeGp3:      {minSizeGiB: 1, maxSizeGiB: 16384, minIOPS: 3000, maxIOPS: 16000, maxIOPSPerGiB: 500, minThroughput: 125, maxThroughput: 1000},
+		ec2.VolumeTypeIo1:      {minSizeGiB: 4, maxSizeGiB: 16384, minIOPS: 100, maxIOPS: 64000, maxIOPSPerGiB: 50},
+		ec2.VolumeTypeIo2:      {minSizeGiB: 4, maxSizeGiB: 16384, minIOPS: 100, maxIOPS: 64000, maxIOPSPerGiB: 500},
+		ec2.VolumeTypeSt1:      {minSizeGiB: 125, maxSizeGiB: 16384},
+		ec2.VolumeTypeSc1:      {minSizeGiB: 125, maxSizeGiB: 16384},
+	}
 )
 
+type ebsLimits struct {
+	minSizeGiB, maxSizeGiB       int64
+	minIOPS, maxIOPS             int64
+	maxIOPSPerGiB                int64
+	minThroughput, maxThroughput int64
+}
+
 func (a *AWS) Validate() (errs *apis.FieldError) {
 	return errs.Also(
 		a.validate().ViaField("provider"),
@@ -53,6 +75,7 @@ func (a *AWS) validate() (errs *apis.FieldError) {
 	return errs.Also(
 		a.validateLaunchTemplate(),
 		a.validateSubnets(),
+		a.validateSubnetPolicy(),
 		a.validateSecurityGroups(),
 		a.validateTags(),
 		a.validateMetadataOptions(),
@@ -195,6 +218,13 @@ func (a *AWS) validateHTTPTokens() *apis.FieldError {
 	return a.validateStringEnum(*a.MetadataOptions.HTTPTokens, "httpTokens", ec2.LaunchTemplateHttpTokensState_Values())
 }
 
+func (a *AWS) validateSubnetPolicy() *apis.FieldError {
+	if a.SubnetPolicy == nil {
+		return nil
+	}
+	return a.validateStringEnum(*a.SubnetPolicy, subnetPolicyPath, SupportedSubnetPolicies)
+}
+
 func (a *AWS) validateAMIFamily() *apis.FieldError {
 	if a.AMIFamily == nil {
 		return nil
@@ -212,6 +242,9 @@ func (a *AWS) validateStringEnum(value, field string, validValues []string) *api
 }
 
 func (a *AWS) validateBlockDeviceMappings() (errs *apis.FieldError) {
+	if len(a.BlockDeviceMappings) > maxBlockDeviceMappings {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("expected at most %d block device mappings, got %d", maxBlockDeviceMappings, len(a.BlockDeviceMappings)), blockDeviceMappingsPath))
+	}
 	for i, blockDeviceMapping := range a.BlockDeviceMappings {
 		if err := a.validateBlockDeviceMapping(blockDeviceMapping); err != nil {
 			errs = errs.Also(err.ViaFieldIndex(blockDeviceMappingsPath, i))
@@ -238,6 +271,9 @@ func (a *AWS) validateEBS(blockDeviceMapping *BlockDeviceMapping) (errs *apis.Fi
 	for _, err := range []*apis.FieldError{
 		a.validateVolumeType(blockDeviceMapping),
 		a.validateVolumeSize(blockDeviceMapping),
+		a.validateVolumeTypeSize(blockDeviceMapping),
+		a.validateIOPS(blockDeviceMapping),
+		a.validateThroughput(blockDeviceMapping),
 	} {
 		if err != nil {
 			errs = errs.Also(err.ViaField("ebs"))
@@ -264,3 +300,63 @@ func (a *AWS) validateVolumeSize(blockDeviceMapping *BlockDeviceMapping) *apis.F
 	}
 	return nil
 }
+
+func (a *AWS) validateVolumeTypeSize(blockDeviceMapping *BlockDeviceMapping) *apis.FieldError {
+	limits, ok := volumeTypeLimits[lo.FromPtr(blockDeviceMapping.EBS.VolumeType)]
+	if !ok || blockDeviceMapping.EBS.VolumeSize == nil {
+		return nil
+	}
+	if size := volumeSizeGiB(blockDeviceMapping.EBS.VolumeSize); size < limits.minSizeGiB || size > limits.maxSizeGiB {
+		return apis.ErrOutOfBoundsValue(blockDeviceMapping.EBS.VolumeSize.String(), fmt.Sprintf("%dGi", limits.minSizeGiB), fmt.Sprintf("%dGi", limits.maxSizeGiB), "volumeSize")
+	}
+	return nil
+}
+
+func (a *AWS) validateIOPS(blockDeviceMapping *BlockDeviceMapping) *apis.FieldError {
+	if blockDeviceMapping.EBS.VolumeType == nil {
+		return nil
+	}
+	if blockDeviceMapping.EBS.IOPS == nil {
+		// Provisioned IOPS volumes can't be created without specifying their IOPS
+		if lo.Contains([]string{ec2.VolumeTypeIo1, ec2.VolumeTypeIo2}, *blockDeviceMapping.EBS.VolumeType) {
+			return apis.ErrMissingField("iops")
+		}
+		return nil
+	}
+	iops := *blockDeviceMapping.EBS.IOPS
+	limits := volumeTypeLimits[*blockDeviceMapping.EBS.VolumeType]
+	if limits.maxIOPS == 0 {
+		return apis.ErrGeneric(fmt.Sprintf("iops is not supported for %s volumes", *blockDeviceMapping.EBS.VolumeType), "iops")
+	}
+	maxIOPS := limits.maxIOPS
+	// IOPS scale with the size of the volume, though every volume is allowed its minimum (baseline) IOPS
+	if blockDeviceMapping.EBS.VolumeSize != nil {
+		maxIOPS = lo.Clamp(volumeSizeGiB(blockDeviceMapping.EBS.VolumeSize)*limits.maxIOPSPerGiB, limits.minIOPS, limits.maxIOPS)
+	}
+	if iops < limits.minIOPS || iops > maxIOPS {
+		return apis.ErrOutOfBoundsValue(iops, limits.minIOPS, maxIOPS, "iops")
+	}
+	return nil
+}
+
+func (a *AWS) validateThroughput(blockDeviceMapping *BlockDeviceMapping) *apis.FieldError {
+	if blockDeviceMapping.EBS.Throughput == nil || blockDeviceMapping.EBS.VolumeType == nil {
+		return nil
+	}
+	throughput := *blockDeviceMapping.EBS.Throughput
+	limits := volumeTypeLimits[*blockDeviceMapping.EBS.VolumeType]
+	if limits.maxThroughput == 0 {
+		return apis.ErrGeneric(fmt.Sprintf("throughput is not supported for %s volumes", *blockDeviceMapping.EBS.VolumeType), "throughput")
+	}
+	// Throughput is limited to 0.25 MiB/s per provisioned IOPS, falling back to the baseline IOPS when unset
+	maxThroughput := lo.Clamp(lo.FromPtrOr(blockDeviceMapping.EBS.IOPS, limits.minIOPS)/4, limits.minThroughput, limits.maxThroughput)
+	if throughput < limits.minThroughput || throughput > maxThroughput {
+		return apis.ErrOutOfBoundsValue(throughput, limits.minThroughput, maxThroughput, "throughput")
+	}
+	return nil
+}
+
+// volumeSizeGiB converts the quantity to GiB, rounding up in the same way as the launch template provider
+func volumeSizeGiB(quantity *resource.Quantity) int64 {
+	return int64(math.Ceil(quantity.AsApproximateFloat64() / math.Pow(2, 30)))
+}
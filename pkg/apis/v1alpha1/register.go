@@ -27,6 +27,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/sets"
 
 	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
 )
 
 var (
@@ -51,6 +52,10 @@ var (
 		regexp.MustCompile(`^kubernetes\.io/cluster/[0-9A-Za-z][A-Za-z0-9\-_]*$`),
 		regexp.MustCompile(fmt.Sprintf("^%s$", regexp.QuoteMeta(v1alpha5.ProvisionerNameLabelKey))),
 		regexp.MustCompile(fmt.Sprintf("^%s$", regexp.QuoteMeta(v1alpha5.MachineManagedByAnnotationKey))),
+		// AWSNodeTemplates launch as NodeClasses, so their instances are tagged with the NodeClass tags as well
+		regexp.MustCompile(fmt.Sprintf("^%s$", regexp.QuoteMeta(corev1beta1.NodePoolLabelKey))),
+		regexp.MustCompile(fmt.Sprintf("^%s$", regexp.QuoteMeta(corev1beta1.ManagedByAnnotationKey))),
+		regexp.MustCompile(`^eks:cluster-name$`),
 	}
 	AMIFamilyBottlerocket = "Bottlerocket"
 	AMIFamilyAL2          = "AL2"
@@ -66,6 +71,108 @@ var (
 		AMIFamilyWindows2022,
 		AMIFamilyCustom,
 	}
+	SubnetPolicyAny         = "any"
+	SubnetPolicyPrivateOnly = "privateOnly"
+	SupportedSubnetPolicies = []string{
+		SubnetPolicyAny,
+		SubnetPolicyPrivateOnly,
+	}
+	BottlerocketBootstrapContainerModeAlways = "always"
+	BottlerocketBootstrapContainerModeOnce   = "once"
+	BottlerocketBootstrapContainerModeOff    = "off"
+	BottlerocketBootstrapContainerModes      = []string{
+		BottlerocketBootstrapContainerModeAlways,
+		BottlerocketBootstrapContainerModeOnce,
+		BottlerocketBootstrapContainerModeOff,
+	}
+	CPUManagerPolicyNone   = "none"
+	CPUManagerPolicyStatic = "static"
+	CPUManagerPolicies     = []string{
+		CPUManagerPolicyNone,
+		CPUManagerPolicyStatic,
+	}
+	TopologyManagerPolicyNone           = "none"
+	TopologyManagerPolicyBestEffort     = "best-effort"
+	TopologyManagerPolicyRestricted     = "restricted"
+	TopologyManagerPolicySingleNUMANode = "single-numa-node"
+	TopologyManagerPolicies             = []string{
+		TopologyManagerPolicyNone,
+		TopologyManagerPolicyBestEffort,
+		TopologyManagerPolicyRestricted,
+		TopologyManagerPolicySingleNUMANode,
+	}
+	MemoryManagerPolicyNone   = "None"
+	MemoryManagerPolicyStatic = "Static"
+	MemoryManagerPolicies     = []string{
+		MemoryManagerPolicyNone,
+		MemoryManagerPolicyStatic,
+	}
+	NVIDIADriverOpen        = "open"
+	NVIDIADriverProprietary = "proprietary"
+	NVIDIADrivers           = []string{
+		NVIDIADriverOpen,
+		NVIDIADriverProprietary,
+	}
+	DeletionPolicyBlock       = "block"
+	DeletionPolicyCascade     = "cascade"
+	SupportedDeletionPolicies = []string{
+		DeletionPolicyBlock,
+		DeletionPolicyCascade,
+	}
+	UserDataRefKindConfigMap = "ConfigMap"
+	UserDataRefKindSecret    = "Secret"
+	UserDataRefKinds         = []string{
+		UserDataRefKindConfigMap,
+		UserDataRefKindSecret,
+	}
+	UserDataMergeOrderPreBootstrap  = "PreBootstrap"
+	UserDataMergeOrderPostBootstrap = "PostBootstrap"
+	UserDataMergeOrders             = []string{
+		UserDataMergeOrderPreBootstrap,
+		UserDataMergeOrderPostBootstrap,
+	}
+	UserDataCompressionNone = "None"
+	UserDataCompressionGzip = "Gzip"
+	UserDataCompressions    = []string{
+		UserDataCompressionNone,
+		UserDataCompressionGzip,
+	}
+	// UserDataMaxSize is the largest base64 encoded UserData, in bytes, that EC2 accepts
+	UserDataMaxSize = 16 * 1024
+
+	AcceleratorTypeGPU       = "gpu"
+	AcceleratorTypeFPGA      = "fpga"
+	AcceleratorTypeInference = "inference"
+	AcceleratorTypes         = []string{
+		AcceleratorTypeGPU,
+		AcceleratorTypeFPGA,
+		AcceleratorTypeInference,
+	}
+	AcceleratorManufacturers = []string{
+		"amazon-web-services",
+		"amd",
+		"habana",
+		"nvidia",
+		"xilinx",
+	}
+	CPUCreditSpecificationStandard  = "standard"
+	CPUCreditSpecificationUnlimited = "unlimited"
+	CPUCreditSpecifications         = []string{
+		CPUCreditSpecificationStandard,
+		CPUCreditSpecificationUnlimited,
+	}
+	AMDSEVSNPEnabled  = "enabled"
+	AMDSEVSNPDisabled = "disabled"
+	AMDSEVSNPs        = []string{
+		AMDSEVSNPEnabled,
+		AMDSEVSNPDisabled,
+	}
+	InstanceInitiatedShutdownBehaviorStop      = "stop"
+	InstanceInitiatedShutdownBehaviorTerminate = "terminate"
+	InstanceInitiatedShutdownBehaviors         = []string{
+		InstanceInitiatedShutdownBehaviorStop,
+		InstanceInitiatedShutdownBehaviorTerminate,
+	}
 	SupportedContainerRuntimesByAMIFamily = map[string]sets.Set[string]{
 		AMIFamilyBottlerocket: sets.New("containerd"),
 		AMIFamilyAL2:          sets.New("dockerd", "containerd"),
@@ -74,20 +181,35 @@ var (
 		AMIFamilyWindows2022:  sets.New("dockerd", "containerd"),
 	}
 
+	WindowsVariants = []string{
+		WindowsCore,
+		WindowsFull,
+	}
+	// BottlerocketClusterSettings are the settings.kubernetes keys that Karpenter sets in Bottlerocket userData
+	BottlerocketClusterSettings = []string{
+		"api-server",
+		"cluster-certificate",
+		"cluster-name",
+	}
 	Windows2019                                           = "2019"
 	Windows2022                                           = "2022"
 	WindowsCore                                           = "Core"
+	WindowsFull                                           = "Full"
 	Windows2019Build                                      = "10.0.17763"
 	Windows2022Build                                      = "10.0.20348"
 	ResourceNVIDIAGPU             v1.ResourceName         = "nvidia.com/gpu"
 	ResourceAMDGPU                v1.ResourceName         = "amd.com/gpu"
 	ResourceAWSNeuron             v1.ResourceName         = "aws.amazon.com/neuron"
+	ResourceAWSElasticInference   v1.ResourceName         = "aws.amazon.com/eia"
 	ResourceHabanaGaudi           v1.ResourceName         = "habana.ai/gaudi"
 	ResourceAWSPodENI             v1.ResourceName         = "vpc.amazonaws.com/pod-eni"
 	ResourcePrivateIPv4Address    v1.ResourceName         = "vpc.amazonaws.com/PrivateIPv4Address"
 	NVIDIAacceleratorManufacturer AcceleratorManufacturer = "nvidia"
 	AWSAcceleratorManufacturer    AcceleratorManufacturer = "aws"
 
+	// LabelTopologyZoneID is the ID of the zone, which identifies the same physical zone across accounts
+	LabelTopologyZoneID = "topology.k8s.aws/zone-id"
+
 	LabelInstanceHypervisor                   = LabelDomain + "/instance-hypervisor"
 	LabelInstanceEncryptionInTransitSupported = LabelDomain + "/instance-encryption-in-transit-supported"
 	LabelInstanceCategory                     = LabelDomain + "/instance-category"
@@ -107,7 +229,19 @@ var (
 	LabelInstanceAcceleratorName              = LabelDomain + "/instance-accelerator-name"
 	LabelInstanceAcceleratorManufacturer      = LabelDomain + "/instance-accelerator-manufacturer"
 	LabelInstanceAcceleratorCount             = LabelDomain + "/instance-accelerator-count"
+	LabelSpotInterruptionRateBucket           = LabelDomain + "/spot-interruption-rate-bucket"
 	AnnotationNodeTemplateHash                = LabelDomain + "/nodetemplate-hash"
+	AnnotationNodeTemplateHashWithoutTags     = LabelDomain + "/nodetemplate-hash-without-tags"
+	AnnotationDriftPolicy                     = LabelDomain + "/drift-policy"
+	AnnotationDriftedAMIID                    = LabelDomain + "/drifted-ami-id"
+	AnnotationResolvedAMIID                   = LabelDomain + "/resolved-ami-id"
+	AnnotationResolvedAMIName                 = LabelDomain + "/resolved-ami-name"
+	AnnotationTerminationReason               = LabelDomain + "/termination-reason"
+	AnnotationUserDataHash                    = LabelDomain + "/userdata-hash"
+	AnnotationPriceEstimate                   = LabelDomain + "/price-estimate"
+	AnnotationPriceEstimateCapacityType       = LabelDomain + "/price-estimate-capacity-type"
+	AnnotationPriceEstimateZone               = LabelDomain + "/price-estimate-zone"
+	TerminationFinalizer                      = LabelDomain + "/termination"
 )
 
 var (
@@ -147,7 +281,9 @@ func init() {
 		LabelInstanceAcceleratorName,
 		LabelInstanceAcceleratorManufacturer,
 		LabelInstanceAcceleratorCount,
+		LabelSpotInterruptionRateBucket,
 		v1.LabelWindowsBuild,
+		LabelTopologyZoneID,
 	)
 }
 
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Pallinder/go-randomdata"
 	"github.com/mitchellh/hashstructure/v2"
@@ -30,6 +31,10 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/test"
@@ -91,6 +96,387 @@ var _ = Describe("Validation", func() {
 			}
 			Expect(ant.Validate(ctx)).ToNot(Succeed())
 		})
+		It("should fail when the subnet policy is unknown", func() {
+			ant.Spec.SubnetPolicy = aws.String("publicOnly")
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+
+			ant.Spec.SubnetPolicy = aws.String(v1alpha1.SubnetPolicyPrivateOnly)
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+	})
+	Context("StartupTaints", func() {
+		It("should succeed with valid startup taints", func() {
+			ant.Spec.StartupTaints = []v1.Taint{
+				{Key: "node.cilium.io/agent-not-ready", Value: "true", Effect: v1.TaintEffectNoExecute},
+				{Key: "ebs.csi.aws.com/agent-not-ready", Effect: v1.TaintEffectNoSchedule},
+			}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an invalid key, value or effect", func() {
+			ant.Spec.StartupTaints = []v1.Taint{{Key: "???", Effect: v1.TaintEffectNoSchedule}}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+			ant.Spec.StartupTaints = []v1.Taint{{Key: "example.com/taint", Value: "???", Effect: v1.TaintEffectNoSchedule}}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+			ant.Spec.StartupTaints = []v1.Taint{{Key: "example.com/taint", Effect: "NoRun"}}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with duplicate startup taints", func() {
+			ant.Spec.StartupTaints = []v1.Taint{
+				{Key: "example.com/taint", Value: "a", Effect: v1.TaintEffectNoSchedule},
+				{Key: "example.com/taint", Value: "b", Effect: v1.TaintEffectNoSchedule},
+			}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with the Custom AMIFamily", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyCustom
+			ant.Spec.AMISelector = map[string]string{"aws-ids": "ami-12345"}
+			ant.Spec.StartupTaints = []v1.Taint{{Key: "example.com/taint", Effect: v1.TaintEffectNoSchedule}}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("Bottlerocket", func() {
+		BeforeEach(func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+		})
+		It("should succeed with valid Bottlerocket settings", func() {
+			ant.Spec.Bottlerocket = &v1alpha1.BottlerocketSettings{
+				HostContainers:      map[string]v1alpha1.BottlerocketHostContainer{"admin": {Enabled: aws.Bool(true)}},
+				BootstrapContainers: map[string]v1alpha1.BottlerocketBootstrapContainer{"setup": {Source: aws.String("example.com/setup:latest"), Mode: aws.String(v1alpha1.BottlerocketBootstrapContainerModeOnce)}},
+				KernelSysctl:        map[string]string{"net.core.somaxconn": "1024"},
+				RegistryMirrors:     []v1alpha1.BottlerocketRegistryMirror{{Registry: "docker.io", Endpoints: []string{"https://mirror.example.com"}}},
+			}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an AMIFamily other than Bottlerocket", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
+			ant.Spec.Bottlerocket = &v1alpha1.BottlerocketSettings{KernelSysctl: map[string]string{"net.core.somaxconn": "1024"}}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with an unknown bootstrap container mode", func() {
+			ant.Spec.Bottlerocket = &v1alpha1.BottlerocketSettings{
+				BootstrapContainers: map[string]v1alpha1.BottlerocketBootstrapContainer{"setup": {Mode: aws.String("sometimes")}},
+			}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with a registry mirror without endpoints", func() {
+			ant.Spec.Bottlerocket = &v1alpha1.BottlerocketSettings{
+				RegistryMirrors: []v1alpha1.BottlerocketRegistryMirror{{Registry: "docker.io"}},
+			}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with duplicate registries", func() {
+			ant.Spec.Bottlerocket = &v1alpha1.BottlerocketSettings{
+				RegistryMirrors: []v1alpha1.BottlerocketRegistryMirror{
+					{Registry: "docker.io", Endpoints: []string{"https://a.example.com"}},
+					{Registry: "docker.io", Endpoints: []string{"https://b.example.com"}},
+				},
+			}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("ContainerRegistries", func() {
+		It("should succeed with valid container registries", func() {
+			ant.Spec.ContainerRegistries = []v1alpha1.ContainerRegistry{
+				{Registry: "docker.io", Mirrors: []string{"https://mirror.example.com"}},
+				{Registry: "registry.example.com:5000", Mirrors: []string{"http://cache.example.com:8080/v2"}, Auth: aws.String("dXNlcjpwYXNz")},
+				{Registry: "_default", Mirrors: []string{"https://mirror.example.com"}},
+			}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an AMIFamily other than AL2", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+			ant.Spec.ContainerRegistries = []v1alpha1.ContainerRegistry{{Registry: "docker.io", Mirrors: []string{"https://mirror.example.com"}}}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with an invalid registry", func() {
+			for _, registry := range []string{"", "https://docker.io", "docker.io/library", "docker.io'"} {
+				ant.Spec.ContainerRegistries = []v1alpha1.ContainerRegistry{{Registry: registry, Mirrors: []string{"https://mirror.example.com"}}}
+				Expect(ant.Validate(ctx)).ToNot(Succeed())
+			}
+		})
+		It("should fail with missing or invalid mirrors", func() {
+			for _, mirrors := range [][]string{nil, {"mirror.example.com"}, {"ftp://mirror.example.com"}, {"https://"}} {
+				ant.Spec.ContainerRegistries = []v1alpha1.ContainerRegistry{{Registry: "docker.io", Mirrors: mirrors}}
+				Expect(ant.Validate(ctx)).ToNot(Succeed())
+			}
+		})
+		It("should fail when auth isn't base64 encoded", func() {
+			ant.Spec.ContainerRegistries = []v1alpha1.ContainerRegistry{{Registry: "docker.io", Mirrors: []string{"https://mirror.example.com"}, Auth: aws.String("user:pass")}}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with duplicate registries", func() {
+			ant.Spec.ContainerRegistries = []v1alpha1.ContainerRegistry{
+				{Registry: "docker.io", Mirrors: []string{"https://a.example.com"}},
+				{Registry: "docker.io", Mirrors: []string{"https://b.example.com"}},
+			}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("Kubelet", func() {
+		It("should succeed with a valid kubelet configuration", func() {
+			ant.Spec.Kubelet = &v1alpha1.KubeletConfiguration{
+				ShutdownGracePeriod:             &metav1.Duration{Duration: time.Minute * 2},
+				ShutdownGracePeriodCriticalPods: &metav1.Duration{Duration: time.Second * 30},
+				RegistryPullQPS:                 aws.Int32(0),
+				RegistryBurst:                   aws.Int32(20),
+			}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with the Bottlerocket AMIFamily", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+			ant.Spec.Kubelet = &v1alpha1.KubeletConfiguration{ShutdownGracePeriod: &metav1.Duration{Duration: time.Minute}}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an AMIFamily other than AL2 or Bottlerocket", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyUbuntu
+			ant.Spec.Kubelet = &v1alpha1.KubeletConfiguration{ShutdownGracePeriod: &metav1.Duration{Duration: time.Minute}}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with a negative shutdownGracePeriod", func() {
+			ant.Spec.Kubelet = &v1alpha1.KubeletConfiguration{ShutdownGracePeriod: &metav1.Duration{Duration: -time.Minute}}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail when shutdownGracePeriodCriticalPods is set without shutdownGracePeriod", func() {
+			ant.Spec.Kubelet = &v1alpha1.KubeletConfiguration{ShutdownGracePeriodCriticalPods: &metav1.Duration{Duration: time.Second * 30}}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail when shutdownGracePeriodCriticalPods is longer than shutdownGracePeriod", func() {
+			ant.Spec.Kubelet = &v1alpha1.KubeletConfiguration{
+				ShutdownGracePeriod:             &metav1.Duration{Duration: time.Minute},
+				ShutdownGracePeriodCriticalPods: &metav1.Duration{Duration: time.Minute * 2},
+			}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with a negative registryPullQPS or registryBurst", func() {
+			ant.Spec.Kubelet = &v1alpha1.KubeletConfiguration{RegistryPullQPS: aws.Int32(-1)}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+			ant.Spec.Kubelet = &v1alpha1.KubeletConfiguration{RegistryBurst: aws.Int32(-1)}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should succeed with supported CPU, topology and memory manager policies", func() {
+			for _, policy := range v1alpha1.CPUManagerPolicies {
+				ant.Spec.Kubelet = &v1alpha1.KubeletConfiguration{CPUManagerPolicy: aws.String(policy)}
+				Expect(ant.Validate(ctx)).To(Succeed())
+			}
+			for _, policy := range v1alpha1.TopologyManagerPolicies {
+				ant.Spec.Kubelet = &v1alpha1.KubeletConfiguration{TopologyManagerPolicy: aws.String(policy)}
+				Expect(ant.Validate(ctx)).To(Succeed())
+			}
+			for _, policy := range v1alpha1.MemoryManagerPolicies {
+				ant.Spec.Kubelet = &v1alpha1.KubeletConfiguration{MemoryManagerPolicy: aws.String(policy)}
+				Expect(ant.Validate(ctx)).To(Succeed())
+			}
+		})
+		It("should fail with unknown CPU, topology or memory manager policies", func() {
+			ant.Spec.Kubelet = &v1alpha1.KubeletConfiguration{CPUManagerPolicy: aws.String("Static")}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+			ant.Spec.Kubelet = &v1alpha1.KubeletConfiguration{TopologyManagerPolicy: aws.String("prefer-closest-numa-nodes")}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+			ant.Spec.Kubelet = &v1alpha1.KubeletConfiguration{MemoryManagerPolicy: aws.String("static")}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with launchTemplate", func() {
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.LaunchTemplateName = aws.String("my-lt")
+			ant.Spec.Kubelet = &v1alpha1.KubeletConfiguration{ShutdownGracePeriod: &metav1.Duration{Duration: time.Minute}}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("MaxPodsPerInstanceType", func() {
+		It("should succeed with globs of instance type names", func() {
+			ant.Spec.MaxPodsPerInstanceType = map[string]int32{"m5.*": 58, "*.metal": 250}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an invalid glob", func() {
+			ant.Spec.MaxPodsPerInstanceType = map[string]int32{"m5.[": 58}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail when maxPods is negative", func() {
+			ant.Spec.MaxPodsPerInstanceType = map[string]int32{"m5.*": -1}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail when used with a launch template", func() {
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.MaxPodsPerInstanceType = map[string]int32{"m5.*": 58}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("NVIDIA", func() {
+		It("should succeed with a supported driver", func() {
+			for _, driver := range v1alpha1.NVIDIADrivers {
+				ant.Spec.NVIDIA = &v1alpha1.NVIDIAConfiguration{Driver: aws.String(driver)}
+				Expect(ant.Validate(ctx)).To(Succeed())
+			}
+		})
+		It("should fail with an unknown driver", func() {
+			ant.Spec.NVIDIA = &v1alpha1.NVIDIAConfiguration{Driver: aws.String("nouveau")}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail to set the driver with an AMIFamily other than AL2 or Bottlerocket", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyUbuntu
+			ant.Spec.NVIDIA = &v1alpha1.NVIDIAConfiguration{Driver: aws.String(v1alpha1.NVIDIADriverOpen)}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail to set the driver with an AMI selector", func() {
+			ant.Spec.AMISelector = map[string]string{"aws-ids": "ami-123"}
+			ant.Spec.NVIDIA = &v1alpha1.NVIDIAConfiguration{Driver: aws.String(v1alpha1.NVIDIADriverOpen)}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should succeed to enable the container runtime with the Custom AMIFamily", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyCustom
+			ant.Spec.AMISelector = map[string]string{"aws-ids": "ami-123"}
+			ant.Spec.NVIDIA = &v1alpha1.NVIDIAConfiguration{ContainerRuntime: aws.Bool(true)}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail to enable the container runtime with an AMIFamily other than Custom", func() {
+			ant.Spec.NVIDIA = &v1alpha1.NVIDIAConfiguration{ContainerRuntime: aws.Bool(true)}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with launchTemplate", func() {
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.LaunchTemplateName = aws.String("my-lt")
+			ant.Spec.NVIDIA = &v1alpha1.NVIDIAConfiguration{Driver: aws.String(v1alpha1.NVIDIADriverOpen)}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("Windows", func() {
+		BeforeEach(func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyWindows2022
+		})
+		It("should succeed with the CSI proxy and gMSA", func() {
+			ant.Spec.Windows = &v1alpha1.WindowsConfiguration{
+				CSIProxy: &v1alpha1.WindowsCSIProxy{BinaryURL: aws.String("https://example.com/csi-proxy.exe")},
+				GMSA:     &v1alpha1.WindowsGMSA{PluginCLSID: "01234567-89ab-cdef-0123-456789abcdef"},
+			}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with a supported variant", func() {
+			for _, variant := range v1alpha1.WindowsVariants {
+				ant.Spec.Windows = &v1alpha1.WindowsConfiguration{Variant: aws.String(variant)}
+				Expect(ant.Validate(ctx)).To(Succeed())
+			}
+		})
+		It("should fail with an unknown variant", func() {
+			ant.Spec.Windows = &v1alpha1.WindowsConfiguration{Variant: aws.String("Nano")}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail to set the variant with an AMI selector", func() {
+			ant.Spec.AMISelector = map[string]string{"aws-ids": "ami-123"}
+			ant.Spec.Windows = &v1alpha1.WindowsConfiguration{Variant: aws.String(v1alpha1.WindowsFull)}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with an AMIFamily other than Windows", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
+			ant.Spec.Windows = &v1alpha1.WindowsConfiguration{CSIProxy: &v1alpha1.WindowsCSIProxy{}}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail when the CSI proxy binaryURL isn't https", func() {
+			for _, binaryURL := range []string{"http://example.com/csi-proxy.exe", "example.com/csi-proxy.exe", "https://example.com/csi-proxy.exe'; Remove-Item C:\\"} {
+				ant.Spec.Windows = &v1alpha1.WindowsConfiguration{CSIProxy: &v1alpha1.WindowsCSIProxy{BinaryURL: aws.String(binaryURL)}}
+				Expect(ant.Validate(ctx)).ToNot(Succeed())
+			}
+		})
+		It("should fail when the gMSA plugin CLSID isn't a COM class ID", func() {
+			for _, clsid := range []string{"", "{01234567-89ab-cdef-0123-456789abcdef}", "01234567-89ab-cdef-0123"} {
+				ant.Spec.Windows = &v1alpha1.WindowsConfiguration{GMSA: &v1alpha1.WindowsGMSA{PluginCLSID: clsid}}
+				Expect(ant.Validate(ctx)).ToNot(Succeed())
+			}
+		})
+		It("should fail with launchTemplate", func() {
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.LaunchTemplateName = aws.String("my-lt")
+			ant.Spec.Windows = &v1alpha1.WindowsConfiguration{CSIProxy: &v1alpha1.WindowsCSIProxy{}}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("InstanceRequirements", func() {
+		It("should succeed with attribute ranges, accelerators and excluded instance types", func() {
+			ant.Spec.InstanceRequirements = &v1alpha1.InstanceRequirements{
+				VCPU:                     &v1alpha1.InstanceRequirementsRange{Min: aws.Int64(2), Max: aws.Int64(16)},
+				MemoryMiB:                &v1alpha1.InstanceRequirementsRange{Min: aws.Int64(4096)},
+				AcceleratorCount:         &v1alpha1.InstanceRequirementsRange{Min: aws.Int64(1)},
+				AcceleratorTypes:         []string{v1alpha1.AcceleratorTypeGPU},
+				AcceleratorManufacturers: []string{"nvidia"},
+				ExcludedInstanceTypes:    []string{"t2.*", "m5.metal"},
+			}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail when the minimum of a range is greater than its maximum", func() {
+			ant.Spec.InstanceRequirements = &v1alpha1.InstanceRequirements{
+				VCPU: &v1alpha1.InstanceRequirementsRange{Min: aws.Int64(16), Max: aws.Int64(2)},
+			}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with an unknown accelerator type or manufacturer", func() {
+			ant.Spec.InstanceRequirements = &v1alpha1.InstanceRequirements{AcceleratorTypes: []string{"tpu"}}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+			ant.Spec.InstanceRequirements = &v1alpha1.InstanceRequirements{AcceleratorManufacturers: []string{"intel"}}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with accelerator types when accelerators are excluded", func() {
+			ant.Spec.InstanceRequirements = &v1alpha1.InstanceRequirements{
+				AcceleratorCount: &v1alpha1.InstanceRequirementsRange{Max: aws.Int64(0)},
+				AcceleratorTypes: []string{v1alpha1.AcceleratorTypeGPU},
+			}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with an invalid excluded instance type pattern", func() {
+			for _, pattern := range []string{"m5.[large", "M5.large", "m5 large"} {
+				ant.Spec.InstanceRequirements = &v1alpha1.InstanceRequirements{ExcludedInstanceTypes: []string{pattern}}
+				Expect(ant.Validate(ctx)).ToNot(Succeed())
+			}
+		})
+	})
+	Context("RequireEncryptionInTransit", func() {
+		It("should succeed when encryption in transit is required", func() {
+			ant.Spec.RequireEncryptionInTransit = aws.Bool(true)
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail when encryption in transit is required with instance requirements", func() {
+			ant.Spec.RequireEncryptionInTransit = aws.Bool(true)
+			ant.Spec.InstanceRequirements = &v1alpha1.InstanceRequirements{VCPU: &v1alpha1.InstanceRequirementsRange{Min: aws.Int64(2)}}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("AMIMaxAge", func() {
+		It("should succeed with a positive maximum AMI age", func() {
+			ant.Spec.AMIMaxAge = &metav1.Duration{Duration: 30 * 24 * time.Hour}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with a maximum AMI age that isn't positive", func() {
+			ant.Spec.AMIMaxAge = &metav1.Duration{Duration: 0}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+			ant.Spec.AMIMaxAge = &metav1.Duration{Duration: -time.Hour}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("RegistrationTTL", func() {
+		It("should succeed with a registration TTL of at most 15 minutes", func() {
+			ant.Spec.RegistrationTTL = &metav1.Duration{Duration: 5 * time.Minute}
+			Expect(ant.Validate(ctx)).To(Succeed())
+			ant.Spec.RegistrationTTL = &metav1.Duration{Duration: 15 * time.Minute}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with a registration TTL that isn't positive", func() {
+			ant.Spec.RegistrationTTL = &metav1.Duration{Duration: 0}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+			ant.Spec.RegistrationTTL = &metav1.Duration{Duration: -time.Minute}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with a registration TTL of more than 15 minutes", func() {
+			ant.Spec.RegistrationTTL = &metav1.Duration{Duration: 20 * time.Minute}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("DeletionPolicy", func() {
+		It("should fail when the deletion policy is unknown", func() {
+			ant.Spec.DeletionPolicy = aws.String("orphan")
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+
+			ant.Spec.DeletionPolicy = aws.String(v1alpha1.DeletionPolicyCascade)
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
 	})
 	Context("SecurityGroupSelector", func() {
 		It("should succeed with a valid security group selector", func() {
@@ -159,16 +545,309 @@ var _ = Describe("Validation", func() {
 			}
 			Expect(ant.Validate(ctx)).ToNot(Succeed())
 		})
+		It("should succeed when an owners ami selector is used in combination with a name", func() {
+			ant.Spec.AMISelector = map[string]string{
+				"aws::name":   "my-ami",
+				"aws::owners": "self",
+			}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail when an owners ami selector is used on its own", func() {
+			ant.Spec.AMISelector = map[string]string{
+				"aws::owners": "self",
+			}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("EphemeralStorageSizing", func() {
+		It("should succeed with a valid sizing", func() {
+			ant.Spec.EphemeralStorageSizing = &v1alpha1.EphemeralStorageSizing{
+				MaxSize:  resource.MustParse("500Gi"),
+				Overhead: lo.ToPtr(resource.MustParse("10Gi")),
+			}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail when the min size is larger than the max size", func() {
+			ant.Spec.EphemeralStorageSizing = &v1alpha1.EphemeralStorageSizing{
+				MinSize: lo.ToPtr(resource.MustParse("100Gi")),
+				MaxSize: resource.MustParse("50Gi"),
+			}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail when used with a launch template", func() {
+			ant.Spec.LaunchTemplateName = aws.String("my-lt")
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.EphemeralStorageSizing = &v1alpha1.EphemeralStorageSizing{MaxSize: resource.MustParse("50Gi")}
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
 	})
 	Context("UserData", func() {
 		It("should succeed if user data is empty", func() {
 			Expect(ant.Validate(ctx)).To(Succeed())
 		})
+		It("should succeed with a valid template if userDataTemplating is enabled", func() {
+			ant.Spec.UserData = ptr.String("#!/bin/bash\necho '{{ .ClusterName }} {{ .NodePool }}'")
+			ant.Spec.UserDataTemplating = ptr.Bool(true)
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an invalid template if userDataTemplating is enabled", func() {
+			ant.Spec.UserData = ptr.String("#!/bin/bash\necho '{{ .ClusterName'")
+			ant.Spec.UserDataTemplating = ptr.Bool(true)
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should succeed with an invalid template if userDataTemplating isn't enabled", func() {
+			ant.Spec.UserData = ptr.String("#!/bin/bash\necho '{{ .ClusterName'")
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
 		It("should fail if launch template is also specified", func() {
 			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
 			ant.Spec.UserData = ptr.String("someUserData")
 			Expect(ant.Validate(ctx)).To(Not(Succeed()))
 		})
+		It("should fail with a Windows AMIFamily", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyWindows2022
+			ant.Spec.UserData = ptr.String("someUserData")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should succeed with valid TOML with the Bottlerocket AMIFamily", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+			ant.Spec.UserData = ptr.String("[settings.kubernetes]\nmax-pods = 110\n")
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with invalid TOML with the Bottlerocket AMIFamily", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+			ant.Spec.UserData = ptr.String("#!/bin/bash\necho 'hello'")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if a cluster setting is set with the Bottlerocket AMIFamily", func() {
+			for _, key := range v1alpha1.BottlerocketClusterSettings {
+				ant.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+				ant.Spec.UserData = ptr.String(fmt.Sprintf("[settings.kubernetes]\n%s = \"value\"\n", key))
+				Expect(ant.Validate(ctx)).To(Not(Succeed()))
+			}
+		})
+		It("should succeed with templated userData with the Bottlerocket AMIFamily", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+			ant.Spec.UserData = ptr.String("[settings.kubernetes.node-labels]\nnodepool = \"{{ .NodePool }}\"\n")
+			ant.Spec.UserDataTemplating = ptr.Bool(true)
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with valid MIME multi-part userData", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
+			ant.Spec.UserData = ptr.String("MIME-Version: 1.0\nContent-Type: multipart/mixed; boundary=\"BOUNDARY\"\n\n--BOUNDARY\nContent-Type: text/x-shellscript; charset=\"us-ascii\"\n\n#!/bin/bash\necho 'hello'\n--BOUNDARY--\n")
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with MIME userData that isn't multi-part", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
+			ant.Spec.UserData = ptr.String("MIME-Version: 1.0\nContent-Type: text/x-shellscript\n\n#!/bin/bash\necho 'hello'\n")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with MIME userData without a boundary", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
+			ant.Spec.UserData = ptr.String("MIME-Version: 1.0\nContent-Type: multipart/mixed\n\n--BOUNDARY\n#!/bin/bash\necho 'hello'\n--BOUNDARY--\n")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if the userData is larger than EC2 allows once base64 encoded", func() {
+			ant.Spec.UserData = ptr.String("#!/bin/bash\n" + strings.Repeat("#", v1alpha1.UserDataMaxSize))
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should succeed if the userData is larger than EC2 allows with Gzip compression", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
+			ant.Spec.UserData = ptr.String("#!/bin/bash\n" + strings.Repeat("#", v1alpha1.UserDataMaxSize))
+			ant.Spec.UserDataCompression = ptr.String(v1alpha1.UserDataCompressionGzip)
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+	})
+	Context("UserDataRef", func() {
+		It("should succeed with a Secret reference", func() {
+			ant.Spec.UserDataRef = &v1alpha1.UserDataReference{Kind: v1alpha1.UserDataRefKindSecret, Name: "userdata", Key: "bootstrap.sh"}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an unsupported kind", func() {
+			ant.Spec.UserDataRef = &v1alpha1.UserDataReference{Kind: "Pod", Name: "userdata", Key: "bootstrap.sh"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if userData is also specified", func() {
+			ant.Spec.UserData = ptr.String("someUserData")
+			ant.Spec.UserDataRef = &v1alpha1.UserDataReference{Kind: v1alpha1.UserDataRefKindSecret, Name: "userdata", Key: "bootstrap.sh"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if launch template is also specified", func() {
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.UserDataRef = &v1alpha1.UserDataReference{Kind: v1alpha1.UserDataRefKindSecret, Name: "userdata", Key: "bootstrap.sh"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with a Windows AMIFamily", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyWindows2019
+			ant.Spec.UserDataRef = &v1alpha1.UserDataReference{Kind: v1alpha1.UserDataRefKindSecret, Name: "userdata", Key: "bootstrap.sh"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("UserDataCompression", func() {
+		It("should succeed with the AL2 AMIFamily", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
+			ant.Spec.UserDataCompression = ptr.String(v1alpha1.UserDataCompressionGzip)
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an unsupported compression", func() {
+			ant.Spec.UserDataCompression = ptr.String("Zstd")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with an AMIFamily other than AL2", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+			ant.Spec.UserDataCompression = ptr.String(v1alpha1.UserDataCompressionGzip)
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("UserDataMergeOrder", func() {
+		It("should succeed with the AL2 AMIFamily", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
+			ant.Spec.UserDataMergeOrder = ptr.String(v1alpha1.UserDataMergeOrderPostBootstrap)
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an unsupported order", func() {
+			ant.Spec.UserDataMergeOrder = ptr.String("DuringBootstrap")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with an AMIFamily other than AL2", func() {
+			ant.Spec.AMIFamily = &v1alpha1.AMIFamilyUbuntu
+			ant.Spec.UserDataMergeOrder = ptr.String(v1alpha1.UserDataMergeOrderPostBootstrap)
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if launch template is also specified", func() {
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.UserDataMergeOrder = ptr.String(v1alpha1.UserDataMergeOrderPostBootstrap)
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("DriftPolicy", func() {
+		It("should succeed with the drift policy of the tags", func() {
+			for _, policy := range []string{"tags=InPlace", "tags=Replace"} {
+				ant.Annotations = map[string]string{v1alpha1.AnnotationDriftPolicy: policy}
+				Expect(ant.Validate(ctx)).To(Succeed())
+			}
+		})
+		It("should fail with an unknown field or policy", func() {
+			for _, policy := range []string{"", "tags", "userData=InPlace", "tags=Ignore"} {
+				ant.Annotations = map[string]string{v1alpha1.AnnotationDriftPolicy: policy}
+				Expect(ant.Validate(ctx)).ToNot(Succeed())
+			}
+		})
+	})
+	Context("AssumeRoleARN", func() {
+		It("should succeed with the ARN of a role", func() {
+			ant.Spec.AssumeRoleARN = ptr.String("arn:aws:iam::123456789012:role/discovery")
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an ARN that isn't of a role", func() {
+			ant.Spec.AssumeRoleARN = ptr.String("arn:aws:iam::123456789012:user/discovery")
+			Expect(ant.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("CPUCreditSpecification", func() {
+		It("should succeed with a supported credit specification", func() {
+			for _, credits := range v1alpha1.CPUCreditSpecifications {
+				ant.Spec.CPUCreditSpecification = ptr.String(credits)
+				Expect(ant.Validate(ctx)).To(Succeed())
+			}
+		})
+		It("should fail with an unsupported credit specification", func() {
+			ant.Spec.CPUCreditSpecification = ptr.String("burst")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if launch template is also specified", func() {
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.CPUCreditSpecification = ptr.String(v1alpha1.CPUCreditSpecificationUnlimited)
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("EnclaveOptions", func() {
+		It("should succeed if enclaves are enabled", func() {
+			ant.Spec.EnclaveOptions = &v1alpha1.EnclaveOptions{Enabled: aws.Bool(true)}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail if launch template is also specified", func() {
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.EnclaveOptions = &v1alpha1.EnclaveOptions{Enabled: aws.Bool(true)}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("CPUOptions", func() {
+		It("should succeed with a supported AMD SEV-SNP option", func() {
+			for _, amdSevSnp := range v1alpha1.AMDSEVSNPs {
+				ant.Spec.CPUOptions = &v1alpha1.CPUOptions{AMDSEVSNP: ptr.String(amdSevSnp)}
+				Expect(ant.Validate(ctx)).To(Succeed())
+			}
+		})
+		It("should fail with an unsupported AMD SEV-SNP option", func() {
+			ant.Spec.CPUOptions = &v1alpha1.CPUOptions{AMDSEVSNP: ptr.String("on")}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if launch template is also specified", func() {
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.CPUOptions = &v1alpha1.CPUOptions{AMDSEVSNP: ptr.String(v1alpha1.AMDSEVSNPEnabled)}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("InstanceInitiatedShutdownBehavior", func() {
+		It("should succeed with a supported shutdown behavior", func() {
+			for _, behavior := range v1alpha1.InstanceInitiatedShutdownBehaviors {
+				ant.Spec.InstanceInitiatedShutdownBehavior = ptr.String(behavior)
+				Expect(ant.Validate(ctx)).To(Succeed())
+			}
+		})
+		It("should fail with an unsupported shutdown behavior", func() {
+			ant.Spec.InstanceInitiatedShutdownBehavior = ptr.String("hibernate")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if launch template is also specified", func() {
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.InstanceInitiatedShutdownBehavior = ptr.String(v1alpha1.InstanceInitiatedShutdownBehaviorStop)
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("BlockDeviceMappings", func() {
+		var ebs *v1alpha1.BlockDevice
+
+		BeforeEach(func() {
+			ebs = &v1alpha1.BlockDevice{VolumeSize: lo.ToPtr(resource.MustParse("100Gi"))}
+			ant.Spec.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{{DeviceName: aws.String("/dev/xvda"), EBS: ebs}}
+		})
+		It("should succeed with gp3 IOPS and throughput within the limits for the volume size", func() {
+			ebs.VolumeType = aws.String(ec2.VolumeTypeGp3)
+			ebs.IOPS = aws.Int64(16000)
+			ebs.Throughput = aws.Int64(1000)
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail when gp3 IOPS exceed 500 per GiB", func() {
+			ebs.VolumeType = aws.String(ec2.VolumeTypeGp3)
+			ebs.VolumeSize = lo.ToPtr(resource.MustParse("8Gi"))
+			ebs.IOPS = aws.Int64(4001)
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail when gp3 throughput exceeds 0.25 MiB/s per IOPS", func() {
+			ebs.VolumeType = aws.String(ec2.VolumeTypeGp3)
+			ebs.Throughput = aws.Int64(751)
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail when io2 volumes don't specify IOPS", func() {
+			ebs.VolumeType = aws.String(ec2.VolumeTypeIo2)
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+			ebs.IOPS = aws.Int64(1000)
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail when there are more block device mappings than can be attached", func() {
+			ant.Spec.BlockDeviceMappings = lo.Times(28, func(i int) *v1alpha1.BlockDeviceMapping {
+				return &v1alpha1.BlockDeviceMapping{DeviceName: aws.String(fmt.Sprintf("/dev/xvd%d", i)), EBS: &v1alpha1.BlockDevice{VolumeSize: lo.ToPtr(resource.MustParse("20Gi"))}}
+			})
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
 	})
 	Context("Tags", func() {
 		It("should succeed when tags are empty", func() {
@@ -210,6 +889,22 @@ var _ = Describe("Validation", func() {
 				"karpenter.sh/managed-by": "test",
 			}
 			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+			ant.Spec.Tags = map[string]string{
+				"karpenter.sh/nodepool": "test",
+			}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+			ant.Spec.Tags = map[string]string{
+				"eks:cluster-name": "test",
+			}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should succeed with templated tags", func() {
+			ant.Spec.Tags = map[string]string{"team": `{{ index .Labels "team" }}`, "zone": "{{ .Zone }}"}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with a tag value that can't be parsed as a template", func() {
+			ant.Spec.Tags = map[string]string{"team": "{{ .Labels", "literal": "{{"}
+			Expect(ant.Validate(ctx)).To(Succeed())
 		})
 	})
 	var _ = Describe("AWSNodeTemplate Hash", func() {
@@ -18,6 +18,7 @@ import (
 	"context"
 
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/samber/lo"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	v1 "k8s.io/api/core/v1"
 	"knative.dev/pkg/apis"
@@ -25,6 +26,7 @@ import (
 	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
 	"github.com/aws/karpenter-core/pkg/scheduling"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
 )
 
 // Provisioner is an alias type for additional validation
@@ -46,7 +48,16 @@ func (p *Provisioner) Validate(_ context.Context) (errs *apis.FieldError) {
 	if err != nil {
 		return apis.ErrGeneric(err.Error())
 	}
-	return provider.Validate()
+	return provider.Validate().Also(p.validateAMIFamily(provider))
+}
+
+// validateAMIFamily rejects requirements that the AMIs of the provider's AMIFamily can never satisfy, since the
+// Provisioner would never be able to launch a node
+func (p *Provisioner) validateAMIFamily(provider *v1alpha1.AWS) *apis.FieldError {
+	if err := v1beta1.ValidateAMIFamilyRequirements(lo.FromPtrOr(provider.AMIFamily, v1alpha1.AMIFamilyAL2), scheduling.NewNodeSelectorRequirements(p.Spec.Requirements...)); err != nil {
+		return apis.ErrGeneric(err.Error(), "requirements")
+	}
+	return nil
 }
 
 func (p *Provisioner) SetDefaults(_ context.Context) {
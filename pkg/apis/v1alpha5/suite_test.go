@@ -171,6 +171,57 @@ var _ = Describe("Provisioner", func() {
 			})
 		})
 
+		Context("AMIFamily", func() {
+			It("should allow requirements that the AMIs of the AMIFamily satisfy", func() {
+				provider, err := v1alpha1.DeserializeProvider(provisioner.Spec.Provider.Raw)
+				Expect(err).ToNot(HaveOccurred())
+				provider.AMIFamily = aws.String(v1alpha1.AMIFamilyWindows2022)
+				Expect(lo.ToPtr(apisv1alpha5.Provisioner(*test.Provisioner(test.ProvisionerOptions{Provider: provider, Requirements: []v1.NodeSelectorRequirement{
+					{Key: v1.LabelOSStable, Operator: v1.NodeSelectorOpIn, Values: []string{string(v1.Windows)}},
+				}}))).Validate(ctx)).To(Succeed())
+			})
+			It("should not allow the arm64 architecture with a Windows AMIFamily", func() {
+				provider, err := v1alpha1.DeserializeProvider(provisioner.Spec.Provider.Raw)
+				Expect(err).ToNot(HaveOccurred())
+				provider.AMIFamily = aws.String(v1alpha1.AMIFamilyWindows2022)
+				Expect(Validate(ctx, test.Provisioner(test.ProvisionerOptions{Provider: provider, Requirements: []v1.NodeSelectorRequirement{
+					{Key: v1.LabelOSStable, Operator: v1.NodeSelectorOpIn, Values: []string{string(v1.Windows)}},
+					{Key: v1.LabelArchStable, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.ArchitectureArm64}},
+				}}))).ToNot(Succeed())
+			})
+			It("should not allow the linux OS with a Windows AMIFamily", func() {
+				provider, err := v1alpha1.DeserializeProvider(provisioner.Spec.Provider.Raw)
+				Expect(err).ToNot(HaveOccurred())
+				provider.AMIFamily = aws.String(v1alpha1.AMIFamilyWindows2019)
+				Expect(Validate(ctx, test.Provisioner(test.ProvisionerOptions{Provider: provider}))).ToNot(Succeed())
+			})
+			It("should not allow another Windows build than the Windows AMIFamily's", func() {
+				provider, err := v1alpha1.DeserializeProvider(provisioner.Spec.Provider.Raw)
+				Expect(err).ToNot(HaveOccurred())
+				provider.AMIFamily = aws.String(v1alpha1.AMIFamilyWindows2019)
+				Expect(Validate(ctx, test.Provisioner(test.ProvisionerOptions{Provider: provider, Requirements: []v1.NodeSelectorRequirement{
+					{Key: v1.LabelOSStable, Operator: v1.NodeSelectorOpIn, Values: []string{string(v1.Windows)}},
+					{Key: v1.LabelWindowsBuild, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha1.Windows2022Build}},
+				}}))).ToNot(Succeed())
+			})
+			It("should not allow a Windows build with a Linux AMIFamily", func() {
+				provider, err := v1alpha1.DeserializeProvider(provisioner.Spec.Provider.Raw)
+				Expect(err).ToNot(HaveOccurred())
+				provider.AMIFamily = aws.String(v1alpha1.AMIFamilyBottlerocket)
+				Expect(Validate(ctx, test.Provisioner(test.ProvisionerOptions{Provider: provider, Requirements: []v1.NodeSelectorRequirement{
+					{Key: v1.LabelWindowsBuild, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha1.Windows2022Build}},
+				}}))).ToNot(Succeed())
+			})
+			It("should allow any requirements with the Custom AMIFamily", func() {
+				provider, err := v1alpha1.DeserializeProvider(provisioner.Spec.Provider.Raw)
+				Expect(err).ToNot(HaveOccurred())
+				provider.AMIFamily = aws.String(v1alpha1.AMIFamilyCustom)
+				Expect(lo.ToPtr(apisv1alpha5.Provisioner(*test.Provisioner(test.ProvisionerOptions{Provider: provider, Requirements: []v1.NodeSelectorRequirement{
+					{Key: v1.LabelOSStable, Operator: v1.NodeSelectorOpIn, Values: []string{string(v1.Windows)}},
+					{Key: v1.LabelArchStable, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.ArchitectureArm64}},
+				}}))).Validate(ctx)).To(Succeed())
+			})
+		})
 		Context("Labels", func() {
 			It("should not allow unrecognized labels with the aws label prefix", func() {
 				provisioner.Spec.Labels = map[string]string{v1alpha1.LabelDomain + "/" + randomdata.SillyName(): randomdata.SillyName()}
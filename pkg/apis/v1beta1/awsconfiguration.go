@@ -0,0 +1,66 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AWSConfigurationName is the name of the AWSConfiguration that Karpenter reads from its own namespace
+const AWSConfigurationName = "default"
+
+// AWSConfigurationSpec is the configuration of the AWS integrations of Karpenter. Fields that are set take precedence
+// over the same settings in the karpenter-global-settings ConfigMap. Like the ConfigMap, the configuration is read
+// when Karpenter starts.
+type AWSConfigurationSpec struct {
+	// ClusterName is the name of the EKS cluster that Karpenter launches nodes into
+	// +optional
+	ClusterName *string `json:"clusterName,omitempty"`
+	// ClusterEndpoint is the endpoint of the cluster's API server. It's discovered with EKS DescribeCluster if not set.
+	// +kubebuilder:validation:Pattern:="^https://.+$"
+	// +optional
+	ClusterEndpoint *string `json:"clusterEndpoint,omitempty"`
+	// InterruptionQueueName is the name of the SQS queue that Karpenter receives interruption events from
+	// +optional
+	InterruptionQueueName *string `json:"interruptionQueueName,omitempty"`
+	// VMMemoryOverheadPercent is the fraction of an instance type's memory that's subtracted for the hypervisor,
+	// e.g. "0.075"
+	// +kubebuilder:validation:Pattern:="^[0-9]+(\\.[0-9]+)?$"
+	// +optional
+	VMMemoryOverheadPercent *string `json:"vmMemoryOverheadPercent,omitempty"`
+	// Tags are applied to all of the resources that Karpenter creates
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// AWSConfiguration is the Schema for the AWSConfiguration API
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=awsconfigurations,scope=Namespaced,categories=karpenter
+// +kubebuilder:subresource:status
+type AWSConfiguration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AWSConfigurationSpec   `json:"spec,omitempty"`
+	Status AWSConfigurationStatus `json:"status,omitempty"`
+}
+
+// AWSConfigurationList contains a list of AWSConfiguration
+// +kubebuilder:object:root=true
+type AWSConfigurationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AWSConfiguration `json:"items"`
+}
@@ -0,0 +1,51 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"knative.dev/pkg/apis"
+)
+
+// AWSConfigurationStatus contains the health of the integrations that the AWSConfiguration configures
+type AWSConfigurationStatus struct {
+	// Conditions contains signals for whether each of the integrations is healthy
+	// +optional
+	Conditions apis.Conditions `json:"conditions,omitempty"`
+}
+
+var (
+	// AWSConfigurationClusterReady is whether EKS DescribeCluster succeeds for the configured cluster
+	AWSConfigurationClusterReady apis.ConditionType = "ClusterReady"
+	// AWSConfigurationInterruptionQueueReady is whether the configured interruption queue exists
+	AWSConfigurationInterruptionQueueReady apis.ConditionType = "InterruptionQueueReady"
+	// AWSConfigurationPricingReady is whether the on-demand and spot prices are being refreshed
+	AWSConfigurationPricingReady apis.ConditionType = "PricingReady"
+)
+
+func (in *AWSConfiguration) StatusConditions() apis.ConditionManager {
+	return apis.NewLivingConditionSet(
+		AWSConfigurationClusterReady,
+		AWSConfigurationInterruptionQueueReady,
+		AWSConfigurationPricingReady,
+	).Manage(in)
+}
+
+func (in *AWSConfiguration) GetConditions() apis.Conditions {
+	return in.Status.Conditions
+}
+
+func (in *AWSConfiguration) SetConditions(conditions apis.Conditions) {
+	in.Status.Conditions = conditions
+}
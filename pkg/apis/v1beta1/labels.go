@@ -47,7 +47,9 @@ func init() {
 		LabelInstanceAcceleratorName,
 		LabelInstanceAcceleratorManufacturer,
 		LabelInstanceAcceleratorCount,
+		LabelSpotInterruptionRateBucket,
 		v1.LabelWindowsBuild,
+		LabelTopologyZoneID,
 	)
 }
 
@@ -73,6 +75,9 @@ var (
 		regexp.MustCompile(fmt.Sprintf("^%s$", regexp.QuoteMeta(v1alpha5.MachineManagedByAnnotationKey))),
 		regexp.MustCompile(fmt.Sprintf("^%s$", regexp.QuoteMeta(v1beta1.NodePoolLabelKey))),
 		regexp.MustCompile(fmt.Sprintf("^%s$", regexp.QuoteMeta(v1beta1.ManagedByAnnotationKey))),
+		regexp.MustCompile(fmt.Sprintf("^%s$", regexp.QuoteMeta(EKSClusterNameTagKey))),
+		regexp.MustCompile(fmt.Sprintf("^%s$", regexp.QuoteMeta(AnnotationStoppedAt))),
+		regexp.MustCompile(fmt.Sprintf("^%s$", regexp.QuoteMeta(VolumeDeleteOnTerminationTagKey))),
 	}
 	AMIFamilyBottlerocket = "Bottlerocket"
 	AMIFamilyAL2          = "AL2"
@@ -88,17 +93,134 @@ var (
 		AMIFamilyWindows2022,
 		AMIFamilyCustom,
 	}
-	Windows2019                                = "2019"
-	Windows2022                                = "2022"
-	WindowsCore                                = "Core"
-	Windows2019Build                           = "10.0.17763"
-	Windows2022Build                           = "10.0.20348"
-	ResourceNVIDIAGPU          v1.ResourceName = "nvidia.com/gpu"
-	ResourceAMDGPU             v1.ResourceName = "amd.com/gpu"
-	ResourceAWSNeuron          v1.ResourceName = "aws.amazon.com/neuron"
-	ResourceHabanaGaudi        v1.ResourceName = "habana.ai/gaudi"
-	ResourceAWSPodENI          v1.ResourceName = "vpc.amazonaws.com/pod-eni"
-	ResourcePrivateIPv4Address v1.ResourceName = "vpc.amazonaws.com/PrivateIPv4Address"
+	SubnetPolicyAny         = "any"
+	SubnetPolicyPrivateOnly = "privateOnly"
+	SupportedSubnetPolicies = []string{
+		SubnetPolicyAny,
+		SubnetPolicyPrivateOnly,
+	}
+	BottlerocketBootstrapContainerModeAlways = "always"
+	BottlerocketBootstrapContainerModeOnce   = "once"
+	BottlerocketBootstrapContainerModeOff    = "off"
+	BottlerocketBootstrapContainerModes      = []string{
+		BottlerocketBootstrapContainerModeAlways,
+		BottlerocketBootstrapContainerModeOnce,
+		BottlerocketBootstrapContainerModeOff,
+	}
+	CPUManagerPolicyNone   = "none"
+	CPUManagerPolicyStatic = "static"
+	CPUManagerPolicies     = []string{
+		CPUManagerPolicyNone,
+		CPUManagerPolicyStatic,
+	}
+	TopologyManagerPolicyNone           = "none"
+	TopologyManagerPolicyBestEffort     = "best-effort"
+	TopologyManagerPolicyRestricted     = "restricted"
+	TopologyManagerPolicySingleNUMANode = "single-numa-node"
+	TopologyManagerPolicies             = []string{
+		TopologyManagerPolicyNone,
+		TopologyManagerPolicyBestEffort,
+		TopologyManagerPolicyRestricted,
+		TopologyManagerPolicySingleNUMANode,
+	}
+	MemoryManagerPolicyNone   = "None"
+	MemoryManagerPolicyStatic = "Static"
+	MemoryManagerPolicies     = []string{
+		MemoryManagerPolicyNone,
+		MemoryManagerPolicyStatic,
+	}
+	NVIDIADriverOpen        = "open"
+	NVIDIADriverProprietary = "proprietary"
+	NVIDIADrivers           = []string{
+		NVIDIADriverOpen,
+		NVIDIADriverProprietary,
+	}
+	DeletionPolicyBlock       = "block"
+	DeletionPolicyCascade     = "cascade"
+	SupportedDeletionPolicies = []string{
+		DeletionPolicyBlock,
+		DeletionPolicyCascade,
+	}
+	UserDataRefKindConfigMap = "ConfigMap"
+	UserDataRefKindSecret    = "Secret"
+	UserDataRefKinds         = []string{
+		UserDataRefKindConfigMap,
+		UserDataRefKindSecret,
+	}
+	UserDataMergeOrderPreBootstrap  = "PreBootstrap"
+	UserDataMergeOrderPostBootstrap = "PostBootstrap"
+	UserDataMergeOrders             = []string{
+		UserDataMergeOrderPreBootstrap,
+		UserDataMergeOrderPostBootstrap,
+	}
+	UserDataCompressionNone = "None"
+	UserDataCompressionGzip = "Gzip"
+	UserDataCompressions    = []string{
+		UserDataCompressionNone,
+		UserDataCompressionGzip,
+	}
+	// UserDataMaxSize is the largest base64 encoded UserData, in bytes, that EC2 accepts
+	UserDataMaxSize = 16 * 1024
+
+	AcceleratorTypeGPU       = "gpu"
+	AcceleratorTypeFPGA      = "fpga"
+	AcceleratorTypeInference = "inference"
+	AcceleratorTypes         = []string{
+		AcceleratorTypeGPU,
+		AcceleratorTypeFPGA,
+		AcceleratorTypeInference,
+	}
+	AcceleratorManufacturers = []string{
+		"amazon-web-services",
+		"amd",
+		"habana",
+		"nvidia",
+		"xilinx",
+	}
+	CPUCreditSpecificationStandard  = "standard"
+	CPUCreditSpecificationUnlimited = "unlimited"
+	CPUCreditSpecifications         = []string{
+		CPUCreditSpecificationStandard,
+		CPUCreditSpecificationUnlimited,
+	}
+	InstanceInitiatedShutdownBehaviorStop      = "stop"
+	InstanceInitiatedShutdownBehaviorTerminate = "terminate"
+	InstanceInitiatedShutdownBehaviors         = []string{
+		InstanceInitiatedShutdownBehaviorStop,
+		InstanceInitiatedShutdownBehaviorTerminate,
+	}
+	AMDSEVSNPEnabled  = "enabled"
+	AMDSEVSNPDisabled = "disabled"
+	AMDSEVSNPs        = []string{
+		AMDSEVSNPEnabled,
+		AMDSEVSNPDisabled,
+	}
+	WindowsVariants = []string{
+		WindowsCore,
+		WindowsFull,
+	}
+	// BottlerocketClusterSettings are the settings.kubernetes keys that Karpenter sets in Bottlerocket userData
+	BottlerocketClusterSettings = []string{
+		"api-server",
+		"cluster-certificate",
+		"cluster-name",
+	}
+	Windows2019                                 = "2019"
+	Windows2022                                 = "2022"
+	WindowsCore                                 = "Core"
+	WindowsFull                                 = "Full"
+	Windows2019Build                            = "10.0.17763"
+	Windows2022Build                            = "10.0.20348"
+	ResourceNVIDIAGPU           v1.ResourceName = "nvidia.com/gpu"
+	ResourceAMDGPU              v1.ResourceName = "amd.com/gpu"
+	ResourceAWSNeuron           v1.ResourceName = "aws.amazon.com/neuron"
+	ResourceAWSElasticInference v1.ResourceName = "aws.amazon.com/eia"
+	ResourceHabanaGaudi         v1.ResourceName = "habana.ai/gaudi"
+	ResourceAWSPodENI           v1.ResourceName = "vpc.amazonaws.com/pod-eni"
+	ResourcePrivateIPv4Address  v1.ResourceName = "vpc.amazonaws.com/PrivateIPv4Address"
+
+	// LabelTopologyZoneID is the ID of the zone, which identifies the same physical zone across accounts
+	LabelTopologyZoneID = "topology.k8s.aws/zone-id"
 
 	LabelInstanceHypervisor                   = Group + "/instance-hypervisor"
 	LabelInstanceEncryptionInTransitSupported = Group + "/instance-encryption-in-transit-supported"
@@ -118,5 +240,37 @@ var (
 	LabelInstanceAcceleratorName              = Group + "/instance-accelerator-name"
 	LabelInstanceAcceleratorManufacturer      = Group + "/instance-accelerator-manufacturer"
 	LabelInstanceAcceleratorCount             = Group + "/instance-accelerator-count"
+	LabelSpotInterruptionRateBucket           = Group + "/spot-interruption-rate-bucket"
 	AnnotationNodeClassHash                   = Group + "/nodeclass-hash"
+	AnnotationNodeClassHashWithoutTags        = Group + "/nodeclass-hash-without-tags"
+	AnnotationDriftPolicy                     = Group + "/drift-policy"
+	AnnotationDriftedAMIID                    = Group + "/drifted-ami-id"
+	AnnotationResolvedAMIID                   = Group + "/resolved-ami-id"
+	AnnotationResolvedAMIName                 = Group + "/resolved-ami-name"
+	AnnotationTerminationReason               = Group + "/termination-reason"
+	AnnotationUserDataHash                    = Group + "/userdata-hash"
+	AnnotationMigratedFrom                    = Group + "/migrated-from"
+	AnnotationStoppedAt                       = Group + "/stopped-at"
+	AnnotationPriceEstimate                   = Group + "/price-estimate"
+	AnnotationPriceEstimateCapacityType       = Group + "/price-estimate-capacity-type"
+	AnnotationPriceEstimateZone               = Group + "/price-estimate-zone"
+	TerminationFinalizer                      = Group + "/termination"
+
+	// SSMAgentNotRegisteredTaintKey is the startup taint of nodes whose NodeClass requires the SSM agent, which is
+	// removed once the instance is registered with Systems Manager
+	SSMAgentNotRegisteredTaintKey = Group + "/ssm-agent-not-registered"
+
+	// EKSClusterNameTagKey is the tag that EKS managed resources are tagged with to identify their cluster
+	EKSClusterNameTagKey = "eks:cluster-name"
+	// NodeClassTagKey is the tag that the resources Karpenter manages for a NodeClass are tagged with
+	NodeClassTagKey = Group + "/nodeclass"
+	// VolumeDeleteOnTerminationTagKey is the tag that the volumes of an instance are tagged with when every one of its
+	// volumes is deleted on termination, so that volumes that are left behind can be garbage collected without
+	// deleting volumes that were meant to be retained
+	VolumeDeleteOnTerminationTagKey = Group + "/delete-on-termination"
+	// SubnetExcludeTagKey excludes the subnets that are tagged with "true" from every NodeClass that selects them
+	SubnetExcludeTagKey = "karpenter.sh/exclude"
+	// SubnetPriorityTagKey is an integer priority of a subnet over the other subnets in its zone, which defaults to 0.
+	// Launches are made into the subnets with the highest priority that have enough available IP addresses.
+	SubnetPriorityTagKey = "karpenter.sh/priority"
 )
@@ -16,9 +16,11 @@ package v1beta1
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/mitchellh/hashstructure/v2"
 	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -29,12 +31,22 @@ type NodeClassSpec struct {
 	// SubnetSelectorTerms is a list of or subnet selector terms. The terms are ORed.
 	// +optional
 	SubnetSelectorTerms []SubnetSelectorTerm `json:"subnetSelectorTerms" hash:"ignore"`
+	// SubnetPolicy restricts which of the subnets matched by SubnetSelectorTerms may be used for launches.
+	// "privateOnly" excludes subnets that map public IPs on launch or that route to an internet gateway.
+	// +kubebuilder:validation:Enum:={any,privateOnly}
+	// +optional
+	SubnetPolicy *string `json:"subnetPolicy,omitempty" hash:"ignore"`
 	// SecurityGroupSelectorTerms is a list of or security group selector terms. The terms are ORed.
 	// +optional
 	SecurityGroupSelectorTerms []SecurityGroupSelectorTerm `json:"securityGroupSelectorTerms" hash:"ignore"`
 	// AMISelectorTerms is a list of or ami selector terms. The terms are ORed.
 	// +optional
 	AMISelectorTerms []AMISelectorTerm `json:"amiSelectorTerms,omitempty" hash:"ignore"`
+	// AMIMaxAge is the age that the AMI of a node can reach once a newer AMI is selected before the node is drifted,
+	// so that nodes are replaced on a predictable cadence rather than every time an AMI is released. If not set,
+	// nodes are drifted as soon as a newer AMI is selected.
+	// +optional
+	AMIMaxAge *metav1.Duration `json:"amiMaxAge,omitempty" hash:"ignore"`
 	// AMIFamily is the AMI family that instances use.
 	// +optional
 	AMIFamily *string `json:"amiFamily,omitempty"`
@@ -43,6 +55,24 @@ type NodeClassSpec struct {
 	// this UserData to ensure nodes are being provisioned with the correct configuration.
 	// +optional
 	UserData *string `json:"userData,omitempty"`
+	// UserDataTemplating renders the UserData as a Go template before it's merged, substituting the {{ .ClusterName }},
+	// {{ .ClusterEndpoint }}, {{ .CABundle }} and {{ .NodePool }} variables, so that the UserData can be shared by clusters.
+	// +optional
+	UserDataTemplating *bool `json:"userDataTemplating,omitempty"`
+	// UserDataRef reads the UserData from a ConfigMap or Secret in Karpenter's namespace rather than from this resource,
+	// so that bootstrap secrets aren't stored in it. Nodes drift when the referenced UserData changes.
+	// +optional
+	UserDataRef *UserDataReference `json:"userDataRef,omitempty"`
+	// UserDataMergeOrder controls whether the custom UserData runs before or after the generated bootstrap script on AL2,
+	// so that agents can start either before the kubelet or once the node has joined the cluster. Defaults to PreBootstrap.
+	// +kubebuilder:validation:Enum:={PreBootstrap,PostBootstrap}
+	// +optional
+	UserDataMergeOrder *string `json:"userDataMergeOrder,omitempty"`
+	// UserDataCompression gzip compresses the merged UserData on AL2, which cloud-init decompresses, so that UserData
+	// larger than the 16KB that EC2 allows can be passed to nodes. Defaults to None.
+	// +kubebuilder:validation:Enum:={None,Gzip}
+	// +optional
+	UserDataCompression *string `json:"userDataCompression,omitempty"`
 	// Role is the AWS identity that nodes use.
 	// +optional
 	Role *string `json:"role,omitempty"`
@@ -52,9 +82,94 @@ type NodeClassSpec struct {
 	// BlockDeviceMappings to be applied to provisioned nodes.
 	// +optional
 	BlockDeviceMappings []*BlockDeviceMapping `json:"blockDeviceMappings,omitempty"`
+	// EphemeralStorageSizing sizes the volume that backs ephemeral storage, e.g. the Bottlerocket data volume,
+	// from the ephemeral-storage requests of the pods that a node is launched for.
+	// +optional
+	EphemeralStorageSizing *EphemeralStorageSizing `json:"ephemeralStorageSizing,omitempty"`
 	// DetailedMonitoring controls if detailed monitoring is enabled for instances that are launched
 	// +optional
 	DetailedMonitoring *bool `json:"detailedMonitoring,omitempty"`
+	// CPUCreditSpecification is the credit option for CPU usage of burstable (T family) instance types. "unlimited"
+	// instances are charged for surplus CPU credits, which is added to the price of their offerings. Defaults to the
+	// EC2 default of the instance type.
+	// +kubebuilder:validation:Enum:={standard,unlimited}
+	// +optional
+	CPUCreditSpecification *string `json:"cpuCreditSpecification,omitempty"`
+	// EnclaveOptions enable AWS Nitro Enclaves on instances that are launched, for confidential-computing workloads.
+	// Only instance types that support enclaves are launched when they're enabled.
+	// +optional
+	EnclaveOptions *EnclaveOptions `json:"enclaveOptions,omitempty"`
+	// CPUOptions configure the processor of instances that are launched. Only instance types that support AMD SEV-SNP
+	// are launched when it's enabled.
+	// +optional
+	CPUOptions *CPUOptions `json:"cpuOptions,omitempty"`
+	// RequireEncryptionInTransit only launches instance types that encrypt traffic between instances in transit with
+	// their ENA, e.g. for compliance. Such instance types have the instance-encryption-in-transit-supported label.
+	// +optional
+	RequireEncryptionInTransit *bool `json:"requireEncryptionInTransit,omitempty"`
+	// InstanceInitiatedShutdownBehavior is what happens to instances that are launched when they're shut down from the
+	// operating system. "stop" keeps the instance and its volumes so that it can be started again. Defaults to the EC2
+	// default of "stop", but instances that were launched as spot are always terminated.
+	// +kubebuilder:validation:Enum:={stop,terminate}
+	// +optional
+	InstanceInitiatedShutdownBehavior *string `json:"instanceInitiatedShutdownBehavior,omitempty"`
+	// StartupTaints are registered on every node that is launched with this NodeClass, in addition to the NodePool's
+	// startup taints, e.g. node.cilium.io/agent-not-ready for a CNI agent that removes the taint once it's ready.
+	// +optional
+	StartupTaints []v1.Taint `json:"startupTaints,omitempty"`
+	// RequireSSMAgent holds nodes that are launched with this NodeClass uninitialized until their SSM agent has
+	// registered the instance with Systems Manager, so that SSM operations such as patching can reach every node.
+	// +optional
+	RequireSSMAgent *bool `json:"requireSSMAgent,omitempty" hash:"ignore"`
+	// RegistrationTTL is how long an instance that is launched with this NodeClass has to join the cluster before it's
+	// terminated and relaunched, with its offering marked unavailable so that the relaunch uses a different one. It
+	// must be at most 15m, after which instances that haven't joined the cluster are always terminated.
+	// +optional
+	RegistrationTTL *metav1.Duration `json:"registrationTTL,omitempty" hash:"ignore"`
+	// Bottlerocket settings are merged into the Bottlerocket settings that Karpenter generates, so that host containers,
+	// bootstrap containers, kernel sysctls and container registries can be configured without a full TOML userData.
+	// Settings from userData are overridden by these, which are in turn overridden by Karpenter's kubernetes settings.
+	// +optional
+	Bottlerocket *BottlerocketSettings `json:"bottlerocket,omitempty"`
+	// ContainerRegistries configures containerd on AL2 nodes to pull images from registries through mirrors, e.g. a
+	// pull-through cache, without custom userData.
+	// +optional
+	ContainerRegistries []ContainerRegistry `json:"containerRegistries,omitempty"`
+	// Kubelet configures kubelet settings that aren't part of the NodePool's kubelet configuration. They're merged into
+	// the kubelet config file on AL2 nodes and into settings.kubernetes on Bottlerocket nodes.
+	// +optional
+	Kubelet *KubeletConfiguration `json:"kubelet,omitempty"`
+	// MaxPodsPerInstanceType overrides the maxPods of the instance types whose names match a glob, e.g. "m5.*" or
+	// "*.metal", taking precedence over the NodePool's maxPods and the ENI limited pod density. When several globs
+	// match an instance type, the longest one is used.
+	// +optional
+	MaxPodsPerInstanceType map[string]int32 `json:"maxPodsPerInstanceType,omitempty"`
+	// NVIDIA configures the NVIDIA driver variant of the accelerated AMIs and the NVIDIA container runtime.
+	// +optional
+	NVIDIA *NVIDIAConfiguration `json:"nvidia,omitempty"`
+	// Windows configures the CSI proxy and gMSA on nodes of the Windows AMIFamilies.
+	// +optional
+	Windows *WindowsConfiguration `json:"windows,omitempty"`
+	// InstanceRequirements lets EC2 select the instance type of each launch from these attributes, rather than from
+	// overrides for each of the instance types that Karpenter considers, which keeps the fleet requests of very flexible
+	// NodePools small. Karpenter only considers the instance types that match the attributes.
+	// +optional
+	InstanceRequirements *InstanceRequirements `json:"instanceRequirements,omitempty"`
+	// AssumeRoleARN is the ARN of an IAM role that is assumed to discover the subnets, security groups and AMIs of this
+	// NodeClass, e.g. to select resources that are owned by a shared-services account. The role is assumed with the
+	// controller's credentials. Instances are still launched with the controller's credentials.
+	// +kubebuilder:validation:Pattern:="^arn:aws[a-z-]*:iam::[0-9]{12}:role/.+$"
+	// +optional
+	AssumeRoleARN *string `json:"assumeRoleARN,omitempty" hash:"ignore"`
+	// LaunchDryRun overrides the aws.enableLaunchDryRun setting for this NodeClass. When enabled, a DryRun CreateFleet
+	// is made before launching so that broken credentials fail fast with an authorization error.
+	// +optional
+	LaunchDryRun *bool `json:"launchDryRun,omitempty" hash:"ignore"`
+	// DeletionPolicy controls what happens to NodeClaims that reference this NodeClass when it is deleted.
+	// "block" holds the NodeClass in deletion until the NodeClaims are gone, "cascade" deletes them.
+	// +kubebuilder:validation:Enum:={block,cascade}
+	// +optional
+	DeletionPolicy *string `json:"deletionPolicy,omitempty" hash:"ignore"`
 	// MetadataOptions for the generated launch template of provisioned nodes.
 	//
 	// This specifies the exposure of the Instance Metadata Service to
@@ -113,6 +228,20 @@ type SubnetSelectorTerm struct {
 	// +kubebuilder:validation:Pattern="subnet-[0-9a-z]+"
 	// +optional
 	ID string `json:"id,omitempty"`
+	// Zone is the name of the availability zone of the subnet
+	// +optional
+	Zone string `json:"zone,omitempty"`
+	// ZoneID is the ID of the availability zone of the subnet, which identifies the same physical zone across accounts
+	// +optional
+	ZoneID string `json:"zoneID,omitempty"`
+	// CIDR is the IPv4 CIDR block of the subnet
+	// +optional
+	CIDR string `json:"cidr,omitempty"`
+	// OwnerAccountID is the ID of the AWS account that owns the subnet, which selects subnets that are shared with
+	// this account from another account
+	// +kubebuilder:validation:Pattern="^[0-9]{12}$"
+	// +optional
+	OwnerAccountID string `json:"ownerAccountID,omitempty"`
 }
 
 // SecurityGroupSelectorTerm defines selection logic for a security group used by Karpenter to launch nodes.
@@ -129,6 +258,11 @@ type SecurityGroupSelectorTerm struct {
 	// Name is the security group name in EC2.
 	// This value is the name field, which is different from the name tag.
 	Name string `json:"name,omitempty"`
+	// VPCID is the ID of the VPC that the security group belongs to. Security group names are only unique within a
+	// VPC, so this narrows a name or tags to the VPC that the selected subnets are in.
+	// +kubebuilder:validation:Pattern:="vpc-[0-9a-z]+"
+	// +optional
+	VPCID string `json:"vpcID,omitempty"`
 }
 
 // AMISelectorTerm defines selection logic for an ami used by Karpenter to launch nodes.
@@ -146,11 +280,24 @@ type AMISelectorTerm struct {
 	// This value is the name field, which is different from the name tag.
 	// +optional
 	Name string `json:"name,omitempty"`
+	// NameRegex is a regular expression that the ami name must match, for names that can't be selected with the
+	// wildcards of Name.
+	// +optional
+	NameRegex string `json:"nameRegex,omitempty"`
 	// Owner is the owner for the ami.
 	// You can specify a combination of AWS account IDs, "self", "amazon", and "aws-marketplace"
 	// +optional
 	Owner string `json:"owner,omitempty"`
-	// SSM is the ssm alias for an ami.
+	// Architecture is the architecture of the ami.
+	// +kubebuilder:validation:Enum:={amd64,arm64}
+	// +optional
+	Architecture string `json:"architecture,omitempty"`
+	// MinCreationDate excludes amis that were created before it. It's either an RFC3339 timestamp, or a duration such
+	// as 720h that selects the amis that were created within that duration of now.
+	// +optional
+	MinCreationDate string `json:"minCreationDate,omitempty"`
+	// SSM is the name of an ssm parameter that holds the id of the ami, such as a parameter that an ami pipeline promotes
+	// tested amis to. The parameter is resolved again periodically, and nodes drift once it holds another ami.
 	// +optional
 	SSM string `json:"ssm,omitempty"`
 }
@@ -196,6 +343,239 @@ type MetadataOptions struct {
 	HTTPTokens *string `json:"httpTokens,omitempty"`
 }
 
+// EphemeralStorageSizing is the formula used to size the volume that backs ephemeral storage. The volume is sized to
+// the ephemeral-storage requests of the node plus Overhead, bounded by MinSize and MaxSize.
+type EphemeralStorageSizing struct {
+	// MinSize is the smallest volume that is launched. Defaults to the size of the ephemeral block device
+	// in BlockDeviceMappings, or to the AMIFamily's default.
+	// +optional
+	MinSize *resource.Quantity `json:"minSize,omitempty" hash:"string"`
+	// MaxSize is the largest volume that is launched. Instance types advertise this as their ephemeral-storage
+	// capacity so that pods with large ephemeral-storage requests can be scheduled, and launched NodeClaims
+	// advertise the size of their volume.
+	// +required
+	MaxSize resource.Quantity `json:"maxSize" hash:"string"`
+	// Overhead is added to the ephemeral-storage requests of the node to leave room for container images and logs.
+	// +optional
+	Overhead *resource.Quantity `json:"overhead,omitempty" hash:"string"`
+}
+
+// EnclaveOptions configure AWS Nitro Enclaves (https://docs.aws.amazon.com/enclaves/latest/user/nitro-enclave.html)
+type EnclaveOptions struct {
+	// Enabled launches instances with Nitro Enclaves enabled.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// CPUOptions configure the processor of instances
+type CPUOptions struct {
+	// AMDSEVSNP turns AMD SEV-SNP (https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/sev-snp.html) on or off.
+	// Defaults to the EC2 default, which is disabled.
+	// +kubebuilder:validation:Enum:={enabled,disabled}
+	// +optional
+	AMDSEVSNP *string `json:"amdSevSnp,omitempty"`
+}
+
+// UserDataReference references the key of a ConfigMap or Secret in Karpenter's namespace that holds the UserData
+type UserDataReference struct {
+	// Kind of the referenced object.
+	// +kubebuilder:validation:Enum:={ConfigMap,Secret}
+	// +required
+	Kind string `json:"kind"`
+	// Name of the ConfigMap or Secret.
+	// +required
+	Name string `json:"name"`
+	// Key of the UserData in the ConfigMap or Secret.
+	// +required
+	Key string `json:"key"`
+}
+
+// WindowsConfiguration configures the Windows features that the generated PowerShell sets up before bootstrapping
+type WindowsConfiguration struct {
+	// Variant selects between the EKS optimized Windows Server Core and Full AMIs. Defaults to Core.
+	// +kubebuilder:validation:Enum:={Core,Full}
+	// +optional
+	Variant *string `json:"variant,omitempty"`
+	// CSIProxy runs the CSI proxy as a Windows service, which CSI node plugins use to manage SMB and block storage on
+	// the host.
+	// +optional
+	CSIProxy *WindowsCSIProxy `json:"csiProxy,omitempty"`
+	// GMSA registers a Container Credential Guard plugin, which retrieves the gMSA credentials of the credential specs
+	// that pods reference on nodes that aren't joined to the domain.
+	// +optional
+	GMSA *WindowsGMSA `json:"gmsa,omitempty"`
+}
+
+// WindowsCSIProxy configures the CSI proxy, see https://github.com/kubernetes-csi/csi-proxy
+type WindowsCSIProxy struct {
+	// BinaryURL downloads csi-proxy.exe from the HTTPS URL. If not specified, the binary in the AMI is used.
+	// +optional
+	BinaryURL *string `json:"binaryURL,omitempty"`
+}
+
+// WindowsGMSA configures gMSA for pods on nodes that aren't joined to the domain, see
+// https://learn.microsoft.com/en-us/virtualization/windowscontainers/manage-containers/manage-serviceaccounts
+type WindowsGMSA struct {
+	// PluginCLSID is the COM class ID of the Container Credential Guard plugin that the AMI includes.
+	// +required
+	PluginCLSID string `json:"pluginCLSID"`
+}
+
+// NVIDIAConfiguration configures the NVIDIA GPU support of the nodes
+type NVIDIAConfiguration struct {
+	// Driver selects between the accelerated AMIs with NVIDIA's open GPU kernel modules and the ones with the
+	// proprietary kernel modules. Defaults to proprietary. It's supported by the AL2 and Bottlerocket AMIFamilies.
+	// +kubebuilder:validation:Enum:={open,proprietary}
+	// +optional
+	Driver *string `json:"driver,omitempty"`
+	// ContainerRuntime configures containerd to use the NVIDIA container runtime by default before the custom userData
+	// runs. It's supported by the Custom AMIFamily, whose AMIs must include the NVIDIA container toolkit.
+	// +optional
+	ContainerRuntime *bool `json:"containerRuntime,omitempty"`
+}
+
+// InstanceRequirements are the attributes of the instance types that EC2 selects from, see
+// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/ec2-fleet-attribute-based-instance-type-selection.html
+type InstanceRequirements struct {
+	// VCPU is the range of the number of vCPUs.
+	// +optional
+	VCPU *InstanceRequirementsRange `json:"vcpu,omitempty"`
+	// MemoryMiB is the range of the amount of memory, in MiB.
+	// +optional
+	MemoryMiB *InstanceRequirementsRange `json:"memoryMiB,omitempty"`
+	// AcceleratorCount is the range of the number of GPUs, FPGAs and inference accelerators. A maximum of 0 excludes
+	// instance types with accelerators.
+	// +optional
+	AcceleratorCount *InstanceRequirementsRange `json:"acceleratorCount,omitempty"`
+	// AcceleratorTypes are the types of accelerators, one of which the instance types must have.
+	// +optional
+	AcceleratorTypes []string `json:"acceleratorTypes,omitempty"`
+	// AcceleratorManufacturers are the manufacturers of accelerators, one of which the instance types must have.
+	// +optional
+	AcceleratorManufacturers []string `json:"acceleratorManufacturers,omitempty"`
+	// ExcludedInstanceTypes are the instance types that EC2 doesn't select, which can include * wildcards, e.g. "t2.*"
+	// or "m5a*" to exclude instance families.
+	// +kubebuilder:validation:MaxItems:=400
+	// +optional
+	ExcludedInstanceTypes []string `json:"excludedInstanceTypes,omitempty"`
+}
+
+// InstanceRequirementsRange is an inclusive range, which is unbounded on the side whose limit isn't set
+type InstanceRequirementsRange struct {
+	// +kubebuilder:validation:Minimum:=0
+	// +optional
+	Min *int64 `json:"min,omitempty"`
+	// +kubebuilder:validation:Minimum:=0
+	// +optional
+	Max *int64 `json:"max,omitempty"`
+}
+
+// KubeletConfiguration is a subset of the kubelet's configuration, see
+// https://kubernetes.io/docs/reference/config-api/kubelet-config.v1beta1/
+type KubeletConfiguration struct {
+	// ShutdownGracePeriod is how long the node delays shutdown by to terminate its pods.
+	// +optional
+	ShutdownGracePeriod *metav1.Duration `json:"shutdownGracePeriod,omitempty"`
+	// ShutdownGracePeriodCriticalPods is the part of ShutdownGracePeriod that is reserved for terminating critical pods.
+	// +optional
+	ShutdownGracePeriodCriticalPods *metav1.Duration `json:"shutdownGracePeriodCriticalPods,omitempty"`
+	// RegistryPullQPS limits the image pulls per second, 0 is unlimited.
+	// +optional
+	RegistryPullQPS *int32 `json:"registryPullQPS,omitempty"`
+	// RegistryBurst is the burst of image pulls that RegistryPullQPS allows.
+	// +optional
+	RegistryBurst *int32 `json:"registryBurst,omitempty"`
+	// CPUManagerPolicy is the kubelet's CPU manager policy. The static policy gives Guaranteed pods with integer CPU
+	// requests exclusive CPUs.
+	// +kubebuilder:validation:Enum:={none,static}
+	// +optional
+	CPUManagerPolicy *string `json:"cpuManagerPolicy,omitempty"`
+	// TopologyManagerPolicy is the kubelet's topology manager policy, which aligns the CPUs, memory and devices of a
+	// pod to NUMA nodes.
+	// +kubebuilder:validation:Enum:={none,best-effort,restricted,single-numa-node}
+	// +optional
+	TopologyManagerPolicy *string `json:"topologyManagerPolicy,omitempty"`
+	// MemoryManagerPolicy is the kubelet's memory manager policy. The Static policy pins the memory of Guaranteed pods
+	// to NUMA nodes.
+	// +kubebuilder:validation:Enum:={None,Static}
+	// +optional
+	MemoryManagerPolicy *string `json:"memoryManagerPolicy,omitempty"`
+}
+
+// ContainerRegistry is rendered into a containerd hosts.toml for the registry, see
+// https://github.com/containerd/containerd/blob/main/docs/hosts.md
+type ContainerRegistry struct {
+	// Registry is the registry host that is mirrored, e.g. docker.io, or "_default" for every registry.
+	// +required
+	Registry string `json:"registry"`
+	// Mirrors are the endpoints that images are pulled through, in the order that they're tried before the registry.
+	// +kubebuilder:validation:MinItems:=1
+	// +required
+	Mirrors []string `json:"mirrors"`
+	// Auth is the base64 encoded "username:password" that is sent to the mirrors. It's stored in plain text in the
+	// NodeClass and in the instance's userData, so prefer short-lived or read-only credentials.
+	// +optional
+	Auth *string `json:"auth,omitempty"`
+}
+
+// BottlerocketSettings are the sections of the Bottlerocket settings API, beyond kubernetes, that can be configured
+// from a NodeClass. See https://bottlerocket.dev/en/os/latest/#/api/settings/ for the meaning of each setting.
+type BottlerocketSettings struct {
+	// HostContainers configures host containers by name, e.g. the admin and control containers.
+	// +optional
+	HostContainers map[string]BottlerocketHostContainer `json:"hostContainers,omitempty"`
+	// BootstrapContainers configures bootstrap containers by name, which run before kubelet starts.
+	// +optional
+	BootstrapContainers map[string]BottlerocketBootstrapContainer `json:"bootstrapContainers,omitempty"`
+	// KernelSysctl are kernel parameters that are set on boot, e.g. net.core.somaxconn.
+	// +optional
+	KernelSysctl map[string]string `json:"kernelSysctl,omitempty"`
+	// RegistryMirrors are the endpoints that images from a registry are pulled through.
+	// +optional
+	RegistryMirrors []BottlerocketRegistryMirror `json:"registryMirrors,omitempty"`
+}
+
+type BottlerocketHostContainer struct {
+	// Source is the image of the host container.
+	// +optional
+	Source *string `json:"source,omitempty"`
+	// Enabled controls if the host container runs.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+	// Superpowered controls if the host container runs with elevated privileges.
+	// +optional
+	Superpowered *bool `json:"superpowered,omitempty"`
+	// UserData is base64 encoded data that is passed to the host container.
+	// +optional
+	UserData *string `json:"userData,omitempty"`
+}
+
+type BottlerocketBootstrapContainer struct {
+	// Source is the image of the bootstrap container.
+	// +optional
+	Source *string `json:"source,omitempty"`
+	// Mode controls if the bootstrap container runs on every boot, on the next boot only, or not at all.
+	// +kubebuilder:validation:Enum:={always,once,off}
+	// +optional
+	Mode *string `json:"mode,omitempty"`
+	// Essential controls if the boot fails when the bootstrap container fails.
+	// +optional
+	Essential *bool `json:"essential,omitempty"`
+	// UserData is base64 encoded data that is passed to the bootstrap container.
+	// +optional
+	UserData *string `json:"userData,omitempty"`
+}
+
+type BottlerocketRegistryMirror struct {
+	// Registry is the registry that is mirrored, e.g. docker.io, or "*" for every registry.
+	// +required
+	Registry string `json:"registry"`
+	// Endpoints are the mirror endpoints, in the order that they're tried.
+	// +kubebuilder:validation:MinItems:=1
+	// +required
+	Endpoints []string `json:"endpoints"`
+}
+
 type BlockDeviceMapping struct {
 	// The device name (for example, /dev/sdh or xvdh).
 	// +optional
@@ -283,13 +663,59 @@ type NodeClass struct {
 }
 
 func (a *NodeClass) Hash() string {
-	return fmt.Sprint(lo.Must(hashstructure.Hash(a.Spec, hashstructure.FormatV2, &hashstructure.HashOptions{
+	return fmt.Sprint(lo.Must(hashstructure.Hash(a.Spec.withoutDefaults(), hashstructure.FormatV2, &hashstructure.HashOptions{
 		SlicesAsSets:    true,
 		IgnoreZeroValue: true,
 		ZeroNil:         true,
 	})))
 }
 
+// HashWithoutTags is the static-field hash of the NodeClass without its tags, which nodes are compared with rather
+// than the static-field hash when the drift policy of the tags is InPlace
+func (a *NodeClass) HashWithoutTags() string {
+	spec := a.Spec.withoutDefaults()
+	spec.Tags = nil
+	return fmt.Sprint(lo.Must(hashstructure.Hash(spec, hashstructure.FormatV2, &hashstructure.HashOptions{
+		SlicesAsSets:    true,
+		IgnoreZeroValue: true,
+		ZeroNil:         true,
+	})))
+}
+
+const (
+	// DriftPolicyReplace replaces the nodes whose field differs from the NodeClass, which is the policy of every field
+	// by default
+	DriftPolicyReplace = "Replace"
+	// DriftPolicyInPlace updates the field of the nodes in place rather than replacing them
+	DriftPolicyInPlace = "InPlace"
+)
+
+// DriftPolicies are the policies that the fields support in the drift-policy annotation
+var DriftPolicies = map[string][]string{
+	"tags": {DriftPolicyReplace, DriftPolicyInPlace},
+}
+
+// ParseDriftPolicies parses the drift-policy annotation, a comma separated list of field=policy pairs such as
+// "tags=InPlace"
+func ParseDriftPolicies(annotation string) (map[string]string, error) {
+	policies := map[string]string{}
+	for _, pair := range strings.Split(annotation, ",") {
+		field, policy, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found {
+			return nil, fmt.Errorf("%q must be a field=policy pair", pair)
+		}
+		supported, ok := DriftPolicies[field]
+		if !ok {
+			return nil, fmt.Errorf("field %q must be one of %v", field, lo.Keys(DriftPolicies))
+		}
+		if !lo.Contains(supported, policy) {
+			return nil, fmt.Errorf("policy %q of field %q must be one of %v", policy, field, supported)
+		}
+		policies[field] = policy
+	}
+	return policies, nil
+}
+
 // NodeClassList contains a list of NodeClass
 // +kubebuilder:object:root=true
 type NodeClassList struct {
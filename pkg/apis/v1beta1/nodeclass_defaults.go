@@ -16,7 +16,99 @@ package v1beta1
 
 import (
 	"context"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
-// SetDefaults for the NodeClass
-func (a *NodeClass) SetDefaults(_ context.Context) {}
+// SetDefaults materializes the configuration that Karpenter would otherwise assume when launching nodes, so that the
+// stored NodeClass shows its effective configuration. Block device mappings aren't defaulted, since they depend on the
+// AMIFamily and would go stale when it changes, so they're resolved when nodes are launched.
+func (a *NodeClass) SetDefaults(ctx context.Context) {
+	a.Spec.SetDefaults(ctx)
+}
+
+func (in *NodeClassSpec) SetDefaults(_ context.Context) {
+	if in.AMIFamily == nil {
+		in.AMIFamily = lo.ToPtr(AMIFamilyAL2)
+	}
+	in.setDefaultMetadataOptions()
+}
+
+// withoutDefaults returns a copy of the spec without the values that are the same as its defaults, so that the hash of
+// a NodeClass doesn't change when it's defaulted, e.g. when it's first updated after upgrading. Block device mappings
+// that are the defaults of the AMIFamily are removed too, as they were stored by earlier versions of the webhook.
+func (in *NodeClassSpec) withoutDefaults() *NodeClassSpec {
+	spec := in.DeepCopy()
+	if equality.Semantic.DeepEqual(spec.BlockDeviceMappings, DefaultBlockDeviceMappings(lo.FromPtrOr(spec.AMIFamily, AMIFamilyAL2))) {
+		spec.BlockDeviceMappings = nil
+	}
+	if lo.FromPtr(spec.AMIFamily) == AMIFamilyAL2 {
+		spec.AMIFamily = nil
+	}
+	if spec.MetadataOptions != nil {
+		defaults := &NodeClassSpec{}
+		defaults.setDefaultMetadataOptions()
+		if equality.Semantic.DeepEqual(spec.MetadataOptions.HTTPEndpoint, defaults.MetadataOptions.HTTPEndpoint) {
+			spec.MetadataOptions.HTTPEndpoint = nil
+		}
+		if equality.Semantic.DeepEqual(spec.MetadataOptions.HTTPPutResponseHopLimit, defaults.MetadataOptions.HTTPPutResponseHopLimit) {
+			spec.MetadataOptions.HTTPPutResponseHopLimit = nil
+		}
+		if equality.Semantic.DeepEqual(spec.MetadataOptions.HTTPTokens, defaults.MetadataOptions.HTTPTokens) {
+			spec.MetadataOptions.HTTPTokens = nil
+		}
+		if equality.Semantic.DeepEqual(spec.MetadataOptions, &MetadataOptions{}) {
+			spec.MetadataOptions = nil
+		}
+	}
+	return spec
+}
+
+// setDefaultMetadataOptions defaults to IMDSv2. httpProtocolIPv6 is left unset, since it's enabled in IPv6 clusters
+// and that's only known when the node is launched.
+func (in *NodeClassSpec) setDefaultMetadataOptions() {
+	if in.MetadataOptions == nil {
+		in.MetadataOptions = &MetadataOptions{}
+	}
+	if in.MetadataOptions.HTTPEndpoint == nil {
+		in.MetadataOptions.HTTPEndpoint = lo.ToPtr(ec2.LaunchTemplateInstanceMetadataEndpointStateEnabled)
+	}
+	if in.MetadataOptions.HTTPPutResponseHopLimit == nil {
+		in.MetadataOptions.HTTPPutResponseHopLimit = lo.ToPtr[int64](2)
+	}
+	if in.MetadataOptions.HTTPTokens == nil {
+		in.MetadataOptions.HTTPTokens = lo.ToPtr(ec2.LaunchTemplateHttpTokensStateRequired)
+	}
+}
+
+// DefaultBlockDeviceMappings returns the block device mappings of nodes of the AMI family that don't specify any. The
+// Custom AMI family has none, so that the volumes defined by the AMI are used.
+func DefaultBlockDeviceMappings(amiFamily string) []*BlockDeviceMapping {
+	switch amiFamily {
+	case AMIFamilyBottlerocket:
+		return []*BlockDeviceMapping{
+			{DeviceName: lo.ToPtr("/dev/xvda"), EBS: DefaultEBS("4Gi")},
+			{DeviceName: lo.ToPtr("/dev/xvdb"), EBS: DefaultEBS("20Gi")},
+		}
+	case AMIFamilyUbuntu:
+		return []*BlockDeviceMapping{{DeviceName: lo.ToPtr("/dev/sda1"), EBS: DefaultEBS("20Gi")}}
+	case AMIFamilyWindows2019, AMIFamilyWindows2022:
+		return []*BlockDeviceMapping{{DeviceName: lo.ToPtr("/dev/sda1"), EBS: DefaultEBS("50Gi")}}
+	case AMIFamilyCustom:
+		return nil
+	default:
+		return []*BlockDeviceMapping{{DeviceName: lo.ToPtr("/dev/xvda"), EBS: DefaultEBS("20Gi")}}
+	}
+}
+
+// DefaultEBS returns an encrypted gp3 volume of the size
+func DefaultEBS(size string) *BlockDevice {
+	return &BlockDevice{
+		Encrypted:  lo.ToPtr(true),
+		VolumeType: lo.ToPtr(ec2.VolumeTypeGp3),
+		VolumeSize: lo.ToPtr(resource.MustParse(size)),
+	}
+}
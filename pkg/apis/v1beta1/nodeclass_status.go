@@ -14,7 +14,10 @@ limitations under the License.
 
 package v1beta1
 
-import v1 "k8s.io/api/core/v1"
+import (
+	v1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/apis"
+)
 
 // Subnet contains resolved Subnet selector values utilized for node launch
 type Subnet struct {
@@ -24,6 +27,12 @@ type Subnet struct {
 	// The associated availability zone
 	// +required
 	Zone string `json:"zone"`
+	// The associated availability zone ID, which identifies the same physical zone across accounts
+	// +optional
+	ZoneID string `json:"zoneID,omitempty"`
+	// AvailableIPAddressCount is the number of unused private IPv4 addresses in the subnet
+	// +optional
+	AvailableIPAddressCount int64 `json:"availableIPAddressCount,omitempty"`
 }
 
 // SecurityGroup contains resolved SecurityGroup selector values utilized for node launch
@@ -63,4 +72,43 @@ type NodeClassStatus struct {
 	// cluster under the AMI selectors.
 	// +optional
 	AMIs []AMI `json:"amis,omitempty"`
+	// InstanceProfile contains the resolved instance profile that is attached to launched nodes
+	// +optional
+	InstanceProfile string `json:"instanceProfile,omitempty"`
+	// UserDataHash is the hash of the UserData that userDataRef references, which nodes drift from when it changes
+	// +optional
+	UserDataHash string `json:"userDataHash,omitempty"`
+	// Conditions contains signals for whether the resolved state is usable for launches
+	// +optional
+	Conditions apis.Conditions `json:"conditions,omitempty"`
+}
+
+var (
+	NodeClassSubnetsReady        apis.ConditionType = "SubnetsReady"
+	NodeClassSecurityGroupsReady apis.ConditionType = "SecurityGroupsReady"
+	NodeClassValidationSucceeded apis.ConditionType = "ValidationSucceeded"
+	// NodeClassNodeClaimsTerminated is only set while the NodeClass is being deleted and lists the NodeClaims
+	// that still reference it. It does not contribute to Ready.
+	NodeClassNodeClaimsTerminated apis.ConditionType = "NodeClaimsTerminated"
+)
+
+// NodeClaimLaunchSucceeded is set to False on NodeClaims and Machines whose launch failed, with the reason that it
+// failed with, e.g. QuotaExceeded or Unauthorized. It's set to True once a launch that had failed succeeds, and does
+// not contribute to Ready.
+var NodeClaimLaunchSucceeded apis.ConditionType = "LaunchSucceeded"
+
+func (in *NodeClass) StatusConditions() apis.ConditionManager {
+	return apis.NewLivingConditionSet(
+		NodeClassSubnetsReady,
+		NodeClassSecurityGroupsReady,
+		NodeClassValidationSucceeded,
+	).Manage(in)
+}
+
+func (in *NodeClass) GetConditions() apis.Conditions {
+	return in.Status.Conditions
+}
+
+func (in *NodeClass) SetConditions(conditions apis.Conditions) {
+	in.Status.Conditions = conditions
 }
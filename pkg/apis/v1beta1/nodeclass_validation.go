@@ -16,32 +16,105 @@ package v1beta1
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/url"
+	"path"
+	"regexp"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pelletier/go-toml/v2"
 	"github.com/samber/lo"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"knative.dev/pkg/apis"
+
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/scheduling"
 )
 
 const (
 	userDataPath                   = "userData"
+	userDataTemplatingPath         = "userDataTemplating"
+	userDataRefPath                = "userDataRef"
+	userDataMergeOrderPath         = "userDataMergeOrder"
+	userDataCompressionPath        = "userDataCompression"
 	subnetSelectorTermsPath        = "subnetSelectorTerms"
+	subnetPolicyPath               = "subnetPolicy"
 	securityGroupSelectorTermsPath = "securityGroupSelectorTerms"
 	amiSelectorTermsPath           = "amiSelectorTerms"
 	amiFamilyPath                  = "amiFamily"
 	tagsPath                       = "tags"
 	metadataOptionsPath            = "metadataOptions"
 	blockDeviceMappingsPath        = "blockDeviceMappings"
+	ephemeralStorageSizingPath     = "ephemeralStorageSizing"
+	deletionPolicyPath             = "deletionPolicy"
+	startupTaintsPath              = "startupTaints"
+	bottlerocketPath               = "bottlerocket"
+	containerRegistriesPath        = "containerRegistries"
+	kubeletPath                    = "kubelet"
+	maxPodsPerInstanceTypePath     = "maxPodsPerInstanceType"
+	cpuCreditSpecificationPath     = "cpuCreditSpecification"
+	cpuOptionsPath                 = "cpuOptions"
+	shutdownBehaviorPath           = "instanceInitiatedShutdownBehavior"
+	assumeRoleARNPath              = "assumeRoleARN"
+	nvidiaPath                     = "nvidia"
+	windowsPath                    = "windows"
+	instanceRequirementsPath       = "instanceRequirements"
+	encryptionInTransitPath        = "requireEncryptionInTransit"
+	amiMaxAgePath                  = "amiMaxAge"
+	registrationTTLPath            = "registrationTTL"
 )
 
 var (
 	minVolumeSize = *resource.NewScaledQuantity(1, resource.Giga)
 	maxVolumeSize = *resource.NewScaledQuantity(64, resource.Tera)
+	// registryRegex matches the registry hosts that containerd looks up in its hosts directory
+	registryRegex = regexp.MustCompile(`^[a-zA-Z0-9._-]+(:[0-9]+)?$`)
+	// clsidRegex matches a COM class ID without braces
+	clsidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-([0-9a-fA-F]{4}-){3}[0-9a-fA-F]{12}$`)
+	// accountIDRegex matches an AWS account ID
+	accountIDRegex = regexp.MustCompile(`^[0-9]{12}$`)
+	// roleARNRegex matches the ARN of an IAM role in any partition
+	roleARNRegex = regexp.MustCompile(`^arn:aws[a-z-]*:iam::[0-9]{12}:role/.+$`)
+	// excludedInstanceTypeRegex matches instance type names and the * wildcards that EC2 accepts in them
+	excludedInstanceTypeRegex = regexp.MustCompile(`^[a-z0-9.*-]+$`)
+	// maxBlockDeviceMappings is the number of EBS attachments left on a Nitro instance after the primary network interface
+	maxBlockDeviceMappings = 27
+	// volumeTypeLimits are the size, IOPS and throughput bounds that EBS enforces for each volume type
+	volumeTypeLimits = map[string]ebsLimits{
+		ec2.VolumeTypeStandard: {minSizeGiB: 1, maxSizeGiB: 1024},
+		ec2.VolumeTypeGp2:      {minSizeGiB: 1, maxSizeGiB: 16384},
+		ec2.VolumeTypeGp3:      {minSizeGiB: 1, maxSizeGiB: 16384, minIOPS: 3000, maxIOPS: 16000, maxIOPSPerGiB: 500, minThroughput: 125, maxThroughput: 1000},
+		ec2.VolumeTypeIo1:      {minSizeGiB: 4, maxSizeGiB: 16384, minIOPS: 100, maxIOPS: 64000, maxIOPSPerGiB: 50},
+		ec2.VolumeTypeIo2:      {minSizeGiB: 4, maxSizeGiB: 16384, minIOPS: 100, maxIOPS: 64000, maxIOPSPerGiB: 500},
+		ec2.VolumeTypeSt1:      {minSizeGiB: 125, maxSizeGiB: 16384},
+		ec2.VolumeTypeSc1:      {minSizeGiB: 125, maxSizeGiB: 16384},
+	}
+	// maxRegistrationTTL is the registration TTL after which karpenter-core terminates instances that haven't joined the
+	// cluster, regardless of the NodeClass
+	maxRegistrationTTL = 15 * time.Minute
 )
 
+type ebsLimits struct {
+	minSizeGiB, maxSizeGiB       int64
+	minIOPS, maxIOPS             int64
+	maxIOPSPerGiB                int64
+	minThroughput, maxThroughput int64
+}
+
 func (a *NodeClass) SupportedVerbs() []admissionregistrationv1.OperationType {
 	return []admissionregistrationv1.OperationType{
 		admissionregistrationv1.Create,
@@ -52,20 +125,51 @@ func (a *NodeClass) SupportedVerbs() []admissionregistrationv1.OperationType {
 func (a *NodeClass) Validate(ctx context.Context) (errs *apis.FieldError) {
 	return errs.Also(
 		apis.ValidateObjectMetadata(a).ViaField("metadata"),
+		a.validateDriftPolicy().ViaField("metadata"),
 		a.Spec.validate(ctx).ViaField("spec"),
 	)
 }
 
+func (a *NodeClass) validateDriftPolicy() (errs *apis.FieldError) {
+	if policy, ok := a.Annotations[AnnotationDriftPolicy]; ok {
+		if _, err := ParseDriftPolicies(policy); err != nil {
+			return apis.ErrInvalidValue(policy, "", err.Error()).ViaKey(AnnotationDriftPolicy).ViaField("annotations")
+		}
+	}
+	return nil
+}
+
 func (in *NodeClassSpec) validate(_ context.Context) (errs *apis.FieldError) {
 	return errs.Also(
 		in.validateSubnetSelectorTerms().ViaField(subnetSelectorTermsPath),
+		in.validateSubnetPolicy(),
+		in.validateDeletionPolicy(),
 		in.validateSecurityGroupSelectorTerms().ViaField(securityGroupSelectorTermsPath),
 		in.validateAMISelectorTerms().ViaField(amiSelectorTermsPath),
 		in.validateMetadataOptions().ViaField(metadataOptionsPath),
 		in.validateAMIFamily().ViaField(amiFamilyPath),
 		in.validateBlockDeviceMappings().ViaField(blockDeviceMappingsPath),
+		in.validateEphemeralStorageSizing().ViaField(ephemeralStorageSizingPath),
 		in.validateUserData().ViaField(userDataPath),
+		in.validateUserDataRef(),
+		in.validateUserDataMergeOrder(),
+		in.validateUserDataCompression(),
 		in.validateTags().ViaField(tagsPath),
+		in.validateStartupTaints().ViaField(startupTaintsPath),
+		in.validateBottlerocket().ViaField(bottlerocketPath),
+		in.validateContainerRegistries().ViaField(containerRegistriesPath),
+		in.validateKubelet().ViaField(kubeletPath),
+		in.validateMaxPodsPerInstanceType().ViaField(maxPodsPerInstanceTypePath),
+		in.validateCPUCreditSpecification(),
+		in.validateCPUOptions().ViaField(cpuOptionsPath),
+		in.validateInstanceInitiatedShutdownBehavior(),
+		in.validateAssumeRoleARN(),
+		in.validateNVIDIA().ViaField(nvidiaPath),
+		in.validateWindows().ViaField(windowsPath),
+		in.validateInstanceRequirements().ViaField(instanceRequirementsPath),
+		in.validateRequireEncryptionInTransit(),
+		in.validateAMIMaxAge(),
+		in.validateRegistrationTTL(),
 	)
 }
 
@@ -79,13 +183,23 @@ func (in *NodeClassSpec) validateSubnetSelectorTerms() (errs *apis.FieldError) {
 	return errs
 }
 
+//nolint:gocyclo
 func (in *SubnetSelectorTerm) validate() (errs *apis.FieldError) {
 	errs = errs.Also(validateTags(in.Tags).ViaField("tags"))
-	if len(in.Tags) == 0 && in.ID == "" {
-		errs = errs.Also(apis.ErrGeneric("expected at least one, got none", "tags", "id"))
-	} else if in.ID != "" && len(in.Tags) > 0 {
+	hasFilters := len(in.Tags) > 0 || in.Zone != "" || in.ZoneID != "" || in.CIDR != "" || in.OwnerAccountID != ""
+	if !hasFilters && in.ID == "" {
+		errs = errs.Also(apis.ErrGeneric("expected at least one, got none", "tags", "id", "zone", "zoneID", "cidr", "ownerAccountID"))
+	} else if in.ID != "" && hasFilters {
 		errs = errs.Also(apis.ErrGeneric(`"id" is mutually exclusive, cannot be set with a combination of other fields in`))
 	}
+	if in.CIDR != "" {
+		if ip, _, err := net.ParseCIDR(in.CIDR); err != nil || ip.To4() == nil {
+			errs = errs.Also(apis.ErrInvalidValue(in.CIDR, "cidr", "must be an IPv4 CIDR block"))
+		}
+	}
+	if in.OwnerAccountID != "" && !accountIDRegex.MatchString(in.OwnerAccountID) {
+		errs = errs.Also(apis.ErrInvalidValue(in.OwnerAccountID, "ownerAccountID", "must be a 12 digit AWS account ID"))
+	}
 	return errs
 }
 
@@ -104,7 +218,7 @@ func (in *SecurityGroupSelectorTerm) validate() (errs *apis.FieldError) {
 	errs = errs.Also(validateTags(in.Tags).ViaField("tags"))
 	if len(in.Tags) == 0 && in.ID == "" && in.Name == "" {
 		errs = errs.Also(apis.ErrGeneric("expect at least one, got none", "tags", "id", "name"))
-	} else if in.ID != "" && (len(in.Tags) > 0 || in.Name != "") {
+	} else if in.ID != "" && (len(in.Tags) > 0 || in.Name != "" || in.VPCID != "") {
 		errs = errs.Also(apis.ErrGeneric(`"id" is mutually exclusive, cannot be set with a combination of other fields in`))
 	} else if in.Name != "" && (len(in.Tags) > 0 || in.ID != "") {
 		errs = errs.Also(apis.ErrGeneric(`"name" is mutually exclusive, cannot be set with a combination of other fields in`))
@@ -124,12 +238,42 @@ func (in *AMISelectorTerm) validate() (errs *apis.FieldError) {
 	errs = errs.Also(validateTags(in.Tags).ViaField("tags"))
 	if len(in.Tags) == 0 && in.ID == "" && in.Name == "" && in.SSM == "" {
 		errs = errs.Also(apis.ErrGeneric("expect at least one, got none", "tags", "id", "name", "ssm"))
-	} else if in.ID != "" && (len(in.Tags) > 0 || in.Name != "" || in.SSM != "" || in.Owner != "") {
+	} else if in.ID != "" && (len(in.Tags) > 0 || in.Name != "" || in.SSM != "" || in.Owner != "" ||
+		in.NameRegex != "" || in.Architecture != "" || in.MinCreationDate != "") {
 		errs = errs.Also(apis.ErrGeneric(`"id" is mutually exclusive, cannot be set with a combination of other fields in`))
+	} else if in.SSM != "" && (len(in.Tags) > 0 || in.Name != "" || in.Owner != "" ||
+		in.NameRegex != "" || in.Architecture != "" || in.MinCreationDate != "") {
+		errs = errs.Also(apis.ErrGeneric(`"ssm" is mutually exclusive, cannot be set with a combination of other fields in`))
+	}
+	if in.NameRegex != "" {
+		if _, err := regexp.Compile(in.NameRegex); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s, %s", in.NameRegex, err), "nameRegex"))
+		}
+	}
+	if in.Architecture != "" && !WellKnownArchitectures.Has(in.Architecture) {
+		errs = errs.Also(apis.ErrInvalidValue(in.Architecture, "architecture"))
+	}
+	if in.MinCreationDate != "" {
+		if _, err := ParseMinCreationDate(in.MinCreationDate, time.Now()); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(in.MinCreationDate, "minCreationDate", err.Error()))
+		}
 	}
 	return errs
 }
 
+// ParseMinCreationDate returns the time of an AMISelectorTerm's MinCreationDate, which is either an RFC3339 timestamp
+// or a duration before now
+func ParseMinCreationDate(minCreationDate string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, minCreationDate); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(minCreationDate)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("expected an RFC3339 timestamp or a positive duration")
+	}
+	return now.Add(-d), nil
+}
+
 func validateTags(m map[string]string) (errs *apis.FieldError) {
 	for k, v := range m {
 		if k == "" {
@@ -195,7 +339,33 @@ func (in *NodeClassSpec) validateStringEnum(value, field string, validValues []s
 	return apis.ErrInvalidValue(fmt.Sprintf("%s not in %v", value, strings.Join(validValues, ", ")), field)
 }
 
+func (in *NodeClassSpec) validateEphemeralStorageSizing() (errs *apis.FieldError) {
+	if in.EphemeralStorageSizing == nil {
+		return nil
+	}
+	if lo.FromPtr(in.AMIFamily) == AMIFamilyCustom {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("%s AMIFamily has no known ephemeral block device to size", AMIFamilyCustom)))
+	}
+	return errs.Also(in.EphemeralStorageSizing.validate())
+}
+
+func (in *EphemeralStorageSizing) validate() (errs *apis.FieldError) {
+	if in.MaxSize.Cmp(minVolumeSize) == -1 || in.MaxSize.Cmp(maxVolumeSize) == 1 {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(in.MaxSize.String(), minVolumeSize.String(), maxVolumeSize.String(), "maxSize"))
+	}
+	if in.MinSize != nil && (in.MinSize.Cmp(minVolumeSize) == -1 || in.MinSize.Cmp(in.MaxSize) == 1) {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(in.MinSize.String(), minVolumeSize.String(), in.MaxSize.String(), "minSize"))
+	}
+	if in.Overhead != nil && in.Overhead.Sign() < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(in.Overhead.String(), "overhead", "must not be negative"))
+	}
+	return errs
+}
+
 func (in *NodeClassSpec) validateBlockDeviceMappings() (errs *apis.FieldError) {
+	if len(in.BlockDeviceMappings) > maxBlockDeviceMappings {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("expected at most %d block device mappings, got %d", maxBlockDeviceMappings, len(in.BlockDeviceMappings))))
+	}
 	for i, blockDeviceMapping := range in.BlockDeviceMappings {
 		if err := in.validateBlockDeviceMapping(blockDeviceMapping); err != nil {
 			errs = errs.Also(err.ViaFieldIndex(blockDeviceMappingsPath, i))
@@ -222,6 +392,9 @@ func (in *NodeClassSpec) validateEBS(blockDeviceMapping *BlockDeviceMapping) (er
 	for _, err := range []*apis.FieldError{
 		in.validateVolumeType(blockDeviceMapping),
 		in.validateVolumeSize(blockDeviceMapping),
+		in.validateVolumeTypeSize(blockDeviceMapping),
+		in.validateIOPS(blockDeviceMapping),
+		in.validateThroughput(blockDeviceMapping),
 	} {
 		if err != nil {
 			errs = errs.Also(err.ViaField("ebs"))
@@ -249,6 +422,66 @@ func (in *NodeClassSpec) validateVolumeSize(blockDeviceMapping *BlockDeviceMappi
 	return nil
 }
 
+func (in *NodeClassSpec) validateVolumeTypeSize(blockDeviceMapping *BlockDeviceMapping) *apis.FieldError {
+	limits, ok := volumeTypeLimits[lo.FromPtr(blockDeviceMapping.EBS.VolumeType)]
+	if !ok || blockDeviceMapping.EBS.VolumeSize == nil {
+		return nil
+	}
+	if size := volumeSizeGiB(blockDeviceMapping.EBS.VolumeSize); size < limits.minSizeGiB || size > limits.maxSizeGiB {
+		return apis.ErrOutOfBoundsValue(blockDeviceMapping.EBS.VolumeSize.String(), fmt.Sprintf("%dGi", limits.minSizeGiB), fmt.Sprintf("%dGi", limits.maxSizeGiB), "volumeSize")
+	}
+	return nil
+}
+
+func (in *NodeClassSpec) validateIOPS(blockDeviceMapping *BlockDeviceMapping) *apis.FieldError {
+	if blockDeviceMapping.EBS.VolumeType == nil {
+		return nil
+	}
+	if blockDeviceMapping.EBS.IOPS == nil {
+		// Provisioned IOPS volumes can't be created without specifying their IOPS
+		if lo.Contains([]string{ec2.VolumeTypeIo1, ec2.VolumeTypeIo2}, *blockDeviceMapping.EBS.VolumeType) {
+			return apis.ErrMissingField("iops")
+		}
+		return nil
+	}
+	iops := *blockDeviceMapping.EBS.IOPS
+	limits := volumeTypeLimits[*blockDeviceMapping.EBS.VolumeType]
+	if limits.maxIOPS == 0 {
+		return apis.ErrGeneric(fmt.Sprintf("iops is not supported for %s volumes", *blockDeviceMapping.EBS.VolumeType), "iops")
+	}
+	maxIOPS := limits.maxIOPS
+	// IOPS scale with the size of the volume, though every volume is allowed its minimum (baseline) IOPS
+	if blockDeviceMapping.EBS.VolumeSize != nil {
+		maxIOPS = lo.Clamp(volumeSizeGiB(blockDeviceMapping.EBS.VolumeSize)*limits.maxIOPSPerGiB, limits.minIOPS, limits.maxIOPS)
+	}
+	if iops < limits.minIOPS || iops > maxIOPS {
+		return apis.ErrOutOfBoundsValue(iops, limits.minIOPS, maxIOPS, "iops")
+	}
+	return nil
+}
+
+func (in *NodeClassSpec) validateThroughput(blockDeviceMapping *BlockDeviceMapping) *apis.FieldError {
+	if blockDeviceMapping.EBS.Throughput == nil || blockDeviceMapping.EBS.VolumeType == nil {
+		return nil
+	}
+	throughput := *blockDeviceMapping.EBS.Throughput
+	limits := volumeTypeLimits[*blockDeviceMapping.EBS.VolumeType]
+	if limits.maxThroughput == 0 {
+		return apis.ErrGeneric(fmt.Sprintf("throughput is not supported for %s volumes", *blockDeviceMapping.EBS.VolumeType), "throughput")
+	}
+	// Throughput is limited to 0.25 MiB/s per provisioned IOPS, falling back to the baseline IOPS when unset
+	maxThroughput := lo.Clamp(lo.FromPtrOr(blockDeviceMapping.EBS.IOPS, limits.minIOPS)/4, limits.minThroughput, limits.maxThroughput)
+	if throughput < limits.minThroughput || throughput > maxThroughput {
+		return apis.ErrOutOfBoundsValue(throughput, limits.minThroughput, maxThroughput, "throughput")
+	}
+	return nil
+}
+
+// volumeSizeGiB converts the quantity to GiB, rounding up in the same way as the launch template provider
+func volumeSizeGiB(quantity *resource.Quantity) int64 {
+	return int64(math.Ceil(quantity.AsApproximateFloat64() / math.Pow(2, 30)))
+}
+
 func (in *NodeClassSpec) validateUserData() (errs *apis.FieldError) {
 	if in.UserData == nil {
 		return nil
@@ -256,9 +489,174 @@ func (in *NodeClassSpec) validateUserData() (errs *apis.FieldError) {
 	if lo.FromPtr(in.AMIFamily) == AMIFamilyWindows2019 || lo.FromPtr(in.AMIFamily) == AMIFamilyWindows2022 {
 		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("%s AMIFamily is not currently supported with custom userData", lo.FromPtr(in.AMIFamily)), userDataPath))
 	}
+	if lo.FromPtr(in.UserDataTemplating) {
+		if _, err := template.New(userDataPath).Parse(*in.UserData); err != nil {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("must be a valid template with %s enabled, %s", userDataTemplatingPath, err)))
+		}
+		// Templated userData is only valid once it's rendered, so it's validated when it's resolved
+		return errs
+	}
+	switch lo.FromPtr(in.AMIFamily) {
+	case AMIFamilyBottlerocket:
+		errs = errs.Also(validateBottlerocketUserData(*in.UserData))
+	case "", AMIFamilyAL2, AMIFamilyUbuntu:
+		errs = errs.Also(validateMIMEUserData(*in.UserData))
+	}
+	// Karpenter merges its own bootstrap configuration into the userData, so the userData that's launched is larger
+	// still and is checked against the limit again when it's resolved
+	if size := base64.StdEncoding.EncodedLen(len(*in.UserData)); size > UserDataMaxSize && lo.FromPtr(in.UserDataCompression) != UserDataCompressionGzip {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("is %d bytes once base64 encoded, exceeding the EC2 limit of %d bytes", size, UserDataMaxSize)))
+	}
+	return errs
+}
+
+// validateMIMEUserData validates that userData in the MIME multi-part format can be read, since it's merged with the
+// bootstrap script part by part. UserData in any other format is wrapped in a single part when it's merged.
+func validateMIMEUserData(userData string) *apis.FieldError {
+	if !strings.HasPrefix(strings.TrimSpace(userData), "MIME-Version:") && !strings.HasPrefix(strings.TrimSpace(userData), "Content-Type:") {
+		return nil
+	}
+	if err := readMIMEParts(userData); err != nil {
+		return apis.ErrGeneric(fmt.Sprintf("must be a valid MIME multi-part archive, %s", err))
+	}
+	return nil
+}
+
+func readMIMEParts(userData string) error {
+	message, err := mail.ReadMessage(strings.NewReader(userData))
+	if err != nil {
+		return err
+	}
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("parsing content type, %w", err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return fmt.Errorf("content type %s is not multipart", mediaType)
+	}
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := io.ReadAll(part); err != nil {
+			return err
+		}
+	}
+}
+
+// validateBottlerocketUserData validates that the userData is TOML that doesn't set the settings that Karpenter always
+// sets for the cluster, which would otherwise only fail or be overwritten once a node is launched
+func validateBottlerocketUserData(userData string) *apis.FieldError {
+	config := map[string]interface{}{}
+	if err := toml.Unmarshal([]byte(userData), &config); err != nil {
+		return apis.ErrGeneric(fmt.Sprintf("must be valid TOML with the %s AMIFamily, %s", AMIFamilyBottlerocket, err))
+	}
+	settings, _ := config["settings"].(map[string]interface{})
+	kubernetes, _ := settings["kubernetes"].(map[string]interface{})
+	var errs *apis.FieldError
+	for _, key := range BottlerocketClusterSettings {
+		if _, ok := kubernetes[key]; ok {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("settings.kubernetes.%s is set by Karpenter and can't be set in userData", key)))
+		}
+	}
 	return errs
 }
 
+func (in *NodeClassSpec) validateUserDataRef() (errs *apis.FieldError) {
+	if in.UserDataRef == nil {
+		return nil
+	}
+	if in.UserData != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(userDataPath, userDataRefPath))
+	}
+	if lo.FromPtr(in.AMIFamily) == AMIFamilyWindows2019 || lo.FromPtr(in.AMIFamily) == AMIFamilyWindows2022 {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("%s AMIFamily is not currently supported with custom userData", lo.FromPtr(in.AMIFamily)), userDataRefPath))
+	}
+	return errs.Also(in.UserDataRef.validate().ViaField(userDataRefPath))
+}
+
+func (in *NodeClassSpec) validateUserDataMergeOrder() (errs *apis.FieldError) {
+	if in.UserDataMergeOrder == nil {
+		return nil
+	}
+	if in.AMIFamily != nil && *in.AMIFamily != AMIFamilyAL2 {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("can only be set with the %s AMIFamily", AMIFamilyAL2), userDataMergeOrderPath))
+	}
+	return errs.Also(in.validateStringEnum(*in.UserDataMergeOrder, userDataMergeOrderPath, UserDataMergeOrders))
+}
+
+func (in *NodeClassSpec) validateUserDataCompression() (errs *apis.FieldError) {
+	if in.UserDataCompression == nil {
+		return nil
+	}
+	if in.AMIFamily != nil && *in.AMIFamily != AMIFamilyAL2 {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("can only be set with the %s AMIFamily", AMIFamilyAL2), userDataCompressionPath))
+	}
+	return errs.Also(in.validateStringEnum(*in.UserDataCompression, userDataCompressionPath, UserDataCompressions))
+}
+
+func (in *UserDataReference) validate() (errs *apis.FieldError) {
+	if !lo.Contains(UserDataRefKinds, in.Kind) {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s not in %v", in.Kind, strings.Join(UserDataRefKinds, ", ")), "kind"))
+	}
+	if in.Name == "" {
+		errs = errs.Also(apis.ErrMissingField("name"))
+	}
+	if in.Key == "" {
+		errs = errs.Also(apis.ErrMissingField("key"))
+	}
+	return errs
+}
+
+func (in *NodeClassSpec) validateSubnetPolicy() *apis.FieldError {
+	if in.SubnetPolicy == nil {
+		return nil
+	}
+	return in.validateStringEnum(*in.SubnetPolicy, subnetPolicyPath, SupportedSubnetPolicies)
+}
+
+func (in *NodeClassSpec) validateStartupTaints() (errs *apis.FieldError) {
+	if len(in.StartupTaints) == 0 {
+		return nil
+	}
+	if lo.FromPtr(in.AMIFamily) == AMIFamilyCustom {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("%s AMIFamily doesn't generate the bootstrap configuration that registers taints", AMIFamilyCustom)))
+	}
+	existing := map[string]struct{}{}
+	for i, taint := range in.StartupTaints {
+		for _, err := range validation.IsQualifiedName(taint.Key) {
+			errs = errs.Also(apis.ErrInvalidArrayValue(err, "key", i))
+		}
+		if taint.Value != "" {
+			for _, err := range validation.IsQualifiedName(taint.Value) {
+				errs = errs.Also(apis.ErrInvalidArrayValue(err, "value", i))
+			}
+		}
+		switch taint.Effect {
+		case v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute:
+		default:
+			errs = errs.Also(apis.ErrInvalidArrayValue(taint.Effect, "effect", i))
+		}
+		if _, ok := existing[taint.Key+string(taint.Effect)]; ok {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("duplicate taint Key/Effect pair %s=%s", taint.Key, taint.Effect)).ViaIndex(i))
+		}
+		existing[taint.Key+string(taint.Effect)] = struct{}{}
+	}
+	return errs
+}
+
+func (in *NodeClassSpec) validateDeletionPolicy() *apis.FieldError {
+	if in.DeletionPolicy == nil {
+		return nil
+	}
+	return in.validateStringEnum(*in.DeletionPolicy, deletionPolicyPath, SupportedDeletionPolicies)
+}
+
 func (in *NodeClassSpec) validateAMIFamily() (errs *apis.FieldError) {
 	if in.AMIFamily == nil {
 		return nil
@@ -283,3 +681,307 @@ func (in *NodeClassSpec) validateTags() (errs *apis.FieldError) {
 	}
 	return errs
 }
+
+func (in *NodeClassSpec) validateBottlerocket() (errs *apis.FieldError) {
+	if in.Bottlerocket == nil {
+		return nil
+	}
+	if lo.FromPtr(in.AMIFamily) != AMIFamilyBottlerocket {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("can only be set with the %s AMIFamily", AMIFamilyBottlerocket)))
+	}
+	return errs.Also(in.Bottlerocket.validate())
+}
+
+func (in *BottlerocketSettings) validate() (errs *apis.FieldError) {
+	for name := range in.HostContainers {
+		if name == "" {
+			errs = errs.Also(apis.ErrInvalidKeyName(`""`, "hostContainers"))
+		}
+	}
+	for name, container := range in.BootstrapContainers {
+		if name == "" {
+			errs = errs.Also(apis.ErrInvalidKeyName(`""`, "bootstrapContainers"))
+		}
+		if container.Mode != nil && !lo.Contains(BottlerocketBootstrapContainerModes, *container.Mode) {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s not in %v", *container.Mode, strings.Join(BottlerocketBootstrapContainerModes, ", ")), "mode").ViaKey(name).ViaField("bootstrapContainers"))
+		}
+	}
+	for key := range in.KernelSysctl {
+		if key == "" {
+			errs = errs.Also(apis.ErrInvalidKeyName(`""`, "kernelSysctl"))
+		}
+	}
+	mirrors := map[string]struct{}{}
+	for i, mirror := range in.RegistryMirrors {
+		if mirror.Registry == "" {
+			errs = errs.Also(apis.ErrMissingField("registry").ViaFieldIndex("registryMirrors", i))
+		}
+		if len(mirror.Endpoints) == 0 {
+			errs = errs.Also(apis.ErrMissingField("endpoints").ViaFieldIndex("registryMirrors", i))
+		}
+		if _, ok := mirrors[mirror.Registry]; ok {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("duplicate registry %s", mirror.Registry)).ViaFieldIndex("registryMirrors", i))
+		}
+		mirrors[mirror.Registry] = struct{}{}
+	}
+	return errs
+}
+
+func (in *NodeClassSpec) validateContainerRegistries() (errs *apis.FieldError) {
+	if len(in.ContainerRegistries) == 0 {
+		return nil
+	}
+	if lo.FromPtr(in.AMIFamily) != "" && lo.FromPtr(in.AMIFamily) != AMIFamilyAL2 {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("can only be set with the %s AMIFamily", AMIFamilyAL2)))
+	}
+	registries := map[string]struct{}{}
+	for i, registry := range in.ContainerRegistries {
+		errs = errs.Also(registry.validate().ViaIndex(i))
+		if _, ok := registries[registry.Registry]; ok {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("duplicate registry %s", registry.Registry)).ViaIndex(i))
+		}
+		registries[registry.Registry] = struct{}{}
+	}
+	return errs
+}
+
+func (in *ContainerRegistry) validate() (errs *apis.FieldError) {
+	if in.Registry == "" {
+		errs = errs.Also(apis.ErrMissingField("registry"))
+	} else if !registryRegex.MatchString(in.Registry) {
+		errs = errs.Also(apis.ErrInvalidValue(in.Registry, "registry", "must be a registry host, optionally with a port, without a scheme or path"))
+	}
+	if len(in.Mirrors) == 0 {
+		errs = errs.Also(apis.ErrMissingField("mirrors"))
+	}
+	for i, mirror := range in.Mirrors {
+		if u, err := url.Parse(mirror); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = errs.Also(apis.ErrInvalidArrayValue(mirror, "mirrors", i))
+		}
+	}
+	if in.Auth != nil {
+		if _, err := base64.StdEncoding.DecodeString(*in.Auth); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue("<redacted>", "auth", "must be base64 encoded"))
+		}
+	}
+	return errs
+}
+
+func (in *NodeClassSpec) validateKubelet() (errs *apis.FieldError) {
+	if in.Kubelet == nil {
+		return nil
+	}
+	if !lo.Contains([]string{"", AMIFamilyAL2, AMIFamilyBottlerocket}, lo.FromPtr(in.AMIFamily)) {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("can only be set with the %s or %s AMIFamily", AMIFamilyAL2, AMIFamilyBottlerocket)))
+	}
+	return errs.Also(in.Kubelet.validate())
+}
+
+func (in *NodeClassSpec) validateCPUCreditSpecification() (errs *apis.FieldError) {
+	if in.CPUCreditSpecification == nil {
+		return nil
+	}
+	return in.validateStringEnum(*in.CPUCreditSpecification, cpuCreditSpecificationPath, CPUCreditSpecifications)
+}
+
+func (in *NodeClassSpec) validateCPUOptions() (errs *apis.FieldError) {
+	if in.CPUOptions == nil || in.CPUOptions.AMDSEVSNP == nil {
+		return nil
+	}
+	return in.validateStringEnum(*in.CPUOptions.AMDSEVSNP, "amdSevSnp", AMDSEVSNPs)
+}
+
+func (in *NodeClassSpec) validateInstanceInitiatedShutdownBehavior() (errs *apis.FieldError) {
+	if in.InstanceInitiatedShutdownBehavior == nil {
+		return nil
+	}
+	return in.validateStringEnum(*in.InstanceInitiatedShutdownBehavior, shutdownBehaviorPath, InstanceInitiatedShutdownBehaviors)
+}
+
+func (in *NodeClassSpec) validateAssumeRoleARN() (errs *apis.FieldError) {
+	if in.AssumeRoleARN != nil && !roleARNRegex.MatchString(*in.AssumeRoleARN) {
+		return apis.ErrInvalidValue(*in.AssumeRoleARN, assumeRoleARNPath, "must be the ARN of an IAM role")
+	}
+	return nil
+}
+
+func (in *NodeClassSpec) validateMaxPodsPerInstanceType() (errs *apis.FieldError) {
+	for pattern, maxPods := range in.MaxPodsPerInstanceType {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = errs.Also(apis.ErrInvalidKeyName(pattern, apis.CurrentField, "must be a valid glob"))
+		}
+		if maxPods <= 0 {
+			errs = errs.Also(apis.ErrInvalidValue(maxPods, apis.CurrentField, "must be greater than 0").ViaKey(pattern))
+		}
+	}
+	return errs
+}
+
+func (in *NodeClassSpec) validateNVIDIA() (errs *apis.FieldError) {
+	if in.NVIDIA == nil {
+		return nil
+	}
+	if in.NVIDIA.Driver != nil {
+		if !lo.Contains(NVIDIADrivers, *in.NVIDIA.Driver) {
+			errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s not in %v", *in.NVIDIA.Driver, strings.Join(NVIDIADrivers, ", ")), "driver"))
+		}
+		if !lo.Contains([]string{"", AMIFamilyAL2, AMIFamilyBottlerocket}, lo.FromPtr(in.AMIFamily)) {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("can only be set with the %s or %s AMIFamily", AMIFamilyAL2, AMIFamilyBottlerocket), "driver"))
+		}
+		if len(in.AMISelectorTerms) > 0 {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("cannot be set with %s", amiSelectorTermsPath), "driver"))
+		}
+	}
+	if lo.FromPtr(in.NVIDIA.ContainerRuntime) && lo.FromPtr(in.AMIFamily) != AMIFamilyCustom {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("can only be enabled with the %s AMIFamily", AMIFamilyCustom), "containerRuntime"))
+	}
+	return errs
+}
+
+func (in *NodeClassSpec) validateWindows() (errs *apis.FieldError) {
+	if in.Windows == nil {
+		return nil
+	}
+	if !lo.Contains([]string{AMIFamilyWindows2019, AMIFamilyWindows2022}, lo.FromPtr(in.AMIFamily)) {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("can only be set with the %s or %s AMIFamily", AMIFamilyWindows2019, AMIFamilyWindows2022)))
+	}
+	if in.Windows.Variant != nil && len(in.AMISelectorTerms) > 0 {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("cannot be set with %s", amiSelectorTermsPath), "variant"))
+	}
+	return errs.Also(in.Windows.validate())
+}
+
+func (in *NodeClassSpec) validateInstanceRequirements() *apis.FieldError {
+	if in.InstanceRequirements == nil {
+		return nil
+	}
+	return in.InstanceRequirements.validate()
+}
+
+// validateRequireEncryptionInTransit rejects instance requirements alongside requireEncryptionInTransit, since EC2
+// can't select instance types by their support for encryption in transit
+func (in *NodeClassSpec) validateRequireEncryptionInTransit() (errs *apis.FieldError) {
+	if lo.FromPtr(in.RequireEncryptionInTransit) && in.InstanceRequirements != nil {
+		return errs.Also(apis.ErrMultipleOneOf(encryptionInTransitPath, instanceRequirementsPath))
+	}
+	return nil
+}
+
+func (in *NodeClassSpec) validateAMIMaxAge() (errs *apis.FieldError) {
+	if in.AMIMaxAge != nil && in.AMIMaxAge.Duration <= 0 {
+		return errs.Also(apis.ErrInvalidValue(in.AMIMaxAge.Duration.String(), amiMaxAgePath, "must be positive"))
+	}
+	return nil
+}
+
+func (in *NodeClassSpec) validateRegistrationTTL() (errs *apis.FieldError) {
+	if in.RegistrationTTL != nil && (in.RegistrationTTL.Duration <= 0 || in.RegistrationTTL.Duration > maxRegistrationTTL) {
+		return errs.Also(apis.ErrOutOfBoundsValue(in.RegistrationTTL.Duration.String(), "0s", maxRegistrationTTL.String(), registrationTTLPath))
+	}
+	return nil
+}
+
+func (in *InstanceRequirements) validate() (errs *apis.FieldError) {
+	errs = errs.Also(in.VCPU.validate().ViaField("vcpu"), in.MemoryMiB.validate().ViaField("memoryMiB"), in.AcceleratorCount.validate().ViaField("acceleratorCount"))
+	for i, acceleratorType := range in.AcceleratorTypes {
+		if !lo.Contains(AcceleratorTypes, acceleratorType) {
+			errs = errs.Also(apis.ErrInvalidArrayValue(fmt.Sprintf("%s not in %v", acceleratorType, strings.Join(AcceleratorTypes, ", ")), "acceleratorTypes", i))
+		}
+	}
+	for i, manufacturer := range in.AcceleratorManufacturers {
+		if !lo.Contains(AcceleratorManufacturers, manufacturer) {
+			errs = errs.Also(apis.ErrInvalidArrayValue(fmt.Sprintf("%s not in %v", manufacturer, strings.Join(AcceleratorManufacturers, ", ")), "acceleratorManufacturers", i))
+		}
+	}
+	if in.AcceleratorCount != nil && in.AcceleratorCount.Max != nil && *in.AcceleratorCount.Max == 0 && (len(in.AcceleratorTypes) > 0 || len(in.AcceleratorManufacturers) > 0) {
+		errs = errs.Also(apis.ErrGeneric("acceleratorTypes and acceleratorManufacturers can't be set when acceleratorCount excludes accelerators"))
+	}
+	for i, instanceType := range in.ExcludedInstanceTypes {
+		if _, err := path.Match(instanceType, ""); err != nil || !excludedInstanceTypeRegex.MatchString(instanceType) {
+			errs = errs.Also(apis.ErrInvalidArrayValue(instanceType, "excludedInstanceTypes", i))
+		}
+	}
+	return errs
+}
+
+func (in *InstanceRequirementsRange) validate() *apis.FieldError {
+	if in != nil && in.Min != nil && in.Max != nil && *in.Min > *in.Max {
+		return apis.ErrGeneric(fmt.Sprintf("min %d is greater than max %d", *in.Min, *in.Max))
+	}
+	return nil
+}
+
+func (in *WindowsConfiguration) validate() (errs *apis.FieldError) {
+	if in.Variant != nil && !lo.Contains(WindowsVariants, *in.Variant) {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s not in %v", *in.Variant, strings.Join(WindowsVariants, ", ")), "variant"))
+	}
+	if in.CSIProxy != nil && in.CSIProxy.BinaryURL != nil {
+		if u, err := url.Parse(*in.CSIProxy.BinaryURL); err != nil || u.Scheme != "https" || u.Host == "" || strings.ContainsAny(*in.CSIProxy.BinaryURL, "'\"`") {
+			errs = errs.Also(apis.ErrInvalidValue(*in.CSIProxy.BinaryURL, "binaryURL", "must be an https URL").ViaField("csiProxy"))
+		}
+	}
+	if in.GMSA != nil && !clsidRegex.MatchString(in.GMSA.PluginCLSID) {
+		errs = errs.Also(apis.ErrInvalidValue(in.GMSA.PluginCLSID, "pluginCLSID", "must be a COM class ID, e.g. 01234567-89ab-cdef-0123-456789abcdef").ViaField("gmsa"))
+	}
+	return errs
+}
+
+func (in *KubeletConfiguration) validate() (errs *apis.FieldError) {
+	if in.ShutdownGracePeriod != nil && in.ShutdownGracePeriod.Duration < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(in.ShutdownGracePeriod.Duration.String(), "shutdownGracePeriod", "must not be negative"))
+	}
+	if in.ShutdownGracePeriodCriticalPods != nil {
+		if in.ShutdownGracePeriod == nil {
+			errs = errs.Also(apis.ErrGeneric("shutdownGracePeriod must be set with shutdownGracePeriodCriticalPods", "shutdownGracePeriodCriticalPods"))
+		} else if in.ShutdownGracePeriodCriticalPods.Duration < 0 || in.ShutdownGracePeriodCriticalPods.Duration > in.ShutdownGracePeriod.Duration {
+			errs = errs.Also(apis.ErrOutOfBoundsValue(in.ShutdownGracePeriodCriticalPods.Duration.String(), "0s", in.ShutdownGracePeriod.Duration.String(), "shutdownGracePeriodCriticalPods"))
+		}
+	}
+	if in.RegistryPullQPS != nil && *in.RegistryPullQPS < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(*in.RegistryPullQPS, "registryPullQPS", "must not be negative"))
+	}
+	if in.RegistryBurst != nil && *in.RegistryBurst < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(*in.RegistryBurst, "registryBurst", "must not be negative"))
+	}
+	if in.CPUManagerPolicy != nil && !lo.Contains(CPUManagerPolicies, *in.CPUManagerPolicy) {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s not in %v", *in.CPUManagerPolicy, strings.Join(CPUManagerPolicies, ", ")), "cpuManagerPolicy"))
+	}
+	if in.TopologyManagerPolicy != nil && !lo.Contains(TopologyManagerPolicies, *in.TopologyManagerPolicy) {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s not in %v", *in.TopologyManagerPolicy, strings.Join(TopologyManagerPolicies, ", ")), "topologyManagerPolicy"))
+	}
+	if in.MemoryManagerPolicy != nil && !lo.Contains(MemoryManagerPolicies, *in.MemoryManagerPolicy) {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s not in %v", *in.MemoryManagerPolicy, strings.Join(MemoryManagerPolicies, ", ")), "memoryManagerPolicy"))
+	}
+	return errs
+}
+
+// ValidateAMIFamilyRequirements returns an error if the requirements can never be satisfied by the AMIs of the
+// AMIFamily, e.g. arm64 with a Windows AMIFamily or a Windows build with a Linux AMIFamily. The AMIs of the Custom
+// AMIFamily may be of any OS and architecture, so its requirements are never rejected.
+func ValidateAMIFamilyRequirements(amiFamily string, requirements scheduling.Requirements) error {
+	switch amiFamily {
+	case AMIFamilyCustom:
+		return nil
+	case AMIFamilyWindows2019, AMIFamilyWindows2022:
+		if !requirements.Get(v1.LabelOSStable).Has(string(v1.Windows)) {
+			return fmt.Errorf("the %s AMIFamily only supports the %s OS, but %s is %s", amiFamily, v1.Windows, v1.LabelOSStable, requirements.Get(v1.LabelOSStable))
+		}
+		if !requirements.Get(v1.LabelArchStable).Has(corev1beta1.ArchitectureAmd64) {
+			return fmt.Errorf("the %s AMIFamily only supports the %s architecture, but %s is %s", amiFamily, corev1beta1.ArchitectureAmd64, v1.LabelArchStable, requirements.Get(v1.LabelArchStable))
+		}
+		build := lo.Ternary(amiFamily == AMIFamilyWindows2019, Windows2019Build, Windows2022Build)
+		if !requirements.Get(v1.LabelWindowsBuild).Has(build) {
+			return fmt.Errorf("the %s AMIFamily only supports the %s Windows build, but %s is %s", amiFamily, build, v1.LabelWindowsBuild, requirements.Get(v1.LabelWindowsBuild))
+		}
+	default:
+		if !requirements.Get(v1.LabelOSStable).Has(string(v1.Linux)) {
+			return fmt.Errorf("the %s AMIFamily only supports the %s OS, but %s is %s", amiFamily, v1.Linux, v1.LabelOSStable, requirements.Get(v1.LabelOSStable))
+		}
+		// Linux nodes don't have a Windows build, so any requirement that the label exists can't be satisfied
+		if operator := requirements.Get(v1.LabelWindowsBuild).Operator(); requirements.Has(v1.LabelWindowsBuild) &&
+			(operator == v1.NodeSelectorOpIn || operator == v1.NodeSelectorOpExists) {
+			return fmt.Errorf("the %s AMIFamily doesn't support Windows builds, but %s is %s", amiFamily, v1.LabelWindowsBuild, requirements.Get(v1.LabelWindowsBuild))
+		}
+	}
+	return nil
+}
@@ -28,6 +28,8 @@ var (
 		scheme.AddKnownTypes(SchemeGroupVersion,
 			&NodeClass{},
 			&NodeClassList{},
+			&AWSConfiguration{},
+			&AWSConfigurationList{},
 		)
 		metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 		return nil
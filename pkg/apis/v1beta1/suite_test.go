@@ -16,8 +16,10 @@ package v1beta1_test
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Pallinder/go-randomdata"
 	. "github.com/onsi/ginkgo/v2"
@@ -28,6 +30,10 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/apis/v1beta1"
@@ -71,6 +77,20 @@ var _ = Describe("Validation", func() {
 		It("should succeed if user data is empty", func() {
 			Expect(nc.Validate(ctx)).To(Succeed())
 		})
+		It("should succeed with a valid template if userDataTemplating is enabled", func() {
+			nc.Spec.UserData = ptr.String("#!/bin/bash\necho '{{ .ClusterName }} {{ .NodePool }}'")
+			nc.Spec.UserDataTemplating = ptr.Bool(true)
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an invalid template if userDataTemplating is enabled", func() {
+			nc.Spec.UserData = ptr.String("#!/bin/bash\necho '{{ .ClusterName'")
+			nc.Spec.UserDataTemplating = ptr.Bool(true)
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should succeed with an invalid template if userDataTemplating isn't enabled", func() {
+			nc.Spec.UserData = ptr.String("#!/bin/bash\necho '{{ .ClusterName'")
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
 		It("should fail if Windows2019 AMIFamily is specified", func() {
 			nc.Spec.AMIFamily = &v1alpha1.AMIFamilyWindows2019
 			nc.Spec.UserData = ptr.String("someUserData")
@@ -81,6 +101,154 @@ var _ = Describe("Validation", func() {
 			nc.Spec.UserData = ptr.String("someUserData")
 			Expect(nc.Validate(ctx)).To(Not(Succeed()))
 		})
+		It("should succeed with valid TOML with the Bottlerocket AMIFamily", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyBottlerocket
+			nc.Spec.UserData = ptr.String("[settings.kubernetes]\nmax-pods = 110\n")
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with invalid TOML with the Bottlerocket AMIFamily", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyBottlerocket
+			nc.Spec.UserData = ptr.String("#!/bin/bash\necho 'hello'")
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if a cluster setting is set with the Bottlerocket AMIFamily", func() {
+			for _, key := range v1beta1.BottlerocketClusterSettings {
+				nc.Spec.AMIFamily = &v1beta1.AMIFamilyBottlerocket
+				nc.Spec.UserData = ptr.String(fmt.Sprintf("[settings.kubernetes]\n%s = \"value\"\n", key))
+				Expect(nc.Validate(ctx)).To(Not(Succeed()))
+			}
+		})
+		It("should succeed with templated userData with the Bottlerocket AMIFamily", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyBottlerocket
+			nc.Spec.UserData = ptr.String("[settings.kubernetes.node-labels]\nnodepool = \"{{ .NodePool }}\"\n")
+			nc.Spec.UserDataTemplating = ptr.Bool(true)
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with valid MIME multi-part userData", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyAL2
+			nc.Spec.UserData = ptr.String("MIME-Version: 1.0\nContent-Type: multipart/mixed; boundary=\"BOUNDARY\"\n\n--BOUNDARY\nContent-Type: text/x-shellscript; charset=\"us-ascii\"\n\n#!/bin/bash\necho 'hello'\n--BOUNDARY--\n")
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with MIME userData that isn't multi-part", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyAL2
+			nc.Spec.UserData = ptr.String("MIME-Version: 1.0\nContent-Type: text/x-shellscript\n\n#!/bin/bash\necho 'hello'\n")
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with MIME userData without a boundary", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyAL2
+			nc.Spec.UserData = ptr.String("MIME-Version: 1.0\nContent-Type: multipart/mixed\n\n--BOUNDARY\n#!/bin/bash\necho 'hello'\n--BOUNDARY--\n")
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if the userData is larger than EC2 allows once base64 encoded", func() {
+			nc.Spec.UserData = ptr.String("#!/bin/bash\n" + strings.Repeat("#", v1beta1.UserDataMaxSize))
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should succeed if the userData is larger than EC2 allows with Gzip compression", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyAL2
+			nc.Spec.UserData = ptr.String("#!/bin/bash\n" + strings.Repeat("#", v1beta1.UserDataMaxSize))
+			nc.Spec.UserDataCompression = ptr.String(v1beta1.UserDataCompressionGzip)
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+	})
+	Context("UserDataRef", func() {
+		It("should succeed with a ConfigMap reference", func() {
+			nc.Spec.UserDataRef = &v1beta1.UserDataReference{Kind: v1beta1.UserDataRefKindConfigMap, Name: "userdata", Key: "bootstrap.sh"}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with a Secret reference", func() {
+			nc.Spec.UserDataRef = &v1beta1.UserDataReference{Kind: v1beta1.UserDataRefKindSecret, Name: "userdata", Key: "bootstrap.sh"}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an unsupported kind", func() {
+			nc.Spec.UserDataRef = &v1beta1.UserDataReference{Kind: "Pod", Name: "userdata", Key: "bootstrap.sh"}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail without a name or key", func() {
+			nc.Spec.UserDataRef = &v1beta1.UserDataReference{Kind: v1beta1.UserDataRefKindSecret}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if userData is also specified", func() {
+			nc.Spec.UserData = ptr.String("someUserData")
+			nc.Spec.UserDataRef = &v1beta1.UserDataReference{Kind: v1beta1.UserDataRefKindSecret, Name: "userdata", Key: "bootstrap.sh"}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if a Windows AMIFamily is specified", func() {
+			nc.Spec.AMIFamily = &v1alpha1.AMIFamilyWindows2022
+			nc.Spec.UserDataRef = &v1beta1.UserDataReference{Kind: v1beta1.UserDataRefKindSecret, Name: "userdata", Key: "bootstrap.sh"}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("UserDataCompression", func() {
+		It("should succeed with the AL2 AMIFamily", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyAL2
+			nc.Spec.UserDataCompression = ptr.String(v1beta1.UserDataCompressionGzip)
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an unsupported compression", func() {
+			nc.Spec.UserDataCompression = ptr.String("Zstd")
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with an AMIFamily other than AL2", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyBottlerocket
+			nc.Spec.UserDataCompression = ptr.String(v1beta1.UserDataCompressionGzip)
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("UserDataMergeOrder", func() {
+		It("should succeed with the AL2 AMIFamily", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyAL2
+			nc.Spec.UserDataMergeOrder = ptr.String(v1beta1.UserDataMergeOrderPostBootstrap)
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed without an AMIFamily", func() {
+			nc.Spec.UserDataMergeOrder = ptr.String(v1beta1.UserDataMergeOrderPreBootstrap)
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an unsupported order", func() {
+			nc.Spec.UserDataMergeOrder = ptr.String("DuringBootstrap")
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with an AMIFamily other than AL2", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyBottlerocket
+			nc.Spec.UserDataMergeOrder = ptr.String(v1beta1.UserDataMergeOrderPostBootstrap)
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("CPUCreditSpecification", func() {
+		It("should succeed with a supported credit specification", func() {
+			for _, credits := range v1beta1.CPUCreditSpecifications {
+				nc.Spec.CPUCreditSpecification = ptr.String(credits)
+				Expect(nc.Validate(ctx)).To(Succeed())
+			}
+		})
+		It("should fail with an unsupported credit specification", func() {
+			nc.Spec.CPUCreditSpecification = ptr.String("burst")
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("InstanceInitiatedShutdownBehavior", func() {
+		It("should succeed with a supported shutdown behavior", func() {
+			for _, behavior := range v1beta1.InstanceInitiatedShutdownBehaviors {
+				nc.Spec.InstanceInitiatedShutdownBehavior = ptr.String(behavior)
+				Expect(nc.Validate(ctx)).To(Succeed())
+			}
+		})
+		It("should fail with an unsupported shutdown behavior", func() {
+			nc.Spec.InstanceInitiatedShutdownBehavior = ptr.String("hibernate")
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("CPUOptions", func() {
+		It("should succeed with a supported AMD SEV-SNP option", func() {
+			for _, amdSevSnp := range v1beta1.AMDSEVSNPs {
+				nc.Spec.CPUOptions = &v1beta1.CPUOptions{AMDSEVSNP: ptr.String(amdSevSnp)}
+				Expect(nc.Validate(ctx)).To(Succeed())
+			}
+		})
+		It("should fail with an unsupported AMD SEV-SNP option", func() {
+			nc.Spec.CPUOptions = &v1beta1.CPUOptions{AMDSEVSNP: ptr.String("on")}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
 	})
 	Context("Tags", func() {
 		It("should succeed when tags are empty", func() {
@@ -122,6 +290,105 @@ var _ = Describe("Validation", func() {
 				"karpenter.sh/managed-by": "test",
 			}
 			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+			nc.Spec.Tags = map[string]string{
+				v1beta1.EKSClusterNameTagKey: "test",
+			}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should succeed with templated tags", func() {
+			nc.Spec.Tags = map[string]string{"team": `{{ index .Labels "team" }}`, "zone": "{{ .Zone }}"}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with a tag value that can't be parsed as a template", func() {
+			nc.Spec.Tags = map[string]string{"team": "{{ .Labels", "literal": "{{"}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+	})
+	Context("BlockDeviceMappings", func() {
+		var ebs *v1beta1.BlockDevice
+
+		BeforeEach(func() {
+			ebs = &v1beta1.BlockDevice{VolumeSize: lo.ToPtr(resource.MustParse("100Gi"))}
+			nc.Spec.BlockDeviceMappings = []*v1beta1.BlockDeviceMapping{{DeviceName: aws.String("/dev/xvda"), EBS: ebs}}
+		})
+		It("should succeed with gp3 IOPS and throughput within the limits for the volume size", func() {
+			ebs.VolumeType = aws.String(ec2.VolumeTypeGp3)
+			ebs.IOPS = aws.Int64(16000)
+			ebs.Throughput = aws.Int64(1000)
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with baseline gp3 IOPS on a small volume", func() {
+			ebs.VolumeType = aws.String(ec2.VolumeTypeGp3)
+			ebs.VolumeSize = lo.ToPtr(resource.MustParse("1Gi"))
+			ebs.IOPS = aws.Int64(3000)
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail when gp3 IOPS exceed 500 per GiB", func() {
+			ebs.VolumeType = aws.String(ec2.VolumeTypeGp3)
+			ebs.VolumeSize = lo.ToPtr(resource.MustParse("8Gi"))
+			ebs.IOPS = aws.Int64(4001)
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail when gp3 IOPS are out of bounds", func() {
+			ebs.VolumeType = aws.String(ec2.VolumeTypeGp3)
+			ebs.IOPS = aws.Int64(2999)
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+			ebs.IOPS = aws.Int64(16001)
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail when gp3 throughput exceeds 0.25 MiB/s per IOPS", func() {
+			ebs.VolumeType = aws.String(ec2.VolumeTypeGp3)
+			ebs.Throughput = aws.Int64(751)
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+			ebs.IOPS = aws.Int64(4000)
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail when throughput is set on a volume type other than gp3", func() {
+			ebs.VolumeType = aws.String(ec2.VolumeTypeGp2)
+			ebs.Throughput = aws.Int64(125)
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail when IOPS are set on a volume type that doesn't support provisioned IOPS", func() {
+			ebs.VolumeType = aws.String(ec2.VolumeTypeSt1)
+			ebs.VolumeSize = lo.ToPtr(resource.MustParse("500Gi"))
+			ebs.IOPS = aws.Int64(3000)
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail when io1 and io2 volumes don't specify IOPS", func() {
+			ebs.VolumeType = aws.String(ec2.VolumeTypeIo2)
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+			ebs.VolumeType = aws.String(ec2.VolumeTypeIo1)
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should enforce the IOPS to size ratio of io1 and io2 volumes", func() {
+			ebs.VolumeType = aws.String(ec2.VolumeTypeIo1)
+			ebs.IOPS = aws.Int64(5000)
+			Expect(nc.Validate(ctx)).To(Succeed())
+			ebs.IOPS = aws.Int64(5001)
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+			ebs.VolumeType = aws.String(ec2.VolumeTypeIo2)
+			ebs.IOPS = aws.Int64(50000)
+			Expect(nc.Validate(ctx)).To(Succeed())
+			ebs.IOPS = aws.Int64(64001)
+			ebs.VolumeSize = lo.ToPtr(resource.MustParse("1Ti"))
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should enforce the volume size limits of the volume type", func() {
+			ebs.VolumeType = aws.String(ec2.VolumeTypeSt1)
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+			ebs.VolumeType = aws.String(ec2.VolumeTypeStandard)
+			ebs.VolumeSize = lo.ToPtr(resource.MustParse("2Ti"))
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+			ebs.VolumeType = aws.String(ec2.VolumeTypeGp3)
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail when there are more block device mappings than can be attached", func() {
+			nc.Spec.BlockDeviceMappings = lo.Times(28, func(i int) *v1beta1.BlockDeviceMapping {
+				return &v1beta1.BlockDeviceMapping{DeviceName: aws.String(fmt.Sprintf("/dev/xvd%d", i)), EBS: &v1beta1.BlockDevice{VolumeSize: lo.ToPtr(resource.MustParse("20Gi"))}}
+			})
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+			nc.Spec.BlockDeviceMappings = nc.Spec.BlockDeviceMappings[:27]
+			Expect(nc.Validate(ctx)).To(Succeed())
 		})
 	})
 	Context("SubnetSelectorTerms", func() {
@@ -143,6 +410,31 @@ var _ = Describe("Validation", func() {
 			}
 			Expect(nc.Validate(ctx)).To(Succeed())
 		})
+		It("should succeed with a valid subnet selector on zone, zone ID, CIDR and owner account ID", func() {
+			nc.Spec.SubnetSelectorTerms = []v1beta1.SubnetSelectorTerm{
+				{
+					Zone:           "us-east-1a",
+					ZoneID:         "use1-az1",
+					CIDR:           "10.0.0.0/16",
+					OwnerAccountID: "123456789012",
+				},
+			}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail when a subnet selector term has an invalid CIDR", func() {
+			for _, cidr := range []string{"10.0.0.0", "10.0.0.0/33", "2001:db8::/32"} {
+				nc.Spec.SubnetSelectorTerms = []v1beta1.SubnetSelectorTerm{{CIDR: cidr}}
+				Expect(nc.Validate(ctx)).ToNot(Succeed())
+			}
+		})
+		It("should fail when a subnet selector term has an invalid owner account ID", func() {
+			nc.Spec.SubnetSelectorTerms = []v1beta1.SubnetSelectorTerm{{OwnerAccountID: "self"}}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail when a subnet selector term has an id and a zone", func() {
+			nc.Spec.SubnetSelectorTerms = []v1beta1.SubnetSelectorTerm{{ID: "subnet-12345749", Zone: "us-east-1a"}}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
 		It("should fail when subnet selector terms is set to nil", func() {
 			nc.Spec.SubnetSelectorTerms = nil
 			Expect(nc.Validate(ctx)).ToNot(Succeed())
@@ -222,6 +514,439 @@ var _ = Describe("Validation", func() {
 			Expect(nc.Validate(ctx)).ToNot(Succeed())
 		})
 	})
+	Context("EphemeralStorageSizing", func() {
+		It("should succeed with a valid sizing", func() {
+			nc.Spec.EphemeralStorageSizing = &v1beta1.EphemeralStorageSizing{
+				MinSize:  lo.ToPtr(resource.MustParse("20Gi")),
+				MaxSize:  resource.MustParse("500Gi"),
+				Overhead: lo.ToPtr(resource.MustParse("10Gi")),
+			}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail when the max size is missing", func() {
+			nc.Spec.EphemeralStorageSizing = &v1beta1.EphemeralStorageSizing{}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail when the min size is larger than the max size", func() {
+			nc.Spec.EphemeralStorageSizing = &v1beta1.EphemeralStorageSizing{
+				MinSize: lo.ToPtr(resource.MustParse("100Gi")),
+				MaxSize: resource.MustParse("50Gi"),
+			}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail when the overhead is negative", func() {
+			nc.Spec.EphemeralStorageSizing = &v1beta1.EphemeralStorageSizing{
+				MaxSize:  resource.MustParse("50Gi"),
+				Overhead: lo.ToPtr(resource.MustParse("-1Gi")),
+			}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail for the Custom AMIFamily", func() {
+			nc.Spec.AMIFamily = aws.String(v1beta1.AMIFamilyCustom)
+			nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{ID: "ami-12345"}}
+			nc.Spec.EphemeralStorageSizing = &v1beta1.EphemeralStorageSizing{MaxSize: resource.MustParse("50Gi")}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("SubnetPolicy", func() {
+		It("should succeed when the subnet policy is any or privateOnly", func() {
+			for _, policy := range v1beta1.SupportedSubnetPolicies {
+				nc.Spec.SubnetPolicy = aws.String(policy)
+				Expect(nc.Validate(ctx)).To(Succeed())
+			}
+		})
+		It("should fail when the subnet policy is unknown", func() {
+			nc.Spec.SubnetPolicy = aws.String("publicOnly")
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("StartupTaints", func() {
+		It("should succeed with valid startup taints", func() {
+			nc.Spec.StartupTaints = []v1.Taint{
+				{Key: "node.cilium.io/agent-not-ready", Value: "true", Effect: v1.TaintEffectNoExecute},
+				{Key: "ebs.csi.aws.com/agent-not-ready", Effect: v1.TaintEffectNoSchedule},
+			}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an invalid key, value or effect", func() {
+			nc.Spec.StartupTaints = []v1.Taint{{Key: "???", Effect: v1.TaintEffectNoSchedule}}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+			nc.Spec.StartupTaints = []v1.Taint{{Key: "example.com/taint", Value: "???", Effect: v1.TaintEffectNoSchedule}}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+			nc.Spec.StartupTaints = []v1.Taint{{Key: "example.com/taint", Effect: "NoRun"}}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with duplicate startup taints", func() {
+			nc.Spec.StartupTaints = []v1.Taint{
+				{Key: "example.com/taint", Value: "a", Effect: v1.TaintEffectNoSchedule},
+				{Key: "example.com/taint", Value: "b", Effect: v1.TaintEffectNoSchedule},
+			}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with the Custom AMIFamily", func() {
+			nc.Spec.AMIFamily = aws.String(v1beta1.AMIFamilyCustom)
+			nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{ID: "ami-12345"}}
+			nc.Spec.StartupTaints = []v1.Taint{{Key: "example.com/taint", Effect: v1.TaintEffectNoSchedule}}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("Bottlerocket", func() {
+		BeforeEach(func() {
+			nc.Spec.AMIFamily = aws.String(v1beta1.AMIFamilyBottlerocket)
+		})
+		It("should succeed with valid Bottlerocket settings", func() {
+			nc.Spec.Bottlerocket = &v1beta1.BottlerocketSettings{
+				HostContainers:      map[string]v1beta1.BottlerocketHostContainer{"admin": {Enabled: aws.Bool(true)}},
+				BootstrapContainers: map[string]v1beta1.BottlerocketBootstrapContainer{"setup": {Source: aws.String("example.com/setup:latest"), Mode: aws.String(v1beta1.BottlerocketBootstrapContainerModeOnce)}},
+				KernelSysctl:        map[string]string{"net.core.somaxconn": "1024"},
+				RegistryMirrors:     []v1beta1.BottlerocketRegistryMirror{{Registry: "docker.io", Endpoints: []string{"https://mirror.example.com"}}},
+			}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an AMIFamily other than Bottlerocket", func() {
+			nc.Spec.AMIFamily = aws.String(v1beta1.AMIFamilyAL2)
+			nc.Spec.Bottlerocket = &v1beta1.BottlerocketSettings{KernelSysctl: map[string]string{"net.core.somaxconn": "1024"}}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with an unknown bootstrap container mode", func() {
+			nc.Spec.Bottlerocket = &v1beta1.BottlerocketSettings{
+				BootstrapContainers: map[string]v1beta1.BottlerocketBootstrapContainer{"setup": {Mode: aws.String("sometimes")}},
+			}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with a registry mirror without endpoints", func() {
+			nc.Spec.Bottlerocket = &v1beta1.BottlerocketSettings{
+				RegistryMirrors: []v1beta1.BottlerocketRegistryMirror{{Registry: "docker.io"}},
+			}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with duplicate registries", func() {
+			nc.Spec.Bottlerocket = &v1beta1.BottlerocketSettings{
+				RegistryMirrors: []v1beta1.BottlerocketRegistryMirror{
+					{Registry: "docker.io", Endpoints: []string{"https://a.example.com"}},
+					{Registry: "docker.io", Endpoints: []string{"https://b.example.com"}},
+				},
+			}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("ContainerRegistries", func() {
+		It("should succeed with valid container registries", func() {
+			nc.Spec.ContainerRegistries = []v1beta1.ContainerRegistry{
+				{Registry: "docker.io", Mirrors: []string{"https://mirror.example.com"}},
+				{Registry: "registry.example.com:5000", Mirrors: []string{"http://cache.example.com:8080/v2"}, Auth: aws.String("dXNlcjpwYXNz")},
+				{Registry: "_default", Mirrors: []string{"https://mirror.example.com"}},
+			}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an AMIFamily other than AL2", func() {
+			nc.Spec.AMIFamily = aws.String(v1beta1.AMIFamilyBottlerocket)
+			nc.Spec.ContainerRegistries = []v1beta1.ContainerRegistry{{Registry: "docker.io", Mirrors: []string{"https://mirror.example.com"}}}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with an invalid registry", func() {
+			for _, registry := range []string{"", "https://docker.io", "docker.io/library", "docker.io'"} {
+				nc.Spec.ContainerRegistries = []v1beta1.ContainerRegistry{{Registry: registry, Mirrors: []string{"https://mirror.example.com"}}}
+				Expect(nc.Validate(ctx)).ToNot(Succeed())
+			}
+		})
+		It("should fail with missing or invalid mirrors", func() {
+			for _, mirrors := range [][]string{nil, {"mirror.example.com"}, {"ftp://mirror.example.com"}, {"https://"}} {
+				nc.Spec.ContainerRegistries = []v1beta1.ContainerRegistry{{Registry: "docker.io", Mirrors: mirrors}}
+				Expect(nc.Validate(ctx)).ToNot(Succeed())
+			}
+		})
+		It("should fail when auth isn't base64 encoded", func() {
+			nc.Spec.ContainerRegistries = []v1beta1.ContainerRegistry{{Registry: "docker.io", Mirrors: []string{"https://mirror.example.com"}, Auth: aws.String("user:pass")}}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with duplicate registries", func() {
+			nc.Spec.ContainerRegistries = []v1beta1.ContainerRegistry{
+				{Registry: "docker.io", Mirrors: []string{"https://a.example.com"}},
+				{Registry: "docker.io", Mirrors: []string{"https://b.example.com"}},
+			}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("Kubelet", func() {
+		It("should succeed with a valid kubelet configuration", func() {
+			nc.Spec.Kubelet = &v1beta1.KubeletConfiguration{
+				ShutdownGracePeriod:             &metav1.Duration{Duration: time.Minute * 2},
+				ShutdownGracePeriodCriticalPods: &metav1.Duration{Duration: time.Second * 30},
+				RegistryPullQPS:                 aws.Int32(0),
+				RegistryBurst:                   aws.Int32(20),
+			}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with the Bottlerocket AMIFamily", func() {
+			nc.Spec.AMIFamily = aws.String(v1beta1.AMIFamilyBottlerocket)
+			nc.Spec.Kubelet = &v1beta1.KubeletConfiguration{ShutdownGracePeriod: &metav1.Duration{Duration: time.Minute}}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an AMIFamily other than AL2 or Bottlerocket", func() {
+			nc.Spec.AMIFamily = aws.String(v1beta1.AMIFamilyUbuntu)
+			nc.Spec.Kubelet = &v1beta1.KubeletConfiguration{ShutdownGracePeriod: &metav1.Duration{Duration: time.Minute}}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with a negative shutdownGracePeriod", func() {
+			nc.Spec.Kubelet = &v1beta1.KubeletConfiguration{ShutdownGracePeriod: &metav1.Duration{Duration: -time.Minute}}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail when shutdownGracePeriodCriticalPods is set without shutdownGracePeriod", func() {
+			nc.Spec.Kubelet = &v1beta1.KubeletConfiguration{ShutdownGracePeriodCriticalPods: &metav1.Duration{Duration: time.Second * 30}}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail when shutdownGracePeriodCriticalPods is longer than shutdownGracePeriod", func() {
+			nc.Spec.Kubelet = &v1beta1.KubeletConfiguration{
+				ShutdownGracePeriod:             &metav1.Duration{Duration: time.Minute},
+				ShutdownGracePeriodCriticalPods: &metav1.Duration{Duration: time.Minute * 2},
+			}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with a negative registryPullQPS or registryBurst", func() {
+			nc.Spec.Kubelet = &v1beta1.KubeletConfiguration{RegistryPullQPS: aws.Int32(-1)}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+			nc.Spec.Kubelet = &v1beta1.KubeletConfiguration{RegistryBurst: aws.Int32(-1)}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should succeed with supported CPU, topology and memory manager policies", func() {
+			for _, policy := range v1beta1.CPUManagerPolicies {
+				nc.Spec.Kubelet = &v1beta1.KubeletConfiguration{CPUManagerPolicy: aws.String(policy)}
+				Expect(nc.Validate(ctx)).To(Succeed())
+			}
+			for _, policy := range v1beta1.TopologyManagerPolicies {
+				nc.Spec.Kubelet = &v1beta1.KubeletConfiguration{TopologyManagerPolicy: aws.String(policy)}
+				Expect(nc.Validate(ctx)).To(Succeed())
+			}
+			for _, policy := range v1beta1.MemoryManagerPolicies {
+				nc.Spec.Kubelet = &v1beta1.KubeletConfiguration{MemoryManagerPolicy: aws.String(policy)}
+				Expect(nc.Validate(ctx)).To(Succeed())
+			}
+		})
+		It("should fail with unknown CPU, topology or memory manager policies", func() {
+			nc.Spec.Kubelet = &v1beta1.KubeletConfiguration{CPUManagerPolicy: aws.String("Static")}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+			nc.Spec.Kubelet = &v1beta1.KubeletConfiguration{TopologyManagerPolicy: aws.String("prefer-closest-numa-nodes")}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+			nc.Spec.Kubelet = &v1beta1.KubeletConfiguration{MemoryManagerPolicy: aws.String("static")}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("MaxPodsPerInstanceType", func() {
+		It("should succeed with globs of instance type names", func() {
+			nc.Spec.MaxPodsPerInstanceType = map[string]int32{"m5.*": 58, "*.metal": 250, "c5.large": 29}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an invalid glob", func() {
+			nc.Spec.MaxPodsPerInstanceType = map[string]int32{"m5.[": 58}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail when maxPods isn't positive", func() {
+			nc.Spec.MaxPodsPerInstanceType = map[string]int32{"m5.*": 0}
+			Expect(nc.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("NVIDIA", func() {
+		It("should succeed with a supported driver", func() {
+			for _, driver := range v1beta1.NVIDIADrivers {
+				nc.Spec.NVIDIA = &v1beta1.NVIDIAConfiguration{Driver: aws.String(driver)}
+				Expect(nc.Validate(ctx)).To(Succeed())
+			}
+		})
+		It("should fail with an unknown driver", func() {
+			nc.Spec.NVIDIA = &v1beta1.NVIDIAConfiguration{Driver: aws.String("nouveau")}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail to set the driver with an AMIFamily other than AL2 or Bottlerocket", func() {
+			nc.Spec.AMIFamily = aws.String(v1beta1.AMIFamilyUbuntu)
+			nc.Spec.NVIDIA = &v1beta1.NVIDIAConfiguration{Driver: aws.String(v1beta1.NVIDIADriverOpen)}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail to set the driver with an AMI selector", func() {
+			nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{ID: "ami-123"}}
+			nc.Spec.NVIDIA = &v1beta1.NVIDIAConfiguration{Driver: aws.String(v1beta1.NVIDIADriverOpen)}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should succeed to enable the container runtime with the Custom AMIFamily", func() {
+			nc.Spec.AMIFamily = aws.String(v1beta1.AMIFamilyCustom)
+			nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{ID: "ami-123"}}
+			nc.Spec.NVIDIA = &v1beta1.NVIDIAConfiguration{ContainerRuntime: aws.Bool(true)}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail to enable the container runtime with an AMIFamily other than Custom", func() {
+			nc.Spec.NVIDIA = &v1beta1.NVIDIAConfiguration{ContainerRuntime: aws.Bool(true)}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("Windows", func() {
+		BeforeEach(func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyWindows2022
+		})
+		It("should succeed with the CSI proxy and gMSA", func() {
+			nc.Spec.Windows = &v1beta1.WindowsConfiguration{
+				CSIProxy: &v1beta1.WindowsCSIProxy{BinaryURL: aws.String("https://example.com/csi-proxy.exe")},
+				GMSA:     &v1beta1.WindowsGMSA{PluginCLSID: "01234567-89ab-cdef-0123-456789abcdef"},
+			}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with a supported variant", func() {
+			for _, variant := range v1beta1.WindowsVariants {
+				nc.Spec.Windows = &v1beta1.WindowsConfiguration{Variant: aws.String(variant)}
+				Expect(nc.Validate(ctx)).To(Succeed())
+			}
+		})
+		It("should fail with an unknown variant", func() {
+			nc.Spec.Windows = &v1beta1.WindowsConfiguration{Variant: aws.String("Nano")}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail to set the variant with an AMI selector", func() {
+			nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{ID: "ami-12345678"}}
+			nc.Spec.Windows = &v1beta1.WindowsConfiguration{Variant: aws.String(v1beta1.WindowsFull)}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with an AMIFamily other than Windows", func() {
+			nc.Spec.AMIFamily = &v1beta1.AMIFamilyAL2
+			nc.Spec.Windows = &v1beta1.WindowsConfiguration{CSIProxy: &v1beta1.WindowsCSIProxy{}}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail when the CSI proxy binaryURL isn't https", func() {
+			for _, binaryURL := range []string{"http://example.com/csi-proxy.exe", "example.com/csi-proxy.exe", "https://example.com/csi-proxy.exe'; Remove-Item C:\\"} {
+				nc.Spec.Windows = &v1beta1.WindowsConfiguration{CSIProxy: &v1beta1.WindowsCSIProxy{BinaryURL: aws.String(binaryURL)}}
+				Expect(nc.Validate(ctx)).ToNot(Succeed())
+			}
+		})
+		It("should fail when the gMSA plugin CLSID isn't a COM class ID", func() {
+			for _, clsid := range []string{"", "{01234567-89ab-cdef-0123-456789abcdef}", "01234567-89ab-cdef-0123"} {
+				nc.Spec.Windows = &v1beta1.WindowsConfiguration{GMSA: &v1beta1.WindowsGMSA{PluginCLSID: clsid}}
+				Expect(nc.Validate(ctx)).ToNot(Succeed())
+			}
+		})
+	})
+	Context("InstanceRequirements", func() {
+		It("should succeed with attribute ranges, accelerators and excluded instance types", func() {
+			nc.Spec.InstanceRequirements = &v1beta1.InstanceRequirements{
+				VCPU:                     &v1beta1.InstanceRequirementsRange{Min: aws.Int64(2), Max: aws.Int64(16)},
+				MemoryMiB:                &v1beta1.InstanceRequirementsRange{Min: aws.Int64(4096)},
+				AcceleratorCount:         &v1beta1.InstanceRequirementsRange{Min: aws.Int64(1)},
+				AcceleratorTypes:         []string{v1beta1.AcceleratorTypeGPU},
+				AcceleratorManufacturers: []string{"nvidia"},
+				ExcludedInstanceTypes:    []string{"t2.*", "m5.metal"},
+			}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail when the minimum of a range is greater than its maximum", func() {
+			nc.Spec.InstanceRequirements = &v1beta1.InstanceRequirements{
+				VCPU: &v1beta1.InstanceRequirementsRange{Min: aws.Int64(16), Max: aws.Int64(2)},
+			}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with an unknown accelerator type or manufacturer", func() {
+			nc.Spec.InstanceRequirements = &v1beta1.InstanceRequirements{AcceleratorTypes: []string{"tpu"}}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+			nc.Spec.InstanceRequirements = &v1beta1.InstanceRequirements{AcceleratorManufacturers: []string{"intel"}}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with accelerator types when accelerators are excluded", func() {
+			nc.Spec.InstanceRequirements = &v1beta1.InstanceRequirements{
+				AcceleratorCount: &v1beta1.InstanceRequirementsRange{Max: aws.Int64(0)},
+				AcceleratorTypes: []string{v1beta1.AcceleratorTypeGPU},
+			}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with an invalid excluded instance type pattern", func() {
+			for _, pattern := range []string{"m5.[large", "M5.large", "m5 large"} {
+				nc.Spec.InstanceRequirements = &v1beta1.InstanceRequirements{ExcludedInstanceTypes: []string{pattern}}
+				Expect(nc.Validate(ctx)).ToNot(Succeed())
+			}
+		})
+	})
+	Context("RequireEncryptionInTransit", func() {
+		It("should succeed when encryption in transit is required", func() {
+			nc.Spec.RequireEncryptionInTransit = aws.Bool(true)
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail when encryption in transit is required with instance requirements", func() {
+			nc.Spec.RequireEncryptionInTransit = aws.Bool(true)
+			nc.Spec.InstanceRequirements = &v1beta1.InstanceRequirements{VCPU: &v1beta1.InstanceRequirementsRange{Min: aws.Int64(2)}}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("AMIMaxAge", func() {
+		It("should succeed with a positive maximum AMI age", func() {
+			nc.Spec.AMIMaxAge = &metav1.Duration{Duration: 30 * 24 * time.Hour}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with a maximum AMI age that isn't positive", func() {
+			nc.Spec.AMIMaxAge = &metav1.Duration{Duration: 0}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+			nc.Spec.AMIMaxAge = &metav1.Duration{Duration: -time.Hour}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("RegistrationTTL", func() {
+		It("should succeed with a registration TTL of at most 15 minutes", func() {
+			nc.Spec.RegistrationTTL = &metav1.Duration{Duration: 5 * time.Minute}
+			Expect(nc.Validate(ctx)).To(Succeed())
+			nc.Spec.RegistrationTTL = &metav1.Duration{Duration: 15 * time.Minute}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with a registration TTL that isn't positive", func() {
+			nc.Spec.RegistrationTTL = &metav1.Duration{Duration: 0}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+			nc.Spec.RegistrationTTL = &metav1.Duration{Duration: -time.Minute}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with a registration TTL of more than 15 minutes", func() {
+			nc.Spec.RegistrationTTL = &metav1.Duration{Duration: 20 * time.Minute}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("DeletionPolicy", func() {
+		It("should succeed when the deletion policy is block or cascade", func() {
+			for _, policy := range v1beta1.SupportedDeletionPolicies {
+				nc.Spec.DeletionPolicy = aws.String(policy)
+				Expect(nc.Validate(ctx)).To(Succeed())
+			}
+		})
+		It("should fail when the deletion policy is unknown", func() {
+			nc.Spec.DeletionPolicy = aws.String("orphan")
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+	})
+	Context("DriftPolicy", func() {
+		It("should succeed with the drift policy of the tags", func() {
+			for _, policy := range []string{"tags=InPlace", "tags=Replace", " tags=InPlace "} {
+				nc.Annotations = map[string]string{v1beta1.AnnotationDriftPolicy: policy}
+				Expect(nc.Validate(ctx)).To(Succeed())
+			}
+		})
+		It("should fail with an unknown field or policy", func() {
+			for _, policy := range []string{"", "tags", "userData=InPlace", "tags=Ignore"} {
+				nc.Annotations = map[string]string{v1beta1.AnnotationDriftPolicy: policy}
+				Expect(nc.Validate(ctx)).ToNot(Succeed())
+			}
+		})
+		It("should not hash the tags into the hash without the tags", func() {
+			hash, hashWithoutTags := nc.Hash(), nc.HashWithoutTags()
+			nc.Spec.Tags = map[string]string{"team": "b"}
+			Expect(nc.Hash()).ToNot(Equal(hash))
+			Expect(nc.HashWithoutTags()).To(Equal(hashWithoutTags))
+		})
+	})
+	Context("AssumeRoleARN", func() {
+		It("should succeed with the ARN of a role", func() {
+			for _, arn := range []string{"arn:aws:iam::123456789012:role/discovery", "arn:aws-us-gov:iam::123456789012:role/path/discovery"} {
+				nc.Spec.AssumeRoleARN = lo.ToPtr(arn)
+				Expect(nc.Validate(ctx)).To(Succeed())
+			}
+		})
+		It("should fail with an ARN that isn't of a role", func() {
+			for _, arn := range []string{"discovery", "arn:aws:iam::123456789012:user/discovery", "arn:aws:iam::self:role/discovery"} {
+				nc.Spec.AssumeRoleARN = lo.ToPtr(arn)
+				Expect(nc.Validate(ctx)).ToNot(Succeed())
+			}
+		})
+	})
 	Context("SecurityGroupSelectorTerms", func() {
 		It("should succeed with a valid security group selector on tags", func() {
 			nc.Spec.SecurityGroupSelectorTerms = []v1beta1.SecurityGroupSelectorTerm{
@@ -249,6 +974,21 @@ var _ = Describe("Validation", func() {
 			}
 			Expect(nc.Validate(ctx)).To(Succeed())
 		})
+		It("should succeed with a valid security group selector on name or tags scoped to a vpc", func() {
+			nc.Spec.SecurityGroupSelectorTerms = []v1beta1.SecurityGroupSelectorTerm{
+				{Name: "testname", VPCID: "vpc-12345749"},
+				{Tags: map[string]string{"test": "testvalue"}, VPCID: "vpc-12345749"},
+			}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail when a security group selector term only has a vpc", func() {
+			nc.Spec.SecurityGroupSelectorTerms = []v1beta1.SecurityGroupSelectorTerm{{VPCID: "vpc-12345749"}}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail when a security group selector term has an id and a vpc", func() {
+			nc.Spec.SecurityGroupSelectorTerms = []v1beta1.SecurityGroupSelectorTerm{{ID: "sg-12345749", VPCID: "vpc-12345749"}}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
 		It("should fail when security group selector terms is set to nil", func() {
 			nc.Spec.SecurityGroupSelectorTerms = nil
 			Expect(nc.Validate(ctx)).ToNot(Succeed())
@@ -480,6 +1220,76 @@ var _ = Describe("Validation", func() {
 			}
 			Expect(nc.Validate(ctx)).ToNot(Succeed())
 		})
+		It("should fail when specifying ssm with tags", func() {
+			nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{
+				{
+					SSM:  "/my-org/ami/tested",
+					Tags: map[string]string{"approved": "true"},
+				},
+			}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should succeed with a name regex, architecture and minimum creation date", func() {
+			nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{
+				{
+					Tags:            map[string]string{"approved": "true"},
+					NameRegex:       `^my-ami-[0-9]+$`,
+					Architecture:    "arm64",
+					MinCreationDate: "720h",
+				},
+				{
+					Name:            "my-ami-*",
+					MinCreationDate: "2023-09-01T00:00:00Z",
+				},
+			}
+			Expect(nc.Validate(ctx)).To(Succeed())
+		})
+		It("should fail when specifying id with a name regex", func() {
+			nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{
+				{
+					ID:        "ami-12345749",
+					NameRegex: "my-ami-.*",
+				},
+			}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail when specifying only a name regex", func() {
+			nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{
+				{
+					NameRegex: "my-ami-.*",
+				},
+			}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with an invalid name regex", func() {
+			nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{
+				{
+					Name:      "my-ami-*",
+					NameRegex: "my-ami-(",
+				},
+			}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with an unknown architecture", func() {
+			nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{
+				{
+					Name:         "my-ami-*",
+					Architecture: "x86_64",
+				},
+			}
+			Expect(nc.Validate(ctx)).ToNot(Succeed())
+		})
+		It("should fail with a minimum creation date that isn't a timestamp or duration", func() {
+			for _, minCreationDate := range []string{"30d", "2023-09-01", "-720h"} {
+				nc.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{
+					{
+						Name:            "my-ami-*",
+						MinCreationDate: minCreationDate,
+					},
+				}
+				Expect(nc.Validate(ctx)).ToNot(Succeed())
+			}
+		})
 	})
 	Context("NodeClass Hash", func() {
 		var nodeClass *v1beta1.NodeClass
@@ -556,3 +1366,80 @@ var _ = Describe("Validation", func() {
 		})
 	})
 })
+
+var _ = Describe("Defaulting", func() {
+	var nc *v1beta1.NodeClass
+
+	BeforeEach(func() {
+		nc = test.NodeClass()
+	})
+
+	It("should default the AMIFamily to AL2", func() {
+		nc.SetDefaults(ctx)
+		Expect(nc.Spec.AMIFamily).To(Equal(lo.ToPtr(v1beta1.AMIFamilyAL2)))
+	})
+	It("should default the metadata options to IMDSv2", func() {
+		nc.SetDefaults(ctx)
+		Expect(nc.Spec.MetadataOptions).To(Equal(&v1beta1.MetadataOptions{
+			HTTPEndpoint:            aws.String(ec2.LaunchTemplateInstanceMetadataEndpointStateEnabled),
+			HTTPPutResponseHopLimit: aws.Int64(2),
+			HTTPTokens:              aws.String(ec2.LaunchTemplateHttpTokensStateRequired),
+		}))
+	})
+	It("should only default the metadata options that aren't set", func() {
+		nc.Spec.MetadataOptions = &v1beta1.MetadataOptions{
+			HTTPProtocolIPv6: aws.String(ec2.LaunchTemplateInstanceMetadataProtocolIpv6Enabled),
+			HTTPTokens:       aws.String(ec2.LaunchTemplateHttpTokensStateOptional),
+		}
+		nc.SetDefaults(ctx)
+		Expect(nc.Spec.MetadataOptions).To(Equal(&v1beta1.MetadataOptions{
+			HTTPEndpoint:            aws.String(ec2.LaunchTemplateInstanceMetadataEndpointStateEnabled),
+			HTTPProtocolIPv6:        aws.String(ec2.LaunchTemplateInstanceMetadataProtocolIpv6Enabled),
+			HTTPPutResponseHopLimit: aws.Int64(2),
+			HTTPTokens:              aws.String(ec2.LaunchTemplateHttpTokensStateOptional),
+		}))
+	})
+	It("should not default the block device mappings", func() {
+		nc.Spec.AMIFamily = lo.ToPtr(v1beta1.AMIFamilyBottlerocket)
+		nc.SetDefaults(ctx)
+		Expect(nc.Spec.BlockDeviceMappings).To(BeEmpty())
+	})
+	It("should not override block device mappings that are set", func() {
+		nc.Spec.BlockDeviceMappings = []*v1beta1.BlockDeviceMapping{{
+			DeviceName: aws.String("/dev/xvdb"),
+			EBS:        &v1beta1.BlockDevice{VolumeSize: lo.ToPtr(resource.MustParse("100Gi"))},
+		}}
+		nc.SetDefaults(ctx)
+		Expect(nc.Spec.BlockDeviceMappings).To(HaveLen(1))
+		Expect(nc.Spec.BlockDeviceMappings[0].EBS.VolumeSize.String()).To(Equal("100Gi"))
+	})
+	It("should not change the hash when defaulted", func() {
+		// NodeClasses that were stored before they were defaulted are defaulted the next time that they're updated
+		hash, hashWithoutTags := nc.Hash(), nc.HashWithoutTags()
+		nc.SetDefaults(ctx)
+		Expect(nc.Hash()).To(Equal(hash))
+		Expect(nc.HashWithoutTags()).To(Equal(hashWithoutTags))
+	})
+	It("should not change the hash when the block device mappings of the AMIFamily were stored", func() {
+		nc.Spec.AMIFamily = lo.ToPtr(v1beta1.AMIFamilyBottlerocket)
+		hash := nc.Hash()
+		nc.Spec.BlockDeviceMappings = v1beta1.DefaultBlockDeviceMappings(v1beta1.AMIFamilyBottlerocket)
+		Expect(nc.Hash()).To(Equal(hash))
+	})
+	It("should change the hash when a default is changed", func() {
+		nc.SetDefaults(ctx)
+		hash := nc.Hash()
+		nc.Spec.AMIFamily = lo.ToPtr(v1beta1.AMIFamilyBottlerocket)
+		Expect(nc.Hash()).ToNot(Equal(hash))
+		nc.Spec.AMIFamily = lo.ToPtr(v1beta1.AMIFamilyAL2)
+		nc.Spec.MetadataOptions.HTTPTokens = aws.String(ec2.LaunchTemplateHttpTokensStateOptional)
+		Expect(nc.Hash()).ToNot(Equal(hash))
+	})
+	It("should be valid and have a stable hash once defaulted", func() {
+		nc.SetDefaults(ctx)
+		Expect(nc.Validate(ctx)).To(Succeed())
+		hash := nc.Hash()
+		nc.SetDefaults(ctx)
+		Expect(nc.Hash()).To(Equal(hash))
+	})
+})
@@ -20,7 +20,9 @@ package v1beta1
 
 import (
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"knative.dev/pkg/apis"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -67,6 +69,129 @@ func (in *AMISelectorTerm) DeepCopy() *AMISelectorTerm {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSConfiguration) DeepCopyInto(out *AWSConfiguration) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSConfiguration.
+func (in *AWSConfiguration) DeepCopy() *AWSConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AWSConfiguration) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSConfigurationList) DeepCopyInto(out *AWSConfigurationList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AWSConfiguration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSConfigurationList.
+func (in *AWSConfigurationList) DeepCopy() *AWSConfigurationList {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSConfigurationList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AWSConfigurationList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSConfigurationSpec) DeepCopyInto(out *AWSConfigurationSpec) {
+	*out = *in
+	if in.ClusterName != nil {
+		in, out := &in.ClusterName, &out.ClusterName
+		*out = new(string)
+		**out = **in
+	}
+	if in.ClusterEndpoint != nil {
+		in, out := &in.ClusterEndpoint, &out.ClusterEndpoint
+		*out = new(string)
+		**out = **in
+	}
+	if in.InterruptionQueueName != nil {
+		in, out := &in.InterruptionQueueName, &out.InterruptionQueueName
+		*out = new(string)
+		**out = **in
+	}
+	if in.VMMemoryOverheadPercent != nil {
+		in, out := &in.VMMemoryOverheadPercent, &out.VMMemoryOverheadPercent
+		*out = new(string)
+		**out = **in
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSConfigurationSpec.
+func (in *AWSConfigurationSpec) DeepCopy() *AWSConfigurationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSConfigurationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSConfigurationStatus) DeepCopyInto(out *AWSConfigurationStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apis.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSConfigurationStatus.
+func (in *AWSConfigurationStatus) DeepCopy() *AWSConfigurationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSConfigurationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BlockDevice) DeepCopyInto(out *BlockDevice) {
 	*out = *in
@@ -147,6 +272,350 @@ func (in *BlockDeviceMapping) DeepCopy() *BlockDeviceMapping {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BottlerocketBootstrapContainer) DeepCopyInto(out *BottlerocketBootstrapContainer) {
+	*out = *in
+	if in.Source != nil {
+		in, out := &in.Source, &out.Source
+		*out = new(string)
+		**out = **in
+	}
+	if in.Mode != nil {
+		in, out := &in.Mode, &out.Mode
+		*out = new(string)
+		**out = **in
+	}
+	if in.Essential != nil {
+		in, out := &in.Essential, &out.Essential
+		*out = new(bool)
+		**out = **in
+	}
+	if in.UserData != nil {
+		in, out := &in.UserData, &out.UserData
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BottlerocketBootstrapContainer.
+func (in *BottlerocketBootstrapContainer) DeepCopy() *BottlerocketBootstrapContainer {
+	if in == nil {
+		return nil
+	}
+	out := new(BottlerocketBootstrapContainer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BottlerocketHostContainer) DeepCopyInto(out *BottlerocketHostContainer) {
+	*out = *in
+	if in.Source != nil {
+		in, out := &in.Source, &out.Source
+		*out = new(string)
+		**out = **in
+	}
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Superpowered != nil {
+		in, out := &in.Superpowered, &out.Superpowered
+		*out = new(bool)
+		**out = **in
+	}
+	if in.UserData != nil {
+		in, out := &in.UserData, &out.UserData
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BottlerocketHostContainer.
+func (in *BottlerocketHostContainer) DeepCopy() *BottlerocketHostContainer {
+	if in == nil {
+		return nil
+	}
+	out := new(BottlerocketHostContainer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BottlerocketRegistryMirror) DeepCopyInto(out *BottlerocketRegistryMirror) {
+	*out = *in
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BottlerocketRegistryMirror.
+func (in *BottlerocketRegistryMirror) DeepCopy() *BottlerocketRegistryMirror {
+	if in == nil {
+		return nil
+	}
+	out := new(BottlerocketRegistryMirror)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BottlerocketSettings) DeepCopyInto(out *BottlerocketSettings) {
+	*out = *in
+	if in.HostContainers != nil {
+		in, out := &in.HostContainers, &out.HostContainers
+		*out = make(map[string]BottlerocketHostContainer, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.BootstrapContainers != nil {
+		in, out := &in.BootstrapContainers, &out.BootstrapContainers
+		*out = make(map[string]BottlerocketBootstrapContainer, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.KernelSysctl != nil {
+		in, out := &in.KernelSysctl, &out.KernelSysctl
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.RegistryMirrors != nil {
+		in, out := &in.RegistryMirrors, &out.RegistryMirrors
+		*out = make([]BottlerocketRegistryMirror, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BottlerocketSettings.
+func (in *BottlerocketSettings) DeepCopy() *BottlerocketSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(BottlerocketSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CPUOptions) DeepCopyInto(out *CPUOptions) {
+	*out = *in
+	if in.AMDSEVSNP != nil {
+		in, out := &in.AMDSEVSNP, &out.AMDSEVSNP
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CPUOptions.
+func (in *CPUOptions) DeepCopy() *CPUOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(CPUOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerRegistry) DeepCopyInto(out *ContainerRegistry) {
+	*out = *in
+	if in.Mirrors != nil {
+		in, out := &in.Mirrors, &out.Mirrors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Auth != nil {
+		in, out := &in.Auth, &out.Auth
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerRegistry.
+func (in *ContainerRegistry) DeepCopy() *ContainerRegistry {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerRegistry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EnclaveOptions) DeepCopyInto(out *EnclaveOptions) {
+	*out = *in
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EnclaveOptions.
+func (in *EnclaveOptions) DeepCopy() *EnclaveOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(EnclaveOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EphemeralStorageSizing) DeepCopyInto(out *EphemeralStorageSizing) {
+	*out = *in
+	if in.MinSize != nil {
+		in, out := &in.MinSize, &out.MinSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	out.MaxSize = in.MaxSize.DeepCopy()
+	if in.Overhead != nil {
+		in, out := &in.Overhead, &out.Overhead
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralStorageSizing.
+func (in *EphemeralStorageSizing) DeepCopy() *EphemeralStorageSizing {
+	if in == nil {
+		return nil
+	}
+	out := new(EphemeralStorageSizing)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InstanceRequirements) DeepCopyInto(out *InstanceRequirements) {
+	*out = *in
+	if in.VCPU != nil {
+		in, out := &in.VCPU, &out.VCPU
+		*out = new(InstanceRequirementsRange)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MemoryMiB != nil {
+		in, out := &in.MemoryMiB, &out.MemoryMiB
+		*out = new(InstanceRequirementsRange)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AcceleratorCount != nil {
+		in, out := &in.AcceleratorCount, &out.AcceleratorCount
+		*out = new(InstanceRequirementsRange)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AcceleratorTypes != nil {
+		in, out := &in.AcceleratorTypes, &out.AcceleratorTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AcceleratorManufacturers != nil {
+		in, out := &in.AcceleratorManufacturers, &out.AcceleratorManufacturers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludedInstanceTypes != nil {
+		in, out := &in.ExcludedInstanceTypes, &out.ExcludedInstanceTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstanceRequirements.
+func (in *InstanceRequirements) DeepCopy() *InstanceRequirements {
+	if in == nil {
+		return nil
+	}
+	out := new(InstanceRequirements)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InstanceRequirementsRange) DeepCopyInto(out *InstanceRequirementsRange) {
+	*out = *in
+	if in.Min != nil {
+		in, out := &in.Min, &out.Min
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Max != nil {
+		in, out := &in.Max, &out.Max
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstanceRequirementsRange.
+func (in *InstanceRequirementsRange) DeepCopy() *InstanceRequirementsRange {
+	if in == nil {
+		return nil
+	}
+	out := new(InstanceRequirementsRange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubeletConfiguration) DeepCopyInto(out *KubeletConfiguration) {
+	*out = *in
+	if in.ShutdownGracePeriod != nil {
+		in, out := &in.ShutdownGracePeriod, &out.ShutdownGracePeriod
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ShutdownGracePeriodCriticalPods != nil {
+		in, out := &in.ShutdownGracePeriodCriticalPods, &out.ShutdownGracePeriodCriticalPods
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.RegistryPullQPS != nil {
+		in, out := &in.RegistryPullQPS, &out.RegistryPullQPS
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RegistryBurst != nil {
+		in, out := &in.RegistryBurst, &out.RegistryBurst
+		*out = new(int32)
+		**out = **in
+	}
+	if in.CPUManagerPolicy != nil {
+		in, out := &in.CPUManagerPolicy, &out.CPUManagerPolicy
+		*out = new(string)
+		**out = **in
+	}
+	if in.TopologyManagerPolicy != nil {
+		in, out := &in.TopologyManagerPolicy, &out.TopologyManagerPolicy
+		*out = new(string)
+		**out = **in
+	}
+	if in.MemoryManagerPolicy != nil {
+		in, out := &in.MemoryManagerPolicy, &out.MemoryManagerPolicy
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubeletConfiguration.
+func (in *KubeletConfiguration) DeepCopy() *KubeletConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(KubeletConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetadataOptions) DeepCopyInto(out *MetadataOptions) {
 	*out = *in
@@ -182,6 +651,31 @@ func (in *MetadataOptions) DeepCopy() *MetadataOptions {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NVIDIAConfiguration) DeepCopyInto(out *NVIDIAConfiguration) {
+	*out = *in
+	if in.Driver != nil {
+		in, out := &in.Driver, &out.Driver
+		*out = new(string)
+		**out = **in
+	}
+	if in.ContainerRuntime != nil {
+		in, out := &in.ContainerRuntime, &out.ContainerRuntime
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NVIDIAConfiguration.
+func (in *NVIDIAConfiguration) DeepCopy() *NVIDIAConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(NVIDIAConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NodeClass) DeepCopyInto(out *NodeClass) {
 	*out = *in
@@ -251,6 +745,11 @@ func (in *NodeClassSpec) DeepCopyInto(out *NodeClassSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.SubnetPolicy != nil {
+		in, out := &in.SubnetPolicy, &out.SubnetPolicy
+		*out = new(string)
+		**out = **in
+	}
 	if in.SecurityGroupSelectorTerms != nil {
 		in, out := &in.SecurityGroupSelectorTerms, &out.SecurityGroupSelectorTerms
 		*out = make([]SecurityGroupSelectorTerm, len(*in))
@@ -275,6 +774,26 @@ func (in *NodeClassSpec) DeepCopyInto(out *NodeClassSpec) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.UserDataTemplating != nil {
+		in, out := &in.UserDataTemplating, &out.UserDataTemplating
+		*out = new(bool)
+		**out = **in
+	}
+	if in.UserDataRef != nil {
+		in, out := &in.UserDataRef, &out.UserDataRef
+		*out = new(UserDataReference)
+		**out = **in
+	}
+	if in.UserDataMergeOrder != nil {
+		in, out := &in.UserDataMergeOrder, &out.UserDataMergeOrder
+		*out = new(string)
+		**out = **in
+	}
+	if in.UserDataCompression != nil {
+		in, out := &in.UserDataCompression, &out.UserDataCompression
+		*out = new(string)
+		**out = **in
+	}
 	if in.Role != nil {
 		in, out := &in.Role, &out.Role
 		*out = new(string)
@@ -298,11 +817,117 @@ func (in *NodeClassSpec) DeepCopyInto(out *NodeClassSpec) {
 			}
 		}
 	}
+	if in.EphemeralStorageSizing != nil {
+		in, out := &in.EphemeralStorageSizing, &out.EphemeralStorageSizing
+		*out = new(EphemeralStorageSizing)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.DetailedMonitoring != nil {
 		in, out := &in.DetailedMonitoring, &out.DetailedMonitoring
 		*out = new(bool)
 		**out = **in
 	}
+	if in.CPUCreditSpecification != nil {
+		in, out := &in.CPUCreditSpecification, &out.CPUCreditSpecification
+		*out = new(string)
+		**out = **in
+	}
+	if in.EnclaveOptions != nil {
+		in, out := &in.EnclaveOptions, &out.EnclaveOptions
+		*out = new(EnclaveOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CPUOptions != nil {
+		in, out := &in.CPUOptions, &out.CPUOptions
+		*out = new(CPUOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AMIMaxAge != nil {
+		in, out := &in.AMIMaxAge, &out.AMIMaxAge
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.RequireEncryptionInTransit != nil {
+		in, out := &in.RequireEncryptionInTransit, &out.RequireEncryptionInTransit
+		*out = new(bool)
+		**out = **in
+	}
+	if in.InstanceInitiatedShutdownBehavior != nil {
+		in, out := &in.InstanceInitiatedShutdownBehavior, &out.InstanceInitiatedShutdownBehavior
+		*out = new(string)
+		**out = **in
+	}
+	if in.StartupTaints != nil {
+		in, out := &in.StartupTaints, &out.StartupTaints
+		*out = make([]v1.Taint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RequireSSMAgent != nil {
+		in, out := &in.RequireSSMAgent, &out.RequireSSMAgent
+		*out = new(bool)
+		**out = **in
+	}
+	if in.RegistrationTTL != nil {
+		in, out := &in.RegistrationTTL, &out.RegistrationTTL
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Bottlerocket != nil {
+		in, out := &in.Bottlerocket, &out.Bottlerocket
+		*out = new(BottlerocketSettings)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ContainerRegistries != nil {
+		in, out := &in.ContainerRegistries, &out.ContainerRegistries
+		*out = make([]ContainerRegistry, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Kubelet != nil {
+		in, out := &in.Kubelet, &out.Kubelet
+		*out = new(KubeletConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxPodsPerInstanceType != nil {
+		in, out := &in.MaxPodsPerInstanceType, &out.MaxPodsPerInstanceType
+		*out = make(map[string]int32, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.NVIDIA != nil {
+		in, out := &in.NVIDIA, &out.NVIDIA
+		*out = new(NVIDIAConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Windows != nil {
+		in, out := &in.Windows, &out.Windows
+		*out = new(WindowsConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.InstanceRequirements != nil {
+		in, out := &in.InstanceRequirements, &out.InstanceRequirements
+		*out = new(InstanceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AssumeRoleARN != nil {
+		in, out := &in.AssumeRoleARN, &out.AssumeRoleARN
+		*out = new(string)
+		**out = **in
+	}
+	if in.LaunchDryRun != nil {
+		in, out := &in.LaunchDryRun, &out.LaunchDryRun
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DeletionPolicy != nil {
+		in, out := &in.DeletionPolicy, &out.DeletionPolicy
+		*out = new(string)
+		**out = **in
+	}
 	if in.MetadataOptions != nil {
 		in, out := &in.MetadataOptions, &out.MetadataOptions
 		*out = new(MetadataOptions)
@@ -376,6 +1001,13 @@ func (in *NodeClassStatus) DeepCopyInto(out *NodeClassStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apis.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeClassStatus.
@@ -461,3 +1093,83 @@ func (in *SubnetSelectorTerm) DeepCopy() *SubnetSelectorTerm {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UserDataReference) DeepCopyInto(out *UserDataReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UserDataReference.
+func (in *UserDataReference) DeepCopy() *UserDataReference {
+	if in == nil {
+		return nil
+	}
+	out := new(UserDataReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WindowsCSIProxy) DeepCopyInto(out *WindowsCSIProxy) {
+	*out = *in
+	if in.BinaryURL != nil {
+		in, out := &in.BinaryURL, &out.BinaryURL
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WindowsCSIProxy.
+func (in *WindowsCSIProxy) DeepCopy() *WindowsCSIProxy {
+	if in == nil {
+		return nil
+	}
+	out := new(WindowsCSIProxy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WindowsConfiguration) DeepCopyInto(out *WindowsConfiguration) {
+	*out = *in
+	if in.Variant != nil {
+		in, out := &in.Variant, &out.Variant
+		*out = new(string)
+		**out = **in
+	}
+	if in.CSIProxy != nil {
+		in, out := &in.CSIProxy, &out.CSIProxy
+		*out = new(WindowsCSIProxy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GMSA != nil {
+		in, out := &in.GMSA, &out.GMSA
+		*out = new(WindowsGMSA)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WindowsConfiguration.
+func (in *WindowsConfiguration) DeepCopy() *WindowsConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(WindowsConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WindowsGMSA) DeepCopyInto(out *WindowsGMSA) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WindowsGMSA.
+func (in *WindowsGMSA) DeepCopy() *WindowsGMSA {
+	if in == nil {
+		return nil
+	}
+	out := new(WindowsGMSA)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -16,15 +16,32 @@ package batcher
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/samber/lo"
 	"knative.dev/pkg/logging"
 )
 
+// WeightedCapacityFulfilledError is returned to the callers of a batched fleet request with weighted overrides that no
+// instance is launched for, because the fleet fulfilled the batch's capacity with fewer, larger instances. Their launches
+// haven't failed for lack of capacity, so they can be retried.
+type WeightedCapacityFulfilledError struct {
+	error
+}
+
+func IsWeightedCapacityFulfilled(err error) bool {
+	if err == nil {
+		return false
+	}
+	var weightedCapacityFulfilledErr *WeightedCapacityFulfilledError
+	return errors.As(err, &weightedCapacityFulfilledErr)
+}
+
 type CreateFleetBatcher struct {
 	batcher *Batcher[ec2.CreateFleetInput, ec2.CreateFleetOutput]
 }
@@ -54,6 +71,18 @@ func execCreateFleetBatch(ec2api ec2iface.EC2API) BatchExecutor[ec2.CreateFleetI
 		results := make([]Result[ec2.CreateFleetOutput], 0, len(inputs))
 		firstInput := inputs[0]
 		firstInput.TargetCapacitySpecification.TotalTargetCapacity = aws.Int64(int64(len(inputs)))
+		// Weighted capacity only lets a batch of launches be fulfilled by fewer, larger instances. A single launch would
+		// only be priced by its weighted capacity, which can launch a larger instance than it needs.
+		weighted := len(inputs) > 1 && lo.SomeBy(firstInput.LaunchTemplateConfigs, func(ltc *ec2.FleetLaunchTemplateConfigRequest) bool {
+			return lo.SomeBy(ltc.Overrides, func(o *ec2.FleetLaunchTemplateOverridesRequest) bool { return o.WeightedCapacity != nil })
+		})
+		if len(inputs) == 1 {
+			for _, ltc := range firstInput.LaunchTemplateConfigs {
+				for _, override := range ltc.Overrides {
+					override.WeightedCapacity = nil
+				}
+			}
+		}
 		output, err := ec2api.CreateFleetWithContext(ctx, firstInput)
 		if err != nil {
 			for range inputs {
@@ -92,6 +121,16 @@ func execCreateFleetBatch(ec2api ec2iface.EC2API) BatchExecutor[ec2.CreateFleetI
 		}
 
 		if requestIdx != len(inputs) {
+			// A weighted fleet that returns no errors has fulfilled the capacity of the batch with larger instances
+			if weighted && len(output.Errors) == 0 {
+				for i := requestIdx + 1; i < len(inputs); i++ {
+					results = append(results, Result[ec2.CreateFleetOutput]{
+						Err: &WeightedCapacityFulfilledError{fmt.Errorf("fleet %s fulfilled the weighted capacity of %d launches with %d instances",
+							aws.StringValue(output.FleetId), len(inputs), requestIdx+1)},
+					})
+				}
+				return results
+			}
 			// we should receive some sort of error, but just in case
 			if len(output.Errors) == 0 {
 				output.Errors = append(output.Errors, &ec2.CreateFleetError{
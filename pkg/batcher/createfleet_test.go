@@ -156,6 +156,75 @@ var _ = Describe("CreateFleet Batching", func() {
 		Expect(*east1Call.TargetCapacitySpecification.TotalTargetCapacity).To(BeNumerically("==", 4))
 		Expect(*east1Call.LaunchTemplateConfigs[0].Overrides[0].AvailabilityZone).To(Equal("us-east-1"))
 	})
+	It("should not weigh the overrides of a fleet request that isn't batched", func() {
+		input := &ec2.CreateFleetInput{
+			LaunchTemplateConfigs: []*ec2.FleetLaunchTemplateConfigRequest{
+				{
+					LaunchTemplateSpecification: &ec2.FleetLaunchTemplateSpecificationRequest{
+						LaunchTemplateName: aws.String("my-template"),
+					},
+					Overrides: []*ec2.FleetLaunchTemplateOverridesRequest{
+						{
+							AvailabilityZone: aws.String("us-east-1"),
+							WeightedCapacity: aws.Float64(2),
+						},
+					},
+				},
+			},
+			TargetCapacitySpecification: &ec2.TargetCapacitySpecificationRequest{
+				TotalTargetCapacity: aws.Int64(1),
+			},
+		}
+		rsp, err := cfb.CreateFleet(ctx, input)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rsp.Instances).To(HaveLen(1))
+		Expect(fakeEC2API.CreateFleetBehavior.CalledWithInput.Len()).To(BeNumerically("==", 1))
+		call := fakeEC2API.CreateFleetBehavior.CalledWithInput.Pop()
+		Expect(call.LaunchTemplateConfigs[0].Overrides[0].WeightedCapacity).To(BeNil())
+	})
+	It("should return errors to the callers that a weighted fleet doesn't launch an instance for", func() {
+		input := &ec2.CreateFleetInput{
+			LaunchTemplateConfigs: []*ec2.FleetLaunchTemplateConfigRequest{
+				{
+					LaunchTemplateSpecification: &ec2.FleetLaunchTemplateSpecificationRequest{
+						LaunchTemplateName: aws.String("my-template"),
+					},
+					Overrides: []*ec2.FleetLaunchTemplateOverridesRequest{
+						{
+							AvailabilityZone: aws.String("us-east-1"),
+							WeightedCapacity: aws.Float64(2),
+						},
+					},
+				},
+			},
+			TargetCapacitySpecification: &ec2.TargetCapacitySpecificationRequest{
+				TotalTargetCapacity: aws.Int64(1),
+			},
+		}
+		var wg sync.WaitGroup
+		var receivedInstance, receivedErrors int64
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				rsp, err := cfb.CreateFleet(ctx, input)
+				if err == nil {
+					Expect(rsp.Instances).ToNot(BeEmpty())
+					atomic.AddInt64(&receivedInstance, 1)
+				} else {
+					Expect(batcher.IsWeightedCapacityFulfilled(err)).To(BeTrue())
+					atomic.AddInt64(&receivedErrors, 1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		// 4 units of capacity are fulfilled by 2 instances with a weighted capacity of 2
+		Expect(receivedInstance).To(BeNumerically("==", 2))
+		Expect(receivedErrors).To(BeNumerically("==", 2))
+		Expect(fakeEC2API.CreateFleetBehavior.CalledWithInput.Len()).To(BeNumerically("==", 1))
+	})
 	It("should return any errors to callers", func() {
 		input := &ec2.CreateFleetInput{
 			LaunchTemplateConfigs: []*ec2.FleetLaunchTemplateConfigRequest{
@@ -27,8 +27,24 @@ const (
 	// UnavailableOfferingsTTL is the time before offerings that were marked as unavailable
 	// are removed from the cache and are available for launch again
 	UnavailableOfferingsTTL = 3 * time.Minute
-	// InstanceTypesAndZonesTTL is the time before we refresh instance types and zones at EC2
+	// InstanceTypesAndZonesTTL is the time before we refresh instance type zones at EC2
 	InstanceTypesAndZonesTTL = 5 * time.Minute
+	// InstanceTypesRefreshInterval is the interval, before jitter, at which the instance types are refreshed
+	// at EC2 in the background
+	InstanceTypesRefreshInterval = 5 * time.Minute
+	// SpotPlacementScoresTTL is the time before the spot placement scores of a set of instance types are requested
+	// again. EC2 limits how many distinct sets of instance types can be scored in a day.
+	SpotPlacementScoresTTL = 15 * time.Minute
+	// AMIsTTL is the time before the AMIs that an AMI selector term discovered are described again in full, so that
+	// AMIs that were deregistered or untagged are no longer selected. Until then, only the AMIs that were created since
+	// the newest AMI that was discovered are described once every DefaultTTL.
+	AMIsTTL = 15 * time.Minute
+	// ObservedMemoryCapacityTTL is the time before the memory capacity observed on nodes of an instance type
+	// is forgotten and the VM memory overhead of the instance type is estimated again
+	ObservedMemoryCapacityTTL = 24 * time.Hour
+	// PreTerminationNotificationTTL is the time before an instance that a pre-termination notification was published
+	// for can be notified again. Karpenter retries the termination of an instance until it's terminated.
+	PreTerminationNotificationTTL = time.Hour
 )
 
 const (
@@ -0,0 +1,88 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/patrickmn/go-cache"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"knative.dev/pkg/logging"
+)
+
+// ObservedMemoryCapacities stores the memory capacity reported by nodes of each instance type and AMI family. The VM
+// memory overhead of an instance type varies by family and size, and the memory that the OS reserves varies by AMI
+// family, so the observed capacity is used in place of the estimated capacity on GetInstanceTypes responses for as
+// long as it's in the cache
+type ObservedMemoryCapacities struct {
+	// key: <instanceType>:<amiFamily>, value: resource.Quantity
+	cache  *cache.Cache
+	SeqNum uint64
+}
+
+func NewObservedMemoryCapacities() *ObservedMemoryCapacities {
+	return &ObservedMemoryCapacities{
+		cache:  cache.New(ObservedMemoryCapacityTTL, DefaultCleanupInterval),
+		SeqNum: 0,
+	}
+}
+
+// Get returns the memory capacity observed on nodes of the instance type that were launched with the AMI family, if any
+func (o *ObservedMemoryCapacities) Get(instanceType, amiFamily string) (*resource.Quantity, bool) {
+	capacity, found := o.cache.Get(o.key(instanceType, amiFamily))
+	if !found {
+		return nil, false
+	}
+	q := capacity.(resource.Quantity)
+	return &q, true
+}
+
+// Observe records the memory capacity reported by nodes of the instance type that were launched with the AMI family
+func (o *ObservedMemoryCapacities) Observe(ctx context.Context, instanceType, amiFamily string, capacity resource.Quantity) {
+	existing, found := o.Get(instanceType, amiFamily)
+	// even if the capacity is unchanged, we still need to call Set to extend the cached entry's TTL
+	o.cache.SetDefault(o.key(instanceType, amiFamily), capacity)
+	if found && existing.Equal(capacity) {
+		return
+	}
+	logging.FromContext(ctx).With(
+		"instance-type", instanceType,
+		"ami-family", amiFamily,
+		"memory", capacity.String()).Debugf("observed memory capacity")
+	atomic.AddUint64(&o.SeqNum, 1)
+}
+
+// List returns the memory capacities observed on nodes that were launched with the AMI family, by instance type
+func (o *ObservedMemoryCapacities) List(amiFamily string) map[string]resource.Quantity {
+	capacities := map[string]resource.Quantity{}
+	for key, item := range o.cache.Items() {
+		instanceType, family, _ := strings.Cut(key, ":")
+		if family == amiFamily {
+			capacities[instanceType] = item.Object.(resource.Quantity)
+		}
+	}
+	return capacities
+}
+
+func (o *ObservedMemoryCapacities) key(instanceType, amiFamily string) string {
+	return fmt.Sprintf("%s:%s", instanceType, amiFamily)
+}
+
+func (o *ObservedMemoryCapacities) Flush() {
+	o.cache.Flush()
+}
@@ -18,6 +18,8 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/service/ec2"
@@ -40,19 +42,23 @@ import (
 	"github.com/aws/karpenter-core/pkg/utils/resources"
 
 	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"knative.dev/pkg/logging"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
-	nodeclaimutil "github.com/aws/karpenter-core/pkg/utils/nodeclaim"
 	cloudproviderevents "github.com/aws/karpenter/pkg/cloudprovider/events"
+	awserrors "github.com/aws/karpenter/pkg/errors"
 	"github.com/aws/karpenter/pkg/providers/amifamily"
 	"github.com/aws/karpenter/pkg/providers/instance"
 	"github.com/aws/karpenter/pkg/providers/instancetype"
+	"github.com/aws/karpenter/pkg/providers/notification"
+	"github.com/aws/karpenter/pkg/providers/quota"
 	"github.com/aws/karpenter/pkg/providers/securitygroup"
 	"github.com/aws/karpenter/pkg/providers/subnet"
+	"github.com/aws/karpenter/pkg/utils/tracing"
 
 	coreapis "github.com/aws/karpenter-core/pkg/apis"
 	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
@@ -74,11 +80,17 @@ type CloudProvider struct {
 	amiProvider           *amifamily.Provider
 	securityGroupProvider *securitygroup.Provider
 	subnetProvider        *subnet.Provider
+	quotaProvider         *quota.Provider
+	notificationProvider  *notification.Provider
 	recorder              events.Recorder
+
+	mu      sync.Mutex
+	drifted map[string]driftedNodeClaim
 }
 
 func New(instanceTypeProvider *instancetype.Provider, instanceProvider *instance.Provider, recorder events.Recorder,
-	kubeClient client.Client, amiProvider *amifamily.Provider, securityGroupProvider *securitygroup.Provider, subnetProvider *subnet.Provider) *CloudProvider {
+	kubeClient client.Client, amiProvider *amifamily.Provider, securityGroupProvider *securitygroup.Provider, subnetProvider *subnet.Provider,
+	quotaProvider *quota.Provider, notificationProvider *notification.Provider) *CloudProvider {
 	return &CloudProvider{
 		instanceTypeProvider:  instanceTypeProvider,
 		instanceProvider:      instanceProvider,
@@ -86,12 +98,18 @@ func New(instanceTypeProvider *instancetype.Provider, instanceProvider *instance
 		amiProvider:           amiProvider,
 		securityGroupProvider: securityGroupProvider,
 		subnetProvider:        subnetProvider,
+		quotaProvider:         quotaProvider,
+		notificationProvider:  notificationProvider,
 		recorder:              recorder,
+		drifted:               map[string]driftedNodeClaim{},
 	}
 }
 
 // Create a machine given the constraints.
 func (c *CloudProvider) Create(ctx context.Context, nodeClaim *corev1beta1.NodeClaim) (*corev1beta1.NodeClaim, error) {
+	// The phases of the launch are traced as the children of this span
+	ctx, span := tracing.Start(ctx, "Create", attribute.String("nodeclaim", nodeClaim.Name))
+	defer span.End()
 	nodeClass, err := c.resolveNodeClassFromNodeClaim(ctx, nodeClaim)
 	if err != nil {
 		if errors.IsNotFound(err) {
@@ -99,6 +117,27 @@ func (c *CloudProvider) Create(ctx context.Context, nodeClaim *corev1beta1.NodeC
 		}
 		return nil, fmt.Errorf("resolving node class, %w", err)
 	}
+	// Launching against a NodeClass that is being deleted would hold up its deletion indefinitely
+	if !nodeClass.DeletionTimestamp.IsZero() {
+		return nil, fmt.Errorf("resolving node class, %s is being deleted", nodeClass.Name)
+	}
+	// Record the NodeClass startup taints on the NodeClaim, which is patched after launch, so that they're handled as
+	// startup taints through registration and initialization rather than blocking scheduling to the node
+	nodeClaim.Spec.StartupTaints = utils.MergeTaints(nodeClaim.Spec.StartupTaints, lo.Reject(nodeClass.Spec.StartupTaints, func(taint v1.Taint, _ int) bool {
+		return lo.ContainsBy(nodeClaim.Spec.Taints, func(t v1.Taint) bool { return t.MatchTaint(&taint) })
+	}))
+	// The SSM agent taint is removed by the ssmagent controller once the instance is registered with Systems Manager
+	if lo.FromPtr(nodeClass.Spec.RequireSSMAgent) {
+		nodeClaim.Spec.StartupTaints = utils.MergeTaints(nodeClaim.Spec.StartupTaints, []v1.Taint{{
+			Key:    v1beta1.SSMAgentNotRegisteredTaintKey,
+			Effect: v1.TaintEffectNoSchedule,
+		}})
+	}
+	// Requirements that the AMIFamily can never satisfy would otherwise surface as no instance types being available
+	if err := amifamily.ValidateRequirements(nodeClass, nodeClaim); err != nil {
+		c.recorder.Publish(cloudproviderevents.NodeClaimIncompatibleAMIFamily(nodeClaim, err))
+		return nil, fmt.Errorf("resolving amis, %w", err)
+	}
 	instanceTypes, err := c.resolveInstanceTypes(ctx, nodeClaim, nodeClass)
 	if err != nil {
 		return nil, fmt.Errorf("resolving instance types, %w", err)
@@ -106,18 +145,127 @@ func (c *CloudProvider) Create(ctx context.Context, nodeClaim *corev1beta1.NodeC
 	if len(instanceTypes) == 0 {
 		return nil, cloudprovider.NewInsufficientCapacityError(fmt.Errorf("all requested instance types were unavailable during launch"))
 	}
+	instanceTypes = c.quotaProvider.Filter(ctx, nodePoolNameFromNodeClaim(nodeClaim), instanceTypes)
+	if len(instanceTypes) == 0 {
+		c.recorder.Publish(cloudproviderevents.NodeClaimQuotaExceeded(nodeClaim))
+		return nil, cloudprovider.NewInsufficientCapacityError(fmt.Errorf("all requested instance types would exceed the account's EC2 vCPU quotas"))
+	}
 	instance, err := c.instanceProvider.Create(ctx, nodeClass, nodeClaim, instanceTypes)
+	if onDemandNodeClaim, ok := onDemandFallback(nodeClaim, instanceTypes, err); ok {
+		// Spot capacity was unavailable, so the launch is retried with on-demand capacity rather than failing the
+		// NodeClaim and waiting for the next scheduling loop to launch on-demand capacity
+		logging.FromContext(ctx).Debugf("launching on-demand capacity, %s", err)
+		c.recorder.Publish(cloudproviderevents.NodeClaimFellBackToOnDemand(nodeClaim, err))
+		instance, err = c.instanceProvider.Create(ctx, nodeClass, onDemandNodeClaim, instanceTypes)
+	}
 	if err != nil {
+		if reason, ok := awserrors.LaunchFailureReason(err); ok {
+			c.recorder.Publish(cloudproviderevents.NodeClaimLaunchFailed(nodeClaim, reason, err))
+			nodeClaim.StatusConditions().MarkFalse(v1beta1.NodeClaimLaunchSucceeded, reason, "%s", err)
+		}
 		return nil, fmt.Errorf("creating instance, %w", err)
 	}
+	if nodeClaim.StatusConditions().GetCondition(v1beta1.NodeClaimLaunchSucceeded) != nil {
+		nodeClaim.StatusConditions().MarkTrue(v1beta1.NodeClaimLaunchSucceeded)
+	}
 	instanceType, _ := lo.Find(instanceTypes, func(i *cloudprovider.InstanceType) bool {
 		return i.Name == instance.Type
 	})
+	if instanceType != nil {
+		c.quotaProvider.UpdateInflightVCPUs(instance.CapacityType, instanceType)
+	}
 	nc := c.instanceToNodeClaim(instance, instanceType)
+	if size, ok := amifamily.LaunchedEphemeralStorage(nodeClass, nodeClaim.Spec.Resources.Requests); ok {
+		withEphemeralStorage(nc, size)
+	}
 	nc.Annotations = lo.Assign(nc.Annotations, nodeclassutil.HashAnnotation(nodeClass))
+	if nodeClass.Status.UserDataHash != "" {
+		nc.Annotations[lo.Ternary(nodeClaim.IsMachine, v1alpha1.AnnotationUserDataHash, v1beta1.AnnotationUserDataHash)] = nodeClass.Status.UserDataHash
+	}
+	if price, ok := launchPrice(instance, instanceType); ok {
+		nc.Annotations = lo.Assign(nc.Annotations, priceAnnotations(nodeClaim, instance, price))
+		c.recorder.Publish(cloudproviderevents.NodeClaimPriceEstimated(nodeClaim, instance.Type, instance.CapacityType, instance.Zone, price))
+	}
 	return nc, nil
 }
 
+// withEphemeralStorage replaces the ephemeral-storage that the instance type advertises, which is the largest volume
+// that may be launched, with the size of the volume that was launched. Allocatable is reduced by the same amount, so
+// that pods that the volume can't fit aren't scheduled to the NodeClaim before its node reports its own capacity.
+func withEphemeralStorage(nodeClaim *corev1beta1.NodeClaim, size resource.Quantity) {
+	capacity, ok := nodeClaim.Status.Capacity[v1.ResourceEphemeralStorage]
+	if !ok {
+		return
+	}
+	capacity.Sub(size)
+	nodeClaim.Status.Capacity[v1.ResourceEphemeralStorage] = size
+	if allocatable, ok := nodeClaim.Status.Allocatable[v1.ResourceEphemeralStorage]; ok {
+		allocatable.Sub(capacity)
+		nodeClaim.Status.Allocatable[v1.ResourceEphemeralStorage] = lo.Ternary(allocatable.Sign() < 0, resource.Quantity{}, allocatable)
+	}
+}
+
+// launchPrice returns the estimated hourly price of the offering that the instance was launched into
+func launchPrice(i *instance.Instance, instanceType *cloudprovider.InstanceType) (float64, bool) {
+	if instanceType == nil {
+		return 0, false
+	}
+	offering, ok := lo.Find(instanceType.Offerings, func(o cloudprovider.Offering) bool {
+		return o.Zone == i.Zone && o.CapacityType == i.CapacityType
+	})
+	return offering.Price, ok
+}
+
+// priceAnnotations records the estimated hourly price of the instance, along with the capacity type and zone that it
+// was estimated for, so that the cost of a NodeClaim can be attributed after the fact
+func priceAnnotations(nodeClaim *corev1beta1.NodeClaim, i *instance.Instance, price float64) map[string]string {
+	if nodeClaim.IsMachine {
+		return map[string]string{
+			v1alpha1.AnnotationPriceEstimate:             strconv.FormatFloat(price, 'f', -1, 64),
+			v1alpha1.AnnotationPriceEstimateCapacityType: i.CapacityType,
+			v1alpha1.AnnotationPriceEstimateZone:         i.Zone,
+		}
+	}
+	return map[string]string{
+		v1beta1.AnnotationPriceEstimate:             strconv.FormatFloat(price, 'f', -1, 64),
+		v1beta1.AnnotationPriceEstimateCapacityType: i.CapacityType,
+		v1beta1.AnnotationPriceEstimateZone:         i.Zone,
+	}
+}
+
+// onDemandFallback returns a copy of the NodeClaim that only allows on-demand capacity if the NodeClaim's launch failed
+// with insufficient spot capacity, and the NodeClaim allows on-demand capacity that one of the instance types offers.
+func onDemandFallback(nodeClaim *corev1beta1.NodeClaim, instanceTypes []*cloudprovider.InstanceType, err error) (*corev1beta1.NodeClaim, bool) {
+	if !cloudprovider.IsInsufficientCapacityError(err) {
+		return nil, false
+	}
+	requirements := scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...)
+	if !requirements.Get(corev1beta1.CapacityTypeLabelKey).Has(corev1beta1.CapacityTypeSpot) ||
+		!requirements.Get(corev1beta1.CapacityTypeLabelKey).Has(corev1beta1.CapacityTypeOnDemand) {
+		return nil, false
+	}
+	// Spot is only launched when one of the instance types offers it, otherwise the launch was already on-demand
+	offered := func(capacityType string) bool {
+		return lo.ContainsBy(instanceTypes, func(it *cloudprovider.InstanceType) bool {
+			return lo.ContainsBy(it.Offerings.Available(), func(o cloudprovider.Offering) bool {
+				return o.CapacityType == capacityType && requirements.Get(v1.LabelTopologyZone).Has(o.Zone)
+			})
+		})
+	}
+	if !offered(corev1beta1.CapacityTypeSpot) || !offered(corev1beta1.CapacityTypeOnDemand) {
+		return nil, false
+	}
+	onDemandNodeClaim := nodeClaim.DeepCopy()
+	onDemandNodeClaim.Spec.Requirements = append(lo.Reject(onDemandNodeClaim.Spec.Requirements, func(r v1.NodeSelectorRequirement, _ int) bool {
+		return r.Key == corev1beta1.CapacityTypeLabelKey
+	}), v1.NodeSelectorRequirement{
+		Key:      corev1beta1.CapacityTypeLabelKey,
+		Operator: v1.NodeSelectorOpIn,
+		Values:   []string{corev1beta1.CapacityTypeOnDemand},
+	})
+	return onDemandNodeClaim, true
+}
+
 // Link adds a tag to the cloudprovider machine to tell the cloudprovider that it's now owned by a Machine
 func (c *CloudProvider) Link(ctx context.Context, nodeClaim *corev1beta1.NodeClaim) error {
 	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With(lo.Ternary(nodeClaim.IsMachine, "machine", "nodeclaim"), nodeClaim.Name))
@@ -195,29 +343,30 @@ func (c *CloudProvider) Delete(ctx context.Context, nodeClaim *corev1beta1.NodeC
 		return fmt.Errorf("getting instance ID, %w", err)
 	}
 	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("id", id))
-	return c.instanceProvider.Delete(ctx, id)
+	stop := c.shouldStop(ctx, nodeClaim)
+	c.notifyTermination(ctx, nodeClaim, id, stop)
+	if stop {
+		err = c.instanceProvider.Stop(ctx, nodeClaim, id)
+	} else {
+		err = c.instanceProvider.Delete(ctx, id)
+	}
+	if err == nil || cloudprovider.IsNodeClaimNotFoundError(err) {
+		c.recordTermination(ctx, nodeClaim)
+		c.recordDrift(ctx, nodeClaim, "", "")
+	}
+	return err
 }
 
 func (c *CloudProvider) IsDrifted(ctx context.Context, nodeClaim *corev1beta1.NodeClaim) (cloudprovider.DriftReason, error) {
-	// Not needed when GetInstanceTypes removes nodepool dependency
-	nodePool, err := nodeclaimutil.Owner(ctx, c.kubeClient, nodeClaim)
-	if err != nil {
-		return "", client.IgnoreNotFound(fmt.Errorf("resolving owner, %w", err))
-	}
-	if nodePool.Spec.Template.Spec.NodeClass == nil {
-		return "", nil
-	}
-	nodeClass, err := c.resolveNodeClassFromNodePool(ctx, nodePool)
-	if err != nil {
-		if errors.IsNotFound(err) {
-			c.recorder.Publish(cloudproviderevents.NodePoolFailedToResolveNodeClass(nodePool))
-		}
-		return "", client.IgnoreNotFound(fmt.Errorf("resolving node class, %w", err))
+	nodePool, nodeClass, err := c.resolveDriftOwners(ctx, nodeClaim)
+	if err != nil || nodeClass == nil {
+		return "", err
 	}
 	driftReason, err := c.isNodeClassDrifted(ctx, nodeClaim, nodePool, nodeClass)
 	if err != nil {
 		return "", err
 	}
+	c.recordDrift(ctx, nodeClaim, nodeClass.Name, driftReason)
 	return driftReason, nil
 }
 
@@ -244,6 +393,12 @@ func (c *CloudProvider) resolveNodeClassFromNodeClaim(ctx context.Context, nodeC
 	return nodeClass, nil
 }
 
+// ResolveNodeClass returns the NodeClass of the NodePool, which is converted from the AWSNodeTemplate or provider of
+// a Provisioner
+func (c *CloudProvider) ResolveNodeClass(ctx context.Context, nodePool *corev1beta1.NodePool) (*v1beta1.NodeClass, error) {
+	return c.resolveNodeClassFromNodePool(ctx, nodePool)
+}
+
 func (c *CloudProvider) resolveNodeClassFromNodePool(ctx context.Context, nodePool *corev1beta1.NodePool) (*v1beta1.NodeClass, error) {
 	// TODO @joinnis: Remove this handling for Provisioner resolution when we remove v1alpha5
 	if nodePool.IsProvisioner {
@@ -333,6 +488,10 @@ func (c *CloudProvider) resolveNodePoolFromInstance(ctx context.Context, instanc
 	}
 }
 
+func nodePoolNameFromNodeClaim(nodeClaim *corev1beta1.NodeClaim) string {
+	return lo.Ternary(nodeClaim.IsMachine, nodeClaim.Labels[v1alpha5.ProvisionerNameLabelKey], nodeClaim.Labels[corev1beta1.NodePoolLabelKey])
+}
+
 func (c *CloudProvider) instanceToNodeClaim(i *instance.Instance, instanceType *cloudprovider.InstanceType) *corev1beta1.NodeClaim {
 	nodeClaim := &corev1beta1.NodeClaim{}
 	labels := map[string]string{}
@@ -348,14 +507,31 @@ func (c *CloudProvider) instanceToNodeClaim(i *instance.Instance, instanceType *
 		nodeClaim.Status.Allocatable = functional.FilterMap(instanceType.Allocatable(), func(_ v1.ResourceName, v resource.Quantity) bool { return !resources.IsZero(v) })
 	}
 	labels[v1.LabelTopologyZone] = i.Zone
+	if zoneID, ok := c.instanceTypeProvider.ZoneID(i.Zone); ok {
+		labels[v1beta1.LabelTopologyZoneID] = zoneID
+	}
 	labels[corev1beta1.CapacityTypeLabelKey] = i.CapacityType
 	if v, ok := i.Tags[v1alpha5.ProvisionerNameLabelKey]; ok {
 		labels[v1alpha5.ProvisionerNameLabelKey] = v
 		nodeClaim.IsMachine = true
 	}
+	if v, ok := i.Tags[corev1beta1.NodePoolLabelKey]; ok {
+		labels[corev1beta1.NodePoolLabelKey] = v
+	}
 	if v, ok := i.Tags[corev1beta1.ManagedByAnnotationKey]; ok {
 		annotations[corev1beta1.ManagedByAnnotationKey] = v
 	}
+	for _, hashKey := range lo.Ternary(nodeClaim.IsMachine,
+		[]string{v1alpha1.AnnotationNodeTemplateHash, v1alpha1.AnnotationNodeTemplateHashWithoutTags},
+		[]string{v1beta1.AnnotationNodeClassHash, v1beta1.AnnotationNodeClassHashWithoutTags}) {
+		if v, ok := i.Tags[hashKey]; ok {
+			annotations[hashKey] = v
+		}
+	}
+	// Instances that were stopped by stop-based consolidation keep the tag until they're started again
+	if v, ok := i.Tags[v1beta1.AnnotationStoppedAt]; ok && (i.State == ec2.InstanceStateNameStopping || i.State == ec2.InstanceStateNameStopped) {
+		annotations[v1beta1.AnnotationStoppedAt] = v
+	}
 	nodeClaim.Labels = labels
 	nodeClaim.Annotations = annotations
 	nodeClaim.CreationTimestamp = metav1.Time{Time: i.LaunchTime}
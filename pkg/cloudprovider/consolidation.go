@@ -0,0 +1,72 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"math"
+
+	"github.com/samber/lo"
+
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter/pkg/apis/settings"
+)
+
+// ConsolidationPrice returns the price that replacements are compared against when consolidation considers replacing
+// a node that runs on an offering with the given price. The price is lowered by the larger of the consolidation price
+// thresholds, so that a node is only replaced by offerings that are cheaper by more than the thresholds.
+func ConsolidationPrice(ctx context.Context, price float64) float64 {
+	threshold := math.Max(settings.FromContext(ctx).ConsolidationPriceThreshold, price*settings.FromContext(ctx).ConsolidationPriceThresholdPercent)
+	return math.Max(price-threshold, 0)
+}
+
+// ConsolidationCloudProvider decorates the CloudProvider of karpenter-core's controllers so that consolidation compares
+// the nodes that it considers replacing against their ConsolidationPrice. karpenter-core prices a node with the first
+// offering of its instance type that matches the node's capacity type and zone, regardless of its availability, while
+// replacements, launches and the ordering of instance types only use available offerings. Each offering is preceded by
+// an unavailable copy at its consolidation price, so the thresholds only apply to the price of the node and the
+// offerings keep their actual prices everywhere else.
+type ConsolidationCloudProvider struct {
+	cloudprovider.CloudProvider
+}
+
+func NewConsolidationCloudProvider(cloudProvider cloudprovider.CloudProvider) *ConsolidationCloudProvider {
+	return &ConsolidationCloudProvider{CloudProvider: cloudProvider}
+}
+
+func (c *ConsolidationCloudProvider) GetInstanceTypes(ctx context.Context, nodePool *corev1beta1.NodePool) ([]*cloudprovider.InstanceType, error) {
+	instanceTypes, err := c.CloudProvider.GetInstanceTypes(ctx, nodePool)
+	if err != nil {
+		return nil, err
+	}
+	if settings.FromContext(ctx).ConsolidationPriceThreshold == 0 && settings.FromContext(ctx).ConsolidationPriceThresholdPercent == 0 {
+		return instanceTypes, nil
+	}
+	// The instance types are cached by the provider, so the offerings are added to copies
+	return lo.Map(instanceTypes, func(it *cloudprovider.InstanceType, _ int) *cloudprovider.InstanceType {
+		return &cloudprovider.InstanceType{
+			Name:         it.Name,
+			Requirements: it.Requirements,
+			Offerings: append(lo.Map(it.Offerings, func(o cloudprovider.Offering, _ int) cloudprovider.Offering {
+				o.Price = ConsolidationPrice(ctx, o.Price)
+				o.Available = false
+				return o
+			}), it.Offerings...),
+			Capacity: it.Capacity,
+			Overhead: it.Overhead,
+		}
+	}), nil
+}
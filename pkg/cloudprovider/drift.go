@@ -17,33 +17,146 @@ package cloudprovider
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/samber/lo"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/logging"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
 	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	nodeclaimutil "github.com/aws/karpenter-core/pkg/utils/nodeclaim"
 	"github.com/aws/karpenter-core/pkg/utils/sets"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	cloudproviderevents "github.com/aws/karpenter/pkg/cloudprovider/events"
 	"github.com/aws/karpenter/pkg/providers/amifamily"
 	"github.com/aws/karpenter/pkg/providers/instance"
 	"github.com/aws/karpenter/pkg/utils"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
 )
 
 const (
 	AMIDrift           cloudprovider.DriftReason = "AMIDrift"
+	AMIMaxAgeDrift     cloudprovider.DriftReason = "AMIMaxAgeDrift"
 	SubnetDrift        cloudprovider.DriftReason = "SubnetDrift"
 	SecurityGroupDrift cloudprovider.DriftReason = "SecurityGroupDrift"
 	NodeTemplateDrift  cloudprovider.DriftReason = "NodeTemplateDrift"
+	UserDataDrift      cloudprovider.DriftReason = "UserDataDrift"
 )
 
+// driftedNodeClaim is the drift that was last detected for a NodeClaim
+type driftedNodeClaim struct {
+	nodeClass string
+	reason    cloudprovider.DriftReason
+}
+
+// recordDrift tracks the drift that was detected for the NodeClaim. The first time that the NodeClaim is found to be
+// drifted for a reason, the drift is counted and an event is published to its Node. The number of NodeClaims that are
+// drifted from each NodeClass is updated as NodeClaims become drifted, stop being drifted or are deleted.
+func (c *CloudProvider) recordDrift(ctx context.Context, nodeClaim *corev1beta1.NodeClaim, nodeClass string, reason cloudprovider.DriftReason) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previous, wasDrifted := c.drifted[nodeClaim.Name]
+	if reason == "" {
+		if !wasDrifted {
+			return
+		}
+		delete(c.drifted, nodeClaim.Name)
+	} else {
+		c.drifted[nodeClaim.Name] = driftedNodeClaim{nodeClass: nodeClass, reason: reason}
+		if !wasDrifted || previous.reason != reason {
+			DriftDetected.WithLabelValues(nodeClass, string(reason)).Inc()
+			c.publishNodeDrifted(ctx, nodeClaim, reason)
+		}
+	}
+	for _, name := range lo.Uniq(lo.Compact([]string{previous.nodeClass, nodeClass})) {
+		DriftedNodeClaims.WithLabelValues(name).Set(float64(lo.CountBy(lo.Values(c.drifted), func(d driftedNodeClaim) bool {
+			return d.nodeClass == name
+		})))
+	}
+}
+
+func (c *CloudProvider) publishNodeDrifted(ctx context.Context, nodeClaim *corev1beta1.NodeClaim, reason cloudprovider.DriftReason) {
+	if nodeClaim.Status.NodeName == "" {
+		return
+	}
+	node := &v1.Node{}
+	if err := c.kubeClient.Get(ctx, types.NamespacedName{Name: nodeClaim.Status.NodeName}, node); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			logging.FromContext(ctx).Errorf("getting node, %s", err)
+		}
+		return
+	}
+	c.recorder.Publish(cloudproviderevents.NodeDrifted(node, string(reason)))
+}
+
+// DriftDetails is the drift of a NodeClaim that's recorded by the drift controller. IsDrifted doesn't record it, so
+// that checking a NodeClaim for drift doesn't have side effects.
+type DriftDetails struct {
+	NodeClass *v1beta1.NodeClass
+	Instance  *instance.Instance
+	// ResolvedAMI is the AMI that the NodeClaim would be launched with today, if it's drifted from the AMI of its instance
+	ResolvedAMI *amifamily.AMI
+	// TagsDrifted is true if only the tags of the NodeClass changed since the NodeClaim was launched and the drift
+	// policy of the tags is InPlace, so the tags of the instance should be updated in place
+	TagsDrifted bool
+}
+
+// DriftDetails returns the drift of the NodeClaim that should be recorded, or nil if the NodeClaim doesn't have a NodeClass
+func (c *CloudProvider) DriftDetails(ctx context.Context, nodeClaim *corev1beta1.NodeClaim) (*DriftDetails, error) {
+	nodePool, nodeClass, err := c.resolveDriftOwners(ctx, nodeClaim)
+	if err != nil || nodeClass == nil {
+		return nil, err
+	}
+	instance, err := c.getInstance(ctx, nodeClaim.Status.ProviderID)
+	if err != nil {
+		return nil, err
+	}
+	_, resolvedAMI, err := c.isAMIDrifted(ctx, nodeClaim, nodePool, instance, nodeClass)
+	if err != nil {
+		return nil, fmt.Errorf("calculating ami drift, %w", err)
+	}
+	_, tagsDrifted := c.areStaticFieldsDrifted(nodeClaim, instance, nodeClass)
+	return &DriftDetails{
+		NodeClass:   nodeClass,
+		Instance:    instance,
+		ResolvedAMI: resolvedAMI,
+		TagsDrifted: tagsDrifted,
+	}, nil
+}
+
+// resolveDriftOwners returns the NodePool and the NodeClass that the drift of the NodeClaim is calculated against. The
+// NodeClass is nil if the NodePool doesn't reference one, or if either doesn't exist anymore.
+func (c *CloudProvider) resolveDriftOwners(ctx context.Context, nodeClaim *corev1beta1.NodeClaim) (*corev1beta1.NodePool, *v1beta1.NodeClass, error) {
+	// Not needed when GetInstanceTypes removes nodepool dependency
+	nodePool, err := nodeclaimutil.Owner(ctx, c.kubeClient, nodeClaim)
+	if err != nil {
+		return nil, nil, client.IgnoreNotFound(fmt.Errorf("resolving owner, %w", err))
+	}
+	if nodePool.Spec.Template.Spec.NodeClass == nil {
+		return nodePool, nil, nil
+	}
+	nodeClass, err := c.resolveNodeClassFromNodePool(ctx, nodePool)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			c.recorder.Publish(cloudproviderevents.NodePoolFailedToResolveNodeClass(nodePool))
+		}
+		return nil, nil, client.IgnoreNotFound(fmt.Errorf("resolving node class, %w", err))
+	}
+	return nodePool, nodeClass, nil
+}
+
 func (c *CloudProvider) isNodeClassDrifted(ctx context.Context, nodeClaim *corev1beta1.NodeClaim, nodePool *corev1beta1.NodePool, nodeClass *v1beta1.NodeClass) (cloudprovider.DriftReason, error) {
 	instance, err := c.getInstance(ctx, nodeClaim.Status.ProviderID)
 	if err != nil {
 		return "", err
 	}
-	amiDrifted, err := c.isAMIDrifted(ctx, nodeClaim, nodePool, instance, nodeClass)
+	amiDrifted, _, err := c.isAMIDrifted(ctx, nodeClaim, nodePool, instance, nodeClass)
 	if err != nil {
 		return "", fmt.Errorf("calculating ami drift, %w", err)
 	}
@@ -55,42 +168,66 @@ func (c *CloudProvider) isNodeClassDrifted(ctx context.Context, nodeClaim *corev
 	if err != nil {
 		return "", fmt.Errorf("calculating subnet drift, %w", err)
 	}
-	drifted := lo.FindOrElse([]cloudprovider.DriftReason{amiDrifted, securitygroupDrifted, subnetDrifted, c.areStaticFieldsDrifted(nodeClaim, nodeClass)}, "", func(i cloudprovider.DriftReason) bool {
+	staticFieldsDrifted, _ := c.areStaticFieldsDrifted(nodeClaim, instance, nodeClass)
+	drifted := lo.FindOrElse([]cloudprovider.DriftReason{amiDrifted, securitygroupDrifted, subnetDrifted, staticFieldsDrifted, c.isUserDataDrifted(nodeClaim, nodeClass)}, "", func(i cloudprovider.DriftReason) bool {
 		return string(i) != ""
 	})
 	return drifted, nil
 }
 
+// isAMIDrifted returns whether the instance of the NodeClaim is drifted from its AMI, along with the AMI that the
+// NodeClaim would be launched with today if it is
 func (c *CloudProvider) isAMIDrifted(ctx context.Context, nodeClaim *corev1beta1.NodeClaim, nodePool *corev1beta1.NodePool,
-	instance *instance.Instance, nodeClass *v1beta1.NodeClass) (cloudprovider.DriftReason, error) {
+	instance *instance.Instance, nodeClass *v1beta1.NodeClass) (cloudprovider.DriftReason, *amifamily.AMI, error) {
 	instanceTypes, err := c.GetInstanceTypes(ctx, nodePool)
 	if err != nil {
-		return "", fmt.Errorf("getting instanceTypes, %w", err)
+		return "", nil, fmt.Errorf("getting instanceTypes, %w", err)
 	}
 	nodeInstanceType, found := lo.Find(instanceTypes, func(instType *cloudprovider.InstanceType) bool {
 		return instType.Name == nodeClaim.Labels[v1.LabelInstanceTypeStable]
 	})
 	if !found {
-		return "", fmt.Errorf(`finding node instance type "%s"`, nodeClaim.Labels[v1.LabelInstanceTypeStable])
+		return "", nil, fmt.Errorf(`finding node instance type "%s"`, nodeClaim.Labels[v1.LabelInstanceTypeStable])
 	}
 	if nodeClass.Spec.LaunchTemplateName != nil {
-		return "", nil
+		return "", nil, nil
 	}
 	amis, err := c.amiProvider.Get(ctx, nodeClass, &amifamily.Options{})
 	if err != nil {
-		return "", fmt.Errorf("getting amis, %w", err)
+		return "", nil, fmt.Errorf("getting amis, %w", err)
 	}
 	if len(amis) == 0 {
-		return "", fmt.Errorf("no amis exist given constraints")
+		return "", nil, fmt.Errorf("no amis exist given constraints")
+	}
+	bootSupport, err := c.amiProvider.GetBootSupport(ctx, amis)
+	if err != nil {
+		return "", nil, fmt.Errorf("getting boot support, %w", err)
 	}
-	mappedAMIs := amis.MapToInstanceTypes([]*cloudprovider.InstanceType{nodeInstanceType}, nodeClaim.IsMachine)
+	mappedAMIs := amis.MapToInstanceTypes([]*cloudprovider.InstanceType{nodeInstanceType}, nodeClaim.IsMachine, bootSupport)
 	if len(mappedAMIs) == 0 {
-		return "", fmt.Errorf("no instance types satisfy requirements of amis %v", amis)
+		return "", nil, fmt.Errorf("no instance types satisfy requirements of amis %v", amis)
 	}
-	if !lo.Contains(lo.Keys(mappedAMIs), instance.ImageID) {
-		return AMIDrift, nil
+	if lo.Contains(lo.Keys(mappedAMIs), instance.ImageID) {
+		return "", nil, nil
 	}
-	return "", nil
+	// With a maximum AMI age, a node is only drifted to a newer AMI once its own AMI has reached the age
+	if nodeClass.Spec.AMIMaxAge != nil {
+		creationDate, err := c.amiProvider.GetCreationDate(ctx, nodeClass, instance.ImageID)
+		if err != nil {
+			return "", nil, fmt.Errorf("getting ami creation date, %w", err)
+		}
+		if time.Since(creationDate) < nodeClass.Spec.AMIMaxAge.Duration {
+			return "", nil, nil
+		}
+	}
+	// The AMIs are ordered newest first, so the resolved AMI is the newest AMI that's mapped to the instance type of the node
+	resolvedAMI, found := lo.Find(amis, func(ami amifamily.AMI) bool {
+		return lo.Contains(mappedAMIs[ami.AmiID], nodeInstanceType)
+	})
+	if !found {
+		return "", nil, fmt.Errorf(`resolving ami for instance type "%s"`, nodeInstanceType.Name)
+	}
+	return lo.Ternary(nodeClass.Spec.AMIMaxAge != nil, AMIMaxAgeDrift, AMIDrift), &resolvedAMI, nil
 }
 
 func (c *CloudProvider) isSubnetDrifted(instance *instance.Instance, nodeClass *v1beta1.NodeClass) (cloudprovider.DriftReason, error) {
@@ -126,20 +263,52 @@ func (c *CloudProvider) areSecurityGroupsDrifted(ec2Instance *instance.Instance,
 	return "", nil
 }
 
-func (c *CloudProvider) areStaticFieldsDrifted(nodeClaim *corev1beta1.NodeClaim, nodeClass *v1beta1.NodeClass) cloudprovider.DriftReason {
-	var ownerHashKey string
+// areStaticFieldsDrifted compares the static-field hash of the NodeClass to the hash that the NodeClaim was launched with.
+// The hash is read from the NodeClaim's annotation, falling back to the instance's tag if the annotation isn't present.
+// When the drift policy of the tags is InPlace, the hashes without the tags are compared instead, and a NodeClaim whose
+// hash only differs in its tags isn't drifted, but its tags are returned as drifted so that they're updated in place.
+// NodeClaims that were launched before the hash without the tags was recorded are compared with the static-field hash.
+func (c *CloudProvider) areStaticFieldsDrifted(nodeClaim *corev1beta1.NodeClaim, ec2Instance *instance.Instance, nodeClass *v1beta1.NodeClass) (cloudprovider.DriftReason, bool) {
+	ownerHashKey, ownerHashWithoutTagsKey := v1beta1.AnnotationNodeClassHash, v1beta1.AnnotationNodeClassHashWithoutTags
 	if nodeClaim.IsMachine {
-		ownerHashKey = v1alpha1.AnnotationNodeTemplateHash
-	} else {
-		ownerHashKey = v1beta1.AnnotationNodeClassHash
+		ownerHashKey, ownerHashWithoutTagsKey = v1alpha1.AnnotationNodeTemplateHash, v1alpha1.AnnotationNodeTemplateHashWithoutTags
 	}
 	nodeClassHash, foundHashNodeClass := nodeClass.Annotations[ownerHashKey]
-	nodeClaimHash, foundHashNodeClaim := nodeClaim.Annotations[ownerHashKey]
-	if !foundHashNodeClass || !foundHashNodeClaim {
+	nodeClaimHash, foundHashNodeClaim := hashOf(nodeClaim, ec2Instance, ownerHashKey)
+	if !foundHashNodeClass || !foundHashNodeClaim || nodeClassHash == nodeClaimHash {
+		return "", false
+	}
+	if nodeclassutil.DriftPolicy(nodeClass, "tags") == v1beta1.DriftPolicyInPlace {
+		nodeClassHashWithoutTags, foundHashWithoutTagsNodeClass := nodeClass.Annotations[ownerHashWithoutTagsKey]
+		nodeClaimHashWithoutTags, foundHashWithoutTagsNodeClaim := hashOf(nodeClaim, ec2Instance, ownerHashWithoutTagsKey)
+		if foundHashWithoutTagsNodeClass && foundHashWithoutTagsNodeClaim {
+			if nodeClassHashWithoutTags != nodeClaimHashWithoutTags {
+				return NodeTemplateDrift, false
+			}
+			return "", true
+		}
+	}
+	return NodeTemplateDrift, false
+}
+
+// hashOf returns the hash that the NodeClaim is annotated with, falling back to the instance's tag
+func hashOf(nodeClaim *corev1beta1.NodeClaim, ec2Instance *instance.Instance, key string) (string, bool) {
+	if hash, ok := nodeClaim.Annotations[key]; ok {
+		return hash, true
+	}
+	hash, ok := ec2Instance.Tags[key]
+	return hash, ok
+}
+
+// isUserDataDrifted compares the hash of the UserData that the NodeClass's userDataRef references to the hash that the
+// NodeClaim was launched with
+func (c *CloudProvider) isUserDataDrifted(nodeClaim *corev1beta1.NodeClaim, nodeClass *v1beta1.NodeClass) cloudprovider.DriftReason {
+	nodeClaimHash, found := nodeClaim.Annotations[lo.Ternary(nodeClaim.IsMachine, v1alpha1.AnnotationUserDataHash, v1beta1.AnnotationUserDataHash)]
+	if !found || nodeClass.Status.UserDataHash == "" {
 		return ""
 	}
-	if nodeClassHash != nodeClaimHash {
-		return NodeTemplateDrift
+	if nodeClaimHash != nodeClass.Status.UserDataHash {
+		return UserDataDrift
 	}
 	return ""
 }
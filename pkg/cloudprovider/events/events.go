@@ -15,8 +15,11 @@ limitations under the License.
 package events
 
 import (
+	"fmt"
+
 	v1 "k8s.io/api/core/v1"
 
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
 	"github.com/aws/karpenter-core/pkg/apis/v1beta1"
 	"github.com/aws/karpenter-core/pkg/events"
 	machineutil "github.com/aws/karpenter-core/pkg/utils/machine"
@@ -58,3 +61,111 @@ func NodeClaimFailedToResolveNodeClass(nodeClaim *v1beta1.NodeClaim) events.Even
 		DedupeValues:   []string{string(nodeClaim.UID)},
 	}
 }
+
+func NodeClaimQuotaExceeded(nodeClaim *v1beta1.NodeClaim) events.Event {
+	if nodeClaim.IsMachine {
+		machine := machineutil.NewFromNodeClaim(nodeClaim)
+		return events.Event{
+			InvolvedObject: machine,
+			Type:           v1.EventTypeWarning,
+			Message:        fmt.Sprintf("Provisioner %q is constrained by EC2 vCPU service quotas, no instance type can be launched without exceeding them", nodeClaim.Labels[v1alpha5.ProvisionerNameLabelKey]),
+			DedupeValues:   []string{string(machine.UID)},
+		}
+	}
+	return events.Event{
+		InvolvedObject: nodeClaim,
+		Type:           v1.EventTypeWarning,
+		Message:        fmt.Sprintf("NodePool %q is constrained by EC2 vCPU service quotas, no instance type can be launched without exceeding them", nodeClaim.Labels[v1beta1.NodePoolLabelKey]),
+		DedupeValues:   []string{string(nodeClaim.UID)},
+	}
+}
+
+func NodeDrifted(node *v1.Node, reason string) events.Event {
+	return events.Event{
+		InvolvedObject: node,
+		Type:           v1.EventTypeNormal,
+		Reason:         "Drifted",
+		Message:        fmt.Sprintf("Node is drifted, %s", reason),
+		DedupeValues:   []string{string(node.UID), reason},
+	}
+}
+
+func NodeClaimLaunchFailed(nodeClaim *v1beta1.NodeClaim, reason string, err error) events.Event {
+	if nodeClaim.IsMachine {
+		machine := machineutil.NewFromNodeClaim(nodeClaim)
+		return events.Event{
+			InvolvedObject: machine,
+			Type:           v1.EventTypeWarning,
+			Reason:         reason,
+			Message:        fmt.Sprintf("Failed launching instance, %s", err),
+			DedupeValues:   []string{string(machine.UID), reason},
+		}
+	}
+	return events.Event{
+		InvolvedObject: nodeClaim,
+		Type:           v1.EventTypeWarning,
+		Reason:         reason,
+		Message:        fmt.Sprintf("Failed launching instance, %s", err),
+		DedupeValues:   []string{string(nodeClaim.UID), reason},
+	}
+}
+
+func NodeClaimFellBackToOnDemand(nodeClaim *v1beta1.NodeClaim, err error) events.Event {
+	if nodeClaim.IsMachine {
+		machine := machineutil.NewFromNodeClaim(nodeClaim)
+		return events.Event{
+			InvolvedObject: machine,
+			Type:           v1.EventTypeNormal,
+			Reason:         "FallbackToOnDemand",
+			Message:        fmt.Sprintf("Spot capacity is unavailable, launching on-demand capacity, %s", err),
+			DedupeValues:   []string{string(machine.UID)},
+		}
+	}
+	return events.Event{
+		InvolvedObject: nodeClaim,
+		Type:           v1.EventTypeNormal,
+		Reason:         "FallbackToOnDemand",
+		Message:        fmt.Sprintf("Spot capacity is unavailable, launching on-demand capacity, %s", err),
+		DedupeValues:   []string{string(nodeClaim.UID)},
+	}
+}
+
+func NodeClaimIncompatibleAMIFamily(nodeClaim *v1beta1.NodeClaim, err error) events.Event {
+	if nodeClaim.IsMachine {
+		machine := machineutil.NewFromNodeClaim(nodeClaim)
+		return events.Event{
+			InvolvedObject: machine,
+			Type:           v1.EventTypeWarning,
+			Reason:         "IncompatibleAMIFamily",
+			Message:        fmt.Sprintf("Machine's requirements can never be satisfied by its AWSNodeTemplate's AMIs, %s", err),
+			DedupeValues:   []string{string(machine.UID)},
+		}
+	}
+	return events.Event{
+		InvolvedObject: nodeClaim,
+		Type:           v1.EventTypeWarning,
+		Reason:         "IncompatibleAMIFamily",
+		Message:        fmt.Sprintf("NodeClaim's requirements can never be satisfied by its NodeClass's AMIs, %s", err),
+		DedupeValues:   []string{string(nodeClaim.UID)},
+	}
+}
+
+func NodeClaimPriceEstimated(nodeClaim *v1beta1.NodeClaim, instanceType, capacityType, zone string, price float64) events.Event {
+	if nodeClaim.IsMachine {
+		machine := machineutil.NewFromNodeClaim(nodeClaim)
+		return events.Event{
+			InvolvedObject: machine,
+			Type:           v1.EventTypeNormal,
+			Reason:         "PriceEstimated",
+			Message:        fmt.Sprintf("Launched %s %s instance in %s at an estimated $%.4f/hour", capacityType, instanceType, zone, price),
+			DedupeValues:   []string{string(machine.UID)},
+		}
+	}
+	return events.Event{
+		InvolvedObject: nodeClaim,
+		Type:           v1.EventTypeNormal,
+		Reason:         "PriceEstimated",
+		Message:        fmt.Sprintf("Launched %s %s instance in %s at an estimated $%.4f/hour", capacityType, instanceType, zone, price),
+		DedupeValues:   []string{string(nodeClaim.UID)},
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/aws/karpenter-core/pkg/metrics"
+)
+
+const (
+	cloudProviderSubsystem = "cloudprovider"
+
+	nodePoolLabel          = "nodepool"
+	capacityTypeLabel      = "capacity_type"
+	terminationReasonLabel = "reason"
+	nodeClassLabel         = "nodeclass"
+	driftReasonLabel       = "reason"
+)
+
+var (
+	InstanceLifetime = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "instance_lifetime_seconds",
+			Help:      "Time between the creation of a NodeClaim and the termination of its instance, labeled by nodepool, capacity type and termination reason.",
+			// 5 minutes up to ~28 days
+			Buckets: prometheus.ExponentialBuckets(300, 2, 14),
+		},
+		[]string{
+			nodePoolLabel,
+			capacityTypeLabel,
+			terminationReasonLabel,
+		})
+	InstanceTerminations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "instance_terminations_total",
+			Help:      "Number of instances terminated, labeled by nodepool, capacity type and termination reason.",
+		},
+		[]string{
+			nodePoolLabel,
+			capacityTypeLabel,
+			terminationReasonLabel,
+		})
+	DriftDetected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "drift_detected_total",
+			Help:      "Number of times drift was first detected for a NodeClaim, labeled by nodeclass and drift reason.",
+		},
+		[]string{
+			nodeClassLabel,
+			driftReasonLabel,
+		})
+	DriftedNodeClaims = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "drifted_nodeclaims",
+			Help:      "Number of NodeClaims that are currently drifted, labeled by nodeclass.",
+		},
+		[]string{
+			nodeClassLabel,
+		})
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(InstanceLifetime, InstanceTerminations, DriftDetected, DriftedNodeClaims)
+}
@@ -16,8 +16,12 @@ package cloudprovider_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
@@ -27,10 +31,12 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	v1 "k8s.io/api/core/v1"
 	clock "k8s.io/utils/clock/testing"
 
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
 	"github.com/aws/aws-sdk-go/service/ssm"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -42,14 +48,20 @@ import (
 	"github.com/aws/karpenter/pkg/apis"
 	"github.com/aws/karpenter/pkg/apis/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
 	"github.com/aws/karpenter/pkg/test"
 
 	"github.com/aws/karpenter/pkg/cloudprovider"
 
+	awserrors "github.com/aws/karpenter/pkg/errors"
 	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/providers/amifamily"
+	"github.com/aws/karpenter/pkg/providers/notification"
+	"github.com/aws/karpenter/pkg/providers/quota"
 
 	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
 	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
 	corecloudproivder "github.com/aws/karpenter-core/pkg/cloudprovider"
 	"github.com/aws/karpenter-core/pkg/controllers/provisioning"
 	"github.com/aws/karpenter-core/pkg/controllers/state"
@@ -90,7 +102,8 @@ var _ = BeforeSuite(func() {
 	fakeClock = clock.NewFakeClock(time.Now())
 	recorder = events.NewRecorder(&record.FakeRecorder{})
 	cloudProvider = cloudprovider.New(awsEnv.InstanceTypesProvider, awsEnv.InstanceProvider, recorder,
-		env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider)
+		env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider, awsEnv.QuotaProvider,
+		awsEnv.NotificationProvider)
 	cluster = state.NewCluster(fakeClock, env.Client, cloudProvider)
 	prov = provisioning.NewProvisioner(env.Client, env.KubernetesInterface.CoreV1(), recorder, cloudProvider, cluster)
 })
@@ -166,6 +179,26 @@ var _ = Describe("CloudProvider", func() {
 		Expect(corecloudproivder.IsInsufficientCapacityError(err)).To(BeTrue())
 		Expect(cloudProviderMachine).To(BeNil())
 	})
+	It("should return an ICE error when every instance type would exceed the account's vCPU quotas", func() {
+		awsEnv.ServiceQuotasAPI.ListServiceQuotasOutput.Set(&servicequotas.ListServiceQuotasOutput{
+			Quotas: lo.Flatten(lo.Map(lo.Values(quota.QuotaCodes), func(codes map[quota.Bucket]string, _ int) []*servicequotas.ServiceQuota {
+				return lo.Map(lo.Values(codes), func(code string, _ int) *servicequotas.ServiceQuota { return fake.NewServiceQuota(code, 0) })
+			})),
+		})
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+		cloudProviderMachine, err := cloudProvider.Create(ctx, nodeclaimutil.New(machine))
+		Expect(corecloudproivder.IsInsufficientCapacityError(err)).To(BeTrue())
+		Expect(cloudProviderMachine).To(BeNil())
+	})
+	It("should launch when the account's vCPU quotas have headroom", func() {
+		awsEnv.ServiceQuotasAPI.ListServiceQuotasOutput.Set(&servicequotas.ListServiceQuotasOutput{
+			Quotas: []*servicequotas.ServiceQuota{fake.NewServiceQuota("L-1216C47A", 1024), fake.NewServiceQuota("L-34B43A08", 1024)},
+		})
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+		cloudProviderMachine, err := cloudProvider.Create(ctx, nodeclaimutil.New(machine))
+		Expect(err).To(BeNil())
+		Expect(cloudProviderMachine).ToNot(BeNil())
+	})
 	It("should return AWSNodetemplate Hash on the machine", func() {
 		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
 		cloudProviderMachine, err := cloudProvider.Create(ctx, nodeclaimutil.New(machine))
@@ -174,6 +207,189 @@ var _ = Describe("CloudProvider", func() {
 		_, ok := cloudProviderMachine.ObjectMeta.Annotations[v1alpha1.AnnotationNodeTemplateHash]
 		Expect(ok).To(BeTrue())
 	})
+	It("should annotate the machine with the estimated price of its instance", func() {
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+		cloudProviderMachine, err := cloudProvider.Create(ctx, nodeclaimutil.New(machine))
+		Expect(err).To(BeNil())
+		price, err := strconv.ParseFloat(cloudProviderMachine.Annotations[v1alpha1.AnnotationPriceEstimate], 64)
+		Expect(err).To(BeNil())
+		Expect(price).To(BeNumerically(">", 0))
+		Expect(cloudProviderMachine.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationPriceEstimateCapacityType, cloudProviderMachine.Labels[v1alpha5.LabelCapacityType]))
+		Expect(cloudProviderMachine.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationPriceEstimateZone, cloudProviderMachine.Labels[v1.LabelTopologyZone]))
+	})
+	It("should tag the instance with the AWSNodeTemplate Hash", func() {
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+		_, err := cloudProvider.Create(ctx, nodeclaimutil.New(machine))
+		Expect(err).To(BeNil())
+		Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(1))
+		createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+		tag, ok := lo.Find(createFleetInput.TagSpecifications[0].Tags, func(t *ec2.Tag) bool {
+			return aws.StringValue(t.Key) == v1alpha1.AnnotationNodeTemplateHash
+		})
+		Expect(ok).To(BeTrue())
+		Expect(aws.StringValue(tag.Value)).To(Equal(nodeTemplate.Hash()))
+	})
+	It("should record the AWSNodeTemplate startup taints on the machine", func() {
+		nodeTemplate.Spec.StartupTaints = []v1.Taint{
+			{Key: "node.cilium.io/agent-not-ready", Value: "true", Effect: v1.TaintEffectNoExecute},
+			{Key: "example.com/taint", Effect: v1.TaintEffectNoSchedule},
+		}
+		machine.Spec.Taints = []v1.Taint{{Key: "example.com/taint", Value: "machine", Effect: v1.TaintEffectNoSchedule}}
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+		nodeClaim := nodeclaimutil.New(machine)
+		_, err := cloudProvider.Create(ctx, nodeClaim)
+		Expect(err).To(BeNil())
+		// taints that the machine already has aren't added again as startup taints
+		Expect(nodeClaim.Spec.StartupTaints).To(ConsistOf(v1.Taint{Key: "node.cilium.io/agent-not-ready", Value: "true", Effect: v1.TaintEffectNoExecute}))
+	})
+	It("should record the SSM agent startup taint on the machine when the AWSNodeTemplate requires the SSM agent", func() {
+		nodeTemplate.Spec.RequireSSMAgent = aws.Bool(true)
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+		nodeClaim := nodeclaimutil.New(machine)
+		_, err := cloudProvider.Create(ctx, nodeClaim)
+		Expect(err).To(BeNil())
+		Expect(nodeClaim.Spec.StartupTaints).To(ConsistOf(v1.Taint{Key: v1beta1.SSMAgentNotRegisteredTaintKey, Effect: v1.TaintEffectNoSchedule}))
+	})
+	It("should not record the SSM agent startup taint on the machine when the AWSNodeTemplate doesn't require the SSM agent", func() {
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+		nodeClaim := nodeclaimutil.New(machine)
+		_, err := cloudProvider.Create(ctx, nodeClaim)
+		Expect(err).To(BeNil())
+		Expect(nodeClaim.Spec.StartupTaints).To(BeEmpty())
+	})
+	Context("Launch Failures", func() {
+		It("should report an unauthorized launch as a failure that needs its configuration fixed", func() {
+			awsEnv.EC2API.CreateFleetBehavior.Error.Set(awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation.", nil))
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+			nodeClaim := nodeclaimutil.New(machine)
+			_, err := cloudProvider.Create(ctx, nodeClaim)
+			Expect(err).To(HaveOccurred())
+			Expect(corecloudproivder.IsInsufficientCapacityError(err)).To(BeFalse())
+			condition := nodeClaim.StatusConditions().GetCondition(v1beta1.NodeClaimLaunchSucceeded)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Reason).To(Equal(awserrors.LaunchFailureUnauthorized))
+		})
+		It("should report an invalid launch template as a failure that needs its configuration fixed", func() {
+			awsEnv.EC2API.CreateFleetBehavior.Output.Set(&ec2.CreateFleetOutput{Errors: []*ec2.CreateFleetError{{
+				ErrorCode:    aws.String("InvalidLaunchTemplateId.Malformed"),
+				ErrorMessage: aws.String("The launch template ID is malformed."),
+				LaunchTemplateAndOverrides: &ec2.LaunchTemplateAndOverridesResponse{
+					Overrides: &ec2.FleetLaunchTemplateOverrides{InstanceType: aws.String("m5.large"), AvailabilityZone: aws.String("test-zone-1a")},
+				},
+			}}})
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+			nodeClaim := nodeclaimutil.New(machine)
+			_, err := cloudProvider.Create(ctx, nodeClaim)
+			Expect(err).To(HaveOccurred())
+			Expect(corecloudproivder.IsInsufficientCapacityError(err)).To(BeFalse())
+			Expect(nodeClaim.StatusConditions().GetCondition(v1beta1.NodeClaimLaunchSucceeded).Reason).To(Equal(awserrors.LaunchFailureInvalidLaunchTemplate))
+		})
+		It("should report a spot quota failure apart from insufficient capacity", func() {
+			awsEnv.EC2API.CreateFleetBehavior.Output.Set(&ec2.CreateFleetOutput{Errors: []*ec2.CreateFleetError{
+				{
+					ErrorCode:    aws.String("MaxSpotInstanceCountExceeded"),
+					ErrorMessage: aws.String("Max spot instance count exceeded"),
+					LaunchTemplateAndOverrides: &ec2.LaunchTemplateAndOverridesResponse{
+						Overrides: &ec2.FleetLaunchTemplateOverrides{InstanceType: aws.String("m5.large"), AvailabilityZone: aws.String("test-zone-1a")},
+					},
+				},
+				{
+					ErrorCode:    aws.String("UnfulfillableCapacity"),
+					ErrorMessage: aws.String("Unable to fulfill capacity"),
+					LaunchTemplateAndOverrides: &ec2.LaunchTemplateAndOverridesResponse{
+						Overrides: &ec2.FleetLaunchTemplateOverrides{InstanceType: aws.String("m5.large"), AvailabilityZone: aws.String("test-zone-1b")},
+					},
+				},
+			}})
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+			nodeClaim := nodeclaimutil.New(machine)
+			_, err := cloudProvider.Create(ctx, nodeClaim)
+			Expect(corecloudproivder.IsInsufficientCapacityError(err)).To(BeTrue())
+			Expect(nodeClaim.StatusConditions().GetCondition(v1beta1.NodeClaimLaunchSucceeded).Reason).To(Equal(awserrors.LaunchFailureQuotaExceeded))
+		})
+		It("should report insufficient capacity when every pool is unfulfillable", func() {
+			awsEnv.EC2API.CreateFleetBehavior.Output.Set(&ec2.CreateFleetOutput{Errors: []*ec2.CreateFleetError{{
+				ErrorCode:    aws.String("UnfulfillableCapacity"),
+				ErrorMessage: aws.String("Unable to fulfill capacity"),
+				LaunchTemplateAndOverrides: &ec2.LaunchTemplateAndOverridesResponse{
+					Overrides: &ec2.FleetLaunchTemplateOverrides{InstanceType: aws.String("m5.large"), AvailabilityZone: aws.String("test-zone-1a")},
+				},
+			}}})
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+			nodeClaim := nodeclaimutil.New(machine)
+			_, err := cloudProvider.Create(ctx, nodeClaim)
+			Expect(corecloudproivder.IsInsufficientCapacityError(err)).To(BeTrue())
+			Expect(nodeClaim.StatusConditions().GetCondition(v1beta1.NodeClaimLaunchSucceeded).Reason).To(Equal(awserrors.LaunchFailureInsufficientCapacity))
+		})
+		It("should not report a launch failure when the error isn't a known failure", func() {
+			awsEnv.EC2API.CreateFleetBehavior.Error.Set(fmt.Errorf("CreateFleet synthetic error"))
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+			nodeClaim := nodeclaimutil.New(machine)
+			_, err := cloudProvider.Create(ctx, nodeClaim)
+			Expect(err).To(HaveOccurred())
+			Expect(nodeClaim.StatusConditions().GetCondition(v1beta1.NodeClaimLaunchSucceeded)).To(BeNil())
+		})
+		It("should return an incompatible error when the requirements can never be satisfied by the AMIFamily", func() {
+			nodeTemplate.Spec.AMIFamily = aws.String(v1alpha1.AMIFamilyWindows2022)
+			machine.Spec.Requirements = []v1.NodeSelectorRequirement{
+				{Key: v1.LabelOSStable, Operator: v1.NodeSelectorOpIn, Values: []string{string(v1.Windows)}},
+				{Key: v1.LabelArchStable, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.ArchitectureArm64}},
+			}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+			_, err := cloudProvider.Create(ctx, nodeclaimutil.New(machine))
+			Expect(amifamily.IsIncompatibleError(err)).To(BeTrue())
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(0))
+		})
+		It("should report a launch that succeeds after a failure", func() {
+			awsEnv.EC2API.CreateFleetBehavior.Error.Set(awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation.", nil), fake.MaxCalls(1))
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+			nodeClaim := nodeclaimutil.New(machine)
+			_, err := cloudProvider.Create(ctx, nodeClaim)
+			Expect(err).To(HaveOccurred())
+			_, err = cloudProvider.Create(ctx, nodeClaim)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(nodeClaim.StatusConditions().GetCondition(v1beta1.NodeClaimLaunchSucceeded).IsTrue()).To(BeTrue())
+		})
+	})
+	Context("On-Demand Fallback", func() {
+		BeforeEach(func() {
+			awsEnv.EC2API.InsufficientCapacityPools.Set([]fake.CapacityPool{
+				{CapacityType: v1alpha5.CapacityTypeSpot, InstanceType: "m5.large", Zone: "test-zone-1a"},
+				{CapacityType: v1alpha5.CapacityTypeSpot, InstanceType: "m5.large", Zone: "test-zone-1b"},
+				{CapacityType: v1alpha5.CapacityTypeSpot, InstanceType: "m5.large", Zone: "test-zone-1c"},
+			})
+		})
+		It("should launch on-demand capacity in the same launch when spot capacity is unavailable", func() {
+			machine.Spec.Requirements = []v1.NodeSelectorRequirement{
+				{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.large"}},
+				{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeSpot, v1alpha5.CapacityTypeOnDemand}},
+			}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+			nodeClaim := nodeclaimutil.New(machine)
+			cloudProviderNodeClaim, err := cloudProvider.Create(ctx, nodeClaim)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cloudProviderNodeClaim.Labels).To(HaveKeyWithValue(v1alpha5.LabelCapacityType, v1alpha5.CapacityTypeOnDemand))
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(2))
+			onDemandInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			spotInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(aws.StringValue(spotInput.TargetCapacitySpecification.DefaultTargetCapacityType)).To(Equal(v1alpha5.CapacityTypeSpot))
+			Expect(aws.StringValue(onDemandInput.TargetCapacitySpecification.DefaultTargetCapacityType)).To(Equal(v1alpha5.CapacityTypeOnDemand))
+			// The NodeClaim's requirements aren't changed by the fallback
+			Expect(nodeClaim.Spec.Requirements).To(ContainElement(v1.NodeSelectorRequirement{
+				Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeSpot, v1alpha5.CapacityTypeOnDemand},
+			}))
+		})
+		It("should not launch on-demand capacity when the NodeClaim only allows spot capacity", func() {
+			machine.Spec.Requirements = []v1.NodeSelectorRequirement{
+				{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.large"}},
+				{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeSpot}},
+			}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+			_, err := cloudProvider.Create(ctx, nodeclaimutil.New(machine))
+			Expect(corecloudproivder.IsInsufficientCapacityError(err)).To(BeTrue())
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(1))
+		})
+	})
 	Context("Defaulting", func() {
 		// Intent here is that if updates occur on the provisioningController, the Provisioner doesn't need to be recreated
 		It("should not set the InstanceProfile with the default if none provided in Provisioner", func() {
@@ -334,6 +550,104 @@ var _ = Describe("CloudProvider", func() {
 			Expect(err).ToNot(HaveOccurred())
 			Expect(isDrifted).To(Equal(cloudprovider.AMIDrift))
 		})
+		It("should not modify the machine when checking whether it's drifted", func() {
+			instance.ImageId = aws.String(fake.ImageID())
+			ExpectApplied(ctx, env.Client, machine)
+			isDrifted, err := cloudProvider.IsDrifted(ctx, nodeclaimutil.New(machine))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(isDrifted).To(Equal(cloudprovider.AMIDrift))
+			machine = ExpectExists(ctx, env.Client, machine)
+			Expect(machine.Annotations).ToNot(HaveKey(v1alpha1.AnnotationDriftedAMIID))
+			Expect(machine.Annotations).ToNot(HaveKey(v1alpha1.AnnotationResolvedAMIID))
+		})
+		It("should return the AMI that the machine would be launched with in the drift details", func() {
+			instance.ImageId = aws.String(fake.ImageID())
+			details, err := cloudProvider.DriftDetails(ctx, nodeclaimutil.New(machine))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(details.ResolvedAMI).ToNot(BeNil())
+			Expect(details.ResolvedAMI.AmiID).To(Equal(validAMI))
+			Expect(details.Instance.ImageID).To(Equal(aws.StringValue(instance.ImageId)))
+		})
+		Context("Drift Metrics", func() {
+			It("should count drift once when it is first detected", func() {
+				instance.ImageId = aws.String(fake.ImageID())
+				for i := 0; i < 2; i++ {
+					isDrifted, err := cloudProvider.IsDrifted(ctx, nodeclaimutil.New(machine))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(isDrifted).To(Equal(cloudprovider.AMIDrift))
+				}
+				metric, ok := FindMetricWithLabelValues("karpenter_cloudprovider_drift_detected_total", map[string]string{
+					"nodeclass": nodeTemplate.Name,
+					"reason":    string(cloudprovider.AMIDrift),
+				})
+				Expect(ok).To(BeTrue())
+				Expect(metric.GetCounter().GetValue()).To(BeNumerically("==", 1))
+			})
+			It("should track the number of drifted machines of the node template", func() {
+				instance.ImageId = aws.String(fake.ImageID())
+				isDrifted, err := cloudProvider.IsDrifted(ctx, nodeclaimutil.New(machine))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(isDrifted).To(Equal(cloudprovider.AMIDrift))
+				metric, ok := FindMetricWithLabelValues("karpenter_cloudprovider_drifted_nodeclaims", map[string]string{"nodeclass": nodeTemplate.Name})
+				Expect(ok).To(BeTrue())
+				Expect(metric.GetGauge().GetValue()).To(BeNumerically("==", 1))
+
+				instance.ImageId = aws.String(validAMI)
+				isDrifted, err = cloudProvider.IsDrifted(ctx, nodeclaimutil.New(machine))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(isDrifted).To(BeEmpty())
+				metric, ok = FindMetricWithLabelValues("karpenter_cloudprovider_drifted_nodeclaims", map[string]string{"nodeclass": nodeTemplate.Name})
+				Expect(ok).To(BeTrue())
+				Expect(metric.GetGauge().GetValue()).To(BeNumerically("==", 0))
+			})
+		})
+		Context("AMI Max Age", func() {
+			var driftedAMI string
+			BeforeEach(func() {
+				driftedAMI = fake.ImageID()
+				instance.ImageId = aws.String(driftedAMI)
+				nodeTemplate.Spec.AMIMaxAge = &metav1.Duration{Duration: 30 * 24 * time.Hour}
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+			})
+			It("should return drifted once the AMI of the node reaches the maximum age", func() {
+				awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: append(awsEnv.EC2API.DescribeImagesOutput.Clone().Images, &ec2.Image{
+					Name:         aws.String(coretest.RandomName()),
+					ImageId:      aws.String(driftedAMI),
+					Architecture: aws.String("arm64"),
+					CreationDate: aws.String(time.Now().Add(-31 * 24 * time.Hour).Format(time.RFC3339)),
+				})})
+				isDrifted, err := cloudProvider.IsDrifted(ctx, nodeclaimutil.New(machine))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(isDrifted).To(Equal(cloudprovider.AMIMaxAgeDrift))
+			})
+			It("should not return drifted before the AMI of the node reaches the maximum age", func() {
+				awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: append(awsEnv.EC2API.DescribeImagesOutput.Clone().Images, &ec2.Image{
+					Name:         aws.String(coretest.RandomName()),
+					ImageId:      aws.String(driftedAMI),
+					Architecture: aws.String("arm64"),
+					CreationDate: aws.String(time.Now().Add(-24 * time.Hour).Format(time.RFC3339)),
+				})})
+				isDrifted, err := cloudProvider.IsDrifted(ctx, nodeclaimutil.New(machine))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(isDrifted).To(BeEmpty())
+			})
+			It("should return drifted if the AMI of the node no longer exists", func() {
+				isDrifted, err := cloudProvider.IsDrifted(ctx, nodeclaimutil.New(machine))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(isDrifted).To(Equal(cloudprovider.AMIMaxAgeDrift))
+			})
+			It("should not return drifted if the AMI of the node is still selected", func() {
+				instance.ImageId = aws.String(validAMI)
+				isDrifted, err := cloudProvider.IsDrifted(ctx, nodeclaimutil.New(machine))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(isDrifted).To(BeEmpty())
+			})
+		})
+		It("should not return a resolved AMI in the drift details when the AMI is not drifted", func() {
+			details, err := cloudProvider.DriftDetails(ctx, nodeclaimutil.New(machine))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(details.ResolvedAMI).To(BeNil())
+		})
 		It("should return drifted if the subnet is not valid", func() {
 			instance.SubnetId = aws.String(fake.SubnetID())
 			isDrifted, err := cloudProvider.IsDrifted(ctx, nodeclaimutil.New(machine))
@@ -389,6 +703,34 @@ var _ = Describe("CloudProvider", func() {
 			Expect(err).ToNot(HaveOccurred())
 			Expect(isDrifted).To(Equal(cloudprovider.SecurityGroupDrift))
 		})
+		It("should return drifted if the security groups of the primary network interface do not match the AWSNodeTemplateStatus", func() {
+			instance.NetworkInterfaces = []*ec2.InstanceNetworkInterface{
+				{
+					Attachment: &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int64(0)},
+					Groups:     []*ec2.GroupIdentifier{{GroupId: aws.String(fake.SecurityGroupID())}},
+				},
+			}
+			isDrifted, err := cloudProvider.IsDrifted(ctx, nodeclaimutil.New(machine))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(isDrifted).To(Equal(cloudprovider.SecurityGroupDrift))
+		})
+		It("should not return drifted if only secondary network interfaces have other securitygroups", func() {
+			secondarySecurityGroup := fake.SecurityGroupID()
+			instance.SecurityGroups = []*ec2.GroupIdentifier{{GroupId: aws.String(validSecurityGroup)}, {GroupId: aws.String(secondarySecurityGroup)}}
+			instance.NetworkInterfaces = []*ec2.InstanceNetworkInterface{
+				{
+					Attachment: &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int64(0)},
+					Groups:     []*ec2.GroupIdentifier{{GroupId: aws.String(validSecurityGroup)}},
+				},
+				{
+					Attachment: &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int64(1)},
+					Groups:     []*ec2.GroupIdentifier{{GroupId: aws.String(secondarySecurityGroup)}},
+				},
+			}
+			isDrifted, err := cloudProvider.IsDrifted(ctx, nodeclaimutil.New(machine))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(isDrifted).To(BeEmpty())
+		})
 		It("should not return drifted if launchTemplateName is defined", func() {
 			nodeTemplate.Spec.LaunchTemplateName = aws.String("validLaunchTemplateName")
 			nodeTemplate.Spec.SecurityGroupSelector = nil
@@ -488,6 +830,280 @@ var _ = Describe("CloudProvider", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Expect(isDrifted).To(BeEmpty())
 			})
+			It("should return drifted if the annotation is not present on the machine but the instance's hash tag doesn't match", func() {
+				machine.Annotations = map[string]string{}
+				instance.Tags = []*ec2.Tag{{Key: aws.String(v1alpha1.AnnotationNodeTemplateHash), Value: aws.String("stale-hash")}}
+				ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+				isDrifted, err := cloudProvider.IsDrifted(ctx, nodeclaimutil.New(machine))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(isDrifted).To(Equal(cloudprovider.NodeTemplateDrift))
+			})
+			It("should not return drifted if the annotation is not present on the machine and the instance's hash tag matches", func() {
+				machine.Annotations = map[string]string{}
+				instance.Tags = []*ec2.Tag{{Key: aws.String(v1alpha1.AnnotationNodeTemplateHash), Value: aws.String(nodeTemplate.Hash())}}
+				ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+				isDrifted, err := cloudProvider.IsDrifted(ctx, nodeclaimutil.New(machine))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(isDrifted).To(BeEmpty())
+			})
+			Context("Tags Drift Policy", func() {
+				BeforeEach(func() {
+					nodeTemplate.Annotations = lo.Assign(nodeTemplate.Annotations, map[string]string{
+						v1alpha1.AnnotationDriftPolicy:                 "tags=InPlace",
+						v1alpha1.AnnotationNodeTemplateHashWithoutTags: nodeTemplate.HashWithoutTags(),
+					})
+					machine.Annotations = lo.Assign(machine.Annotations, map[string]string{
+						v1alpha1.AnnotationNodeTemplateHashWithoutTags: nodeTemplate.HashWithoutTags(),
+					})
+				})
+				It("should return the tags as drifted rather than return drifted if only the tags are updated", func() {
+					nodeTemplate.Spec.Tags = map[string]string{"team": "b"}
+					nodeTemplate.Annotations[v1alpha1.AnnotationNodeTemplateHash] = nodeTemplate.Hash()
+					ExpectApplied(ctx, env.Client, provisioner, nodeTemplate, machine)
+					isDrifted, err := cloudProvider.IsDrifted(ctx, nodeclaimutil.New(machine))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(isDrifted).To(BeEmpty())
+					Expect(awsEnv.EC2API.CreateTagsBehavior.CalledWithInput.Len()).To(Equal(0))
+
+					details, err := cloudProvider.DriftDetails(ctx, nodeclaimutil.New(machine))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(details.TagsDrifted).To(BeTrue())
+				})
+				It("should return drifted if fields other than the tags are updated", func() {
+					nodeTemplate.Spec.Tags = map[string]string{"team": "b"}
+					nodeTemplate.Spec.UserData = aws.String("userdata-test-2")
+					nodeTemplate.Annotations[v1alpha1.AnnotationNodeTemplateHash] = nodeTemplate.Hash()
+					nodeTemplate.Annotations[v1alpha1.AnnotationNodeTemplateHashWithoutTags] = nodeTemplate.HashWithoutTags()
+					ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+					isDrifted, err := cloudProvider.IsDrifted(ctx, nodeclaimutil.New(machine))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(isDrifted).To(Equal(cloudprovider.NodeTemplateDrift))
+					Expect(awsEnv.EC2API.CreateTagsBehavior.CalledWithInput.Len()).To(Equal(0))
+				})
+				It("should return drifted if the machine was launched without the hash without the tags", func() {
+					delete(machine.Annotations, v1alpha1.AnnotationNodeTemplateHashWithoutTags)
+					nodeTemplate.Spec.Tags = map[string]string{"team": "b"}
+					nodeTemplate.Annotations[v1alpha1.AnnotationNodeTemplateHash] = nodeTemplate.Hash()
+					ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+					isDrifted, err := cloudProvider.IsDrifted(ctx, nodeclaimutil.New(machine))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(isDrifted).To(Equal(cloudprovider.NodeTemplateDrift))
+				})
+			})
+			It("should return drifted if the userData that userDataRef references has changed", func() {
+				machine.Annotations = lo.Assign(machine.Annotations, map[string]string{v1alpha1.AnnotationUserDataHash: "stale-hash"})
+				nodeTemplate.Status.UserDataHash = "new-hash"
+				ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+				isDrifted, err := cloudProvider.IsDrifted(ctx, nodeclaimutil.New(machine))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(isDrifted).To(Equal(cloudprovider.UserDataDrift))
+			})
+			It("should not return drifted if the userData hash annotation is not present on the machine", func() {
+				nodeTemplate.Status.UserDataHash = "new-hash"
+				ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+				isDrifted, err := cloudProvider.IsDrifted(ctx, nodeclaimutil.New(machine))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(isDrifted).To(BeEmpty())
+			})
+		})
+	})
+	Context("Termination Metrics", func() {
+		var machine *v1alpha5.Machine
+		BeforeEach(func() {
+			instance := &ec2.Instance{
+				InstanceId: aws.String(fake.InstanceID()),
+				State:      &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+				Placement:  &ec2.Placement{AvailabilityZone: aws.String("test-zone-1a")},
+			}
+			awsEnv.EC2API.Instances.Store(aws.StringValue(instance.InstanceId), instance)
+			machine = coretest.Machine(v1alpha5.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: provisioner.Name,
+						v1alpha5.LabelCapacityType:       v1alpha5.CapacityTypeOnDemand,
+					},
+				},
+				Status: v1alpha5.MachineStatus{
+					ProviderID: fake.ProviderID(aws.StringValue(instance.InstanceId)),
+				},
+			})
+			ExpectApplied(ctx, env.Client, machine)
+			machine = ExpectExists(ctx, env.Client, machine)
+			machine.StatusConditions().MarkTrue(v1alpha5.MachineRegistered)
+		})
+		It("should attribute the termination to drift when the machine is drifted", func() {
+			machine.StatusConditions().MarkTrue(v1alpha5.MachineDrifted)
+			Expect(cloudProvider.Delete(ctx, nodeclaimutil.New(machine))).To(Succeed())
+			ExpectTerminationMetric(provisioner.Name, cloudprovider.TerminationReasonDrift)
+		})
+		It("should attribute the termination to the reason in the annotation", func() {
+			machine.Annotations = lo.Assign(machine.Annotations, map[string]string{v1alpha1.AnnotationTerminationReason: string(cloudprovider.TerminationReasonInterruption)})
+			Expect(cloudProvider.Delete(ctx, nodeclaimutil.New(machine))).To(Succeed())
+			ExpectTerminationMetric(provisioner.Name, cloudprovider.TerminationReasonInterruption)
+		})
+		It("should attribute the termination to the reason in the context", func() {
+			machine.StatusConditions().MarkTrue(v1alpha5.MachineExpired)
+			Expect(cloudProvider.Delete(cloudprovider.WithTerminationReason(ctx, cloudprovider.TerminationReasonConsolidation), nodeclaimutil.New(machine))).To(Succeed())
+			ExpectTerminationMetric(provisioner.Name, cloudprovider.TerminationReasonConsolidation)
+		})
+		It("should attribute the termination to health when the machine never registered", func() {
+			machine.StatusConditions().MarkFalse(v1alpha5.MachineRegistered, "", "")
+			Expect(cloudProvider.Delete(ctx, nodeclaimutil.New(machine))).To(Succeed())
+			ExpectTerminationMetric(provisioner.Name, cloudprovider.TerminationReasonHealth)
+		})
+	})
+	Context("Pre-Termination Notifications", func() {
+		var machine *v1alpha5.Machine
+		var instanceID string
+		BeforeEach(func() {
+			instance := &ec2.Instance{
+				InstanceId: aws.String(fake.InstanceID()),
+				State:      &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+				Placement:  &ec2.Placement{AvailabilityZone: aws.String("test-zone-1a")},
+			}
+			instanceID = aws.StringValue(instance.InstanceId)
+			awsEnv.EC2API.Instances.Store(instanceID, instance)
+			machine = coretest.Machine(v1alpha5.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: provisioner.Name,
+						v1alpha5.LabelCapacityType:       v1alpha5.CapacityTypeOnDemand,
+					},
+				},
+				Status: v1alpha5.MachineStatus{
+					ProviderID: fake.ProviderID(instanceID),
+					NodeName:   "test-node",
+				},
+			})
+			ExpectApplied(ctx, env.Client, machine)
+			machine = ExpectExists(ctx, env.Client, machine)
+			machine.StatusConditions().MarkTrue(v1alpha5.MachineRegistered)
+		})
+		It("should publish the instance, node and reason to the sns topic before terminating the instance", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+				PreTerminationTopicARN: lo.ToPtr("arn:aws:sns:us-west-2:111122223333:karpenter-termination"),
+			}))
+			machine.StatusConditions().MarkTrue(v1alpha5.MachineDrifted)
+			Expect(cloudProvider.Delete(ctx, nodeclaimutil.New(machine))).To(Succeed())
+
+			Expect(awsEnv.SNSAPI.PublishBehavior.Calls()).To(Equal(1))
+			input := awsEnv.SNSAPI.PublishBehavior.CalledWithInput.Pop()
+			Expect(aws.StringValue(input.TopicArn)).To(Equal("arn:aws:sns:us-west-2:111122223333:karpenter-termination"))
+			preTermination := notification.PreTermination{}
+			Expect(json.Unmarshal([]byte(aws.StringValue(input.Message)), &preTermination)).To(Succeed())
+			Expect(preTermination.InstanceID).To(Equal(instanceID))
+			Expect(preTermination.NodeName).To(Equal("test-node"))
+			Expect(preTermination.NodePool).To(Equal(provisioner.Name))
+			Expect(preTermination.Reason).To(Equal(string(cloudprovider.TerminationReasonDrift)))
+			Expect(preTermination.Action).To(Equal(notification.ActionTerminate))
+			Expect(aws.StringValue(input.MessageAttributes["reason"].StringValue)).To(Equal(string(cloudprovider.TerminationReasonDrift)))
+		})
+		It("should post the notification to the webhook", func() {
+			received := make(chan notification.PreTermination, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer GinkgoRecover()
+				preTermination := notification.PreTermination{}
+				Expect(json.NewDecoder(r.Body).Decode(&preTermination)).To(Succeed())
+				received <- preTermination
+			}))
+			defer server.Close()
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{PreTerminationWebhookURL: lo.ToPtr(server.URL)}))
+			Expect(cloudProvider.Delete(cloudprovider.WithTerminationReason(ctx, cloudprovider.TerminationReasonConsolidation), nodeclaimutil.New(machine))).To(Succeed())
+
+			var preTermination notification.PreTermination
+			Eventually(received).Should(Receive(&preTermination))
+			Expect(preTermination.InstanceID).To(Equal(instanceID))
+			Expect(preTermination.Reason).To(Equal(string(cloudprovider.TerminationReasonConsolidation)))
+		})
+		It("should only notify once when the termination is retried", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+				PreTerminationTopicARN: lo.ToPtr("arn:aws:sns:us-west-2:111122223333:karpenter-termination"),
+			}))
+			Expect(cloudProvider.Delete(ctx, nodeclaimutil.New(machine))).To(Succeed())
+			_ = cloudProvider.Delete(ctx, nodeclaimutil.New(machine))
+			Expect(awsEnv.SNSAPI.PublishBehavior.Calls()).To(Equal(1))
+		})
+		It("should terminate the instance when the notification fails", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+				PreTerminationTopicARN: lo.ToPtr("arn:aws:sns:us-west-2:111122223333:karpenter-termination"),
+			}))
+			awsEnv.SNSAPI.PublishBehavior.Error.Set(fmt.Errorf("failed"))
+			Expect(cloudProvider.Delete(ctx, nodeclaimutil.New(machine))).To(Succeed())
+			Expect(awsEnv.EC2API.TerminateInstancesBehavior.SuccessfulCalls()).To(Equal(1))
+		})
+	})
+	Context("Stop-Based Consolidation", func() {
+		var nodeClass *v1beta1.NodeClass
+		var nodeClaim *corev1beta1.NodeClaim
+		var instance *ec2.Instance
+		BeforeEach(func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{EnableStopBasedConsolidation: lo.ToPtr(true)}))
+			nodeClass = test.NodeClass(v1beta1.NodeClass{
+				Spec: v1beta1.NodeClassSpec{
+					InstanceInitiatedShutdownBehavior: aws.String(v1beta1.InstanceInitiatedShutdownBehaviorStop),
+				},
+			})
+			instance = &ec2.Instance{
+				InstanceId: aws.String(fake.InstanceID()),
+				State:      &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+				Placement:  &ec2.Placement{AvailabilityZone: aws.String("test-zone-1a")},
+			}
+			awsEnv.EC2API.Instances.Store(aws.StringValue(instance.InstanceId), instance)
+			nodeClaim = coretest.NodeClaim(corev1beta1.NodeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						corev1beta1.NodePoolLabelKey:     "default",
+						corev1beta1.CapacityTypeLabelKey: corev1beta1.CapacityTypeOnDemand,
+					},
+				},
+				Spec: corev1beta1.NodeClaimSpec{
+					NodeClass: &corev1beta1.NodeClassReference{Name: nodeClass.Name},
+				},
+				Status: corev1beta1.NodeClaimStatus{
+					ProviderID: fake.ProviderID(aws.StringValue(instance.InstanceId)),
+				},
+			})
+		})
+		It("should stop a consolidated on-demand instance", func() {
+			ExpectApplied(ctx, env.Client, nodeClass, nodeClaim)
+			nodeClaim = ExpectExists(ctx, env.Client, nodeClaim)
+			Expect(cloudProvider.Delete(cloudprovider.WithTerminationReason(ctx, cloudprovider.TerminationReasonConsolidation), nodeClaim)).To(Succeed())
+			Expect(aws.StringValue(instance.State.Name)).To(Equal(ec2.InstanceStateNameStopped))
+			Expect(instance.Tags).To(ContainElement(HaveField("Key", HaveValue(Equal(v1beta1.AnnotationStoppedAt)))))
+		})
+		It("should stop the instance when it's deleted through the node of a consolidated nodeclaim", func() {
+			nodeClaim.Annotations = lo.Assign(nodeClaim.Annotations, map[string]string{v1beta1.AnnotationTerminationReason: string(cloudprovider.TerminationReasonConsolidation)})
+			ExpectApplied(ctx, env.Client, nodeClass, nodeClaim)
+			nodeClaim = ExpectExists(ctx, env.Client, nodeClaim)
+			Expect(cloudProvider.Delete(ctx, nodeclaimutil.NewFromNode(coretest.NodeClaimLinkedNode(nodeClaim)))).To(Succeed())
+			Expect(aws.StringValue(instance.State.Name)).To(Equal(ec2.InstanceStateNameStopped))
+		})
+		It("should terminate an instance that's deleted for a reason other than consolidation", func() {
+			ExpectApplied(ctx, env.Client, nodeClass, nodeClaim)
+			nodeClaim = ExpectExists(ctx, env.Client, nodeClaim)
+			Expect(cloudProvider.Delete(cloudprovider.WithTerminationReason(ctx, cloudprovider.TerminationReasonDrift), nodeClaim)).To(Succeed())
+			Expect(aws.StringValue(instance.State.Name)).To(Equal(ec2.InstanceStateNameTerminated))
+		})
+		It("should terminate a consolidated spot instance", func() {
+			nodeClaim.Labels[corev1beta1.CapacityTypeLabelKey] = corev1beta1.CapacityTypeSpot
+			ExpectApplied(ctx, env.Client, nodeClass, nodeClaim)
+			nodeClaim = ExpectExists(ctx, env.Client, nodeClaim)
+			Expect(cloudProvider.Delete(cloudprovider.WithTerminationReason(ctx, cloudprovider.TerminationReasonConsolidation), nodeClaim)).To(Succeed())
+			Expect(aws.StringValue(instance.State.Name)).To(Equal(ec2.InstanceStateNameTerminated))
+		})
+		It("should terminate a consolidated instance when its NodeClass doesn't stop instances", func() {
+			nodeClass.Spec.InstanceInitiatedShutdownBehavior = aws.String(v1beta1.InstanceInitiatedShutdownBehaviorTerminate)
+			ExpectApplied(ctx, env.Client, nodeClass, nodeClaim)
+			nodeClaim = ExpectExists(ctx, env.Client, nodeClaim)
+			Expect(cloudProvider.Delete(cloudprovider.WithTerminationReason(ctx, cloudprovider.TerminationReasonConsolidation), nodeClaim)).To(Succeed())
+			Expect(aws.StringValue(instance.State.Name)).To(Equal(ec2.InstanceStateNameTerminated))
+		})
+		It("should terminate a consolidated instance when stop-based consolidation is disabled", func() {
+			ctx = settings.ToContext(ctx, test.Settings())
+			ExpectApplied(ctx, env.Client, nodeClass, nodeClaim)
+			nodeClaim = ExpectExists(ctx, env.Client, nodeClaim)
+			Expect(cloudProvider.Delete(cloudprovider.WithTerminationReason(ctx, cloudprovider.TerminationReasonConsolidation), nodeClaim)).To(Succeed())
+			Expect(aws.StringValue(instance.State.Name)).To(Equal(ec2.InstanceStateNameTerminated))
 		})
 	})
 	Context("Provider Backwards Compatibility", func() {
@@ -635,11 +1251,21 @@ var _ = Describe("CloudProvider", func() {
 			}
 			Expect(foundNonGPULT).To(BeTrue())
 		})
+		It("should launch instances into the subnets of the zone with the required zone ID", func() {
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod(coretest.PodOptions{NodeSelector: map[string]string{v1alpha1.LabelTopologyZoneID: "testzone1b"}})
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			node := ExpectScheduled(ctx, env.Client, pod)
+			Expect(node.Labels).To(HaveKeyWithValue(v1alpha1.LabelTopologyZoneID, "testzone1b"))
+			Expect(node.Labels).To(HaveKeyWithValue(v1.LabelTopologyZone, "test-zone-1b"))
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(fake.SubnetsFromFleetRequest(createFleetInput)).To(ConsistOf("subnet-test2"))
+		})
 		It("should launch instances into subnet with the most available IP addresses", func() {
 			awsEnv.EC2API.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
-				{SubnetId: aws.String("test-subnet-1"), AvailabilityZone: aws.String("test-zone-1a"), AvailableIpAddressCount: aws.Int64(10),
+				{SubnetId: aws.String("test-subnet-1"), AvailabilityZone: aws.String("test-zone-1a"), AvailabilityZoneId: aws.String("testzone1a"), AvailableIpAddressCount: aws.Int64(10),
 					Tags: []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("test-subnet-1")}}},
-				{SubnetId: aws.String("test-subnet-2"), AvailabilityZone: aws.String("test-zone-1a"), AvailableIpAddressCount: aws.Int64(100),
+				{SubnetId: aws.String("test-subnet-2"), AvailabilityZone: aws.String("test-zone-1a"), AvailabilityZoneId: aws.String("testzone1a"), AvailableIpAddressCount: aws.Int64(100),
 					Tags: []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("test-subnet-2")}}},
 			}})
 			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
@@ -651,9 +1277,9 @@ var _ = Describe("CloudProvider", func() {
 		})
 		It("should launch instances into subnet with the most available IP addresses in-between cache refreshes", func() {
 			awsEnv.EC2API.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
-				{SubnetId: aws.String("test-subnet-1"), AvailabilityZone: aws.String("test-zone-1a"), AvailableIpAddressCount: aws.Int64(10),
+				{SubnetId: aws.String("test-subnet-1"), AvailabilityZone: aws.String("test-zone-1a"), AvailabilityZoneId: aws.String("testzone1a"), AvailableIpAddressCount: aws.Int64(10),
 					Tags: []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("test-subnet-1")}}},
-				{SubnetId: aws.String("test-subnet-2"), AvailabilityZone: aws.String("test-zone-1a"), AvailableIpAddressCount: aws.Int64(11),
+				{SubnetId: aws.String("test-subnet-2"), AvailabilityZone: aws.String("test-zone-1a"), AvailabilityZoneId: aws.String("testzone1a"), AvailableIpAddressCount: aws.Int64(11),
 					Tags: []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("test-subnet-2")}}},
 			}})
 			provisioner.Spec.KubeletConfiguration = &v1alpha5.KubeletConfiguration{MaxPods: aws.Int32(1)}
@@ -674,7 +1300,7 @@ var _ = Describe("CloudProvider", func() {
 		})
 		It("should update in-flight IPs when a CreateFleet error occurs", func() {
 			awsEnv.EC2API.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
-				{SubnetId: aws.String("test-subnet-1"), AvailabilityZone: aws.String("test-zone-1a"), AvailableIpAddressCount: aws.Int64(10),
+				{SubnetId: aws.String("test-subnet-1"), AvailabilityZone: aws.String("test-zone-1a"), AvailabilityZoneId: aws.String("testzone1a"), AvailableIpAddressCount: aws.Int64(10),
 					Tags: []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("test-subnet-1")}}},
 			}})
 			pod1 := coretest.UnschedulablePod(coretest.PodOptions{NodeSelector: map[string]string{v1.LabelTopologyZone: "test-zone-1a"}})
@@ -685,9 +1311,9 @@ var _ = Describe("CloudProvider", func() {
 		})
 		It("should launch instances into subnets that are excluded by another provisioner", func() {
 			awsEnv.EC2API.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
-				{SubnetId: aws.String("test-subnet-1"), AvailabilityZone: aws.String("test-zone-1a"), AvailableIpAddressCount: aws.Int64(10),
+				{SubnetId: aws.String("test-subnet-1"), AvailabilityZone: aws.String("test-zone-1a"), AvailabilityZoneId: aws.String("testzone1a"), AvailableIpAddressCount: aws.Int64(10),
 					Tags: []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("test-subnet-1")}}},
-				{SubnetId: aws.String("test-subnet-2"), AvailabilityZone: aws.String("test-zone-1b"), AvailableIpAddressCount: aws.Int64(100),
+				{SubnetId: aws.String("test-subnet-2"), AvailabilityZone: aws.String("test-zone-1b"), AvailabilityZoneId: aws.String("testzone1b"), AvailableIpAddressCount: aws.Int64(100),
 					Tags: []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("test-subnet-2")}}},
 			}})
 			nodeTemplate.Spec.SubnetSelector = map[string]string{"Name": "test-subnet-1"}
@@ -735,3 +1361,13 @@ var _ = Describe("CloudProvider", func() {
 		})
 	})
 })
+
+func ExpectTerminationMetric(nodePool string, reason cloudprovider.TerminationReason) {
+	GinkgoHelper()
+	labels := map[string]string{"nodepool": nodePool, "capacity_type": v1alpha5.CapacityTypeOnDemand, "reason": string(reason)}
+	metric, ok := FindMetricWithLabelValues("karpenter_cloudprovider_instance_terminations_total", labels)
+	Expect(ok).To(BeTrue())
+	Expect(metric.GetCounter().GetValue()).To(BeNumerically(">", 0))
+	_, ok = FindMetricWithLabelValues("karpenter_cloudprovider_instance_lifetime_seconds", labels)
+	Expect(ok).To(BeTrue())
+}
@@ -0,0 +1,162 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"time"
+
+	"github.com/samber/lo"
+	"knative.dev/pkg/logging"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/providers/notification"
+)
+
+// TerminationReason is the source of churn that caused an instance to be terminated
+type TerminationReason string
+
+const (
+	TerminationReasonConsolidation TerminationReason = "consolidation"
+	TerminationReasonDrift         TerminationReason = "drift"
+	TerminationReasonInterruption  TerminationReason = "interruption"
+	TerminationReasonExpiration    TerminationReason = "expiration"
+	TerminationReasonManual        TerminationReason = "manual"
+	TerminationReasonHealth        TerminationReason = "health"
+)
+
+type terminationReasonKeyType struct{}
+
+var terminationReasonKey = terminationReasonKeyType{}
+
+// WithTerminationReason returns a context that attributes any Delete call made with it to the given reason
+func WithTerminationReason(ctx context.Context, reason TerminationReason) context.Context {
+	return context.WithValue(ctx, terminationReasonKey, reason)
+}
+
+// TerminationReasonAnnotationKey returns the annotation that controllers outside of the termination flow set on a
+// NodeClaim to attribute its eventual termination
+func TerminationReasonAnnotationKey(nodeClaim *corev1beta1.NodeClaim) string {
+	return lo.Ternary(nodeClaim.IsMachine, v1alpha1.AnnotationTerminationReason, v1beta1.AnnotationTerminationReason)
+}
+
+// terminationReason attributes the termination of the NodeClaim, preferring an explicit reason from the context
+// or the NodeClaim annotations over the reason inferred from its status conditions
+func terminationReason(ctx context.Context, nodeClaim *corev1beta1.NodeClaim) TerminationReason {
+	if reason, ok := ctx.Value(terminationReasonKey).(TerminationReason); ok {
+		return reason
+	}
+	if reason, ok := nodeClaim.Annotations[TerminationReasonAnnotationKey(nodeClaim)]; ok {
+		return TerminationReason(reason)
+	}
+	conditions := nodeClaim.StatusConditions()
+	switch {
+	case conditions.GetCondition(corev1beta1.NodeDrifted).IsTrue():
+		return TerminationReasonDrift
+	case conditions.GetCondition(corev1beta1.NodeExpired).IsTrue():
+		return TerminationReasonExpiration
+	case conditions.GetCondition(corev1beta1.NodeEmpty).IsTrue(), conditions.GetCondition(corev1beta1.NodeUnderutilized).IsTrue():
+		return TerminationReasonConsolidation
+	// NodeClaims that never registered are removed by the liveness check
+	case !conditions.GetCondition(corev1beta1.NodeRegistered).IsTrue():
+		return TerminationReasonHealth
+	}
+	return TerminationReasonManual
+}
+
+func (c *CloudProvider) recordTermination(ctx context.Context, nodeClaim *corev1beta1.NodeClaim) {
+	// NodeClaims that are built from Nodes during node termination don't carry the conditions needed to attribute
+	// the termination, the owning NodeClaim is recorded when it is terminated instead
+	if nodeClaim.UID == "" {
+		return
+	}
+	nodePool := lo.Ternary(nodeClaim.IsMachine, nodeClaim.Labels[v1alpha5.ProvisionerNameLabelKey], nodeClaim.Labels[corev1beta1.NodePoolLabelKey])
+	capacityType := nodeClaim.Labels[corev1beta1.CapacityTypeLabelKey]
+	reason := string(terminationReason(ctx, nodeClaim))
+	InstanceTerminations.WithLabelValues(nodePool, capacityType, reason).Inc()
+	InstanceLifetime.WithLabelValues(nodePool, capacityType, reason).Observe(time.Since(nodeClaim.CreationTimestamp.Time).Seconds())
+}
+
+// shouldStop returns whether the instance is stopped rather than terminated so that it can be started again for a
+// later NodeClaim. This is the case for consolidated on-demand instances when stop-based consolidation is enabled and
+// their NodeClass stops instances on shutdown. Nodes are terminated before their NodeClaim, so NodeClaims that are
+// built from Nodes are attributed through the NodeClaim that owns the instance. Instances without an owning NodeClaim,
+// like those that are garbage collected, are always terminated.
+func (c *CloudProvider) shouldStop(ctx context.Context, nodeClaim *corev1beta1.NodeClaim) bool {
+	if !settings.FromContext(ctx).EnableStopBasedConsolidation || nodeClaim.IsMachine ||
+		nodeClaim.Labels[corev1beta1.CapacityTypeLabelKey] != corev1beta1.CapacityTypeOnDemand {
+		return false
+	}
+	owner, ok := c.owner(ctx, nodeClaim)
+	if !ok {
+		return false
+	}
+	if terminationReason(ctx, owner) != TerminationReasonConsolidation {
+		return false
+	}
+	nodeClass, err := c.resolveNodeClassFromNodeClaim(ctx, owner)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("resolving node class, %s", err)
+		return false
+	}
+	return lo.FromPtr(nodeClass.Spec.InstanceInitiatedShutdownBehavior) == v1beta1.InstanceInitiatedShutdownBehaviorStop
+}
+
+// owner returns the NodeClaim that owns the instance. NodeClaims that are built from Nodes are resolved to the
+// NodeClaim with the same provider ID.
+func (c *CloudProvider) owner(ctx context.Context, nodeClaim *corev1beta1.NodeClaim) (*corev1beta1.NodeClaim, bool) {
+	if nodeClaim.UID != "" {
+		return nodeClaim, true
+	}
+	nodeClaimList := &corev1beta1.NodeClaimList{}
+	if err := c.kubeClient.List(ctx, nodeClaimList); err != nil {
+		logging.FromContext(ctx).Errorf("listing nodeclaims, %s", err)
+		return nil, false
+	}
+	found, ok := lo.Find(nodeClaimList.Items, func(nc corev1beta1.NodeClaim) bool {
+		return nc.Status.ProviderID == nodeClaim.Status.ProviderID
+	})
+	if !ok {
+		return nil, false
+	}
+	return &found, true
+}
+
+// notifyTermination publishes a pre-termination notification for the instance, attributed through the NodeClaim that
+// owns it. Failing to notify doesn't hold up the termination.
+func (c *CloudProvider) notifyTermination(ctx context.Context, nodeClaim *corev1beta1.NodeClaim, instanceID string, stop bool) {
+	if !notification.Enabled(ctx) {
+		return
+	}
+	owner, ok := c.owner(ctx, nodeClaim)
+	if !ok {
+		owner = nodeClaim
+	}
+	if err := c.notificationProvider.Notify(ctx, notification.PreTermination{
+		InstanceID: instanceID,
+		NodeName:   lo.Ternary(owner.Status.NodeName != "", owner.Status.NodeName, nodeClaim.Status.NodeName),
+		NodeClaim:  owner.Name,
+		NodePool:   lo.Ternary(owner.IsMachine, owner.Labels[v1alpha5.ProvisionerNameLabelKey], owner.Labels[corev1beta1.NodePoolLabelKey]),
+		Reason:     string(terminationReason(ctx, owner)),
+		Action:     lo.Ternary(stop, notification.ActionStop, notification.ActionTerminate),
+		Time:       time.Now(),
+	}); err != nil {
+		logging.FromContext(ctx).Errorf("publishing pre-termination notification, %s", err)
+	}
+}
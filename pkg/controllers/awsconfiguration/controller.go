@@ -0,0 +1,136 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package awsconfiguration
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/aws/aws-sdk-go/service/eks/eksiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/api/equality"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	awserrors "github.com/aws/karpenter/pkg/errors"
+	"github.com/aws/karpenter/pkg/providers/pricing"
+)
+
+// pricesStaleAfter is how long the prices may go without being refreshed. The pricing controller refreshes them every
+// 12 hours, so they're only this old if a refresh has failed.
+const pricesStaleAfter = 24 * time.Hour
+
+// Controller reports whether each of the integrations that the AWSConfiguration configures is healthy in its status
+// conditions
+type Controller struct {
+	kubeClient      client.Client
+	cache           cache.Cache
+	eksapi          eksiface.EKSAPI
+	sqsapi          sqsiface.SQSAPI
+	pricingProvider *pricing.Provider
+}
+
+// NewController creates a Controller that reads and watches the AWSConfiguration with a cache of Karpenter's namespace,
+// since Karpenter is only permitted to read the AWSConfiguration in its own namespace
+func NewController(kubeClient client.Client, cache cache.Cache, eksapi eksiface.EKSAPI, sqsapi sqsiface.SQSAPI, pricingProvider *pricing.Provider) *Controller {
+	return &Controller{
+		kubeClient:      kubeClient,
+		cache:           cache,
+		eksapi:          eksapi,
+		sqsapi:          sqsapi,
+		pricingProvider: pricingProvider,
+	}
+}
+
+func (c *Controller) Name() string {
+	return "awsconfiguration"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	configuration := &v1beta1.AWSConfiguration{}
+	if err := c.cache.Get(ctx, req.NamespacedName, configuration); err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+	stored := configuration.DeepCopy()
+	c.checkCluster(ctx, configuration)
+	c.checkInterruptionQueue(ctx, configuration)
+	c.checkPricing(ctx, configuration)
+	if !equality.Semantic.DeepEqual(stored, configuration) {
+		if err := c.kubeClient.Status().Patch(ctx, configuration, client.MergeFrom(stored)); err != nil {
+			return reconcile.Result{}, client.IgnoreNotFound(err)
+		}
+	}
+	// The integrations can become healthy or unhealthy without the AWSConfiguration changing
+	return reconcile.Result{RequeueAfter: 5 * time.Minute}, nil
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.Adapt(controllerruntime.
+		NewControllerManagedBy(m).
+		Named(c.Name()).
+		Watches(source.NewKindWithCache(&v1beta1.AWSConfiguration{}, c.cache), &handler.EnqueueRequestForObject{}))
+}
+
+func (c *Controller) checkCluster(ctx context.Context, configuration *v1beta1.AWSConfiguration) {
+	clusterName := lo.FromPtrOr(configuration.Spec.ClusterName, settings.FromContext(ctx).ClusterName)
+	if _, err := c.eksapi.DescribeClusterWithContext(ctx, &eks.DescribeClusterInput{Name: aws.String(clusterName)}); err != nil {
+		configuration.StatusConditions().MarkFalse(v1beta1.AWSConfigurationClusterReady, "DescribeClusterFailed", "describing cluster %q, %s", clusterName, err)
+		return
+	}
+	configuration.StatusConditions().MarkTrue(v1beta1.AWSConfigurationClusterReady)
+}
+
+func (c *Controller) checkInterruptionQueue(ctx context.Context, configuration *v1beta1.AWSConfiguration) {
+	queueName := lo.FromPtrOr(configuration.Spec.InterruptionQueueName, settings.FromContext(ctx).InterruptionQueueName)
+	if queueName == "" {
+		configuration.StatusConditions().MarkTrueWithReason(v1beta1.AWSConfigurationInterruptionQueueReady, "InterruptionHandlingDisabled", "no interruption queue is configured")
+		return
+	}
+	if _, err := c.sqsapi.GetQueueUrlWithContext(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(queueName)}); err != nil {
+		if awserrors.IsNotFound(err) {
+			configuration.StatusConditions().MarkFalse(v1beta1.AWSConfigurationInterruptionQueueReady, "QueueNotFound", "interruption queue %q does not exist", queueName)
+			return
+		}
+		configuration.StatusConditions().MarkFalse(v1beta1.AWSConfigurationInterruptionQueueReady, "GetQueueURLFailed", "getting interruption queue url, %s", err)
+		return
+	}
+	configuration.StatusConditions().MarkTrue(v1beta1.AWSConfigurationInterruptionQueueReady)
+}
+
+func (c *Controller) checkPricing(ctx context.Context, configuration *v1beta1.AWSConfiguration) {
+	if settings.FromContext(ctx).IsolatedVPC {
+		configuration.StatusConditions().MarkTrueWithReason(v1beta1.AWSConfigurationPricingReady, "IsolatedVPC", "prices aren't refreshed in an isolated VPC")
+		return
+	}
+	onDemandUpdated, spotUpdated := c.pricingProvider.OnDemandLastUpdated(), c.pricingProvider.SpotLastUpdated()
+	if time.Since(onDemandUpdated) > pricesStaleAfter || time.Since(spotUpdated) > pricesStaleAfter {
+		configuration.StatusConditions().MarkFalse(v1beta1.AWSConfigurationPricingReady, "PricesStale", "on-demand prices were last refreshed at %s and spot prices at %s",
+			onDemandUpdated.Format(time.RFC3339), spotUpdated.Format(time.RFC3339))
+		return
+	}
+	configuration.StatusConditions().MarkTrue(v1beta1.AWSConfigurationPricingReady)
+}
@@ -0,0 +1,170 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package awsconfiguration_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	. "knative.dev/pkg/logging/testing"
+	"knative.dev/pkg/system"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/controllers/awsconfiguration"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+// apiCache reads from the API server rather than from informers, so that the AWSConfiguration can be reconciled as soon
+// as it's applied. The controller only watches with the cache when it's registered with a manager.
+type apiCache struct {
+	cache.Cache
+	client.Client
+}
+
+func (c apiCache) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+func (c apiCache) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return c.Client.List(ctx, list, opts...)
+}
+
+var ctx context.Context
+var env *coretest.Environment
+var awsEnv *test.Environment
+var eksapi *fake.EKSAPI
+var sqsapi *fake.SQSAPI
+var controller *awsconfiguration.Controller
+var configuration *v1beta1.AWSConfiguration
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "AWSConfiguration")
+}
+
+var _ = BeforeSuite(func() {
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	awsEnv = test.NewEnvironment(ctx, env)
+	eksapi = &fake.EKSAPI{}
+	sqsapi = &fake.SQSAPI{}
+	controller = awsconfiguration.NewController(env.Client, apiCache{Client: env.Client}, eksapi, sqsapi, awsEnv.PricingProvider)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	ctx = settings.ToContext(ctx, test.Settings())
+	awsEnv.Reset()
+	eksapi.Reset()
+	sqsapi.Reset()
+	configuration = &v1beta1.AWSConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      v1beta1.AWSConfigurationName,
+			Namespace: system.Namespace(),
+		},
+		Spec: v1beta1.AWSConfigurationSpec{
+			ClusterName: lo.ToPtr("my-cluster"),
+		},
+	}
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("AWSConfiguration", func() {
+	It("should be ready when all of the integrations are healthy", func() {
+		configuration.Spec.InterruptionQueueName = lo.ToPtr("my-queue")
+		updatePrices()
+		ExpectApplied(ctx, env.Client, configuration)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(configuration))
+		configuration = ExpectExists(ctx, env.Client, configuration)
+		Expect(configuration.StatusConditions().IsHappy()).To(BeTrue())
+	})
+	It("should not be ready when the cluster can't be described", func() {
+		eksapi.DescribeClusterBehaviour.Error.Set(fmt.Errorf("cluster not found"))
+		updatePrices()
+		ExpectApplied(ctx, env.Client, configuration)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(configuration))
+		configuration = ExpectExists(ctx, env.Client, configuration)
+		Expect(configuration.StatusConditions().GetCondition(v1beta1.AWSConfigurationClusterReady).IsFalse()).To(BeTrue())
+		Expect(configuration.StatusConditions().IsHappy()).To(BeFalse())
+	})
+	It("should not be ready when the interruption queue doesn't exist", func() {
+		configuration.Spec.InterruptionQueueName = lo.ToPtr("my-queue")
+		sqsapi.GetQueueURLBehavior.Error.Set(awserr.New(sqs.ErrCodeQueueDoesNotExist, "The specified queue does not exist.", nil))
+		updatePrices()
+		ExpectApplied(ctx, env.Client, configuration)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(configuration))
+		configuration = ExpectExists(ctx, env.Client, configuration)
+		condition := configuration.StatusConditions().GetCondition(v1beta1.AWSConfigurationInterruptionQueueReady)
+		Expect(condition.IsFalse()).To(BeTrue())
+		Expect(condition.Reason).To(Equal("QueueNotFound"))
+	})
+	It("should report the interruption queue as ready when interruption handling is disabled", func() {
+		updatePrices()
+		ExpectApplied(ctx, env.Client, configuration)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(configuration))
+		configuration = ExpectExists(ctx, env.Client, configuration)
+		condition := configuration.StatusConditions().GetCondition(v1beta1.AWSConfigurationInterruptionQueueReady)
+		Expect(condition.IsTrue()).To(BeTrue())
+		Expect(condition.Reason).To(Equal("InterruptionHandlingDisabled"))
+	})
+	It("should not be ready when the prices haven't been refreshed", func() {
+		ExpectApplied(ctx, env.Client, configuration)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(configuration))
+		configuration = ExpectExists(ctx, env.Client, configuration)
+		Expect(configuration.StatusConditions().GetCondition(v1beta1.AWSConfigurationPricingReady).IsFalse()).To(BeTrue())
+	})
+	It("should report the prices as ready in an isolated VPC", func() {
+		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{IsolatedVPC: lo.ToPtr(true)}))
+		ExpectApplied(ctx, env.Client, configuration)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(configuration))
+		configuration = ExpectExists(ctx, env.Client, configuration)
+		condition := configuration.StatusConditions().GetCondition(v1beta1.AWSConfigurationPricingReady)
+		Expect(condition.IsTrue()).To(BeTrue())
+		Expect(condition.Reason).To(Equal("IsolatedVPC"))
+	})
+})
+
+func updatePrices() {
+	awsEnv.PricingAPI.GetProductsOutput.Set(fake.NewOnDemandPrices(map[string]float64{"c99.large": 1.23}))
+	awsEnv.EC2API.SpotPriceHistory.Add(fake.NewSpotPrice("c99.large", "test-zone-1a", 1.00, time.Now().Add(-time.Hour)))
+	Expect(awsEnv.PricingProvider.UpdateOnDemandPricing(ctx)).To(Succeed())
+	Expect(awsEnv.PricingProvider.UpdateSpotPricing(ctx)).To(Succeed())
+}
@@ -0,0 +1,153 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go.uber.org/multierr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"knative.dev/pkg/system"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	nodepoolutil "github.com/aws/karpenter-core/pkg/utils/nodepool"
+	awscache "github.com/aws/karpenter/pkg/cache"
+	"github.com/aws/karpenter/pkg/cloudprovider"
+	"github.com/aws/karpenter/pkg/providers/instancetype"
+)
+
+// ConfigMapName is the name of the ConfigMap in Karpenter's namespace that the instance type catalog is published to
+const ConfigMapName = "karpenter-instance-type-catalog"
+
+// Controller publishes the instance type catalog of each NodePool and Provisioner to a ConfigMap, so that tools that
+// scale them up from zero nodes can read the resources, labels and taints of the nodes that they would launch
+type Controller struct {
+	kubeClient client.Client
+	// kubeReader reads the ConfigMap from the API server, since Karpenter is only permitted to read the ConfigMaps in
+	// its own namespace
+	kubeReader           client.Reader
+	cloudProvider        *cloudprovider.CloudProvider
+	instanceTypeProvider *instancetype.Provider
+}
+
+func NewController(kubeClient client.Client, cloudProvider *cloudprovider.CloudProvider, instanceTypeProvider *instancetype.Provider) *Controller {
+	return &Controller{
+		kubeClient:           kubeClient,
+		kubeReader:           kubeClient,
+		cloudProvider:        cloudProvider,
+		instanceTypeProvider: instanceTypeProvider,
+	}
+}
+
+func (c *Controller) Name() string {
+	return "catalog"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	configMap := &v1.ConfigMap{}
+	if err := c.kubeReader.Get(ctx, types.NamespacedName{Namespace: system.Namespace(), Name: ConfigMapName}, configMap); err != nil {
+		if !errors.IsNotFound(err) {
+			return reconcile.Result{}, fmt.Errorf("getting configmap, %w", err)
+		}
+		configMap = nil
+	}
+	nodePoolList, err := nodepoolutil.List(ctx, c.kubeClient)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("listing nodepools, %w", err)
+	}
+	var errs []error
+	data := map[string]string{}
+	for i := range nodePoolList.Items {
+		nodePool := &nodePoolList.Items[i]
+		catalog, err := c.catalog(ctx, nodePool)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("getting instance type catalog of %s, %w", nodePool.Name, err))
+			// Keep publishing the last catalog of the NodePool until it's resolved again
+			if configMap != nil {
+				if previous, ok := configMap.Data[Key(nodePool)]; ok {
+					data[Key(nodePool)] = previous
+				}
+			}
+			continue
+		}
+		data[Key(nodePool)] = catalog
+	}
+	errs = append(errs, c.publish(ctx, configMap, data))
+	// jitter the refresh so that it doesn't line up with the refresh of the instance types
+	return reconcile.Result{RequeueAfter: wait.Jitter(awscache.InstanceTypesRefreshInterval, 0.2)}, multierr.Combine(errs...)
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	c.kubeReader = m.GetAPIReader()
+	return corecontroller.NewSingletonManagedBy(m)
+}
+
+// Key returns the key of the NodePool's catalog in the ConfigMap, which is prefixed with its kind so that a
+// NodePool and a Provisioner with the same name don't collide
+func Key(nodePool *corev1beta1.NodePool) string {
+	if nodePool.IsProvisioner {
+		return fmt.Sprintf("provisioner.%s.json", nodePool.Name)
+	}
+	return fmt.Sprintf("nodepool.%s.json", nodePool.Name)
+}
+
+func (c *Controller) catalog(ctx context.Context, nodePool *corev1beta1.NodePool) (string, error) {
+	nodeClass, err := c.cloudProvider.ResolveNodeClass(ctx, nodePool)
+	if err != nil {
+		return "", fmt.Errorf("resolving node class, %w", err)
+	}
+	entries, err := c.instanceTypeProvider.Catalog(ctx, nodePool, nodeClass)
+	if err != nil {
+		return "", err
+	}
+	raw, err := json.Marshal(entries)
+	if err != nil {
+		return "", fmt.Errorf("marshaling instance type catalog, %w", err)
+	}
+	return string(raw), nil
+}
+
+// publish creates the ConfigMap or replaces its data, which drops the catalogs of deleted NodePools
+func (c *Controller) publish(ctx context.Context, configMap *v1.ConfigMap, data map[string]string) error {
+	if configMap == nil {
+		if err := c.kubeClient.Create(ctx, &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: system.Namespace(), Name: ConfigMapName},
+			Data:       data,
+		}); err != nil {
+			return fmt.Errorf("creating configmap, %w", err)
+		}
+		return nil
+	}
+	if equality.Semantic.DeepEqual(configMap.Data, data) {
+		return nil
+	}
+	stored := configMap.DeepCopy()
+	configMap.Data = data
+	if err := c.kubeClient.Patch(ctx, configMap, client.MergeFrom(stored)); err != nil {
+		return fmt.Errorf("patching configmap, %w", err)
+	}
+	return nil
+}
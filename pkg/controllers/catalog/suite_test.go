@@ -0,0 +1,149 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package catalog_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	. "knative.dev/pkg/logging/testing"
+	"knative.dev/pkg/system"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/events"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+	nodepoolutil "github.com/aws/karpenter-core/pkg/utils/nodepool"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/cloudprovider"
+	"github.com/aws/karpenter/pkg/controllers/catalog"
+	"github.com/aws/karpenter/pkg/providers/instancetype"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var awsEnv *test.Environment
+var controller *catalog.Controller
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Catalog")
+}
+
+var _ = BeforeSuite(func() {
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	awsEnv = test.NewEnvironment(ctx, env)
+	cloudProvider := cloudprovider.New(awsEnv.InstanceTypesProvider, awsEnv.InstanceProvider, events.NewRecorder(&record.FakeRecorder{}),
+		env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider, awsEnv.QuotaProvider,
+		awsEnv.NotificationProvider)
+	controller = catalog.NewController(env.Client, cloudProvider, awsEnv.InstanceTypesProvider)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	awsEnv.Reset()
+})
+
+var _ = AfterEach(func() {
+	Expect(client.IgnoreNotFound(env.Client.Delete(ctx, &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: system.Namespace(), Name: catalog.ConfigMapName}}))).To(Succeed())
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("Catalog", func() {
+	var nodeTemplate *v1alpha1.AWSNodeTemplate
+	var provisioner *v1alpha5.Provisioner
+	BeforeEach(func() {
+		nodeTemplate = test.AWSNodeTemplate()
+		provisioner = test.Provisioner(coretest.ProvisionerOptions{
+			ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+			Labels:      map[string]string{"team": "a"},
+			Taints:      []v1.Taint{{Key: "dedicated", Value: "a", Effect: v1.TaintEffectNoSchedule}},
+			Requirements: []v1.NodeSelectorRequirement{
+				{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.large", "p3.8xlarge"}},
+				{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeOnDemand}},
+			},
+		})
+	})
+	entries := func(key string) []instancetype.CatalogEntry {
+		configMap := ExpectExists(ctx, env.Client, &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: system.Namespace(), Name: catalog.ConfigMapName}})
+		Expect(configMap.Data).To(HaveKey(key))
+		var entries []instancetype.CatalogEntry
+		Expect(json.Unmarshal([]byte(configMap.Data[key]), &entries)).To(Succeed())
+		return entries
+	}
+	It("should publish the instance types that a provisioner can launch", func() {
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+
+		catalogEntries := entries(catalog.Key(nodepoolutil.New(provisioner)))
+		Expect(lo.Map(catalogEntries, func(e instancetype.CatalogEntry, _ int) string { return e.InstanceType })).To(Equal([]string{"m5.large", "p3.8xlarge"}))
+		for _, entry := range catalogEntries {
+			Expect(entry.Labels).To(HaveKeyWithValue(v1.LabelInstanceTypeStable, entry.InstanceType))
+			Expect(entry.Labels).To(HaveKeyWithValue(v1alpha5.LabelCapacityType, v1alpha5.CapacityTypeOnDemand))
+			Expect(entry.Labels).To(HaveKeyWithValue(v1alpha5.ProvisionerNameLabelKey, provisioner.Name))
+			Expect(entry.Labels).To(HaveKeyWithValue("team", "a"))
+			Expect(entry.Labels).ToNot(HaveKey(v1.LabelTopologyZone))
+			Expect(entry.Taints).To(Equal(provisioner.Spec.Taints))
+			Expect(entry.CapacityTypes).To(Equal([]string{v1alpha5.CapacityTypeOnDemand}))
+			Expect(entry.Zones).ToNot(BeEmpty())
+			Expect(entry.Allocatable.Cpu().Cmp(*entry.Capacity.Cpu())).To(BeNumerically("<", 0))
+		}
+		gpu := catalogEntries[1]
+		Expect(gpu.Capacity).To(HaveKey(v1.ResourceName("nvidia.com/gpu")))
+	})
+	It("should remove the catalog of a deleted provisioner", func() {
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		entries(catalog.Key(nodepoolutil.New(provisioner)))
+
+		ExpectDeleted(ctx, env.Client, provisioner)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		configMap := ExpectExists(ctx, env.Client, &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: system.Namespace(), Name: catalog.ConfigMapName}})
+		Expect(configMap.Data).To(BeEmpty())
+	})
+	It("should keep publishing the last catalog of a provisioner whose node template can't be resolved", func() {
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		key := catalog.Key(nodepoolutil.New(provisioner))
+		Expect(entries(key)).To(HaveLen(2))
+
+		ExpectDeleted(ctx, env.Client, nodeTemplate)
+		_, err := controller.Reconcile(ctx, reconcile.Request{})
+		Expect(err).To(HaveOccurred())
+		Expect(entries(key)).To(HaveLen(2))
+	})
+})
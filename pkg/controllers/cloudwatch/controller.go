@@ -0,0 +1,241 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudwatch
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awscloudwatch "github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	nodeclaimutil "github.com/aws/karpenter-core/pkg/utils/nodeclaim"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/providers/pricing"
+)
+
+const (
+	MetricNodesLaunched              = "NodesLaunched"
+	MetricNodes                      = "Nodes"
+	MetricInsufficientCapacityErrors = "InsufficientCapacityErrors"
+	MetricConsolidationSavings       = "ConsolidationSavings"
+
+	DimensionNodePool     = "NodePool"
+	DimensionCapacityType = "CapacityType"
+
+	// maxMetricData is the most metric data that PutMetricData accepts in a single request
+	maxMetricData = 1000
+)
+
+// counters are the Prometheus counters that are published as the number of events since the last publication, by the
+// CloudWatch metric that they're published as and the label that's published as its dimension
+var counters = map[string]struct {
+	metric    string
+	label     string
+	dimension string
+}{
+	"karpenter_nodeclaims_launched":                              {metric: MetricNodesLaunched, label: "nodepool", dimension: DimensionNodePool},
+	"karpenter_machines_launched":                                {metric: MetricNodesLaunched, label: "provisioner", dimension: DimensionNodePool},
+	"karpenter_cloudprovider_insufficient_capacity_errors_total": {metric: MetricInsufficientCapacityErrors, label: "capacity_type", dimension: DimensionCapacityType},
+}
+
+// series is a CloudWatch metric and the value of its dimension
+type series struct {
+	metric, dimension, value string
+}
+
+// Controller periodically publishes the provisioning decisions that Karpenter makes to CloudWatch, so that they can be
+// observed from CloudWatch dashboards and alarms in clusters that don't run Prometheus. Node launches, the capacity type
+// mix of running nodes, insufficient capacity errors and the hourly price of the capacity that's consolidated away
+// are published each minute.
+type Controller struct {
+	kubeClient      client.Client
+	cloudwatchapi   cloudwatchiface.CloudWatchAPI
+	pricingProvider *pricing.Provider
+	gatherer        prometheus.Gatherer
+
+	// published is the value of each counter series as of the last publication
+	published map[string]float64
+	// consolidated is the UIDs of the NodeClaims whose consolidation has been published
+	consolidated sets.Set[string]
+}
+
+func NewController(kubeClient client.Client, cloudwatchapi cloudwatchiface.CloudWatchAPI, pricingProvider *pricing.Provider, gatherer prometheus.Gatherer) *Controller {
+	return &Controller{
+		kubeClient:      kubeClient,
+		cloudwatchapi:   cloudwatchapi,
+		pricingProvider: pricingProvider,
+		gatherer:        gatherer,
+		published:       map[string]float64{},
+		consolidated:    sets.New[string](),
+	}
+}
+
+func (c *Controller) Name() string {
+	return "cloudwatch"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	counterData, published, err := c.counterData()
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	nodeClaimList, err := nodeclaimutil.List(ctx, c.kubeClient)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("listing nodeclaims, %w", err)
+	}
+	consolidationData, consolidated := c.consolidationData(nodeClaimList.Items)
+	data := append(counterData, c.capacityTypeData(nodeClaimList.Items)...)
+	data = append(data, consolidationData)
+	for _, chunk := range lo.Chunk(data, maxMetricData) {
+		if _, err := c.cloudwatchapi.PutMetricDataWithContext(ctx, &awscloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(settings.FromContext(ctx).CloudWatchMetricsNamespace),
+			MetricData: chunk,
+		}); err != nil {
+			return reconcile.Result{}, fmt.Errorf("putting metric data, %w", err)
+		}
+	}
+	// Only what was published is marked as published so that a failed publication is retried with the next one
+	c.published = published
+	c.consolidated = consolidated
+	return reconcile.Result{RequeueAfter: time.Minute}, nil
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.NewSingletonManagedBy(m)
+}
+
+// counterData returns how much each of the counters has increased since the last publication, and their current values.
+// Counters start at zero when Karpenter starts, so their first publication is everything that was counted since then.
+func (c *Controller) counterData() ([]*awscloudwatch.MetricDatum, map[string]float64, error) {
+	families, err := c.gatherer.Gather()
+	if err != nil {
+		return nil, nil, fmt.Errorf("gathering metrics, %w", err)
+	}
+	published := map[string]float64{}
+	// Counters that are published as the same metric, like the launches of NodeClaims and Machines, are summed
+	increases := map[series]float64{}
+	for _, family := range families {
+		counter, ok := counters[family.GetName()]
+		if !ok {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			value, ok := labelValue(metric, counter.label)
+			if !ok {
+				continue
+			}
+			key := fmt.Sprintf("%s/%s", family.GetName(), value)
+			increase := metric.GetCounter().GetValue() - c.published[key]
+			published[key] = metric.GetCounter().GetValue()
+			increases[series{counter.metric, counter.dimension, value}] += increase
+		}
+	}
+	var data []*awscloudwatch.MetricDatum
+	for s, increase := range increases {
+		data = append(data, datum(s.metric, awscloudwatch.StandardUnitCount, increase, s.dimension, s.value))
+	}
+	return data, published, nil
+}
+
+// capacityTypeData returns the number of launched nodes of each capacity type. Both capacity types are published even
+// when there are no nodes of that type so that the mix can be graphed without gaps.
+func (c *Controller) capacityTypeData(nodeClaims []v1beta1.NodeClaim) []*awscloudwatch.MetricDatum {
+	nodes := map[string]float64{v1beta1.CapacityTypeOnDemand: 0, v1beta1.CapacityTypeSpot: 0}
+	for i := range nodeClaims {
+		if nodeClaims[i].Status.ProviderID == "" {
+			continue
+		}
+		if capacityType, ok := nodeClaims[i].Labels[v1beta1.CapacityTypeLabelKey]; ok {
+			nodes[capacityType]++
+		}
+	}
+	return lo.MapToSlice(nodes, func(capacityType string, count float64) *awscloudwatch.MetricDatum {
+		return datum(MetricNodes, awscloudwatch.StandardUnitCount, count, DimensionCapacityType, capacityType)
+	})
+}
+
+// consolidationData returns the hourly price of the NodeClaims that started being consolidated since the last
+// publication, and the UIDs of every NodeClaim that's being consolidated. The price of the capacity that replaces them
+// isn't subtracted, so the savings of replacing a node with a cheaper one are overestimated.
+func (c *Controller) consolidationData(nodeClaims []v1beta1.NodeClaim) (*awscloudwatch.MetricDatum, sets.Set[string]) {
+	var savings float64
+	// NodeClaims that have been deleted aren't listed, so they're dropped from the set
+	consolidated := sets.New[string]()
+	for i := range nodeClaims {
+		nodeClaim := &nodeClaims[i]
+		if nodeClaim.DeletionTimestamp.IsZero() || !isConsolidated(nodeClaim) {
+			continue
+		}
+		consolidated.Insert(string(nodeClaim.UID))
+		if c.consolidated.Has(string(nodeClaim.UID)) {
+			continue
+		}
+		if price, ok := c.price(nodeClaim); ok {
+			savings += price
+		}
+	}
+	return &awscloudwatch.MetricDatum{
+		MetricName: aws.String(MetricConsolidationSavings),
+		Unit:       aws.String(awscloudwatch.StandardUnitNone),
+		Value:      aws.Float64(savings),
+	}, consolidated
+}
+
+func (c *Controller) price(nodeClaim *v1beta1.NodeClaim) (float64, bool) {
+	instanceType := nodeClaim.Labels[v1.LabelInstanceTypeStable]
+	if nodeClaim.Labels[v1beta1.CapacityTypeLabelKey] == v1beta1.CapacityTypeSpot {
+		return c.pricingProvider.SpotPrice(instanceType, nodeClaim.Labels[v1.LabelTopologyZone])
+	}
+	return c.pricingProvider.OnDemandPrice(instanceType)
+}
+
+// isConsolidated returns whether the NodeClaim was deleted by consolidation, which marks the NodeClaims that it
+// deprovisions as empty or underutilized
+func isConsolidated(nodeClaim *v1beta1.NodeClaim) bool {
+	conditions := nodeClaim.StatusConditions()
+	return conditions.GetCondition(v1beta1.NodeEmpty).IsTrue() || conditions.GetCondition(v1beta1.NodeUnderutilized).IsTrue() ||
+		conditions.GetCondition(v1alpha5.MachineEmpty).IsTrue()
+}
+
+func labelValue(metric *dto.Metric, name string) (string, bool) {
+	label, ok := lo.Find(metric.GetLabel(), func(l *dto.LabelPair) bool { return l.GetName() == name })
+	if !ok {
+		return "", false
+	}
+	return label.GetValue(), true
+}
+
+func datum(name, unit string, value float64, dimension, dimensionValue string) *awscloudwatch.MetricDatum {
+	return &awscloudwatch.MetricDatum{
+		MetricName: aws.String(name),
+		Unit:       aws.String(unit),
+		Value:      aws.Float64(value),
+		Dimensions: []*awscloudwatch.Dimension{{Name: aws.String(dimension), Value: aws.String(dimensionValue)}},
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudwatch_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awscloudwatch "github.com/aws/aws-sdk-go/service/cloudwatch"
+	awspricing "github.com/aws/aws-sdk-go/service/pricing"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	. "knative.dev/pkg/logging/testing"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/controllers/cloudwatch"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/providers/pricing"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var awsEnv *test.Environment
+var cloudwatchapi *fake.CloudWatchAPI
+var nodeClaimsLaunched *prometheus.CounterVec
+var machinesLaunched *prometheus.CounterVec
+var controller *cloudwatch.Controller
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "CloudWatch")
+}
+
+var _ = BeforeSuite(func() {
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{CloudWatchMetricsNamespace: lo.ToPtr("Karpenter")}))
+	awsEnv = test.NewEnvironment(ctx, env)
+	cloudwatchapi = &fake.CloudWatchAPI{}
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	awsEnv.Reset()
+	cloudwatchapi.Reset()
+	awsEnv.PricingAPI.GetProductsOutput.Set(&awspricing.GetProductsOutput{
+		PriceList: []aws.JSONValue{
+			fake.NewOnDemandPrice("c98.large", 1.00),
+		},
+	})
+	ExpectReconcileSucceeded(ctx, pricing.NewController(awsEnv.PricingProvider), types.NamespacedName{})
+
+	// Each test publishes from its own registry so that counters start at zero
+	registry := prometheus.NewRegistry()
+	nodeClaimsLaunched = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "karpenter_nodeclaims_launched"}, []string{"nodepool"})
+	machinesLaunched = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "karpenter_machines_launched"}, []string{"provisioner"})
+	registry.MustRegister(nodeClaimsLaunched, machinesLaunched)
+	controller = cloudwatch.NewController(env.Client, cloudwatchapi, awsEnv.PricingProvider, registry)
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("CloudWatch", func() {
+	It("should publish to the configured namespace", func() {
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(aws.StringValue(cloudwatchapi.PutMetricDataBehavior.CalledWithInput.Pop().Namespace)).To(Equal("Karpenter"))
+	})
+	It("should publish the launches since the last publication", func() {
+		nodeClaimsLaunched.WithLabelValues("default").Add(2)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(value(cloudwatch.MetricNodesLaunched, cloudwatch.DimensionNodePool, "default")).To(BeNumerically("==", 2))
+
+		nodeClaimsLaunched.WithLabelValues("default").Inc()
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(value(cloudwatch.MetricNodesLaunched, cloudwatch.DimensionNodePool, "default")).To(BeNumerically("==", 1))
+	})
+	It("should sum the launches of nodeclaims and machines", func() {
+		nodeClaimsLaunched.WithLabelValues("default").Add(2)
+		machinesLaunched.WithLabelValues("default").Add(3)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(value(cloudwatch.MetricNodesLaunched, cloudwatch.DimensionNodePool, "default")).To(BeNumerically("==", 5))
+	})
+	It("should publish launches again after a failed publication", func() {
+		nodeClaimsLaunched.WithLabelValues("default").Add(2)
+		cloudwatchapi.PutMetricDataBehavior.Error.Set(fmt.Errorf("throttled"))
+		ExpectReconcileFailed(ctx, controller, types.NamespacedName{})
+
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(value(cloudwatch.MetricNodesLaunched, cloudwatch.DimensionNodePool, "default")).To(BeNumerically("==", 2))
+	})
+	It("should publish the number of launched nodes of each capacity type", func() {
+		ExpectApplied(ctx, env.Client,
+			machine(v1alpha1.CapacityTypeSpot, true),
+			machine(v1alpha1.CapacityTypeSpot, true),
+			machine(v1alpha1.CapacityTypeOnDemand, true),
+			machine(v1alpha1.CapacityTypeOnDemand, false),
+		)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input := cloudwatchapi.PutMetricDataBehavior.CalledWithInput.Pop()
+		Expect(datumValue(input, cloudwatch.MetricNodes, cloudwatch.DimensionCapacityType, v1alpha1.CapacityTypeSpot)).To(BeNumerically("==", 2))
+		Expect(datumValue(input, cloudwatch.MetricNodes, cloudwatch.DimensionCapacityType, v1alpha1.CapacityTypeOnDemand)).To(BeNumerically("==", 1))
+	})
+	It("should publish the price of consolidated nodes once", func() {
+		m := machine(v1alpha1.CapacityTypeOnDemand, true)
+		m.StatusConditions().MarkTrue(v1alpha5.MachineEmpty)
+		ExpectApplied(ctx, env.Client, m)
+		ExpectDeletionTimestampSet(ctx, env.Client, m)
+
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(value(cloudwatch.MetricConsolidationSavings, "", "")).To(BeNumerically("~", 1.00))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(value(cloudwatch.MetricConsolidationSavings, "", "")).To(BeNumerically("==", 0))
+	})
+	It("should not publish the price of nodes that are deleted for other reasons", func() {
+		m := machine(v1alpha1.CapacityTypeOnDemand, true)
+		ExpectApplied(ctx, env.Client, m)
+		ExpectDeletionTimestampSet(ctx, env.Client, m)
+
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(value(cloudwatch.MetricConsolidationSavings, "", "")).To(BeNumerically("==", 0))
+	})
+})
+
+func machine(capacityType string, launched bool) *v1alpha5.Machine {
+	m := coretest.Machine(v1alpha5.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				v1alpha5.ProvisionerNameLabelKey: "default",
+				v1alpha5.LabelCapacityType:       capacityType,
+				v1.LabelInstanceTypeStable:       "c98.large",
+				v1.LabelTopologyZone:             "test-zone-1a",
+			},
+		},
+	})
+	if launched {
+		m.Status.ProviderID = fake.ProviderID(fake.InstanceID())
+	}
+	return m
+}
+
+// value returns the value of the metric in the last publication
+func value(metricName, dimension, dimensionValue string) float64 {
+	return datumValue(cloudwatchapi.PutMetricDataBehavior.CalledWithInput.Pop(), metricName, dimension, dimensionValue)
+}
+
+func datumValue(input *awscloudwatch.PutMetricDataInput, metricName, dimension, dimensionValue string) float64 {
+	datum, ok := lo.Find(input.MetricData, func(d *awscloudwatch.MetricDatum) bool {
+		if aws.StringValue(d.MetricName) != metricName {
+			return false
+		}
+		if dimension == "" {
+			return len(d.Dimensions) == 0
+		}
+		return lo.ContainsBy(d.Dimensions, func(dim *awscloudwatch.Dimension) bool {
+			return aws.StringValue(dim.Name) == dimension && aws.StringValue(dim.Value) == dimensionValue
+		})
+	})
+	Expect(ok).To(BeTrue())
+	return aws.Float64Value(datum.Value)
+}
@@ -0,0 +1,150 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consolidation
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"go.uber.org/multierr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	corecloudprovider "github.com/aws/karpenter-core/pkg/cloudprovider"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter-core/pkg/scheduling"
+	nodeclaimutil "github.com/aws/karpenter-core/pkg/utils/nodeclaim"
+	nodepoolutil "github.com/aws/karpenter-core/pkg/utils/nodepool"
+	"github.com/aws/karpenter-core/pkg/utils/resources"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/cloudprovider"
+)
+
+// Controller periodically estimates how many consolidation decisions the consolidation price thresholds suppress. A
+// replacement of a node is suppressed when an offering that fits the node's NodeClaim is cheaper than the node, but
+// isn't cheaper by more than the thresholds. Pods that were scheduled to the node after it launched aren't considered,
+// so the estimate may count nodes that consolidation couldn't replace anyway.
+type Controller struct {
+	kubeClient    client.Client
+	cloudProvider *cloudprovider.CloudProvider
+}
+
+func NewController(kubeClient client.Client, cloudProvider *cloudprovider.CloudProvider) *Controller {
+	return &Controller{
+		kubeClient:    kubeClient,
+		cloudProvider: cloudProvider,
+	}
+}
+
+func (c *Controller) Name() string {
+	return "consolidation"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	suppressed := map[string]int{v1beta1.CapacityTypeSpot: 0, v1beta1.CapacityTypeOnDemand: 0}
+	var errs []error
+	if settings.FromContext(ctx).ConsolidationPriceThreshold > 0 || settings.FromContext(ctx).ConsolidationPriceThresholdPercent > 0 {
+		nodeClaimList, err := nodeclaimutil.List(ctx, c.kubeClient)
+		if err != nil {
+			return reconcile.Result{}, fmt.Errorf("listing nodeclaims, %w", err)
+		}
+		instanceTypes := map[nodepoolutil.Key][]*corecloudprovider.InstanceType{}
+		for i := range nodeClaimList.Items {
+			nodeClaim := &nodeClaimList.Items[i]
+			if !nodeClaim.DeletionTimestamp.IsZero() || nodeClaim.Status.ProviderID == "" {
+				continue
+			}
+			key := nodeclaimutil.OwnerKey(nodeClaim)
+			if _, ok := instanceTypes[key]; !ok {
+				its, err := c.consolidatableInstanceTypes(ctx, nodeClaim)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				instanceTypes[key] = its
+			}
+			if replacementSuppressed(ctx, nodeClaim, instanceTypes[key]) {
+				suppressed[nodeClaim.Labels[v1beta1.CapacityTypeLabelKey]]++
+			}
+		}
+	}
+	for capacityType, count := range suppressed {
+		SuppressedReplacements.WithLabelValues(capacityType).Set(float64(count))
+	}
+	return reconcile.Result{RequeueAfter: time.Minute}, multierr.Combine(errs...)
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.NewSingletonManagedBy(m)
+}
+
+// consolidatableInstanceTypes returns the instance types of the NodeClaim's NodePool, or none if the NodePool doesn't
+// exist or doesn't consolidate underutilized nodes
+func (c *Controller) consolidatableInstanceTypes(ctx context.Context, nodeClaim *v1beta1.NodeClaim) ([]*corecloudprovider.InstanceType, error) {
+	nodePool, err := nodeclaimutil.Owner(ctx, c.kubeClient, nodeClaim)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("resolving owner, %w", err)
+	}
+	if nodePool.Spec.Disruption.ConsolidationPolicy != v1beta1.ConsolidationPolicyWhenUnderutilized {
+		return nil, nil
+	}
+	instanceTypes, err := c.cloudProvider.GetInstanceTypes(ctx, nodePool)
+	if err != nil {
+		return nil, fmt.Errorf("getting instance types, %w", err)
+	}
+	return instanceTypes, nil
+}
+
+// replacementSuppressed returns true if the cheapest offering that fits the NodeClaim is cheaper than the offering that
+// it runs on, but not cheaper than its consolidation price. Like consolidation, spot nodes are only replaced with
+// on-demand offerings.
+func replacementSuppressed(ctx context.Context, nodeClaim *v1beta1.NodeClaim, instanceTypes []*corecloudprovider.InstanceType) bool {
+	capacityType := nodeClaim.Labels[v1beta1.CapacityTypeLabelKey]
+	var price float64
+	found := false
+	for _, it := range instanceTypes {
+		if it.Name == nodeClaim.Labels[v1.LabelInstanceTypeStable] {
+			offering, ok := it.Offerings.Get(capacityType, nodeClaim.Labels[v1.LabelTopologyZone])
+			price, found = offering.Price, ok
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+	requirements := scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...)
+	if capacityType == v1beta1.CapacityTypeSpot {
+		requirements.Add(scheduling.NewRequirement(v1beta1.CapacityTypeLabelKey, v1.NodeSelectorOpIn, v1beta1.CapacityTypeOnDemand))
+	}
+	cheapest := math.MaxFloat64
+	for _, it := range instanceTypes {
+		if it.Requirements.Intersects(requirements) != nil || !resources.Fits(nodeClaim.Spec.Resources.Requests, it.Allocatable()) {
+			continue
+		}
+		if offerings := it.Offerings.Available().Requirements(requirements); len(offerings) > 0 {
+			cheapest = math.Min(cheapest, offerings.Cheapest().Price)
+		}
+	}
+	return cheapest < price && cheapest >= cloudprovider.ConsolidationPrice(ctx, price)
+}
@@ -0,0 +1,43 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consolidation
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/aws/karpenter-core/pkg/metrics"
+)
+
+const (
+	cloudProviderSubsystem = "cloudprovider"
+	capacityTypeLabel      = "capacity_type"
+)
+
+var (
+	SuppressedReplacements = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "consolidation_price_suppressed_replacements",
+			Help:      "Estimated number of nodes that consolidation doesn't replace with a cheaper offering because no offering is cheaper by more than the consolidation price thresholds, labeled by the capacity type of the node.",
+		},
+		[]string{capacityTypeLabel},
+	)
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(SuppressedReplacements)
+}
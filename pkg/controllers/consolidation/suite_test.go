@@ -0,0 +1,205 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consolidation_test
+
+import (
+	"context"
+	"math"
+	"sort"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	. "knative.dev/pkg/logging/testing"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/events"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+	nodepoolutil "github.com/aws/karpenter-core/pkg/utils/nodepool"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/cloudprovider"
+	"github.com/aws/karpenter/pkg/controllers/consolidation"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var awsEnv *test.Environment
+var cloudProvider *cloudprovider.CloudProvider
+var controller *consolidation.Controller
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Consolidation")
+}
+
+var _ = BeforeSuite(func() {
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	awsEnv = test.NewEnvironment(ctx, env)
+	cloudProvider = cloudprovider.New(awsEnv.InstanceTypesProvider, awsEnv.InstanceProvider, events.NewRecorder(&record.FakeRecorder{}),
+		env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider, awsEnv.QuotaProvider,
+		awsEnv.NotificationProvider)
+	controller = consolidation.NewController(env.Client, cloudProvider)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	ctx = settings.ToContext(ctx, test.Settings())
+	awsEnv.Reset()
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("Consolidation", func() {
+	var provisioner *v1alpha5.Provisioner
+	var machine *v1alpha5.Machine
+	var difference float64
+
+	BeforeEach(func() {
+		nodeTemplate := test.AWSNodeTemplate()
+		provisioner = test.Provisioner(coretest.ProvisionerOptions{
+			Consolidation: &v1alpha5.Consolidation{Enabled: lo.ToPtr(true)},
+			ProviderRef: &v1alpha5.MachineTemplateRef{
+				APIVersion: nodeTemplate.APIVersion,
+				Kind:       nodeTemplate.Kind,
+				Name:       nodeTemplate.Name,
+			},
+		})
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+		instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+		Expect(err).ToNot(HaveOccurred())
+
+		// The machine runs on the more expensive of two on-demand offerings in the same zone with different prices
+		type offering struct {
+			instanceType string
+			price        float64
+		}
+		var offerings []offering
+		for _, it := range instanceTypes {
+			if o, ok := it.Offerings.Get(v1alpha5.CapacityTypeOnDemand, "test-zone-1a"); ok && o.Available {
+				offerings = append(offerings, offering{instanceType: it.Name, price: o.Price})
+			}
+		}
+		sort.Slice(offerings, func(i, j int) bool { return offerings[i].price < offerings[j].price })
+		cheaper, _ := lo.Find(offerings, func(o offering) bool { return o.price > 0 })
+		current, found := lo.Find(offerings, func(o offering) bool { return o.price > cheaper.price })
+		Expect(found).To(BeTrue())
+		difference = current.price - cheaper.price
+
+		machine = coretest.Machine(v1alpha5.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					v1alpha5.ProvisionerNameLabelKey: provisioner.Name,
+					v1.LabelInstanceTypeStable:       current.instanceType,
+					v1.LabelTopologyZone:             "test-zone-1a",
+					v1alpha5.LabelCapacityType:       v1alpha5.CapacityTypeOnDemand,
+				},
+			},
+			Spec: v1alpha5.MachineSpec{
+				Requirements: []v1.NodeSelectorRequirement{{
+					Key:      v1.LabelInstanceTypeStable,
+					Operator: v1.NodeSelectorOpIn,
+					Values:   []string{current.instanceType, cheaper.instanceType},
+				}},
+			},
+			Status: v1alpha5.MachineStatus{
+				ProviderID: fake.ProviderID(fake.InstanceID()),
+			},
+		})
+	})
+	It("should count a node whose cheaper replacement is within the price threshold", func() {
+		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{ConsolidationPriceThreshold: lo.ToPtr(difference * 2)}))
+		ExpectApplied(ctx, env.Client, machine)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(suppressedReplacements(v1alpha5.CapacityTypeOnDemand)).To(BeNumerically("==", 1))
+	})
+	It("should not count a node whose cheaper replacement is cheaper by more than the price threshold", func() {
+		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{ConsolidationPriceThreshold: lo.ToPtr(difference / 2)}))
+		ExpectApplied(ctx, env.Client, machine)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(suppressedReplacements(v1alpha5.CapacityTypeOnDemand)).To(BeNumerically("==", 0))
+	})
+	It("should not count a node whose provisioner doesn't consolidate underutilized nodes", func() {
+		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{ConsolidationPriceThreshold: lo.ToPtr(difference * 2)}))
+		provisioner.Spec.Consolidation = nil
+		ExpectApplied(ctx, env.Client, provisioner, machine)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(suppressedReplacements(v1alpha5.CapacityTypeOnDemand)).To(BeNumerically("==", 0))
+	})
+	It("should not count any nodes without price thresholds", func() {
+		ExpectApplied(ctx, env.Client, machine)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(suppressedReplacements(v1alpha5.CapacityTypeOnDemand)).To(BeNumerically("==", 0))
+	})
+})
+
+var _ = Describe("ConsolidationCloudProvider", func() {
+	It("should price the nodes that consolidation replaces lower by the larger of the price thresholds", func() {
+		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+			ConsolidationPriceThreshold:        lo.ToPtr(0.01),
+			ConsolidationPriceThresholdPercent: lo.ToPtr(0.1),
+		}))
+		nodeTemplate := test.AWSNodeTemplate()
+		provisioner := test.Provisioner(coretest.ProvisionerOptions{
+			ProviderRef: &v1alpha5.MachineTemplateRef{
+				APIVersion: nodeTemplate.APIVersion,
+				Kind:       nodeTemplate.Kind,
+				Name:       nodeTemplate.Name,
+			},
+		})
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+		instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+		Expect(err).ToNot(HaveOccurred())
+		consolidationInstanceTypes, err := cloudprovider.NewConsolidationCloudProvider(cloudProvider).GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(consolidationInstanceTypes).To(HaveLen(len(instanceTypes)))
+		for i, it := range instanceTypes {
+			Expect(consolidationInstanceTypes[i].Name).To(Equal(it.Name))
+			for _, o := range it.Offerings {
+				// Nodes are priced with the first offering of their capacity type and zone
+				offering, ok := consolidationInstanceTypes[i].Offerings.Get(o.CapacityType, o.Zone)
+				Expect(ok).To(BeTrue())
+				Expect(offering.Price).To(BeNumerically("~", math.Max(o.Price-math.Max(0.01, o.Price*0.1), 0), 1e-9))
+			}
+			// Replacements and launches only use the available offerings, which keep their actual prices
+			Expect(consolidationInstanceTypes[i].Offerings.Available()).To(Equal(it.Offerings.Available()))
+		}
+	})
+})
+
+func suppressedReplacements(capacityType string) float64 {
+	metric, ok := FindMetricWithLabelValues("karpenter_cloudprovider_consolidation_price_suppressed_replacements", map[string]string{"capacity_type": capacityType})
+	Expect(ok).To(BeTrue())
+	return metric.GetGauge().GetValue()
+}
@@ -17,23 +17,52 @@ package controllers
 import (
 	"context"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/sts"
 	"k8s.io/utils/clock"
 	"knative.dev/pkg/logging"
+	crcache "sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	"github.com/aws/karpenter-core/pkg/events"
 	"github.com/aws/karpenter/pkg/apis/settings"
 	"github.com/aws/karpenter/pkg/cache"
 	"github.com/aws/karpenter/pkg/cloudprovider"
+	"github.com/aws/karpenter/pkg/controllers/awsconfiguration"
+	"github.com/aws/karpenter/pkg/controllers/catalog"
+	cloudwatchcontroller "github.com/aws/karpenter/pkg/controllers/cloudwatch"
+	"github.com/aws/karpenter/pkg/controllers/consolidation"
+	"github.com/aws/karpenter/pkg/controllers/elasticinference"
 	"github.com/aws/karpenter/pkg/controllers/interruption"
+	"github.com/aws/karpenter/pkg/controllers/memorycapacity"
+	"github.com/aws/karpenter/pkg/controllers/migration"
+	nodeclaimdrift "github.com/aws/karpenter/pkg/controllers/nodeclaim/drift"
 	nodeclaimgarbagecollection "github.com/aws/karpenter/pkg/controllers/nodeclaim/garbagecollection"
 	nodeclaimlink "github.com/aws/karpenter/pkg/controllers/nodeclaim/link"
+	nodeclaimregistration "github.com/aws/karpenter/pkg/controllers/nodeclaim/registration"
+	nodeclaimstatuscheck "github.com/aws/karpenter/pkg/controllers/nodeclaim/statuscheck"
 	"github.com/aws/karpenter/pkg/controllers/nodeclass"
+	"github.com/aws/karpenter/pkg/controllers/noderole"
+	"github.com/aws/karpenter/pkg/controllers/permission"
+	"github.com/aws/karpenter/pkg/controllers/savings"
+	settingscontroller "github.com/aws/karpenter/pkg/controllers/settings"
+	"github.com/aws/karpenter/pkg/controllers/ssmagent"
 	"github.com/aws/karpenter/pkg/providers/amifamily"
+	"github.com/aws/karpenter/pkg/providers/instance"
+	"github.com/aws/karpenter/pkg/providers/instanceprofile"
+	"github.com/aws/karpenter/pkg/providers/instancetype"
+	"github.com/aws/karpenter/pkg/providers/launchtemplate"
 	"github.com/aws/karpenter/pkg/providers/pricing"
 	"github.com/aws/karpenter/pkg/providers/securitygroup"
+	"github.com/aws/karpenter/pkg/providers/spotadvisor"
 	"github.com/aws/karpenter/pkg/providers/subnet"
 	"github.com/aws/karpenter/pkg/utils/project"
 
@@ -41,24 +70,64 @@ import (
 )
 
 func NewControllers(ctx context.Context, sess *session.Session, clk clock.Clock, kubeClient client.Client, recorder events.Recorder,
-	unavailableOfferings *cache.UnavailableOfferings, cloudProvider *cloudprovider.CloudProvider, subnetProvider *subnet.Provider,
-	securityGroupProvider *securitygroup.Provider, pricingProvider *pricing.Provider, amiProvider *amifamily.Provider) []controller.Controller {
+	unavailableOfferings *cache.UnavailableOfferings, observedMemoryCapacities *cache.ObservedMemoryCapacities, cloudProvider *cloudprovider.CloudProvider, subnetProvider *subnet.Provider,
+	securityGroupProvider *securitygroup.Provider, pricingProvider *pricing.Provider, spotAdvisorProvider *spotadvisor.Provider, amiProvider *amifamily.Provider,
+	launchTemplateProvider *launchtemplate.Provider, instanceTypeProvider *instancetype.Provider, instanceProfileProvider *instanceprofile.Provider,
+	instanceProvider *instance.Provider, awsConfigurationCache crcache.Cache) []controller.Controller {
 
 	logging.FromContext(ctx).With("version", project.Version).Debugf("discovered version")
 
 	linkController := nodeclaimlink.NewController(kubeClient, cloudProvider)
 	controllers := []controller.Controller{
-		nodeclass.NewNodeTemplateController(kubeClient, subnetProvider, securityGroupProvider, amiProvider),
+		nodeclass.NewNodeTemplateController(kubeClient, subnetProvider, securityGroupProvider, amiProvider, launchTemplateProvider, instanceProfileProvider),
 		linkController,
-		nodeclaimgarbagecollection.NewController(kubeClient, cloudProvider, linkController),
+		nodeclaimgarbagecollection.NewController(kubeClient, cloudProvider, recorder, linkController),
+		nodeclaimregistration.NewController(clk, kubeClient, recorder, unavailableOfferings),
+		nodeclaimdrift.NewController(kubeClient, recorder, cloudProvider, instanceProvider),
+		savings.NewController(kubeClient, pricingProvider),
+		consolidation.NewController(kubeClient, cloudProvider),
+		instancetype.NewController(kubeClient, recorder, instanceTypeProvider),
+		elasticinference.NewController(kubeClient, recorder),
+		ssmagent.NewController(kubeClient, ssm.New(sess)),
+		settingscontroller.NewController(kubeClient, recorder, sqs.New(sess)),
+		launchtemplate.NewController(kubeClient, eks.New(sess), launchTemplateProvider),
+	}
+	// The cache is only created if the AWSConfiguration CRD exists
+	if awsConfigurationCache != nil {
+		controllers = append(controllers, awsconfiguration.NewController(kubeClient, awsConfigurationCache, eks.New(sess), sqs.New(sess), pricingProvider))
 	}
 	if settings.FromContext(ctx).InterruptionQueueName != "" {
 		controllers = append(controllers, interruption.NewController(kubeClient, clk, recorder, interruption.NewSQSProvider(sqs.New(sess)), unavailableOfferings))
 	}
+	if settings.FromContext(ctx).EnableVMMemoryOverheadLearning {
+		controllers = append(controllers, memorycapacity.NewController(kubeClient, observedMemoryCapacities))
+	}
+	if settings.FromContext(ctx).EnableResourceGarbageCollection {
+		controllers = append(controllers, nodeclaimgarbagecollection.NewResourceController(clk, ec2.New(sess)))
+	}
+	if settings.FromContext(ctx).EnableNodeTemplateMigration {
+		controllers = append(controllers, migration.NewController(kubeClient))
+	}
+	if settings.FromContext(ctx).EnableNodeRoleRegistration {
+		controllers = append(controllers, noderole.NewNodeTemplateController(kubeClient, eks.New(sess), iam.New(sess)))
+	}
+	if settings.FromContext(ctx).EnableInstanceTypeCatalog {
+		controllers = append(controllers, catalog.NewController(kubeClient, cloudProvider, instanceTypeProvider))
+	}
+	if settings.FromContext(ctx).EnableInstanceStatusChecks {
+		controllers = append(controllers, nodeclaimstatuscheck.NewController(kubeClient, recorder, ec2.New(sess)))
+	}
+	if settings.FromContext(ctx).CloudWatchMetricsNamespace != "" {
+		controllers = append(controllers, cloudwatchcontroller.NewController(kubeClient, cloudwatch.New(sess), pricingProvider, crmetrics.Registry))
+	}
 	if settings.FromContext(ctx).IsolatedVPC {
-		logging.FromContext(ctx).Infof("assuming isolated VPC, pricing information will not be updated")
+		logging.FromContext(ctx).Infof("assuming isolated VPC, pricing information and spot interruption rates will not be updated and IAM permissions will not be reported")
 	} else {
-		controllers = append(controllers, pricing.NewController(pricingProvider))
+		controllers = append(controllers,
+			pricing.NewController(pricingProvider),
+			spotadvisor.NewController(spotAdvisorProvider),
+			permission.NewController(kubeClient, sts.New(sess), iam.New(sess), aws.StringValue(sess.Config.Region)),
+		)
 	}
 	return controllers
 }
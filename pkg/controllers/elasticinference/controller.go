@@ -0,0 +1,77 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package elasticinference
+
+import (
+	"context"
+
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/events"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+)
+
+// Controller publishes a warning event on pending pods that request Elastic Inference accelerators. Elastic Inference
+// is deprecated and no instance type offers the resource, so Karpenter never launches capacity for these pods, which
+// would otherwise only be reported as incompatible with every instance type.
+type Controller struct {
+	recorder events.Recorder
+}
+
+func NewController(kubeClient client.Client, recorder events.Recorder) corecontroller.Controller {
+	return corecontroller.Typed[*v1.Pod](kubeClient, &Controller{
+		recorder: recorder,
+	})
+}
+
+func (c *Controller) Name() string {
+	return "pod.elasticinference"
+}
+
+func (c *Controller) Reconcile(_ context.Context, pod *v1.Pod) (reconcile.Result, error) {
+	if pod.Spec.NodeName != "" || !pod.DeletionTimestamp.IsZero() || !RequestsElasticInference(pod) {
+		return reconcile.Result{}, nil
+	}
+	c.recorder.Publish(PodRequestsElasticInference(pod))
+	return reconcile.Result{}, nil
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.Adapt(controllerruntime.
+		NewControllerManagedBy(m).
+		Named(c.Name()).
+		For(&v1.Pod{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(o client.Object) bool {
+			return RequestsElasticInference(o.(*v1.Pod))
+		}))).
+		WithOptions(controller.Options{MaxConcurrentReconciles: 10}))
+}
+
+// RequestsElasticInference returns true if any container of the pod requests or limits Elastic Inference accelerators
+func RequestsElasticInference(pod *v1.Pod) bool {
+	return lo.ContainsBy(append(pod.Spec.InitContainers, pod.Spec.Containers...), func(container v1.Container) bool {
+		_, requested := container.Resources.Requests[v1beta1.ResourceAWSElasticInference]
+		_, limited := container.Resources.Limits[v1beta1.ResourceAWSElasticInference]
+		return requested || limited
+	})
+}
@@ -0,0 +1,35 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package elasticinference
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/aws/karpenter-core/pkg/events"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+)
+
+func PodRequestsElasticInference(pod *v1.Pod) events.Event {
+	return events.Event{
+		InvolvedObject: pod,
+		Type:           v1.EventTypeWarning,
+		Reason:         "ElasticInferenceUnsupported",
+		Message: fmt.Sprintf("Pod requests %s, but Elastic Inference is deprecated and no instance type can be launched for it, request %s (AWS Inferentia) or %s instead",
+			v1beta1.ResourceAWSElasticInference, v1beta1.ResourceAWSNeuron, v1beta1.ResourceNVIDIAGPU),
+		DedupeValues: []string{string(pod.UID)},
+	}
+}
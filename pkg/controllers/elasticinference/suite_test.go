@@ -0,0 +1,104 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package elasticinference_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	. "knative.dev/pkg/logging/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/controllers/elasticinference"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var recorder *coretest.EventRecorder
+var controller corecontroller.Controller
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ElasticInference")
+}
+
+var _ = BeforeSuite(func() {
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	recorder = coretest.NewEventRecorder()
+	controller = elasticinference.NewController(env.Client, recorder)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	recorder.Reset()
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("ElasticInference", func() {
+	It("should publish an event for pending pods that request Elastic Inference accelerators", func() {
+		pod := coretest.UnschedulablePod(coretest.PodOptions{
+			ResourceRequirements: v1.ResourceRequirements{
+				Limits: v1.ResourceList{v1beta1.ResourceAWSElasticInference: resource.MustParse("1")},
+			},
+		})
+		ExpectApplied(ctx, env.Client, pod)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(pod))
+		Expect(recorder.Calls("ElasticInferenceUnsupported")).To(Equal(1))
+	})
+	It("should not publish an event for pods that don't request Elastic Inference accelerators", func() {
+		pod := coretest.UnschedulablePod(coretest.PodOptions{
+			ResourceRequirements: v1.ResourceRequirements{
+				Limits: v1.ResourceList{v1beta1.ResourceAWSNeuron: resource.MustParse("1")},
+			},
+		})
+		ExpectApplied(ctx, env.Client, pod)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(pod))
+		Expect(recorder.Calls("ElasticInferenceUnsupported")).To(Equal(0))
+	})
+	It("should not publish an event for pods that are bound to a node", func() {
+		pod := coretest.Pod(coretest.PodOptions{
+			NodeName: "node",
+			ResourceRequirements: v1.ResourceRequirements{
+				Limits: v1.ResourceList{v1beta1.ResourceAWSElasticInference: resource.MustParse("1")},
+			},
+		})
+		ExpectApplied(ctx, env.Client, pod)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(pod))
+		Expect(recorder.Calls("ElasticInferenceUnsupported")).To(Equal(0))
+	})
+})
@@ -17,12 +17,18 @@ package interruption
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	sqsapi "github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/samber/lo"
 	"go.uber.org/multierr"
 	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/utils/clock"
 	"knative.dev/pkg/logging"
@@ -35,13 +41,17 @@ import (
 	"github.com/aws/karpenter/pkg/apis/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/cache"
+	"github.com/aws/karpenter/pkg/cloudprovider"
 	interruptionevents "github.com/aws/karpenter/pkg/controllers/interruption/events"
 	"github.com/aws/karpenter/pkg/controllers/interruption/messages"
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages/spotinterruption"
 	"github.com/aws/karpenter/pkg/controllers/interruption/messages/statechange"
 	"github.com/aws/karpenter/pkg/utils"
 
+	terminatorevents "github.com/aws/karpenter-core/pkg/controllers/termination/terminator/events"
 	"github.com/aws/karpenter-core/pkg/events"
 	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	nodeutil "github.com/aws/karpenter-core/pkg/utils/node"
 	nodeclaimutil "github.com/aws/karpenter-core/pkg/utils/nodeclaim"
 )
 
@@ -52,6 +62,14 @@ const (
 	NoAction       Action = "NoAction"
 )
 
+// The reasons that a message is discarded without being handled
+const (
+	DiscardReasonParseFailure    = "ParseFailure"
+	DiscardReasonMaxReceiveCount = "MaxReceiveCount"
+)
+
+const podDeletionCostAnnotationKey = "controller.kubernetes.io/pod-deletion-cost"
+
 // Controller is an AWS interruption controller.
 // It continually polls an SQS queue for events from aws.ec2 and aws.health that
 // trigger node health events or node spot interruption/rebalance events.
@@ -88,6 +106,7 @@ func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconc
 	if err != nil {
 		return reconcile.Result{}, fmt.Errorf("getting messages from queue, %w", err)
 	}
+	messageBatchSize.Observe(float64(len(sqsMessages)))
 	if len(sqsMessages) == 0 {
 		return reconcile.Result{}, nil
 	}
@@ -103,12 +122,19 @@ func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconc
 	workqueue.ParallelizeUntil(ctx, 10, len(sqsMessages), func(i int) {
 		msg, e := c.parseMessage(sqsMessages[i])
 		if e != nil {
-			// If we fail to parse, then we should delete the message but still log the error
+			// If we fail to parse, then we should discard the message but still log the error
 			logging.FromContext(ctx).Errorf("parsing message, %v", e)
-			errs[i] = c.deleteMessage(ctx, sqsMessages[i])
+			parseFailures.Inc()
+			errs[i] = c.discardMessage(ctx, sqsMessages[i], DiscardReasonParseFailure)
 			return
 		}
 		if e = c.handleMessage(ctx, nodeClaimInstanceIDMap, nodeInstanceIDMap, msg); e != nil {
+			// A message that keeps failing would otherwise be retried until it expires from the queue
+			if receiveCount(sqsMessages[i]) >= settings.FromContext(ctx).InterruptionMaxReceiveCount {
+				logging.FromContext(ctx).Errorf("handling message, discarding after %d receives, %v", receiveCount(sqsMessages[i]), e)
+				errs[i] = c.discardMessage(ctx, sqsMessages[i], DiscardReasonMaxReceiveCount)
+				return
+			}
 			errs[i] = fmt.Errorf("handling message, %w", e)
 			return
 		}
@@ -144,6 +170,10 @@ func (c *Controller) handleMessage(ctx context.Context, nodeClaimInstanceIDMap m
 
 	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("messageKind", msg.Kind()))
 	receivedMessages.WithLabelValues(string(msg.Kind())).Inc()
+	start := time.Now()
+	defer func() {
+		messageProcessingDuration.WithLabelValues(string(msg.Kind())).Observe(time.Since(start).Seconds())
+	}()
 
 	if msg.Kind() == messages.NoOpKind {
 		return nil
@@ -174,6 +204,31 @@ func (c *Controller) deleteMessage(ctx context.Context, msg *sqsapi.Message) err
 	return nil
 }
 
+// discardMessage removes a message that can't be handled from the queue. The message is redriven to the dead-letter
+// queue if one is configured so that it can be inspected.
+func (c *Controller) discardMessage(ctx context.Context, msg *sqsapi.Message, reason string) error {
+	redriven := settings.FromContext(ctx).InterruptionDeadLetterQueueName != ""
+	if redriven {
+		if err := c.sqsProvider.RedriveSQSMessage(ctx, msg); err != nil {
+			return fmt.Errorf("redriving sqs message, %w", err)
+		}
+		deletedMessages.Inc()
+	} else if err := c.deleteMessage(ctx, msg); err != nil {
+		return err
+	}
+	discardedMessages.WithLabelValues(reason, strconv.FormatBool(redriven)).Inc()
+	return nil
+}
+
+// receiveCount returns the number of times that the message has been received from the queue, including this time
+func receiveCount(msg *sqsapi.Message) int {
+	count, err := strconv.Atoi(aws.StringValue(msg.Attributes[sqsapi.MessageSystemAttributeNameApproximateReceiveCount]))
+	if err != nil {
+		return 1
+	}
+	return count
+}
+
 // handleNodeClaim retrieves the action for the message and then performs the appropriate action against the node
 func (c *Controller) handleNodeClaim(ctx context.Context, msg messages.Message, nodeClaim *v1beta1.NodeClaim, node *v1.Node) error {
 	action := actionForMessage(msg)
@@ -184,7 +239,7 @@ func (c *Controller) handleNodeClaim(ctx context.Context, msg messages.Message,
 	}
 
 	// Record metric and event for this action
-	c.notifyForMessage(msg, nodeClaim, node)
+	c.notifyForMessage(ctx, msg, nodeClaim, node)
 	actionsPerformed.WithLabelValues(string(action)).Inc()
 
 	// Mark the offering as unavailable in the ICE cache since we got a spot interruption warning
@@ -194,29 +249,42 @@ func (c *Controller) handleNodeClaim(ctx context.Context, msg messages.Message,
 		if zone != "" && instanceType != "" {
 			c.unavailableOfferingsCache.MarkUnavailable(ctx, string(msg.Kind()), instanceType, zone, v1alpha1.CapacityTypeSpot)
 		}
+		// Evict the pods in the order of their deletion cost before the node's termination drains the rest
+		if node != nil && action != NoAction && nodeClaim.DeletionTimestamp.IsZero() {
+			c.evictPods(ctx, node)
+		}
 	}
 	if action != NoAction {
-		return c.deleteNodeClaim(ctx, nodeClaim, node)
+		// Scheduled changes are raised by AWS Health rather than by EC2 reclaiming capacity
+		reason := lo.Ternary(msg.Kind() == messages.ScheduledChangeKind, cloudprovider.TerminationReasonHealth, cloudprovider.TerminationReasonInterruption)
+		return c.deleteNodeClaim(ctx, msg, nodeClaim, node, reason)
 	}
 	return nil
 }
 
 // deleteNodeClaim removes the NodeClaim from the api-server
-func (c *Controller) deleteNodeClaim(ctx context.Context, nodeClaim *v1beta1.NodeClaim, node *v1.Node) error {
+func (c *Controller) deleteNodeClaim(ctx context.Context, msg messages.Message, nodeClaim *v1beta1.NodeClaim, node *v1.Node, reason cloudprovider.TerminationReason) error {
 	if !nodeClaim.DeletionTimestamp.IsZero() {
 		return nil
 	}
+	// Attribute the termination so that the cloudprovider can report it once the instance is deleted
+	stored := nodeClaim.DeepCopy()
+	nodeClaim.Annotations = lo.Assign(nodeClaim.Annotations, map[string]string{cloudprovider.TerminationReasonAnnotationKey(nodeClaim): string(reason)})
+	if err := nodeclaimutil.Patch(ctx, c.kubeClient, stored, nodeClaim); err != nil {
+		return client.IgnoreNotFound(fmt.Errorf("annotating the node with the termination reason, %w", err))
+	}
 	if err := nodeclaimutil.Delete(ctx, c.kubeClient, nodeClaim); err != nil {
 		return client.IgnoreNotFound(fmt.Errorf("deleting the node on interruption message, %w", err))
 	}
 	logging.FromContext(ctx).Infof("initiating delete from interruption message")
+	actionLatency.WithLabelValues(string(msg.Kind())).Observe(time.Since(msg.StartTime()).Seconds())
 	c.recorder.Publish(interruptionevents.TerminatingOnInterruption(node, nodeClaim)...)
 	nodeclaimutil.TerminatedCounter(nodeClaim, terminationReasonLabel).Inc()
 	return nil
 }
 
 // notifyForMessage publishes the relevant alert based on the message kind
-func (c *Controller) notifyForMessage(msg messages.Message, nodeClaim *v1beta1.NodeClaim, n *v1.Node) {
+func (c *Controller) notifyForMessage(ctx context.Context, msg messages.Message, nodeClaim *v1beta1.NodeClaim, n *v1.Node) {
 	switch msg.Kind() {
 	case messages.RebalanceRecommendationKind:
 		c.recorder.Publish(interruptionevents.RebalanceRecommendation(n, nodeClaim)...)
@@ -225,7 +293,11 @@ func (c *Controller) notifyForMessage(msg messages.Message, nodeClaim *v1beta1.N
 		c.recorder.Publish(interruptionevents.Unhealthy(n, nodeClaim)...)
 
 	case messages.SpotInterruptionKind:
-		c.recorder.Publish(interruptionevents.SpotInterrupted(n, nodeClaim)...)
+		deadline := msg.(spotinterruption.Message).Deadline()
+		c.recorder.Publish(interruptionevents.SpotInterrupted(n, nodeClaim, deadline)...)
+		if n != nil {
+			c.notifyPods(ctx, n, deadline)
+		}
 
 	case messages.StateChangeKind:
 		typed := msg.(statechange.Message)
@@ -239,6 +311,65 @@ func (c *Controller) notifyForMessage(msg messages.Message, nodeClaim *v1beta1.N
 	}
 }
 
+// notifyPods publishes the deadline of a spot interruption to the pods of the node, in the order of their pod deletion
+// cost, so that the pods that are cheapest to lose are warned first
+func (c *Controller) notifyPods(ctx context.Context, node *v1.Node, deadline time.Time) {
+	pods, err := c.podsByDeletionCost(ctx, node)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("listing pods to warn of the interruption, %s", err)
+		return
+	}
+	for _, pod := range pods {
+		c.recorder.Publish(interruptionevents.PodSpotInterrupted(pod, node, deadline))
+	}
+}
+
+// evictPods evicts the pods of a spot interrupted node in the order of their pod deletion cost, so that the pods that
+// are cheapest to lose are evicted first. Pods whose eviction would violate a PodDisruptionBudget are left to the
+// termination of the node, which retries their eviction.
+func (c *Controller) evictPods(ctx context.Context, node *v1.Node) {
+	pods, err := c.podsByDeletionCost(ctx, node)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("listing pods to evict on the interruption, %s", err)
+		return
+	}
+	for _, pod := range pods {
+		if err := c.kubeClient.SubResource("eviction").Create(ctx, pod, &policyv1.Eviction{
+			ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+		}); err != nil {
+			// status codes for the eviction API are defined here:
+			// https://kubernetes.io/docs/concepts/scheduling-eviction/api-eviction/#how-api-initiated-eviction-works
+			if apierrors.IsNotFound(err) || apierrors.IsTooManyRequests(err) {
+				continue
+			}
+			logging.FromContext(ctx).With("pod", client.ObjectKeyFromObject(pod)).Errorf("evicting pod, %s", err)
+			continue
+		}
+		c.recorder.Publish(terminatorevents.EvictPod(pod))
+	}
+}
+
+// podsByDeletionCost lists the pods of the node that need to be rescheduled, ordered by their pod deletion cost
+func (c *Controller) podsByDeletionCost(ctx context.Context, node *v1.Node) ([]*v1.Pod, error) {
+	pods, err := nodeutil.GetNodePods(ctx, c.kubeClient, node)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(pods, func(i, j int) bool {
+		return podDeletionCost(pods[i]) < podDeletionCost(pods[j])
+	})
+	return pods, nil
+}
+
+// podDeletionCost returns the controller.kubernetes.io/pod-deletion-cost of the pod, which defaults to 0
+func podDeletionCost(pod *v1.Pod) int64 {
+	cost, err := strconv.ParseInt(pod.Annotations[podDeletionCostAnnotationKey], 10, 32)
+	if err != nil {
+		return 0
+	}
+	return cost
+}
+
 // makeNodeClaimInstanceIDMap builds a map between the instance id that is stored in the
 // NodeClaim .status.providerID and the NodeClaim
 func (c *Controller) makeNodeClaimInstanceIDMap(ctx context.Context) (map[string]*v1beta1.NodeClaim, error) {
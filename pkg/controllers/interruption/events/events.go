@@ -15,6 +15,9 @@ limitations under the License.
 package events
 
 import (
+	"fmt"
+	"time"
+
 	v1 "k8s.io/api/core/v1"
 
 	"github.com/aws/karpenter-core/pkg/apis/v1beta1"
@@ -22,14 +25,15 @@ import (
 	machineutil "github.com/aws/karpenter-core/pkg/utils/machine"
 )
 
-func SpotInterrupted(node *v1.Node, nodeClaim *v1beta1.NodeClaim) (evts []events.Event) {
+func SpotInterrupted(node *v1.Node, nodeClaim *v1beta1.NodeClaim, deadline time.Time) (evts []events.Event) {
+	message := fmt.Sprintf("Spot interruption warning was triggered, the instance will be reclaimed at %s", deadline.UTC().Format(time.RFC3339))
 	if nodeClaim.IsMachine {
 		machine := machineutil.NewFromNodeClaim(nodeClaim)
 		evts = append(evts, events.Event{
 			InvolvedObject: machine,
 			Type:           v1.EventTypeWarning,
 			Reason:         "SpotInterrupted",
-			Message:        "Spot interruption warning was triggered",
+			Message:        message,
 			DedupeValues:   []string{string(machine.UID)},
 		})
 	} else {
@@ -37,7 +41,7 @@ func SpotInterrupted(node *v1.Node, nodeClaim *v1beta1.NodeClaim) (evts []events
 			InvolvedObject: nodeClaim,
 			Type:           v1.EventTypeWarning,
 			Reason:         "SpotInterrupted",
-			Message:        "Spot interruption warning was triggered",
+			Message:        message,
 			DedupeValues:   []string{string(nodeClaim.UID)},
 		})
 	}
@@ -46,13 +50,25 @@ func SpotInterrupted(node *v1.Node, nodeClaim *v1beta1.NodeClaim) (evts []events
 			InvolvedObject: node,
 			Type:           v1.EventTypeWarning,
 			Reason:         "SpotInterrupted",
-			Message:        "Spot interruption warning was triggered",
+			Message:        message,
 			DedupeValues:   []string{string(node.UID)},
 		})
 	}
 	return evts
 }
 
+// PodSpotInterrupted warns the pods of an interrupted node of the deadline, so that they can checkpoint before the
+// instance is reclaimed
+func PodSpotInterrupted(pod *v1.Pod, node *v1.Node, deadline time.Time) events.Event {
+	return events.Event{
+		InvolvedObject: pod,
+		Type:           v1.EventTypeWarning,
+		Reason:         "SpotInterrupted",
+		Message:        fmt.Sprintf("Spot interruption warning was triggered for node %s, the instance will be reclaimed at %s", node.Name, deadline.UTC().Format(time.RFC3339)),
+		DedupeValues:   []string{string(pod.UID)},
+	}
+}
+
 func RebalanceRecommendation(node *v1.Node, nodeClaim *v1beta1.NodeClaim) (evts []events.Event) {
 	if nodeClaim.IsMachine {
 		machine := machineutil.NewFromNodeClaim(nodeClaim)
@@ -31,6 +31,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sqs"
+	prometheusmodel "github.com/prometheus/client_model/go"
 	"github.com/samber/lo"
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
@@ -41,12 +42,12 @@ import (
 	"knative.dev/pkg/logging"
 	controllerruntime "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	"github.com/aws/karpenter-core/pkg/operator/scheme"
 	"github.com/aws/karpenter/pkg/apis/settings"
 	awscache "github.com/aws/karpenter/pkg/cache"
 	"github.com/aws/karpenter/pkg/controllers/interruption"
-	"github.com/aws/karpenter/pkg/controllers/interruption/events"
 	"github.com/aws/karpenter/pkg/fake"
 	"github.com/aws/karpenter/pkg/test"
 
@@ -147,7 +148,7 @@ func benchmarkNotificationController(b *testing.B, messageCount int) {
 	}()
 
 	select {
-	case <-providers.monitorMessagesProcessed(ctx, recorder, messageCount):
+	case <-providers.monitorMessagesProcessed(ctx, messageCount):
 	case err = <-managerErr:
 		b.Fatalf("running manager, %v", err)
 	}
@@ -156,6 +157,11 @@ func benchmarkNotificationController(b *testing.B, messageCount int) {
 	b.ReportMetric(float64(messageCount), "Messages")
 	b.ReportMetric(duration.Seconds(), "TotalDurationInSeconds")
 	b.ReportMetric(float64(messageCount)/duration.Seconds(), "Messages/Second")
+	for kind, histogram := range processingDurations() {
+		if histogram.GetSampleCount() > 0 {
+			b.ReportMetric(histogram.GetSampleSum()/float64(histogram.GetSampleCount()), fmt.Sprintf("%sSecondsPerMessage", kind))
+		}
+	}
 }
 
 type providerSet struct {
@@ -213,16 +219,14 @@ func (p *providerSet) provisionMessages(ctx context.Context, messages ...interfa
 	return multierr.Combine(errs...)
 }
 
-func (p *providerSet) monitorMessagesProcessed(ctx context.Context, eventRecorder *coretest.EventRecorder, expectedProcessed int) <-chan struct{} {
+func (p *providerSet) monitorMessagesProcessed(ctx context.Context, expectedProcessed int) <-chan struct{} {
 	done := make(chan struct{})
 	totalProcessed := 0
 	go func() {
 		for totalProcessed < expectedProcessed {
-			totalProcessed = eventRecorder.Calls(events.InstanceStopping(coretest.Node()).Reason) +
-				eventRecorder.Calls(events.InstanceTerminating(coretest.Node()).Reason) +
-				eventRecorder.Calls(events.InstanceUnhealthy(coretest.Node()).Reason) +
-				eventRecorder.Calls(events.InstanceRebalanceRecommendation(coretest.Node()).Reason) +
-				eventRecorder.Calls(events.InstanceSpotInterrupted(coretest.Node()).Reason)
+			totalProcessed = lo.Sum(lo.MapToSlice(processingDurations(), func(_ string, histogram *prometheusmodel.Histogram) int {
+				return int(histogram.GetSampleCount())
+			}))
 			logging.FromContext(ctx).With("processed-message-count", totalProcessed).Infof("processed messages from the queue")
 			time.Sleep(time.Second)
 		}
@@ -231,6 +235,28 @@ func (p *providerSet) monitorMessagesProcessed(ctx context.Context, eventRecorde
 	return done
 }
 
+// processingDurations returns the histogram of the time taken to act on the messages of each kind
+func processingDurations() map[string]*prometheusmodel.Histogram {
+	histograms := map[string]*prometheusmodel.Histogram{}
+	families, err := crmetrics.Registry.Gather()
+	if err != nil {
+		return histograms
+	}
+	for _, family := range families {
+		if family.GetName() != "karpenter_interruption_message_processing_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "message_type" {
+					histograms[label.GetValue()] = metric.GetHistogram()
+				}
+			}
+		}
+	}
+	return histograms
+}
+
 func provisionNodes(ctx context.Context, kubeClient client.Client, nodes []*v1.Node) error {
 	errs := make([]error, len(nodes))
 	workqueue.ParallelizeUntil(ctx, 20, len(nodes), func(i int) {
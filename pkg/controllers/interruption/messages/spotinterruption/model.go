@@ -15,9 +15,14 @@ limitations under the License.
 package spotinterruption
 
 import (
+	"time"
+
 	"github.com/aws/karpenter/pkg/controllers/interruption/messages"
 )
 
+// NoticePeriod is how long EC2 waits after a spot interruption warning before it reclaims the instance
+const NoticePeriod = 2 * time.Minute
+
 // Message contains the properties defined in AWS EventBridge schema
 // aws.ec2@EC2SpotInstanceInterruptionWarning v0.
 type Message struct {
@@ -38,3 +43,8 @@ func (m Message) EC2InstanceIDs() []string {
 func (Message) Kind() messages.Kind {
 	return messages.SpotInterruptionKind
 }
+
+// Deadline is when EC2 reclaims the instance
+func (m Message) Deadline() time.Time {
+	return m.StartTime().Add(NoticePeriod)
+}
@@ -26,6 +26,8 @@ const (
 	messageTypeLabel       = "message_type"
 	actionTypeLabel        = "action_type"
 	terminationReasonLabel = "interruption"
+	discardReasonLabel     = "reason"
+	redrivenLabel          = "redriven"
 )
 
 var (
@@ -46,6 +48,23 @@ var (
 			Help:      "Count of messages deleted from the SQS queue.",
 		},
 	)
+	parseFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: interruptionSubsystem,
+			Name:      "parse_failures",
+			Help:      "Count of messages received from the SQS queue that couldn't be parsed.",
+		},
+	)
+	discardedMessages = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: interruptionSubsystem,
+			Name:      "discarded_messages",
+			Help:      "Count of messages removed from the SQS queue without being handled. Labeled by the reason and whether the message was redriven to the dead-letter queue.",
+		},
+		[]string{discardReasonLabel, redrivenLabel},
+	)
 	messageLatency = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
 			Namespace: metrics.Namespace,
@@ -55,6 +74,35 @@ var (
 			Buckets:   metrics.DurationBuckets(),
 		},
 	)
+	messageBatchSize = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: interruptionSubsystem,
+			Name:      "message_batch_size",
+			Help:      "Number of messages received from the SQS queue in each poll.",
+			Buckets:   prometheus.LinearBuckets(0, 1, 11),
+		},
+	)
+	messageProcessingDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: interruptionSubsystem,
+			Name:      "message_processing_duration_seconds",
+			Help:      "Length of time taken to act on a message once it's parsed. Labeled by message type.",
+			Buckets:   metrics.DurationBuckets(),
+		},
+		[]string{messageTypeLabel},
+	)
+	actionLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: interruptionSubsystem,
+			Name:      "action_latency_seconds",
+			Help:      "Length of time between the event in the message and the deletion of the NodeClaim, which cordons and drains its node. Labeled by message type.",
+			Buckets:   metrics.DurationBuckets(),
+		},
+		[]string{messageTypeLabel},
+	)
 	actionsPerformed = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: metrics.Namespace,
@@ -67,5 +115,6 @@ var (
 )
 
 func init() {
-	crmetrics.Registry.MustRegister(receivedMessages, deletedMessages, messageLatency, actionsPerformed)
+	crmetrics.Registry.MustRegister(receivedMessages, deletedMessages, parseFailures, discardedMessages, messageLatency, messageBatchSize,
+		messageProcessingDuration, actionLatency, actionsPerformed)
 }
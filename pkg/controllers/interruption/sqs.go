@@ -89,6 +89,7 @@ func (s *SQSProvider) GetSQSMessages(ctx context.Context) ([]*sqs.Message, error
 		WaitTimeSeconds:     aws.Int64(20), // Seconds, maximum for long polling
 		AttributeNames: []*string{
 			aws.String(sqs.MessageSystemAttributeNameSentTimestamp),
+			aws.String(sqs.MessageSystemAttributeNameApproximateReceiveCount),
 		},
 		MessageAttributeNames: []*string{
 			aws.String(sqs.QueueAttributeNameAll),
@@ -124,6 +125,26 @@ func (s *SQSProvider) SendMessage(ctx context.Context, body interface{}) (string
 	return aws.StringValue(result.MessageId), nil
 }
 
+// RedriveSQSMessage sends the message to the dead-letter queue and removes it from the interruption queue. The url of the
+// dead-letter queue is looked up on each call since messages are only redriven when they can't be handled.
+func (s *SQSProvider) RedriveSQSMessage(ctx context.Context, msg *sqs.Message) error {
+	ret, err := s.client.GetQueueUrlWithContext(ctx, &sqs.GetQueueUrlInput{
+		QueueName: aws.String(settings.FromContext(ctx).InterruptionDeadLetterQueueName),
+	})
+	if err != nil {
+		return fmt.Errorf("fetching dead-letter queue url, %w", err)
+	}
+	input := &sqs.SendMessageInput{
+		MessageAttributes: msg.MessageAttributes,
+		MessageBody:       msg.Body,
+		QueueUrl:          ret.QueueUrl,
+	}
+	if _, err = s.client.SendMessageWithContext(ctx, input); err != nil {
+		return fmt.Errorf("sending message to dead-letter queue, %w", err)
+	}
+	return s.DeleteSQSMessage(ctx, msg)
+}
+
 func (s *SQSProvider) DeleteSQSMessage(ctx context.Context, msg *sqs.Message) error {
 	queueURL, err := s.DiscoverQueueURL(ctx)
 	if err != nil {
@@ -18,6 +18,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"testing"
 	"time"
 
@@ -30,8 +31,8 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/uuid"
-	"k8s.io/client-go/tools/record"
 	clock "k8s.io/utils/clock/testing"
 	. "knative.dev/pkg/logging/testing"
 	_ "knative.dev/pkg/system/testing"
@@ -71,6 +72,7 @@ var sqsProvider *interruption.SQSProvider
 var unavailableOfferingsCache *awscache.UnavailableOfferings
 var fakeClock *clock.FakeClock
 var controller *interruption.Controller
+var recorder *coretest.EventRecorder
 
 func TestAPIs(t *testing.T) {
 	ctx = TestContextWithLogger(t)
@@ -84,7 +86,8 @@ var _ = BeforeSuite(func() {
 	unavailableOfferingsCache = awscache.NewUnavailableOfferings()
 	sqsapi = &fake.SQSAPI{}
 	sqsProvider = interruption.NewSQSProvider(sqsapi)
-	controller = interruption.NewController(env.Client, fakeClock, events.NewRecorder(&record.FakeRecorder{}), sqsProvider, unavailableOfferingsCache)
+	recorder = coretest.NewEventRecorder()
+	controller = interruption.NewController(env.Client, fakeClock, recorder, sqsProvider, unavailableOfferingsCache)
 })
 
 var _ = AfterSuite(func() {
@@ -93,7 +96,8 @@ var _ = AfterSuite(func() {
 
 var _ = BeforeEach(func() {
 	sqsProvider = interruption.NewSQSProvider(sqsapi)
-	controller = interruption.NewController(env.Client, fakeClock, events.NewRecorder(&record.FakeRecorder{}), sqsProvider, unavailableOfferingsCache)
+	recorder.Reset()
+	controller = interruption.NewController(env.Client, fakeClock, recorder, sqsProvider, unavailableOfferingsCache)
 	ctx = coresettings.ToContext(ctx, coretest.Settings())
 	ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
 		InterruptionQueueName: lo.ToPtr("test-cluster"),
@@ -128,6 +132,117 @@ var _ = Describe("AWSInterruption", func() {
 			ExpectNotFound(ctx, env.Client, machine)
 			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
 		})
+		It("should record the latency between the spot interruption warning and the deletion of the machine", func() {
+			machine, node := coretest.MachineAndNode(v1alpha5.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: "default",
+					},
+				},
+				Status: v1alpha5.MachineStatus{
+					ProviderID: fake.RandomProviderID(),
+				},
+			})
+			ExpectMessagesCreated(spotInterruptionMessage(lo.Must(utils.ParseInstanceID(machine.Status.ProviderID))))
+			ExpectApplied(ctx, env.Client, machine, node)
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectNotFound(ctx, env.Client, machine)
+			labels := map[string]string{"message_type": string(messages.SpotInterruptionKind)}
+			metric, ok := FindMetricWithLabelValues("karpenter_interruption_action_latency_seconds", labels)
+			Expect(ok).To(BeTrue())
+			Expect(metric.GetHistogram().GetSampleCount()).To(BeNumerically(">", 0))
+			metric, ok = FindMetricWithLabelValues("karpenter_interruption_message_processing_duration_seconds", labels)
+			Expect(ok).To(BeTrue())
+			Expect(metric.GetHistogram().GetSampleCount()).To(BeNumerically(">", 0))
+		})
+		It("should warn the machine, node and pods of the deadline when receiving a spot interruption warning", func() {
+			machine, node := coretest.MachineAndNode(v1alpha5.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: "default",
+					},
+				},
+				Status: v1alpha5.MachineStatus{
+					ProviderID: fake.RandomProviderID(),
+				},
+			})
+			pods := []*v1.Pod{
+				coretest.Pod(coretest.PodOptions{NodeName: node.Name, ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"controller.kubernetes.io/pod-deletion-cost": "100"}}}),
+				coretest.Pod(coretest.PodOptions{NodeName: node.Name, ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"controller.kubernetes.io/pod-deletion-cost": "-100"}}}),
+			}
+			msg := spotInterruptionMessage(lo.Must(utils.ParseInstanceID(machine.Status.ProviderID)))
+			ExpectMessagesCreated(msg)
+			ExpectApplied(ctx, env.Client, machine, node, pods[0], pods[1])
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			deadline := msg.Time.Add(spotinterruption.NoticePeriod).UTC().Format(time.RFC3339)
+			Expect(recorder.DetectedEvent(fmt.Sprintf("Spot interruption warning was triggered, the instance will be reclaimed at %s", deadline))).To(BeTrue())
+			var warned []string
+			recorder.ForEachEvent(func(evt events.Event) {
+				if pod, ok := evt.InvolvedObject.(*v1.Pod); ok {
+					Expect(evt.Message).To(ContainSubstring(deadline))
+					warned = append(warned, pod.Name)
+				}
+			})
+			// Pods with a lower deletion cost are warned first
+			Expect(warned).To(Equal([]string{pods[1].Name, pods[0].Name}))
+		})
+		It("should evict the pods in the order of their deletion cost when receiving a spot interruption warning", func() {
+			machine, node := coretest.MachineAndNode(v1alpha5.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: "default",
+					},
+				},
+				Status: v1alpha5.MachineStatus{
+					ProviderID: fake.RandomProviderID(),
+				},
+			})
+			pods := []*v1.Pod{
+				coretest.Pod(coretest.PodOptions{NodeName: node.Name, ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"controller.kubernetes.io/pod-deletion-cost": "100"}}}),
+				coretest.Pod(coretest.PodOptions{NodeName: node.Name}),
+				coretest.Pod(coretest.PodOptions{NodeName: node.Name, ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"controller.kubernetes.io/pod-deletion-cost": "-100"}}}),
+			}
+			ExpectMessagesCreated(spotInterruptionMessage(lo.Must(utils.ParseInstanceID(machine.Status.ProviderID))))
+			ExpectApplied(ctx, env.Client, machine, node, pods[0], pods[1], pods[2])
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			var evicted []string
+			recorder.ForEachEvent(func(evt events.Event) {
+				if pod, ok := evt.InvolvedObject.(*v1.Pod); ok && evt.Reason == "Evicted" {
+					evicted = append(evicted, pod.Name)
+				}
+			})
+			// Pods with a lower deletion cost are evicted first
+			Expect(evicted).To(Equal([]string{pods[2].Name, pods[1].Name, pods[0].Name}))
+			for _, pod := range pods {
+				Expect(env.Client.Get(ctx, client.ObjectKeyFromObject(pod), pod)).To(Succeed())
+				Expect(pod.DeletionTimestamp.IsZero()).To(BeFalse())
+			}
+		})
+		It("should not evict the pods whose eviction violates a PodDisruptionBudget", func() {
+			machine, node := coretest.MachineAndNode(v1alpha5.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: "default",
+					},
+				},
+				Status: v1alpha5.MachineStatus{
+					ProviderID: fake.RandomProviderID(),
+				},
+			})
+			labels := map[string]string{"app": "protected"}
+			pod := coretest.Pod(coretest.PodOptions{NodeName: node.Name, ObjectMeta: metav1.ObjectMeta{Labels: labels}, Phase: v1.PodRunning})
+			pdb := coretest.PodDisruptionBudget(coretest.PDBOptions{Labels: labels, MaxUnavailable: &intstr.IntOrString{IntVal: 0}})
+			ExpectMessagesCreated(spotInterruptionMessage(lo.Must(utils.ParseInstanceID(machine.Status.ProviderID))))
+			ExpectApplied(ctx, env.Client, machine, node, pod, pdb)
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(env.Client.Get(ctx, client.ObjectKeyFromObject(pod), pod)).To(Succeed())
+			Expect(pod.DeletionTimestamp.IsZero()).To(BeTrue())
+			ExpectNotFound(ctx, env.Client, machine)
+		})
 		It("should delete the machine when receiving a scheduled change message", func() {
 			machine, node := coretest.MachineAndNode(v1alpha5.Machine{
 				ObjectMeta: metav1.ObjectMeta{
@@ -224,6 +339,39 @@ var _ = Describe("AWSInterruption", func() {
 			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
 			Expect(sqsapi.ReceiveMessageBehavior.SuccessfulCalls()).To(Equal(1))
 			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+			ExpectDiscardedMessagesMetric(interruption.DiscardReasonParseFailure, false)
+		})
+		It("should redrive a message that can't be parsed to the dead-letter queue", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+				InterruptionQueueName:           lo.ToPtr("test-cluster"),
+				InterruptionDeadLetterQueueName: lo.ToPtr("test-cluster-dlq"),
+			}))
+			badMessage := &sqs.Message{
+				Body:      aws.String("not json"),
+				MessageId: aws.String(string(uuid.NewUUID())),
+			}
+
+			ExpectMessagesCreated(badMessage)
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(sqsapi.SendMessageBehavior.SuccessfulCalls()).To(Equal(1))
+			Expect(aws.StringValue(sqsapi.SendMessageBehavior.CalledWithInput.Pop().MessageBody)).To(Equal("not json"))
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+			ExpectDiscardedMessagesMetric(interruption.DiscardReasonParseFailure, true)
+		})
+		It("should not delete a message that can't be parsed when it can't be redriven", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+				InterruptionQueueName:           lo.ToPtr("test-cluster"),
+				InterruptionDeadLetterQueueName: lo.ToPtr("test-cluster-dlq"),
+			}))
+			sqsapi.SendMessageBehavior.Error.Set(awsErrWithCode("AccessDenied"), fake.MaxCalls(0))
+			ExpectMessagesCreated(&sqs.Message{
+				Body:      aws.String("not json"),
+				MessageId: aws.String(string(uuid.NewUUID())),
+			})
+
+			ExpectReconcileFailed(ctx, controller, types.NamespacedName{})
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(0))
 		})
 		It("should delete a state change message when the state isn't in accepted states", func() {
 			machine, node := coretest.MachineAndNode(v1alpha5.Machine{
@@ -300,6 +448,16 @@ func ExpectMessagesCreated(messages ...interface{}) {
 	)
 }
 
+func ExpectDiscardedMessagesMetric(reason string, redriven bool) {
+	GinkgoHelper()
+	metric, ok := FindMetricWithLabelValues("karpenter_interruption_discarded_messages", map[string]string{
+		"reason":   reason,
+		"redriven": strconv.FormatBool(redriven),
+	})
+	Expect(ok).To(BeTrue())
+	Expect(metric.GetCounter().GetValue()).To(BeNumerically(">", 0))
+}
+
 func awsErrWithCode(code string) awserr.Error {
 	return awserr.New(code, "", fmt.Errorf(""))
 }
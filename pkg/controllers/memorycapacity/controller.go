@@ -0,0 +1,132 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memorycapacity
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	nodeclaimutil "github.com/aws/karpenter-core/pkg/utils/nodeclaim"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/cache"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+// Controller periodically records the memory capacity reported by Karpenter nodes of each instance type and AMI family
+// so that instance types advertise the memory that's actually available rather than an estimate of the VM memory
+// overhead
+type Controller struct {
+	kubeClient               client.Client
+	observedMemoryCapacities *cache.ObservedMemoryCapacities
+}
+
+func NewController(kubeClient client.Client, observedMemoryCapacities *cache.ObservedMemoryCapacities) *Controller {
+	return &Controller{
+		kubeClient:               kubeClient,
+		observedMemoryCapacities: observedMemoryCapacities,
+	}
+}
+
+func (c *Controller) Name() string {
+	return "memorycapacity"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	nodeList := &v1.NodeList{}
+	if err := c.kubeClient.List(ctx, nodeList); err != nil {
+		return reconcile.Result{}, fmt.Errorf("listing nodes, %w", err)
+	}
+	nodeClaimList, err := nodeclaimutil.List(ctx, c.kubeClient)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("listing nodeclaims, %w", err)
+	}
+	nodeClaims := lo.SliceToMap(nodeClaimList.Items, func(nodeClaim v1beta1.NodeClaim) (string, v1beta1.NodeClaim) {
+		return nodeClaim.Status.ProviderID, nodeClaim
+	})
+	amiFamilies := map[nodeclassutil.Key]string{}
+	capacities := map[key]resource.Quantity{}
+	for i := range nodeList.Items {
+		instanceType, capacity, ok := memoryCapacity(&nodeList.Items[i])
+		if !ok {
+			continue
+		}
+		nodeClaim, ok := nodeClaims[nodeList.Items[i].Spec.ProviderID]
+		if !ok || nodeClaim.Spec.NodeClass == nil {
+			continue
+		}
+		nodeClassKey := nodeclassutil.Key{Name: nodeClaim.Spec.NodeClass.Name, IsNodeTemplate: nodeClaim.IsMachine}
+		amiFamily, ok := amiFamilies[nodeClassKey]
+		if !ok {
+			nodeClass, err := nodeclassutil.Get(ctx, c.kubeClient, nodeClassKey)
+			if err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				return reconcile.Result{}, fmt.Errorf("resolving node class, %w", err)
+			}
+			amiFamily = lo.FromPtrOr(nodeClass.Spec.AMIFamily, v1alpha1.AMIFamilyAL2)
+			amiFamilies[nodeClassKey] = amiFamily
+		}
+		// Use the smallest capacity across nodes of the instance type so that pods are never placed on
+		// a node that can't fit them
+		k := key{instanceType: instanceType, amiFamily: amiFamily}
+		if existing, found := capacities[k]; !found || capacity.Cmp(existing) < 0 {
+			capacities[k] = capacity
+		}
+	}
+	for k, capacity := range capacities {
+		c.observedMemoryCapacities.Observe(ctx, k.instanceType, k.amiFamily, capacity)
+	}
+	return reconcile.Result{RequeueAfter: time.Minute}, nil
+}
+
+type key struct {
+	instanceType string
+	amiFamily    string
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.NewSingletonManagedBy(m)
+}
+
+// memoryCapacity returns the instance type and memory capacity reported by the kubelet of a Karpenter node
+func memoryCapacity(node *v1.Node) (string, resource.Quantity, bool) {
+	_, hasNodePool := node.Labels[v1beta1.NodePoolLabelKey]
+	_, hasProvisioner := node.Labels[v1alpha5.ProvisionerNameLabelKey]
+	if !hasNodePool && !hasProvisioner {
+		return "", resource.Quantity{}, false
+	}
+	instanceType, ok := node.Labels[v1.LabelInstanceTypeStable]
+	if !ok {
+		return "", resource.Quantity{}, false
+	}
+	capacity, ok := node.Status.Capacity[v1.ResourceMemory]
+	if !ok || capacity.IsZero() {
+		return "", resource.Quantity{}, false
+	}
+	return instanceType, capacity, true
+}
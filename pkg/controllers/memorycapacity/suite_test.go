@@ -0,0 +1,173 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memorycapacity_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	. "knative.dev/pkg/logging/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/cache"
+	"github.com/aws/karpenter/pkg/controllers/memorycapacity"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var observedMemoryCapacities *cache.ObservedMemoryCapacities
+var controller *memorycapacity.Controller
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "MemoryCapacity")
+}
+
+var _ = BeforeSuite(func() {
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	observedMemoryCapacities = cache.NewObservedMemoryCapacities()
+	controller = memorycapacity.NewController(env.Client, observedMemoryCapacities)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	observedMemoryCapacities.Flush()
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("MemoryCapacity", func() {
+	var nodeTemplate *v1alpha1.AWSNodeTemplate
+
+	BeforeEach(func() {
+		nodeTemplate = test.AWSNodeTemplate()
+	})
+	It("should record the smallest memory capacity of nodes per instance type and AMI family", func() {
+		bottlerocket := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{AWS: v1alpha1.AWS{AMIFamily: aws.String(v1alpha1.AMIFamilyBottlerocket)}})
+		ExpectApplied(ctx, env.Client, nodeTemplate, bottlerocket)
+		for _, n := range []struct {
+			nodeTemplate *v1alpha1.AWSNodeTemplate
+			instanceType string
+			memory       string
+		}{
+			{nodeTemplate, "m5.large", "7800Mi"},
+			{nodeTemplate, "m5.large", "7700Mi"},
+			{nodeTemplate, "m5.xlarge", "15800Mi"},
+			{bottlerocket, "m5.large", "7600Mi"},
+		} {
+			ExpectApplied(ctx, env.Client, machineAndNode(n.nodeTemplate, n.instanceType, n.memory)...)
+		}
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		capacity, ok := observedMemoryCapacities.Get("m5.large", v1alpha1.AMIFamilyAL2)
+		Expect(ok).To(BeTrue())
+		Expect(capacity.String()).To(Equal("7700Mi"))
+		capacity, ok = observedMemoryCapacities.Get("m5.xlarge", v1alpha1.AMIFamilyAL2)
+		Expect(ok).To(BeTrue())
+		Expect(capacity.String()).To(Equal("15800Mi"))
+		capacity, ok = observedMemoryCapacities.Get("m5.large", v1alpha1.AMIFamilyBottlerocket)
+		Expect(ok).To(BeTrue())
+		Expect(capacity.String()).To(Equal("7600Mi"))
+	})
+	It("should only change the sequence number when the memory capacity changes", func() {
+		objects := machineAndNode(nodeTemplate, "m5.large", "7800Mi")
+		ExpectApplied(ctx, env.Client, append(objects, nodeTemplate)...)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		seqNum := observedMemoryCapacities.SeqNum
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(observedMemoryCapacities.SeqNum).To(Equal(seqNum))
+
+		n := objects[1].(*v1.Node)
+		n.Status.Capacity[v1.ResourceMemory] = resource.MustParse("7700Mi")
+		ExpectApplied(ctx, env.Client, n)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(observedMemoryCapacities.SeqNum).To(BeNumerically(">", seqNum))
+	})
+	It("should ignore nodes that aren't managed by karpenter", func() {
+		ExpectApplied(ctx, env.Client, coretest.Node(coretest.NodeOptions{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					v1.LabelInstanceTypeStable: "m5.large",
+				},
+			},
+			Capacity: v1.ResourceList{
+				v1.ResourceMemory: resource.MustParse("7800Mi"),
+			},
+		}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		_, ok := observedMemoryCapacities.Get("m5.large", v1alpha1.AMIFamilyAL2)
+		Expect(ok).To(BeFalse())
+	})
+	It("should ignore nodes whose node template doesn't exist", func() {
+		ExpectApplied(ctx, env.Client, machineAndNode(nodeTemplate, "m5.large", "7800Mi")...)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		_, ok := observedMemoryCapacities.Get("m5.large", v1alpha1.AMIFamilyAL2)
+		Expect(ok).To(BeFalse())
+	})
+	It("should ignore nodes that haven't reported their memory capacity", func() {
+		objects := machineAndNode(nodeTemplate, "m5.large", "7800Mi")
+		delete(objects[1].(*v1.Node).Status.Capacity, v1.ResourceMemory)
+		ExpectApplied(ctx, env.Client, append(objects, nodeTemplate)...)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		_, ok := observedMemoryCapacities.Get("m5.large", v1alpha1.AMIFamilyAL2)
+		Expect(ok).To(BeFalse())
+	})
+})
+
+func machineAndNode(nodeTemplate *v1alpha1.AWSNodeTemplate, instanceType, memory string) []client.Object {
+	machine, node := coretest.MachineAndNode(v1alpha5.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				v1alpha5.ProvisionerNameLabelKey: "default",
+				v1.LabelInstanceTypeStable:       instanceType,
+			},
+		},
+		Spec: v1alpha5.MachineSpec{
+			MachineTemplateRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+		},
+		Status: v1alpha5.MachineStatus{
+			ProviderID: fake.ProviderID(fake.InstanceID()),
+			Capacity: v1.ResourceList{
+				v1.ResourceMemory: resource.MustParse(memory),
+			},
+		},
+	})
+	return []client.Object{machine, node}
+}
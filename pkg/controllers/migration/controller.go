@@ -0,0 +1,123 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/logging"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+// Controller copies each AWSNodeTemplate to a NodeClass of the same name, converting selectors into selector terms,
+// and keeps the NodeClass in sync with the AWSNodeTemplate until the AWSNodeTemplate is deleted. NodeClasses that
+// weren't created from the AWSNodeTemplate are never modified.
+type Controller struct {
+	kubeClient client.Client
+}
+
+func NewController(kubeClient client.Client) corecontroller.Controller {
+	return corecontroller.Typed[*v1alpha1.AWSNodeTemplate](kubeClient, &Controller{
+		kubeClient: kubeClient,
+	})
+}
+
+func (c *Controller) Name() string {
+	return "awsnodetemplate.migration"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, nodeTemplate *v1alpha1.AWSNodeTemplate) (reconcile.Result, error) {
+	// The NodeClass is left as it was when the AWSNodeTemplate is deleted, so that it can replace the AWSNodeTemplate
+	if !nodeTemplate.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+	migrated := New(nodeTemplate)
+	// The NodeClass is compared with its defaults, which the defaulting webhook materializes
+	migrated.SetDefaults(ctx)
+	nodeClass := &v1beta1.NodeClass{}
+	if err := c.kubeClient.Get(ctx, types.NamespacedName{Name: nodeTemplate.Name}, nodeClass); err != nil {
+		if !errors.IsNotFound(err) {
+			return reconcile.Result{}, fmt.Errorf("getting nodeclass, %w", err)
+		}
+		if err = c.kubeClient.Create(ctx, migrated); err != nil {
+			return reconcile.Result{}, client.IgnoreAlreadyExists(fmt.Errorf("creating nodeclass, %w", err))
+		}
+		logging.FromContext(ctx).With("nodeclass", migrated.Name).Infof("migrated awsnodetemplate to nodeclass")
+		return reconcile.Result{}, nil
+	}
+	if nodeClass.Annotations[v1beta1.AnnotationMigratedFrom] != nodeTemplate.Name {
+		logging.FromContext(ctx).With("nodeclass", nodeClass.Name).Debugf("skipping migration, nodeclass wasn't migrated from the awsnodetemplate")
+		return reconcile.Result{}, nil
+	}
+	stored := nodeClass.DeepCopy()
+	nodeClass.Labels = lo.Assign(nodeClass.Labels, migrated.Labels)
+	nodeClass.Annotations = lo.Assign(nodeClass.Annotations, migrated.Annotations)
+	nodeClass.Spec = migrated.Spec
+	if !equality.Semantic.DeepEqual(stored, nodeClass) {
+		if err := c.kubeClient.Patch(ctx, nodeClass, client.MergeFrom(stored)); err != nil {
+			return reconcile.Result{}, client.IgnoreNotFound(fmt.Errorf("patching nodeclass, %w", err))
+		}
+	}
+	return reconcile.Result{}, nil
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.Adapt(controllerruntime.
+		NewControllerManagedBy(m).
+		Named(c.Name()).
+		For(&v1alpha1.AWSNodeTemplate{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// Changes made to a migrated NodeClass are reverted, since the AWSNodeTemplate is the source of truth until it's deleted
+		Watches(&source.Kind{Type: &v1beta1.NodeClass{}}, handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+			name, ok := o.GetAnnotations()[v1beta1.AnnotationMigratedFrom]
+			if !ok {
+				return nil
+			}
+			return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name}}}
+		})).
+		WithOptions(controller.Options{MaxConcurrentReconciles: 10}))
+}
+
+// New returns the NodeClass that an AWSNodeTemplate is migrated to. The NodeClass has the AWSNodeTemplate's name and
+// labels, and is annotated with the name of the AWSNodeTemplate that it was migrated from.
+func New(nodeTemplate *v1alpha1.AWSNodeTemplate) *v1beta1.NodeClass {
+	annotations := map[string]string{v1beta1.AnnotationMigratedFrom: nodeTemplate.Name}
+	return &v1beta1.NodeClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        nodeTemplate.Name,
+			Labels:      lo.Assign(nodeTemplate.Labels),
+			Annotations: annotations,
+		},
+		Spec: nodeclassutil.New(nodeTemplate.DeepCopy()).Spec,
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	. "knative.dev/pkg/logging/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/controllers/migration"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var controller corecontroller.Controller
+var nodeTemplate *v1alpha1.AWSNodeTemplate
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Migration")
+}
+
+var _ = BeforeSuite(func() {
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	controller = migration.NewController(env.Client)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	nodeTemplate = &v1alpha1.AWSNodeTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   coretest.RandomName(),
+			Labels: map[string]string{"team": "a"},
+		},
+		Spec: v1alpha1.AWSNodeTemplateSpec{
+			AWS: v1alpha1.AWS{
+				SubnetSelector:        map[string]string{"karpenter.sh/discovery": "my-cluster"},
+				SecurityGroupSelector: map[string]string{"aws-ids": "sg-123,sg-456"},
+				Tags:                  map[string]string{"owner": "team-a"},
+			},
+			AMISelector: map[string]string{"aws::ids": "ami-123"},
+		},
+	}
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("Migration", func() {
+	It("should create a NodeClass from the AWSNodeTemplate", func() {
+		ExpectApplied(ctx, env.Client, nodeTemplate)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+		nodeClass := ExpectExists(ctx, env.Client, &v1beta1.NodeClass{ObjectMeta: metav1.ObjectMeta{Name: nodeTemplate.Name}})
+		Expect(nodeClass.Labels).To(HaveKeyWithValue("team", "a"))
+		Expect(nodeClass.Annotations).To(HaveKeyWithValue(v1beta1.AnnotationMigratedFrom, nodeTemplate.Name))
+		Expect(nodeClass.Spec.SubnetSelectorTerms).To(ConsistOf(v1beta1.SubnetSelectorTerm{Tags: map[string]string{"karpenter.sh/discovery": "my-cluster"}}))
+		Expect(nodeClass.Spec.SecurityGroupSelectorTerms).To(ConsistOf(
+			v1beta1.SecurityGroupSelectorTerm{ID: "sg-123"},
+			v1beta1.SecurityGroupSelectorTerm{ID: "sg-456"},
+		))
+		Expect(nodeClass.Spec.AMISelectorTerms).To(ConsistOf(v1beta1.AMISelectorTerm{ID: "ami-123"}))
+		Expect(nodeClass.Spec.Tags).To(Equal(map[string]string{"owner": "team-a"}))
+		Expect(nodeClass.Spec.AMIFamily).To(Equal(lo.ToPtr(v1beta1.AMIFamilyAL2)))
+	})
+	It("should update the NodeClass when the AWSNodeTemplate changes", func() {
+		ExpectApplied(ctx, env.Client, nodeTemplate)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+
+		nodeTemplate.Spec.Tags = map[string]string{"owner": "team-b"}
+		ExpectApplied(ctx, env.Client, nodeTemplate)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+		nodeClass := ExpectExists(ctx, env.Client, &v1beta1.NodeClass{ObjectMeta: metav1.ObjectMeta{Name: nodeTemplate.Name}})
+		Expect(nodeClass.Spec.Tags).To(Equal(map[string]string{"owner": "team-b"}))
+	})
+	It("should revert changes made to the spec of a migrated NodeClass", func() {
+		ExpectApplied(ctx, env.Client, nodeTemplate)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+
+		nodeClass := ExpectExists(ctx, env.Client, &v1beta1.NodeClass{ObjectMeta: metav1.ObjectMeta{Name: nodeTemplate.Name}})
+		nodeClass.Spec.Tags = map[string]string{"owner": "someone-else"}
+		ExpectApplied(ctx, env.Client, nodeClass)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+		nodeClass = ExpectExists(ctx, env.Client, nodeClass)
+		Expect(nodeClass.Spec.Tags).To(Equal(map[string]string{"owner": "team-a"}))
+	})
+	It("should not modify a NodeClass that wasn't migrated from the AWSNodeTemplate", func() {
+		nodeClass := test.NodeClass(v1beta1.NodeClass{ObjectMeta: metav1.ObjectMeta{Name: nodeTemplate.Name}})
+		ExpectApplied(ctx, env.Client, nodeTemplate, nodeClass)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+		stored := nodeClass.DeepCopy()
+		nodeClass = ExpectExists(ctx, env.Client, nodeClass)
+		Expect(nodeClass.Annotations).ToNot(HaveKey(v1beta1.AnnotationMigratedFrom))
+		Expect(nodeClass.Spec).To(Equal(stored.Spec))
+	})
+})
@@ -0,0 +1,166 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package drift
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/events"
+	"github.com/aws/karpenter-core/pkg/operator/controller"
+	nodeclaimutil "github.com/aws/karpenter-core/pkg/utils/nodeclaim"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	awsv1beta1 "github.com/aws/karpenter/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/cloudprovider"
+	"github.com/aws/karpenter/pkg/providers/instance"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+// Controller records the drift of NodeClaims that the cloudprovider detects. NodeClaims that are drifted from their
+// AMI are annotated with the AMI that they're running and the AMI that they would be launched with today, so that the
+// reason a node was rotated can be audited after the fact. The tags of the instances of NodeClaims whose NodeClass only
+// changed its tags are updated in place when the drift policy of the tags is InPlace. Drift is only resolved for the
+// NodeClaims that karpenter-core marked as drifted from their AMI and for the NodeClaims whose NodeClass changed under
+// the InPlace drift policy, so that the drift of every NodeClaim isn't resolved again with AWS.
+type Controller struct {
+	kubeClient       client.Client
+	recorder         events.Recorder
+	cloudProvider    *cloudprovider.CloudProvider
+	instanceProvider *instance.Provider
+}
+
+func NewController(kubeClient client.Client, recorder events.Recorder, cloudProvider *cloudprovider.CloudProvider, instanceProvider *instance.Provider) *Controller {
+	return &Controller{
+		kubeClient:       kubeClient,
+		recorder:         recorder,
+		cloudProvider:    cloudProvider,
+		instanceProvider: instanceProvider,
+	}
+}
+
+func (c *Controller) Name() string {
+	return "machine.drift"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	nodeClaimList, err := nodeclaimutil.List(ctx, c.kubeClient)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("listing nodeclaims, %w", err)
+	}
+	errs := make([]error, len(nodeClaimList.Items))
+	workqueue.ParallelizeUntil(ctx, 20, len(nodeClaimList.Items), func(i int) {
+		errs[i] = c.reconcile(ctx, &nodeClaimList.Items[i])
+	})
+	return reconcile.Result{RequeueAfter: time.Minute}, multierr.Combine(errs...)
+}
+
+func (c *Controller) reconcile(ctx context.Context, nodeClaim *v1beta1.NodeClaim) error {
+	if !nodeClaim.DeletionTimestamp.IsZero() || nodeClaim.Spec.NodeClass == nil || nodeClaim.Status.ProviderID == "" {
+		return nil
+	}
+	if ok, err := c.mayBeDrifted(ctx, nodeClaim); !ok || err != nil {
+		return err
+	}
+	details, err := c.cloudProvider.DriftDetails(ctx, nodeClaim)
+	if err != nil {
+		return fmt.Errorf("getting drift details, %w", err)
+	}
+	if details == nil {
+		return nil
+	}
+	if details.ResolvedAMI != nil {
+		if err := c.recordAMIDrift(ctx, nodeClaim, details); err != nil {
+			return err
+		}
+	}
+	if details.TagsDrifted {
+		if err := c.updateTags(ctx, nodeClaim, details); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mayBeDrifted returns whether the NodeClaim may have drift to record, without making any AWS requests. The NodeClaim
+// is either marked as drifted from its AMI, or it was launched from a different NodeClass than it has today and the
+// drift policy of the tags is InPlace.
+func (c *Controller) mayBeDrifted(ctx context.Context, nodeClaim *v1beta1.NodeClaim) (bool, error) {
+	if condition := nodeClaim.StatusConditions().GetCondition(v1beta1.NodeDrifted); condition.IsTrue() &&
+		lo.Contains([]string{string(cloudprovider.AMIDrift), string(cloudprovider.AMIMaxAgeDrift)}, condition.Reason) {
+		return true, nil
+	}
+	nodeClass, err := nodeclassutil.Get(ctx, c.kubeClient, nodeclassutil.Key{Name: nodeClaim.Spec.NodeClass.Name, IsNodeTemplate: nodeClaim.IsMachine})
+	if err != nil {
+		return false, client.IgnoreNotFound(fmt.Errorf("getting nodeclass, %w", err))
+	}
+	if nodeclassutil.DriftPolicy(nodeClass, "tags") != awsv1beta1.DriftPolicyInPlace {
+		return false, nil
+	}
+	hashKey := lo.Ternary(nodeClaim.IsMachine, v1alpha1.AnnotationNodeTemplateHash, awsv1beta1.AnnotationNodeClassHash)
+	hash, ok := nodeClaim.Annotations[hashKey]
+	return ok && hash != nodeClass.Annotations[hashKey], nil
+}
+
+// recordAMIDrift annotates the NodeClaim with the AMI that it is running and the AMI that it would be launched with
+// today and publishes an event
+func (c *Controller) recordAMIDrift(ctx context.Context, nodeClaim *v1beta1.NodeClaim, details *cloudprovider.DriftDetails) error {
+	driftedKey, resolvedIDKey, resolvedNameKey := awsv1beta1.AnnotationDriftedAMIID, awsv1beta1.AnnotationResolvedAMIID, awsv1beta1.AnnotationResolvedAMIName
+	if nodeClaim.IsMachine {
+		driftedKey, resolvedIDKey, resolvedNameKey = v1alpha1.AnnotationDriftedAMIID, v1alpha1.AnnotationResolvedAMIID, v1alpha1.AnnotationResolvedAMIName
+	}
+	// The drift has already been recorded for this AMI
+	if nodeClaim.Annotations[driftedKey] == details.Instance.ImageID && nodeClaim.Annotations[resolvedIDKey] == details.ResolvedAMI.AmiID {
+		return nil
+	}
+	stored := nodeClaim.DeepCopy()
+	nodeClaim.Annotations = lo.Assign(nodeClaim.Annotations, map[string]string{
+		driftedKey:      details.Instance.ImageID,
+		resolvedIDKey:   details.ResolvedAMI.AmiID,
+		resolvedNameKey: details.ResolvedAMI.Name,
+	})
+	if err := nodeclaimutil.Patch(ctx, c.kubeClient, stored, nodeClaim); err != nil {
+		return client.IgnoreNotFound(fmt.Errorf("recording ami drift, %w", err))
+	}
+	c.recorder.Publish(NodeClaimAMIDrifted(nodeClaim, details.Instance.ImageID, details.ResolvedAMI.AmiID, details.ResolvedAMI.Name))
+	return nil
+}
+
+// updateTags updates the tags of the NodeClaim's instance in place and annotates the NodeClaim with the hashes of the
+// NodeClass, so that the tags aren't updated again until the tags of the NodeClass change
+func (c *Controller) updateTags(ctx context.Context, nodeClaim *v1beta1.NodeClaim, details *cloudprovider.DriftDetails) error {
+	if err := c.instanceProvider.UpdateTags(ctx, details.NodeClass, nodeClaim, details.Instance); err != nil {
+		return fmt.Errorf("updating tags, %w", err)
+	}
+	stored := nodeClaim.DeepCopy()
+	nodeClaim.Annotations = lo.Assign(nodeClaim.Annotations, nodeclassutil.HashAnnotation(details.NodeClass))
+	if err := nodeclaimutil.Patch(ctx, c.kubeClient, stored, nodeClaim); err != nil {
+		return client.IgnoreNotFound(fmt.Errorf("recording updated tags, %w", err))
+	}
+	c.recorder.Publish(NodeClaimTagsUpdated(nodeClaim))
+	return nil
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) controller.Builder {
+	return controller.NewSingletonManagedBy(m)
+}
@@ -0,0 +1,65 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package drift
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/events"
+	machineutil "github.com/aws/karpenter-core/pkg/utils/machine"
+)
+
+func NodeClaimAMIDrifted(nodeClaim *v1beta1.NodeClaim, driftedAMIID, resolvedAMIID, resolvedAMIName string) events.Event {
+	if nodeClaim.IsMachine {
+		machine := machineutil.NewFromNodeClaim(nodeClaim)
+		return events.Event{
+			InvolvedObject: machine,
+			Type:           v1.EventTypeNormal,
+			Reason:         "AMIDrifted",
+			Message:        fmt.Sprintf("Machine is running AMI %s but AWSNodeTemplate resolves to AMI %s (%s)", driftedAMIID, resolvedAMIID, resolvedAMIName),
+			DedupeValues:   []string{string(machine.UID), resolvedAMIID},
+		}
+	}
+	return events.Event{
+		InvolvedObject: nodeClaim,
+		Type:           v1.EventTypeNormal,
+		Reason:         "AMIDrifted",
+		Message:        fmt.Sprintf("NodeClaim is running AMI %s but NodeClass resolves to AMI %s (%s)", driftedAMIID, resolvedAMIID, resolvedAMIName),
+		DedupeValues:   []string{string(nodeClaim.UID), resolvedAMIID},
+	}
+}
+
+func NodeClaimTagsUpdated(nodeClaim *v1beta1.NodeClaim) events.Event {
+	if nodeClaim.IsMachine {
+		machine := machineutil.NewFromNodeClaim(nodeClaim)
+		return events.Event{
+			InvolvedObject: machine,
+			Type:           v1.EventTypeNormal,
+			Reason:         "TagsUpdated",
+			Message:        "Updated the tags of the instance in place to match the AWSNodeTemplate",
+			DedupeValues:   []string{string(machine.UID)},
+		}
+	}
+	return events.Event{
+		InvolvedObject: nodeClaim,
+		Type:           v1.EventTypeNormal,
+		Reason:         "TagsUpdated",
+		Message:        "Updated the tags of the instance in place to match the NodeClass",
+		DedupeValues:   []string{string(nodeClaim.UID)},
+	}
+}
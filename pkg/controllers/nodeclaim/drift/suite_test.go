@@ -0,0 +1,236 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package drift_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	. "knative.dev/pkg/logging/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+	nodepoolutil "github.com/aws/karpenter-core/pkg/utils/nodepool"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/cloudprovider"
+	"github.com/aws/karpenter/pkg/controllers/nodeclaim/drift"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var awsEnv *test.Environment
+var cloudProvider *cloudprovider.CloudProvider
+var recorder *coretest.EventRecorder
+var controller *drift.Controller
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Drift")
+}
+
+var _ = BeforeSuite(func() {
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	awsEnv = test.NewEnvironment(ctx, env)
+	recorder = coretest.NewEventRecorder()
+	cloudProvider = cloudprovider.New(awsEnv.InstanceTypesProvider, awsEnv.InstanceProvider, recorder,
+		env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider, awsEnv.QuotaProvider,
+		awsEnv.NotificationProvider)
+	controller = drift.NewController(env.Client, recorder, cloudProvider, awsEnv.InstanceProvider)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	awsEnv.Reset()
+	recorder.Reset()
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("Drift", func() {
+	var validAMI string
+	var nodeTemplate *v1alpha1.AWSNodeTemplate
+	var provisioner *v1alpha5.Provisioner
+	var instance *ec2.Instance
+	var machine *v1alpha5.Machine
+
+	BeforeEach(func() {
+		validAMI = fake.ImageID()
+		awsEnv.SSMAPI.GetParameterOutput = &ssm.GetParameterOutput{
+			Parameter: &ssm.Parameter{Value: aws.String(validAMI)},
+		}
+		awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{
+			Images: []*ec2.Image{
+				{
+					Name:         aws.String("valid-ami"),
+					ImageId:      aws.String(validAMI),
+					Architecture: aws.String("arm64"),
+					CreationDate: aws.String("2022-08-15T12:00:00Z"),
+				},
+			},
+		})
+		nodeTemplate = test.AWSNodeTemplate()
+		nodeTemplate.Status.Subnets = []v1alpha1.Subnet{{ID: fake.SubnetID(), Zone: "test-zone-1a"}}
+		nodeTemplate.Status.SecurityGroups = []v1alpha1.SecurityGroup{{ID: fake.SecurityGroupID(), Name: "test-securitygroup"}}
+		provisioner = test.Provisioner(coretest.ProvisionerOptions{
+			ProviderRef: &v1alpha5.MachineTemplateRef{
+				APIVersion: nodeTemplate.APIVersion,
+				Kind:       nodeTemplate.Kind,
+				Name:       nodeTemplate.Name,
+			},
+		})
+		ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+		instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+		Expect(err).ToNot(HaveOccurred())
+
+		instance = &ec2.Instance{
+			ImageId:      aws.String(validAMI),
+			InstanceType: aws.String(instanceTypes[0].Name),
+			SubnetId:     aws.String(nodeTemplate.Status.Subnets[0].ID),
+			State: &ec2.InstanceState{
+				Name: aws.String(ec2.InstanceStateNameRunning),
+			},
+			InstanceId: aws.String(fake.InstanceID()),
+			Placement: &ec2.Placement{
+				AvailabilityZone: aws.String("test-zone-1a"),
+			},
+			SecurityGroups: []*ec2.GroupIdentifier{{GroupId: aws.String(nodeTemplate.Status.SecurityGroups[0].ID)}},
+		}
+		awsEnv.EC2API.DescribeInstancesBehavior.Output.Set(&ec2.DescribeInstancesOutput{
+			Reservations: []*ec2.Reservation{{Instances: []*ec2.Instance{instance}}},
+		})
+		machine = coretest.Machine(v1alpha5.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					v1alpha5.ProvisionerNameLabelKey: provisioner.Name,
+					v1.LabelInstanceTypeStable:       instanceTypes[0].Name,
+				},
+				Annotations: map[string]string{
+					v1alpha1.AnnotationNodeTemplateHash:            nodeTemplate.Hash(),
+					v1alpha1.AnnotationNodeTemplateHashWithoutTags: nodeTemplate.HashWithoutTags(),
+				},
+			},
+			Spec: v1alpha5.MachineSpec{
+				MachineTemplateRef: &v1alpha5.MachineTemplateRef{
+					APIVersion: nodeTemplate.APIVersion,
+					Kind:       nodeTemplate.Kind,
+					Name:       nodeTemplate.Name,
+				},
+			},
+			Status: v1alpha5.MachineStatus{
+				ProviderID: fake.ProviderID(aws.StringValue(instance.InstanceId)),
+			},
+		})
+	})
+	Context("AMI Drift", func() {
+		It("should record the drifted and resolved AMIs on the machine", func() {
+			driftedAMI := fake.ImageID()
+			instance.ImageId = aws.String(driftedAMI)
+			machine.StatusConditions().MarkTrueWithReason(v1alpha5.MachineDrifted, string(cloudprovider.AMIDrift), "")
+			ExpectApplied(ctx, env.Client, machine)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+			machine = ExpectExists(ctx, env.Client, machine)
+			Expect(machine.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationDriftedAMIID, driftedAMI))
+			Expect(machine.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationResolvedAMIID, validAMI))
+			Expect(machine.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationResolvedAMIName, "valid-ami"))
+			Expect(recorder.Calls("AMIDrifted")).To(Equal(1))
+		})
+		It("should not resolve the drift of a machine that isn't marked as drifted from its AMI", func() {
+			instance.ImageId = aws.String(fake.ImageID())
+			ExpectApplied(ctx, env.Client, machine)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+			machine = ExpectExists(ctx, env.Client, machine)
+			Expect(machine.Annotations).ToNot(HaveKey(v1alpha1.AnnotationDriftedAMIID))
+			Expect(awsEnv.EC2API.DescribeInstancesBehavior.Calls()).To(Equal(0))
+		})
+		It("should not record AMIs on the machine when the AMI is not drifted", func() {
+			machine.StatusConditions().MarkTrueWithReason(v1alpha5.MachineDrifted, string(cloudprovider.AMIDrift), "")
+			ExpectApplied(ctx, env.Client, machine)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+			machine = ExpectExists(ctx, env.Client, machine)
+			Expect(machine.Annotations).ToNot(HaveKey(v1alpha1.AnnotationDriftedAMIID))
+			Expect(machine.Annotations).ToNot(HaveKey(v1alpha1.AnnotationResolvedAMIID))
+			Expect(recorder.Calls("AMIDrifted")).To(Equal(0))
+		})
+		It("should not record AMIs on a machine that hasn't launched", func() {
+			instance.ImageId = aws.String(fake.ImageID())
+			machine.Status.ProviderID = ""
+			machine.StatusConditions().MarkTrueWithReason(v1alpha5.MachineDrifted, string(cloudprovider.AMIDrift), "")
+			ExpectApplied(ctx, env.Client, machine)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+			machine = ExpectExists(ctx, env.Client, machine)
+			Expect(machine.Annotations).ToNot(HaveKey(v1alpha1.AnnotationDriftedAMIID))
+		})
+	})
+	Context("Tags Drift", func() {
+		BeforeEach(func() {
+			nodeTemplate.Annotations = lo.Assign(nodeTemplate.Annotations, map[string]string{
+				v1alpha1.AnnotationDriftPolicy: "tags=InPlace",
+			})
+		})
+		It("should update the tags in place if only the tags are updated", func() {
+			nodeTemplate.Spec.Tags = map[string]string{"team": "b"}
+			nodeTemplate.Annotations = lo.Assign(nodeTemplate.Annotations, map[string]string{
+				v1alpha1.AnnotationNodeTemplateHash:            nodeTemplate.Hash(),
+				v1alpha1.AnnotationNodeTemplateHashWithoutTags: nodeTemplate.HashWithoutTags(),
+			})
+			ExpectApplied(ctx, env.Client, nodeTemplate, machine)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+
+			Expect(awsEnv.EC2API.CreateTagsBehavior.CalledWithInput.Len()).To(Equal(1))
+			input := awsEnv.EC2API.CreateTagsBehavior.CalledWithInput.Pop()
+			Expect(aws.StringValueSlice(input.Resources)).To(ContainElement(aws.StringValue(instance.InstanceId)))
+			Expect(input.Tags).To(ContainElement(&ec2.Tag{Key: aws.String("team"), Value: aws.String("b")}))
+			machine = ExpectExists(ctx, env.Client, machine)
+			Expect(machine.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationNodeTemplateHash, nodeTemplate.Hash()))
+			Expect(recorder.Calls("TagsUpdated")).To(Equal(1))
+		})
+		It("should not update the tags if fields other than the tags are updated", func() {
+			nodeTemplate.Spec.Tags = map[string]string{"team": "b"}
+			nodeTemplate.Spec.UserData = aws.String("userdata-test-2")
+			nodeTemplate.Annotations = lo.Assign(nodeTemplate.Annotations, map[string]string{
+				v1alpha1.AnnotationNodeTemplateHash:            nodeTemplate.Hash(),
+				v1alpha1.AnnotationNodeTemplateHashWithoutTags: nodeTemplate.HashWithoutTags(),
+			})
+			ExpectApplied(ctx, env.Client, nodeTemplate, machine)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+			Expect(awsEnv.EC2API.CreateTagsBehavior.CalledWithInput.Len()).To(Equal(0))
+		})
+	})
+})
@@ -19,9 +19,11 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/samber/lo"
 	"go.uber.org/multierr"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/workqueue"
 	"knative.dev/pkg/logging"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -31,9 +33,12 @@ import (
 	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
 	"github.com/aws/karpenter-core/pkg/apis/v1beta1"
 	corecloudprovider "github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/events"
 	"github.com/aws/karpenter-core/pkg/operator/controller"
 	nodeclaimutil "github.com/aws/karpenter-core/pkg/utils/nodeclaim"
 	"github.com/aws/karpenter-core/pkg/utils/sets"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	awsv1beta1 "github.com/aws/karpenter/pkg/apis/v1beta1"
 	"github.com/aws/karpenter/pkg/cloudprovider"
 	"github.com/aws/karpenter/pkg/controllers/nodeclaim/link"
 )
@@ -41,15 +46,17 @@ import (
 type Controller struct {
 	kubeClient      client.Client
 	cloudProvider   *cloudprovider.CloudProvider
+	recorder        events.Recorder
 	successfulCount uint64           // keeps track of successful reconciles for more aggressive requeueing near the start of the controller
 	linkController  *link.Controller // get machines recently linked by this controller
 
 }
 
-func NewController(kubeClient client.Client, cloudProvider *cloudprovider.CloudProvider, linkController *link.Controller) *Controller {
+func NewController(kubeClient client.Client, cloudProvider *cloudprovider.CloudProvider, recorder events.Recorder, linkController *link.Controller) *Controller {
 	return &Controller{
 		kubeClient:      kubeClient,
 		cloudProvider:   cloudProvider,
+		recorder:        recorder,
 		successfulCount: 0,
 		linkController:  linkController,
 	}
@@ -68,7 +75,7 @@ func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconc
 		return reconcile.Result{}, fmt.Errorf("listing cloudprovider machines, %w", err)
 	}
 	managedRetrieved := lo.Filter(retrieved, func(nc *v1beta1.NodeClaim, _ int) bool {
-		return nc.Annotations[v1beta1.ManagedByAnnotationKey] != "" && nc.DeletionTimestamp.IsZero()
+		return nc.Annotations[v1beta1.ManagedByAnnotationKey] != "" && nc.DeletionTimestamp.IsZero() && !isReusable(ctx, nc)
 	})
 	nodeClaimList, err := nodeclaimutil.List(ctx, c.kubeClient)
 	if err != nil {
@@ -93,7 +100,7 @@ func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconc
 
 		if !recentlyLinked &&
 			!resolvedProviderIDs.Has(managedRetrieved[i].Status.ProviderID) &&
-			time.Since(managedRetrieved[i].CreationTimestamp.Time) > time.Second*30 {
+			time.Since(managedRetrieved[i].CreationTimestamp.Time) > settings.FromContext(ctx).GarbageCollectionGracePeriod {
 			errs[i] = c.garbageCollect(ctx, managedRetrieved[i], nodeList)
 		}
 	})
@@ -101,12 +108,24 @@ func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconc
 	return reconcile.Result{RequeueAfter: lo.Ternary(c.successfulCount <= 20, time.Second*10, time.Minute*2)}, multierr.Combine(errs...)
 }
 
+// isReusable returns whether the instance was stopped by stop-based consolidation within the stopped instance TTL, in
+// which case it's kept so that it can be started again for a later NodeClaim
+func isReusable(ctx context.Context, nodeClaim *v1beta1.NodeClaim) bool {
+	v, ok := nodeClaim.Annotations[awsv1beta1.AnnotationStoppedAt]
+	if !ok {
+		return false
+	}
+	stoppedAt, err := time.Parse(time.RFC3339, v)
+	return err == nil && time.Since(stoppedAt) < settings.FromContext(ctx).StoppedInstanceTTL
+}
+
 func (c *Controller) garbageCollect(ctx context.Context, nodeClaim *v1beta1.NodeClaim, nodeList *v1.NodeList) error {
 	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("provider-id", nodeClaim.Status.ProviderID))
 	if err := c.cloudProvider.Delete(ctx, nodeClaim); err != nil {
 		return corecloudprovider.IgnoreNodeClaimNotFoundError(err)
 	}
 	logging.FromContext(ctx).Debugf("garbage collected cloudprovider instance")
+	c.publishGarbageCollected(ctx, nodeClaim)
 
 	// Go ahead and cleanup the node if we know that it exists to make scheduling go quicker
 	if node, ok := lo.Find(nodeList.Items, func(n v1.Node) bool {
@@ -120,6 +139,24 @@ func (c *Controller) garbageCollect(ctx context.Context, nodeClaim *v1beta1.Node
 	return nil
 }
 
+// publishGarbageCollected counts the instance and publishes an event to the provisioner or nodepool that launched it,
+// if it still exists
+func (c *Controller) publishGarbageCollected(ctx context.Context, nodeClaim *v1beta1.NodeClaim) {
+	if nodeClaim.IsMachine {
+		garbageCollectedInstances.With(prometheus.Labels{nodePoolLabel: nodeClaim.Labels[v1alpha5.ProvisionerNameLabelKey]}).Inc()
+		provisioner := &v1alpha5.Provisioner{}
+		if err := c.kubeClient.Get(ctx, types.NamespacedName{Name: nodeClaim.Labels[v1alpha5.ProvisionerNameLabelKey]}, provisioner); err == nil {
+			c.recorder.Publish(ProvisionerInstanceGarbageCollected(provisioner, nodeClaim.Status.ProviderID))
+		}
+		return
+	}
+	garbageCollectedInstances.With(prometheus.Labels{nodePoolLabel: nodeClaim.Labels[v1beta1.NodePoolLabelKey]}).Inc()
+	nodePool := &v1beta1.NodePool{}
+	if err := c.kubeClient.Get(ctx, types.NamespacedName{Name: nodeClaim.Labels[v1beta1.NodePoolLabelKey]}, nodePool); err == nil {
+		c.recorder.Publish(NodePoolInstanceGarbageCollected(nodePool, nodeClaim.Status.ProviderID))
+	}
+}
+
 func (c *Controller) Builder(_ context.Context, m manager.Manager) controller.Builder {
 	return controller.NewSingletonManagedBy(m)
 }
@@ -0,0 +1,45 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package garbagecollection
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/events"
+)
+
+func ProvisionerInstanceGarbageCollected(provisioner *v1alpha5.Provisioner, providerID string) events.Event {
+	return events.Event{
+		InvolvedObject: provisioner,
+		Type:           v1.EventTypeWarning,
+		Reason:         "InstanceGarbageCollected",
+		Message:        fmt.Sprintf("Terminated instance %s, which was launched for the provisioner but has no Machine", providerID),
+		DedupeValues:   []string{string(provisioner.UID), providerID},
+	}
+}
+
+func NodePoolInstanceGarbageCollected(nodePool *v1beta1.NodePool, providerID string) events.Event {
+	return events.Event{
+		InvolvedObject: nodePool,
+		Type:           v1.EventTypeWarning,
+		Reason:         "InstanceGarbageCollected",
+		Message:        fmt.Sprintf("Terminated instance %s, which was launched for the NodePool but has no NodeClaim", providerID),
+		DedupeValues:   []string{string(nodePool.UID), providerID},
+	}
+}
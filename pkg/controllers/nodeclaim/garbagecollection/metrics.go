@@ -0,0 +1,54 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package garbagecollection
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/aws/karpenter-core/pkg/metrics"
+)
+
+const (
+	cloudProviderSubsystem = "cloudprovider"
+	nodePoolLabel          = "nodepool"
+	resourceTypeLabel      = "resource_type"
+	dryRunLabel            = "dry_run"
+)
+
+var (
+	garbageCollectedInstances = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "instances_garbage_collected_total",
+			Help:      "Number of instances terminated because they had no NodeClaim or Machine. Labeled by the nodepool or provisioner that launched them.",
+		},
+		[]string{nodePoolLabel},
+	)
+	garbageCollectedResources = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "resources_garbage_collected_total",
+			Help:      "Number of orphaned network interfaces and volumes deleted, or found in dry-run mode. Labeled by resource type and whether it was a dry run.",
+		},
+		[]string{resourceTypeLabel, dryRunLabel},
+	)
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(garbageCollectedInstances, garbageCollectedResources)
+}
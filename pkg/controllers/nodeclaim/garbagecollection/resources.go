@@ -0,0 +1,192 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package garbagecollection
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	"k8s.io/utils/clock"
+	"knative.dev/pkg/logging"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	awsv1beta1 "github.com/aws/karpenter/pkg/apis/v1beta1"
+	awserrors "github.com/aws/karpenter/pkg/errors"
+)
+
+const (
+	networkInterfaceResourceType = "network_interface"
+	volumeResourceType           = "volume"
+)
+
+// ResourceController deletes the network interfaces and volumes that were created for instances that Karpenter
+// launched, but were left behind unattached, e.g. by failed launches or instances that were force-terminated. Only
+// volumes that are tagged as deleted on termination are deleted, so volumes that were retained on purpose are kept.
+type ResourceController struct {
+	clk    clock.Clock
+	ec2api ec2iface.EC2API
+	// availableSince is when each resource was first seen unattached, by resource type. EC2 doesn't report when a
+	// network interface was created, and a volume can be detached long after it was created.
+	availableSince map[string]map[string]time.Time
+}
+
+func NewResourceController(clk clock.Clock, ec2api ec2iface.EC2API) *ResourceController {
+	return &ResourceController{
+		clk:    clk,
+		ec2api: ec2api,
+		availableSince: map[string]map[string]time.Time{
+			networkInterfaceResourceType: {},
+			volumeResourceType:           {},
+		},
+	}
+}
+
+func (c *ResourceController) Name() string {
+	return "resource.garbagecollection"
+}
+
+func (c *ResourceController) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	err := multierr.Combine(
+		c.garbageCollectNetworkInterfaces(ctx),
+		c.garbageCollectVolumes(ctx),
+	)
+	return reconcile.Result{RequeueAfter: time.Minute * 5}, err
+}
+
+func (c *ResourceController) garbageCollectNetworkInterfaces(ctx context.Context) error {
+	var networkInterfaces []*ec2.NetworkInterface
+	if err := c.ec2api.DescribeNetworkInterfacesPagesWithContext(ctx, &ec2.DescribeNetworkInterfacesInput{
+		Filters: orphanedResourceFilters(ctx, ec2.NetworkInterfaceStatusAvailable),
+	}, func(page *ec2.DescribeNetworkInterfacesOutput, _ bool) bool {
+		networkInterfaces = append(networkInterfaces, page.NetworkInterfaces...)
+		return true
+	}); err != nil {
+		return fmt.Errorf("describing network interfaces, %w", err)
+	}
+	var errs []error
+	for _, id := range c.expired(ctx, networkInterfaceResourceType, lo.Map(networkInterfaces, func(n *ec2.NetworkInterface, _ int) string {
+		return aws.StringValue(n.NetworkInterfaceId)
+	})) {
+		if err := c.garbageCollect(ctx, networkInterfaceResourceType, id, func() error {
+			_, err := c.ec2api.DeleteNetworkInterfaceWithContext(ctx, &ec2.DeleteNetworkInterfaceInput{NetworkInterfaceId: aws.String(id)})
+			return err
+		}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return multierr.Combine(errs...)
+}
+
+func (c *ResourceController) garbageCollectVolumes(ctx context.Context) error {
+	var volumes []*ec2.Volume
+	if err := c.ec2api.DescribeVolumesPagesWithContext(ctx, &ec2.DescribeVolumesInput{
+		Filters: append(orphanedResourceFilters(ctx, ec2.VolumeStateAvailable), &ec2.Filter{
+			Name:   aws.String(fmt.Sprintf("tag:%s", awsv1beta1.VolumeDeleteOnTerminationTagKey)),
+			Values: aws.StringSlice([]string{"true"}),
+		}),
+	}, func(page *ec2.DescribeVolumesOutput, _ bool) bool {
+		volumes = append(volumes, page.Volumes...)
+		return true
+	}); err != nil {
+		return fmt.Errorf("describing volumes, %w", err)
+	}
+	var errs []error
+	for _, id := range c.expired(ctx, volumeResourceType, lo.Map(volumes, func(v *ec2.Volume, _ int) string {
+		return aws.StringValue(v.VolumeId)
+	})) {
+		if err := c.garbageCollect(ctx, volumeResourceType, id, func() error {
+			_, err := c.ec2api.DeleteVolumeWithContext(ctx, &ec2.DeleteVolumeInput{VolumeId: aws.String(id)})
+			return err
+		}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return multierr.Combine(errs...)
+}
+
+// expired records when each of the unattached resources was first seen unattached, and returns the ones that have been
+// unattached for longer than the grace period. Resources that were attached or deleted since the last reconcile start
+// their grace period over.
+func (c *ResourceController) expired(ctx context.Context, resourceType string, ids []string) []string {
+	available := map[string]time.Time{}
+	for _, id := range ids {
+		available[id] = c.clk.Now()
+		if since, ok := c.availableSince[resourceType][id]; ok {
+			available[id] = since
+		}
+	}
+	c.availableSince[resourceType] = available
+	return lo.Filter(ids, func(id string, _ int) bool {
+		return c.clk.Since(available[id]) > settings.FromContext(ctx).GarbageCollectionGracePeriod
+	})
+}
+
+// garbageCollect deletes the resource, unless resources are garbage collected in dry-run mode, in which case the
+// resource is only logged and counted
+func (c *ResourceController) garbageCollect(ctx context.Context, resourceType, id string, deleteFunc func() error) error {
+	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("resource-type", resourceType, "id", id))
+	dryRun := settings.FromContext(ctx).ResourceGarbageCollectionDryRun
+	if dryRun {
+		logging.FromContext(ctx).Infof("found orphaned resource, not deleting it in dry-run mode")
+	} else {
+		if err := deleteFunc(); err != nil {
+			if awserrors.IsNotFound(err) {
+				return nil
+			}
+			return fmt.Errorf("deleting %s %s, %w", resourceType, id, err)
+		}
+		logging.FromContext(ctx).Debugf("garbage collected orphaned resource")
+	}
+	garbageCollectedResources.With(prometheus.Labels{
+		resourceTypeLabel: resourceType,
+		dryRunLabel:       strconv.FormatBool(dryRun),
+	}).Inc()
+	return nil
+}
+
+func (c *ResourceController) Builder(_ context.Context, m manager.Manager) controller.Builder {
+	return controller.NewSingletonManagedBy(m)
+}
+
+// orphanedResourceFilters select the resources in the status that were tagged for instances that Karpenter launched
+// for the cluster
+func orphanedResourceFilters(ctx context.Context, status string) []*ec2.Filter {
+	return []*ec2.Filter{
+		{
+			Name:   aws.String("status"),
+			Values: aws.StringSlice([]string{status}),
+		},
+		{
+			Name:   aws.String(fmt.Sprintf("tag:%s", v1beta1.ManagedByAnnotationKey)),
+			Values: aws.StringSlice([]string{settings.FromContext(ctx).ClusterName}),
+		},
+		{
+			Name:   aws.String("tag-key"),
+			Values: aws.StringSlice([]string{fmt.Sprintf("kubernetes.io/cluster/%s", settings.FromContext(ctx).ClusterName)}),
+		},
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/samber/lo"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/record"
+	clock "k8s.io/utils/clock/testing"
 	. "knative.dev/pkg/logging/testing"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -44,6 +45,7 @@ import (
 	"github.com/aws/karpenter/pkg/apis"
 	"github.com/aws/karpenter/pkg/apis/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
 	"github.com/aws/karpenter/pkg/cloudprovider"
 	"github.com/aws/karpenter/pkg/controllers/nodeclaim/garbagecollection"
 	"github.com/aws/karpenter/pkg/controllers/nodeclaim/link"
@@ -57,6 +59,9 @@ var env *coretest.Environment
 var garbageCollectionController controller.Controller
 var linkedMachineCache *cache.Cache
 var cloudProvider *cloudprovider.CloudProvider
+var recorder *coretest.EventRecorder
+var fakeClock *clock.FakeClock
+var resourceController *garbagecollection.ResourceController
 
 func TestAPIs(t *testing.T) {
 	ctx = TestContextWithLogger(t)
@@ -70,12 +75,16 @@ var _ = BeforeSuite(func() {
 	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
 	awsEnv = test.NewEnvironment(ctx, env)
 	cloudProvider = cloudprovider.New(awsEnv.InstanceTypesProvider, awsEnv.InstanceProvider, events.NewRecorder(&record.FakeRecorder{}),
-		env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider)
+		env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider, awsEnv.QuotaProvider,
+		awsEnv.NotificationProvider)
 	linkedMachineCache = cache.New(time.Minute*10, time.Second*10)
 	linkController := &link.Controller{
 		Cache: linkedMachineCache,
 	}
-	garbageCollectionController = garbagecollection.NewController(env.Client, cloudProvider, linkController)
+	recorder = coretest.NewEventRecorder()
+	garbageCollectionController = garbagecollection.NewController(env.Client, cloudProvider, recorder, linkController)
+	fakeClock = clock.NewFakeClock(time.Now())
+	resourceController = garbagecollection.NewResourceController(fakeClock, awsEnv.EC2API)
 })
 
 var _ = AfterSuite(func() {
@@ -84,17 +93,19 @@ var _ = AfterSuite(func() {
 
 var _ = BeforeEach(func() {
 	awsEnv.Reset()
+	recorder.Reset()
 })
 
 var _ = Describe("NodeClaimGarbageCollection", func() {
 	var instance *ec2.Instance
 	var providerID string
+	var provisioner *v1alpha5.Provisioner
 
 	BeforeEach(func() {
 		instanceID := fake.InstanceID()
 		providerID = fmt.Sprintf("aws:///test-zone-1a/%s", instanceID)
 		nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{})
-		provisioner := test.Provisioner(coretest.ProvisionerOptions{
+		provisioner = test.Provisioner(coretest.ProvisionerOptions{
 			ProviderRef: &v1alpha5.MachineTemplateRef{
 				APIVersion: "testing/v1alpha1",
 				Kind:       "NodeTemplate",
@@ -159,6 +170,66 @@ var _ = Describe("NodeClaimGarbageCollection", func() {
 
 		ExpectNotFound(ctx, env.Client, node)
 	})
+	It("should publish an event to the provisioner and count the instance when it's garbage collected", func() {
+		ExpectApplied(ctx, env.Client, provisioner)
+		instance.LaunchTime = aws.Time(time.Now().Add(-time.Minute))
+		awsEnv.EC2API.Instances.Store(aws.StringValue(instance.InstanceId), instance)
+
+		ExpectReconcileSucceeded(ctx, garbageCollectionController, client.ObjectKey{})
+		_, err := cloudProvider.Get(ctx, providerID)
+		Expect(corecloudprovider.IsNodeClaimNotFoundError(err)).To(BeTrue())
+
+		Expect(recorder.Calls("InstanceGarbageCollected")).To(Equal(1))
+		metric, ok := FindMetricWithLabelValues("karpenter_cloudprovider_instances_garbage_collected_total", map[string]string{"nodepool": provisioner.Name})
+		Expect(ok).To(BeTrue())
+		Expect(metric.GetCounter().GetValue()).To(BeNumerically(">=", 1))
+	})
+	It("should not delete an instance if it is within a configured grace period", func() {
+		ctx := settings.ToContext(ctx, test.Settings(test.SettingOptions{GarbageCollectionGracePeriod: lo.ToPtr(time.Minute * 10)}))
+		instance.LaunchTime = aws.Time(time.Now().Add(-time.Minute * 5))
+		awsEnv.EC2API.Instances.Store(aws.StringValue(instance.InstanceId), instance)
+
+		ExpectReconcileSucceeded(ctx, garbageCollectionController, client.ObjectKey{})
+		_, err := cloudProvider.Get(ctx, providerID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorder.Calls("InstanceGarbageCollected")).To(Equal(0))
+	})
+	It("should delete an instance once a configured grace period has passed", func() {
+		ctx := settings.ToContext(ctx, test.Settings(test.SettingOptions{GarbageCollectionGracePeriod: lo.ToPtr(time.Minute * 10)}))
+		instance.LaunchTime = aws.Time(time.Now().Add(-time.Minute * 15))
+		awsEnv.EC2API.Instances.Store(aws.StringValue(instance.InstanceId), instance)
+
+		ExpectReconcileSucceeded(ctx, garbageCollectionController, client.ObjectKey{})
+		_, err := cloudProvider.Get(ctx, providerID)
+		Expect(corecloudprovider.IsNodeClaimNotFoundError(err)).To(BeTrue())
+	})
+	It("should not delete an instance that was stopped for reuse within the stopped instance TTL", func() {
+		instance.LaunchTime = aws.Time(time.Now().Add(-time.Hour * 2))
+		instance.State = &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameStopped)}
+		instance.Tags = append(instance.Tags, &ec2.Tag{
+			Key:   aws.String(v1beta1.AnnotationStoppedAt),
+			Value: aws.String(time.Now().Add(-time.Minute * 30).UTC().Format(time.RFC3339)),
+		})
+		awsEnv.EC2API.Instances.Store(aws.StringValue(instance.InstanceId), instance)
+
+		ExpectReconcileSucceeded(ctx, garbageCollectionController, client.ObjectKey{})
+		_, err := cloudProvider.Get(ctx, providerID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(aws.StringValue(instance.State.Name)).To(Equal(ec2.InstanceStateNameStopped))
+	})
+	It("should delete an instance that was stopped for reuse once the stopped instance TTL has passed", func() {
+		instance.LaunchTime = aws.Time(time.Now().Add(-time.Hour * 2))
+		instance.State = &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameStopped)}
+		instance.Tags = append(instance.Tags, &ec2.Tag{
+			Key:   aws.String(v1beta1.AnnotationStoppedAt),
+			Value: aws.String(time.Now().Add(-time.Minute * 90).UTC().Format(time.RFC3339)),
+		})
+		awsEnv.EC2API.Instances.Store(aws.StringValue(instance.InstanceId), instance)
+
+		ExpectReconcileSucceeded(ctx, garbageCollectionController, client.ObjectKey{})
+		_, err := cloudProvider.Get(ctx, providerID)
+		Expect(corecloudprovider.IsNodeClaimNotFoundError(err)).To(BeTrue())
+	})
 	It("should delete many instances if they all don't have machine owners", func() {
 		// Generate 100 instances that have different instanceIDs
 		var ids []string
@@ -352,3 +423,105 @@ var _ = Describe("NodeClaimGarbageCollection", func() {
 		Expect(err).NotTo(HaveOccurred())
 	})
 })
+
+var _ = Describe("ResourceGarbageCollection", func() {
+	var tags []*ec2.Tag
+	var volumeTags []*ec2.Tag
+
+	BeforeEach(func() {
+		tags = []*ec2.Tag{
+			{
+				Key:   aws.String(fmt.Sprintf("kubernetes.io/cluster/%s", settings.FromContext(ctx).ClusterName)),
+				Value: aws.String("owned"),
+			},
+			{
+				Key:   aws.String(v1alpha5.MachineManagedByAnnotationKey),
+				Value: aws.String(settings.FromContext(ctx).ClusterName),
+			},
+		}
+		volumeTags = append(tags, &ec2.Tag{Key: aws.String(v1beta1.VolumeDeleteOnTerminationTagKey), Value: aws.String("true")})
+	})
+	It("should delete an unattached volume once it has been unattached for the grace period", func() {
+		awsEnv.EC2API.Volumes.Store("vol-1", &ec2.Volume{
+			VolumeId:   aws.String("vol-1"),
+			State:      aws.String(ec2.VolumeStateAvailable),
+			CreateTime: aws.Time(fakeClock.Now().Add(-time.Hour)),
+			Tags:       volumeTags,
+		})
+		// The grace period starts when the volume is first found unattached rather than when it was created
+		ExpectReconcileSucceeded(ctx, resourceController, client.ObjectKey{})
+		_, ok := awsEnv.EC2API.Volumes.Load("vol-1")
+		Expect(ok).To(BeTrue())
+
+		fakeClock.Step(time.Minute)
+		ExpectReconcileSucceeded(ctx, resourceController, client.ObjectKey{})
+		_, ok = awsEnv.EC2API.Volumes.Load("vol-1")
+		Expect(ok).To(BeFalse())
+	})
+	It("should not delete a volume that was retained on termination", func() {
+		awsEnv.EC2API.Volumes.Store("vol-1", &ec2.Volume{
+			VolumeId:   aws.String("vol-1"),
+			State:      aws.String(ec2.VolumeStateAvailable),
+			CreateTime: aws.Time(fakeClock.Now().Add(-time.Hour)),
+			Tags:       tags,
+		})
+		ExpectReconcileSucceeded(ctx, resourceController, client.ObjectKey{})
+		fakeClock.Step(time.Hour)
+		ExpectReconcileSucceeded(ctx, resourceController, client.ObjectKey{})
+		_, ok := awsEnv.EC2API.Volumes.Load("vol-1")
+		Expect(ok).To(BeTrue())
+	})
+	It("should not delete volumes that are attached or weren't created by Karpenter", func() {
+		awsEnv.EC2API.Volumes.Store("vol-1", &ec2.Volume{
+			VolumeId:   aws.String("vol-1"),
+			State:      aws.String(ec2.VolumeStateInUse),
+			CreateTime: aws.Time(fakeClock.Now().Add(-time.Hour)),
+			Tags:       volumeTags,
+		})
+		awsEnv.EC2API.Volumes.Store("vol-2", &ec2.Volume{
+			VolumeId:   aws.String("vol-2"),
+			State:      aws.String(ec2.VolumeStateAvailable),
+			CreateTime: aws.Time(fakeClock.Now().Add(-time.Hour)),
+			Tags:       volumeTags[1:],
+		})
+		ExpectReconcileSucceeded(ctx, resourceController, client.ObjectKey{})
+		fakeClock.Step(time.Minute)
+		ExpectReconcileSucceeded(ctx, resourceController, client.ObjectKey{})
+		_, ok := awsEnv.EC2API.Volumes.Load("vol-1")
+		Expect(ok).To(BeTrue())
+		_, ok = awsEnv.EC2API.Volumes.Load("vol-2")
+		Expect(ok).To(BeTrue())
+	})
+	It("should delete an unattached network interface once it has been unattached for the grace period", func() {
+		awsEnv.EC2API.NetworkInterfaces.Store("eni-1", &ec2.NetworkInterface{
+			NetworkInterfaceId: aws.String("eni-1"),
+			Status:             aws.String(ec2.NetworkInterfaceStatusAvailable),
+			TagSet:             tags,
+		})
+		ExpectReconcileSucceeded(ctx, resourceController, client.ObjectKey{})
+		_, ok := awsEnv.EC2API.NetworkInterfaces.Load("eni-1")
+		Expect(ok).To(BeTrue())
+
+		fakeClock.Step(time.Minute)
+		ExpectReconcileSucceeded(ctx, resourceController, client.ObjectKey{})
+		_, ok = awsEnv.EC2API.NetworkInterfaces.Load("eni-1")
+		Expect(ok).To(BeFalse())
+	})
+	It("should not delete resources in dry-run mode", func() {
+		ctx := settings.ToContext(ctx, test.Settings(test.SettingOptions{ResourceGarbageCollectionDryRun: lo.ToPtr(true)}))
+		awsEnv.EC2API.Volumes.Store("vol-1", &ec2.Volume{
+			VolumeId:   aws.String("vol-1"),
+			State:      aws.String(ec2.VolumeStateAvailable),
+			CreateTime: aws.Time(fakeClock.Now().Add(-time.Minute)),
+			Tags:       volumeTags,
+		})
+		ExpectReconcileSucceeded(ctx, resourceController, client.ObjectKey{})
+		fakeClock.Step(time.Minute)
+		ExpectReconcileSucceeded(ctx, resourceController, client.ObjectKey{})
+		_, ok := awsEnv.EC2API.Volumes.Load("vol-1")
+		Expect(ok).To(BeTrue())
+		metric, ok := FindMetricWithLabelValues("karpenter_cloudprovider_resources_garbage_collected_total", map[string]string{"resource_type": "volume", "dry_run": "true"})
+		Expect(ok).To(BeTrue())
+		Expect(metric.GetCounter().GetValue()).To(BeNumerically(">=", 1))
+	})
+})
@@ -0,0 +1,121 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/multierr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/clock"
+	"knative.dev/pkg/logging"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/events"
+	"github.com/aws/karpenter-core/pkg/operator/controller"
+	nodeclaimutil "github.com/aws/karpenter-core/pkg/utils/nodeclaim"
+	"github.com/aws/karpenter/pkg/cache"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+// UnavailableReason is the reason that the offerings of instances that didn't join the cluster are marked unavailable
+const UnavailableReason = "RegistrationTimeout"
+
+// Controller terminates the instances that haven't joined the cluster within the registration TTL of their NodeClass
+// since they launched, which is shorter than karpenter-core's registration TTL. The offering of the instance is marked unavailable so that
+// the NodeClaim's pods are relaunched on a different offering, which recovers from AMI or userData issues that only
+// affect some instance types.
+type Controller struct {
+	clk                  clock.Clock
+	kubeClient           client.Client
+	recorder             events.Recorder
+	unavailableOfferings *cache.UnavailableOfferings
+}
+
+func NewController(clk clock.Clock, kubeClient client.Client, recorder events.Recorder, unavailableOfferings *cache.UnavailableOfferings) *Controller {
+	return &Controller{
+		clk:                  clk,
+		kubeClient:           kubeClient,
+		recorder:             recorder,
+		unavailableOfferings: unavailableOfferings,
+	}
+}
+
+func (c *Controller) Name() string {
+	return "machine.registration"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	nodeClaimList, err := nodeclaimutil.List(ctx, c.kubeClient)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("listing nodeclaims, %w", err)
+	}
+	errs := make([]error, len(nodeClaimList.Items))
+	workqueue.ParallelizeUntil(ctx, 20, len(nodeClaimList.Items), func(i int) {
+		errs[i] = c.reconcile(ctx, &nodeClaimList.Items[i])
+	})
+	return reconcile.Result{RequeueAfter: 15 * time.Second}, multierr.Combine(errs...)
+}
+
+func (c *Controller) reconcile(ctx context.Context, nodeClaim *v1beta1.NodeClaim) error {
+	if !nodeClaim.DeletionTimestamp.IsZero() || nodeClaim.Spec.NodeClass == nil {
+		return nil
+	}
+	// The registration TTL is measured from the launch of the instance, so that the time spent launching it doesn't count
+	launched := nodeClaim.StatusConditions().GetCondition(v1beta1.NodeLaunched)
+	if launched == nil || !launched.IsTrue() {
+		return nil
+	}
+	if registered := nodeClaim.StatusConditions().GetCondition(v1beta1.NodeRegistered); registered == nil || registered.IsTrue() {
+		return nil
+	}
+	nodeClass, err := nodeclassutil.Get(ctx, c.kubeClient, nodeclassutil.Key{Name: nodeClaim.Spec.NodeClass.Name, IsNodeTemplate: nodeClaim.IsMachine})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("resolving node class, %w", err)
+	}
+	if nodeClass.Spec.RegistrationTTL == nil || c.clk.Since(launched.LastTransitionTime.Inner.Time) < nodeClass.Spec.RegistrationTTL.Duration {
+		return nil
+	}
+	instanceType := nodeClaim.Labels[v1.LabelInstanceTypeStable]
+	zone := nodeClaim.Labels[v1.LabelTopologyZone]
+	capacityType := nodeClaim.Labels[v1beta1.CapacityTypeLabelKey]
+	if instanceType != "" && zone != "" && capacityType != "" {
+		c.unavailableOfferings.MarkUnavailable(ctx, UnavailableReason, instanceType, zone, capacityType)
+	}
+	if err := nodeclaimutil.Delete(ctx, c.kubeClient, nodeClaim); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	logging.FromContext(ctx).With(
+		"nodeclaim", nodeClaim.Name,
+		"provider-id", nodeClaim.Status.ProviderID,
+		"ttl", nodeClass.Spec.RegistrationTTL.Duration).Infof("terminating due to node class registration ttl")
+	nodeclaimutil.TerminatedCounter(nodeClaim, "registration_ttl").Inc()
+	c.recorder.Publish(NodeClaimRegistrationTimedOut(nodeClaim, nodeClass.Spec.RegistrationTTL.Duration))
+	return nil
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) controller.Builder {
+	return controller.NewSingletonManagedBy(m)
+}
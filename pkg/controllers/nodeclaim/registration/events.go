@@ -0,0 +1,48 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registration
+
+import (
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/events"
+	machineutil "github.com/aws/karpenter-core/pkg/utils/machine"
+)
+
+func NodeClaimRegistrationTimedOut(nodeClaim *v1beta1.NodeClaim, ttl time.Duration) events.Event {
+	message := fmt.Sprintf("Terminating instance %s of type %s in %s, which didn't join the cluster within %s",
+		nodeClaim.Status.ProviderID, nodeClaim.Labels[v1.LabelInstanceTypeStable], nodeClaim.Labels[v1.LabelTopologyZone], ttl)
+	if nodeClaim.IsMachine {
+		machine := machineutil.NewFromNodeClaim(nodeClaim)
+		return events.Event{
+			InvolvedObject: machine,
+			Type:           v1.EventTypeWarning,
+			Reason:         "RegistrationTimedOut",
+			Message:        message,
+			DedupeValues:   []string{string(machine.UID)},
+		}
+	}
+	return events.Event{
+		InvolvedObject: nodeClaim,
+		Type:           v1.EventTypeWarning,
+		Reason:         "RegistrationTimedOut",
+		Message:        message,
+		DedupeValues:   []string{string(nodeClaim.UID)},
+	}
+}
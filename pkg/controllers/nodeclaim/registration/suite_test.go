@@ -0,0 +1,174 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registration_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clock "k8s.io/utils/clock/testing"
+	. "knative.dev/pkg/logging/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/cache"
+	"github.com/aws/karpenter/pkg/controllers/nodeclaim/registration"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var fakeClock *clock.FakeClock
+var recorder *coretest.EventRecorder
+var unavailableOfferings *cache.UnavailableOfferings
+var controller *registration.Controller
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Registration")
+}
+
+var _ = BeforeSuite(func() {
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	fakeClock = clock.NewFakeClock(time.Now())
+	recorder = coretest.NewEventRecorder()
+	unavailableOfferings = cache.NewUnavailableOfferings()
+	controller = registration.NewController(fakeClock, env.Client, recorder, unavailableOfferings)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	fakeClock.SetTime(time.Now())
+	recorder.Reset()
+	unavailableOfferings.Flush()
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("Registration", func() {
+	var nodeTemplate *v1alpha1.AWSNodeTemplate
+	var machine *v1alpha5.Machine
+
+	BeforeEach(func() {
+		nodeTemplate = test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+			RegistrationTTL: &metav1.Duration{Duration: 5 * time.Minute},
+		})
+		machine = coretest.Machine(v1alpha5.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					v1.LabelInstanceTypeStable: "m5.large",
+					v1.LabelTopologyZone:       "test-zone-1a",
+					v1alpha5.LabelCapacityType: v1alpha1.CapacityTypeOnDemand,
+				},
+			},
+			Spec: v1alpha5.MachineSpec{
+				MachineTemplateRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+			},
+			Status: v1alpha5.MachineStatus{
+				ProviderID: fake.ProviderID(fake.InstanceID()),
+			},
+		})
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineLaunched)
+		machine.StatusConditions().MarkFalse(v1alpha5.MachineRegistered, "", "")
+	})
+	It("should terminate a machine that hasn't registered within the registration TTL and mark its offering unavailable", func() {
+		ExpectApplied(ctx, env.Client, nodeTemplate, machine)
+		fakeClock.Step(6 * time.Minute)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+
+		ExpectNotFound(ctx, env.Client, machine)
+		Expect(unavailableOfferings.IsUnavailable("m5.large", "test-zone-1a", v1alpha1.CapacityTypeOnDemand)).To(BeTrue())
+		Expect(recorder.Calls("RegistrationTimedOut")).To(Equal(1))
+	})
+	It("should not terminate a machine within the registration TTL", func() {
+		ExpectApplied(ctx, env.Client, nodeTemplate, machine)
+		fakeClock.Step(time.Minute)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+
+		ExpectExists(ctx, env.Client, machine)
+		Expect(unavailableOfferings.IsUnavailable("m5.large", "test-zone-1a", v1alpha1.CapacityTypeOnDemand)).To(BeFalse())
+	})
+	It("should not terminate a machine that has registered", func() {
+		machine.StatusConditions().MarkTrue(v1alpha5.MachineRegistered)
+		ExpectApplied(ctx, env.Client, nodeTemplate, machine)
+		fakeClock.Step(6 * time.Minute)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+
+		ExpectExists(ctx, env.Client, machine)
+	})
+	It("should not terminate a machine when the node template doesn't have a registration TTL", func() {
+		nodeTemplate.Spec.RegistrationTTL = nil
+		ExpectApplied(ctx, env.Client, nodeTemplate, machine)
+		fakeClock.Step(10 * time.Minute)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+
+		ExpectExists(ctx, env.Client, machine)
+		Expect(recorder.Calls("RegistrationTimedOut")).To(Equal(0))
+	})
+	It("should not terminate a machine that hasn't launched", func() {
+		machine.Status.Conditions = nil
+		ExpectApplied(ctx, env.Client, nodeTemplate, machine)
+		fakeClock.Step(10 * time.Minute)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+
+		ExpectExists(ctx, env.Client, machine)
+	})
+	It("should not terminate a machine that is still launching", func() {
+		machine.StatusConditions().MarkFalse(v1alpha5.MachineLaunched, "", "")
+		ExpectApplied(ctx, env.Client, nodeTemplate, machine)
+		fakeClock.Step(10 * time.Minute)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+
+		ExpectExists(ctx, env.Client, machine)
+		Expect(recorder.Calls("RegistrationTimedOut")).To(Equal(0))
+	})
+	It("should measure the registration TTL from the launch of the machine", func() {
+		for i := range machine.Status.Conditions {
+			if machine.Status.Conditions[i].Type == v1alpha5.MachineLaunched {
+				machine.Status.Conditions[i].LastTransitionTime.Inner = metav1.NewTime(fakeClock.Now().Add(10 * time.Minute))
+			}
+		}
+		ExpectApplied(ctx, env.Client, nodeTemplate, machine)
+		fakeClock.Step(12 * time.Minute)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+		ExpectExists(ctx, env.Client, machine)
+
+		fakeClock.Step(4 * time.Minute)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+		ExpectNotFound(ctx, env.Client, machine)
+	})
+})
@@ -0,0 +1,169 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statuscheck
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/logging"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/events"
+	"github.com/aws/karpenter-core/pkg/operator/controller"
+	nodeclaimutil "github.com/aws/karpenter-core/pkg/utils/nodeclaim"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/cloudprovider"
+	"github.com/aws/karpenter/pkg/utils"
+)
+
+const (
+	CheckSystem   = "system"
+	CheckInstance = "instance"
+
+	// maxInstanceIDs is the most instance IDs that DescribeInstanceStatus accepts in a single request
+	maxInstanceIDs = 100
+)
+
+// Controller polls the EC2 status checks of the instances that Karpenter launched and publishes an Unhealthy event
+// for the NodeClaims and Nodes of instances that fail their system or instance status check. Otherwise, instances on
+// degraded hardware linger as NotReady nodes until they're deleted by hand. If aws.replaceImpairedInstances is
+// enabled, the NodeClaims are deleted so that their pods are rescheduled on replacement capacity.
+type Controller struct {
+	kubeClient client.Client
+	recorder   events.Recorder
+	ec2api     ec2iface.EC2API
+}
+
+func NewController(kubeClient client.Client, recorder events.Recorder, ec2api ec2iface.EC2API) *Controller {
+	return &Controller{
+		kubeClient: kubeClient,
+		recorder:   recorder,
+		ec2api:     ec2api,
+	}
+}
+
+func (c *Controller) Name() string {
+	return "machine.statuscheck"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	nodeClaimList, err := nodeclaimutil.List(ctx, c.kubeClient)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("listing nodeclaims, %w", err)
+	}
+	nodeClaims := map[string]*v1beta1.NodeClaim{}
+	for i := range nodeClaimList.Items {
+		nodeClaim := &nodeClaimList.Items[i]
+		if nodeClaim.Status.ProviderID == "" || !nodeClaim.DeletionTimestamp.IsZero() {
+			continue
+		}
+		instanceID, err := utils.ParseInstanceID(nodeClaim.Status.ProviderID)
+		if err != nil {
+			continue
+		}
+		nodeClaims[instanceID] = nodeClaim
+	}
+	statuses, err := c.impairedInstances(ctx, lo.Keys(nodeClaims))
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	impairedInstances.Reset()
+	var errs []error
+	for instanceID, checks := range statuses {
+		for _, check := range checks {
+			impairedInstances.WithLabelValues(check).Inc()
+		}
+		errs = append(errs, c.handleImpaired(ctx, nodeClaims[instanceID], checks))
+	}
+	return reconcile.Result{RequeueAfter: time.Minute}, multierr.Combine(errs...)
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) controller.Builder {
+	return controller.NewSingletonManagedBy(m)
+}
+
+// impairedInstances returns the status checks that each of the instances is failing, keyed by instance ID. Instances
+// whose checks pass or are still initializing aren't returned.
+func (c *Controller) impairedInstances(ctx context.Context, instanceIDs []string) (map[string][]string, error) {
+	impaired := map[string][]string{}
+	for _, chunk := range lo.Chunk(instanceIDs, maxInstanceIDs) {
+		if err := c.ec2api.DescribeInstanceStatusPagesWithContext(ctx, &ec2.DescribeInstanceStatusInput{
+			InstanceIds: aws.StringSlice(chunk),
+		}, func(page *ec2.DescribeInstanceStatusOutput, _ bool) bool {
+			for _, status := range page.InstanceStatuses {
+				var checks []string
+				if status.SystemStatus != nil && aws.StringValue(status.SystemStatus.Status) == ec2.SummaryStatusImpaired {
+					checks = append(checks, CheckSystem)
+				}
+				if status.InstanceStatus != nil && aws.StringValue(status.InstanceStatus.Status) == ec2.SummaryStatusImpaired {
+					checks = append(checks, CheckInstance)
+				}
+				if len(checks) > 0 {
+					impaired[aws.StringValue(status.InstanceId)] = checks
+				}
+			}
+			return true
+		}); err != nil {
+			return nil, fmt.Errorf("describing instance status, %w", err)
+		}
+	}
+	return impaired, nil
+}
+
+func (c *Controller) handleImpaired(ctx context.Context, nodeClaim *v1beta1.NodeClaim, checks []string) error {
+	if nodeClaim == nil {
+		return nil
+	}
+	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("provider-id", nodeClaim.Status.ProviderID, "checks", checks))
+	var node *v1.Node
+	if nodeClaim.Status.NodeName != "" {
+		node = &v1.Node{}
+		if err := c.kubeClient.Get(ctx, types.NamespacedName{Name: nodeClaim.Status.NodeName}, node); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return fmt.Errorf("getting node, %w", err)
+			}
+			node = nil
+		}
+	}
+	c.recorder.Publish(Unhealthy(node, nodeClaim, checks)...)
+	if !settings.FromContext(ctx).ReplaceImpairedInstances {
+		logging.FromContext(ctx).Debugf("instance failed its status checks")
+		return nil
+	}
+	// Attribute the termination so that the cloudprovider can report it once the instance is deleted
+	stored := nodeClaim.DeepCopy()
+	nodeClaim.Annotations = lo.Assign(nodeClaim.Annotations, map[string]string{cloudprovider.TerminationReasonAnnotationKey(nodeClaim): string(cloudprovider.TerminationReasonHealth)})
+	if err := nodeclaimutil.Patch(ctx, c.kubeClient, stored, nodeClaim); err != nil {
+		return client.IgnoreNotFound(fmt.Errorf("annotating the nodeclaim with the termination reason, %w", err))
+	}
+	if err := nodeclaimutil.Delete(ctx, c.kubeClient, nodeClaim); err != nil {
+		return client.IgnoreNotFound(fmt.Errorf("deleting the nodeclaim of an impaired instance, %w", err))
+	}
+	logging.FromContext(ctx).Infof("replacing instance that failed its status checks")
+	nodeclaimutil.TerminatedCounter(nodeClaim, "impaired").Inc()
+	return nil
+}
@@ -0,0 +1,58 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statuscheck
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/events"
+	machineutil "github.com/aws/karpenter-core/pkg/utils/machine"
+)
+
+func Unhealthy(node *v1.Node, nodeClaim *v1beta1.NodeClaim, checks []string) (evts []events.Event) {
+	message := fmt.Sprintf("Instance failed its EC2 %s status check", strings.Join(checks, " and "))
+	if nodeClaim.IsMachine {
+		machine := machineutil.NewFromNodeClaim(nodeClaim)
+		evts = append(evts, events.Event{
+			InvolvedObject: machine,
+			Type:           v1.EventTypeWarning,
+			Reason:         "Unhealthy",
+			Message:        message,
+			DedupeValues:   []string{string(machine.UID)},
+		})
+	} else {
+		evts = append(evts, events.Event{
+			InvolvedObject: nodeClaim,
+			Type:           v1.EventTypeWarning,
+			Reason:         "Unhealthy",
+			Message:        message,
+			DedupeValues:   []string{string(nodeClaim.UID)},
+		})
+	}
+	if node != nil {
+		evts = append(evts, events.Event{
+			InvolvedObject: node,
+			Type:           v1.EventTypeWarning,
+			Reason:         "Unhealthy",
+			Message:        message,
+			DedupeValues:   []string{string(node.UID)},
+		})
+	}
+	return evts
+}
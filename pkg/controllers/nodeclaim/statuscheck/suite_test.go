@@ -0,0 +1,166 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statuscheck_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	. "knative.dev/pkg/logging/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/controllers/nodeclaim/statuscheck"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var ec2api *fake.EC2API
+var recorder *coretest.EventRecorder
+var controller *statuscheck.Controller
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "StatusCheck")
+}
+
+var _ = BeforeSuite(func() {
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ec2api = &fake.EC2API{}
+	recorder = coretest.NewEventRecorder()
+	controller = statuscheck.NewController(env.Client, recorder, ec2api)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+		EnableInstanceStatusChecks: lo.ToPtr(true),
+	}))
+	ec2api.Reset()
+	recorder.Reset()
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("StatusCheck", func() {
+	var instanceID string
+	var machine *v1alpha5.Machine
+	var node *v1.Node
+
+	BeforeEach(func() {
+		instanceID = fake.InstanceID()
+		node = coretest.Node(coretest.NodeOptions{
+			ProviderID: fake.ProviderID(instanceID),
+		})
+		machine = coretest.Machine(v1alpha5.Machine{
+			Status: v1alpha5.MachineStatus{
+				ProviderID: fake.ProviderID(instanceID),
+				NodeName:   node.Name,
+			},
+		})
+	})
+	instanceStatus := func(system, instance string) *ec2.DescribeInstanceStatusOutput {
+		return &ec2.DescribeInstanceStatusOutput{
+			InstanceStatuses: []*ec2.InstanceStatus{
+				{
+					InstanceId:     aws.String(instanceID),
+					SystemStatus:   &ec2.InstanceStatusSummary{Status: aws.String(system)},
+					InstanceStatus: &ec2.InstanceStatusSummary{Status: aws.String(instance)},
+				},
+			},
+		}
+	}
+	It("should publish Unhealthy events for an instance that fails its system status check", func() {
+		ec2api.DescribeInstanceStatusBehavior.Output.Set(instanceStatus(ec2.SummaryStatusImpaired, ec2.SummaryStatusOk))
+		ExpectApplied(ctx, env.Client, machine, node)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+
+		Expect(ec2api.DescribeInstanceStatusBehavior.CalledWithInput.Pop().InstanceIds).To(ConsistOf(aws.String(instanceID)))
+		Expect(recorder.Calls("Unhealthy")).To(Equal(2))
+		ExpectExists(ctx, env.Client, machine)
+	})
+	It("should publish Unhealthy events for an instance that fails its instance status check", func() {
+		ec2api.DescribeInstanceStatusBehavior.Output.Set(instanceStatus(ec2.SummaryStatusOk, ec2.SummaryStatusImpaired))
+		ExpectApplied(ctx, env.Client, machine, node)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+
+		Expect(recorder.Calls("Unhealthy")).To(Equal(2))
+		ExpectExists(ctx, env.Client, machine)
+	})
+	It("should not publish events for an instance that passes its status checks", func() {
+		ec2api.DescribeInstanceStatusBehavior.Output.Set(instanceStatus(ec2.SummaryStatusOk, ec2.SummaryStatusOk))
+		ExpectApplied(ctx, env.Client, machine, node)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+
+		Expect(recorder.Calls("Unhealthy")).To(Equal(0))
+		ExpectExists(ctx, env.Client, machine)
+	})
+	It("should not publish events for an instance whose status checks are initializing", func() {
+		ec2api.DescribeInstanceStatusBehavior.Output.Set(instanceStatus(ec2.SummaryStatusInitializing, ec2.SummaryStatusInitializing))
+		ExpectApplied(ctx, env.Client, machine, node)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+
+		Expect(recorder.Calls("Unhealthy")).To(Equal(0))
+	})
+	It("should not describe the status of a machine that hasn't launched", func() {
+		machine.Status.ProviderID = ""
+		ExpectApplied(ctx, env.Client, machine)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+
+		Expect(ec2api.DescribeInstanceStatusBehavior.Calls()).To(Equal(0))
+	})
+	It("should delete the machine of an impaired instance when replaceImpairedInstances is enabled", func() {
+		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+			EnableInstanceStatusChecks: lo.ToPtr(true),
+			ReplaceImpairedInstances:   lo.ToPtr(true),
+		}))
+		ec2api.DescribeInstanceStatusBehavior.Output.Set(instanceStatus(ec2.SummaryStatusImpaired, ec2.SummaryStatusImpaired))
+		ExpectApplied(ctx, env.Client, machine, node)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+
+		Expect(recorder.Calls("Unhealthy")).To(Equal(2))
+		ExpectNotFound(ctx, env.Client, machine)
+	})
+	It("should publish an event on the machine when its node doesn't exist", func() {
+		machine.Status.NodeName = ""
+		ec2api.DescribeInstanceStatusBehavior.Output.Set(instanceStatus(ec2.SummaryStatusImpaired, ec2.SummaryStatusOk))
+		ExpectApplied(ctx, env.Client, machine)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+
+		Expect(recorder.Calls("Unhealthy")).To(Equal(1))
+	})
+})
@@ -18,56 +18,82 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
 	"time"
 
 	"go.uber.org/multierr"
 	"golang.org/x/time/rate"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/client-go/util/workqueue"
+	"knative.dev/pkg/system"
 	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/mitchellh/hashstructure/v2"
 	"github.com/samber/lo"
 
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
 	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	nodeclaimutil "github.com/aws/karpenter-core/pkg/utils/nodeclaim"
+	"github.com/aws/karpenter/pkg/apis/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/apis/v1beta1"
 	"github.com/aws/karpenter/pkg/providers/amifamily"
+	"github.com/aws/karpenter/pkg/providers/instanceprofile"
+	"github.com/aws/karpenter/pkg/providers/launchtemplate"
 	"github.com/aws/karpenter/pkg/providers/securitygroup"
 	"github.com/aws/karpenter/pkg/providers/subnet"
 	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
 )
 
 type Controller struct {
-	kubeClient            client.Client
-	subnetProvider        *subnet.Provider
-	securityGroupProvider *securitygroup.Provider
-	amiProvider           *amifamily.Provider
+	kubeClient              client.Client
+	subnetProvider          *subnet.Provider
+	securityGroupProvider   *securitygroup.Provider
+	amiProvider             *amifamily.Provider
+	launchTemplateProvider  *launchtemplate.Provider
+	instanceProfileProvider *instanceprofile.Provider
 }
 
-func NewController(kubeClient client.Client, subnetProvider *subnet.Provider,
-	securityGroupProvider *securitygroup.Provider, amiProvider *amifamily.Provider) *Controller {
+func NewController(kubeClient client.Client, subnetProvider *subnet.Provider, securityGroupProvider *securitygroup.Provider,
+	amiProvider *amifamily.Provider, launchTemplateProvider *launchtemplate.Provider, instanceProfileProvider *instanceprofile.Provider) *Controller {
 	return &Controller{
-		kubeClient:            kubeClient,
-		subnetProvider:        subnetProvider,
-		securityGroupProvider: securityGroupProvider,
-		amiProvider:           amiProvider,
+		kubeClient:              kubeClient,
+		subnetProvider:          subnetProvider,
+		securityGroupProvider:   securityGroupProvider,
+		amiProvider:             amiProvider,
+		launchTemplateProvider:  launchTemplateProvider,
+		instanceProfileProvider: instanceProfileProvider,
 	}
 }
 
 func (c *Controller) Reconcile(ctx context.Context, nodeClass *v1beta1.NodeClass) (reconcile.Result, error) {
 	stored := nodeClass.DeepCopy()
+	controllerutil.AddFinalizer(nodeClass, terminationFinalizer(nodeClass))
 	nodeClass.Annotations = lo.Assign(nodeClass.Annotations, nodeclassutil.HashAnnotation(nodeClass))
 	err := multierr.Combine(
 		c.resolveSubnets(ctx, nodeClass),
 		c.resolveSecurityGroups(ctx, nodeClass),
 		c.resolveAMIs(ctx, nodeClass),
+		c.resolveInstanceProfile(ctx, nodeClass),
+		c.resolveUserData(ctx, nodeClass),
 	)
+	// Only attempt a dry run once everything it depends on has been resolved
+	if err == nil {
+		err = c.validate(ctx, nodeClass)
+	}
 	if !equality.Semantic.DeepEqual(stored, nodeClass) {
 		statusCopy := nodeClass.DeepCopy()
 		if patchErr := nodeclassutil.Patch(ctx, c.kubeClient, stored, nodeClass); patchErr != nil {
@@ -80,6 +106,63 @@ func (c *Controller) Reconcile(ctx context.Context, nodeClass *v1beta1.NodeClass
 	return reconcile.Result{RequeueAfter: 5 * time.Minute}, err
 }
 
+// Finalize holds the NodeClass in deletion until no NodeClaims reference it so that they aren't left without the
+// configuration they were launched with. A "cascade" deletion policy deletes the NodeClaims instead of waiting on them.
+func (c *Controller) Finalize(ctx context.Context, nodeClass *v1beta1.NodeClass) (reconcile.Result, error) {
+	stored := nodeClass.DeepCopy()
+	if !controllerutil.ContainsFinalizer(nodeClass, terminationFinalizer(nodeClass)) {
+		return reconcile.Result{}, nil
+	}
+	nodeClaims, err := c.nodeClaimsForNodeClass(ctx, nodeClass)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("listing nodeclaims, %w", err)
+	}
+	if len(nodeClaims) > 0 {
+		if lo.FromPtr(nodeClass.Spec.DeletionPolicy) == v1beta1.DeletionPolicyCascade {
+			for i := range nodeClaims {
+				if nodeClaims[i].DeletionTimestamp.IsZero() {
+					err = multierr.Append(err, client.IgnoreNotFound(nodeclaimutil.Delete(ctx, c.kubeClient, &nodeClaims[i])))
+				}
+			}
+		}
+		names := lo.Map(nodeClaims, func(nc corev1beta1.NodeClaim, _ int) string { return nc.Name })
+		sort.Strings(names)
+		nodeClass.StatusConditions().MarkFalse(v1beta1.NodeClassNodeClaimsTerminated, "NodeClaimsExist", "waiting on %s to terminate, %s",
+			lo.Ternary(nodeClass.IsNodeTemplate, "machines", "nodeclaims"), strings.Join(names, ", "))
+		if !equality.Semantic.DeepEqual(stored, nodeClass) {
+			if patchErr := nodeclassutil.PatchStatus(ctx, c.kubeClient, stored, nodeClass); patchErr != nil {
+				err = multierr.Append(err, client.IgnoreNotFound(patchErr))
+			}
+		}
+		return reconcile.Result{RequeueAfter: 10 * time.Second}, err
+	}
+	if nodeClass.Spec.Role != nil {
+		if err = c.instanceProfileProvider.Delete(ctx, nodeClass); err != nil {
+			return reconcile.Result{}, fmt.Errorf("deleting instance profile, %w", err)
+		}
+	}
+	controllerutil.RemoveFinalizer(nodeClass, terminationFinalizer(nodeClass))
+	if err = nodeclassutil.Patch(ctx, c.kubeClient, stored, nodeClass); err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(fmt.Errorf("removing termination finalizer, %w", err))
+	}
+	return reconcile.Result{}, nil
+}
+
+// nodeClaimsForNodeClass returns the NodeClaims, or Machines for an AWSNodeTemplate, that reference the NodeClass
+func (c *Controller) nodeClaimsForNodeClass(ctx context.Context, nodeClass *v1beta1.NodeClass) ([]corev1beta1.NodeClaim, error) {
+	nodeClaimList, err := nodeclaimutil.List(ctx, c.kubeClient)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Filter(nodeClaimList.Items, func(nc corev1beta1.NodeClaim, _ int) bool {
+		return nc.Spec.NodeClass != nil && nc.Spec.NodeClass.Name == nodeClass.Name && nc.IsMachine == nodeClass.IsNodeTemplate
+	}), nil
+}
+
+func terminationFinalizer(nodeClass *v1beta1.NodeClass) string {
+	return lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.TerminationFinalizer, v1beta1.TerminationFinalizer)
+}
+
 func (c *Controller) resolveSubnets(ctx context.Context, nodeClass *v1beta1.NodeClass) error {
 	subnetList, err := c.subnetProvider.List(ctx, nodeClass)
 	if err != nil {
@@ -87,6 +170,12 @@ func (c *Controller) resolveSubnets(ctx context.Context, nodeClass *v1beta1.Node
 	}
 	if len(subnetList) == 0 {
 		nodeClass.Status.Subnets = nil
+		if lo.FromPtr(nodeClass.Spec.SubnetPolicy) == v1beta1.SubnetPolicyPrivateOnly {
+			nodeClass.StatusConditions().MarkFalse(v1beta1.NodeClassSubnetsReady, "PrivateSubnetsNotFound",
+				"no private subnets exist given constraints %v, subnets that map public IPs on launch or route to an internet gateway are excluded by subnetPolicy", nodeClass.Spec.SubnetSelectorTerms)
+			return fmt.Errorf("no private subnets exist given constraints %v", nodeClass.Spec.SubnetSelectorTerms)
+		}
+		nodeClass.StatusConditions().MarkFalse(v1beta1.NodeClassSubnetsReady, "SubnetsNotFound", "no subnets exist given constraints %v", nodeClass.Spec.SubnetSelectorTerms)
 		return fmt.Errorf("no subnets exist given constraints %v", nodeClass.Spec.SubnetSelectorTerms)
 	}
 	sort.Slice(subnetList, func(i, j int) bool {
@@ -94,10 +183,13 @@ func (c *Controller) resolveSubnets(ctx context.Context, nodeClass *v1beta1.Node
 	})
 	nodeClass.Status.Subnets = lo.Map(subnetList, func(ec2subnet *ec2.Subnet, _ int) v1beta1.Subnet {
 		return v1beta1.Subnet{
-			ID:   *ec2subnet.SubnetId,
-			Zone: *ec2subnet.AvailabilityZone,
+			ID:                      *ec2subnet.SubnetId,
+			Zone:                    *ec2subnet.AvailabilityZone,
+			ZoneID:                  aws.StringValue(ec2subnet.AvailabilityZoneId),
+			AvailableIPAddressCount: aws.Int64Value(ec2subnet.AvailableIpAddressCount),
 		}
 	})
+	nodeClass.StatusConditions().MarkTrue(v1beta1.NodeClassSubnetsReady)
 	return nil
 }
 
@@ -108,6 +200,7 @@ func (c *Controller) resolveSecurityGroups(ctx context.Context, nodeClass *v1bet
 	}
 	if len(securityGroups) == 0 && len(nodeClass.Spec.SecurityGroupSelectorTerms) > 0 {
 		nodeClass.Status.SecurityGroups = nil
+		nodeClass.StatusConditions().MarkFalse(v1beta1.NodeClassSecurityGroupsReady, "SecurityGroupsNotFound", "no security groups exist given constraints %v", nodeClass.Spec.SecurityGroupSelectorTerms)
 		return fmt.Errorf("no security groups exist given constraints")
 	}
 	nodeClass.Status.SecurityGroups = lo.Map(securityGroups, func(securityGroup *ec2.SecurityGroup, _ int) v1beta1.SecurityGroup {
@@ -116,6 +209,19 @@ func (c *Controller) resolveSecurityGroups(ctx context.Context, nodeClass *v1bet
 			Name: *securityGroup.GroupName,
 		}
 	})
+	subnets, err := c.subnetProvider.List(ctx, nodeClass)
+	if err != nil {
+		return err
+	}
+	if err = c.securityGroupProvider.ValidateVPC(securityGroups, subnets); err != nil {
+		nodeClass.StatusConditions().MarkFalse(v1beta1.NodeClassSecurityGroupsReady, "SecurityGroupVPCMismatch", "%s", err)
+		return err
+	}
+	if err = c.securityGroupProvider.ValidateCount(ctx, securityGroups); err != nil {
+		nodeClass.StatusConditions().MarkFalse(v1beta1.NodeClassSecurityGroupsReady, "TooManySecurityGroups", "%s", err)
+		return err
+	}
+	nodeClass.StatusConditions().MarkTrue(v1beta1.NodeClassSecurityGroupsReady)
 	return nil
 }
 
@@ -139,15 +245,63 @@ func (c *Controller) resolveAMIs(ctx context.Context, nodeClass *v1beta1.NodeCla
 	return nil
 }
 
+func (c *Controller) resolveInstanceProfile(ctx context.Context, nodeClass *v1beta1.NodeClass) error {
+	// Custom launch templates carry their own instance profile
+	if nodeClass.Spec.LaunchTemplateName != nil {
+		nodeClass.Status.InstanceProfile = ""
+		return nil
+	}
+	// The instance profile of a NodeClass with a role is managed by Karpenter, and its role is replaced when spec.role
+	// changes. Nodes that were launched with the previous role are drifted, since the role is part of the NodeClass hash.
+	if nodeClass.Spec.Role != nil {
+		name, err := c.instanceProfileProvider.Create(ctx, nodeClass)
+		if err != nil {
+			return fmt.Errorf("resolving instance profile, %w", err)
+		}
+		nodeClass.Status.InstanceProfile = name
+		return nil
+	}
+	nodeClass.Status.InstanceProfile = lo.FromPtrOr(nodeClass.Spec.InstanceProfile, settings.FromContext(ctx).DefaultInstanceProfile)
+	if nodeClass.Status.InstanceProfile == "" {
+		return fmt.Errorf("neither spec.instanceProfile nor --aws-default-instance-profile is specified")
+	}
+	return nil
+}
+
+// resolveUserData hashes the UserData that userDataRef references so that nodes drift when it changes
+func (c *Controller) resolveUserData(ctx context.Context, nodeClass *v1beta1.NodeClass) error {
+	if nodeClass.Spec.UserDataRef == nil {
+		nodeClass.Status.UserDataHash = ""
+		return nil
+	}
+	userData, err := c.launchTemplateProvider.ResolveUserData(ctx, nodeClass)
+	if err != nil {
+		return err
+	}
+	nodeClass.Status.UserDataHash = fmt.Sprint(lo.Must(hashstructure.Hash(lo.FromPtr(userData), hashstructure.FormatV2, nil)))
+	return nil
+}
+
+// validate performs a DryRun launch with the resolved AMIs, subnets, security groups and instance profile so that
+// permission and parameter errors are reported on the NodeClass before any nodes are launched with it
+func (c *Controller) validate(ctx context.Context, nodeClass *v1beta1.NodeClass) error {
+	if err := c.launchTemplateProvider.Validate(ctx, nodeClass); err != nil {
+		nodeClass.StatusConditions().MarkFalse(v1beta1.NodeClassValidationSucceeded, "DryRunFailed", "%s", err)
+		return fmt.Errorf("validating nodeclass, %w", err)
+	}
+	nodeClass.StatusConditions().MarkTrue(v1beta1.NodeClassValidationSucceeded)
+	return nil
+}
+
 //nolint:revive
 type NodeClassController struct {
 	*Controller
 }
 
-func NewNodeClassController(kubeClient client.Client, subnetProvider *subnet.Provider,
-	securityGroupProvider *securitygroup.Provider, amiProvider *amifamily.Provider) corecontroller.Controller {
+func NewNodeClassController(kubeClient client.Client, subnetProvider *subnet.Provider, securityGroupProvider *securitygroup.Provider,
+	amiProvider *amifamily.Provider, launchTemplateProvider *launchtemplate.Provider, instanceProfileProvider *instanceprofile.Provider) corecontroller.Controller {
 	return corecontroller.Typed[*v1beta1.NodeClass](kubeClient, &NodeClassController{
-		Controller: NewController(kubeClient, subnetProvider, securityGroupProvider, amiProvider),
+		Controller: NewController(kubeClient, subnetProvider, securityGroupProvider, amiProvider, launchTemplateProvider, instanceProfileProvider),
 	})
 }
 
@@ -156,10 +310,16 @@ func (c *NodeClassController) Name() string {
 }
 
 func (c *NodeClassController) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	nsCache := lo.Must(newNamespacedCache(m))
 	return corecontroller.Adapt(controllerruntime.
 		NewControllerManagedBy(m).
-		For(&v1beta1.NodeClass{}).
-		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		For(&v1beta1.NodeClass{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(source.NewKindWithCache(&v1.ConfigMap{}, nsCache), handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+			return c.nodeClassesForUserData(v1beta1.UserDataRefKindConfigMap, o.GetName())
+		})).
+		Watches(source.NewKindWithCache(&v1.Secret{}, nsCache), handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+			return c.nodeClassesForUserData(v1beta1.UserDataRefKindSecret, o.GetName())
+		})).
 		WithOptions(controller.Options{
 			RateLimiter: workqueue.NewMaxOfRateLimiter(
 				workqueue.NewItemExponentialFailureRateLimiter(100*time.Millisecond, 1*time.Minute),
@@ -174,10 +334,10 @@ type NodeTemplateController struct {
 	*Controller
 }
 
-func NewNodeTemplateController(kubeClient client.Client, subnetProvider *subnet.Provider,
-	securityGroupProvider *securitygroup.Provider, amiProvider *amifamily.Provider) corecontroller.Controller {
+func NewNodeTemplateController(kubeClient client.Client, subnetProvider *subnet.Provider, securityGroupProvider *securitygroup.Provider,
+	amiProvider *amifamily.Provider, launchTemplateProvider *launchtemplate.Provider, instanceProfileProvider *instanceprofile.Provider) corecontroller.Controller {
 	return corecontroller.Typed[*v1alpha1.AWSNodeTemplate](kubeClient, &NodeTemplateController{
-		Controller: NewController(kubeClient, subnetProvider, securityGroupProvider, amiProvider),
+		Controller: NewController(kubeClient, subnetProvider, securityGroupProvider, amiProvider, launchTemplateProvider, instanceProfileProvider),
 	})
 }
 
@@ -185,15 +345,25 @@ func (c *NodeTemplateController) Reconcile(ctx context.Context, nodeTemplate *v1
 	return c.Controller.Reconcile(ctx, nodeclassutil.New(nodeTemplate))
 }
 
+func (c *NodeTemplateController) Finalize(ctx context.Context, nodeTemplate *v1alpha1.AWSNodeTemplate) (reconcile.Result, error) {
+	return c.Controller.Finalize(ctx, nodeclassutil.New(nodeTemplate))
+}
+
 func (c *NodeTemplateController) Name() string {
 	return "awsnodetemplate"
 }
 
 func (c *NodeTemplateController) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	nsCache := lo.Must(newNamespacedCache(m))
 	return corecontroller.Adapt(controllerruntime.
 		NewControllerManagedBy(m).
-		For(&v1alpha1.AWSNodeTemplate{}).
-		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		For(&v1alpha1.AWSNodeTemplate{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(source.NewKindWithCache(&v1.ConfigMap{}, nsCache), handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+			return c.nodeTemplatesForUserData(v1alpha1.UserDataRefKindConfigMap, o.GetName())
+		})).
+		Watches(source.NewKindWithCache(&v1.Secret{}, nsCache), handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+			return c.nodeTemplatesForUserData(v1alpha1.UserDataRefKindSecret, o.GetName())
+		})).
 		WithOptions(controller.Options{
 			RateLimiter: workqueue.NewMaxOfRateLimiter(
 				workqueue.NewItemExponentialFailureRateLimiter(100*time.Millisecond, 1*time.Minute),
@@ -203,3 +373,37 @@ func (c *NodeTemplateController) Builder(_ context.Context, m manager.Manager) c
 			MaxConcurrentReconciles: 10,
 		}))
 }
+
+// nodeClassesForUserData enqueues the NodeClasses whose userDataRef references the ConfigMap or Secret
+func (c *NodeClassController) nodeClassesForUserData(kind, name string) []reconcile.Request {
+	nodeClassList := &v1beta1.NodeClassList{}
+	if err := c.kubeClient.List(context.Background(), nodeClassList); err != nil {
+		return nil
+	}
+	return lo.FilterMap(nodeClassList.Items, func(nc v1beta1.NodeClass, _ int) (reconcile.Request, bool) {
+		return reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&nc)},
+			nc.Spec.UserDataRef != nil && nc.Spec.UserDataRef.Kind == kind && nc.Spec.UserDataRef.Name == name
+	})
+}
+
+// nodeTemplatesForUserData enqueues the AWSNodeTemplates whose userDataRef references the ConfigMap or Secret
+func (c *NodeTemplateController) nodeTemplatesForUserData(kind, name string) []reconcile.Request {
+	nodeTemplateList := &v1alpha1.AWSNodeTemplateList{}
+	if err := c.kubeClient.List(context.Background(), nodeTemplateList); err != nil {
+		return nil
+	}
+	return lo.FilterMap(nodeTemplateList.Items, func(nt v1alpha1.AWSNodeTemplate, _ int) (reconcile.Request, bool) {
+		return reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&nt)},
+			nt.Spec.UserDataRef != nil && nt.Spec.UserDataRef.Kind == kind && nt.Spec.UserDataRef.Name == name
+	})
+}
+
+// newNamespacedCache returns a cache of Karpenter's namespace, since Karpenter is only permitted to read the
+// ConfigMaps and Secrets that userDataRef references from there
+func newNamespacedCache(m manager.Manager) (cache.Cache, error) {
+	c, err := cache.New(m.GetConfig(), cache.Options{Scheme: m.GetScheme(), Mapper: m.GetRESTMapper(), Namespace: system.Namespace()})
+	if err != nil {
+		return nil, err
+	}
+	return c, m.Add(c)
+}
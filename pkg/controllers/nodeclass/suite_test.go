@@ -22,7 +22,9 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/iam"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/samber/lo"
@@ -45,7 +47,9 @@ import (
 	"github.com/aws/karpenter/pkg/apis"
 	"github.com/aws/karpenter/pkg/apis/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
 	"github.com/aws/karpenter/pkg/controllers/nodeclass"
+	"github.com/aws/karpenter/pkg/providers/instanceprofile"
 	"github.com/aws/karpenter/pkg/test"
 )
 
@@ -55,6 +59,7 @@ var awsEnv *test.Environment
 var opts options.Options
 var nodeTemplate *v1alpha1.AWSNodeTemplate
 var controller corecontroller.Controller
+var nodeClassController corecontroller.Controller
 
 func TestAPIs(t *testing.T) {
 	ctx = TestContextWithLogger(t)
@@ -68,7 +73,8 @@ var _ = BeforeSuite(func() {
 	ctx = settings.ToContext(ctx, test.Settings())
 	awsEnv = test.NewEnvironment(ctx, env)
 
-	controller = nodeclass.NewNodeTemplateController(env.Client, awsEnv.SubnetProvider, awsEnv.SecurityGroupProvider, awsEnv.AMIProvider)
+	controller = nodeclass.NewNodeTemplateController(env.Client, awsEnv.SubnetProvider, awsEnv.SecurityGroupProvider, awsEnv.AMIProvider, awsEnv.LaunchTemplateProvider, awsEnv.InstanceProfileProvider)
+	nodeClassController = nodeclass.NewNodeClassController(env.Client, awsEnv.SubnetProvider, awsEnv.SecurityGroupProvider, awsEnv.AMIProvider, awsEnv.LaunchTemplateProvider, awsEnv.InstanceProfileProvider)
 })
 
 var _ = AfterSuite(func() {
@@ -106,40 +112,52 @@ var _ = Describe("AWSNodeTemplateController", func() {
 			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
 			Expect(nodeTemplate.Status.Subnets).To(ConsistOf(
 				v1alpha1.Subnet{
-					ID:   "subnet-test1",
-					Zone: "test-zone-1a",
+					ID:                      "subnet-test1",
+					Zone:                    "test-zone-1a",
+					ZoneID:                  "testzone1a",
+					AvailableIPAddressCount: 100,
 				},
 				v1alpha1.Subnet{
-					ID:   "subnet-test2",
-					Zone: "test-zone-1b",
+					ID:                      "subnet-test2",
+					Zone:                    "test-zone-1b",
+					ZoneID:                  "testzone1b",
+					AvailableIPAddressCount: 100,
 				},
 				v1alpha1.Subnet{
-					ID:   "subnet-test3",
-					Zone: "test-zone-1c",
+					ID:                      "subnet-test3",
+					Zone:                    "test-zone-1c",
+					ZoneID:                  "testzone1c",
+					AvailableIPAddressCount: 100,
 				},
 			))
 		})
 		It("Should have the correct ordering for the Subnets", func() {
 			awsEnv.EC2API.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
-				{SubnetId: aws.String("subnet-test1"), AvailabilityZone: aws.String("test-zone-1a"), AvailableIpAddressCount: aws.Int64(20)},
-				{SubnetId: aws.String("subnet-test2"), AvailabilityZone: aws.String("test-zone-1b"), AvailableIpAddressCount: aws.Int64(100)},
-				{SubnetId: aws.String("subnet-test3"), AvailabilityZone: aws.String("test-zone-1c"), AvailableIpAddressCount: aws.Int64(50)},
+				{SubnetId: aws.String("subnet-test1"), AvailabilityZone: aws.String("test-zone-1a"), AvailabilityZoneId: aws.String("testzone1a"), AvailableIpAddressCount: aws.Int64(20)},
+				{SubnetId: aws.String("subnet-test2"), AvailabilityZone: aws.String("test-zone-1b"), AvailabilityZoneId: aws.String("testzone1b"), AvailableIpAddressCount: aws.Int64(100)},
+				{SubnetId: aws.String("subnet-test3"), AvailabilityZone: aws.String("test-zone-1c"), AvailabilityZoneId: aws.String("testzone1c"), AvailableIpAddressCount: aws.Int64(50)},
 			}})
 			ExpectApplied(ctx, env.Client, nodeTemplate)
 			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
 			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
 			Expect(nodeTemplate.Status.Subnets).To(ConsistOf(
 				v1alpha1.Subnet{
-					ID:   "subnet-test2",
-					Zone: "test-zone-1b",
+					ID:                      "subnet-test2",
+					Zone:                    "test-zone-1b",
+					ZoneID:                  "testzone1b",
+					AvailableIPAddressCount: 100,
 				},
 				v1alpha1.Subnet{
-					ID:   "subnet-test3",
-					Zone: "test-zone-1c",
+					ID:                      "subnet-test3",
+					Zone:                    "test-zone-1c",
+					ZoneID:                  "testzone1c",
+					AvailableIPAddressCount: 50,
 				},
 				v1alpha1.Subnet{
-					ID:   "subnet-test1",
-					Zone: "test-zone-1a",
+					ID:                      "subnet-test1",
+					Zone:                    "test-zone-1a",
+					ZoneID:                  "testzone1a",
+					AvailableIPAddressCount: 20,
 				},
 			))
 		})
@@ -150,12 +168,16 @@ var _ = Describe("AWSNodeTemplateController", func() {
 			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
 			Expect(nodeTemplate.Status.Subnets).To(ConsistOf(
 				v1alpha1.Subnet{
-					ID:   "subnet-test1",
-					Zone: "test-zone-1a",
+					ID:                      "subnet-test1",
+					Zone:                    "test-zone-1a",
+					ZoneID:                  "testzone1a",
+					AvailableIPAddressCount: 100,
 				},
 				v1alpha1.Subnet{
-					ID:   "subnet-test2",
-					Zone: "test-zone-1b",
+					ID:                      "subnet-test2",
+					Zone:                    "test-zone-1b",
+					ZoneID:                  "testzone1b",
+					AvailableIPAddressCount: 100,
 				},
 			))
 		})
@@ -166,8 +188,10 @@ var _ = Describe("AWSNodeTemplateController", func() {
 			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
 			Expect(nodeTemplate.Status.Subnets).To(Equal([]v1alpha1.Subnet{
 				{
-					ID:   "subnet-test1",
-					Zone: "test-zone-1a",
+					ID:                      "subnet-test1",
+					Zone:                    "test-zone-1a",
+					ZoneID:                  "testzone1a",
+					AvailableIPAddressCount: 100,
 				},
 			}))
 		})
@@ -177,16 +201,22 @@ var _ = Describe("AWSNodeTemplateController", func() {
 			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
 			Expect(nodeTemplate.Status.Subnets).To(ConsistOf(
 				v1alpha1.Subnet{
-					ID:   "subnet-test1",
-					Zone: "test-zone-1a",
+					ID:                      "subnet-test1",
+					Zone:                    "test-zone-1a",
+					ZoneID:                  "testzone1a",
+					AvailableIPAddressCount: 100,
 				},
 				v1alpha1.Subnet{
-					ID:   "subnet-test2",
-					Zone: "test-zone-1b",
+					ID:                      "subnet-test2",
+					Zone:                    "test-zone-1b",
+					ZoneID:                  "testzone1b",
+					AvailableIPAddressCount: 100,
 				},
 				v1alpha1.Subnet{
-					ID:   "subnet-test3",
-					Zone: "test-zone-1c",
+					ID:                      "subnet-test3",
+					Zone:                    "test-zone-1c",
+					ZoneID:                  "testzone1c",
+					AvailableIPAddressCount: 100,
 				},
 			))
 
@@ -196,12 +226,16 @@ var _ = Describe("AWSNodeTemplateController", func() {
 			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
 			Expect(nodeTemplate.Status.Subnets).To(ConsistOf(
 				v1alpha1.Subnet{
-					ID:   "subnet-test1",
-					Zone: "test-zone-1a",
+					ID:                      "subnet-test1",
+					Zone:                    "test-zone-1a",
+					ZoneID:                  "testzone1a",
+					AvailableIPAddressCount: 100,
 				},
 				v1alpha1.Subnet{
-					ID:   "subnet-test2",
-					Zone: "test-zone-1b",
+					ID:                      "subnet-test2",
+					Zone:                    "test-zone-1b",
+					ZoneID:                  "testzone1b",
+					AvailableIPAddressCount: 100,
 				},
 			))
 		})
@@ -211,16 +245,22 @@ var _ = Describe("AWSNodeTemplateController", func() {
 			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
 			Expect(nodeTemplate.Status.Subnets).To(ConsistOf(
 				v1alpha1.Subnet{
-					ID:   "subnet-test1",
-					Zone: "test-zone-1a",
+					ID:                      "subnet-test1",
+					Zone:                    "test-zone-1a",
+					ZoneID:                  "testzone1a",
+					AvailableIPAddressCount: 100,
 				},
 				v1alpha1.Subnet{
-					ID:   "subnet-test2",
-					Zone: "test-zone-1b",
+					ID:                      "subnet-test2",
+					Zone:                    "test-zone-1b",
+					ZoneID:                  "testzone1b",
+					AvailableIPAddressCount: 100,
 				},
 				v1alpha1.Subnet{
-					ID:   "subnet-test3",
-					Zone: "test-zone-1c",
+					ID:                      "subnet-test3",
+					Zone:                    "test-zone-1c",
+					ZoneID:                  "testzone1c",
+					AvailableIPAddressCount: 100,
 				},
 			))
 
@@ -230,8 +270,10 @@ var _ = Describe("AWSNodeTemplateController", func() {
 			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
 			Expect(nodeTemplate.Status.Subnets).To(ConsistOf(
 				v1alpha1.Subnet{
-					ID:   "subnet-test1",
-					Zone: "test-zone-1a",
+					ID:                      "subnet-test1",
+					Zone:                    "test-zone-1a",
+					ZoneID:                  "testzone1a",
+					AvailableIPAddressCount: 100,
 				},
 			))
 		})
@@ -248,16 +290,22 @@ var _ = Describe("AWSNodeTemplateController", func() {
 			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
 			Expect(nodeTemplate.Status.Subnets).To(ConsistOf(
 				v1alpha1.Subnet{
-					ID:   "subnet-test1",
-					Zone: "test-zone-1a",
+					ID:                      "subnet-test1",
+					Zone:                    "test-zone-1a",
+					ZoneID:                  "testzone1a",
+					AvailableIPAddressCount: 100,
 				},
 				v1alpha1.Subnet{
-					ID:   "subnet-test2",
-					Zone: "test-zone-1b",
+					ID:                      "subnet-test2",
+					Zone:                    "test-zone-1b",
+					ZoneID:                  "testzone1b",
+					AvailableIPAddressCount: 100,
 				},
 				v1alpha1.Subnet{
-					ID:   "subnet-test3",
-					Zone: "test-zone-1c",
+					ID:                      "subnet-test3",
+					Zone:                    "test-zone-1c",
+					ZoneID:                  "testzone1c",
+					AvailableIPAddressCount: 100,
 				},
 			))
 
@@ -267,6 +315,39 @@ var _ = Describe("AWSNodeTemplateController", func() {
 			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
 			Expect(nodeTemplate.Status.Subnets).To(BeNil())
 		})
+		It("Should exclude public subnets when the subnet policy is privateOnly", func() {
+			awsEnv.EC2API.DescribeRouteTablesOutput.Set(&ec2.DescribeRouteTablesOutput{RouteTables: []*ec2.RouteTable{
+				{
+					RouteTableId: aws.String("rtb-test1"),
+					Associations: []*ec2.RouteTableAssociation{{SubnetId: aws.String("subnet-test3")}},
+					Routes:       []*ec2.Route{{DestinationCidrBlock: aws.String("0.0.0.0/0"), GatewayId: aws.String("igw-test1")}},
+				},
+			}})
+			nodeTemplate.Spec.SubnetPolicy = aws.String(v1alpha1.SubnetPolicyPrivateOnly)
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			Expect(nodeTemplate.Status.Subnets).To(ConsistOf(
+				v1alpha1.Subnet{
+					ID:                      "subnet-test1",
+					Zone:                    "test-zone-1a",
+					ZoneID:                  "testzone1a",
+					AvailableIPAddressCount: 100,
+				},
+			))
+			Expect(nodeclassutil.New(nodeTemplate).StatusConditions().GetCondition(v1beta1.NodeClassSubnetsReady).IsTrue()).To(BeTrue())
+		})
+		It("Should mark subnets as not ready when the subnet policy excludes every subnet", func() {
+			nodeTemplate.Spec.SubnetSelector = map[string]string{"aws-ids": "subnet-test2"}
+			nodeTemplate.Spec.SubnetPolicy = aws.String(v1alpha1.SubnetPolicyPrivateOnly)
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileFailed(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			Expect(nodeTemplate.Status.Subnets).To(BeNil())
+			condition := nodeclassutil.New(nodeTemplate).StatusConditions().GetCondition(v1beta1.NodeClassSubnetsReady)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Reason).To(Equal("PrivateSubnetsNotFound"))
+		})
 	})
 	Context("Security Groups Status", func() {
 		It("Should expect no errors when security groups are not in the AWSNodeTemplate", func() {
@@ -393,6 +474,42 @@ var _ = Describe("AWSNodeTemplateController", func() {
 			ExpectReconcileFailed(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
 			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
 			Expect(nodeTemplate.Status.SecurityGroups).To(BeNil())
+			condition := nodeclassutil.New(nodeTemplate).StatusConditions().GetCondition(v1beta1.NodeClassSecurityGroupsReady)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Reason).To(Equal("SecurityGroupsNotFound"))
+		})
+		It("Should mark security groups as ready when they are in the VPC of the subnets", func() {
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			Expect(nodeclassutil.New(nodeTemplate).StatusConditions().GetCondition(v1beta1.NodeClassSecurityGroupsReady).IsTrue()).To(BeTrue())
+		})
+		It("Should mark security groups as not ready when they are in a different VPC than the subnets", func() {
+			awsEnv.EC2API.DescribeSecurityGroupsOutput.Set(&ec2.DescribeSecurityGroupsOutput{SecurityGroups: []*ec2.SecurityGroup{
+				{GroupId: aws.String("sg-test1"), GroupName: aws.String("securityGroup-test1"), VpcId: aws.String("vpc-test1")},
+				{GroupId: aws.String("sg-other"), GroupName: aws.String("securityGroup-other"), VpcId: aws.String("vpc-other")},
+			}})
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileFailed(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			Expect(nodeTemplate.Status.SecurityGroups).To(HaveLen(2))
+			condition := nodeclassutil.New(nodeTemplate).StatusConditions().GetCondition(v1beta1.NodeClassSecurityGroupsReady)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Reason).To(Equal("SecurityGroupVPCMismatch"))
+			Expect(condition.Message).To(ContainSubstring("sg-other (vpc-other)"))
+			Expect(nodeclassutil.New(nodeTemplate).StatusConditions().IsHappy()).To(BeFalse())
+		})
+		It("Should mark security groups as not ready when there are more than fit on a network interface", func() {
+			awsEnv.EC2API.DescribeSecurityGroupsOutput.Set(&ec2.DescribeSecurityGroupsOutput{SecurityGroups: lo.Times(6, func(i int) *ec2.SecurityGroup {
+				return &ec2.SecurityGroup{GroupId: aws.String(fmt.Sprintf("sg-test%d", i)), GroupName: aws.String(fmt.Sprintf("securityGroup-test%d", i))}
+			})})
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileFailed(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			condition := nodeclassutil.New(nodeTemplate).StatusConditions().GetCondition(v1beta1.NodeClassSecurityGroupsReady)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Reason).To(Equal("TooManySecurityGroups"))
+			Expect(condition.Message).To(ContainSubstring("limit of 5"))
 		})
 		It("Should not resolve a invalid selectors for an updated Security Groups selector", func() {
 			ExpectApplied(ctx, env.Client, nodeTemplate)
@@ -719,6 +836,179 @@ var _ = Describe("AWSNodeTemplateController", func() {
 			}, nodeTemplate.Status.AMIs)
 		})
 	})
+	Context("Instance Profile Status", func() {
+		It("Should update AWSNodeTemplate status for the instance profile", func() {
+			nodeTemplate.Spec.InstanceProfile = aws.String("test-profile")
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			Expect(nodeTemplate.Status.InstanceProfile).To(Equal("test-profile"))
+		})
+		It("Should fall back to the default instance profile", func() {
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			Expect(nodeTemplate.Status.InstanceProfile).To(Equal(settings.FromContext(ctx).DefaultInstanceProfile))
+		})
+		It("Should not set the instance profile when a launch template is used", func() {
+			nodeTemplate.Spec.SecurityGroupSelector = nil
+			nodeTemplate.Spec.LaunchTemplateName = aws.String("test-launch-template")
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			Expect(nodeTemplate.Status.InstanceProfile).To(BeEmpty())
+		})
+		Context("Role", func() {
+			var nodeClass *v1beta1.NodeClass
+			BeforeEach(func() {
+				nodeClass = test.NodeClass(v1beta1.NodeClass{Spec: v1beta1.NodeClassSpec{Role: aws.String("test-role")}})
+			})
+			It("should create an instance profile with the role", func() {
+				ExpectApplied(ctx, env.Client, nodeClass)
+				ExpectReconcileSucceeded(ctx, nodeClassController, client.ObjectKeyFromObject(nodeClass))
+				nodeClass = ExpectExists(ctx, env.Client, nodeClass)
+				Expect(nodeClass.Status.InstanceProfile).To(Equal(instanceprofile.GetProfileName(ctx, "", nodeClass)))
+				out, err := awsEnv.IAMAPI.GetInstanceProfileWithContext(ctx, &iam.GetInstanceProfileInput{InstanceProfileName: aws.String(nodeClass.Status.InstanceProfile)})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(out.InstanceProfile.Roles).To(HaveLen(1))
+				Expect(aws.StringValue(out.InstanceProfile.Roles[0].RoleName)).To(Equal("test-role"))
+			})
+			It("should replace the role of the instance profile when the role changes", func() {
+				ExpectApplied(ctx, env.Client, nodeClass)
+				ExpectReconcileSucceeded(ctx, nodeClassController, client.ObjectKeyFromObject(nodeClass))
+				nodeClass = ExpectExists(ctx, env.Client, nodeClass)
+				hash := nodeClass.Hash()
+
+				nodeClass.Spec.Role = aws.String("other-role")
+				ExpectApplied(ctx, env.Client, nodeClass)
+				ExpectReconcileSucceeded(ctx, nodeClassController, client.ObjectKeyFromObject(nodeClass))
+				nodeClass = ExpectExists(ctx, env.Client, nodeClass)
+				Expect(nodeClass.Status.InstanceProfile).To(Equal(instanceprofile.GetProfileName(ctx, "", nodeClass)))
+				out, err := awsEnv.IAMAPI.GetInstanceProfileWithContext(ctx, &iam.GetInstanceProfileInput{InstanceProfileName: aws.String(nodeClass.Status.InstanceProfile)})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(out.InstanceProfile.Roles).To(HaveLen(1))
+				Expect(aws.StringValue(out.InstanceProfile.Roles[0].RoleName)).To(Equal("other-role"))
+				// Nodes launched with the previous role are drifted by the change to the NodeClass hash
+				Expect(nodeClass.Annotations[v1beta1.AnnotationNodeClassHash]).ToNot(Equal(hash))
+			})
+			It("should delete the instance profile when the NodeClass is deleted", func() {
+				ExpectApplied(ctx, env.Client, nodeClass)
+				ExpectReconcileSucceeded(ctx, nodeClassController, client.ObjectKeyFromObject(nodeClass))
+				name := instanceprofile.GetProfileName(ctx, "", nodeClass)
+				Expect(env.Client.Delete(ctx, nodeClass)).To(Succeed())
+				ExpectReconcileSucceeded(ctx, nodeClassController, client.ObjectKeyFromObject(nodeClass))
+				ExpectNotFound(ctx, env.Client, nodeClass)
+				_, err := awsEnv.IAMAPI.GetInstanceProfileWithContext(ctx, &iam.GetInstanceProfileInput{InstanceProfileName: aws.String(name)})
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+	Context("Validation", func() {
+		It("should mark validation as succeeded when the dry run launch succeeds", func() {
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			nodeClass := nodeclassutil.New(nodeTemplate)
+			Expect(nodeClass.StatusConditions().GetCondition(v1beta1.NodeClassValidationSucceeded).IsTrue()).To(BeTrue())
+			Expect(nodeClass.StatusConditions().IsHappy()).To(BeTrue())
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(0))
+			Expect(awsEnv.EC2API.RunInstancesBehavior.CalledWithInput.Len()).To(BeNumerically(">", 0))
+			runInstancesInput := awsEnv.EC2API.RunInstancesBehavior.CalledWithInput.Pop()
+			Expect(aws.BoolValue(runInstancesInput.DryRun)).To(BeTrue())
+			Expect(aws.StringValue(runInstancesInput.IamInstanceProfile.Name)).ToNot(BeEmpty())
+			Expect(aws.StringValue(runInstancesInput.NetworkInterfaces[0].SubnetId)).To(Equal(nodeClass.Status.Subnets[0].ID))
+		})
+		It("should mark validation as failed when the dry run launch isn't authorized", func() {
+			awsEnv.EC2API.RunInstancesBehavior.Error.Set(awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation.", nil))
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileFailed(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			condition := nodeclassutil.New(nodeTemplate).StatusConditions().GetCondition(v1beta1.NodeClassValidationSucceeded)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Message).To(ContainSubstring("dry run launching"))
+			Expect(condition.Message).To(ContainSubstring("UnauthorizedOperation"))
+		})
+		It("should mark validation as failed with the dry run error", func() {
+			awsEnv.EC2API.NextDryRunError.Set(awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation.", nil))
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileFailed(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			condition := nodeclassutil.New(nodeTemplate).StatusConditions().GetCondition(v1beta1.NodeClassValidationSucceeded)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Reason).To(Equal("DryRunFailed"))
+			Expect(condition.Message).To(ContainSubstring("UnauthorizedOperation"))
+		})
+		It("should skip validation when a custom launch template is used", func() {
+			nodeTemplate.Spec.SecurityGroupSelector = nil
+			nodeTemplate.Spec.LaunchTemplateName = aws.String("test-launch-template")
+			awsEnv.EC2API.NextDryRunError.Set(awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation.", nil))
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			Expect(nodeclassutil.New(nodeTemplate).StatusConditions().GetCondition(v1beta1.NodeClassValidationSucceeded).IsTrue()).To(BeTrue())
+		})
+	})
+	Context("Termination", func() {
+		var machine *v1alpha5.Machine
+		BeforeEach(func() {
+			machine = coretest.Machine(v1alpha5.Machine{
+				Spec: v1alpha5.MachineSpec{
+					MachineTemplateRef: &v1alpha5.MachineTemplateRef{
+						Name: nodeTemplate.Name,
+					},
+				},
+			})
+		})
+		It("should add the termination finalizer", func() {
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			Expect(nodeTemplate.Finalizers).To(ContainElement(v1alpha1.TerminationFinalizer))
+		})
+		It("should remove the termination finalizer when no machines reference the AWSNodeTemplate", func() {
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(env.Client.Delete(ctx, nodeTemplate)).To(Succeed())
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			ExpectNotFound(ctx, env.Client, nodeTemplate)
+		})
+		It("should block deletion while machines reference the AWSNodeTemplate", func() {
+			ExpectApplied(ctx, env.Client, nodeTemplate, machine)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(env.Client.Delete(ctx, nodeTemplate)).To(Succeed())
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+
+			nodeTemplate = ExpectExists(ctx, env.Client, nodeTemplate)
+			Expect(nodeTemplate.Finalizers).To(ContainElement(v1alpha1.TerminationFinalizer))
+			condition := nodeclassutil.New(nodeTemplate).StatusConditions().GetCondition(v1beta1.NodeClassNodeClaimsTerminated)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Message).To(ContainSubstring(machine.Name))
+			ExpectExists(ctx, env.Client, machine)
+
+			ExpectDeleted(ctx, env.Client, machine)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			ExpectNotFound(ctx, env.Client, nodeTemplate)
+		})
+		It("should ignore machines that reference a different AWSNodeTemplate", func() {
+			machine.Spec.MachineTemplateRef.Name = "other-nodetemplate"
+			ExpectApplied(ctx, env.Client, nodeTemplate, machine)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(env.Client.Delete(ctx, nodeTemplate)).To(Succeed())
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			ExpectNotFound(ctx, env.Client, nodeTemplate)
+		})
+		It("should delete machines that reference the AWSNodeTemplate with a cascade deletion policy", func() {
+			nodeTemplate.Spec.DeletionPolicy = aws.String(v1alpha1.DeletionPolicyCascade)
+			ExpectApplied(ctx, env.Client, nodeTemplate, machine)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(env.Client.Delete(ctx, nodeTemplate)).To(Succeed())
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			ExpectNotFound(ctx, env.Client, machine)
+
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			ExpectNotFound(ctx, env.Client, nodeTemplate)
+		})
+	})
 	Context("AWSNodeTemplate Static Drift Hash", func() {
 		DescribeTable("should update the static drift hash when nodeTemplate static field is updated", func(awsnodetemplatespec v1alpha1.AWSNodeTemplateSpec) {
 			updatedAWSNodeTemplate := test.AWSNodeTemplate(*nodeTemplate.Spec.DeepCopy(), awsnodetemplatespec)
@@ -0,0 +1,306 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package noderole
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/aws/aws-sdk-go/service/eks/eksiface"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/logging"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	awscache "github.com/aws/karpenter/pkg/cache"
+	awserrors "github.com/aws/karpenter/pkg/errors"
+	"github.com/aws/karpenter/pkg/utils"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+)
+
+const (
+	AWSAuthNamespace = "kube-system"
+	AWSAuthName      = "aws-auth"
+
+	accessEntryTypeLinux   = "EC2_LINUX"
+	accessEntryTypeWindows = "EC2_WINDOWS"
+	// windowsGroup is the aws-auth group that Windows nodes need in addition to the node groups
+	windowsGroup = "eks:kube-proxy-windows"
+	// registeredTTL is how long a role is assumed to stay registered before it's checked again, so that a mapping
+	// that's removed out of band is restored
+	registeredTTL = time.Hour
+)
+
+// Controller registers the node role of each NodeClass with the cluster so that the nodes that are launched with it
+// are authorized to join without the role being mapped by hand. The role is registered as an EKS access entry when the
+// cluster's authentication mode allows them, and is added to the aws-auth ConfigMap otherwise. Roles are never
+// deregistered, since nodes that aren't managed by Karpenter may share them.
+type Controller struct {
+	kubeClient client.Client
+	kubeReader client.Reader
+	eksapi     eksiface.EKSAPI
+	iamapi     iamiface.IAMAPI
+	// roles holds the ARN of the role that the nodes of each NodeClass use, keyed by the NodeClass and the role or
+	// instance profile that it's resolved from, so that IAM isn't called on every reconcile
+	roles *cache.Cache
+	// registered holds the ARNs of the roles that have been registered, keyed with the OS of the nodes that use them
+	// since Linux and Windows nodes may share a role
+	registered *cache.Cache
+}
+
+func NewController(kubeClient client.Client, eksapi eksiface.EKSAPI, iamapi iamiface.IAMAPI) *Controller {
+	return &Controller{
+		kubeClient: kubeClient,
+		kubeReader: kubeClient,
+		eksapi:     eksapi,
+		iamapi:     iamapi,
+		roles:      cache.New(registeredTTL, awscache.DefaultCleanupInterval),
+		registered: cache.New(registeredTTL, awscache.DefaultCleanupInterval),
+	}
+}
+
+func (c *Controller) Reconcile(ctx context.Context, nodeClass *v1beta1.NodeClass) (reconcile.Result, error) {
+	if !nodeClass.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+	roleARN, err := c.roleARN(ctx, nodeClass)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	// The instance profile is resolved by the nodeclass controller, which updates the status that this is watching
+	if roleARN == "" {
+		return reconcile.Result{}, nil
+	}
+	windows := lo.Contains([]string{v1beta1.AMIFamilyWindows2019, v1beta1.AMIFamilyWindows2022}, lo.FromPtr(nodeClass.Spec.AMIFamily))
+	registeredKey := fmt.Sprintf("%s/%s", roleARN, lo.Ternary(windows, accessEntryTypeWindows, accessEntryTypeLinux))
+	if _, ok := c.registered.Get(registeredKey); ok {
+		return reconcile.Result{RequeueAfter: 5 * time.Minute}, nil
+	}
+	out, err := c.eksapi.DescribeClusterWithContext(ctx, &eks.DescribeClusterInput{Name: aws.String(settings.FromContext(ctx).ClusterName)})
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("describing cluster, %w", err)
+	}
+	if accessEntriesEnabled(out.Cluster) {
+		err = c.ensureAccessEntry(ctx, roleARN, windows)
+	} else {
+		err = c.ensureRoleMapping(ctx, roleARN, windows)
+	}
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	c.registered.SetDefault(registeredKey, struct{}{})
+	return reconcile.Result{RequeueAfter: 5 * time.Minute}, nil
+}
+
+// roleARN returns the ARN of the role that the nodes of the NodeClass use, or "" if the NodeClass's instance profile
+// hasn't been resolved or comes from a custom launch template
+func (c *Controller) roleARN(ctx context.Context, nodeClass *v1beta1.NodeClass) (string, error) {
+	if nodeClass.Spec.Role == nil && nodeClass.Status.InstanceProfile == "" {
+		return "", nil
+	}
+	key := fmt.Sprintf("%s/%s/%s", nodeClass.UID, aws.StringValue(nodeClass.Spec.Role), nodeClass.Status.InstanceProfile)
+	if roleARN, ok := c.roles.Get(key); ok {
+		return roleARN.(string), nil
+	}
+	var roleARN string
+	if nodeClass.Spec.Role != nil {
+		out, err := c.iamapi.GetRoleWithContext(ctx, &iam.GetRoleInput{RoleName: nodeClass.Spec.Role})
+		if err != nil {
+			return "", fmt.Errorf("getting role %q, %w", aws.StringValue(nodeClass.Spec.Role), err)
+		}
+		roleARN = aws.StringValue(out.Role.Arn)
+	} else {
+		out, err := c.iamapi.GetInstanceProfileWithContext(ctx, &iam.GetInstanceProfileInput{InstanceProfileName: aws.String(nodeClass.Status.InstanceProfile)})
+		if err != nil {
+			return "", fmt.Errorf("getting instance profile %q, %w", nodeClass.Status.InstanceProfile, err)
+		}
+		if len(out.InstanceProfile.Roles) == 0 {
+			return "", fmt.Errorf("instance profile %q has no role", nodeClass.Status.InstanceProfile)
+		}
+		roleARN = aws.StringValue(out.InstanceProfile.Roles[0].Arn)
+	}
+	c.roles.SetDefault(key, roleARN)
+	return roleARN, nil
+}
+
+// accessEntriesEnabled returns true if the cluster authenticates with access entries. Clusters that predate access
+// entries don't report an authentication mode, and only authenticate with the aws-auth ConfigMap.
+func accessEntriesEnabled(cluster *eks.Cluster) bool {
+	if cluster == nil || cluster.AccessConfig == nil {
+		return false
+	}
+	return lo.Contains([]string{eks.AuthenticationModeApi, eks.AuthenticationModeApiAndConfigMap}, aws.StringValue(cluster.AccessConfig.AuthenticationMode))
+}
+
+func (c *Controller) ensureAccessEntry(ctx context.Context, roleARN string, windows bool) error {
+	clusterName := settings.FromContext(ctx).ClusterName
+	_, err := c.eksapi.DescribeAccessEntryWithContext(ctx, &eks.DescribeAccessEntryInput{
+		ClusterName:  aws.String(clusterName),
+		PrincipalArn: aws.String(roleARN),
+	})
+	if err == nil {
+		return nil
+	}
+	if !awserrors.IsNotFound(err) {
+		return fmt.Errorf("describing access entry for %q, %w", roleARN, err)
+	}
+	entryType := lo.Ternary(windows, accessEntryTypeWindows, accessEntryTypeLinux)
+	if _, err = c.eksapi.CreateAccessEntryWithContext(ctx, &eks.CreateAccessEntryInput{
+		ClusterName:  aws.String(clusterName),
+		PrincipalArn: aws.String(roleARN),
+		Type:         aws.String(entryType),
+		Tags:         aws.StringMap(lo.Assign(settings.FromContext(ctx).Tags, utils.ClusterTags(clusterName))),
+	}); err != nil {
+		return fmt.Errorf("creating access entry for %q, %w", roleARN, err)
+	}
+	logging.FromContext(ctx).With("role", roleARN, "type", entryType).Infof("created access entry")
+	return nil
+}
+
+// ensureRoleMapping adds the role to the mapRoles of the aws-auth ConfigMap, creating the ConfigMap if it doesn't exist.
+// The existing mappings are preserved as they are, except that the Windows group is added to the role's mapping when
+// Windows nodes use a role that's already mapped for Linux nodes.
+func (c *Controller) ensureRoleMapping(ctx context.Context, roleARN string, windows bool) error {
+	// aws-auth doesn't match role ARNs that include a path
+	mappedARN, err := withoutPath(roleARN)
+	if err != nil {
+		return err
+	}
+	configMap := &v1.ConfigMap{}
+	if err = c.kubeReader.Get(ctx, client.ObjectKey{Namespace: AWSAuthNamespace, Name: AWSAuthName}, configMap); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("getting aws-auth configmap, %w", err)
+	}
+	exists := err == nil
+	var mappings []map[string]interface{}
+	if err = yaml.Unmarshal([]byte(configMap.Data["mapRoles"]), &mappings); err != nil {
+		return fmt.Errorf("parsing mapRoles of aws-auth configmap, %w", err)
+	}
+	if mapping, ok := lo.Find(mappings, func(m map[string]interface{}) bool { return m["rolearn"] == mappedARN }); ok {
+		groups, _ := mapping["groups"].([]interface{})
+		if !windows || lo.Contains(groups, interface{}(windowsGroup)) {
+			return nil
+		}
+		mapping["groups"] = append([]interface{}{windowsGroup}, groups...)
+	} else {
+		mappings = append(mappings, map[string]interface{}{
+			"rolearn":  mappedARN,
+			"username": "system:node:{{EC2PrivateDNSName}}",
+			"groups": append(lo.Ternary(windows, []string{windowsGroup}, []string{}),
+				"system:bootstrappers", "system:nodes"),
+		})
+	}
+	mapRoles, err := yaml.Marshal(mappings)
+	if err != nil {
+		return fmt.Errorf("serializing mapRoles of aws-auth configmap, %w", err)
+	}
+	if !exists {
+		if err = c.kubeClient.Create(ctx, &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: AWSAuthNamespace, Name: AWSAuthName},
+			Data:       map[string]string{"mapRoles": string(mapRoles)},
+		}); err != nil {
+			return fmt.Errorf("creating aws-auth configmap, %w", err)
+		}
+	} else {
+		stored := configMap.DeepCopy()
+		configMap.Data = lo.Assign(configMap.Data, map[string]string{"mapRoles": string(mapRoles)})
+		// The optimistic lock keeps mappings that are added concurrently, e.g. by eksctl, from being overwritten
+		if err = c.kubeClient.Patch(ctx, configMap, client.MergeFromWithOptions(stored, client.MergeFromWithOptimisticLock{})); err != nil {
+			return fmt.Errorf("patching aws-auth configmap, %w", err)
+		}
+	}
+	logging.FromContext(ctx).With("role", mappedARN).Infof("added role to aws-auth configmap")
+	return nil
+}
+
+// withoutPath returns the ARN of the role without its path, e.g. arn:aws:iam::111122223333:role/path/to/name becomes
+// arn:aws:iam::111122223333:role/name
+func withoutPath(roleARN string) (string, error) {
+	parsed, err := arn.Parse(roleARN)
+	if err != nil {
+		return "", fmt.Errorf("parsing role arn, %w", err)
+	}
+	parts := strings.Split(parsed.Resource, "/")
+	parsed.Resource = fmt.Sprintf("role/%s", parts[len(parts)-1])
+	return parsed.String(), nil
+}
+
+// builder watches the NodeClasses without filtering on generation, since the instance profile that the role is
+// resolved from is written to their status
+func (c *Controller) builder(m manager.Manager, nodeClass client.Object) corecontroller.Builder {
+	// aws-auth is read directly rather than through the cache so that Karpenter isn't required to watch ConfigMaps
+	// across the cluster
+	c.kubeReader = m.GetAPIReader()
+	return corecontroller.Adapt(controllerruntime.
+		NewControllerManagedBy(m).
+		For(nodeClass))
+}
+
+//nolint:revive
+type NodeClassController struct {
+	*Controller
+}
+
+func NewNodeClassController(kubeClient client.Client, eksapi eksiface.EKSAPI, iamapi iamiface.IAMAPI) corecontroller.Controller {
+	return corecontroller.Typed[*v1beta1.NodeClass](kubeClient, &NodeClassController{
+		Controller: NewController(kubeClient, eksapi, iamapi),
+	})
+}
+
+func (c *NodeClassController) Name() string {
+	return "nodeclass.noderole"
+}
+
+func (c *NodeClassController) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return c.builder(m, &v1beta1.NodeClass{})
+}
+
+type NodeTemplateController struct {
+	*Controller
+}
+
+func NewNodeTemplateController(kubeClient client.Client, eksapi eksiface.EKSAPI, iamapi iamiface.IAMAPI) corecontroller.Controller {
+	return corecontroller.Typed[*v1alpha1.AWSNodeTemplate](kubeClient, &NodeTemplateController{
+		Controller: NewController(kubeClient, eksapi, iamapi),
+	})
+}
+
+func (c *NodeTemplateController) Reconcile(ctx context.Context, nodeTemplate *v1alpha1.AWSNodeTemplate) (reconcile.Result, error) {
+	return c.Controller.Reconcile(ctx, nodeclassutil.New(nodeTemplate))
+}
+
+func (c *NodeTemplateController) Name() string {
+	return "awsnodetemplate.noderole"
+}
+
+func (c *NodeTemplateController) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return c.builder(m, &v1alpha1.AWSNodeTemplate{})
+}
@@ -0,0 +1,230 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package noderole_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/aws/aws-sdk-go/service/iam"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	. "knative.dev/pkg/logging/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/controllers/noderole"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+const roleARN = "arn:aws:iam::111122223333:role/nodes/KarpenterNodeRole"
+
+var ctx context.Context
+var env *coretest.Environment
+var eksapi *fake.EKSAPI
+var iamapi *fake.IAMAPI
+var controller corecontroller.Controller
+var nodeTemplate *v1alpha1.AWSNodeTemplate
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "NodeRole")
+}
+
+var _ = BeforeSuite(func() {
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{EnableNodeRoleRegistration: aws.Bool(true)}))
+	eksapi = &fake.EKSAPI{}
+	iamapi = &fake.IAMAPI{}
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	eksapi.Reset()
+	iamapi.Reset()
+	// The controller remembers the roles that it has registered
+	controller = noderole.NewNodeTemplateController(env.Client, eksapi, iamapi)
+	iamapi.InstanceProfiles.Store("test-instance-profile", &iam.InstanceProfile{
+		InstanceProfileName: aws.String("test-instance-profile"),
+		Roles:               []*iam.Role{{RoleName: aws.String("KarpenterNodeRole"), Arn: aws.String(roleARN)}},
+	})
+	nodeTemplate = test.AWSNodeTemplate()
+	nodeTemplate.Status.InstanceProfile = "test-instance-profile"
+})
+
+var _ = AfterEach(func() {
+	Expect(client.IgnoreNotFound(env.Client.Delete(ctx, &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: noderole.AWSAuthNamespace, Name: noderole.AWSAuthName},
+	}))).To(Succeed())
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("NodeRole", func() {
+	It("should do nothing until the instance profile is resolved", func() {
+		nodeTemplate.Status.InstanceProfile = ""
+		ExpectApplied(ctx, env.Client, nodeTemplate)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+		Expect(eksapi.DescribeClusterBehaviour.Calls()).To(Equal(0))
+	})
+	It("should fail when the instance profile has no role", func() {
+		iamapi.InstanceProfiles.Store("test-instance-profile", &iam.InstanceProfile{InstanceProfileName: aws.String("test-instance-profile")})
+		ExpectApplied(ctx, env.Client, nodeTemplate)
+		ExpectReconcileFailed(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+	})
+	It("should not get the instance profile again once its role is resolved", func() {
+		ExpectApplied(ctx, env.Client, nodeTemplate)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+		iamapi.InstanceProfiles.Delete("test-instance-profile")
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+	})
+	Context("Access Entries", func() {
+		BeforeEach(func() {
+			eksapi.DescribeClusterBehaviour.Output.Set(&eks.DescribeClusterOutput{Cluster: &eks.Cluster{
+				AccessConfig: &eks.AccessConfigResponse{AuthenticationMode: aws.String(eks.AuthenticationModeApiAndConfigMap)},
+			}})
+		})
+		It("should create an access entry for the role of the instance profile", func() {
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(eksapi.CreateAccessEntryBehavior.CalledWithInput.Len()).To(Equal(1))
+			input := eksapi.CreateAccessEntryBehavior.CalledWithInput.Pop()
+			Expect(aws.StringValue(input.ClusterName)).To(Equal("test-cluster"))
+			Expect(aws.StringValue(input.PrincipalArn)).To(Equal(roleARN))
+			Expect(aws.StringValue(input.Type)).To(Equal("EC2_LINUX"))
+			Expect(input.Tags).To(HaveKey("kubernetes.io/cluster/test-cluster"))
+		})
+		It("should create a windows access entry for windows node templates", func() {
+			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyWindows2022
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			input := eksapi.CreateAccessEntryBehavior.CalledWithInput.Pop()
+			Expect(aws.StringValue(input.Type)).To(Equal("EC2_WINDOWS"))
+		})
+		It("should not create an access entry that already exists", func() {
+			eksapi.AccessEntries.Store(roleARN, &eks.AccessEntry{PrincipalArn: aws.String(roleARN), Type: aws.String("EC2_LINUX")})
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(eksapi.CreateAccessEntryBehavior.Calls()).To(Equal(0))
+		})
+		It("should not check the access entry again once the role is registered", func() {
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(eksapi.DescribeAccessEntryBehavior.Calls()).To(Equal(1))
+		})
+		It("should check the access entry again for windows node templates that share a registered role", func() {
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			windowsNodeTemplate := test.AWSNodeTemplate()
+			windowsNodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyWindows2022
+			windowsNodeTemplate.Status.InstanceProfile = "test-instance-profile"
+			ExpectApplied(ctx, env.Client, windowsNodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(windowsNodeTemplate))
+			Expect(eksapi.DescribeAccessEntryBehavior.Calls()).To(Equal(2))
+		})
+	})
+	Context("aws-auth", func() {
+		BeforeEach(func() {
+			eksapi.DescribeClusterBehaviour.Output.Set(&eks.DescribeClusterOutput{Cluster: &eks.Cluster{
+				AccessConfig: &eks.AccessConfigResponse{AuthenticationMode: aws.String(eks.AuthenticationModeConfigMap)},
+			}})
+		})
+		It("should create the configmap with a mapping for the role without its path", func() {
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			mappings := expectRoleMappings()
+			Expect(mappings).To(HaveLen(1))
+			Expect(mappings[0]["rolearn"]).To(Equal("arn:aws:iam::111122223333:role/KarpenterNodeRole"))
+			Expect(mappings[0]["username"]).To(Equal("system:node:{{EC2PrivateDNSName}}"))
+			Expect(mappings[0]["groups"]).To(ConsistOf("system:bootstrappers", "system:nodes"))
+			Expect(eksapi.CreateAccessEntryBehavior.Calls()).To(Equal(0))
+		})
+		It("should preserve the existing mappings", func() {
+			ExpectApplied(ctx, env.Client, &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: noderole.AWSAuthNamespace, Name: noderole.AWSAuthName},
+				Data: map[string]string{
+					"mapRoles": "- rolearn: arn:aws:iam::111122223333:role/Admin\n  username: admin\n  groups:\n  - system:masters\n",
+					"mapUsers": "[]",
+				},
+			})
+			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyWindows2022
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			mappings := expectRoleMappings()
+			Expect(mappings).To(HaveLen(2))
+			Expect(mappings[0]["rolearn"]).To(Equal("arn:aws:iam::111122223333:role/Admin"))
+			Expect(mappings[1]["groups"]).To(ConsistOf("eks:kube-proxy-windows", "system:bootstrappers", "system:nodes"))
+			configMap := &v1.ConfigMap{}
+			Expect(env.Client.Get(ctx, client.ObjectKey{Namespace: noderole.AWSAuthNamespace, Name: noderole.AWSAuthName}, configMap)).To(Succeed())
+			Expect(configMap.Data).To(HaveKeyWithValue("mapUsers", "[]"))
+		})
+		It("should not add a mapping for a role that's already mapped", func() {
+			ExpectApplied(ctx, env.Client, &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: noderole.AWSAuthNamespace, Name: noderole.AWSAuthName},
+				Data: map[string]string{
+					"mapRoles": "- rolearn: arn:aws:iam::111122223333:role/KarpenterNodeRole\n  username: system:node:{{EC2PrivateDNSName}}\n  groups:\n  - system:bootstrappers\n  - system:nodes\n",
+				},
+			})
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(expectRoleMappings()).To(HaveLen(1))
+		})
+		It("should add the windows group to a role that's already mapped for linux node templates", func() {
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			windowsNodeTemplate := test.AWSNodeTemplate()
+			windowsNodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyWindows2022
+			windowsNodeTemplate.Status.InstanceProfile = "test-instance-profile"
+			ExpectApplied(ctx, env.Client, windowsNodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(windowsNodeTemplate))
+			mappings := expectRoleMappings()
+			Expect(mappings).To(HaveLen(1))
+			Expect(mappings[0]["groups"]).To(ConsistOf("eks:kube-proxy-windows", "system:bootstrappers", "system:nodes"))
+		})
+		It("should use aws-auth when the cluster doesn't report an authentication mode", func() {
+			eksapi.DescribeClusterBehaviour.Output.Set(&eks.DescribeClusterOutput{Cluster: &eks.Cluster{}})
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(expectRoleMappings()).To(HaveLen(1))
+		})
+	})
+})
+
+func expectRoleMappings() []map[string]interface{} {
+	configMap := &v1.ConfigMap{}
+	ExpectWithOffset(1, env.Client.Get(ctx, client.ObjectKey{Namespace: noderole.AWSAuthNamespace, Name: noderole.AWSAuthName}, configMap)).To(Succeed())
+	var mappings []map[string]interface{}
+	ExpectWithOffset(1, yaml.Unmarshal([]byte(configMap.Data["mapRoles"]), &mappings)).To(Succeed())
+	return mappings
+}
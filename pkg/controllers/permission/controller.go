@@ -0,0 +1,267 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package permission
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/samber/lo"
+	"knative.dev/pkg/logging"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter-core/pkg/utils/pretty"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	awserrors "github.com/aws/karpenter/pkg/errors"
+)
+
+// requiredActions are the IAM actions that the controller role needs regardless of which features are enabled
+var requiredActions = []string{
+	"ec2:CreateFleet",
+	"ec2:CreateLaunchTemplate",
+	"ec2:CreateTags",
+	"ec2:DeleteLaunchTemplate",
+	"ec2:DescribeAvailabilityZones",
+	"ec2:DescribeImages",
+	"ec2:DescribeInstanceTypeOfferings",
+	"ec2:DescribeInstanceTypes",
+	"ec2:DescribeInstances",
+	"ec2:DescribeLaunchTemplates",
+	"ec2:DescribeRouteTables",
+	"ec2:DescribeSecurityGroups",
+	"ec2:DescribeSpotPriceHistory",
+	"ec2:DescribeSubnets",
+	"ec2:RunInstances",
+	"ec2:TerminateInstances",
+	"iam:PassRole",
+	"pricing:GetProducts",
+	"servicequotas:ListServiceQuotas",
+	"ssm:GetParameter",
+	"ssm:GetParameters",
+}
+
+// Controller periodically simulates the controller role's IAM policies against the actions that Karpenter calls and
+// reports the actions that aren't allowed, so that permission drift is discovered before a feature stops working
+type Controller struct {
+	kubeClient client.Client
+	stsapi     stsiface.STSAPI
+	iamapi     iamiface.IAMAPI
+	region     string
+	cm         *pretty.ChangeMonitor
+}
+
+func NewController(kubeClient client.Client, stsapi stsiface.STSAPI, iamapi iamiface.IAMAPI, region string) *Controller {
+	return &Controller{
+		kubeClient: kubeClient,
+		stsapi:     stsapi,
+		iamapi:     iamapi,
+		region:     region,
+		cm:         pretty.NewChangeMonitor(),
+	}
+}
+
+func (c *Controller) Name() string {
+	return "permission"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	actions, err := c.actions(ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	decisions, err := c.simulate(ctx, actions)
+	if err != nil {
+		// Simulating policies needs its own permission, which we don't want to require
+		if awserrors.IsUnauthorized(err) {
+			if c.cm.HasChanged("simulation-unauthorized", true) {
+				logging.FromContext(ctx).Infof("unable to report missing IAM permissions, allow iam:SimulatePrincipalPolicy on the controller role to enable it, %s", err)
+			}
+			PermissionMissing.Reset()
+			return reconcile.Result{RequeueAfter: time.Hour}, nil
+		}
+		return reconcile.Result{}, fmt.Errorf("simulating principal policy, %w", err)
+	}
+	c.cm.HasChanged("simulation-unauthorized", false)
+
+	var missing []string
+	for _, action := range actions {
+		if decisions[action] == iam.PolicyEvaluationDecisionTypeAllowed {
+			PermissionMissing.WithLabelValues(action).Set(0)
+			continue
+		}
+		PermissionMissing.WithLabelValues(action).Set(1)
+		missing = append(missing, action)
+	}
+	if c.cm.HasChanged("missing-actions", missing) && len(missing) > 0 {
+		logging.FromContext(ctx).With("actions", strings.Join(missing, ",")).
+			Errorf("controller role is not allowed to perform actions that Karpenter requires, add them to the controller policy, see https://karpenter.sh/docs/getting-started/")
+	}
+	return reconcile.Result{RequeueAfter: time.Hour}, nil
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.NewSingletonManagedBy(m)
+}
+
+// actions returns the actions that Karpenter calls given the features that are enabled in settings and NodeClasses
+func (c *Controller) actions(ctx context.Context) ([]string, error) {
+	actions := append([]string{}, requiredActions...)
+	if settings.FromContext(ctx).ClusterEndpoint == "" {
+		actions = append(actions, "eks:DescribeCluster")
+	}
+	if settings.FromContext(ctx).InterruptionQueueName != "" {
+		actions = append(actions, "sqs:DeleteMessage", "sqs:GetQueueAttributes", "sqs:GetQueueUrl", "sqs:ReceiveMessage")
+		if settings.FromContext(ctx).InterruptionDeadLetterQueueName != "" {
+			actions = append(actions, "sqs:SendMessage")
+		}
+	}
+	if settings.FromContext(ctx).EnableResourceGarbageCollection {
+		actions = append(actions, "ec2:DescribeNetworkInterfaces", "ec2:DescribeVolumes")
+		if !settings.FromContext(ctx).ResourceGarbageCollectionDryRun {
+			actions = append(actions, "ec2:DeleteNetworkInterface", "ec2:DeleteVolume")
+		}
+	}
+	if settings.FromContext(ctx).EnableStopBasedConsolidation {
+		actions = append(actions, "ec2:DeleteTags", "ec2:StartInstances", "ec2:StopInstances")
+	}
+	if settings.FromContext(ctx).PreTerminationTopicARN != "" {
+		actions = append(actions, "sns:Publish")
+	}
+	if settings.FromContext(ctx).EnableSpotPlacementScores {
+		actions = append(actions, "ec2:GetSpotPlacementScores")
+	}
+	if settings.FromContext(ctx).EnableInstanceStatusChecks {
+		actions = append(actions, "ec2:DescribeInstanceStatus")
+	}
+	if settings.FromContext(ctx).CloudWatchMetricsNamespace != "" {
+		actions = append(actions, "cloudwatch:PutMetricData")
+	}
+	if settings.FromContext(ctx).EnableNodeRoleRegistration {
+		actions = append(actions, "eks:CreateAccessEntry", "eks:DescribeAccessEntry", "eks:TagResource", "iam:GetInstanceProfile", "iam:GetRole")
+		// The cluster is described to discover its authentication mode even when its endpoint is configured
+		if settings.FromContext(ctx).ClusterEndpoint != "" {
+			actions = append(actions, "eks:DescribeCluster")
+		}
+	}
+	nodeClassList := &v1beta1.NodeClassList{}
+	if err := c.kubeClient.List(ctx, nodeClassList); err != nil {
+		return nil, fmt.Errorf("listing nodeclasses, %w", err)
+	}
+	// Instance profiles are managed for NodeClasses that specify a role rather than an instance profile
+	if lo.ContainsBy(nodeClassList.Items, func(nc v1beta1.NodeClass) bool { return nc.Spec.Role != nil }) {
+		actions = append(actions, "iam:AddRoleToInstanceProfile", "iam:CreateInstanceProfile", "iam:DeleteInstanceProfile",
+			"iam:GetInstanceProfile", "iam:RemoveRoleFromInstanceProfile", "iam:TagInstanceProfile")
+	}
+	nodeTemplateList := &v1alpha1.AWSNodeTemplateList{}
+	if err := c.kubeClient.List(ctx, nodeTemplateList); err != nil {
+		return nil, fmt.Errorf("listing awsnodetemplates, %w", err)
+	}
+	// The SSM agent registration of nodes is only checked for NodeClasses and AWSNodeTemplates that require the agent
+	if lo.ContainsBy(nodeClassList.Items, func(nc v1beta1.NodeClass) bool { return lo.FromPtr(nc.Spec.RequireSSMAgent) }) ||
+		lo.ContainsBy(nodeTemplateList.Items, func(nt v1alpha1.AWSNodeTemplate) bool { return lo.FromPtr(nt.Spec.RequireSSMAgent) }) {
+		actions = append(actions, "ssm:DescribeInstanceInformation")
+	}
+	sort.Strings(actions)
+	return lo.Uniq(actions), nil
+}
+
+// simulate returns the evaluation decision for each action as the controller role would make the request
+func (c *Controller) simulate(ctx context.Context, actions []string) (map[string]string, error) {
+	identity, err := c.stsapi.GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, fmt.Errorf("getting caller identity, %w", err)
+	}
+	principal, err := principalARN(aws.StringValue(identity.Arn))
+	if err != nil {
+		return nil, err
+	}
+	decisions := map[string]string{}
+	if err := c.iamapi.SimulatePrincipalPolicyPagesWithContext(ctx, &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(principal),
+		ActionNames:     aws.StringSlice(actions),
+		ContextEntries:  c.contextEntries(ctx),
+	}, func(out *iam.SimulatePolicyResponse, _ bool) bool {
+		for _, result := range out.EvaluationResults {
+			decisions[aws.StringValue(result.EvalActionName)] = aws.StringValue(result.EvalDecision)
+		}
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	return decisions, nil
+}
+
+// contextEntries describes the requests that Karpenter makes so that policies scoped with the conditions from the
+// recommended controller policy evaluate the same way that they do for real requests
+func (c *Controller) contextEntries(ctx context.Context) []*iam.ContextEntry {
+	clusterTag := fmt.Sprintf("kubernetes.io/cluster/%s", settings.FromContext(ctx).ClusterName)
+	entries := []*iam.ContextEntry{
+		stringContextEntry("aws:RequestedRegion", c.region),
+		stringContextEntry("iam:PassedToService", "ec2.amazonaws.com"),
+		stringContextEntry("ec2:CreateAction", "CreateFleet"),
+		stringContextEntry("aws:RequestTag/karpenter.sh/managed-by", settings.FromContext(ctx).ClusterName),
+	}
+	for _, key := range []string{"aws:RequestTag", "aws:ResourceTag"} {
+		entries = append(entries,
+			stringContextEntry(fmt.Sprintf("%s/%s", key, clusterTag), "owned"),
+			stringContextEntry(fmt.Sprintf("%s/%s", key, v1alpha5.ProvisionerNameLabelKey), "default"),
+			stringContextEntry(fmt.Sprintf("%s/%s", key, corev1beta1.NodePoolLabelKey), "default"),
+		)
+	}
+	return entries
+}
+
+func stringContextEntry(key, value string) *iam.ContextEntry {
+	return &iam.ContextEntry{
+		ContextKeyName:   aws.String(key),
+		ContextKeyType:   aws.String(iam.ContextKeyTypeEnumString),
+		ContextKeyValues: aws.StringSlice([]string{value}),
+	}
+}
+
+// principalARN converts the caller identity into an ARN that policies can be simulated for. Assumed role sessions,
+// e.g. from IRSA, are converted to the ARN of the role. Roles with a path aren't resolvable from the session ARN.
+func principalARN(callerARN string) (string, error) {
+	parsed, err := arn.Parse(callerARN)
+	if err != nil {
+		return "", fmt.Errorf("parsing caller identity arn, %w", err)
+	}
+	if parsed.Service != sts.ServiceName || !strings.HasPrefix(parsed.Resource, "assumed-role/") {
+		return callerARN, nil
+	}
+	parts := strings.Split(parsed.Resource, "/")
+	return arn.ARN{
+		Partition: parsed.Partition,
+		Service:   iam.ServiceName,
+		AccountID: parsed.AccountID,
+		Resource:  fmt.Sprintf("role/%s", parts[1]),
+	}.String(), nil
+}
@@ -0,0 +1,43 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package permission
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/aws/karpenter-core/pkg/metrics"
+)
+
+const (
+	awsSubsystem = "aws"
+	actionLabel  = "action"
+)
+
+var (
+	PermissionMissing = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: awsSubsystem,
+			Name:      "permission_missing",
+			Help:      "Whether the controller role is missing an IAM action that Karpenter requires, 1 if the action isn't allowed by a policy simulation. Labeled by action.",
+		},
+		[]string{actionLabel},
+	)
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(PermissionMissing)
+}
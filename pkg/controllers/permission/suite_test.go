@@ -0,0 +1,263 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package permission_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/types"
+	. "knative.dev/pkg/logging/testing"
+
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/controllers/permission"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var stsapi *fake.STSAPI
+var iamapi *fake.IAMAPI
+var controller *permission.Controller
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Permission")
+}
+
+var _ = BeforeSuite(func() {
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	ctx = settings.ToContext(ctx, test.Settings())
+	stsapi = &fake.STSAPI{}
+	iamapi = &fake.IAMAPI{}
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = BeforeEach(func() {
+	stsapi.Reset()
+	iamapi.Reset()
+	permission.PermissionMissing.Reset()
+	controller = permission.NewController(env.Client, stsapi, iamapi, "us-west-2")
+})
+
+var _ = Describe("Permission", func() {
+	It("should simulate the policies of the role that the caller assumed", func() {
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Len()).To(Equal(1))
+		input := iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValue(input.PolicySourceArn)).To(Equal("arn:aws:iam::123456789012:role/KarpenterControllerRole"))
+		Expect(aws.StringValueSlice(input.ActionNames)).To(ContainElements("ec2:CreateFleet", "ec2:RunInstances", "ec2:DescribeRouteTables", "iam:PassRole", "servicequotas:ListServiceQuotas"))
+	})
+	It("should simulate the policies of an IAM user directly", func() {
+		stsapi.GetCallerIdentityBehavior.Output.Set(&sts.GetCallerIdentityOutput{Arn: aws.String("arn:aws:iam::123456789012:user/karpenter")})
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input := iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValue(input.PolicySourceArn)).To(Equal("arn:aws:iam::123456789012:user/karpenter"))
+	})
+	It("should only simulate interruption queue actions when the interruption queue is configured", func() {
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input := iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).ToNot(ContainElement("sqs:ReceiveMessage"))
+
+		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{InterruptionQueueName: lo.ToPtr("test-queue")}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input = iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).To(ContainElement("sqs:ReceiveMessage"))
+		Expect(aws.StringValueSlice(input.ActionNames)).ToNot(ContainElement("sqs:SendMessage"))
+
+		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{InterruptionQueueName: lo.ToPtr("test-queue"), InterruptionDeadLetterQueueName: lo.ToPtr("test-dlq")}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input = iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).To(ContainElement("sqs:SendMessage"))
+		ctx = settings.ToContext(ctx, test.Settings())
+	})
+	It("should only simulate sns:Publish when the pre-termination topic is configured", func() {
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input := iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).ToNot(ContainElement("sns:Publish"))
+
+		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{PreTerminationTopicARN: lo.ToPtr("arn:aws:sns:us-west-2:111122223333:karpenter-termination")}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input = iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).To(ContainElement("sns:Publish"))
+		ctx = settings.ToContext(ctx, test.Settings())
+	})
+	It("should only simulate resource garbage collection actions when resource garbage collection is enabled", func() {
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input := iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).ToNot(ContainElement("ec2:DescribeVolumes"))
+
+		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{EnableResourceGarbageCollection: lo.ToPtr(true), ResourceGarbageCollectionDryRun: lo.ToPtr(true)}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input = iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).To(ContainElements("ec2:DescribeNetworkInterfaces", "ec2:DescribeVolumes"))
+		Expect(aws.StringValueSlice(input.ActionNames)).ToNot(ContainElement("ec2:DeleteVolume"))
+
+		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{EnableResourceGarbageCollection: lo.ToPtr(true)}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input = iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).To(ContainElements("ec2:DeleteNetworkInterface", "ec2:DeleteVolume"))
+		ctx = settings.ToContext(ctx, test.Settings())
+	})
+	It("should only simulate stop and start actions when stop-based consolidation is enabled", func() {
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input := iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).ToNot(ContainElement("ec2:StopInstances"))
+
+		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{EnableStopBasedConsolidation: lo.ToPtr(true)}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input = iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).To(ContainElements("ec2:DeleteTags", "ec2:StartInstances", "ec2:StopInstances"))
+		ctx = settings.ToContext(ctx, test.Settings())
+	})
+	It("should only simulate GetSpotPlacementScores when spot placement scores are enabled", func() {
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input := iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).ToNot(ContainElement("ec2:GetSpotPlacementScores"))
+
+		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{EnableSpotPlacementScores: lo.ToPtr(true)}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input = iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).To(ContainElement("ec2:GetSpotPlacementScores"))
+		ctx = settings.ToContext(ctx, test.Settings())
+	})
+	It("should only simulate DescribeInstanceStatus when instance status checks are enabled", func() {
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input := iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).ToNot(ContainElement("ec2:DescribeInstanceStatus"))
+
+		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{EnableInstanceStatusChecks: lo.ToPtr(true)}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input = iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).To(ContainElement("ec2:DescribeInstanceStatus"))
+		ctx = settings.ToContext(ctx, test.Settings())
+	})
+	It("should only simulate PutMetricData when a CloudWatch metrics namespace is configured", func() {
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input := iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).ToNot(ContainElement("cloudwatch:PutMetricData"))
+
+		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{CloudWatchMetricsNamespace: lo.ToPtr("Karpenter")}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input = iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).To(ContainElement("cloudwatch:PutMetricData"))
+		ctx = settings.ToContext(ctx, test.Settings())
+	})
+	It("should only simulate access entry actions when node role registration is enabled", func() {
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input := iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).ToNot(ContainElement("eks:CreateAccessEntry"))
+
+		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{EnableNodeRoleRegistration: lo.ToPtr(true)}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input = iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).To(ContainElements("eks:CreateAccessEntry", "eks:DescribeAccessEntry", "eks:DescribeCluster", "iam:GetInstanceProfile", "iam:GetRole"))
+		ctx = settings.ToContext(ctx, test.Settings())
+	})
+	It("should only simulate instance profile actions when a NodeClass specifies a role", func() {
+		nodeClass := test.NodeClass()
+		ExpectApplied(ctx, env.Client, nodeClass)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input := iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).ToNot(ContainElement("iam:CreateInstanceProfile"))
+
+		ExpectApplied(ctx, env.Client, test.NodeClass(v1beta1.NodeClass{Spec: v1beta1.NodeClassSpec{Role: lo.ToPtr("KarpenterNodeRole")}}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input = iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).To(ContainElements("iam:AddRoleToInstanceProfile", "iam:CreateInstanceProfile",
+			"iam:DeleteInstanceProfile", "iam:GetInstanceProfile", "iam:RemoveRoleFromInstanceProfile", "iam:TagInstanceProfile"))
+	})
+	It("should only simulate DescribeInstanceInformation when a NodeClass requires the SSM agent", func() {
+		ExpectApplied(ctx, env.Client, test.NodeClass())
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input := iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).ToNot(ContainElement("ssm:DescribeInstanceInformation"))
+
+		ExpectApplied(ctx, env.Client, test.NodeClass(v1beta1.NodeClass{Spec: v1beta1.NodeClassSpec{RequireSSMAgent: lo.ToPtr(true)}}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input = iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).To(ContainElement("ssm:DescribeInstanceInformation"))
+	})
+	It("should only simulate DescribeInstanceInformation when an AWSNodeTemplate requires the SSM agent", func() {
+		ExpectApplied(ctx, env.Client, test.AWSNodeTemplate())
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input := iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).ToNot(ContainElement("ssm:DescribeInstanceInformation"))
+
+		ExpectApplied(ctx, env.Client, test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{RequireSSMAgent: lo.ToPtr(true)}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		input = iamapi.SimulatePrincipalPolicyBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValueSlice(input.ActionNames)).To(ContainElement("ssm:DescribeInstanceInformation"))
+	})
+	It("should report actions that are allowed as not missing", func() {
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(permissionMissingValue("ec2:CreateFleet")).To(BeNumerically("==", 0))
+	})
+	It("should report actions that aren't allowed as missing", func() {
+		iamapi.SimulatePrincipalPolicyBehavior.Output.Set(&iam.SimulatePolicyResponse{
+			EvaluationResults: []*iam.EvaluationResult{
+				{EvalActionName: aws.String("ec2:CreateFleet"), EvalDecision: aws.String(iam.PolicyEvaluationDecisionTypeAllowed)},
+				{EvalActionName: aws.String("ec2:TerminateInstances"), EvalDecision: aws.String(iam.PolicyEvaluationDecisionTypeImplicitDeny)},
+			},
+		})
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(permissionMissingValue("ec2:CreateFleet")).To(BeNumerically("==", 0))
+		Expect(permissionMissingValue("ec2:TerminateInstances")).To(BeNumerically("==", 1))
+		// actions that the simulation didn't return a decision for are treated as missing
+		Expect(permissionMissingValue("ec2:RunInstances")).To(BeNumerically("==", 1))
+	})
+	It("should not fail when the controller role isn't allowed to simulate its policies", func() {
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(permissionMissingValue("ec2:CreateFleet")).To(BeNumerically("==", 0))
+
+		iamapi.SimulatePrincipalPolicyBehavior.Error.Set(awserr.New("AccessDenied", "not authorized to perform iam:SimulatePrincipalPolicy", nil))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		_, ok := FindMetricWithLabelValues("karpenter_aws_permission_missing", map[string]string{"action": "ec2:CreateFleet"})
+		Expect(ok).To(BeFalse())
+	})
+	It("should fail when the simulation fails for another reason", func() {
+		iamapi.SimulatePrincipalPolicyBehavior.Error.Set(awserr.New("ServiceFailure", "internal error", nil))
+		ExpectReconcileFailed(ctx, controller, types.NamespacedName{})
+	})
+})
+
+func permissionMissingValue(action string) float64 {
+	m, ok := FindMetricWithLabelValues("karpenter_aws_permission_missing", map[string]string{"action": action})
+	Expect(ok).To(BeTrue())
+	return m.GetGauge().GetValue()
+}
@@ -0,0 +1,108 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package savings
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter/pkg/providers/pricing"
+)
+
+// Controller periodically estimates how much running Karpenter capacity saves compared to paying the on-demand list
+// price for the same instances and exports the result per NodePool
+type Controller struct {
+	kubeClient      client.Client
+	pricingProvider *pricing.Provider
+}
+
+func NewController(kubeClient client.Client, pricingProvider *pricing.Provider) *Controller {
+	return &Controller{
+		kubeClient:      kubeClient,
+		pricingProvider: pricingProvider,
+	}
+}
+
+func (c *Controller) Name() string {
+	return "savings"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	nodeList := &v1.NodeList{}
+	if err := c.kubeClient.List(ctx, nodeList); err != nil {
+		return reconcile.Result{}, fmt.Errorf("listing nodes, %w", err)
+	}
+	onDemandPrices := map[string]float64{}
+	prices := map[string]float64{}
+	for i := range nodeList.Items {
+		nodePoolName, ok := nodePoolName(&nodeList.Items[i])
+		if !ok {
+			continue
+		}
+		if onDemandPrice, price, ok := c.prices(&nodeList.Items[i]); ok {
+			onDemandPrices[nodePoolName] += onDemandPrice
+			prices[nodePoolName] += price
+		}
+	}
+	// Reset so that NodePools without any running capacity stop being reported
+	SpotSavingsEstimate.Reset()
+	OnDemandEquivalentPriceEstimate.Reset()
+	PriceEstimate.Reset()
+	for nodePoolName, onDemandPrice := range onDemandPrices {
+		SpotSavingsEstimate.WithLabelValues(nodePoolName).Set(onDemandPrice - prices[nodePoolName])
+		OnDemandEquivalentPriceEstimate.WithLabelValues(nodePoolName).Set(onDemandPrice)
+		PriceEstimate.WithLabelValues(nodePoolName).Set(prices[nodePoolName])
+	}
+	return reconcile.Result{RequeueAfter: time.Minute}, nil
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.NewSingletonManagedBy(m)
+}
+
+// prices returns the on-demand list price of the node and the price that is actually paid for it. On-demand nodes
+// contribute no savings, but still count towards the NodePool being reported.
+func (c *Controller) prices(node *v1.Node) (float64, float64, bool) {
+	instanceType := node.Labels[v1.LabelInstanceTypeStable]
+	onDemandPrice, ok := c.pricingProvider.OnDemandPrice(instanceType)
+	if !ok {
+		return 0, 0, false
+	}
+	if node.Labels[v1beta1.CapacityTypeLabelKey] != v1beta1.CapacityTypeSpot {
+		return onDemandPrice, onDemandPrice, true
+	}
+	spotPrice, ok := c.pricingProvider.SpotPrice(instanceType, node.Labels[v1.LabelTopologyZone])
+	if !ok {
+		return 0, 0, false
+	}
+	return onDemandPrice, spotPrice, true
+}
+
+func nodePoolName(node *v1.Node) (string, bool) {
+	if name, ok := node.Labels[v1beta1.NodePoolLabelKey]; ok {
+		return name, true
+	}
+	name, ok := node.Labels[v1alpha5.ProvisionerNameLabelKey]
+	return name, ok
+}
@@ -0,0 +1,61 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package savings
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/aws/karpenter-core/pkg/metrics"
+)
+
+const (
+	cloudProviderSubsystem = "cloudprovider"
+	nodePoolLabel          = "nodepool"
+)
+
+var (
+	SpotSavingsEstimate = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "nodepool_savings_estimate",
+			Help:      "Estimated hourly savings of running capacity compared to the on-demand list price for the same instance types, labeled by nodepool.",
+		},
+		[]string{nodePoolLabel},
+	)
+	OnDemandEquivalentPriceEstimate = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "nodepool_on_demand_equivalent_price_estimate",
+			Help:      "Estimated hourly price of running capacity if every instance were paid the on-demand list price, labeled by nodepool.",
+		},
+		[]string{nodePoolLabel},
+	)
+	PriceEstimate = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "nodepool_price_estimate",
+			Help:      "Estimated hourly price of running capacity at the spot or on-demand price that is paid for each instance, labeled by nodepool.",
+		},
+		[]string{nodePoolLabel},
+	)
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(SpotSavingsEstimate, OnDemandEquivalentPriceEstimate, PriceEstimate)
+}
@@ -0,0 +1,160 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package savings_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	awspricing "github.com/aws/aws-sdk-go/service/pricing"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	. "knative.dev/pkg/logging/testing"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/controllers/savings"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/providers/pricing"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var awsEnv *test.Environment
+var controller *savings.Controller
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Savings")
+}
+
+var _ = BeforeSuite(func() {
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	awsEnv = test.NewEnvironment(ctx, env)
+	controller = savings.NewController(env.Client, awsEnv.PricingProvider)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	awsEnv.Reset()
+
+	now := time.Now()
+	awsEnv.EC2API.DescribeSpotPriceHistoryOutput.Set(&ec2.DescribeSpotPriceHistoryOutput{
+		SpotPriceHistory: []*ec2.SpotPrice{
+			{
+				AvailabilityZone: aws.String("test-zone-1a"),
+				InstanceType:     aws.String("c98.large"),
+				SpotPrice:        aws.String("0.25"),
+				Timestamp:        &now,
+			},
+			{
+				AvailabilityZone: aws.String("test-zone-1b"),
+				InstanceType:     aws.String("c98.large"),
+				SpotPrice:        aws.String("0.50"),
+				Timestamp:        &now,
+			},
+		},
+	})
+	awsEnv.PricingAPI.GetProductsOutput.Set(&awspricing.GetProductsOutput{
+		PriceList: []aws.JSONValue{
+			fake.NewOnDemandPrice("c98.large", 1.00),
+		},
+	})
+	ExpectReconcileSucceeded(ctx, pricing.NewController(awsEnv.PricingProvider), types.NamespacedName{})
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("Savings", func() {
+	It("should report the savings of spot nodes per nodepool", func() {
+		ExpectApplied(ctx, env.Client,
+			node(corev1beta1.NodePoolLabelKey, "default", corev1beta1.CapacityTypeSpot, "test-zone-1a"),
+			node(corev1beta1.NodePoolLabelKey, "default", corev1beta1.CapacityTypeSpot, "test-zone-1b"),
+			node(v1alpha5.ProvisionerNameLabelKey, "legacy", corev1beta1.CapacityTypeSpot, "test-zone-1a"),
+		)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(savingsMetricValue("default")).To(BeNumerically("~", 1.25))
+		Expect(savingsMetricValue("legacy")).To(BeNumerically("~", 0.75))
+	})
+	It("should report the on-demand equivalent and actual price of running capacity per nodepool", func() {
+		ExpectApplied(ctx, env.Client,
+			node(corev1beta1.NodePoolLabelKey, "default", corev1beta1.CapacityTypeSpot, "test-zone-1a"),
+			node(corev1beta1.NodePoolLabelKey, "default", corev1beta1.CapacityTypeSpot, "test-zone-1b"),
+			node(corev1beta1.NodePoolLabelKey, "default", corev1beta1.CapacityTypeOnDemand, "test-zone-1a"),
+		)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(metricValue("karpenter_cloudprovider_nodepool_on_demand_equivalent_price_estimate", "default")).To(BeNumerically("~", 3.00))
+		Expect(metricValue("karpenter_cloudprovider_nodepool_price_estimate", "default")).To(BeNumerically("~", 1.75))
+		Expect(savingsMetricValue("default")).To(BeNumerically("~", 1.25))
+	})
+	It("should report no savings for on-demand nodes", func() {
+		ExpectApplied(ctx, env.Client, node(corev1beta1.NodePoolLabelKey, "default", corev1beta1.CapacityTypeOnDemand, "test-zone-1a"))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(savingsMetricValue("default")).To(BeNumerically("==", 0))
+	})
+	It("should ignore nodes that aren't managed by karpenter", func() {
+		unmanaged := node(corev1beta1.NodePoolLabelKey, "default", corev1beta1.CapacityTypeSpot, "test-zone-1a")
+		delete(unmanaged.Labels, corev1beta1.NodePoolLabelKey)
+		ExpectApplied(ctx, env.Client, unmanaged)
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		_, ok := FindMetricWithLabelValues("karpenter_cloudprovider_nodepool_savings_estimate", map[string]string{"nodepool": "default"})
+		Expect(ok).To(BeFalse())
+	})
+})
+
+func node(nodePoolLabelKey, nodePoolName, capacityType, zone string) *v1.Node {
+	return coretest.Node(coretest.NodeOptions{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				nodePoolLabelKey:                 nodePoolName,
+				corev1beta1.CapacityTypeLabelKey: capacityType,
+				v1.LabelInstanceTypeStable:       "c98.large",
+				v1.LabelTopologyZone:             zone,
+			},
+		},
+	})
+}
+
+func savingsMetricValue(nodePoolName string) float64 {
+	return metricValue("karpenter_cloudprovider_nodepool_savings_estimate", nodePoolName)
+}
+
+func metricValue(name, nodePoolName string) float64 {
+	metric, ok := FindMetricWithLabelValues(name, map[string]string{"nodepool": nodePoolName})
+	Expect(ok).To(BeTrue())
+	return metric.GetGauge().GetValue()
+}
@@ -0,0 +1,127 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/system"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/aws/karpenter-core/pkg/events"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	awssettings "github.com/aws/karpenter/pkg/apis/settings"
+	awserrors "github.com/aws/karpenter/pkg/errors"
+)
+
+// Controller validates the karpenter-global-settings ConfigMap whenever it changes. Settings are only read when
+// Karpenter starts, so an invalid change would otherwise go unnoticed until the next restart fails with it.
+type Controller struct {
+	kubeReader client.Reader
+	recorder   events.Recorder
+	sqsapi     sqsiface.SQSAPI
+}
+
+func NewController(kubeClient client.Client, recorder events.Recorder, sqsapi sqsiface.SQSAPI) *Controller {
+	return &Controller{
+		kubeReader: kubeClient,
+		recorder:   recorder,
+		sqsapi:     sqsapi,
+	}
+}
+
+func (c *Controller) Name() string {
+	return "settings"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	cm := &v1.ConfigMap{}
+	if err := c.kubeReader.Get(ctx, req.NamespacedName, cm); err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+	reason, err := c.validate(ctx, cm)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if reason == "" {
+		SettingsInvalid.Set(0)
+		return reconcile.Result{}, nil
+	}
+	SettingsInvalid.Set(1)
+	c.recorder.Publish(SettingsInvalidEvent(cm, reason))
+	// The settings can become valid without changing, e.g. once the interruption queue is created
+	return reconcile.Result{RequeueAfter: 5 * time.Minute}, nil
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	// Karpenter is only permitted to read the ConfigMaps in its own namespace
+	nsCache := lo.Must(newNamespacedCache(m))
+	c.kubeReader = nsCache
+	return corecontroller.Adapt(controllerruntime.
+		NewControllerManagedBy(m).
+		Named(c.Name()).
+		Watches(source.NewKindWithCache(&v1.ConfigMap{}, nsCache), &handler.EnqueueRequestForObject{}, builder.WithPredicates(
+			predicate.NewPredicateFuncs(func(o client.Object) bool { return o.GetName() == (&awssettings.Settings{}).ConfigMap() }),
+			predicate.ResourceVersionChangedPredicate{},
+		)))
+}
+
+// validate returns the reason that the settings are invalid, or an error if they couldn't be validated
+func (c *Controller) validate(ctx context.Context, cm *v1.ConfigMap) (string, error) {
+	ctx, err := (&awssettings.Settings{}).Inject(ctx, cm)
+	if err != nil {
+		return err.Error(), nil
+	}
+	if queueName := awssettings.FromContext(ctx).InterruptionQueueName; queueName != "" {
+		if _, err := c.sqsapi.GetQueueUrlWithContext(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(queueName)}); err != nil {
+			if awserrors.IsNotFound(err) {
+				return fmt.Sprintf("interruption queue %q does not exist", queueName), nil
+			}
+			return "", fmt.Errorf("getting interruption queue url, %w", err)
+		}
+	}
+	if queueName := awssettings.FromContext(ctx).InterruptionDeadLetterQueueName; queueName != "" {
+		if _, err := c.sqsapi.GetQueueUrlWithContext(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(queueName)}); err != nil {
+			if awserrors.IsNotFound(err) {
+				return fmt.Sprintf("interruption dead-letter queue %q does not exist", queueName), nil
+			}
+			return "", fmt.Errorf("getting interruption dead-letter queue url, %w", err)
+		}
+	}
+	return "", nil
+}
+
+func newNamespacedCache(m manager.Manager) (cache.Cache, error) {
+	c, err := cache.New(m.GetConfig(), cache.Options{Scheme: m.GetScheme(), Mapper: m.GetRESTMapper(), Namespace: system.Namespace()})
+	if err != nil {
+		return nil, err
+	}
+	return c, m.Add(c)
+}
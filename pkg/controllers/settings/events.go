@@ -0,0 +1,33 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/aws/karpenter-core/pkg/events"
+)
+
+func SettingsInvalidEvent(cm *v1.ConfigMap, reason string) events.Event {
+	return events.Event{
+		InvolvedObject: cm,
+		Type:           v1.EventTypeWarning,
+		Reason:         "SettingsInvalid",
+		Message:        fmt.Sprintf("Settings are invalid and Karpenter will fail to start with them, %s", reason),
+		DedupeValues:   []string{string(cm.UID), cm.ResourceVersion, reason},
+	}
+}
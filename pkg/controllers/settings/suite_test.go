@@ -0,0 +1,143 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	. "knative.dev/pkg/logging/testing"
+	"knative.dev/pkg/system"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	awssettings "github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/controllers/settings"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var recorder *coretest.EventRecorder
+var sqsapi *fake.SQSAPI
+var controller *settings.Controller
+var cm *v1.ConfigMap
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Settings")
+}
+
+var _ = BeforeSuite(func() {
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = awssettings.ToContext(ctx, test.Settings())
+	recorder = coretest.NewEventRecorder()
+	sqsapi = &fake.SQSAPI{}
+	controller = settings.NewController(env.Client, recorder, sqsapi)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	recorder.Reset()
+	sqsapi.Reset()
+	cm = &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      (&awssettings.Settings{}).ConfigMap(),
+			Namespace: system.Namespace(),
+		},
+		Data: map[string]string{
+			"aws.clusterName": "my-cluster",
+		},
+	}
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("Settings", func() {
+	It("should not publish an event for valid settings", func() {
+		cm.Data["aws.tags"] = `{"team": "my-team"}`
+		ExpectApplied(ctx, env.Client, cm)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(cm))
+		Expect(recorder.Calls("SettingsInvalid")).To(Equal(0))
+		Expect(settingsInvalidMetricValue()).To(BeNumerically("==", 0))
+	})
+	It("should publish an event for malformed tags", func() {
+		cm.Data["aws.tags"] = `{"team": "my-team"`
+		ExpectApplied(ctx, env.Client, cm)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(cm))
+		Expect(recorder.Calls("SettingsInvalid")).To(Equal(1))
+		Expect(settingsInvalidMetricValue()).To(BeNumerically("==", 1))
+	})
+	It("should publish an event for an assumeRoleARN that isn't an IAM role", func() {
+		cm.Data["aws.assumeRoleARN"] = "my-role"
+		ExpectApplied(ctx, env.Client, cm)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(cm))
+		Expect(recorder.Calls("SettingsInvalid")).To(Equal(1))
+		Expect(settingsInvalidMetricValue()).To(BeNumerically("==", 1))
+	})
+	It("should publish an event for an interruption queue that doesn't exist", func() {
+		cm.Data["aws.interruptionQueueName"] = "my-queue"
+		sqsapi.GetQueueURLBehavior.Error.Set(awserr.New(sqs.ErrCodeQueueDoesNotExist, "The specified queue does not exist.", nil))
+		ExpectApplied(ctx, env.Client, cm)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(cm))
+		Expect(recorder.Calls("SettingsInvalid")).To(Equal(1))
+		Expect(settingsInvalidMetricValue()).To(BeNumerically("==", 1))
+
+		// The settings become valid once the queue is created
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(cm))
+		Expect(settingsInvalidMetricValue()).To(BeNumerically("==", 0))
+	})
+	It("should publish an event for an interruption dead-letter queue that doesn't exist", func() {
+		cm.Data["aws.interruptionDeadLetterQueueName"] = "my-dlq"
+		sqsapi.GetQueueURLBehavior.Error.Set(awserr.New(sqs.ErrCodeQueueDoesNotExist, "The specified queue does not exist.", nil))
+		ExpectApplied(ctx, env.Client, cm)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(cm))
+		Expect(recorder.Calls("SettingsInvalid")).To(Equal(1))
+		Expect(settingsInvalidMetricValue()).To(BeNumerically("==", 1))
+	})
+	It("should fail to reconcile if the interruption queue can't be looked up", func() {
+		cm.Data["aws.interruptionQueueName"] = "my-queue"
+		sqsapi.GetQueueURLBehavior.Error.Set(fmt.Errorf("failed"))
+		ExpectApplied(ctx, env.Client, cm)
+		ExpectReconcileFailed(ctx, controller, client.ObjectKeyFromObject(cm))
+		Expect(recorder.Calls("SettingsInvalid")).To(Equal(0))
+	})
+})
+
+func settingsInvalidMetricValue() float64 {
+	metric, ok := FindMetricWithLabelValues("karpenter_aws_settings_invalid", map[string]string{})
+	Expect(ok).To(BeTrue())
+	return metric.GetGauge().GetValue()
+}
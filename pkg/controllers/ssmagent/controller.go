@@ -0,0 +1,125 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssmagent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/logging"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/utils"
+)
+
+const pollingPeriod = 15 * time.Second
+
+// Controller removes the SSM agent startup taint from nodes once their instance is registered with Systems Manager.
+// Nodes that are launched with a NodeClass that requires the SSM agent aren't initialized until the taint is removed,
+// so they never receive pods if the agent can't register, e.g. because the instance profile lacks the SSM permissions.
+type Controller struct {
+	kubeClient client.Client
+	ssmapi     ssmiface.SSMAPI
+}
+
+func NewController(kubeClient client.Client, ssmapi ssmiface.SSMAPI) corecontroller.Controller {
+	return corecontroller.Typed[*v1.Node](kubeClient, &Controller{
+		kubeClient: kubeClient,
+		ssmapi:     ssmapi,
+	})
+}
+
+func (c *Controller) Name() string {
+	return "node.ssmagent"
+}
+
+func (c *Controller) Reconcile(ctx context.Context, node *v1.Node) (reconcile.Result, error) {
+	if !HasSSMAgentTaint(node) || !node.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+	// The provider ID is set by the cloud controller manager shortly after the node registers
+	if node.Spec.ProviderID == "" {
+		return reconcile.Result{RequeueAfter: pollingPeriod}, nil
+	}
+	instanceID, err := utils.ParseInstanceID(node.Spec.ProviderID)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("parsing instance id, %w", err)
+	}
+	registered, err := c.registered(ctx, instanceID)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if !registered {
+		return reconcile.Result{RequeueAfter: pollingPeriod}, nil
+	}
+	stored := node.DeepCopy()
+	node.Spec.Taints = lo.Reject(node.Spec.Taints, func(taint v1.Taint, _ int) bool {
+		return taint.Key == v1beta1.SSMAgentNotRegisteredTaintKey
+	})
+	if err := c.kubeClient.Patch(ctx, node, client.MergeFrom(stored)); err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(fmt.Errorf("removing ssm agent taint, %w", err))
+	}
+	logging.FromContext(ctx).With("instance-id", instanceID).Debugf("ssm agent registered, removed taint %s", v1beta1.SSMAgentNotRegisteredTaintKey)
+	return reconcile.Result{}, nil
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.Adapt(controllerruntime.
+		NewControllerManagedBy(m).
+		Named(c.Name()).
+		For(&v1.Node{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(o client.Object) bool {
+			return HasSSMAgentTaint(o.(*v1.Node))
+		}))).
+		WithOptions(controller.Options{MaxConcurrentReconciles: 10}))
+}
+
+// registered returns true if the SSM agent of the instance is registered with Systems Manager and online
+func (c *Controller) registered(ctx context.Context, instanceID string) (bool, error) {
+	out, err := c.ssmapi.DescribeInstanceInformationWithContext(ctx, &ssm.DescribeInstanceInformationInput{
+		Filters: []*ssm.InstanceInformationStringFilter{
+			{
+				Key:    aws.String("InstanceIds"),
+				Values: aws.StringSlice([]string{instanceID}),
+			},
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("describing ssm instance information, %w", err)
+	}
+	return lo.ContainsBy(out.InstanceInformationList, func(info *ssm.InstanceInformation) bool {
+		return aws.StringValue(info.InstanceId) == instanceID && aws.StringValue(info.PingStatus) == ssm.PingStatusOnline
+	}), nil
+}
+
+// HasSSMAgentTaint returns true if the node still has the startup taint of a NodeClass that requires the SSM agent
+func HasSSMAgentTaint(node *v1.Node) bool {
+	return lo.ContainsBy(node.Spec.Taints, func(taint v1.Taint) bool {
+		return taint.Key == v1beta1.SSMAgentNotRegisteredTaintKey
+	})
+}
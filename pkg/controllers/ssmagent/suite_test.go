@@ -0,0 +1,142 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssmagent_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	. "knative.dev/pkg/logging/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/controllers/ssmagent"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var ssmapi *fake.SSMAPI
+var controller corecontroller.Controller
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "SSMAgent")
+}
+
+var _ = BeforeSuite(func() {
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	ssmapi = &fake.SSMAPI{}
+	controller = ssmagent.NewController(env.Client, ssmapi)
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	ssmapi.Reset()
+})
+
+var _ = AfterEach(func() {
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("SSMAgent", func() {
+	var node *v1.Node
+	var instanceID string
+	BeforeEach(func() {
+		instanceID = fake.InstanceID()
+		node = coretest.Node(coretest.NodeOptions{
+			ProviderID: fake.ProviderID(instanceID),
+			Taints: []v1.Taint{
+				{Key: v1beta1.SSMAgentNotRegisteredTaintKey, Effect: v1.TaintEffectNoSchedule},
+				{Key: "example.com/taint", Effect: v1.TaintEffectNoSchedule},
+			},
+		})
+	})
+	It("should remove the taint once the SSM agent is registered and online", func() {
+		ssmapi.DescribeInstanceInformationBehavior.Output.Set(&ssm.DescribeInstanceInformationOutput{
+			InstanceInformationList: []*ssm.InstanceInformation{
+				{InstanceId: aws.String(instanceID), PingStatus: aws.String(ssm.PingStatusOnline)},
+			},
+		})
+		ExpectApplied(ctx, env.Client, node)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(node))
+		node = ExpectExists(ctx, env.Client, node)
+		Expect(node.Spec.Taints).To(ConsistOf(v1.Taint{Key: "example.com/taint", Effect: v1.TaintEffectNoSchedule}))
+
+		input := ssmapi.DescribeInstanceInformationBehavior.CalledWithInput.Pop()
+		Expect(input.Filters).To(HaveLen(1))
+		Expect(aws.StringValue(input.Filters[0].Key)).To(Equal("InstanceIds"))
+		Expect(aws.StringValueSlice(input.Filters[0].Values)).To(ConsistOf(instanceID))
+	})
+	It("should keep the taint while the SSM agent isn't registered", func() {
+		ExpectApplied(ctx, env.Client, node)
+		result := ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(node))
+		Expect(result.RequeueAfter).ToNot(BeZero())
+		node = ExpectExists(ctx, env.Client, node)
+		Expect(ssmagent.HasSSMAgentTaint(node)).To(BeTrue())
+	})
+	It("should keep the taint while the SSM agent isn't online", func() {
+		ssmapi.DescribeInstanceInformationBehavior.Output.Set(&ssm.DescribeInstanceInformationOutput{
+			InstanceInformationList: []*ssm.InstanceInformation{
+				{InstanceId: aws.String(instanceID), PingStatus: aws.String(ssm.PingStatusConnectionLost)},
+			},
+		})
+		ExpectApplied(ctx, env.Client, node)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(node))
+		node = ExpectExists(ctx, env.Client, node)
+		Expect(ssmagent.HasSSMAgentTaint(node)).To(BeTrue())
+	})
+	It("should keep the taint when describing the instance information fails", func() {
+		ssmapi.DescribeInstanceInformationBehavior.Error.Set(fmt.Errorf("failed"))
+		ExpectApplied(ctx, env.Client, node)
+		ExpectReconcileFailed(ctx, controller, client.ObjectKeyFromObject(node))
+		node = ExpectExists(ctx, env.Client, node)
+		Expect(ssmagent.HasSSMAgentTaint(node)).To(BeTrue())
+	})
+	It("should requeue nodes that don't have a provider ID yet", func() {
+		node.Spec.ProviderID = ""
+		ExpectApplied(ctx, env.Client, node)
+		result := ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(node))
+		Expect(result.RequeueAfter).ToNot(BeZero())
+		Expect(ssmapi.DescribeInstanceInformationBehavior.Calls()).To(BeZero())
+	})
+	It("should ignore nodes without the taint", func() {
+		node.Spec.Taints = nil
+		ExpectApplied(ctx, env.Client, node)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(node))
+		Expect(ssmapi.DescribeInstanceInformationBehavior.Calls()).To(BeZero())
+	})
+})
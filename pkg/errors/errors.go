@@ -19,20 +19,37 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/samber/lo"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 const (
 	launchTemplateNotFoundCode = "InvalidLaunchTemplateName.NotFoundException"
+	dryRunOperationCode        = "DryRunOperation"
+)
+
+// The reasons that launches fail with, which tell failures that resolve once capacity or quota is available apart from
+// failures that need the configuration to be fixed
+const (
+	LaunchFailureUnauthorized          = "Unauthorized"
+	LaunchFailureInvalidLaunchTemplate = "InvalidLaunchTemplate"
+	LaunchFailureQuotaExceeded         = "QuotaExceeded"
+	LaunchFailureInsufficientCapacity  = "InsufficientCapacity"
 )
 
 var (
 	// This is not an exhaustive list, add to it as needed
 	notFoundErrorCodes = sets.NewString(
 		"InvalidInstanceID.NotFound",
+		"InvalidNetworkInterfaceID.NotFound",
+		"InvalidVolume.NotFound",
 		launchTemplateNotFoundCode,
 		sqs.ErrCodeQueueDoesNotExist,
+		eks.ErrCodeResourceNotFoundException,
+		iam.ErrCodeNoSuchEntityException,
 	)
 	// unfulfillableCapacityErrorCodes signify that capacity is temporarily unable to be launched
 	unfulfillableCapacityErrorCodes = sets.NewString(
@@ -43,8 +60,71 @@ var (
 		"Unsupported",
 		"InsufficientFreeAddressesInSubnet",
 	)
+	// unauthorizedErrorCodes signify that the credentials that Karpenter is using aren't permitted to make the request
+	unauthorizedErrorCodes = sets.NewString(
+		"UnauthorizedOperation",
+		"AuthFailure",
+		"AccessDenied",
+	)
+	// launchFailureErrorCodes are the CreateFleet error codes of each launch failure reason, in the order that they're
+	// reported in when a launch fails with more than one, so that the failures that need action are reported first
+	launchFailureErrorCodes = []lo.Tuple2[string, sets.String]{
+		{A: LaunchFailureUnauthorized, B: unauthorizedErrorCodes},
+		{A: LaunchFailureInvalidLaunchTemplate, B: sets.NewString(
+			"InvalidLaunchTemplateId.NotFound",
+			"InvalidLaunchTemplateId.Malformed",
+			"InvalidLaunchTemplateId.VersionNotFound",
+			"InvalidLaunchTemplateName.NotFoundException",
+			"InvalidLaunchTemplateName.MalformedException",
+		)},
+		{A: LaunchFailureQuotaExceeded, B: sets.NewString(
+			"MaxSpotInstanceCountExceeded",
+			"VcpuLimitExceeded",
+			"InstanceLimitExceeded",
+		)},
+		{A: LaunchFailureInsufficientCapacity, B: sets.NewString(
+			"InsufficientInstanceCapacity",
+			"UnfulfillableCapacity",
+			"InsufficientFreeAddressesInSubnet",
+		)},
+	}
 )
 
+// LaunchError is a failed launch with the reason that it's reported with
+type LaunchError struct {
+	Reason string
+	error
+}
+
+// NewLaunchError wraps err with the reason of the first of its error codes that's a known launch failure, or returns
+// err if none of them are. The codes are those of the errors in the CreateFleet output, and of the AWS error that err
+// wraps, if any.
+func NewLaunchError(err error, codes ...string) error {
+	var awsError awserr.Error
+	if errors.As(err, &awsError) {
+		codes = append(codes, awsError.Code())
+	}
+	for _, reason := range launchFailureErrorCodes {
+		if reason.B.HasAny(codes...) {
+			return &LaunchError{Reason: reason.A, error: err}
+		}
+	}
+	return err
+}
+
+func (e *LaunchError) Unwrap() error {
+	return e.error
+}
+
+// LaunchFailureReason returns the reason of the LaunchError that err wraps, or false if it doesn't wrap one
+func LaunchFailureReason(err error) (string, bool) {
+	var launchErr *LaunchError
+	if errors.As(err, &launchErr) {
+		return launchErr.Reason, true
+	}
+	return "", false
+}
+
 // IsNotFound returns true if the err is an AWS error (even if it's
 // wrapped) and is a known to mean "not found" (as opposed to a more
 // serious or unexpected error)
@@ -76,3 +156,29 @@ func IsLaunchTemplateNotFound(err error) bool {
 	}
 	return false
 }
+
+// IsDryRunSucceeded returns true if the err is the error that EC2 returns when a request with DryRun set would have
+// succeeded
+func IsDryRunSucceeded(err error) bool {
+	if err == nil {
+		return false
+	}
+	var awsError awserr.Error
+	if errors.As(err, &awsError) {
+		return awsError.Code() == dryRunOperationCode
+	}
+	return false
+}
+
+// IsUnauthorized returns true if the err is an AWS error (even if it's
+// wrapped) that signifies that the request was denied due to missing or broken credentials
+func IsUnauthorized(err error) bool {
+	if err == nil {
+		return false
+	}
+	var awsError awserr.Error
+	if errors.As(err, &awsError) {
+		return unauthorizedErrorCodes.Has(awsError.Code())
+	}
+	return false
+}
@@ -18,6 +18,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"path"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -50,21 +52,33 @@ type EC2Behavior struct {
 	DescribeLaunchTemplatesOutput       AtomicPtr[ec2.DescribeLaunchTemplatesOutput]
 	DescribeSubnetsOutput               AtomicPtr[ec2.DescribeSubnetsOutput]
 	DescribeSecurityGroupsOutput        AtomicPtr[ec2.DescribeSecurityGroupsOutput]
+	DescribeRouteTablesOutput           AtomicPtr[ec2.DescribeRouteTablesOutput]
 	DescribeInstanceTypesOutput         AtomicPtr[ec2.DescribeInstanceTypesOutput]
 	DescribeInstanceTypeOfferingsOutput AtomicPtr[ec2.DescribeInstanceTypeOfferingsOutput]
 	DescribeAvailabilityZonesOutput     AtomicPtr[ec2.DescribeAvailabilityZonesOutput]
 	DescribeSpotPriceHistoryInput       AtomicPtr[ec2.DescribeSpotPriceHistoryInput]
 	DescribeSpotPriceHistoryOutput      AtomicPtr[ec2.DescribeSpotPriceHistoryOutput]
+	DescribeSpotPriceHistoryError       AtomicError
+	SpotPriceHistory                    AtomicPtrSlice[ec2.SpotPrice]
 	CreateFleetBehavior                 MockedFunction[ec2.CreateFleetInput, ec2.CreateFleetOutput]
 	TerminateInstancesBehavior          MockedFunction[ec2.TerminateInstancesInput, ec2.TerminateInstancesOutput]
+	StopInstancesBehavior               MockedFunction[ec2.StopInstancesInput, ec2.StopInstancesOutput]
+	StartInstancesBehavior              MockedFunction[ec2.StartInstancesInput, ec2.StartInstancesOutput]
+	RunInstancesBehavior                MockedFunction[ec2.RunInstancesInput, ec2.Reservation]
+	GetSpotPlacementScoresBehavior      MockedFunction[ec2.GetSpotPlacementScoresInput, ec2.GetSpotPlacementScoresOutput]
 	DescribeInstancesBehavior           MockedFunction[ec2.DescribeInstancesInput, ec2.DescribeInstancesOutput]
 	CreateTagsBehavior                  MockedFunction[ec2.CreateTagsInput, ec2.CreateTagsOutput]
+	DescribeInstanceStatusBehavior      MockedFunction[ec2.DescribeInstanceStatusInput, ec2.DescribeInstanceStatusOutput]
 	CalledWithCreateLaunchTemplateInput AtomicPtrSlice[ec2.CreateLaunchTemplateInput]
 	CalledWithDescribeImagesInput       AtomicPtrSlice[ec2.DescribeImagesInput]
 	Instances                           sync.Map
 	LaunchTemplates                     sync.Map
+	NetworkInterfaces                   sync.Map
+	Volumes                             sync.Map
 	InsufficientCapacityPools           atomic.Slice[CapacityPool]
 	NextError                           AtomicError
+	NextDryRunError                     AtomicError
+	Faults                              Faults
 }
 
 type EC2API struct {
@@ -82,16 +96,24 @@ func (e *EC2API) Reset() {
 	e.DescribeLaunchTemplatesOutput.Reset()
 	e.DescribeSubnetsOutput.Reset()
 	e.DescribeSecurityGroupsOutput.Reset()
+	e.DescribeRouteTablesOutput.Reset()
 	e.DescribeInstanceTypesOutput.Reset()
 	e.DescribeInstanceTypeOfferingsOutput.Reset()
 	e.DescribeAvailabilityZonesOutput.Reset()
 	e.CreateFleetBehavior.Reset()
 	e.TerminateInstancesBehavior.Reset()
+	e.StopInstancesBehavior.Reset()
+	e.StartInstancesBehavior.Reset()
+	e.RunInstancesBehavior.Reset()
+	e.GetSpotPlacementScoresBehavior.Reset()
 	e.DescribeInstancesBehavior.Reset()
+	e.DescribeInstanceStatusBehavior.Reset()
 	e.CalledWithCreateLaunchTemplateInput.Reset()
 	e.CalledWithDescribeImagesInput.Reset()
 	e.DescribeSpotPriceHistoryInput.Reset()
 	e.DescribeSpotPriceHistoryOutput.Reset()
+	e.DescribeSpotPriceHistoryError.Reset()
+	e.SpotPriceHistory.Reset()
 	e.Instances.Range(func(k, v any) bool {
 		e.Instances.Delete(k)
 		return true
@@ -100,26 +122,57 @@ func (e *EC2API) Reset() {
 		e.LaunchTemplates.Delete(k)
 		return true
 	})
+	e.NetworkInterfaces.Range(func(k, v any) bool {
+		e.NetworkInterfaces.Delete(k)
+		return true
+	})
+	e.Volumes.Range(func(k, v any) bool {
+		e.Volumes.Delete(k)
+		return true
+	})
 	e.InsufficientCapacityPools.Reset()
 	e.NextError.Reset()
+	e.NextDryRunError.Reset()
+	e.Faults.Reset()
 }
 
 // nolint: gocyclo
 func (e *EC2API) CreateFleetWithContext(_ context.Context, input *ec2.CreateFleetInput, _ ...request.Option) (*ec2.CreateFleetOutput, error) {
+	if aws.BoolValue(input.DryRun) {
+		if !e.NextDryRunError.IsNil() {
+			defer e.NextDryRunError.Reset()
+			return nil, e.NextDryRunError.Get()
+		}
+		return nil, awserr.New("DryRunOperation", "Request would have succeeded, but DryRun flag is set.", nil)
+	}
 	return e.CreateFleetBehavior.Invoke(input, func(input *ec2.CreateFleetInput) (*ec2.CreateFleetOutput, error) {
+		if err := e.Faults.Throttle("CreateFleet"); err != nil {
+			return nil, err
+		}
 		if input.LaunchTemplateConfigs[0].LaunchTemplateSpecification.LaunchTemplateName == nil {
 			return nil, fmt.Errorf("missing launch template name")
 		}
+		// Attribute-based overrides are expanded into one override per allowed instance type so that they can be
+		// fulfilled the same way as enumerated overrides
+		launchTemplateConfigs := e.expandInstanceRequirements(input.LaunchTemplateConfigs)
 		var instanceIds []*string
 		var skippedPools []CapacityPool
-		var spotInstanceRequestID *string
+		var spotInstanceRequestID, instanceLifecycle *string
+		// The overrides that the fleet is fulfilled with, reported back the same way that EC2 does
+		fulfilledOverride := launchTemplateConfigs[0].Overrides[0]
 
 		if aws.StringValue(input.TargetCapacitySpecification.DefaultTargetCapacityType) == v1alpha5.CapacityTypeSpot {
 			spotInstanceRequestID = aws.String(test.RandomName())
+			instanceLifecycle = aws.String(ec2.InstanceLifecycleTypeSpot)
 		}
+		instanceTags := lo.FlatMap(input.TagSpecifications, func(t *ec2.TagSpecification, _ int) []*ec2.Tag {
+			return lo.Ternary(aws.StringValue(t.ResourceType) == ec2.ResourceTypeInstance, t.Tags, nil)
+		})
 
-		fulfilled := 0
-		for _, ltc := range input.LaunchTemplateConfigs {
+		// Instances count towards the target capacity by the weighted capacity of their override
+		target := float64(aws.Int64Value(input.TargetCapacitySpecification.TotalTargetCapacity))
+		fulfilled := 0.0
+		for _, ltc := range launchTemplateConfigs {
 			for _, override := range ltc.Overrides {
 				skipInstance := false
 				e.InsufficientCapacityPools.Range(func(pool CapacityPool) bool {
@@ -132,6 +185,15 @@ func (e *EC2API) CreateFleetWithContext(_ context.Context, input *ec2.CreateFlee
 					}
 					return true
 				})
+				// Only the pools that the fleet still needs capacity from are attempted
+				if !skipInstance && fulfilled < target && e.Faults.InsufficientCapacity() {
+					skippedPools = append(skippedPools, CapacityPool{
+						CapacityType: aws.StringValue(input.TargetCapacitySpecification.DefaultTargetCapacityType),
+						InstanceType: aws.StringValue(override.InstanceType),
+						Zone:         aws.StringValue(override.AvailabilityZone),
+					})
+					skipInstance = true
+				}
 				if skipInstance {
 					continue
 				}
@@ -141,37 +203,52 @@ func (e *EC2API) CreateFleetWithContext(_ context.Context, input *ec2.CreateFlee
 					amiID = lt.LaunchTemplateData.ImageId
 					e.CalledWithCreateLaunchTemplateInput.Add(lt)
 				}
-				instanceState := ec2.InstanceStateNameRunning
-				for ; fulfilled < int(*input.TargetCapacitySpecification.TotalTargetCapacity); fulfilled++ {
+				if fulfilled < target {
+					fulfilledOverride = override
+				}
+				for ; fulfilled < target; fulfilled += lo.Ternary(override.WeightedCapacity != nil, aws.Float64Value(override.WeightedCapacity), 1) {
 					instance := &ec2.Instance{
 						ImageId:               aws.String(*amiID),
 						InstanceId:            aws.String(test.RandomName()),
-						Placement:             &ec2.Placement{AvailabilityZone: input.LaunchTemplateConfigs[0].Overrides[0].AvailabilityZone},
+						Placement:             &ec2.Placement{AvailabilityZone: override.AvailabilityZone},
+						SubnetId:              override.SubnetId,
 						PrivateDnsName:        aws.String(randomdata.IpV4Address()),
-						InstanceType:          input.LaunchTemplateConfigs[0].Overrides[0].InstanceType,
+						InstanceType:          override.InstanceType,
+						InstanceLifecycle:     instanceLifecycle,
 						SpotInstanceRequestId: spotInstanceRequestID,
+						LaunchTime:            aws.Time(time.Now()),
+						Tags:                  lo.Map(instanceTags, func(t *ec2.Tag, _ int) *ec2.Tag { return &ec2.Tag{Key: t.Key, Value: t.Value} }),
 						State: &ec2.InstanceState{
-							Name: &instanceState,
+							Name: aws.String(ec2.InstanceStateNameRunning),
+							Code: aws.Int64(16),
 						},
+						BlockDeviceMappings: []*ec2.InstanceBlockDeviceMapping{{
+							DeviceName: aws.String("/dev/xvda"),
+							Ebs:        &ec2.EbsInstanceBlockDevice{VolumeId: aws.String(fmt.Sprintf("vol-%s", randomdata.Alphanumeric(17)))},
+						}},
+						NetworkInterfaces: []*ec2.InstanceNetworkInterface{{
+							NetworkInterfaceId: aws.String(fmt.Sprintf("eni-%s", randomdata.Alphanumeric(17))),
+							Attachment:         &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int64(0)},
+						}},
 					}
 					e.Instances.Store(*instance.InstanceId, instance)
 					instanceIds = append(instanceIds, instance.InstanceId)
 				}
 			}
-			if fulfilled == int(*input.TargetCapacitySpecification.TotalTargetCapacity) {
+			if fulfilled >= target {
 				break
 			}
 		}
 		result := &ec2.CreateFleetOutput{Instances: []*ec2.CreateFleetInstance{
 			{
 				InstanceIds:  instanceIds,
-				InstanceType: input.LaunchTemplateConfigs[0].Overrides[0].InstanceType,
+				InstanceType: fulfilledOverride.InstanceType,
 				Lifecycle:    input.TargetCapacitySpecification.DefaultTargetCapacityType,
 				LaunchTemplateAndOverrides: &ec2.LaunchTemplateAndOverridesResponse{
 					Overrides: &ec2.FleetLaunchTemplateOverrides{
-						SubnetId:         input.LaunchTemplateConfigs[0].Overrides[0].SubnetId,
-						InstanceType:     input.LaunchTemplateConfigs[0].Overrides[0].InstanceType,
-						AvailabilityZone: input.LaunchTemplateConfigs[0].Overrides[0].AvailabilityZone,
+						SubnetId:         fulfilledOverride.SubnetId,
+						InstanceType:     fulfilledOverride.InstanceType,
+						AvailabilityZone: fulfilledOverride.AvailabilityZone,
 					},
 				},
 			},
@@ -191,15 +268,72 @@ func (e *EC2API) CreateFleetWithContext(_ context.Context, input *ec2.CreateFlee
 	})
 }
 
+// expandInstanceRequirements expands the instance requirements of overrides into an override per instance type, using
+// the allowed instance types, or else the described instance types that match the vCPU and memory ranges
+func (e *EC2API) expandInstanceRequirements(launchTemplateConfigs []*ec2.FleetLaunchTemplateConfigRequest) []*ec2.FleetLaunchTemplateConfigRequest {
+	return lo.Map(launchTemplateConfigs, func(ltc *ec2.FleetLaunchTemplateConfigRequest, _ int) *ec2.FleetLaunchTemplateConfigRequest {
+		return &ec2.FleetLaunchTemplateConfigRequest{
+			LaunchTemplateSpecification: ltc.LaunchTemplateSpecification,
+			Overrides: lo.FlatMap(ltc.Overrides, func(override *ec2.FleetLaunchTemplateOverridesRequest, _ int) []*ec2.FleetLaunchTemplateOverridesRequest {
+				if override.InstanceRequirements == nil {
+					return []*ec2.FleetLaunchTemplateOverridesRequest{override}
+				}
+				instanceTypes := override.InstanceRequirements.AllowedInstanceTypes
+				if len(instanceTypes) == 0 {
+					instanceTypes = e.matchingInstanceTypes(override.InstanceRequirements)
+				}
+				return lo.Map(instanceTypes, func(instanceType *string, _ int) *ec2.FleetLaunchTemplateOverridesRequest {
+					return &ec2.FleetLaunchTemplateOverridesRequest{
+						InstanceType:     instanceType,
+						SubnetId:         override.SubnetId,
+						AvailabilityZone: override.AvailabilityZone,
+					}
+				})
+			}),
+		}
+	})
+}
+
+func (e *EC2API) matchingInstanceTypes(requirements *ec2.InstanceRequirementsRequest) []*string {
+	output := defaultDescribeInstanceTypesOutput
+	if !e.DescribeInstanceTypesOutput.IsNil() {
+		output = e.DescribeInstanceTypesOutput.Clone()
+	}
+	inRange := func(value int64, min, max *int64) bool {
+		return (min == nil || value >= *min) && (max == nil || value <= *max)
+	}
+	var instanceTypes []*string
+	for _, info := range output.InstanceTypes {
+		if requirements.VCpuCount != nil && !inRange(aws.Int64Value(info.VCpuInfo.DefaultVCpus), requirements.VCpuCount.Min, requirements.VCpuCount.Max) {
+			continue
+		}
+		if requirements.MemoryMiB != nil && !inRange(aws.Int64Value(info.MemoryInfo.SizeInMiB), requirements.MemoryMiB.Min, requirements.MemoryMiB.Max) {
+			continue
+		}
+		if lo.ContainsBy(requirements.ExcludedInstanceTypes, func(pattern *string) bool {
+			matched, _ := path.Match(aws.StringValue(pattern), aws.StringValue(info.InstanceType))
+			return matched
+		}) {
+			continue
+		}
+		instanceTypes = append(instanceTypes, info.InstanceType)
+	}
+	return instanceTypes
+}
+
 func (e *EC2API) TerminateInstancesWithContext(_ context.Context, input *ec2.TerminateInstancesInput, _ ...request.Option) (*ec2.TerminateInstancesOutput, error) {
 	return e.TerminateInstancesBehavior.Invoke(input, func(input *ec2.TerminateInstancesInput) (*ec2.TerminateInstancesOutput, error) {
+		if err := e.Faults.Throttle("TerminateInstances"); err != nil {
+			return nil, err
+		}
 		var instanceStateChanges []*ec2.InstanceStateChange
 		for _, id := range input.InstanceIds {
 			instanceID := *id
-			if _, ok := e.Instances.LoadAndDelete(instanceID); ok {
+			// Terminated instances stay visible to DescribeInstances, but can't be terminated again
+			if previousState, ok := e.terminate(instanceID, nil); ok {
 				instanceStateChanges = append(instanceStateChanges, &ec2.InstanceStateChange{
-					PreviousState: &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning), Code: aws.Int64(16)},
-					CurrentState:  &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameShuttingDown), Code: aws.Int64(32)},
+					PreviousState: previousState,
+					CurrentState:  &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameTerminated), Code: aws.Int64(48)},
 					InstanceId:    aws.String(instanceID),
 				})
 			}
@@ -208,11 +342,197 @@ func (e *EC2API) TerminateInstancesWithContext(_ context.Context, input *ec2.Ter
 	})
 }
 
+func (e *EC2API) StopInstancesWithContext(_ context.Context, input *ec2.StopInstancesInput, _ ...request.Option) (*ec2.StopInstancesOutput, error) {
+	return e.StopInstancesBehavior.Invoke(input, func(input *ec2.StopInstancesInput) (*ec2.StopInstancesOutput, error) {
+		if err := e.Faults.Throttle("StopInstances"); err != nil {
+			return nil, err
+		}
+		var instanceStateChanges []*ec2.InstanceStateChange
+		for _, id := range input.InstanceIds {
+			change, err := e.setInstanceState(aws.StringValue(id), &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameStopped), Code: aws.Int64(80)})
+			if err != nil {
+				return nil, err
+			}
+			instanceStateChanges = append(instanceStateChanges, change)
+		}
+		return &ec2.StopInstancesOutput{StoppingInstances: instanceStateChanges}, nil
+	})
+}
+
+func (e *EC2API) StartInstancesWithContext(_ context.Context, input *ec2.StartInstancesInput, _ ...request.Option) (*ec2.StartInstancesOutput, error) {
+	return e.StartInstancesBehavior.Invoke(input, func(input *ec2.StartInstancesInput) (*ec2.StartInstancesOutput, error) {
+		if err := e.Faults.Throttle("StartInstances"); err != nil {
+			return nil, err
+		}
+		var instanceStateChanges []*ec2.InstanceStateChange
+		for _, id := range input.InstanceIds {
+			change, err := e.setInstanceState(aws.StringValue(id), &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNamePending), Code: aws.Int64(0)})
+			if err != nil {
+				return nil, err
+			}
+			instanceStateChanges = append(instanceStateChanges, change)
+		}
+		return &ec2.StartInstancesOutput{StartingInstances: instanceStateChanges}, nil
+	})
+}
+
+// RunInstancesWithContext only supports DryRun launches, since instances are launched with CreateFleet
+func (e *EC2API) RunInstancesWithContext(_ context.Context, input *ec2.RunInstancesInput, _ ...request.Option) (*ec2.Reservation, error) {
+	return e.RunInstancesBehavior.Invoke(input, func(input *ec2.RunInstancesInput) (*ec2.Reservation, error) {
+		if !aws.BoolValue(input.DryRun) {
+			return nil, fmt.Errorf("RunInstances is only supported with DryRun")
+		}
+		return nil, awserr.New("DryRunOperation", "Request would have succeeded, but DryRun flag is set.", nil)
+	})
+}
+
+// setInstanceState moves an instance that isn't terminated to the given state
+func (e *EC2API) setInstanceState(instanceID string, state *ec2.InstanceState) (*ec2.InstanceStateChange, error) {
+	raw, ok := e.Instances.Load(instanceID)
+	if !ok {
+		return nil, instanceNotFoundError(instanceID)
+	}
+	instance := raw.(*ec2.Instance)
+	previousState := instance.State
+	if previousState == nil {
+		previousState = &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning), Code: aws.Int64(16)}
+	}
+	if aws.StringValue(previousState.Name) == ec2.InstanceStateNameTerminated {
+		return nil, awserr.New("IncorrectInstanceState", fmt.Sprintf("The instance '%s' is not in a state from which it can be stopped or started.", instanceID), nil)
+	}
+	instance.State = state
+	return &ec2.InstanceStateChange{PreviousState: previousState, CurrentState: state, InstanceId: aws.String(instanceID)}, nil
+}
+
+func (e *EC2API) DescribeNetworkInterfacesPagesWithContext(_ context.Context, input *ec2.DescribeNetworkInterfacesInput, fn func(*ec2.DescribeNetworkInterfacesOutput, bool) bool, _ ...request.Option) error {
+	if err := e.Faults.Throttle("DescribeNetworkInterfaces"); err != nil {
+		return err
+	}
+	var networkInterfaces []*ec2.NetworkInterface
+	e.NetworkInterfaces.Range(func(_, v any) bool {
+		networkInterface := v.(*ec2.NetworkInterface)
+		if matchesFilters(networkInterface.TagSet, aws.StringValue(networkInterface.Status), input.Filters) {
+			networkInterfaces = append(networkInterfaces, networkInterface)
+		}
+		return true
+	})
+	fn(&ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: networkInterfaces}, true)
+	return nil
+}
+
+func (e *EC2API) DeleteNetworkInterfaceWithContext(_ context.Context, input *ec2.DeleteNetworkInterfaceInput, _ ...request.Option) (*ec2.DeleteNetworkInterfaceOutput, error) {
+	if err := e.Faults.Throttle("DeleteNetworkInterface"); err != nil {
+		return nil, err
+	}
+	if _, ok := e.NetworkInterfaces.LoadAndDelete(aws.StringValue(input.NetworkInterfaceId)); !ok {
+		return nil, awserr.New("InvalidNetworkInterfaceID.NotFound", fmt.Sprintf("The networkInterface ID '%s' does not exist", aws.StringValue(input.NetworkInterfaceId)), nil)
+	}
+	return &ec2.DeleteNetworkInterfaceOutput{}, nil
+}
+
+func (e *EC2API) DescribeVolumesPagesWithContext(_ context.Context, input *ec2.DescribeVolumesInput, fn func(*ec2.DescribeVolumesOutput, bool) bool, _ ...request.Option) error {
+	if err := e.Faults.Throttle("DescribeVolumes"); err != nil {
+		return err
+	}
+	var volumes []*ec2.Volume
+	e.Volumes.Range(func(_, v any) bool {
+		volume := v.(*ec2.Volume)
+		if matchesFilters(volume.Tags, aws.StringValue(volume.State), input.Filters) {
+			volumes = append(volumes, volume)
+		}
+		return true
+	})
+	fn(&ec2.DescribeVolumesOutput{Volumes: volumes}, true)
+	return nil
+}
+
+func (e *EC2API) DeleteVolumeWithContext(_ context.Context, input *ec2.DeleteVolumeInput, _ ...request.Option) (*ec2.DeleteVolumeOutput, error) {
+	if err := e.Faults.Throttle("DeleteVolume"); err != nil {
+		return nil, err
+	}
+	if _, ok := e.Volumes.LoadAndDelete(aws.StringValue(input.VolumeId)); !ok {
+		return nil, awserr.New("InvalidVolume.NotFound", fmt.Sprintf("The volume '%s' does not exist.", aws.StringValue(input.VolumeId)), nil)
+	}
+	return &ec2.DeleteVolumeOutput{}, nil
+}
+
+// matchesFilters supports the tag and status filters of network interfaces and volumes
+func matchesFilters(tags []*ec2.Tag, status string, filters []*ec2.Filter) bool {
+	for _, filter := range filters {
+		switch name := aws.StringValue(filter.Name); {
+		case name == "status":
+			if !lo.Contains(aws.StringValueSlice(filter.Values), status) {
+				return false
+			}
+		case name == "tag-key":
+			if !lo.ContainsBy(tags, func(t *ec2.Tag) bool { return lo.Contains(aws.StringValueSlice(filter.Values), aws.StringValue(t.Key)) }) {
+				return false
+			}
+		case strings.HasPrefix(name, "tag:"):
+			if !lo.ContainsBy(tags, func(t *ec2.Tag) bool {
+				return aws.StringValue(t.Key) == strings.TrimPrefix(name, "tag:") && lo.Contains(aws.StringValueSlice(filter.Values), aws.StringValue(t.Value))
+			}) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// InterruptSpotInstance reclaims a running spot instance the way that EC2 does at the end of a spot interruption
+// notice. The interrupted instance is returned so that tests can deliver the matching interruption message.
+func (e *EC2API) InterruptSpotInstance(instanceID string) (*ec2.Instance, error) {
+	raw, ok := e.Instances.Load(instanceID)
+	if !ok {
+		return nil, fmt.Errorf("instance with id '%s' does not exist", instanceID)
+	}
+	if aws.StringValue(raw.(*ec2.Instance).InstanceLifecycle) != ec2.InstanceLifecycleTypeSpot {
+		return nil, fmt.Errorf("instance with id '%s' is not a spot instance", instanceID)
+	}
+	if _, ok := e.terminate(instanceID, &ec2.StateReason{
+		Code:    aws.String("Server.SpotInstanceTermination"),
+		Message: aws.String("Server.SpotInstanceTermination: Spot instance termination"),
+	}); !ok {
+		return nil, fmt.Errorf("instance with id '%s' is already terminated", instanceID)
+	}
+	return raw.(*ec2.Instance), nil
+}
+
+// terminate moves an instance to the terminated state, returning the state that it was previously in and whether
+// the instance existed and wasn't already terminated
+func (e *EC2API) terminate(instanceID string, reason *ec2.StateReason) (*ec2.InstanceState, bool) {
+	raw, ok := e.Instances.Load(instanceID)
+	if !ok {
+		return nil, false
+	}
+	instance := raw.(*ec2.Instance)
+	previousState := instance.State
+	if previousState == nil {
+		previousState = &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning), Code: aws.Int64(16)}
+	}
+	if aws.StringValue(previousState.Name) == ec2.InstanceStateNameTerminated {
+		return nil, false
+	}
+	instance.State = &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameTerminated), Code: aws.Int64(48)}
+	instance.StateReason = reason
+	return previousState, true
+}
+
 func (e *EC2API) CreateLaunchTemplateWithContext(_ context.Context, input *ec2.CreateLaunchTemplateInput, _ ...request.Option) (*ec2.CreateLaunchTemplateOutput, error) {
 	if !e.NextError.IsNil() {
 		defer e.NextError.Reset()
 		return nil, e.NextError.Get()
 	}
+	if aws.BoolValue(input.DryRun) {
+		if !e.NextDryRunError.IsNil() {
+			defer e.NextDryRunError.Reset()
+			return nil, e.NextDryRunError.Get()
+		}
+		return nil, awserr.New("DryRunOperation", "Request would have succeeded, but DryRun flag is set.", nil)
+	}
+	if err := e.Faults.Throttle("CreateLaunchTemplate"); err != nil {
+		return nil, err
+	}
 	e.CalledWithCreateLaunchTemplateInput.Add(input)
 	launchTemplate := &ec2.LaunchTemplate{LaunchTemplateName: input.LaunchTemplateName}
 	e.LaunchTemplates.Store(input.LaunchTemplateName, launchTemplate)
@@ -221,6 +541,9 @@ func (e *EC2API) CreateLaunchTemplateWithContext(_ context.Context, input *ec2.C
 
 func (e *EC2API) CreateTagsWithContext(_ context.Context, input *ec2.CreateTagsInput, _ ...request.Option) (*ec2.CreateTagsOutput, error) {
 	return e.CreateTagsBehavior.Invoke(input, func(input *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+		if err := e.Faults.Throttle("CreateTags"); err != nil {
+			return nil, err
+		}
 		// Update passed in instances with the passed tags
 		for _, id := range input.Resources {
 			raw, ok := e.Instances.Load(aws.StringValue(id))
@@ -228,24 +551,48 @@ func (e *EC2API) CreateTagsWithContext(_ context.Context, input *ec2.CreateTagsI
 				return nil, fmt.Errorf("instance with id '%s' does not exist", aws.StringValue(id))
 			}
 			instance := raw.(*ec2.Instance)
+			if !e.Faults.Visible(instance) {
+				return nil, instanceNotFoundError(aws.StringValue(id))
+			}
 
 			// Upsert any tags that have the same key
 			newTagKeys := sets.New(lo.Map(input.Tags, func(t *ec2.Tag, _ int) string { return aws.StringValue(t.Key) })...)
-			instance.Tags = lo.Filter(input.Tags, func(t *ec2.Tag, _ int) bool { return newTagKeys.Has(aws.StringValue(t.Key)) })
+			instance.Tags = lo.Filter(instance.Tags, func(t *ec2.Tag, _ int) bool { return !newTagKeys.Has(aws.StringValue(t.Key)) })
 			instance.Tags = append(instance.Tags, input.Tags...)
 		}
 		return nil, nil
 	})
 }
 
+func (e *EC2API) DeleteTagsWithContext(_ context.Context, input *ec2.DeleteTagsInput, _ ...request.Option) (*ec2.DeleteTagsOutput, error) {
+	if err := e.Faults.Throttle("DeleteTags"); err != nil {
+		return nil, err
+	}
+	keys := sets.New(lo.Map(input.Tags, func(t *ec2.Tag, _ int) string { return aws.StringValue(t.Key) })...)
+	for _, id := range input.Resources {
+		raw, ok := e.Instances.Load(aws.StringValue(id))
+		if !ok {
+			return nil, fmt.Errorf("instance with id '%s' does not exist", aws.StringValue(id))
+		}
+		instance := raw.(*ec2.Instance)
+		instance.Tags = lo.Reject(instance.Tags, func(t *ec2.Tag, _ int) bool { return keys.Has(aws.StringValue(t.Key)) })
+	}
+	return &ec2.DeleteTagsOutput{}, nil
+}
+
 func (e *EC2API) DescribeInstancesWithContext(_ context.Context, input *ec2.DescribeInstancesInput, _ ...request.Option) (*ec2.DescribeInstancesOutput, error) {
 	return e.DescribeInstancesBehavior.Invoke(input, func(input *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+		if err := e.Faults.Throttle("DescribeInstances"); err != nil {
+			return nil, err
+		}
 		var instances []*ec2.Instance
 
 		// If it's a list call and no instance ids are specified
 		if len(aws.StringValueSlice(input.InstanceIds)) == 0 {
 			e.Instances.Range(func(k interface{}, v interface{}) bool {
-				instances = append(instances, v.(*ec2.Instance))
+				if e.Faults.Visible(v.(*ec2.Instance)) {
+					instances = append(instances, v.(*ec2.Instance))
+				}
 				return true
 			})
 		}
@@ -254,6 +601,10 @@ func (e *EC2API) DescribeInstancesWithContext(_ context.Context, input *ec2.Desc
 			if instance == nil {
 				continue
 			}
+			// EC2 fails the whole request if any of the instances that it's asked for haven't propagated yet
+			if !e.Faults.Visible(instance.(*ec2.Instance)) {
+				return nil, instanceNotFoundError(*instanceID)
+			}
 			instances = append(instances, instance.(*ec2.Instance))
 		}
 		return &ec2.DescribeInstancesOutput{
@@ -271,6 +622,19 @@ func (e *EC2API) DescribeInstancesPagesWithContext(ctx context.Context, input *e
 	return nil
 }
 
+// DescribeInstanceStatusPagesWithContext returns no statuses unless DescribeInstanceStatusBehavior is set, as if every
+// instance were still initializing
+func (e *EC2API) DescribeInstanceStatusPagesWithContext(_ context.Context, input *ec2.DescribeInstanceStatusInput, fn func(*ec2.DescribeInstanceStatusOutput, bool) bool, _ ...request.Option) error {
+	output, err := e.DescribeInstanceStatusBehavior.Invoke(input, func(_ *ec2.DescribeInstanceStatusInput) (*ec2.DescribeInstanceStatusOutput, error) {
+		return &ec2.DescribeInstanceStatusOutput{}, nil
+	})
+	if err != nil {
+		return err
+	}
+	fn(output, true)
+	return nil
+}
+
 //nolint:gocyclo
 func filterInstances(instances []*ec2.Instance, filters []*ec2.Filter) []*ec2.Instance {
 	var ret []*ec2.Instance
@@ -357,6 +721,9 @@ func (e *EC2API) DescribeLaunchTemplatesWithContext(_ context.Context, input *ec
 		defer e.NextError.Reset()
 		return nil, e.NextError.Get()
 	}
+	if err := e.Faults.Throttle("DescribeLaunchTemplates"); err != nil {
+		return nil, err
+	}
 	if !e.DescribeLaunchTemplatesOutput.IsNil() {
 		return e.DescribeLaunchTemplatesOutput.Clone(), nil
 	}
@@ -387,7 +754,11 @@ func (e *EC2API) DescribeSubnetsWithContext(_ context.Context, input *ec2.Descri
 	subnets := []*ec2.Subnet{
 		{
 			SubnetId:                aws.String("subnet-test1"),
+			VpcId:                   aws.String("vpc-test1"),
 			AvailabilityZone:        aws.String("test-zone-1a"),
+			AvailabilityZoneId:      aws.String("testzone1a"),
+			CidrBlock:               aws.String("10.0.1.0/24"),
+			OwnerId:                 aws.String("123456789012"),
 			AvailableIpAddressCount: aws.Int64(100),
 			MapPublicIpOnLaunch:     aws.Bool(false),
 			Tags: []*ec2.Tag{
@@ -397,7 +768,11 @@ func (e *EC2API) DescribeSubnetsWithContext(_ context.Context, input *ec2.Descri
 		},
 		{
 			SubnetId:                aws.String("subnet-test2"),
+			VpcId:                   aws.String("vpc-test1"),
 			AvailabilityZone:        aws.String("test-zone-1b"),
+			AvailabilityZoneId:      aws.String("testzone1b"),
+			CidrBlock:               aws.String("10.0.2.0/24"),
+			OwnerId:                 aws.String("123456789012"),
 			AvailableIpAddressCount: aws.Int64(100),
 			MapPublicIpOnLaunch:     aws.Bool(true),
 			Tags: []*ec2.Tag{
@@ -407,7 +782,11 @@ func (e *EC2API) DescribeSubnetsWithContext(_ context.Context, input *ec2.Descri
 		},
 		{
 			SubnetId:                aws.String("subnet-test3"),
+			VpcId:                   aws.String("vpc-test1"),
 			AvailabilityZone:        aws.String("test-zone-1c"),
+			AvailabilityZoneId:      aws.String("testzone1c"),
+			CidrBlock:               aws.String("10.0.3.0/24"),
+			OwnerId:                 aws.String("210987654321"),
 			AvailableIpAddressCount: aws.Int64(100),
 			Tags: []*ec2.Tag{
 				{Key: aws.String("Name"), Value: aws.String("test-subnet-3")},
@@ -422,6 +801,19 @@ func (e *EC2API) DescribeSubnetsWithContext(_ context.Context, input *ec2.Descri
 	return &ec2.DescribeSubnetsOutput{Subnets: FilterDescribeSubnets(subnets, input.Filters)}, nil
 }
 
+func (e *EC2API) DescribeRouteTablesPagesWithContext(_ context.Context, _ *ec2.DescribeRouteTablesInput, fn func(*ec2.DescribeRouteTablesOutput, bool) bool, _ ...request.Option) error {
+	if !e.NextError.IsNil() {
+		defer e.NextError.Reset()
+		return e.NextError.Get()
+	}
+	if !e.DescribeRouteTablesOutput.IsNil() {
+		fn(e.DescribeRouteTablesOutput.Clone(), false)
+		return nil
+	}
+	fn(&ec2.DescribeRouteTablesOutput{}, false)
+	return nil
+}
+
 func (e *EC2API) DescribeSecurityGroupsWithContext(_ context.Context, input *ec2.DescribeSecurityGroupsInput, _ ...request.Option) (*ec2.DescribeSecurityGroupsOutput, error) {
 	if !e.NextError.IsNil() {
 		defer e.NextError.Reset()
@@ -436,6 +828,7 @@ func (e *EC2API) DescribeSecurityGroupsWithContext(_ context.Context, input *ec2
 		{
 			GroupId:   aws.String("sg-test1"),
 			GroupName: aws.String("securityGroup-test1"),
+			VpcId:     aws.String("vpc-test1"),
 			Tags: []*ec2.Tag{
 				{Key: aws.String("Name"), Value: aws.String("test-security-group-1")},
 				{Key: aws.String("foo"), Value: aws.String("bar")},
@@ -444,6 +837,7 @@ func (e *EC2API) DescribeSecurityGroupsWithContext(_ context.Context, input *ec2
 		{
 			GroupId:   aws.String("sg-test2"),
 			GroupName: aws.String("securityGroup-test2"),
+			VpcId:     aws.String("vpc-test1"),
 			Tags: []*ec2.Tag{
 				{Key: aws.String("Name"), Value: aws.String("test-security-group-2")},
 				{Key: aws.String("foo"), Value: aws.String("bar")},
@@ -452,6 +846,7 @@ func (e *EC2API) DescribeSecurityGroupsWithContext(_ context.Context, input *ec2
 		{
 			GroupId:   aws.String("sg-test3"),
 			GroupName: aws.String("securityGroup-test3"),
+			VpcId:     aws.String("vpc-test1"),
 			Tags: []*ec2.Tag{
 				{Key: aws.String("Name"), Value: aws.String("test-security-group-3")},
 				{Key: aws.String("TestTag")},
@@ -512,95 +907,95 @@ func (e *EC2API) DescribeInstanceTypeOfferingsWithContext(_ context.Context, _ *
 		InstanceTypeOfferings: []*ec2.InstanceTypeOffering{
 			{
 				InstanceType: aws.String("m5.large"),
-				Location:     aws.String("test-zone-1a"),
+				Location:     aws.String("testzone1a"),
 			},
 			{
 				InstanceType: aws.String("m5.large"),
-				Location:     aws.String("test-zone-1b"),
+				Location:     aws.String("testzone1b"),
 			},
 			{
 				InstanceType: aws.String("m5.large"),
-				Location:     aws.String("test-zone-1c"),
+				Location:     aws.String("testzone1c"),
 			},
 			{
 				InstanceType: aws.String("m5.xlarge"),
-				Location:     aws.String("test-zone-1a"),
+				Location:     aws.String("testzone1a"),
 			},
 			{
 				InstanceType: aws.String("m5.xlarge"),
-				Location:     aws.String("test-zone-1b"),
+				Location:     aws.String("testzone1b"),
 			},
 			{
 				InstanceType: aws.String("m5.2xlarge"),
-				Location:     aws.String("test-zone-1a"),
+				Location:     aws.String("testzone1a"),
 			},
 			{
 				InstanceType: aws.String("m5.4xlarge"),
-				Location:     aws.String("test-zone-1a"),
+				Location:     aws.String("testzone1a"),
 			},
 			{
 				InstanceType: aws.String("m5.8xlarge"),
-				Location:     aws.String("test-zone-1a"),
+				Location:     aws.String("testzone1a"),
 			},
 			{
 				InstanceType: aws.String("p3.8xlarge"),
-				Location:     aws.String("test-zone-1a"),
+				Location:     aws.String("testzone1a"),
 			},
 			{
 				InstanceType: aws.String("p3.8xlarge"),
-				Location:     aws.String("test-zone-1b"),
+				Location:     aws.String("testzone1b"),
 			},
 			{
 				InstanceType: aws.String("dl1.24xlarge"),
-				Location:     aws.String("test-zone-1a"),
+				Location:     aws.String("testzone1a"),
 			},
 			{
 				InstanceType: aws.String("dl1.24xlarge"),
-				Location:     aws.String("test-zone-1b"),
+				Location:     aws.String("testzone1b"),
 			},
 			{
 				InstanceType: aws.String("g4dn.8xlarge"),
-				Location:     aws.String("test-zone-1a"),
+				Location:     aws.String("testzone1a"),
 			},
 			{
 				InstanceType: aws.String("g4dn.8xlarge"),
-				Location:     aws.String("test-zone-1b"),
+				Location:     aws.String("testzone1b"),
 			},
 			{
 				InstanceType: aws.String("t3.large"),
-				Location:     aws.String("test-zone-1a"),
+				Location:     aws.String("testzone1a"),
 			},
 			{
 				InstanceType: aws.String("t3.large"),
-				Location:     aws.String("test-zone-1b"),
+				Location:     aws.String("testzone1b"),
 			},
 			{
 				InstanceType: aws.String("inf1.2xlarge"),
-				Location:     aws.String("test-zone-1a"),
+				Location:     aws.String("testzone1a"),
 			},
 			{
 				InstanceType: aws.String("inf1.6xlarge"),
-				Location:     aws.String("test-zone-1a"),
+				Location:     aws.String("testzone1a"),
 			},
 			{
 				InstanceType: aws.String("trn1.2xlarge"),
-				Location:     aws.String("test-zone-1a"),
+				Location:     aws.String("testzone1a"),
 			},
 			{
 				InstanceType: aws.String("c6g.large"),
-				Location:     aws.String("test-zone-1a"),
+				Location:     aws.String("testzone1a"),
 			},
 			{
 				InstanceType: aws.String("m5.metal"),
-				Location:     aws.String("test-zone-1a"),
+				Location:     aws.String("testzone1a"),
 			},
 			{
 				InstanceType: aws.String("m5.metal"),
-				Location:     aws.String("test-zone-1b"),
+				Location:     aws.String("testzone1b"),
 			},
 			{
 				InstanceType: aws.String("m5.metal"),
-				Location:     aws.String("test-zone-1c"),
+				Location:     aws.String("testzone1c"),
 			},
 		},
 	}, nil
@@ -621,13 +1016,60 @@ func (e *EC2API) DescribeSpotPriceHistoryWithContext(_ aws.Context, input *ec2.D
 		defer e.NextError.Reset()
 		return nil, e.NextError.Get()
 	}
+	if err := e.DescribeSpotPriceHistoryError.Get(); err != nil {
+		return nil, err
+	}
 	if !e.DescribeSpotPriceHistoryOutput.IsNil() {
 		return e.DescribeSpotPriceHistoryOutput.Clone(), nil
 	}
+	if e.SpotPriceHistory.Len() > 0 {
+		return &ec2.DescribeSpotPriceHistoryOutput{SpotPriceHistory: e.spotPricesAt(input)}, nil
+	}
 	// fail if the test doesn't provide specific data which causes our pricing provider to use its static price list
 	return nil, errors.New("no pricing data provided")
 }
 
+// spotPricesAt simulates the spot price history, returning the price of each instance type and zone that was in
+// effect at the start time of the request, newest first
+func (e *EC2API) spotPricesAt(input *ec2.DescribeSpotPriceHistoryInput) []*ec2.SpotPrice {
+	startTime := lo.Ternary(input.StartTime != nil, aws.TimeValue(input.StartTime), time.Now())
+	instanceTypes := sets.New(aws.StringValueSlice(input.InstanceTypes)...)
+	productDescriptions := sets.New(aws.StringValueSlice(input.ProductDescriptions)...)
+	latest := map[string]*ec2.SpotPrice{}
+	e.SpotPriceHistory.ForEach(func(spotPrice *ec2.SpotPrice) {
+		switch {
+		case aws.TimeValue(spotPrice.Timestamp).After(startTime):
+		case instanceTypes.Len() > 0 && !instanceTypes.Has(aws.StringValue(spotPrice.InstanceType)):
+		case input.AvailabilityZone != nil && aws.StringValue(input.AvailabilityZone) != aws.StringValue(spotPrice.AvailabilityZone):
+		case spotPrice.ProductDescription != nil && productDescriptions.Len() > 0 && !productDescriptions.Has(aws.StringValue(spotPrice.ProductDescription)):
+		default:
+			key := aws.StringValue(spotPrice.InstanceType) + "/" + aws.StringValue(spotPrice.AvailabilityZone)
+			if current, ok := latest[key]; !ok || aws.TimeValue(spotPrice.Timestamp).After(aws.TimeValue(current.Timestamp)) {
+				latest[key] = spotPrice
+			}
+		}
+	})
+	spotPrices := lo.Values(latest)
+	sort.Slice(spotPrices, func(i, j int) bool {
+		if !spotPrices[i].Timestamp.Equal(aws.TimeValue(spotPrices[j].Timestamp)) {
+			return spotPrices[i].Timestamp.After(aws.TimeValue(spotPrices[j].Timestamp))
+		}
+		return aws.StringValue(spotPrices[i].InstanceType)+aws.StringValue(spotPrices[i].AvailabilityZone) <
+			aws.StringValue(spotPrices[j].InstanceType)+aws.StringValue(spotPrices[j].AvailabilityZone)
+	})
+	return spotPrices
+}
+
+func (e *EC2API) GetSpotPlacementScoresWithContext(_ context.Context, input *ec2.GetSpotPlacementScoresInput, _ ...request.Option) (*ec2.GetSpotPlacementScoresOutput, error) {
+	return e.GetSpotPlacementScoresBehavior.Invoke(input, func(_ *ec2.GetSpotPlacementScoresInput) (*ec2.GetSpotPlacementScoresOutput, error) {
+		if err := e.Faults.Throttle("GetSpotPlacementScores"); err != nil {
+			return nil, err
+		}
+		// zones aren't scored unless the test provides scores
+		return &ec2.GetSpotPlacementScoresOutput{}, nil
+	})
+}
+
 func (e *EC2API) DescribeSpotPriceHistoryPagesWithContext(ctx aws.Context, input *ec2.DescribeSpotPriceHistoryInput, fn func(*ec2.DescribeSpotPriceHistoryOutput, bool) bool, _ ...request.Option) error {
 	out, err := e.DescribeSpotPriceHistoryWithContext(ctx, input)
 	if err != nil {
@@ -15,6 +15,13 @@ limitations under the License.
 package fake
 
 import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/eks"
 	"github.com/aws/aws-sdk-go/service/eks/eksiface"
 )
@@ -24,7 +31,11 @@ const ()
 // EKSAPIBehavior must be reset between tests otherwise tests will
 // pollute each other.
 type EKSAPIBehavior struct {
-	DescribeClusterBehaviour MockedFunction[eks.DescribeClusterInput, eks.DescribeClusterOutput]
+	DescribeClusterBehaviour    MockedFunction[eks.DescribeClusterInput, eks.DescribeClusterOutput]
+	DescribeAccessEntryBehavior MockedFunction[eks.DescribeAccessEntryInput, eks.DescribeAccessEntryOutput]
+	CreateAccessEntryBehavior   MockedFunction[eks.CreateAccessEntryInput, eks.CreateAccessEntryOutput]
+	// AccessEntries are keyed by principal ARN
+	AccessEntries sync.Map
 }
 
 type EKSAPI struct {
@@ -36,6 +47,12 @@ type EKSAPI struct {
 // each other.
 func (s *EKSAPI) Reset() {
 	s.DescribeClusterBehaviour.Reset()
+	s.DescribeAccessEntryBehavior.Reset()
+	s.CreateAccessEntryBehavior.Reset()
+	s.AccessEntries.Range(func(k, _ any) bool {
+		s.AccessEntries.Delete(k)
+		return true
+	})
 }
 
 func (s *EKSAPI) DescribeCluster(input *eks.DescribeClusterInput) (*eks.DescribeClusterOutput, error) {
@@ -43,3 +60,27 @@ func (s *EKSAPI) DescribeCluster(input *eks.DescribeClusterInput) (*eks.Describe
 		return nil, nil
 	})
 }
+
+func (s *EKSAPI) DescribeClusterWithContext(_ context.Context, input *eks.DescribeClusterInput, _ ...request.Option) (*eks.DescribeClusterOutput, error) {
+	return s.DescribeCluster(input)
+}
+
+func (s *EKSAPI) DescribeAccessEntryWithContext(_ context.Context, input *eks.DescribeAccessEntryInput, _ ...request.Option) (*eks.DescribeAccessEntryOutput, error) {
+	return s.DescribeAccessEntryBehavior.Invoke(input, func(input *eks.DescribeAccessEntryInput) (*eks.DescribeAccessEntryOutput, error) {
+		entry, ok := s.AccessEntries.Load(aws.StringValue(input.PrincipalArn))
+		if !ok {
+			return nil, awserr.New(eks.ErrCodeResourceNotFoundException, fmt.Sprintf("The specified access entry resource %s could not be found.", aws.StringValue(input.PrincipalArn)), nil)
+		}
+		return &eks.DescribeAccessEntryOutput{AccessEntry: entry.(*eks.AccessEntry)}, nil
+	})
+}
+
+func (s *EKSAPI) CreateAccessEntryWithContext(_ context.Context, input *eks.CreateAccessEntryInput, _ ...request.Option) (*eks.CreateAccessEntryOutput, error) {
+	return s.CreateAccessEntryBehavior.Invoke(input, func(input *eks.CreateAccessEntryInput) (*eks.CreateAccessEntryOutput, error) {
+		entry := &eks.AccessEntry{ClusterName: input.ClusterName, PrincipalArn: input.PrincipalArn, Type: input.Type}
+		if _, loaded := s.AccessEntries.LoadOrStore(aws.StringValue(input.PrincipalArn), entry); loaded {
+			return nil, awserr.New(eks.ErrCodeResourceInUseException, fmt.Sprintf("The specified access entry resource %s is already in use on this cluster.", aws.StringValue(input.PrincipalArn)), nil)
+		}
+		return &eks.CreateAccessEntryOutput{AccessEntry: entry}, nil
+	})
+}
@@ -0,0 +1,102 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/aws/karpenter-core/pkg/test"
+)
+
+// FaultOptions configures the faults that are injected into the fake's responses. Each probability is in [0, 1], and
+// faults are disabled when all of the options are left unset.
+type FaultOptions struct {
+	// Seed seeds the source that faults are drawn from, so that a test sees the same faults on every run
+	Seed int64
+	// ThrottleProbability is the probability that a request fails with RequestLimitExceeded
+	ThrottleProbability float64
+	// InsufficientCapacityProbability is the probability that CreateFleet can't fulfill a capacity pool
+	InsufficientCapacityProbability float64
+	// ConsistencyDelay is how long a launched instance is invisible to the requests that look it up by ID
+	ConsistencyDelay time.Duration
+}
+
+// Faults injects the failures that EC2 returns under load, so that tests exercise the handling of throttling,
+// insufficient capacity and eventual consistency rather than only the happy path.
+type Faults struct {
+	mu      sync.Mutex
+	options FaultOptions
+	rand    *rand.Rand
+}
+
+func (f *Faults) Set(options FaultOptions) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.options = options
+	f.rand = rand.New(rand.NewSource(options.Seed)) //nolint:gosec
+}
+
+func (f *Faults) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.options = FaultOptions{}
+	f.rand = nil
+}
+
+// Throttle returns the error that EC2 returns when the request rate of the operation is exceeded, or nil if the
+// request isn't throttled
+func (f *Faults) Throttle(operation string) error {
+	if !f.draw(func(o FaultOptions) float64 { return o.ThrottleProbability }) {
+		return nil
+	}
+	return awserr.NewRequestFailure(awserr.New("RequestLimitExceeded", fmt.Sprintf("Request limit exceeded for %s.", operation), nil),
+		http.StatusServiceUnavailable, test.RandomName())
+}
+
+// InsufficientCapacity returns true if CreateFleet shouldn't be able to fulfill a capacity pool
+func (f *Faults) InsufficientCapacity() bool {
+	return f.draw(func(o FaultOptions) float64 { return o.InsufficientCapacityProbability })
+}
+
+// Visible returns false if the instance was launched too recently to be visible to requests that look it up by ID
+func (f *Faults) Visible(instance *ec2.Instance) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.options.ConsistencyDelay == 0 || instance.LaunchTime == nil {
+		return true
+	}
+	return time.Since(aws.TimeValue(instance.LaunchTime)) >= f.options.ConsistencyDelay
+}
+
+func (f *Faults) draw(probability func(FaultOptions) float64) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.rand == nil {
+		return false
+	}
+	return f.rand.Float64() < probability(f.options)
+}
+
+func instanceNotFoundError(instanceID string) error {
+	return awserr.New("InvalidInstanceID.NotFound", fmt.Sprintf("The instance ID '%s' does not exist", instanceID), nil)
+}
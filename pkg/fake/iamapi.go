@@ -0,0 +1,138 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/samber/lo"
+)
+
+// IAMAPIBehavior must be reset between tests otherwise tests will
+// pollute each other.
+type IAMAPIBehavior struct {
+	SimulatePrincipalPolicyBehavior MockedFunction[iam.SimulatePrincipalPolicyInput, iam.SimulatePolicyResponse]
+	// InstanceProfiles are keyed by name
+	InstanceProfiles sync.Map
+	// Roles are keyed by name
+	Roles sync.Map
+}
+
+type IAMAPI struct {
+	iamiface.IAMAPI
+	IAMAPIBehavior
+}
+
+// Reset must be called between tests otherwise tests will pollute
+// each other.
+func (s *IAMAPI) Reset() {
+	s.SimulatePrincipalPolicyBehavior.Reset()
+	s.InstanceProfiles.Range(func(k, _ any) bool {
+		s.InstanceProfiles.Delete(k)
+		return true
+	})
+	s.Roles.Range(func(k, _ any) bool {
+		s.Roles.Delete(k)
+		return true
+	})
+}
+
+func (s *IAMAPI) SimulatePrincipalPolicyPagesWithContext(_ aws.Context, input *iam.SimulatePrincipalPolicyInput, fn func(*iam.SimulatePolicyResponse, bool) bool, _ ...request.Option) error {
+	out, err := s.SimulatePrincipalPolicyBehavior.Invoke(input, func(input *iam.SimulatePrincipalPolicyInput) (*iam.SimulatePolicyResponse, error) {
+		// every action is allowed by default
+		return &iam.SimulatePolicyResponse{
+			EvaluationResults: lo.Map(input.ActionNames, func(action *string, _ int) *iam.EvaluationResult {
+				return &iam.EvaluationResult{EvalActionName: action, EvalDecision: aws.String(iam.PolicyEvaluationDecisionTypeAllowed)}
+			}),
+		}, nil
+	})
+	if err != nil {
+		return err
+	}
+	fn(out, true)
+	return nil
+}
+
+func (s *IAMAPI) GetInstanceProfileWithContext(_ aws.Context, input *iam.GetInstanceProfileInput, _ ...request.Option) (*iam.GetInstanceProfileOutput, error) {
+	profile, ok := s.InstanceProfiles.Load(aws.StringValue(input.InstanceProfileName))
+	if !ok {
+		return nil, noSuchInstanceProfile(aws.StringValue(input.InstanceProfileName))
+	}
+	return &iam.GetInstanceProfileOutput{InstanceProfile: profile.(*iam.InstanceProfile)}, nil
+}
+
+func (s *IAMAPI) CreateInstanceProfileWithContext(_ aws.Context, input *iam.CreateInstanceProfileInput, _ ...request.Option) (*iam.CreateInstanceProfileOutput, error) {
+	profile := &iam.InstanceProfile{InstanceProfileName: input.InstanceProfileName, Tags: input.Tags}
+	if _, loaded := s.InstanceProfiles.LoadOrStore(aws.StringValue(input.InstanceProfileName), profile); loaded {
+		return nil, awserr.New(iam.ErrCodeEntityAlreadyExistsException, fmt.Sprintf("Instance Profile %s already exists.", aws.StringValue(input.InstanceProfileName)), nil)
+	}
+	return &iam.CreateInstanceProfileOutput{InstanceProfile: profile}, nil
+}
+
+func (s *IAMAPI) DeleteInstanceProfileWithContext(_ aws.Context, input *iam.DeleteInstanceProfileInput, _ ...request.Option) (*iam.DeleteInstanceProfileOutput, error) {
+	if _, ok := s.InstanceProfiles.LoadAndDelete(aws.StringValue(input.InstanceProfileName)); !ok {
+		return nil, noSuchInstanceProfile(aws.StringValue(input.InstanceProfileName))
+	}
+	return &iam.DeleteInstanceProfileOutput{}, nil
+}
+
+func (s *IAMAPI) AddRoleToInstanceProfileWithContext(_ aws.Context, input *iam.AddRoleToInstanceProfileInput, _ ...request.Option) (*iam.AddRoleToInstanceProfileOutput, error) {
+	profile, ok := s.InstanceProfiles.Load(aws.StringValue(input.InstanceProfileName))
+	if !ok {
+		return nil, noSuchInstanceProfile(aws.StringValue(input.InstanceProfileName))
+	}
+	if len(profile.(*iam.InstanceProfile).Roles) > 0 {
+		return nil, awserr.New(iam.ErrCodeLimitExceededException, "Cannot exceed quota for InstanceSessionsPerInstanceProfile: 1", nil)
+	}
+	s.InstanceProfiles.Store(aws.StringValue(input.InstanceProfileName), &iam.InstanceProfile{
+		InstanceProfileName: input.InstanceProfileName,
+		Tags:                profile.(*iam.InstanceProfile).Tags,
+		Roles:               []*iam.Role{{RoleName: input.RoleName}},
+	})
+	return &iam.AddRoleToInstanceProfileOutput{}, nil
+}
+
+func (s *IAMAPI) RemoveRoleFromInstanceProfileWithContext(_ aws.Context, input *iam.RemoveRoleFromInstanceProfileInput, _ ...request.Option) (*iam.RemoveRoleFromInstanceProfileOutput, error) {
+	profile, ok := s.InstanceProfiles.Load(aws.StringValue(input.InstanceProfileName))
+	if !ok {
+		return nil, noSuchInstanceProfile(aws.StringValue(input.InstanceProfileName))
+	}
+	s.InstanceProfiles.Store(aws.StringValue(input.InstanceProfileName), &iam.InstanceProfile{
+		InstanceProfileName: input.InstanceProfileName,
+		Tags:                profile.(*iam.InstanceProfile).Tags,
+		Roles: lo.Reject(profile.(*iam.InstanceProfile).Roles, func(r *iam.Role, _ int) bool {
+			return aws.StringValue(r.RoleName) == aws.StringValue(input.RoleName)
+		}),
+	})
+	return &iam.RemoveRoleFromInstanceProfileOutput{}, nil
+}
+
+func (s *IAMAPI) GetRoleWithContext(_ aws.Context, input *iam.GetRoleInput, _ ...request.Option) (*iam.GetRoleOutput, error) {
+	role, ok := s.Roles.Load(aws.StringValue(input.RoleName))
+	if !ok {
+		return nil, awserr.New(iam.ErrCodeNoSuchEntityException, fmt.Sprintf("The role with name %s cannot be found.", aws.StringValue(input.RoleName)), nil)
+	}
+	return &iam.GetRoleOutput{Role: role.(*iam.Role)}, nil
+}
+
+func noSuchInstanceProfile(name string) error {
+	return awserr.New(iam.ErrCodeNoSuchEntityException, fmt.Sprintf("Instance Profile %s cannot be found.", name), nil)
+}
@@ -17,11 +17,14 @@ package fake
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/pricing"
 	"github.com/aws/aws-sdk-go/service/pricing/pricingiface"
+	"github.com/samber/lo"
 )
 
 type PricingAPI struct {
@@ -71,3 +74,24 @@ func NewOnDemandPrice(instanceType string, price float64) aws.JSONValue {
 		},
 	}
 }
+
+// NewOnDemandPrices returns the pricing API output for the on-demand price of each instance type
+func NewOnDemandPrices(prices map[string]float64) *pricing.GetProductsOutput {
+	return &pricing.GetProductsOutput{
+		PriceList: lo.Map(lo.Keys(prices), func(instanceType string, _ int) aws.JSONValue {
+			return NewOnDemandPrice(instanceType, prices[instanceType])
+		}),
+	}
+}
+
+// NewSpotPrice returns a spot price record that takes effect at the timestamp, so that a series of them can be added to
+// the simulated spot price history
+func NewSpotPrice(instanceType string, zone string, price float64, timestamp time.Time) *ec2.SpotPrice {
+	return &ec2.SpotPrice{
+		AvailabilityZone:   aws.String(zone),
+		InstanceType:       aws.String(instanceType),
+		ProductDescription: aws.String("Linux/UNIX (Amazon VPC)"),
+		SpotPrice:          aws.String(fmt.Sprintf("%f", price)),
+		Timestamp:          aws.Time(timestamp),
+	}
+}
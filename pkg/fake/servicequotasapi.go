@@ -0,0 +1,57 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/servicequotas/servicequotasiface"
+)
+
+type ServiceQuotasAPI struct {
+	servicequotasiface.ServiceQuotasAPI
+	ServiceQuotasBehavior
+}
+type ServiceQuotasBehavior struct {
+	NextError               AtomicError
+	ListServiceQuotasOutput AtomicPtr[servicequotas.ListServiceQuotasOutput]
+}
+
+func (s *ServiceQuotasAPI) Reset() {
+	s.NextError.Reset()
+	s.ListServiceQuotasOutput.Reset()
+}
+
+func (s *ServiceQuotasAPI) ListServiceQuotasPagesWithContext(_ aws.Context, _ *servicequotas.ListServiceQuotasInput, fn func(*servicequotas.ListServiceQuotasOutput, bool) bool, _ ...request.Option) error {
+	if !s.NextError.IsNil() {
+		return s.NextError.Get()
+	}
+	// no quotas are returned by default so that every quota is treated as unlimited
+	if !s.ListServiceQuotasOutput.IsNil() {
+		fn(s.ListServiceQuotasOutput.Clone(), false)
+		return nil
+	}
+	fn(&servicequotas.ListServiceQuotasOutput{}, false)
+	return nil
+}
+
+func NewServiceQuota(code string, value float64) *servicequotas.ServiceQuota {
+	return &servicequotas.ServiceQuota{
+		ServiceCode: aws.String("ec2"),
+		QuotaCode:   aws.String(code),
+		Value:       aws.Float64(value),
+	}
+}
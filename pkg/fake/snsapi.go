@@ -0,0 +1,46 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+)
+
+// SNSBehavior must be reset between tests otherwise tests will
+// pollute each other.
+type SNSBehavior struct {
+	PublishBehavior MockedFunction[sns.PublishInput, sns.PublishOutput]
+}
+
+type SNSAPI struct {
+	snsiface.SNSAPI
+	SNSBehavior
+}
+
+// Reset must be called between tests otherwise tests will pollute
+// each other.
+func (s *SNSAPI) Reset() {
+	s.PublishBehavior.Reset()
+}
+
+func (s *SNSAPI) PublishWithContext(_ context.Context, input *sns.PublishInput, _ ...request.Option) (*sns.PublishOutput, error) {
+	return s.PublishBehavior.Invoke(input, func(_ *sns.PublishInput) (*sns.PublishOutput, error) {
+		return &sns.PublishOutput{}, nil
+	})
+}
@@ -0,0 +1,66 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/karpenter/pkg/providers/spotadvisor"
+)
+
+type SpotAdvisorAPI struct {
+	SpotAdvisorBehavior
+}
+type SpotAdvisorBehavior struct {
+	NextError AtomicError
+	Data      AtomicPtr[spotadvisor.Data]
+}
+
+func (s *SpotAdvisorAPI) Reset() {
+	s.NextError.Reset()
+	s.Data.Reset()
+}
+
+func (s *SpotAdvisorAPI) GetData(_ context.Context) (*spotadvisor.Data, error) {
+	if !s.NextError.IsNil() {
+		return nil, s.NextError.Get()
+	}
+	if !s.Data.IsNil() {
+		return s.Data.Clone(), nil
+	}
+	return nil, errors.New("no spot advisor data provided")
+}
+
+// NewSpotAdvisorData returns the Spot Instance Advisor dataset with the interruption frequency bucket of each Linux
+// instance type in the region
+func NewSpotAdvisorData(region string, buckets map[string]int) *spotadvisor.Data {
+	advice := map[string]spotadvisor.Advice{}
+	for instanceType, bucket := range buckets {
+		advice[instanceType] = spotadvisor.Advice{InterruptionRate: bucket}
+	}
+	return &spotadvisor.Data{
+		Ranges: []spotadvisor.Range{
+			{Index: 0, Label: "<5%"},
+			{Index: 1, Label: "5-10%"},
+			{Index: 2, Label: "10-15%"},
+			{Index: 3, Label: "15-20%"},
+			{Index: 4, Label: ">20%"},
+		},
+		SpotAdvisor: map[string]map[string]map[string]spotadvisor.Advice{
+			region: {spotadvisor.OSLinux: advice},
+		},
+	}
+}
@@ -34,6 +34,7 @@ type SQSBehavior struct {
 	GetQueueAttributesBehavior MockedFunction[sqs.GetQueueAttributesInput, sqs.GetQueueAttributesOutput]
 	ReceiveMessageBehavior     MockedFunction[sqs.ReceiveMessageInput, sqs.ReceiveMessageOutput]
 	DeleteMessageBehavior      MockedFunction[sqs.DeleteMessageInput, sqs.DeleteMessageOutput]
+	SendMessageBehavior        MockedFunction[sqs.SendMessageInput, sqs.SendMessageOutput]
 }
 
 type SQSAPI struct {
@@ -48,6 +49,7 @@ func (s *SQSAPI) Reset() {
 	s.GetQueueAttributesBehavior.Reset()
 	s.ReceiveMessageBehavior.Reset()
 	s.DeleteMessageBehavior.Reset()
+	s.SendMessageBehavior.Reset()
 }
 
 //nolint:revive,stylecheck
@@ -70,3 +72,9 @@ func (s *SQSAPI) DeleteMessageWithContext(_ context.Context, input *sqs.DeleteMe
 		return nil, nil
 	})
 }
+
+func (s *SQSAPI) SendMessageWithContext(_ context.Context, input *sqs.SendMessageInput, _ ...request.Option) (*sqs.SendMessageOutput, error) {
+	return s.SendMessageBehavior.Invoke(input, func(_ *sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+		return &sqs.SendMessageOutput{}, nil
+	})
+}
@@ -29,12 +29,15 @@ import (
 
 type SSMAPI struct {
 	ssmiface.SSMAPI
-	Parameters         map[string]string
-	GetParameterOutput *ssm.GetParameterOutput
-	WantErr            error
+	Parameters                   map[string]string
+	GetParameterOutput           *ssm.GetParameterOutput
+	WantErr                      error
+	CalledWithGetParametersInput AtomicPtrSlice[ssm.GetParametersInput]
+
+	DescribeInstanceInformationBehavior MockedFunction[ssm.DescribeInstanceInformationInput, ssm.DescribeInstanceInformationOutput]
 }
 
-func (a SSMAPI) GetParameterWithContext(_ context.Context, input *ssm.GetParameterInput, _ ...request.Option) (*ssm.GetParameterOutput, error) {
+func (a *SSMAPI) GetParameterWithContext(_ context.Context, input *ssm.GetParameterInput, _ ...request.Option) (*ssm.GetParameterOutput, error) {
 	if a.WantErr != nil {
 		return nil, a.WantErr
 	}
@@ -56,8 +59,37 @@ func (a SSMAPI) GetParameterWithContext(_ context.Context, input *ssm.GetParamet
 	}, nil
 }
 
+// GetParametersWithContext resolves each of the names in the same way as GetParameterWithContext. Names that aren't
+// in Parameters are returned as invalid parameters, as SSM does.
+func (a *SSMAPI) GetParametersWithContext(ctx context.Context, input *ssm.GetParametersInput, _ ...request.Option) (*ssm.GetParametersOutput, error) {
+	a.CalledWithGetParametersInput.Add(input)
+	if a.WantErr != nil {
+		return nil, a.WantErr
+	}
+	output := &ssm.GetParametersOutput{}
+	for _, name := range input.Names {
+		parameter, err := a.GetParameterWithContext(ctx, &ssm.GetParameterInput{Name: name})
+		if err != nil {
+			output.InvalidParameters = append(output.InvalidParameters, name)
+			continue
+		}
+		output.Parameters = append(output.Parameters, &ssm.Parameter{Name: name, Value: parameter.Parameter.Value})
+	}
+	return output, nil
+}
+
+// DescribeInstanceInformationWithContext returns no instances unless DescribeInstanceInformationBehavior is set, as if
+// no SSM agent had registered
+func (a *SSMAPI) DescribeInstanceInformationWithContext(_ context.Context, input *ssm.DescribeInstanceInformationInput, _ ...request.Option) (*ssm.DescribeInstanceInformationOutput, error) {
+	return a.DescribeInstanceInformationBehavior.Invoke(input, func(_ *ssm.DescribeInstanceInformationInput) (*ssm.DescribeInstanceInformationOutput, error) {
+		return &ssm.DescribeInstanceInformationOutput{}, nil
+	})
+}
+
 func (a *SSMAPI) Reset() {
 	a.GetParameterOutput = nil
 	a.Parameters = nil
 	a.WantErr = nil
+	a.CalledWithGetParametersInput.Reset()
+	a.DescribeInstanceInformationBehavior.Reset()
 }
@@ -16,6 +16,7 @@ package fake
 
 import (
 	"fmt"
+	"path"
 	"strings"
 
 	"github.com/Pallinder/go-randomdata"
@@ -68,7 +69,11 @@ func SubnetsFromFleetRequest(createFleetInput *ec2.CreateFleetInput) []string {
 // Filters are chained with a logical "AND"
 func FilterDescribeSecurtyGroups(sgs []*ec2.SecurityGroup, filters []*ec2.Filter) []*ec2.SecurityGroup {
 	return lo.Filter(sgs, func(group *ec2.SecurityGroup, _ int) bool {
-		return Filter(filters, *group.GroupId, *group.GroupName, group.Tags)
+		isVPCFilter := func(filter *ec2.Filter, _ int) bool { return aws.StringValue(filter.Name) == "vpc-id" }
+		vpcFilters, otherFilters := lo.Filter(filters, isVPCFilter), lo.Reject(filters, isVPCFilter)
+		return Filter(otherFilters, *group.GroupId, *group.GroupName, group.Tags) && lo.EveryBy(vpcFilters, func(filter *ec2.Filter) bool {
+			return lo.Contains(aws.StringValueSlice(filter.Values), aws.StringValue(group.VpcId))
+		})
 	})
 }
 
@@ -76,13 +81,37 @@ func FilterDescribeSecurtyGroups(sgs []*ec2.SecurityGroup, filters []*ec2.Filter
 // Filters are chained with a logical "AND"
 func FilterDescribeSubnets(subnets []*ec2.Subnet, filters []*ec2.Filter) []*ec2.Subnet {
 	return lo.Filter(subnets, func(subnet *ec2.Subnet, _ int) bool {
-		return Filter(filters, *subnet.SubnetId, "", subnet.Tags)
+		attributes := map[string]string{
+			"availability-zone":    aws.StringValue(subnet.AvailabilityZone),
+			"availability-zone-id": aws.StringValue(subnet.AvailabilityZoneId),
+			"cidr-block":           aws.StringValue(subnet.CidrBlock),
+			"owner-id":             aws.StringValue(subnet.OwnerId),
+		}
+		isAttributeFilter := func(filter *ec2.Filter, _ int) bool {
+			_, ok := attributes[aws.StringValue(filter.Name)]
+			return ok
+		}
+		attributeFilters, otherFilters := lo.Filter(filters, isAttributeFilter), lo.Reject(filters, isAttributeFilter)
+		return Filter(otherFilters, *subnet.SubnetId, "", subnet.Tags) && lo.EveryBy(attributeFilters, func(filter *ec2.Filter) bool {
+			return lo.Contains(aws.StringValueSlice(filter.Values), attributes[aws.StringValue(filter.Name)])
+		})
 	})
 }
 
 func FilterDescribeImages(images []*ec2.Image, filters []*ec2.Filter) []*ec2.Image {
+	// Only images are filtered by architecture and creation date
+	isImageFilter := func(filter *ec2.Filter, _ int) bool {
+		return lo.Contains([]string{"architecture", "creation-date"}, aws.StringValue(filter.Name))
+	}
+	imageFilters, filters := lo.Filter(filters, isImageFilter), lo.Reject(filters, isImageFilter)
 	return lo.Filter(images, func(image *ec2.Image, _ int) bool {
-		return Filter(filters, *image.ImageId, *image.Name, image.Tags)
+		return lo.EveryBy(imageFilters, func(filter *ec2.Filter) bool {
+			value := lo.Ternary(aws.StringValue(filter.Name) == "architecture", aws.StringValue(image.Architecture), aws.StringValue(image.CreationDate))
+			return lo.ContainsBy(aws.StringValueSlice(filter.Values), func(pattern string) bool {
+				matched, _ := path.Match(pattern, value)
+				return matched
+			})
+		}) && Filter(filters, *image.ImageId, *image.Name, image.Tags)
 	})
 }
 
@@ -34,6 +34,9 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			BurstablePerformanceSupported: aws.Bool(false),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroEnclavesSupport:          aws.String("unsupported"),
+			NitroTpmSupport:               aws.String("supported"),
+			SupportedBootModes:            aws.StringSlice([]string{"uefi"}),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"arm64"}),
 			},
@@ -64,6 +67,9 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			BurstablePerformanceSupported: aws.Bool(false),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroEnclavesSupport:          aws.String("unsupported"),
+			NitroTpmSupport:               aws.String("supported"),
+			SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 			},
@@ -121,6 +127,9 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			BurstablePerformanceSupported: aws.Bool(false),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroEnclavesSupport:          aws.String("unsupported"),
+			NitroTpmSupport:               aws.String("supported"),
+			SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 			},
@@ -166,6 +175,9 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			BurstablePerformanceSupported: aws.Bool(false),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroEnclavesSupport:          aws.String("unsupported"),
+			NitroTpmSupport:               aws.String("supported"),
+			SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 			},
@@ -205,6 +217,9 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			BurstablePerformanceSupported: aws.Bool(false),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroEnclavesSupport:          aws.String("unsupported"),
+			NitroTpmSupport:               aws.String("supported"),
+			SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 			},
@@ -244,6 +259,9 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			BurstablePerformanceSupported: aws.Bool(false),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroEnclavesSupport:          aws.String("unsupported"),
+			NitroTpmSupport:               aws.String("supported"),
+			SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 			},
@@ -274,6 +292,9 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			BurstablePerformanceSupported: aws.Bool(false),
 			BareMetal:                     aws.Bool(true),
 			Hypervisor:                    aws.String(""),
+			NitroEnclavesSupport:          aws.String("unsupported"),
+			NitroTpmSupport:               aws.String("unsupported"),
+			SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 			},
@@ -304,6 +325,9 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			BurstablePerformanceSupported: aws.Bool(false),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroEnclavesSupport:          aws.String("supported"),
+			NitroTpmSupport:               aws.String("supported"),
+			SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 			},
@@ -334,6 +358,9 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			BurstablePerformanceSupported: aws.Bool(false),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroEnclavesSupport:          aws.String("supported"),
+			NitroTpmSupport:               aws.String("supported"),
+			SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 			},
@@ -371,6 +398,9 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			BurstablePerformanceSupported: aws.Bool(false),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("xen"),
+			NitroEnclavesSupport:          aws.String("unsupported"),
+			NitroTpmSupport:               aws.String("unsupported"),
+			SupportedBootModes:            aws.StringSlice([]string{"legacy-bios"}),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 			},
@@ -413,6 +443,9 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			BurstablePerformanceSupported: aws.Bool(true),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroEnclavesSupport:          aws.String("unsupported"),
+			NitroTpmSupport:               aws.String("supported"),
+			SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 			},
@@ -443,6 +476,9 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			BurstablePerformanceSupported: aws.Bool(true),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroEnclavesSupport:          aws.String("unsupported"),
+			NitroTpmSupport:               aws.String("supported"),
+			SupportedBootModes:            aws.StringSlice([]string{"uefi"}),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"arm64"}),
 			},
@@ -473,6 +509,9 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			BurstablePerformanceSupported: aws.Bool(true),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroEnclavesSupport:          aws.String("unsupported"),
+			NitroTpmSupport:               aws.String("supported"),
+			SupportedBootModes:            aws.StringSlice([]string{"uefi"}),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"arm64"}),
 			},
@@ -503,6 +542,9 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			BurstablePerformanceSupported: aws.Bool(true),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroEnclavesSupport:          aws.String("unsupported"),
+			NitroTpmSupport:               aws.String("supported"),
+			SupportedBootModes:            aws.StringSlice([]string{"uefi"}),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"arm64"}),
 			},
@@ -533,6 +575,9 @@ var defaultDescribeInstanceTypesOutput = &ec2.DescribeInstanceTypesOutput{
 			BurstablePerformanceSupported: aws.Bool(false),
 			BareMetal:                     aws.Bool(false),
 			Hypervisor:                    aws.String("nitro"),
+			NitroEnclavesSupport:          aws.String("unsupported"),
+			NitroTpmSupport:               aws.String("supported"),
+			SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
 			ProcessorInfo: &ec2.ProcessorInfo{
 				SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 			},
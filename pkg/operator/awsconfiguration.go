@@ -0,0 +1,114 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/system"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	coreapis "github.com/aws/karpenter-core/pkg/apis"
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/operator"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+)
+
+// ResolveAWSConfiguration returns the settings of the AWSConfiguration in Karpenter's namespace, keyed the same as
+// the karpenter-global-settings ConfigMap. No settings are returned if the AWSConfiguration or its CRD doesn't exist.
+func ResolveAWSConfiguration(ctx context.Context, kubeReader client.Reader) (map[string]string, error) {
+	configuration := &v1beta1.AWSConfiguration{}
+	if err := kubeReader.Get(ctx, types.NamespacedName{Namespace: system.Namespace(), Name: v1beta1.AWSConfigurationName}, configuration); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, client.IgnoreNotFound(err)
+	}
+	data := map[string]string{}
+	if configuration.Spec.ClusterName != nil {
+		data["aws.clusterName"] = *configuration.Spec.ClusterName
+	}
+	if configuration.Spec.ClusterEndpoint != nil {
+		data["aws.clusterEndpoint"] = *configuration.Spec.ClusterEndpoint
+	}
+	if configuration.Spec.InterruptionQueueName != nil {
+		data["aws.interruptionQueueName"] = *configuration.Spec.InterruptionQueueName
+	}
+	if configuration.Spec.VMMemoryOverheadPercent != nil {
+		data["aws.vmMemoryOverheadPercent"] = *configuration.Spec.VMMemoryOverheadPercent
+	}
+	if configuration.Spec.Tags != nil {
+		data["aws.tags"] = string(lo.Must(json.Marshal(configuration.Spec.Tags)))
+	}
+	return data, nil
+}
+
+// withAWSConfiguration re-injects the settings with the AWSConfiguration taking precedence over the ConfigMap. The
+// settings are injected again into the context of each controller, so the Settings are also replaced in the settings
+// store's registrations with Settings that are overridden by the AWSConfiguration.
+func withAWSConfiguration(ctx context.Context, op *operator.Operator) (context.Context, error) {
+	overrides, err := ResolveAWSConfiguration(ctx, op.GetAPIReader())
+	if err != nil {
+		return ctx, fmt.Errorf("getting aws configuration, %w", err)
+	}
+	if len(overrides) == 0 {
+		return ctx, nil
+	}
+	overridden := &settings.Overridden{Overrides: overrides}
+	coreapis.Settings = lo.Map(coreapis.Settings, func(injectable coresettings.Injectable, _ int) coresettings.Injectable {
+		if _, ok := injectable.(*settings.Settings); ok {
+			return overridden
+		}
+		return injectable
+	})
+	cm, err := op.KubernetesInterface.CoreV1().ConfigMaps(system.Namespace()).Get(ctx, overridden.ConfigMap(), metav1.GetOptions{})
+	if err != nil {
+		return ctx, fmt.Errorf("getting settings, %w", err)
+	}
+	return overridden.Inject(ctx, cm)
+}
+
+// newAWSConfigurationCache creates the cache that the AWSConfiguration is watched with. Karpenter is only permitted to
+// read the AWSConfiguration in its own namespace, so it isn't read from the manager's cache. No cache is created if the
+// AWSConfiguration CRD doesn't exist, in the same way that no settings are resolved from it.
+func newAWSConfigurationCache(op *operator.Operator) (cache.Cache, error) {
+	gvk, err := apiutil.GVKForObject(&v1beta1.AWSConfiguration{}, op.GetScheme())
+	if err != nil {
+		return nil, err
+	}
+	if _, err = op.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getting rest mapping, %w", err)
+	}
+	c, err := cache.New(op.GetConfig(), cache.Options{Scheme: op.GetScheme(), Mapper: op.GetRESTMapper(), Namespace: system.Namespace()})
+	if err != nil {
+		return nil, fmt.Errorf("creating cache, %w", err)
+	}
+	if err = op.Add(c); err != nil {
+		return nil, fmt.Errorf("starting cache, %w", err)
+	}
+	return c, nil
+}
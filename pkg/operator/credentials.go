@@ -0,0 +1,101 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/defaults"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"knative.dev/pkg/logging"
+
+	"github.com/aws/karpenter/pkg/apis/settings"
+)
+
+// The environment variables that EKS injects into pods for IRSA and EKS Pod Identity
+const (
+	roleARNEnvVar                = "AWS_ROLE_ARN"
+	webIdentityTokenFileEnvVar   = "AWS_WEB_IDENTITY_TOKEN_FILE"
+	roleSessionNameEnvVar        = "AWS_ROLE_SESSION_NAME"
+	containerCredentialsEnvVar   = "AWS_CONTAINER_CREDENTIALS_FULL_URI"
+	containerAuthTokenFileEnvVar = "AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"
+)
+
+// NewCredentials returns the credentials of aws.credentialsSource, or nil if the SDK's default chain should resolve
+// them. The default chain tries static credentials in the environment, then IRSA, then EKS Pod Identity, and then the
+// instance role, so the other sources let a single one be required when more than one is configured.
+func NewCredentials(ctx context.Context, config *aws.Config) (*credentials.Credentials, error) {
+	switch source := settings.FromContext(ctx).CredentialsSource; source {
+	case settings.CredentialsSourceIRSA:
+		roleARN, tokenFile := os.Getenv(roleARNEnvVar), os.Getenv(webIdentityTokenFileEnvVar)
+		if roleARN == "" || tokenFile == "" {
+			return nil, fmt.Errorf("%s and %s must be set to use IRSA credentials, annotate the service account with "+
+				"eks.amazonaws.com/role-arn and restart the pod", roleARNEnvVar, webIdentityTokenFileEnvVar)
+		}
+		return stscreds.NewWebIdentityCredentials(session.Must(session.NewSession(config)), roleARN, os.Getenv(roleSessionNameEnvVar), tokenFile), nil
+	case settings.CredentialsSourcePodIdentity:
+		if os.Getenv(containerCredentialsEnvVar) == "" || os.Getenv(containerAuthTokenFileEnvVar) == "" {
+			return nil, fmt.Errorf("%s and %s must be set to use EKS Pod Identity credentials, create a pod identity "+
+				"association for the service account and restart the pod", containerCredentialsEnvVar, containerAuthTokenFileEnvVar)
+		}
+		return credentials.NewCredentials(defaults.RemoteCredProvider(*defaults.Config(), defaults.Handlers())), nil
+	case settings.CredentialsSourceInstanceRole:
+		return credentials.NewCredentials(&ec2rolecreds.EC2RoleProvider{Client: ec2metadata.New(session.Must(session.NewSession(config)))}), nil
+	default:
+		return nil, nil
+	}
+}
+
+// checkCredentials resolves the controller's credentials so that Karpenter fails to start with the steps to fix them,
+// rather than with the authorization errors of its first requests
+func checkCredentials(ctx context.Context, sess *session.Session) error {
+	value, err := sess.Config.Credentials.GetWithContext(ctx)
+	if err != nil {
+		if hint := credentialsHint(settings.FromContext(ctx).CredentialsSource, err); hint != "" {
+			return fmt.Errorf("%w, %s", err, hint)
+		}
+		return err
+	}
+	logging.FromContext(ctx).With("source", settings.FromContext(ctx).CredentialsSource, "provider", value.ProviderName).Debugf("resolved credentials")
+	return nil
+}
+
+func credentialsHint(source string, err error) string {
+	switch source {
+	case settings.CredentialsSourceIRSA:
+		return "check that the cluster has an IAM OIDC provider and that the role's trust policy allows the service account to assume it"
+	case settings.CredentialsSourcePodIdentity:
+		return "check that the EKS Pod Identity Agent add-on is running on the node and that the role's trust policy allows pods.eks.amazonaws.com to assume it"
+	case settings.CredentialsSourceInstanceRole:
+		return "check that the node has an instance profile and that IMDS is reachable from pods, which requires a hop limit of at least 2 with IMDSv2"
+	default:
+		var aerr awserr.Error
+		if errors.As(err, &aerr) && aerr.Code() == credentials.ErrNoValidProvidersFoundInChain.Code() {
+			return "no credentials were found in the environment, from IRSA, from EKS Pod Identity or from the instance role, " +
+				"annotate the service account with eks.amazonaws.com/role-arn or create a pod identity association for it, " +
+				"see https://karpenter.sh/docs/getting-started/"
+		}
+		return ""
+	}
+}
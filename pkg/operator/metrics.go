@@ -0,0 +1,110 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/aws/karpenter-core/pkg/metrics"
+)
+
+const (
+	awsSubsystem   = "aws"
+	serviceLabel   = "service"
+	operationLabel = "operation"
+	codeLabel      = "code"
+)
+
+var (
+	apiRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: awsSubsystem,
+			Name:      "api_request_duration_seconds",
+			Help:      "Duration of AWS API requests, including retries. Labeled by service and operation.",
+			Buckets:   metrics.DurationBuckets(),
+		},
+		[]string{serviceLabel, operationLabel},
+	)
+	apiRequestErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: awsSubsystem,
+			Name:      "api_request_errors",
+			Help:      "Number of AWS API requests that failed after all retries. Labeled by service, operation and error code.",
+		},
+		[]string{serviceLabel, operationLabel, codeLabel},
+	)
+	apiRequestThrottles = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: awsSubsystem,
+			Name:      "api_request_throttles",
+			Help:      "Number of attempts of AWS API requests that were throttled. Labeled by service and operation.",
+		},
+		[]string{serviceLabel, operationLabel},
+	)
+	apiRequestRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: awsSubsystem,
+			Name:      "api_request_retries",
+			Help:      "Number of times that AWS API requests were retried. Labeled by service and operation.",
+		},
+		[]string{serviceLabel, operationLabel},
+	)
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(apiRequestDuration, apiRequestErrors, apiRequestThrottles, apiRequestRetries)
+}
+
+// WithMetrics records the duration, errors, throttles and retries of every request made with the session's clients
+func WithMetrics(sess *session.Session) *session.Session {
+	sess.Handlers.CompleteAttempt.PushBackNamed(request.NamedHandler{Name: "karpenter.AttemptMetrics", Fn: recordAttempt})
+	sess.Handlers.Complete.PushBackNamed(request.NamedHandler{Name: "karpenter.RequestMetrics", Fn: recordRequest})
+	return sess
+}
+
+func recordAttempt(r *request.Request) {
+	if r.IsErrorThrottle() {
+		apiRequestThrottles.With(apiLabels(r)).Inc()
+	}
+}
+
+func recordRequest(r *request.Request) {
+	labels := apiLabels(r)
+	apiRequestDuration.With(labels).Observe(time.Since(r.Time).Seconds())
+	apiRequestRetries.With(labels).Add(float64(r.RetryCount))
+	if r.Error != nil {
+		code := "Unknown"
+		var aerr awserr.Error
+		if errors.As(r.Error, &aerr) {
+			code = aerr.Code()
+		}
+		apiRequestErrors.With(prometheus.Labels{serviceLabel: labels[serviceLabel], operationLabel: labels[operationLabel], codeLabel: code}).Inc()
+	}
+}
+
+func apiLabels(r *request.Request) prometheus.Labels {
+	return prometheus.Labels{serviceLabel: r.ClientInfo.ServiceName, operationLabel: r.Operation.Name}
+}
@@ -16,46 +16,51 @@ package operator
 
 import (
 	"context"
-	"encoding/base64"
 	"errors"
 	"fmt"
-	"net"
+	"net/http"
+	"sort"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
-	awsclient "github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
-	"github.com/aws/aws-sdk-go/aws/ec2metadata"
-	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/eks"
-	"github.com/aws/aws-sdk-go/service/eks/eksiface"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/sns"
 	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/patrickmn/go-cache"
 	"github.com/samber/lo"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/transport"
+	"go.opentelemetry.io/otel"
 	"knative.dev/pkg/logging"
-	"knative.dev/pkg/ptr"
+	"knative.dev/pkg/system"
+	crcache "sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	"github.com/aws/karpenter-core/pkg/operator"
 	"github.com/aws/karpenter/pkg/apis/settings"
 	awscache "github.com/aws/karpenter/pkg/cache"
 	"github.com/aws/karpenter/pkg/providers/amifamily"
+	"github.com/aws/karpenter/pkg/providers/ec2client"
 	"github.com/aws/karpenter/pkg/providers/instance"
+	"github.com/aws/karpenter/pkg/providers/instanceprofile"
 	"github.com/aws/karpenter/pkg/providers/instancetype"
 	"github.com/aws/karpenter/pkg/providers/launchtemplate"
+	"github.com/aws/karpenter/pkg/providers/notification"
 	"github.com/aws/karpenter/pkg/providers/pricing"
+	"github.com/aws/karpenter/pkg/providers/quota"
 	"github.com/aws/karpenter/pkg/providers/securitygroup"
+	"github.com/aws/karpenter/pkg/providers/spotadvisor"
 	"github.com/aws/karpenter/pkg/providers/subnet"
 	"github.com/aws/karpenter/pkg/providers/version"
 	"github.com/aws/karpenter/pkg/utils/project"
+	"github.com/aws/karpenter/pkg/utils/tracing"
 )
 
 // Operator is injected into the AWS CloudProvider's factories
@@ -64,6 +69,7 @@ type Operator struct {
 
 	Session                   *session.Session
 	UnavailableOfferingsCache *awscache.UnavailableOfferings
+	ObservedMemoryCapacities  *awscache.ObservedMemoryCapacities
 	EC2API                    ec2iface.EC2API
 	SubnetProvider            *subnet.Provider
 	SecurityGroupProvider     *securitygroup.Provider
@@ -71,45 +77,40 @@ type Operator struct {
 	AMIResolver               *amifamily.Resolver
 	LaunchTemplateProvider    *launchtemplate.Provider
 	PricingProvider           *pricing.Provider
+	SpotAdvisorProvider       *spotadvisor.Provider
 	InstanceTypesProvider     *instancetype.Provider
 	InstanceProvider          *instance.Provider
+	QuotaProvider             *quota.Provider
+	InstanceProfileProvider   *instanceprofile.Provider
+	NotificationProvider      *notification.Provider
+	AWSConfigurationCache     crcache.Cache
 }
 
 func NewOperator(ctx context.Context, operator *operator.Operator) (context.Context, *Operator) {
-	config := &aws.Config{
-		STSRegionalEndpoint: endpoints.RegionalSTSEndpoint,
+	ctx, err := withAWSConfiguration(ctx, operator)
+	if err != nil {
+		logging.FromContext(ctx).Fatalf("resolving aws configuration, %s", err)
 	}
-
-	if assumeRoleARN := settings.FromContext(ctx).AssumeRoleARN; assumeRoleARN != "" {
-		config.Credentials = stscreds.NewCredentials(session.Must(session.NewSession()), assumeRoleARN,
-			func(provider *stscreds.AssumeRoleProvider) { setDurationAndExpiry(ctx, provider) })
+	sess, err := newSession(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Fatalf("creating aws session, %s", err)
 	}
-
-	sess := withUserAgent(session.Must(session.NewSession(
-		request.WithRetryer(
-			config,
-			awsclient.DefaultRetryer{NumMaxRetries: awsclient.DefaultRetryerMaxNumRetries},
-		),
-	)))
-
-	if *sess.Config.Region == "" {
-		logging.FromContext(ctx).Debug("retrieving region from IMDS")
-		region, err := ec2metadata.New(sess).Region()
-		*sess.Config.Region = lo.Must(region, err, "failed to get region from metadata server")
+	if err := checkCredentials(ctx, sess); err != nil {
+		logging.FromContext(ctx).Fatalf("resolving aws credentials, %s", err)
 	}
 	ec2api := ec2.New(sess)
 	if err := checkEC2Connectivity(ctx, ec2api); err != nil {
 		logging.FromContext(ctx).Fatalf("Checking EC2 API connectivity, %s", err)
 	}
 	logging.FromContext(ctx).With("region", *sess.Config.Region).Debugf("discovered region")
-	clusterEndpoint, err := ResolveClusterEndpoint(ctx, eks.New(sess))
+	clusterEndpoint, err := launchtemplate.ResolveClusterEndpoint(ctx, eks.New(sess))
 	if err != nil {
 		logging.FromContext(ctx).Fatalf("unable to detect the cluster endpoint, %s", err)
 	} else {
 		logging.FromContext(ctx).With("cluster-endpoint", clusterEndpoint).Debugf("discovered cluster endpoint")
 	}
 	// We perform best-effort on resolving the kube-dns IP
-	kubeDNSIP, err := kubeDNSIP(ctx, operator.KubernetesInterface)
+	kubeDNSIP, err := launchtemplate.ResolveKubeDNSIP(ctx, operator.GetAPIReader())
 	if err != nil {
 		// If we fail to get the kube-dns IP, we don't want to crash because this causes issues with custom DNS setups
 		// https://github.com/aws/karpenter/issues/2787
@@ -117,18 +118,40 @@ func NewOperator(ctx context.Context, operator *operator.Operator) (context.Cont
 	} else {
 		logging.FromContext(ctx).With("kube-dns-ip", kubeDNSIP).Debugf("discovered kube dns")
 	}
+	if endpoint := settings.FromContext(ctx).TracingEndpoint; endpoint != "" {
+		tracerProvider, err := tracing.NewTracerProvider(ctx, endpoint)
+		if err != nil {
+			logging.FromContext(ctx).Fatalf("creating tracer provider, %s", err)
+		}
+		otel.SetTracerProvider(tracerProvider)
+		// The spans that are still batched are exported when the operator stops
+		lo.Must0(operator.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			return tracerProvider.Shutdown(shutdownCtx)
+		})))
+		logging.FromContext(ctx).With("tracing-endpoint", endpoint).Debugf("exporting traces")
+	}
 
 	unavailableOfferingsCache := awscache.NewUnavailableOfferings()
-	subnetProvider := subnet.NewProvider(ec2api, cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval))
-	securityGroupProvider := securitygroup.NewProvider(ec2api, cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval))
+	observedMemoryCapacities := awscache.NewObservedMemoryCapacities()
+	ec2clientProvider := ec2client.NewProvider(ec2api, func(roleARN string) ec2iface.EC2API {
+		// The role is assumed with the controller's credentials, which may themselves be assumed with aws.assumeRoleARN
+		return ec2.New(sess, &aws.Config{Credentials: stscreds.NewCredentials(sess, roleARN,
+			func(provider *stscreds.AssumeRoleProvider) { setAssumeRoleOptions(ctx, provider) })})
+	})
+	subnetProvider := subnet.NewProvider(ec2clientProvider, cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval))
+	securityGroupProvider := securitygroup.NewProvider(ec2clientProvider, cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval))
 	pricingProvider := pricing.NewProvider(
 		ctx,
 		pricing.NewAPI(sess, *sess.Config.Region),
 		ec2api,
 		*sess.Config.Region,
 	)
-	versionProvider := version.NewProvider(operator.KubernetesInterface, cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval))
-	amiProvider := amifamily.NewProvider(versionProvider, ssm.New(sess), ec2api, cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval))
+	spotAdvisorProvider := spotadvisor.NewProvider(spotadvisor.NewAPI(&http.Client{Timeout: 30 * time.Second}), *sess.Config.Region)
+	versionProvider := version.NewProvider(operator.KubernetesInterface, eks.New(sess), cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval))
+	amiProvider := amifamily.NewProvider(versionProvider, ssm.New(sess), ec2api, ec2clientProvider, cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval))
 	amiResolver := amifamily.New(amiProvider)
 	launchTemplateProvider := launchtemplate.NewProvider(
 		ctx,
@@ -137,10 +160,12 @@ func NewOperator(ctx context.Context, operator *operator.Operator) (context.Cont
 		amiResolver,
 		securityGroupProvider,
 		subnetProvider,
-		lo.Must(getCABundle(ctx, operator.GetConfig())),
+		lo.Must(launchtemplate.ResolveCABundle(ctx, operator.GetConfig())),
 		operator.Elected(),
 		kubeDNSIP,
 		clusterEndpoint,
+		operator.KubernetesInterface,
+		system.Namespace(),
 	)
 	instanceTypeProvider := instancetype.NewProvider(
 		*sess.Config.Region,
@@ -148,8 +173,13 @@ func NewOperator(ctx context.Context, operator *operator.Operator) (context.Cont
 		ec2api,
 		subnetProvider,
 		unavailableOfferingsCache,
+		observedMemoryCapacities,
 		pricingProvider,
+		spotAdvisorProvider,
 	)
+	instanceProfileProvider := instanceprofile.NewProvider(*sess.Config.Region, iam.New(sess), cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval))
+	quotaProvider := quota.NewProvider(servicequotas.New(sess), ec2api, cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval))
+	notificationProvider := notification.NewProvider(sns.New(sess), &http.Client{Timeout: 10 * time.Second}, cache.New(awscache.PreTerminationNotificationTTL, awscache.DefaultCleanupInterval))
 	instanceProvider := instance.NewProvider(
 		ctx,
 		aws.StringValue(sess.Config.Region),
@@ -158,12 +188,34 @@ func NewOperator(ctx context.Context, operator *operator.Operator) (context.Cont
 		instanceTypeProvider,
 		subnetProvider,
 		launchTemplateProvider,
+		cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval),
+		cache.New(awscache.SpotPlacementScoresTTL, awscache.DefaultCleanupInterval),
 	)
 
+	if interval := settings.FromContext(ctx).StandbyRefreshInterval; interval > 0 {
+		refreshers := []func(context.Context) error{
+			instanceTypeProvider.UpdateInstanceTypes,
+			func(ctx context.Context) error {
+				_, err := instanceTypeProvider.UpdateInstanceTypeOfferings(ctx)
+				return err
+			},
+		}
+		if !settings.FromContext(ctx).IsolatedVPC {
+			refreshers = append(refreshers, pricingProvider.UpdateOnDemandPricing, pricingProvider.UpdateSpotPricing, spotAdvisorProvider.Update)
+		}
+		lo.Must0(operator.Add(NewStandby(operator.Elected(), interval, refreshers...)))
+	}
+
+	awsConfigurationCache, err := newAWSConfigurationCache(operator)
+	if err != nil {
+		logging.FromContext(ctx).Fatalf("creating aws configuration cache, %s", err)
+	}
+
 	return ctx, &Operator{
 		Operator:                  operator,
 		Session:                   sess,
 		UnavailableOfferingsCache: unavailableOfferingsCache,
+		ObservedMemoryCapacities:  observedMemoryCapacities,
 		EC2API:                    ec2api,
 		SubnetProvider:            subnetProvider,
 		SecurityGroupProvider:     securityGroupProvider,
@@ -171,8 +223,13 @@ func NewOperator(ctx context.Context, operator *operator.Operator) (context.Cont
 		AMIResolver:               amiResolver,
 		LaunchTemplateProvider:    launchTemplateProvider,
 		PricingProvider:           pricingProvider,
+		SpotAdvisorProvider:       spotAdvisorProvider,
 		InstanceTypesProvider:     instanceTypeProvider,
 		InstanceProvider:          instanceProvider,
+		QuotaProvider:             quotaProvider,
+		InstanceProfileProvider:   instanceProfileProvider,
+		NotificationProvider:      notificationProvider,
+		AWSConfigurationCache:     awsConfigurationCache,
 	}
 }
 
@@ -194,55 +251,20 @@ func checkEC2Connectivity(ctx context.Context, api *ec2.EC2) error {
 	return err
 }
 
-func ResolveClusterEndpoint(ctx context.Context, eksAPI eksiface.EKSAPI) (string, error) {
-	clusterEndpointFromSettings := settings.FromContext(ctx).ClusterEndpoint
-	if clusterEndpointFromSettings != "" {
-		return clusterEndpointFromSettings, nil // cluster endpoint is explicitly set
-	}
-	out, err := eksAPI.DescribeCluster(&eks.DescribeClusterInput{
-		Name: aws.String(settings.FromContext(ctx).ClusterName),
-	})
-	if err != nil {
-		return "", fmt.Errorf("failed to resolve cluster endpoint, %w", err)
-	}
-	return *out.Cluster.Endpoint, nil
-}
-
-func getCABundle(ctx context.Context, restConfig *rest.Config) (*string, error) {
-	// Discover CA Bundle from the REST client. We could alternatively
-	// have used the simpler client-go InClusterConfig() method.
-	// However, that only works when Karpenter is running as a Pod
-	// within the same cluster it's managing.
-	if caBundle := settings.FromContext(ctx).ClusterCABundle; caBundle != "" {
-		return lo.ToPtr(caBundle), nil
-	}
-	transportConfig, err := restConfig.TransportConfig()
-	if err != nil {
-		return nil, fmt.Errorf("discovering caBundle, loading transport config, %w", err)
-	}
-	_, err = transport.TLSConfigFor(transportConfig) // fills in CAData!
-	if err != nil {
-		return nil, fmt.Errorf("discovering caBundle, loading TLS config, %w", err)
-	}
-	return ptr.String(base64.StdEncoding.EncodeToString(transportConfig.TLS.CAData)), nil
-}
-
-func kubeDNSIP(ctx context.Context, kubernetesInterface kubernetes.Interface) (net.IP, error) {
-	if kubernetesInterface == nil {
-		return nil, fmt.Errorf("no K8s client provided")
+// setAssumeRoleOptions configures the AssumeRole requests of aws.assumeRoleARN and of NodeClass roles alike, so that an
+// external ID, session tags or source identity required by a role's trust policy are always sent
+func setAssumeRoleOptions(ctx context.Context, provider *stscreds.AssumeRoleProvider) {
+	provider.Duration = settings.FromContext(ctx).AssumeRoleDuration
+	provider.ExpiryWindow = time.Duration(10) * time.Second
+	if externalID := settings.FromContext(ctx).AssumeRoleExternalID; externalID != "" {
+		provider.ExternalID = aws.String(externalID)
 	}
-	dnsService, err := kubernetesInterface.CoreV1().Services("kube-system").Get(ctx, "kube-dns", metav1.GetOptions{})
-	if err != nil {
-		return nil, err
+	if sourceIdentity := settings.FromContext(ctx).AssumeRoleSourceIdentity; sourceIdentity != "" {
+		provider.SourceIdentity = aws.String(sourceIdentity)
 	}
-	kubeDNSIP := net.ParseIP(dnsService.Spec.ClusterIP)
-	if kubeDNSIP == nil {
-		return nil, fmt.Errorf("parsing cluster IP")
+	tags := settings.FromContext(ctx).AssumeRoleSessionTags
+	for _, key := range lo.Keys(tags) {
+		provider.Tags = append(provider.Tags, &sts.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
 	}
-	return kubeDNSIP, nil
-}
-
-func setDurationAndExpiry(ctx context.Context, provider *stscreds.AssumeRoleProvider) {
-	provider.Duration = settings.FromContext(ctx).AssumeRoleDuration
-	provider.ExpiryWindow = time.Duration(10) * time.Second
+	sort.Slice(provider.Tags, func(i, j int) bool { return *provider.Tags[i].Key < *provider.Tags[j].Key })
 }
@@ -0,0 +1,115 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsclient "github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/samber/lo"
+	"knative.dev/pkg/logging"
+
+	"github.com/aws/karpenter/pkg/apis/settings"
+)
+
+// endpointsIDs maps the services of aws.endpoints to the endpoints IDs that the SDK resolves endpoints for
+var endpointsIDs = map[string]string{
+	"ec2":           ec2.EndpointsID,
+	"eks":           eks.EndpointsID,
+	"iam":           iam.EndpointsID,
+	"pricing":       pricing.EndpointsID,
+	"servicequotas": servicequotas.EndpointsID,
+	"sns":           sns.EndpointsID,
+	"sqs":           sqs.EndpointsID,
+	"ssm":           ssm.EndpointsID,
+	"sts":           sts.EndpointsID,
+}
+
+// newSession creates the session of the controller's region, which is discovered from IMDS if it isn't configured.
+// Credentials are resolved from aws.credentialsSource, and then used to assume aws.assumeRoleARN if it's set.
+func newSession(ctx context.Context) (*session.Session, error) {
+	config := newConfig(ctx)
+	creds, err := NewCredentials(ctx, newConfig(ctx))
+	if err != nil {
+		return nil, err
+	}
+	config.Credentials = creds
+
+	if assumeRoleARN := settings.FromContext(ctx).AssumeRoleARN; assumeRoleARN != "" {
+		config.Credentials = stscreds.NewCredentials(session.Must(session.NewSession(newConfig(ctx).WithCredentials(creds))), assumeRoleARN,
+			func(provider *stscreds.AssumeRoleProvider) { setAssumeRoleOptions(ctx, provider) })
+	}
+
+	sess := withUserAgent(session.Must(session.NewSession(
+		request.WithRetryer(
+			config,
+			awsclient.DefaultRetryer{NumMaxRetries: awsclient.DefaultRetryerMaxNumRetries},
+		),
+	)))
+	sess = WithMetrics(sess)
+
+	if *sess.Config.Region == "" {
+		logging.FromContext(ctx).Debug("retrieving region from IMDS")
+		region, err := ec2metadata.New(sess).Region()
+		*sess.Config.Region = lo.Must(region, err, "failed to get region from metadata server")
+	}
+	return sess, nil
+}
+
+// newConfig configures the endpoints that the session resolves. FIPS and dual-stack endpoints are used for every
+// service when they're enabled, and the endpoints of aws.endpoints, such as VPC endpoints, replace the endpoints of
+// their services.
+func newConfig(ctx context.Context) *aws.Config {
+	config := &aws.Config{
+		STSRegionalEndpoint: endpoints.RegionalSTSEndpoint,
+		// Report why each credentials provider failed when none of them resolve credentials
+		CredentialsChainVerboseErrors: aws.Bool(true),
+	}
+	if settings.FromContext(ctx).UseFIPSEndpoint {
+		config.UseFIPSEndpoint = endpoints.FIPSEndpointStateEnabled
+	}
+	if settings.FromContext(ctx).UseDualStackEndpoint {
+		config.UseDualStackEndpoint = endpoints.DualStackEndpointStateEnabled
+	}
+	if len(settings.FromContext(ctx).Endpoints) > 0 {
+		config.EndpointResolver = newEndpointResolver(settings.FromContext(ctx).Endpoints)
+	}
+	return config
+}
+
+func newEndpointResolver(overrides map[string]string) endpoints.Resolver {
+	urls := lo.MapKeys(overrides, func(_ string, service string) string { return endpointsIDs[service] })
+	return endpoints.ResolverFunc(func(service, region string, opts ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
+		if url, ok := urls[service]; ok {
+			return endpoints.ResolvedEndpoint{URL: url, SigningRegion: region}, nil
+		}
+		return endpoints.DefaultResolver().EndpointFor(service, region, opts...)
+	})
+}
@@ -0,0 +1,69 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"knative.dev/pkg/logging"
+)
+
+// Standby keeps the caches of a replica that isn't the leader warm, so that it doesn't start from cold caches once
+// it's elected. The leader refreshes the same caches with its controllers, so Standby stops once the replica is
+// elected. Standby refreshes at a longer interval than the leader so that standby replicas add little AWS API load.
+type Standby struct {
+	elected    <-chan struct{}
+	interval   time.Duration
+	refreshers []func(context.Context) error
+}
+
+func NewStandby(elected <-chan struct{}, interval time.Duration, refreshers ...func(context.Context) error) *Standby {
+	return &Standby{
+		elected:    elected,
+		interval:   interval,
+		refreshers: refreshers,
+	}
+}
+
+// NeedLeaderElection is false so that Standby runs on the replicas that aren't the leader
+func (s *Standby) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *Standby) Start(ctx context.Context) error {
+	for {
+		select {
+		case <-s.elected:
+			return nil
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+		for _, refresh := range s.refreshers {
+			if err := refresh(ctx); err != nil {
+				logging.FromContext(ctx).Errorf("refreshing standby caches, %s", err)
+			}
+		}
+		select {
+		case <-s.elected:
+			return nil
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait.Jitter(s.interval, 0.2)):
+		}
+	}
+}
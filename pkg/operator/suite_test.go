@@ -16,18 +16,32 @@ package operator_test
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 
-	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	prometheusmodel "github.com/prometheus/client_model/go"
 	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	. "knative.dev/pkg/logging/testing"
+	"knative.dev/pkg/system"
 
 	"github.com/aws/karpenter/pkg/apis"
 	"github.com/aws/karpenter/pkg/apis/settings"
-	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
 	awscontext "github.com/aws/karpenter/pkg/operator"
 	"github.com/aws/karpenter/pkg/test"
 
@@ -40,7 +54,6 @@ import (
 var ctx context.Context
 var stop context.CancelFunc
 var env *coretest.Environment
-var fakeEKSAPI *fake.EKSAPI
 
 func TestAWS(t *testing.T) {
 	ctx = TestContextWithLogger(t)
@@ -53,8 +66,6 @@ var _ = BeforeSuite(func() {
 	ctx = coresettings.ToContext(ctx, coretest.Settings())
 	ctx = settings.ToContext(ctx, test.Settings())
 	ctx, stop = context.WithCancel(ctx)
-
-	fakeEKSAPI = &fake.EKSAPI{}
 })
 
 var _ = AfterSuite(func() {
@@ -62,49 +73,188 @@ var _ = AfterSuite(func() {
 	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
 })
 
-var _ = BeforeEach(func() {
-	fakeEKSAPI.Reset()
-})
-
 var _ = AfterEach(func() {
+	ctx = settings.ToContext(ctx, test.Settings())
 	ExpectCleanedUp(ctx, env.Client)
 })
 
 var _ = Describe("Operator", func() {
-
-	It("should resolve endpoint if set via configuration", func() {
-		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
-			ClusterEndpoint: lo.ToPtr("https://api.test-cluster.k8s.local"),
-		}))
-		endpoint, err := awscontext.ResolveClusterEndpoint(ctx, fakeEKSAPI)
+	It("should resolve settings from the AWSConfiguration", func() {
+		ExpectApplied(ctx, env.Client, &v1beta1.AWSConfiguration{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      v1beta1.AWSConfigurationName,
+				Namespace: system.Namespace(),
+			},
+			Spec: v1beta1.AWSConfigurationSpec{
+				ClusterName:             lo.ToPtr("my-cluster"),
+				VMMemoryOverheadPercent: lo.ToPtr("0.1"),
+				Tags:                    map[string]string{"team": "my-team"},
+			},
+		})
+		overrides, err := awscontext.ResolveAWSConfiguration(ctx, env.Client)
 		Expect(err).ToNot(HaveOccurred())
-		Expect(endpoint).To(Equal("https://api.test-cluster.k8s.local"))
-	})
-
-	It("should resolve endpoint if not set, via call to API", func() {
-		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
-			ClusterEndpoint: lo.ToPtr(""),
+		Expect(overrides).To(Equal(map[string]string{
+			"aws.clusterName":             "my-cluster",
+			"aws.vmMemoryOverheadPercent": "0.1",
+			"aws.tags":                    `{"team":"my-team"}`,
 		}))
-		fakeEKSAPI.DescribeClusterBehaviour.Output.Set(
-			&eks.DescribeClusterOutput{
-				Cluster: &eks.Cluster{
-					Endpoint: lo.ToPtr("https://cluster-endpoint.test-cluster.k8s.local"),
-				},
-			},
-		)
+	})
 
-		endpoint, err := awscontext.ResolveClusterEndpoint(ctx, fakeEKSAPI)
+	It("should resolve no settings if the AWSConfiguration doesn't exist", func() {
+		overrides, err := awscontext.ResolveAWSConfiguration(ctx, env.Client)
 		Expect(err).ToNot(HaveOccurred())
-		Expect(endpoint).To(Equal("https://cluster-endpoint.test-cluster.k8s.local"))
+		Expect(overrides).To(BeEmpty())
 	})
 
-	It("should propagate error if API fails", func() {
-		ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
-			ClusterEndpoint: lo.ToPtr(""),
-		}))
-		fakeEKSAPI.DescribeClusterBehaviour.Error.Set(errors.New("test error"))
-
-		_, err := awscontext.ResolveClusterEndpoint(ctx, fakeEKSAPI)
-		Expect(err).To(HaveOccurred())
+	Context("Standby", func() {
+		It("should refresh the caches until the replica is elected", func() {
+			elected := make(chan struct{})
+			var refreshes atomic.Int64
+			standby := awscontext.NewStandby(elected, time.Millisecond, func(context.Context) error {
+				refreshes.Add(1)
+				return nil
+			})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				Expect(standby.Start(ctx)).To(Succeed())
+			}()
+			Eventually(refreshes.Load).Should(BeNumerically(">=", 2))
+			close(elected)
+			Eventually(done).Should(BeClosed())
+		})
+		It("should keep refreshing the caches after a refresh fails", func() {
+			elected := make(chan struct{})
+			var refreshes atomic.Int64
+			standby := awscontext.NewStandby(elected, time.Millisecond, func(context.Context) error {
+				refreshes.Add(1)
+				return fmt.Errorf("failed")
+			})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				Expect(standby.Start(ctx)).To(Succeed())
+			}()
+			Eventually(refreshes.Load).Should(BeNumerically(">=", 2))
+			close(elected)
+			Eventually(done).Should(BeClosed())
+		})
+		It("should not refresh the caches of the leader", func() {
+			elected := make(chan struct{})
+			close(elected)
+			var refreshes atomic.Int64
+			standby := awscontext.NewStandby(elected, time.Millisecond, func(context.Context) error {
+				refreshes.Add(1)
+				return nil
+			})
+			Expect(standby.Start(ctx)).To(Succeed())
+			Expect(refreshes.Load()).To(BeZero())
+		})
+		It("should not need leader election", func() {
+			Expect(awscontext.NewStandby(make(chan struct{}), time.Minute).NeedLeaderElection()).To(BeFalse())
+		})
+	})
+	Context("Credentials", func() {
+		It("should use the default chain when the credentials source is auto", func() {
+			creds, err := awscontext.NewCredentials(ctx, &aws.Config{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(creds).To(BeNil())
+		})
+		It("should fail when IRSA is required but the service account isn't annotated", func() {
+			GinkgoT().Setenv("AWS_ROLE_ARN", "")
+			GinkgoT().Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "")
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{CredentialsSource: lo.ToPtr(settings.CredentialsSourceIRSA)}))
+			_, err := awscontext.NewCredentials(ctx, &aws.Config{})
+			Expect(err).To(MatchError(ContainSubstring("eks.amazonaws.com/role-arn")))
+		})
+		It("should fail when EKS Pod Identity is required but the service account isn't associated", func() {
+			GinkgoT().Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", "")
+			GinkgoT().Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE", "")
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{CredentialsSource: lo.ToPtr(settings.CredentialsSourcePodIdentity)}))
+			_, err := awscontext.NewCredentials(ctx, &aws.Config{})
+			Expect(err).To(MatchError(ContainSubstring("pod identity association")))
+		})
+		It("should get credentials from the EKS Pod Identity Agent", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Header.Get("Authorization")).To(Equal("service-account-token"))
+				fmt.Fprintf(w, `{"AccessKeyId":"AKID","SecretAccessKey":"SECRET","Token":"TOKEN","Expiration":%q}`, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+			}))
+			defer server.Close()
+			tokenFile := filepath.Join(GinkgoT().TempDir(), "token")
+			Expect(os.WriteFile(tokenFile, []byte("service-account-token"), 0600)).To(Succeed())
+			GinkgoT().Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", server.URL)
+			GinkgoT().Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE", tokenFile)
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{CredentialsSource: lo.ToPtr(settings.CredentialsSourcePodIdentity)}))
+			creds, err := awscontext.NewCredentials(ctx, &aws.Config{})
+			Expect(err).ToNot(HaveOccurred())
+			value, err := creds.GetWithContext(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value.AccessKeyID).To(Equal("AKID"))
+			Expect(value.SessionToken).To(Equal("TOKEN"))
+		})
+	})
+	Context("Metrics", func() {
+		// ec2api returns an EC2 client that sends its requests to a server that fails with the given errors in turn
+		// before succeeding
+		ec2api := func(errors ...ec2Error) *ec2.EC2 {
+			var requests atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if i := int(requests.Add(1)) - 1; i < len(errors) {
+					w.WriteHeader(errors[i].status)
+					fmt.Fprintf(w, `<Response><Errors><Error><Code>%s</Code><Message>failed</Message></Error></Errors><RequestID>test</RequestID></Response>`, errors[i].code)
+					return
+				}
+				fmt.Fprint(w, `<DescribeInstancesResponse><requestId>test</requestId><reservationSet/></DescribeInstancesResponse>`)
+			}))
+			DeferCleanup(server.Close)
+			sess := awscontext.WithMetrics(session.Must(session.NewSession(request.WithRetryer(&aws.Config{
+				Region:      aws.String("us-west-2"),
+				Endpoint:    aws.String(server.URL),
+				Credentials: credentials.NewStaticCredentials("AKID", "SECRET", ""),
+			}, client.DefaultRetryer{NumMaxRetries: 2, MinRetryDelay: time.Millisecond, MaxRetryDelay: time.Millisecond,
+				MinThrottleDelay: time.Millisecond, MaxThrottleDelay: time.Millisecond}))))
+			return ec2.New(sess)
+		}
+		It("should record the throttles and retries of requests that succeed", func() {
+			_, err := ec2api(ec2Error{http.StatusServiceUnavailable, "RequestLimitExceeded"}).DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{})
+			Expect(err).ToNot(HaveOccurred())
+			labels := map[string]string{"service": "ec2", "operation": "DescribeInstances"}
+			Expect(expectMetricValue("karpenter_aws_api_request_throttles", labels).GetCounter().GetValue()).To(BeNumerically("==", 1))
+			Expect(expectMetricValue("karpenter_aws_api_request_retries", labels).GetCounter().GetValue()).To(BeNumerically("==", 1))
+			Expect(expectMetricValue("karpenter_aws_api_request_duration_seconds", labels).GetHistogram().GetSampleCount()).To(BeNumerically("==", 1))
+			_, ok := FindMetricWithLabelValues("karpenter_aws_api_request_errors", labels)
+			Expect(ok).To(BeFalse())
+		})
+		It("should record the error code of requests that fail after all retries", func() {
+			_, err := ec2api(
+				ec2Error{http.StatusInternalServerError, "InternalError"},
+				ec2Error{http.StatusInternalServerError, "InternalError"},
+				ec2Error{http.StatusInternalServerError, "InternalError"},
+			).DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{})
+			Expect(err).To(HaveOccurred())
+			labels := map[string]string{"service": "ec2", "operation": "DescribeSubnets"}
+			Expect(expectMetricValue("karpenter_aws_api_request_retries", labels).GetCounter().GetValue()).To(BeNumerically("==", 2))
+			Expect(expectMetricValue("karpenter_aws_api_request_errors", lo.Assign(labels, map[string]string{"code": "InternalError"})).GetCounter().GetValue()).To(BeNumerically("==", 1))
+			_, ok := FindMetricWithLabelValues("karpenter_aws_api_request_throttles", labels)
+			Expect(ok).To(BeFalse())
+		})
+		It("should record the error code of requests that fail without being retried", func() {
+			_, err := ec2api(ec2Error{http.StatusForbidden, "UnauthorizedOperation"}).DescribeImagesWithContext(ctx, &ec2.DescribeImagesInput{})
+			Expect(err).To(HaveOccurred())
+			labels := map[string]string{"service": "ec2", "operation": "DescribeImages"}
+			Expect(expectMetricValue("karpenter_aws_api_request_retries", labels).GetCounter().GetValue()).To(BeNumerically("==", 0))
+			Expect(expectMetricValue("karpenter_aws_api_request_errors", lo.Assign(labels, map[string]string{"code": "UnauthorizedOperation"})).GetCounter().GetValue()).To(BeNumerically("==", 1))
+		})
 	})
 })
+
+type ec2Error struct {
+	status int
+	code   string
+}
+
+func expectMetricValue(name string, labels map[string]string) *prometheusmodel.Metric {
+	metric, ok := FindMetricWithLabelValues(name, labels)
+	ExpectWithOffset(1, ok).To(BeTrue(), "expected metric %s with labels %v", name, labels)
+	return metric
+}
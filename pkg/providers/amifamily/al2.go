@@ -36,7 +36,8 @@ type AL2 struct {
 }
 
 // DefaultAMIs returns the AMI name, and Requirements, with an SSM query
-func (a AL2) DefaultAMIs(version string, isNodeTemplate bool) []DefaultAMIOutput {
+func (a AL2) DefaultAMIs(version string, isNodeTemplate bool, nvidiaDriver string) []DefaultAMIOutput {
+	gpuVariant := lo.Ternary(nvidiaDriver == v1beta1.NVIDIADriverOpen, "amazon-linux-2-gpu-open", "amazon-linux-2-gpu")
 	return []DefaultAMIOutput{
 		{
 			Query: fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2/recommended/image_id", version),
@@ -47,14 +48,14 @@ func (a AL2) DefaultAMIs(version string, isNodeTemplate bool) []DefaultAMIOutput
 			),
 		},
 		{
-			Query: fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2-gpu/recommended/image_id", version),
+			Query: fmt.Sprintf("/aws/service/eks/optimized-ami/%s/%s/recommended/image_id", version, gpuVariant),
 			Requirements: scheduling.NewRequirements(
 				scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, corev1beta1.ArchitectureAmd64),
 				scheduling.NewRequirement(lo.Ternary(isNodeTemplate, v1alpha1.LabelInstanceGPUCount, v1beta1.LabelInstanceGPUCount), v1.NodeSelectorOpExists),
 			),
 		},
 		{
-			Query: fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2-gpu/recommended/image_id", version),
+			Query: fmt.Sprintf("/aws/service/eks/optimized-ami/%s/%s/recommended/image_id", version, gpuVariant),
 			Requirements: scheduling.NewRequirements(
 				scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, corev1beta1.ArchitectureAmd64),
 				scheduling.NewRequirement(lo.Ternary(isNodeTemplate, v1alpha1.LabelInstanceAcceleratorCount, v1beta1.LabelInstanceAcceleratorCount), v1.NodeSelectorOpExists),
@@ -81,7 +82,11 @@ func (a AL2) UserData(kubeletConfig *corev1beta1.KubeletConfiguration, taints []
 		containerRuntime = kubeletConfig.ContainerRuntime
 	}
 	return bootstrap.EKS{
-		ContainerRuntime: *containerRuntime,
+		ContainerRuntime:    *containerRuntime,
+		ContainerRegistries: a.Options.ContainerRegistries,
+		KubeletConfigFile:   true,
+		PostBootstrap:       a.Options.PostBootstrapUserData,
+		Compress:            a.Options.CompressUserData,
 		Options: bootstrap.Options{
 			ClusterName:             a.Options.ClusterName,
 			ClusterEndpoint:         a.Options.ClusterEndpoint,
@@ -91,16 +96,14 @@ func (a AL2) UserData(kubeletConfig *corev1beta1.KubeletConfiguration, taints []
 			Labels:                  labels,
 			CABundle:                caBundle,
 			CustomUserData:          customUserData,
+			NodeClassKubeletConfig:  a.Options.NodeClassKubeletConfig,
 		},
 	}
 }
 
 // DefaultBlockDeviceMappings returns the default block device mappings for the AMI Family
 func (a AL2) DefaultBlockDeviceMappings() []*v1beta1.BlockDeviceMapping {
-	return []*v1beta1.BlockDeviceMapping{{
-		DeviceName: a.EphemeralBlockDevice(),
-		EBS:        &DefaultEBS,
-	}}
+	return v1beta1.DefaultBlockDeviceMappings(v1beta1.AMIFamilyAL2)
 }
 
 func (a AL2) EphemeralBlockDevice() *string {
@@ -17,6 +17,7 @@ package amifamily
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -36,6 +37,8 @@ import (
 	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	awscache "github.com/aws/karpenter/pkg/cache"
+	"github.com/aws/karpenter/pkg/providers/ec2client"
 	"github.com/aws/karpenter/pkg/providers/version"
 
 	"github.com/aws/karpenter-core/pkg/cloudprovider"
@@ -44,12 +47,34 @@ import (
 	"github.com/aws/karpenter-core/pkg/utils/pretty"
 )
 
+var (
+	// windowsServerVersionRegex matches the Windows Server version in the names of the EKS optimized Windows AMIs, e.g.
+	// Windows_Server-2022-English-Core-EKS_Optimized-1.27-2023.09.12
+	windowsServerVersionRegex = regexp.MustCompile(`^Windows_Server-(2019|2022)-`)
+	windowsBuilds             = map[string]string{
+		v1beta1.Windows2019: v1beta1.Windows2019Build,
+		v1beta1.Windows2022: v1beta1.Windows2022Build,
+	}
+	// kubeletVersionRegex matches the Kubernetes version in the names of the EKS optimized AMIs, e.g.
+	// amazon-eks-node-1.27-v20230607, amazon-eks-node-al2023-x86_64-standard-1.29-v20240117,
+	// bottlerocket-aws-k8s-1.27-x86_64-v1.14.1-7d3a7f9c, ubuntu-eks/k8s_1.27/images/... and
+	// Windows_Server-2022-English-Core-EKS_Optimized-1.27-2023.09.12
+	kubeletVersionRegex = regexp.MustCompile(`(?:-node-(?:al2023-[a-z0-9_]+-[a-z]+-)?|k8s[-_]|EKS_Optimized-)(\d+\.\d+)`)
+)
+
+const (
+	bootSupportCacheKey = "boot-support"
+	// maxGetParametersNames is the most parameters that SSM resolves in a GetParameters call
+	maxGetParametersNames = 10
+)
+
 type Provider struct {
-	cache           *cache.Cache
-	ssm             ssmiface.SSMAPI
-	ec2api          ec2iface.EC2API
-	cm              *pretty.ChangeMonitor
-	versionProvider *version.Provider
+	cache             *cache.Cache
+	ssm               ssmiface.SSMAPI
+	ec2api            ec2iface.EC2API
+	ec2clientProvider *ec2client.Provider
+	cm                *pretty.ChangeMonitor
+	versionProvider   *version.Provider
 }
 
 type AMI struct {
@@ -57,6 +82,36 @@ type AMI struct {
 	AmiID        string
 	CreationDate string
 	Requirements scheduling.Requirements
+	// BootMode and TPMSupport are only discovered for the AMIs that are selected with amiSelectorTerms
+	BootMode   string
+	TPMSupport string
+}
+
+// BootSupport is the boot modes and NitroTPM support of an instance type
+type BootSupport struct {
+	BootModes []string
+	NitroTPM  bool
+}
+
+// requiresBootSupport returns true if the AMI can only boot on instance types that support its boot mode or NitroTPM.
+// AMIs in the uefi-preferred boot mode boot on any instance type.
+func (a AMI) requiresBootSupport() bool {
+	return a.BootMode == ec2.BootModeValuesUefi || a.BootMode == ec2.BootModeValuesLegacyBios || a.TPMSupport == ec2.TpmSupportValuesV20
+}
+
+// bootable returns false if the instance type doesn't support the boot mode or NitroTPM that the AMI requires, since
+// such launches don't fail but hang at boot. Instance types whose boot support is unknown are assumed to be bootable.
+func (a AMI) bootable(bootSupport map[string]BootSupport, instanceType string) bool {
+	support, ok := bootSupport[instanceType]
+	if !ok || !a.requiresBootSupport() {
+		return true
+	}
+	if a.BootMode == ec2.BootModeValuesUefi || a.BootMode == ec2.BootModeValuesLegacyBios {
+		if !lo.Contains(support.BootModes, a.BootMode) {
+			return false
+		}
+	}
+	return a.TPMSupport != ec2.TpmSupportValuesV20 || support.NitroTPM
 }
 
 type AMIs []AMI
@@ -88,11 +143,15 @@ func (a AMIs) String() string {
 	return sb.String()
 }
 
-// MapToInstanceTypes returns a map of AMIIDs that are the most recent on creationDate to compatible instancetypes
-func (a AMIs) MapToInstanceTypes(instanceTypes []*cloudprovider.InstanceType, isMachine bool) map[string][]*cloudprovider.InstanceType {
+// MapToInstanceTypes returns a map of AMIIDs that are the most recent on creationDate to compatible instancetypes.
+// Instance types that can't boot an AMI, according to bootSupport, aren't mapped to it.
+func (a AMIs) MapToInstanceTypes(instanceTypes []*cloudprovider.InstanceType, isMachine bool, bootSupport map[string]BootSupport) map[string][]*cloudprovider.InstanceType {
 	amiIDs := map[string][]*cloudprovider.InstanceType{}
 	for _, instanceType := range instanceTypes {
 		for _, ami := range a {
+			if !ami.bootable(bootSupport, instanceType.Name) {
+				continue
+			}
 			if err := instanceType.Requirements.Compatible(ami.Requirements, lo.Ternary(isMachine, scheduling.AllowUndefinedWellKnownLabelsV1Alpha5, scheduling.AllowUndefinedWellKnownLabelsV1Beta1)); err == nil {
 				amiIDs[ami.AmiID] = append(amiIDs[ami.AmiID], instanceType)
 				break
@@ -102,13 +161,15 @@ func (a AMIs) MapToInstanceTypes(instanceTypes []*cloudprovider.InstanceType, is
 	return amiIDs
 }
 
-func NewProvider(versionProvider *version.Provider, ssm ssmiface.SSMAPI, ec2api ec2iface.EC2API, cache *cache.Cache) *Provider {
+func NewProvider(versionProvider *version.Provider, ssm ssmiface.SSMAPI, ec2api ec2iface.EC2API, ec2clientProvider *ec2client.Provider,
+	cache *cache.Cache) *Provider {
 	return &Provider{
-		cache:           cache,
-		ssm:             ssm,
-		ec2api:          ec2api,
-		cm:              pretty.NewChangeMonitor(),
-		versionProvider: versionProvider,
+		cache:             cache,
+		ssm:               ssm,
+		ec2api:            ec2api,
+		ec2clientProvider: ec2clientProvider,
+		cm:                pretty.NewChangeMonitor(),
+		versionProvider:   versionProvider,
 	}
 }
 
@@ -122,10 +183,16 @@ func (p *Provider) Get(ctx context.Context, nodeClass *v1beta1.NodeClass, option
 			return nil, err
 		}
 	} else {
-		amis, err = p.getAMIs(ctx, nodeClass.Spec.AMISelectorTerms)
+		amis, err = p.getAMIs(ctx, nodeClass)
 		if err != nil {
 			return nil, err
 		}
+		if _, ok := GetAMIFamily(nodeClass.Spec.AMIFamily, options).(*Windows); ok {
+			amis = withWindowsBuild(amis)
+		}
+		if amis, err = p.withSupportedKubeletVersion(ctx, nodeClass, amis); err != nil {
+			return nil, err
+		}
 	}
 	amis.Sort()
 	if p.cm.HasChanged(fmt.Sprintf("amis/%t/%s", nodeClass.IsNodeTemplate, nodeClass.Name), amis) {
@@ -134,20 +201,87 @@ func (p *Provider) Get(ctx context.Context, nodeClass *v1beta1.NodeClass, option
 	return amis, nil
 }
 
+// GetBootSupport returns the boot modes and NitroTPM support of the region's instance types when any of the AMIs
+// require them, so that instance types that can't boot the AMIs are filtered out. It returns nil otherwise.
+func (p *Provider) GetBootSupport(ctx context.Context, amis AMIs) (map[string]BootSupport, error) {
+	if !lo.ContainsBy(amis, func(ami AMI) bool { return ami.requiresBootSupport() }) {
+		return nil, nil
+	}
+	if bootSupport, ok := p.cache.Get(bootSupportCacheKey); ok {
+		return bootSupport.(map[string]BootSupport), nil
+	}
+	bootSupport := map[string]BootSupport{}
+	if err := p.ec2api.DescribeInstanceTypesPagesWithContext(ctx, &ec2.DescribeInstanceTypesInput{}, func(page *ec2.DescribeInstanceTypesOutput, _ bool) bool {
+		for _, info := range page.InstanceTypes {
+			bootSupport[aws.StringValue(info.InstanceType)] = BootSupport{
+				BootModes: aws.StringValueSlice(info.SupportedBootModes),
+				NitroTPM:  aws.StringValue(info.NitroTpmSupport) == ec2.NitroTpmSupportSupported,
+			}
+		}
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("describing instance types, %w", err)
+	}
+	p.cache.Set(bootSupportCacheKey, bootSupport, awscache.InstanceTypesAndZonesTTL)
+	return bootSupport, nil
+}
+
+// GetCreationDate returns the creation date of an AMI. AMIs that no longer exist are returned as the zero time, so that
+// they're older than any age.
+func (p *Provider) GetCreationDate(ctx context.Context, nodeClass *v1beta1.NodeClass, amiID string) (time.Time, error) {
+	key := fmt.Sprintf("creation-date/%s", amiID)
+	if creationDate, ok := p.cache.Get(key); ok {
+		return creationDate.(time.Time), nil
+	}
+	output, err := p.ec2clientProvider.EC2API(nodeClass).DescribeImagesWithContext(ctx, &ec2.DescribeImagesInput{
+		Filters: []*ec2.Filter{{Name: aws.String("image-id"), Values: aws.StringSlice([]string{amiID})}},
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("describing images, %w", err)
+	}
+	if len(output.Images) == 0 {
+		return time.Time{}, nil
+	}
+	creationDate, err := time.Parse(time.RFC3339, aws.StringValue(output.Images[0].CreationDate))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing creation date of %s, %w", amiID, err)
+	}
+	// Creation dates don't change
+	p.cache.Set(key, creationDate, cache.NoExpiration)
+	return creationDate, nil
+}
+
 func (p *Provider) getDefaultAMIs(ctx context.Context, nodeClass *v1beta1.NodeClass, options *Options) (res AMIs, err error) {
-	if images, ok := p.cache.Get(lo.FromPtr(nodeClass.Spec.AMIFamily)); ok {
+	var nvidiaDriver string
+	if nodeClass.Spec.NVIDIA != nil {
+		nvidiaDriver = lo.FromPtr(nodeClass.Spec.NVIDIA.Driver)
+	}
+	var windowsVariant string
+	if nodeClass.Spec.Windows != nil {
+		windowsVariant = lo.FromPtr(nodeClass.Spec.Windows.Variant)
+	}
+	cacheKey := lo.FromPtr(nodeClass.Spec.AMIFamily)
+	if nvidiaDriver != "" {
+		cacheKey = fmt.Sprintf("%s/%s", cacheKey, nvidiaDriver)
+	}
+	if windowsVariant != "" {
+		cacheKey = fmt.Sprintf("%s/%s", cacheKey, windowsVariant)
+	}
+	if images, ok := p.cache.Get(cacheKey); ok {
 		return images.(AMIs), nil
 	}
 	amiFamily := GetAMIFamily(nodeClass.Spec.AMIFamily, options)
+	if windows, ok := amiFamily.(*Windows); ok {
+		windows.Variant = windowsVariant
+	}
 	kubernetesVersion, err := p.versionProvider.Get(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("getting kubernetes version %w", err)
 	}
-	defaultAMIs := amiFamily.DefaultAMIs(kubernetesVersion, nodeClass.IsNodeTemplate)
+	defaultAMIs := amiFamily.DefaultAMIs(kubernetesVersion, nodeClass.IsNodeTemplate, nvidiaDriver)
+	ids := p.resolveSSMParameters(ctx, lo.Map(defaultAMIs, func(ami DefaultAMIOutput, _ int) string { return ami.Query }))
 	for _, ami := range defaultAMIs {
-		if id, err := p.resolveSSMParameter(ctx, ami.Query); err != nil {
-			logging.FromContext(ctx).With("query", ami.Query).Errorf("discovering amis from ssm, %s", err)
-		} else {
+		if id, ok := ids[ami.Query]; ok {
 			res = append(res, AMI{AmiID: id, Requirements: ami.Requirements})
 		}
 	}
@@ -168,10 +302,41 @@ func (p *Provider) getDefaultAMIs(ctx context.Context, nodeClass *v1beta1.NodeCl
 	}); err != nil {
 		return nil, fmt.Errorf("describing images, %w", err)
 	}
-	p.cache.SetDefault(lo.FromPtr(nodeClass.Spec.AMIFamily), res)
+	p.cache.SetDefault(cacheKey, res)
 	return res, nil
 }
 
+// resolveSSMParameters resolves the SSM parameters of the default AMIs with as few GetParameters calls as possible,
+// rather than a GetParameter call per parameter. Parameters are cached individually, so that AMI families and variants
+// that share parameters resolve them once. Parameters that don't exist and batches that fail are logged and left out,
+// so that the AMIs of the other parameters are still resolved.
+func (p *Provider) resolveSSMParameters(ctx context.Context, ssmQueries []string) map[string]string {
+	res := map[string]string{}
+	var unresolved []string
+	for _, ssmQuery := range lo.Uniq(ssmQueries) {
+		if id, ok := p.cache.Get(fmt.Sprintf("ssm/%s", ssmQuery)); ok {
+			res[ssmQuery] = id.(string)
+		} else {
+			unresolved = append(unresolved, ssmQuery)
+		}
+	}
+	for _, batch := range lo.Chunk(unresolved, maxGetParametersNames) {
+		output, err := p.ssm.GetParametersWithContext(ctx, &ssm.GetParametersInput{Names: aws.StringSlice(batch)})
+		if err != nil {
+			logging.FromContext(ctx).With("queries", batch).Errorf("discovering amis from ssm, %s", err)
+			continue
+		}
+		for _, parameter := range output.Parameters {
+			res[aws.StringValue(parameter.Name)] = aws.StringValue(parameter.Value)
+			p.cache.SetDefault(fmt.Sprintf("ssm/%s", aws.StringValue(parameter.Name)), aws.StringValue(parameter.Value))
+		}
+		for _, ssmQuery := range output.InvalidParameters {
+			logging.FromContext(ctx).With("query", aws.StringValue(ssmQuery)).Errorf("discovering amis from ssm, parameter not found")
+		}
+	}
+	return res
+}
+
 func (p *Provider) resolveSSMParameter(ctx context.Context, ssmQuery string) (string, error) {
 	output, err := p.ssm.GetParameterWithContext(ctx, &ssm.GetParameterInput{Name: aws.String(ssmQuery)})
 	if err != nil {
@@ -181,59 +346,228 @@ func (p *Provider) resolveSSMParameter(ctx context.Context, ssmQuery string) (st
 	return ami, nil
 }
 
-func (p *Provider) getAMIs(ctx context.Context, terms []v1beta1.AMISelectorTerm) (AMIs, error) {
-	filterAndOwnerSets := GetFilterAndOwnerSets(terms)
-	hash, err := hashstructure.Hash(filterAndOwnerSets, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
+// resolveSSMTerms replaces the terms that select an AMI through an SSM parameter with the ID of the AMI that the
+// parameter holds. Parameters are resolved again once they expire from the cache, so that an AMI that a pipeline
+// promotes to the parameter is discovered within a minute and drifts the nodes that were launched with the previous AMI.
+func (p *Provider) resolveSSMTerms(ctx context.Context, terms []v1beta1.AMISelectorTerm) ([]v1beta1.AMISelectorTerm, error) {
+	res := make([]v1beta1.AMISelectorTerm, 0, len(terms))
+	for _, term := range terms {
+		if term.SSM == "" {
+			res = append(res, term)
+			continue
+		}
+		key := fmt.Sprintf("ssm/%s", term.SSM)
+		id, ok := p.cache.Get(key)
+		if !ok {
+			resolved, err := p.resolveSSMParameter(ctx, term.SSM)
+			if err != nil {
+				return nil, err
+			}
+			if !strings.HasPrefix(resolved, "ami-") {
+				return nil, fmt.Errorf("ssm parameter %q doesn't hold an ami id, %q", term.SSM, resolved)
+			}
+			p.cache.SetDefault(key, resolved)
+			id = resolved
+		}
+		res = append(res, v1beta1.AMISelectorTerm{ID: id.(string)})
+	}
+	return res, nil
+}
+
+// getAMIs discovers the AMIs that the NodeClass selects in its region and with the role that it assumes. Default AMIs
+// are resolved with the controller's client regardless.
+func (p *Provider) getAMIs(ctx context.Context, nodeClass *v1beta1.NodeClass) (AMIs, error) {
+	terms, err := p.resolveSSMTerms(ctx, nodeClass.Spec.AMISelectorTerms)
 	if err != nil {
 		return nil, err
 	}
-	if images, ok := p.cache.Get(fmt.Sprint(hash)); ok {
-		return images.(AMIs), nil
-	}
+	ec2api := p.ec2clientProvider.EC2API(nodeClass)
+	clientKey := p.ec2clientProvider.Key(nodeClass)
 	images := map[uint64]AMI{}
-	for _, filtersAndOwners := range filterAndOwnerSets {
-		if err = p.ec2api.DescribeImagesPagesWithContext(ctx, &ec2.DescribeImagesInput{
-			// Don't include filters in the Describe Images call as EC2 API doesn't allow empty filters.
-			Filters:    lo.Ternary(len(filtersAndOwners.Filters) > 0, filtersAndOwners.Filters, nil),
-			Owners:     lo.Ternary(len(filtersAndOwners.Owners) > 0, aws.StringSlice(filtersAndOwners.Owners), nil),
-			MaxResults: aws.Int64(500),
-		}, func(page *ec2.DescribeImagesOutput, _ bool) bool {
-			for i := range page.Images {
-				reqs := p.getRequirementsFromImage(page.Images[i])
-				if !v1beta1.WellKnownArchitectures.Has(reqs.Get(v1.LabelArchStable).Any()) {
+	now := time.Now()
+	for _, filtersAndOwners := range GetFilterAndOwnerSets(terms) {
+		described, err := p.describeImages(ctx, ec2api, clientKey, filtersAndOwners)
+		if err != nil {
+			return nil, err
+		}
+		for _, image := range described {
+			if !filtersAndOwners.Matches(image, now) {
+				continue
+			}
+			reqs := p.getRequirementsFromImage(image)
+			if !v1beta1.WellKnownArchitectures.Has(reqs.Get(v1.LabelArchStable).Any()) {
+				continue
+			}
+			// AMIs with different boot requirements boot on different instance types, so the newest of each is kept
+			reqsHash := lo.Must(hashstructure.Hash([]interface{}{reqs.NodeSelectorRequirements(), lo.FromPtr(image.BootMode), lo.FromPtr(image.TpmSupport)},
+				hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true}))
+			// If the proposed image is newer, store it so that we can return it
+			if v, ok := images[reqsHash]; ok {
+				candidateCreationTime, _ := time.Parse(time.RFC3339, lo.FromPtr(image.CreationDate))
+				existingCreationTime, _ := time.Parse(time.RFC3339, v.CreationDate)
+				if existingCreationTime == candidateCreationTime && lo.FromPtr(image.Name) < v.Name {
 					continue
 				}
-				reqsHash := lo.Must(hashstructure.Hash(reqs.NodeSelectorRequirements(), hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true}))
-				// If the proposed image is newer, store it so that we can return it
-				if v, ok := images[reqsHash]; ok {
-					candidateCreationTime, _ := time.Parse(time.RFC3339, lo.FromPtr(page.Images[i].CreationDate))
-					existingCreationTime, _ := time.Parse(time.RFC3339, v.CreationDate)
-					if existingCreationTime == candidateCreationTime && lo.FromPtr(page.Images[i].Name) < v.Name {
-						continue
-					}
-					if candidateCreationTime.Unix() < existingCreationTime.Unix() {
-						continue
-					}
-				}
-				images[reqsHash] = AMI{
-					Name:         lo.FromPtr(page.Images[i].Name),
-					AmiID:        lo.FromPtr(page.Images[i].ImageId),
-					CreationDate: lo.FromPtr(page.Images[i].CreationDate),
-					Requirements: reqs,
+				if candidateCreationTime.Unix() < existingCreationTime.Unix() {
+					continue
 				}
 			}
-			return true
-		}); err != nil {
-			return nil, fmt.Errorf("describing images, %w", err)
+			images[reqsHash] = AMI{
+				Name:         lo.FromPtr(image.Name),
+				AmiID:        lo.FromPtr(image.ImageId),
+				CreationDate: lo.FromPtr(image.CreationDate),
+				Requirements: reqs,
+				BootMode:     lo.FromPtr(image.BootMode),
+				TPMSupport:   lo.FromPtr(image.TpmSupport),
+			}
 		}
 	}
-	p.cache.SetDefault(fmt.Sprint(hash), AMIs(lo.Values(images)))
 	return lo.Values(images), nil
 }
 
+// describedImages are the images that the filters and owners of an AMI selector term described
+type describedImages struct {
+	images     map[string]*ec2.Image
+	newest     time.Time
+	expiration time.Time
+}
+
+// describeImages returns the images that match the filters and owners of an AMI selector term. The images are cached
+// by the filters and owners and the account that they're described in, so that NodeClasses with the same terms share
+// them. Once the images are more than DefaultTTL old, only the images that were created since the newest image are
+// described and added to them, since broad selectors can describe thousands of images. The images are described in
+// full again once they expire after AMIsTTL.
+func (p *Provider) describeImages(ctx context.Context, ec2api ec2iface.EC2API, clientKey string, filtersAndOwners FiltersAndOwners) ([]*ec2.Image, error) {
+	hash, err := hashstructure.Hash([]interface{}{filtersAndOwners.Filters, filtersAndOwners.Owners}, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("images/%d", hash)
+	if clientKey != "" {
+		key = fmt.Sprintf("%s/%s", key, clientKey)
+	}
+	refreshedKey := fmt.Sprintf("images-refreshed/%s", strings.TrimPrefix(key, "images/"))
+	now := time.Now()
+	var cached *describedImages
+	if entry, ok := p.cache.Get(key); ok {
+		cached = entry.(*describedImages)
+		if _, ok := p.cache.Get(refreshedKey); ok {
+			return lo.Values(cached.images), nil
+		}
+	}
+	filters := append([]*ec2.Filter{}, filtersAndOwners.Filters...)
+	if cached != nil {
+		if days := creationDays(cached.newest, now); cached.expiration.After(now) && len(days) > 0 && len(days) <= maxCreationDateFilterValues {
+			filters = append(filters, &ec2.Filter{Name: aws.String("creation-date"), Values: aws.StringSlice(days)})
+		} else {
+			cached = nil
+		}
+	}
+	images := map[string]*ec2.Image{}
+	if err = ec2api.DescribeImagesPagesWithContext(ctx, &ec2.DescribeImagesInput{
+		// Don't include filters in the Describe Images call as EC2 API doesn't allow empty filters.
+		Filters:    lo.Ternary(len(filters) > 0, filters, nil),
+		Owners:     lo.Ternary(len(filtersAndOwners.Owners) > 0, aws.StringSlice(filtersAndOwners.Owners), nil),
+		MaxResults: aws.Int64(500),
+	}, func(page *ec2.DescribeImagesOutput, _ bool) bool {
+		for _, image := range page.Images {
+			images[aws.StringValue(image.ImageId)] = image
+		}
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("describing images, %w", err)
+	}
+	entry := &describedImages{images: images, expiration: now.Add(awscache.AMIsTTL)}
+	if cached != nil {
+		entry = &describedImages{images: lo.Assign(cached.images, images), newest: cached.newest, expiration: cached.expiration}
+	}
+	for _, image := range images {
+		if creationDate, err := time.Parse(time.RFC3339, aws.StringValue(image.CreationDate)); err == nil && creationDate.After(entry.newest) {
+			entry.newest = creationDate
+		}
+	}
+	p.cache.Set(key, entry, entry.expiration.Sub(now))
+	p.cache.SetDefault(refreshedKey, struct{}{})
+	return lo.Values(entry.images), nil
+}
+
+// maxCreationDateFilterValues is the most days that are described incrementally, beyond which the images are described
+// in full. EC2 accepts up to 200 values in a filter.
+const maxCreationDateFilterValues = 31
+
+// creationDays returns the creation-date filter values of the days from the day of newest to the day of now, in UTC.
+// The day of newest is included, since images created later on the same day have the same prefix.
+func creationDays(newest, now time.Time) (days []string) {
+	if newest.IsZero() {
+		return nil
+	}
+	for day := newest.UTC().Truncate(24 * time.Hour); !day.After(now.UTC()); day = day.Add(24 * time.Hour) {
+		days = append(days, fmt.Sprintf("%s*", day.Format("2006-01-02")))
+	}
+	return days
+}
+
+// withWindowsBuild adds the windows-build of the Windows Server version in the name of the EKS optimized Windows AMIs
+// to the AMIs that aren't tagged with one, so that an AMI of another Windows Server version isn't launched for the
+// instance types of the AMIFamily, whose nodes would fail to register with the windows-build label of the AMIFamily
+func withWindowsBuild(amis AMIs) AMIs {
+	return lo.Map(amis, func(ami AMI, _ int) AMI {
+		matches := windowsServerVersionRegex.FindStringSubmatch(ami.Name)
+		if matches == nil || ami.Requirements.Has(v1.LabelWindowsBuild) {
+			return ami
+		}
+		// The AMIs are cached, so the requirements are copied rather than modified
+		ami.Requirements = scheduling.NewRequirements(ami.Requirements.Values()...)
+		ami.Requirements.Add(scheduling.NewRequirement(v1.LabelWindowsBuild, v1.NodeSelectorOpIn, windowsBuilds[matches[1]]))
+		return ami
+	})
+}
+
+// withSupportedKubeletVersion removes the AMIs whose kubelet version, which is read from the name of the EKS optimized
+// AMIs, is outside the version skew that the control plane supports, since their nodes would fail to join the cluster.
+// The default AMIs always match the version of the control plane, and AMIs of an unknown version are kept.
+func (p *Provider) withSupportedKubeletVersion(ctx context.Context, nodeClass *v1beta1.NodeClass, amis AMIs) (AMIs, error) {
+	kubernetesVersion, err := p.versionProvider.Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting kubernetes version %w", err)
+	}
+	isUnsupported := func(ami AMI, _ int) bool {
+		matches := kubeletVersionRegex.FindStringSubmatch(ami.Name)
+		return matches != nil && version.ValidateKubeletVersion(kubernetesVersion, matches[1]) != nil
+	}
+	unsupported, supported := lo.Filter(amis, isUnsupported), lo.Reject(amis, isUnsupported)
+	if len(unsupported) > 0 && p.cm.HasChanged(fmt.Sprintf("unsupported-amis/%t/%s", nodeClass.IsNodeTemplate, nodeClass.Name), unsupported) {
+		logging.FromContext(ctx).With("ids", unsupported, "kubernetes-version", kubernetesVersion).Infof("ignoring amis outside of the kubelet version skew of %d minor versions", version.MaxKubeletVersionSkew)
+	}
+	return supported, nil
+}
+
 type FiltersAndOwners struct {
 	Filters []*ec2.Filter
 	Owners  []string
+	// NameRegex and MinCreationDate can't be expressed as EC2 filters, so they're matched against the described images
+	NameRegex       string
+	MinCreationDate string
+}
+
+// Matches returns true if the image matches the NameRegex and MinCreationDate of the term
+func (f FiltersAndOwners) Matches(image *ec2.Image, now time.Time) bool {
+	if f.NameRegex != "" {
+		if r, err := regexp.Compile(f.NameRegex); err != nil || !r.MatchString(aws.StringValue(image.Name)) {
+			return false
+		}
+	}
+	if f.MinCreationDate != "" {
+		minCreationDate, err := v1beta1.ParseMinCreationDate(f.MinCreationDate, now)
+		if err != nil {
+			return false
+		}
+		creationDate, err := time.Parse(time.RFC3339, aws.StringValue(image.CreationDate))
+		if err != nil || creationDate.Before(minCreationDate) {
+			return false
+		}
+	}
+	return true
 }
 
 func GetFilterAndOwnerSets(terms []v1beta1.AMISelectorTerm) (res []FiltersAndOwners) {
@@ -244,7 +578,9 @@ func GetFilterAndOwnerSets(terms []v1beta1.AMISelectorTerm) (res []FiltersAndOwn
 			idFilter.Values = append(idFilter.Values, aws.String(term.ID))
 		default:
 			elem := FiltersAndOwners{
-				Owners: lo.Ternary(term.Owner != "", []string{term.Owner}, []string{"self", "amazon"}),
+				Owners:          lo.Ternary(term.Owner != "", []string{term.Owner}, []string{"self", "amazon"}),
+				NameRegex:       term.NameRegex,
+				MinCreationDate: term.MinCreationDate,
 			}
 			if term.Name != "" {
 				elem.Filters = append(elem.Filters, &ec2.Filter{
@@ -252,6 +588,12 @@ func GetFilterAndOwnerSets(terms []v1beta1.AMISelectorTerm) (res []FiltersAndOwn
 					Values: aws.StringSlice([]string{term.Name}),
 				})
 			}
+			if term.Architecture != "" {
+				elem.Filters = append(elem.Filters, &ec2.Filter{
+					Name:   aws.String("architecture"),
+					Values: aws.StringSlice([]string{lo.Invert(v1beta1.AWSToKubeArchitectures)[term.Architecture]}),
+				})
+			}
 			for k, v := range term.Tags {
 				if v == "*" {
 					elem.Filters = append(elem.Filters, &ec2.Filter{
@@ -18,14 +18,18 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/eks"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
 	. "knative.dev/pkg/logging/testing"
 
 	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
@@ -149,6 +153,31 @@ var _ = Describe("AMIProvider", func() {
 		Expect(err).ToNot(HaveOccurred())
 		Expect(amis).To(HaveLen(6))
 	})
+	It("should resolve the open NVIDIA driver AMIs (AL2)", func() {
+		nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyAL2
+		nodeClass.Spec.NVIDIA = &v1beta1.NVIDIAConfiguration{Driver: aws.String(v1beta1.NVIDIADriverOpen)}
+		awsEnv.SSMAPI.Parameters = map[string]string{
+			fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2/recommended/image_id", version):          amd64AMI,
+			fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2-gpu-open/recommended/image_id", version): amd64NvidiaAMI,
+			fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2-arm64/recommended/image_id", version):    arm64AMI,
+		}
+		amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(amis).To(HaveLen(4))
+	})
+	It("should resolve the open NVIDIA driver AMIs (Bottlerocket)", func() {
+		nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyBottlerocket
+		nodeClass.Spec.NVIDIA = &v1beta1.NVIDIAConfiguration{Driver: aws.String(v1beta1.NVIDIADriverOpen)}
+		awsEnv.SSMAPI.Parameters = map[string]string{
+			fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s/x86_64/latest/image_id", version):             amd64AMI,
+			fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s-nvidia-open/x86_64/latest/image_id", version): amd64NvidiaAMI,
+			fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s/arm64/latest/image_id", version):              arm64AMI,
+			fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s-nvidia-open/arm64/latest/image_id", version):  arm64NvidiaAMI,
+		}
+		amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(amis).To(HaveLen(6))
+	})
 	It("should succeed to resolve AMIs (Ubuntu)", func() {
 		nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyUbuntu
 		awsEnv.SSMAPI.Parameters = map[string]string{
@@ -177,6 +206,50 @@ var _ = Describe("AMIProvider", func() {
 		Expect(err).ToNot(HaveOccurred())
 		Expect(amis).To(HaveLen(1))
 	})
+	It("should resolve the Full AMIs (Windows2022)", func() {
+		nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyWindows2022
+		nodeClass.Spec.Windows = &v1beta1.WindowsConfiguration{Variant: aws.String(v1beta1.WindowsFull)}
+		awsEnv.SSMAPI.Parameters = map[string]string{
+			fmt.Sprintf("/aws/service/ami-windows-latest/Windows_Server-2022-English-Full-EKS_Optimized-%s/image_id", version): amd64AMI,
+		}
+		amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(amis).To(HaveLen(1))
+		Expect(amis[0].Requirements.Get(v1.LabelWindowsBuild).Values()).To(ConsistOf(v1beta1.Windows2022Build))
+	})
+	It("should add the windows-build of the Windows Server version in the name of selected AMIs (Windows2022)", func() {
+		nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyWindows2022
+		nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{Tags: map[string]string{"*": "*"}}}
+		awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{
+			Images: []*ec2.Image{
+				{
+					Name:         aws.String(fmt.Sprintf("Windows_Server-2019-English-Core-EKS_Optimized-%s-2023.09.12", version)),
+					ImageId:      aws.String("ami-2019"),
+					CreationDate: aws.String(time.Now().Format(time.RFC3339)),
+					Architecture: aws.String("x86_64"),
+				},
+				{
+					Name:         aws.String("my-windows-ami"),
+					ImageId:      aws.String("ami-tagged"),
+					CreationDate: aws.String(time.Now().Format(time.RFC3339)),
+					Architecture: aws.String("arm64"),
+					Tags: []*ec2.Tag{
+						{Key: aws.String(v1.LabelWindowsBuild), Value: aws.String(v1beta1.Windows2022Build)},
+					},
+				},
+			},
+		})
+		amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(amis).To(HaveLen(2))
+		builds := lo.SliceToMap(amis, func(ami amifamily.AMI) (string, []string) {
+			return ami.AmiID, ami.Requirements.Get(v1.LabelWindowsBuild).Values()
+		})
+		Expect(builds).To(Equal(map[string][]string{
+			"ami-2019":   {v1beta1.Windows2019Build},
+			"ami-tagged": {v1beta1.Windows2022Build},
+		}))
+	})
 	It("should succeed to resolve AMIs (Custom)", func() {
 		nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyCustom
 		amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
@@ -220,6 +293,196 @@ var _ = Describe("AMIProvider", func() {
 		Expect(err).ToNot(HaveOccurred())
 		Expect(amis).To(HaveLen(1))
 	})
+	It("should resolve the SSM aliases of an AMI family in a single call", func() {
+		nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyBottlerocket
+		amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(amis).ToNot(BeEmpty())
+		Expect(awsEnv.SSMAPI.CalledWithGetParametersInput.Len()).To(Equal(1))
+	})
+	It("should resolve the SSM aliases that are shared by AMI families and variants once", func() {
+		nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyAL2
+		_, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(awsEnv.SSMAPI.CalledWithGetParametersInput.Len()).To(Equal(1))
+
+		// Only the accelerated AMIs of the open NVIDIA driver differ
+		nodeClass.Spec.NVIDIA = &v1beta1.NVIDIAConfiguration{Driver: aws.String(v1beta1.NVIDIADriverOpen)}
+		_, err = awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(awsEnv.SSMAPI.CalledWithGetParametersInput.Len()).To(Equal(2))
+		input := awsEnv.SSMAPI.CalledWithGetParametersInput.Pop()
+		Expect(aws.StringValueSlice(input.Names)).ToNot(ContainElement(fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2/recommended/image_id", version)))
+	})
+	It("should not fail to resolve AMIs when SSM fails", func() {
+		nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyAL2
+		awsEnv.SSMAPI.WantErr = fmt.Errorf("throttled")
+		amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(amis).To(BeEmpty())
+	})
+	It("should discover selected AMIs with the role that the NodeClass assumes", func() {
+		nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{Tags: map[string]string{"Name": "shared-ami"}}}
+		awsEnv.DiscoveryEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+			{
+				Name:         aws.String("shared-ami"),
+				ImageId:      aws.String("ami-shared"),
+				CreationDate: aws.String("2022-08-15T12:00:00Z"),
+				Architecture: aws.String("x86_64"),
+				Tags:         []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("shared-ami")}},
+			},
+		}})
+		amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(lo.Map(amis, func(a amifamily.AMI, _ int) string { return a.AmiID })).ToNot(ContainElement("ami-shared"))
+
+		nodeClass.Spec.AssumeRoleARN = aws.String("arn:aws:iam::210987654321:role/discovery")
+		amis, err = awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(lo.Map(amis, func(a amifamily.AMI, _ int) string { return a.AmiID })).To(ConsistOf("ami-shared"))
+	})
+	Context("SSM", func() {
+		BeforeEach(func() {
+			nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyCustom
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{SSM: "/my-org/ami/tested"}}
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{Name: aws.String("my-ami-1"), ImageId: aws.String("ami-1"), CreationDate: aws.String("2023-09-01T00:00:00Z"), Architecture: aws.String("x86_64")},
+				{Name: aws.String("my-ami-2"), ImageId: aws.String("ami-2"), CreationDate: aws.String("2023-09-08T00:00:00Z"), Architecture: aws.String("x86_64")},
+			}})
+		})
+		It("should resolve the AMI that the SSM parameter holds", func() {
+			awsEnv.SSMAPI.Parameters = map[string]string{"/my-org/ami/tested": "ami-1"}
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(amis, func(a amifamily.AMI, _ int) string { return a.AmiID })).To(ConsistOf("ami-1"))
+		})
+		It("should resolve the AMI that is promoted to the SSM parameter once the parameter expires from the cache", func() {
+			awsEnv.SSMAPI.Parameters = map[string]string{"/my-org/ami/tested": "ami-1"}
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(amis, func(a amifamily.AMI, _ int) string { return a.AmiID })).To(ConsistOf("ami-1"))
+
+			awsEnv.SSMAPI.Parameters = map[string]string{"/my-org/ami/tested": "ami-2"}
+			amis, err = awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(amis, func(a amifamily.AMI, _ int) string { return a.AmiID })).To(ConsistOf("ami-1"))
+
+			awsEnv.EC2Cache.Flush()
+			amis, err = awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(amis, func(a amifamily.AMI, _ int) string { return a.AmiID })).To(ConsistOf("ami-2"))
+		})
+		It("should fail when the SSM parameter doesn't exist", func() {
+			awsEnv.SSMAPI.Parameters = map[string]string{"/my-org/ami/other": "ami-1"}
+			_, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).To(HaveOccurred())
+		})
+		It("should fail when the SSM parameter doesn't hold an AMI ID", func() {
+			awsEnv.SSMAPI.Parameters = map[string]string{"/my-org/ami/tested": "my-ami-1"}
+			_, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("Kubelet Version Skew", func() {
+		minorVersion := func(skew int) string {
+			major, minor, _ := strings.Cut(version, ".")
+			return fmt.Sprintf("%s.%d", major, lo.Must(strconv.Atoi(minor))+skew)
+		}
+		BeforeEach(func() {
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{Tags: map[string]string{"*": "*"}}}
+		})
+		It("should ignore the selected AMIs of kubelet versions outside of the version skew", func() {
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{
+				Images: []*ec2.Image{
+					{Name: aws.String(fmt.Sprintf("amazon-eks-node-%s-v20230607", version)), ImageId: aws.String("ami-current"), CreationDate: aws.String(time.Now().Format(time.RFC3339)), Architecture: aws.String("x86_64")},
+					{Name: aws.String(fmt.Sprintf("bottlerocket-aws-k8s-%s-x86_64-v1.14.1-7d3a7f9c", minorVersion(-2))), ImageId: aws.String("ami-behind"), CreationDate: aws.String(time.Now().Add(-time.Minute).Format(time.RFC3339)), Architecture: aws.String("arm64")},
+					{Name: aws.String(fmt.Sprintf("amazon-eks-node-%s-v20230607", minorVersion(-3))), ImageId: aws.String("ami-too-old"), CreationDate: aws.String(time.Now().Format(time.RFC3339)), Architecture: aws.String("arm64")},
+					{Name: aws.String(fmt.Sprintf("ubuntu-eks/k8s_%s/images/hvm-ssd/ubuntu-focal-20.04-amd64-server-20230616", minorVersion(1))), ImageId: aws.String("ami-too-new"), CreationDate: aws.String(time.Now().Format(time.RFC3339)), Architecture: aws.String("arm64")},
+				},
+			})
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(amis, func(ami amifamily.AMI, _ int) string { return ami.AmiID })).To(ConsistOf("ami-current", "ami-behind"))
+		})
+		It("should keep the selected AMIs of unknown kubelet versions", func() {
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{
+				Images: []*ec2.Image{
+					{Name: aws.String("my-custom-ami"), ImageId: aws.String("ami-custom"), CreationDate: aws.String(time.Now().Format(time.RFC3339)), Architecture: aws.String("x86_64")},
+				},
+			})
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+		})
+		It("should validate the kubelet versions against the version of the control plane that EKS describes", func() {
+			ctx := settings.ToContext(ctx, test.Settings(test.SettingOptions{KubernetesVersionSource: lo.ToPtr(settings.KubernetesVersionSourceEKS)}))
+			awsEnv.KubernetesVersionCache.Flush()
+			awsEnv.EKSAPI.DescribeClusterBehaviour.Output.Set(&eks.DescribeClusterOutput{Cluster: &eks.Cluster{Version: aws.String(minorVersion(3))}})
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{
+				Images: []*ec2.Image{
+					{Name: aws.String(fmt.Sprintf("amazon-eks-node-%s-v20230607", version)), ImageId: aws.String("ami-too-old"), CreationDate: aws.String(time.Now().Format(time.RFC3339)), Architecture: aws.String("x86_64")},
+					{Name: aws.String(fmt.Sprintf("amazon-eks-node-%s-v20230607", minorVersion(1))), ImageId: aws.String("ami-behind"), CreationDate: aws.String(time.Now().Format(time.RFC3339)), Architecture: aws.String("arm64")},
+				},
+			})
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(amis, func(ami amifamily.AMI, _ int) string { return ami.AmiID })).To(ConsistOf("ami-behind"))
+			Expect(awsEnv.EKSAPI.DescribeClusterBehaviour.CalledWithInput.Len()).To(Equal(1))
+		})
+	})
+	Context("Image Cache", func() {
+		var image func(id string, creationDate time.Time) *ec2.Image
+		BeforeEach(func() {
+			nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyCustom
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{Tags: map[string]string{"approved": "true"}}}
+			image = func(id string, creationDate time.Time) *ec2.Image {
+				return &ec2.Image{
+					Name:         aws.String(id),
+					ImageId:      aws.String(id),
+					CreationDate: aws.String(creationDate.UTC().Format(time.RFC3339)),
+					Architecture: aws.String("x86_64"),
+					Tags:         []*ec2.Tag{{Key: aws.String("approved"), Value: aws.String("true")}},
+				}
+			}
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{image("ami-1", time.Now().Add(-48*time.Hour))}})
+		})
+		It("should share the described images between NodeClasses with the same selector terms", func() {
+			_, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			other := test.NodeClass(v1beta1.NodeClass{Spec: nodeClass.Spec})
+			_, err = awsEnv.AMIProvider.Get(ctx, other, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(awsEnv.EC2API.CalledWithDescribeImagesInput.Len()).To(Equal(1))
+		})
+		It("should only describe the images that were created since the newest image when the images are refreshed", func() {
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(amis, func(a amifamily.AMI, _ int) string { return a.AmiID })).To(ConsistOf("ami-1"))
+			Expect(awsEnv.EC2API.CalledWithDescribeImagesInput.Pop().Filters).ToNot(ContainElement(HaveField("Name", aws.String("creation-date"))))
+
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				image("ami-1", time.Now().Add(-48*time.Hour)),
+				image("ami-2", time.Now()),
+			}})
+			ExpectImagesRefreshed()
+			amis, err = awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(amis, func(a amifamily.AMI, _ int) string { return a.AmiID })).To(ConsistOf("ami-2"))
+			filter, ok := lo.Find(awsEnv.EC2API.CalledWithDescribeImagesInput.Pop().Filters, func(f *ec2.Filter) bool { return aws.StringValue(f.Name) == "creation-date" })
+			Expect(ok).To(BeTrue())
+			Expect(aws.StringValueSlice(filter.Values)).To(ContainElement(time.Now().UTC().Format("2006-01-02") + "*"))
+			Expect(aws.StringValueSlice(filter.Values)).To(HaveLen(3))
+		})
+		It("should not describe the images again before they're refreshed", func() {
+			_, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{image("ami-2", time.Now())}})
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(amis, func(a amifamily.AMI, _ int) string { return a.AmiID })).To(ConsistOf("ami-1"))
+			Expect(awsEnv.EC2API.CalledWithDescribeImagesInput.Len()).To(Equal(1))
+		})
+	})
 	Context("AMI Selectors", func() {
 		It("should have default owners and use tags when prefixes aren't set", func() {
 			amiSelectorTerms := []v1beta1.AMISelectorTerm{
@@ -340,6 +603,59 @@ var _ = Describe("AMIProvider", func() {
 				},
 			}, filterAndOwnersSets)
 		})
+		It("should filter by architecture", func() {
+			filterAndOwnersSets := amifamily.GetFilterAndOwnerSets([]v1beta1.AMISelectorTerm{{Name: "my-name", Architecture: "amd64"}})
+			ExpectConsistsOfFiltersAndOwners([]amifamily.FiltersAndOwners{
+				{
+					Owners: []string{"self", "amazon"},
+					Filters: []*ec2.Filter{
+						{
+							Name:   aws.String("architecture"),
+							Values: aws.StringSlice([]string{"x86_64"}),
+						},
+						{
+							Name:   aws.String("name"),
+							Values: aws.StringSlice([]string{"my-name"}),
+						},
+					},
+				},
+			}, filterAndOwnersSets)
+		})
+		It("should select the newest AMI that matches the name regex and was created after the minimum creation date", func() {
+			nodeClass.Spec.AMIFamily = &v1beta1.AMIFamilyAL2
+			nodeClass.Spec.AMISelectorTerms = []v1beta1.AMISelectorTerm{{
+				Tags:            map[string]string{"approved": "true"},
+				NameRegex:       `^my-ami-[0-9]+$`,
+				Architecture:    "amd64",
+				MinCreationDate: "720h",
+			}}
+			image := func(name string, age time.Duration, architecture string) *ec2.Image {
+				return &ec2.Image{
+					Name:         aws.String(name),
+					ImageId:      aws.String(fmt.Sprintf("ami-%s", name)),
+					CreationDate: aws.String(time.Now().Add(-age).Format(time.RFC3339)),
+					Architecture: aws.String(architecture),
+					Tags:         []*ec2.Tag{{Key: aws.String("approved"), Value: aws.String("true")}},
+				}
+			}
+			awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				image("my-ami-3", 24*time.Hour, "x86_64"),
+				image("my-ami-2", 48*time.Hour, "x86_64"),
+				image("my-ami-1", 40*24*time.Hour, "x86_64"),
+				image("my-ami-rc", time.Hour, "x86_64"),
+				image("my-ami-4", time.Hour, "arm64"),
+			}})
+			amis, err := awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(HaveLen(1))
+			Expect(amis[0].AmiID).To(Equal("ami-my-ami-3"))
+
+			// None of the AMIs were created within the window
+			nodeClass.Spec.AMISelectorTerms[0].MinCreationDate = "12h"
+			amis, err = awsEnv.AMIProvider.Get(ctx, nodeClass, &amifamily.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(amis).To(BeEmpty())
+		})
 		It("should sort amis by creationDate", func() {
 			amis := amifamily.AMIs{
 				{
@@ -419,3 +735,13 @@ func ExpectConsistsOfFiltersAndOwners(expected, actual []amifamily.FiltersAndOwn
 	}
 	Expect(actual).To(ConsistOf(lo.Map(expected, func(f amifamily.FiltersAndOwners, _ int) interface{} { return f })...))
 }
+
+// ExpectImagesRefreshed expires the refresh interval of the cached images, as if DefaultTTL had passed
+func ExpectImagesRefreshed() {
+	GinkgoHelper()
+	for key := range awsEnv.EC2Cache.Items() {
+		if strings.HasPrefix(key, "images-refreshed/") {
+			awsEnv.EC2Cache.Delete(key)
+		}
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
 	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
 	"github.com/aws/karpenter-core/pkg/utils/resources"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
 )
 
 // Options is the node bootstrapping parameters passed from Karpenter to the provisioning node
@@ -40,6 +41,8 @@ type Options struct {
 	AWSENILimitedPodDensity bool
 	ContainerRuntime        *string
 	CustomUserData          *string
+	// NodeClassKubeletConfig are the kubelet settings of the NodeClass, which only the AL2 and Bottlerocket AMI families support
+	NodeClassKubeletConfig *v1beta1.KubeletConfiguration
 }
 
 func (o Options) kubeletExtraArgs() (args []string) {
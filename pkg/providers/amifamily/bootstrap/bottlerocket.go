@@ -25,12 +25,14 @@ import (
 	"github.com/samber/lo"
 
 	"github.com/aws/karpenter-core/pkg/utils/resources"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
 
 	"github.com/aws/aws-sdk-go/aws"
 )
 
 type Bottlerocket struct {
 	Options
+	Settings *v1beta1.BottlerocketSettings
 }
 
 // nolint:gocyclo
@@ -39,6 +41,7 @@ func (b Bottlerocket) Script() (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("invalid UserData %w", err)
 	}
+	s.MergeSettings(b.Settings)
 	// Karpenter will overwrite settings present inside custom UserData
 	// based on other fields specified in the provisioner
 	s.Settings.Kubernetes.ClusterName = &b.ClusterName
@@ -78,6 +81,29 @@ func (b Bottlerocket) Script() (string, error) {
 			s.Settings.Kubernetes.CPUCFSQuota = b.KubeletConfig.CPUCFSQuota
 		}
 	}
+	if b.NodeClassKubeletConfig != nil {
+		if b.NodeClassKubeletConfig.ShutdownGracePeriod != nil {
+			s.Settings.Kubernetes.ShutdownGracePeriod = lo.ToPtr(b.NodeClassKubeletConfig.ShutdownGracePeriod.Duration.String())
+		}
+		if b.NodeClassKubeletConfig.ShutdownGracePeriodCriticalPods != nil {
+			s.Settings.Kubernetes.ShutdownGracePeriodForCriticalPods = lo.ToPtr(b.NodeClassKubeletConfig.ShutdownGracePeriodCriticalPods.Duration.String())
+		}
+		if b.NodeClassKubeletConfig.RegistryPullQPS != nil {
+			s.Settings.Kubernetes.RegistryQPS = aws.Int(int(*b.NodeClassKubeletConfig.RegistryPullQPS))
+		}
+		if b.NodeClassKubeletConfig.RegistryBurst != nil {
+			s.Settings.Kubernetes.RegistryBurst = aws.Int(int(*b.NodeClassKubeletConfig.RegistryBurst))
+		}
+		if b.NodeClassKubeletConfig.CPUManagerPolicy != nil {
+			s.Settings.Kubernetes.CPUManagerPolicy = b.NodeClassKubeletConfig.CPUManagerPolicy
+		}
+		if b.NodeClassKubeletConfig.TopologyManagerPolicy != nil {
+			s.Settings.Kubernetes.TopologyManagerPolicy = b.NodeClassKubeletConfig.TopologyManagerPolicy
+		}
+		if b.NodeClassKubeletConfig.MemoryManagerPolicy != nil {
+			s.Settings.Kubernetes.MemoryManagerPolicy = b.NodeClassKubeletConfig.MemoryManagerPolicy
+		}
+	}
 
 	s.Settings.Kubernetes.NodeTaints = map[string][]string{}
 	for _, taint := range b.Taints {
@@ -16,6 +16,9 @@ package bootstrap
 
 import (
 	"github.com/pelletier/go-toml/v2"
+	"github.com/samber/lo"
+
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
 )
 
 func NewBottlerocketConfig(userdata *string) (*BottlerocketConfig, error) {
@@ -68,6 +71,8 @@ type BottlerocketKubernetes struct {
 	CPUManagerPolicy                   *string                          `toml:"cpu-manager-policy,omitempty"`
 	CPUManagerReconcilePeriod          *string                          `toml:"cpu-manager-reconcile-period,omitempty"`
 	TopologyManagerScope               *string                          `toml:"topology-manager-scope,omitempty"`
+	TopologyManagerPolicy              *string                          `toml:"topology-manager-policy,omitempty"`
+	MemoryManagerPolicy                *string                          `toml:"memory-manager-policy,omitempty"`
 	ImageGCHighThresholdPercent        *string                          `toml:"image-gc-high-threshold-percent,omitempty"`
 	ImageGCLowThresholdPercent         *string                          `toml:"image-gc-low-threshold-percent,omitempty"`
 	CPUCFSQuota                        *bool                            `toml:"cpu-cfs-quota-enforced,omitempty"`
@@ -96,6 +101,76 @@ func (c *BottlerocketConfig) UnmarshalTOML(data []byte) error {
 	return nil
 }
 
+// MergeSettings merges the NodeClass's Bottlerocket settings into the untyped settings, overriding any of the same
+// settings from custom UserData. Registry mirrors are merged by registry.
+func (c *BottlerocketConfig) MergeSettings(settings *v1beta1.BottlerocketSettings) {
+	if settings == nil {
+		return
+	}
+	if c.SettingsRaw == nil {
+		c.SettingsRaw = map[string]interface{}{}
+	}
+	for name, container := range settings.HostContainers {
+		table := tomlTable(tomlTable(c.SettingsRaw, "host-containers"), name)
+		setIfNotNil(table, "source", container.Source)
+		setIfNotNil(table, "enabled", container.Enabled)
+		setIfNotNil(table, "superpowered", container.Superpowered)
+		setIfNotNil(table, "user-data", container.UserData)
+	}
+	for name, container := range settings.BootstrapContainers {
+		table := tomlTable(tomlTable(c.SettingsRaw, "bootstrap-containers"), name)
+		setIfNotNil(table, "source", container.Source)
+		setIfNotNil(table, "mode", container.Mode)
+		setIfNotNil(table, "essential", container.Essential)
+		setIfNotNil(table, "user-data", container.UserData)
+	}
+	if len(settings.KernelSysctl) > 0 {
+		sysctl := tomlTable(tomlTable(c.SettingsRaw, "kernel"), "sysctl")
+		for key, value := range settings.KernelSysctl {
+			sysctl[key] = value
+		}
+	}
+	if len(settings.RegistryMirrors) > 0 {
+		registry := tomlTable(c.SettingsRaw, "container-registry")
+		registry["mirrors"] = mergeByRegistry(registry["mirrors"], lo.Map(settings.RegistryMirrors, func(m v1beta1.BottlerocketRegistryMirror, _ int) map[string]interface{} {
+			return map[string]interface{}{"registry": m.Registry, "endpoint": m.Endpoints}
+		}))
+	}
+}
+
+// tomlTable returns the table at key in parent, replacing any value that isn't a table
+func tomlTable(parent map[string]interface{}, key string) map[string]interface{} {
+	if table, ok := parent[key].(map[string]interface{}); ok {
+		return table
+	}
+	table := map[string]interface{}{}
+	parent[key] = table
+	return table
+}
+
+func setIfNotNil[T any](table map[string]interface{}, key string, value *T) {
+	if value != nil {
+		table[key] = *value
+	}
+}
+
+// mergeByRegistry replaces the entries of an array of tables that have the same registry as an override, and appends
+// the rest of the overrides
+func mergeByRegistry(existing interface{}, overrides []map[string]interface{}) []interface{} {
+	var merged []interface{}
+	entries, _ := existing.([]interface{})
+	for _, entry := range entries {
+		if table, ok := entry.(map[string]interface{}); ok && lo.ContainsBy(overrides, func(o map[string]interface{}) bool { return o["registry"] == table["registry"] }) {
+			continue
+		}
+		merged = append(merged, entry)
+	}
+	for _, override := range overrides {
+		merged = append(merged, override)
+	}
+	return merged
+}
+
 func (c *BottlerocketConfig) MarshalTOML() ([]byte, error) {
 	if c.SettingsRaw == nil {
 		c.SettingsRaw = map[string]interface{}{}
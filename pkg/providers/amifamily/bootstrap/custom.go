@@ -16,14 +16,32 @@ package bootstrap
 
 import (
 	"encoding/base64"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/samber/lo"
 )
 
+// nvidiaContainerRuntimeScript makes the NVIDIA container runtime containerd's default runtime, so that GPU pods don't
+// need a RuntimeClass
+const nvidiaContainerRuntimeScript = `#!/bin/bash -xe
+nvidia-ctk runtime configure --runtime=containerd --set-as-default
+systemctl restart containerd
+`
+
 type Custom struct {
 	Options
+	// NVIDIAContainerRuntime configures containerd for the NVIDIA container runtime before the custom userData runs
+	NVIDIAContainerRuntime bool
 }
 
 func (e Custom) Script() (string, error) {
-	return base64.StdEncoding.EncodeToString([]byte(aws.StringValue(e.Options.CustomUserData))), nil
+	if !e.NVIDIAContainerRuntime {
+		return base64.StdEncoding.EncodeToString([]byte(aws.StringValue(e.Options.CustomUserData))), nil
+	}
+	userData, err := EKS{}.mergeCustomUserData(lo.Compact([]string{nvidiaContainerRuntimeScript, lo.FromPtr(e.CustomUserData)})...)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString([]byte(strings.ReplaceAll(userData, "\r", ""))), nil
 }
@@ -16,7 +16,9 @@ package bootstrap
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -25,30 +27,71 @@ import (
 	"net"
 	"net/mail"
 	"net/textproto"
+	"path"
 	"strings"
 
 	"github.com/samber/lo"
+
+	"github.com/aws/karpenter-core/pkg/utils/resources"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
 )
 
 type EKS struct {
 	Options
-	ContainerRuntime string
+	ContainerRuntime    string
+	ContainerRegistries []v1beta1.ContainerRegistry
+	// KubeletConfigFile merges the kubelet settings into the AMI's kubelet config file rather than passing them to
+	// bootstrap.sh as kubelet flags
+	KubeletConfigFile bool
+	// PostBootstrap merges the custom UserData after the bootstrap script rather than before it, so that it runs once
+	// the kubelet has started
+	PostBootstrap bool
+	// Compress gzip compresses the merged UserData, which cloud-init detects and decompresses
+	Compress bool
 }
 
 const (
 	Boundary                      = "//"
 	MIMEVersionHeader             = "MIME-Version: 1.0"
 	MIMEContentTypeHeaderTemplate = "Content-Type: multipart/mixed; boundary=\"%s\""
+	// ContainerdHostsDir is the config_path that the EKS optimized AMI's containerd config looks up registry hosts in
+	ContainerdHostsDir = "/etc/containerd/certs.d"
 )
 
 func (e EKS) Script() (string, error) {
-	userData, err := e.mergeCustomUserData(lo.Compact([]string{lo.FromPtr(e.CustomUserData), e.eksBootstrapScript()})...)
+	userDatas := []string{lo.FromPtr(e.CustomUserData), e.eksBootstrapScript()}
+	if e.PostBootstrap {
+		userDatas[0], userDatas[1] = userDatas[1], userDatas[0]
+	}
+	userData, err := e.mergeCustomUserData(lo.Compact(userDatas)...)
 	if err != nil {
 		return "", err
 	}
 	// The mime/multipart package adds carriage returns, while the rest of our logic does not. Remove all
 	// carriage returns for consistency.
-	return base64.StdEncoding.EncodeToString([]byte(strings.ReplaceAll(userData, "\r", ""))), nil
+	return e.encode([]byte(strings.ReplaceAll(userData, "\r", "")))
+}
+
+// encode base64 encodes the merged UserData, compressing it first if it's enabled, and fails if the result is larger
+// than EC2 accepts, since the launch template would otherwise only be rejected when it's created
+func (e EKS) encode(userData []byte) (string, error) {
+	if e.Compress {
+		var buffer bytes.Buffer
+		writer := gzip.NewWriter(&buffer)
+		if _, err := writer.Write(userData); err != nil {
+			return "", fmt.Errorf("compressing user data, %w", err)
+		}
+		if err := writer.Close(); err != nil {
+			return "", fmt.Errorf("compressing user data, %w", err)
+		}
+		userData = buffer.Bytes()
+	}
+	encoded := base64.StdEncoding.EncodeToString(userData)
+	if len(encoded) > v1beta1.UserDataMaxSize {
+		return "", fmt.Errorf("merged user data is %d bytes once base64 encoded, exceeding the EC2 limit of %d bytes%s",
+			len(encoded), v1beta1.UserDataMaxSize, lo.Ternary(e.Compress, "", ", userDataCompression can be set to Gzip to compress it"))
+	}
+	return encoded, nil
 }
 
 //nolint:gocyclo
@@ -60,6 +103,8 @@ func (e EKS) eksBootstrapScript() string {
 	var userData bytes.Buffer
 	userData.WriteString("#!/bin/bash -xe\n")
 	userData.WriteString("exec > >(tee /var/log/user-data.log|logger -t user-data -s 2>/dev/console) 2>&1\n")
+	userData.WriteString(e.containerRegistriesScript())
+	userData.WriteString(e.kubeletConfigScript())
 	// Due to the way bootstrap.sh is written, parameters should not be passed to it with an equal sign
 	userData.WriteString(fmt.Sprintf("/etc/eks/bootstrap.sh '%s' --apiserver-endpoint '%s' %s", e.ClusterName, e.ClusterEndpoint, caBundleArg))
 
@@ -81,9 +126,63 @@ func (e EKS) eksBootstrapScript() string {
 	return userData.String()
 }
 
+// containerRegistriesScript writes a containerd hosts.toml for each registry, which containerd reads when bootstrap.sh
+// restarts it. Mirrors are configured in the hosts directory rather than in a config.d drop-in since containerd rejects
+// registry mirrors in its config when the config_path is set.
+func (e EKS) containerRegistriesScript() string {
+	if e.ContainerRuntime != "containerd" {
+		return ""
+	}
+	var script bytes.Buffer
+	for _, registry := range e.ContainerRegistries {
+		dir := path.Join(ContainerdHostsDir, registry.Registry)
+		script.WriteString(fmt.Sprintf("mkdir -p '%s'\n", dir))
+		script.WriteString(fmt.Sprintf("cat <<'EOF' > '%s/hosts.toml'\n", dir))
+		for _, mirror := range registry.Mirrors {
+			script.WriteString(fmt.Sprintf("[host.%q]\n", mirror))
+			script.WriteString("  capabilities = [\"pull\", \"resolve\"]\n")
+			if registry.Auth != nil {
+				script.WriteString(fmt.Sprintf("  [host.%q.header]\n", mirror))
+				script.WriteString(fmt.Sprintf("    authorization = \"Basic %s\"\n", *registry.Auth))
+			}
+		}
+		script.WriteString("EOF\n")
+	}
+	return script.String()
+}
+
+// kubeletConfigScript merges the kubelet configuration into the AMI's kubelet config file, which bootstrap.sh then
+// updates in place with the cluster's settings. Top-level fields replace the AMI's defaults, e.g. all of evictionHard.
+func (e EKS) kubeletConfigScript() string {
+	if !e.KubeletConfigFile {
+		return ""
+	}
+	config := e.kubeletConfiguration()
+	// Set the static value for maxPods to 110 when AWSENILimitedPodDensity is explicitly disabled and the value isn't set
+	if !e.AWSENILimitedPodDensity && config.MaxPods == nil {
+		config.MaxPods = lo.ToPtr(int32(110))
+	}
+	raw := lo.Must(json.Marshal(config))
+	if string(raw) == "{}" {
+		return ""
+	}
+	var script bytes.Buffer
+	script.WriteString(fmt.Sprintf("cat <<'EOF' > %s\n%s\nEOF\n", KarpenterKubeletConfigPath, raw))
+	script.WriteString(fmt.Sprintf("echo \"$(jq -s '.[0] + .[1]' %s %s)\" > %s\n", KubeletConfigPath, KarpenterKubeletConfigPath, KubeletConfigPath))
+	return script.String()
+}
+
 // kubeletExtraArgs for the EKS bootstrap.sh script uses the concept of ENI-limited pod density to set pods
 // If this argument is explicitly disabled, then set the max-pods value on the kubelet to the static value of 110
 func (e EKS) kubeletExtraArgs() []string {
+	if e.KubeletConfigFile {
+		// Node labels have no config file equivalent, and bootstrap.sh overwrites kubeReserved in the config file
+		args := []string{e.nodeLabelArg(), e.nodeTaintArg()}
+		if e.KubeletConfig != nil {
+			args = append(args, joinParameterArgs("--kube-reserved", resources.StringMap(e.KubeletConfig.KubeReserved), "="))
+		}
+		return lo.Compact(args)
+	}
 	args := e.Options.kubeletExtraArgs()
 	// Set the static value for --max-pods to 110 when AWSENILimitedPodDensity is explicitly disabled and the value isn't set
 	if !e.AWSENILimitedPodDensity && (e.KubeletConfig == nil || e.KubeletConfig.MaxPods == nil) {
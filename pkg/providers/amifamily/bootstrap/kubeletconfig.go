@@ -0,0 +1,85 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/aws/karpenter-core/pkg/utils/resources"
+)
+
+const (
+	// KubeletConfigPath is the kubelet config file of the EKS optimized AL2 AMI, which bootstrap.sh updates in place
+	KubeletConfigPath = "/etc/kubernetes/kubelet/kubelet-config.json"
+	// KarpenterKubeletConfigPath is where the kubelet configuration is written before it's merged into KubeletConfigPath
+	KarpenterKubeletConfigPath = "/etc/kubernetes/kubelet/kubelet-config-karpenter.json"
+)
+
+// KubeletConfiguration is the subset of the kubelet's config file that Karpenter configures, see
+// https://kubernetes.io/docs/reference/config-api/kubelet-config.v1beta1/
+type KubeletConfiguration struct {
+	MaxPods                         *int32            `json:"maxPods,omitempty"`
+	PodsPerCore                     *int32            `json:"podsPerCore,omitempty"`
+	SystemReserved                  map[string]string `json:"systemReserved,omitempty"`
+	EvictionHard                    map[string]string `json:"evictionHard,omitempty"`
+	EvictionSoft                    map[string]string `json:"evictionSoft,omitempty"`
+	EvictionSoftGracePeriod         map[string]string `json:"evictionSoftGracePeriod,omitempty"`
+	EvictionMaxPodGracePeriod       *int32            `json:"evictionMaxPodGracePeriod,omitempty"`
+	ImageGCHighThresholdPercent     *int32            `json:"imageGCHighThresholdPercent,omitempty"`
+	ImageGCLowThresholdPercent      *int32            `json:"imageGCLowThresholdPercent,omitempty"`
+	CPUCFSQuota                     *bool             `json:"cpuCFSQuota,omitempty"`
+	ShutdownGracePeriod             *string           `json:"shutdownGracePeriod,omitempty"`
+	ShutdownGracePeriodCriticalPods *string           `json:"shutdownGracePeriodCriticalPods,omitempty"`
+	RegistryPullQPS                 *int32            `json:"registryPullQPS,omitempty"`
+	RegistryBurst                   *int32            `json:"registryBurst,omitempty"`
+	CPUManagerPolicy                *string           `json:"cpuManagerPolicy,omitempty"`
+	TopologyManagerPolicy           *string           `json:"topologyManagerPolicy,omitempty"`
+	MemoryManagerPolicy             *string           `json:"memoryManagerPolicy,omitempty"`
+}
+
+// kubeletConfiguration converts the kubelet settings of the NodePool and the NodeClass into the kubelet's config file.
+// KubeReserved isn't included since bootstrap.sh overwrites it in the config file with its own calculation.
+func (o Options) kubeletConfiguration() KubeletConfiguration {
+	config := KubeletConfiguration{}
+	if o.KubeletConfig != nil {
+		config.MaxPods = o.KubeletConfig.MaxPods
+		config.PodsPerCore = o.KubeletConfig.PodsPerCore
+		config.SystemReserved = resources.StringMap(o.KubeletConfig.SystemReserved)
+		config.EvictionHard = o.KubeletConfig.EvictionHard
+		config.EvictionSoft = o.KubeletConfig.EvictionSoft
+		if o.KubeletConfig.EvictionSoftGracePeriod != nil {
+			config.EvictionSoftGracePeriod = lo.MapValues(o.KubeletConfig.EvictionSoftGracePeriod, func(v metav1.Duration, _ string) string { return v.Duration.String() })
+		}
+		config.EvictionMaxPodGracePeriod = o.KubeletConfig.EvictionMaxPodGracePeriod
+		config.ImageGCHighThresholdPercent = o.KubeletConfig.ImageGCHighThresholdPercent
+		config.ImageGCLowThresholdPercent = o.KubeletConfig.ImageGCLowThresholdPercent
+		config.CPUCFSQuota = o.KubeletConfig.CPUCFSQuota
+	}
+	if o.NodeClassKubeletConfig != nil {
+		if o.NodeClassKubeletConfig.ShutdownGracePeriod != nil {
+			config.ShutdownGracePeriod = lo.ToPtr(o.NodeClassKubeletConfig.ShutdownGracePeriod.Duration.String())
+		}
+		if o.NodeClassKubeletConfig.ShutdownGracePeriodCriticalPods != nil {
+			config.ShutdownGracePeriodCriticalPods = lo.ToPtr(o.NodeClassKubeletConfig.ShutdownGracePeriodCriticalPods.Duration.String())
+		}
+		config.RegistryPullQPS = o.NodeClassKubeletConfig.RegistryPullQPS
+		config.RegistryBurst = o.NodeClassKubeletConfig.RegistryBurst
+		config.CPUManagerPolicy = o.NodeClassKubeletConfig.CPUManagerPolicy
+		config.TopologyManagerPolicy = o.NodeClassKubeletConfig.TopologyManagerPolicy
+		config.MemoryManagerPolicy = o.NodeClassKubeletConfig.MemoryManagerPolicy
+	}
+	return config
+}
@@ -21,10 +21,14 @@ import (
 	"strings"
 
 	"github.com/samber/lo"
+
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
 )
 
 type Windows struct {
 	Options
+	// WindowsConfiguration sets up the CSI proxy and gMSA after the custom userData and before the node bootstraps
+	WindowsConfiguration *v1beta1.WindowsConfiguration
 }
 
 // nolint:gocyclo
@@ -37,6 +41,11 @@ func (w Windows) Script() (string, error) {
 		userData.WriteString(customUserData + "\n")
 	}
 
+	if w.WindowsConfiguration != nil {
+		w.writeCSIProxy(&userData)
+		w.writeGMSA(&userData)
+	}
+
 	userData.WriteString("[string]$EKSBootstrapScriptFile = \"$env:ProgramFiles\\Amazon\\EKS\\Start-EKSBootstrap.ps1\"\n")
 	userData.WriteString(fmt.Sprintf(`& $EKSBootstrapScriptFile -EKSClusterName '%s' -APIServerEndpoint '%s'`, w.ClusterName, w.ClusterEndpoint))
 	if w.CABundle != nil {
@@ -54,3 +63,25 @@ func (w Windows) Script() (string, error) {
 	userData.WriteString("\n</powershell>")
 	return base64.StdEncoding.EncodeToString(userData.Bytes()), nil
 }
+
+// writeCSIProxy registers the CSI proxy as a service that starts with the node, downloading the binary first if a
+// binaryURL is set
+func (w Windows) writeCSIProxy(userData *bytes.Buffer) {
+	if w.WindowsConfiguration.CSIProxy == nil {
+		return
+	}
+	userData.WriteString("[string]$CSIProxyBinaryFile = \"$env:ProgramFiles\\Amazon\\EKS\\bin\\csi-proxy.exe\"\n")
+	if binaryURL := lo.FromPtr(w.WindowsConfiguration.CSIProxy.BinaryURL); binaryURL != "" {
+		userData.WriteString(fmt.Sprintf("Invoke-WebRequest -UseBasicParsing -Uri '%s' -OutFile $CSIProxyBinaryFile\n", binaryURL))
+	}
+	userData.WriteString("New-Service -Name csiproxy -BinaryPathName \"$CSIProxyBinaryFile -windows-service\" -StartupType Automatic\n")
+	userData.WriteString("Start-Service -Name csiproxy\n")
+}
+
+// writeGMSA registers the Container Credential Guard plugin that retrieves the gMSA credentials of credential specs
+func (w Windows) writeGMSA(userData *bytes.Buffer) {
+	if w.WindowsConfiguration.GMSA == nil {
+		return
+	}
+	userData.WriteString(fmt.Sprintf("New-Item -Path 'HKLM:\\SYSTEM\\CurrentControlSet\\Control\\CCG\\COMClasses\\{%s}' -Force | Out-Null\n", w.WindowsConfiguration.GMSA.PluginCLSID))
+}
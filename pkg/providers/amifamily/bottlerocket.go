@@ -28,7 +28,6 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
 
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 )
@@ -39,7 +38,8 @@ type Bottlerocket struct {
 }
 
 // DefaultAMIs returns the AMI name, and Requirements, with an SSM query
-func (b Bottlerocket) DefaultAMIs(version string, isNodeTemplate bool) []DefaultAMIOutput {
+func (b Bottlerocket) DefaultAMIs(version string, isNodeTemplate bool, nvidiaDriver string) []DefaultAMIOutput {
+	gpuVariant := lo.Ternary(nvidiaDriver == v1beta1.NVIDIADriverOpen, "nvidia-open", "nvidia")
 	return []DefaultAMIOutput{
 		{
 			Query: fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s/x86_64/latest/image_id", version),
@@ -50,14 +50,14 @@ func (b Bottlerocket) DefaultAMIs(version string, isNodeTemplate bool) []Default
 			),
 		},
 		{
-			Query: fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s-nvidia/x86_64/latest/image_id", version),
+			Query: fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s-%s/x86_64/latest/image_id", version, gpuVariant),
 			Requirements: scheduling.NewRequirements(
 				scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, corev1beta1.ArchitectureAmd64),
 				scheduling.NewRequirement(lo.Ternary(isNodeTemplate, v1alpha1.LabelInstanceGPUCount, v1beta1.LabelInstanceGPUCount), v1.NodeSelectorOpExists),
 			),
 		},
 		{
-			Query: fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s-nvidia/x86_64/latest/image_id", version),
+			Query: fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s-%s/x86_64/latest/image_id", version, gpuVariant),
 			Requirements: scheduling.NewRequirements(
 				scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, corev1beta1.ArchitectureAmd64),
 				scheduling.NewRequirement(lo.Ternary(isNodeTemplate, v1alpha1.LabelInstanceAcceleratorCount, v1beta1.LabelInstanceAcceleratorCount), v1.NodeSelectorOpExists),
@@ -72,14 +72,14 @@ func (b Bottlerocket) DefaultAMIs(version string, isNodeTemplate bool) []Default
 			),
 		},
 		{
-			Query: fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s-nvidia/%s/latest/image_id", version, corev1beta1.ArchitectureArm64),
+			Query: fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s-%s/%s/latest/image_id", version, gpuVariant, corev1beta1.ArchitectureArm64),
 			Requirements: scheduling.NewRequirements(
 				scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, corev1beta1.ArchitectureArm64),
 				scheduling.NewRequirement(lo.Ternary(isNodeTemplate, v1alpha1.LabelInstanceGPUCount, v1beta1.LabelInstanceGPUCount), v1.NodeSelectorOpExists),
 			),
 		},
 		{
-			Query: fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s-nvidia/%s/latest/image_id", version, corev1beta1.ArchitectureArm64),
+			Query: fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s-%s/%s/latest/image_id", version, gpuVariant, corev1beta1.ArchitectureArm64),
 			Requirements: scheduling.NewRequirements(
 				scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, corev1beta1.ArchitectureArm64),
 				scheduling.NewRequirement(lo.Ternary(isNodeTemplate, v1alpha1.LabelInstanceAcceleratorCount, v1beta1.LabelInstanceAcceleratorCount), v1.NodeSelectorOpExists),
@@ -100,24 +100,15 @@ func (b Bottlerocket) UserData(kubeletConfig *corev1beta1.KubeletConfiguration,
 			Labels:                  labels,
 			CABundle:                caBundle,
 			CustomUserData:          customUserData,
+			NodeClassKubeletConfig:  b.Options.NodeClassKubeletConfig,
 		},
+		Settings: b.Options.BottlerocketSettings,
 	}
 }
 
 // DefaultBlockDeviceMappings returns the default block device mappings for the AMI Family
 func (b Bottlerocket) DefaultBlockDeviceMappings() []*v1beta1.BlockDeviceMapping {
-	xvdaEBS := DefaultEBS
-	xvdaEBS.VolumeSize = lo.ToPtr(resource.MustParse("4Gi"))
-	return []*v1beta1.BlockDeviceMapping{
-		{
-			DeviceName: aws.String("/dev/xvda"),
-			EBS:        &xvdaEBS,
-		},
-		{
-			DeviceName: b.EphemeralBlockDevice(),
-			EBS:        &DefaultEBS,
-		},
-	}
+	return v1beta1.DefaultBlockDeviceMappings(v1beta1.AMIFamilyBottlerocket)
 }
 
 func (b Bottlerocket) EphemeralBlockDevice() *string {
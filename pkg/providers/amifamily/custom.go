@@ -35,10 +35,11 @@ func (c Custom) UserData(_ *corev1beta1.KubeletConfiguration, _ []v1.Taint, _ ma
 		Options: bootstrap.Options{
 			CustomUserData: customUserData,
 		},
+		NVIDIAContainerRuntime: c.Options.NVIDIAContainerRuntime,
 	}
 }
 
-func (c Custom) DefaultAMIs(_ string, _ bool) []DefaultAMIOutput {
+func (c Custom) DefaultAMIs(_ string, _ bool, _ string) []DefaultAMIOutput {
 	return nil
 }
 
@@ -15,9 +15,13 @@ limitations under the License.
 package amifamily
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"net"
+	"text/template"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
@@ -26,26 +30,55 @@ import (
 	core "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
 	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/apis/v1beta1"
 	"github.com/aws/karpenter/pkg/providers/amifamily/bootstrap"
+	"github.com/aws/karpenter/pkg/utils"
 
 	"github.com/aws/karpenter-core/pkg/cloudprovider"
 	"github.com/aws/karpenter-core/pkg/scheduling"
 )
 
-var DefaultEBS = v1beta1.BlockDevice{
-	Encrypted:  aws.Bool(true),
-	VolumeType: aws.String(ec2.VolumeTypeGp3),
-	VolumeSize: lo.ToPtr(resource.MustParse("20Gi")),
-}
+var DefaultEBS = *v1beta1.DefaultEBS("20Gi")
 
 // Resolver is able to fill-in dynamic launch template parameters
 type Resolver struct {
 	amiProvider *Provider
 }
 
+// IncompatibleError is returned when a NodeClaim's requirements can never be satisfied by the AMIs of its NodeClass's
+// AMIFamily, so launching it can't succeed until either of them changes
+type IncompatibleError struct {
+	error
+}
+
+func NewIncompatibleError(err error) error {
+	return &IncompatibleError{error: err}
+}
+
+func (e *IncompatibleError) Unwrap() error {
+	return e.error
+}
+
+func IsIncompatibleError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var incompatibleError *IncompatibleError
+	return errors.As(err, &incompatibleError)
+}
+
+// ValidateRequirements returns an IncompatibleError if the NodeClaim's requirements can never be satisfied by the AMIs
+// of the NodeClass's AMIFamily
+func ValidateRequirements(nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim) error {
+	if err := v1beta1.ValidateAMIFamilyRequirements(lo.FromPtrOr(nodeClass.Spec.AMIFamily, v1beta1.AMIFamilyAL2), scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...)); err != nil {
+		return NewIncompatibleError(err)
+	}
+	return nil
+}
+
 // Options define the static launch template parameters
 type Options struct {
 	ClusterName             string
@@ -59,6 +92,13 @@ type Options struct {
 	Labels                   map[string]string `hash:"ignore"`
 	KubeDNSIP                net.IP
 	AssociatePublicIPAddress *bool
+	BottlerocketSettings     *v1beta1.BottlerocketSettings
+	ContainerRegistries      []v1beta1.ContainerRegistry
+	NodeClassKubeletConfig   *v1beta1.KubeletConfiguration
+	NVIDIAContainerRuntime   bool
+	Windows                  *v1beta1.WindowsConfiguration
+	PostBootstrapUserData    bool
+	CompressUserData         bool
 }
 
 // LaunchTemplate holds the dynamically generated launch template parameters
@@ -70,11 +110,15 @@ type LaunchTemplate struct {
 	AMIID               string
 	InstanceTypes       []*cloudprovider.InstanceType `hash:"ignore"`
 	DetailedMonitoring  bool
+	CPUCredits          *string
+	EnclavesEnabled     bool
+	AMDSEVSNP           *string
+	ShutdownBehavior    *string
 }
 
 // AMIFamily can be implemented to override the default logic for generating dynamic launch template parameters
 type AMIFamily interface {
-	DefaultAMIs(version string, isNodeTemplate bool) []DefaultAMIOutput
+	DefaultAMIs(version string, isNodeTemplate bool, nvidiaDriver string) []DefaultAMIOutput
 	UserData(kubeletConfig *corev1beta1.KubeletConfiguration, taints []core.Taint, labels map[string]string, caBundle *string, instanceTypes []*cloudprovider.InstanceType, customUserData *string) bootstrap.Bootstrapper
 	DefaultBlockDeviceMappings() []*v1beta1.BlockDeviceMapping
 	DefaultMetadataOptions() *v1beta1.MetadataOptions
@@ -118,6 +162,9 @@ func New(amiProvider *Provider) *Resolver {
 // Multiple ResolvedTemplates are returned based on the instanceTypes passed in to support special AMIs for certain instance types like GPUs.
 func (r Resolver) Resolve(ctx context.Context, nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim, instanceTypes []*cloudprovider.InstanceType, options *Options) ([]*LaunchTemplate, error) {
 	amiFamily := GetAMIFamily(nodeClass.Spec.AMIFamily, options)
+	if err := ValidateRequirements(nodeClass, nodeClaim); err != nil {
+		return nil, err
+	}
 	amis, err := r.amiProvider.Get(ctx, nodeClass, options)
 	if err != nil {
 		return nil, err
@@ -125,49 +172,75 @@ func (r Resolver) Resolve(ctx context.Context, nodeClass *v1beta1.NodeClass, nod
 	if len(amis) == 0 {
 		return nil, fmt.Errorf("no amis exist given constraints")
 	}
-	mappedAMIs := amis.MapToInstanceTypes(instanceTypes, nodeClaim.IsMachine)
+	bootSupport, err := r.amiProvider.GetBootSupport(ctx, amis)
+	if err != nil {
+		return nil, err
+	}
+	mappedAMIs := amis.MapToInstanceTypes(instanceTypes, nodeClaim.IsMachine, bootSupport)
 	if len(mappedAMIs) == 0 {
 		return nil, fmt.Errorf("no instance types satisfy requirements of amis %v", amis)
 	}
+	userData, err := renderUserData(nodeClass, nodeClaim, options)
+	if err != nil {
+		return nil, err
+	}
 	var resolvedTemplates []*LaunchTemplate
 	for amiID, instanceTypes := range mappedAMIs {
-		maxPodsToInstanceTypes := lo.GroupBy(instanceTypes, func(instanceType *cloudprovider.InstanceType) int {
-			return int(instanceType.Capacity.Pods().Value())
-		})
 		// In order to support reserved ENIs for CNI custom networking setups,
 		// we need to pass down the max-pods calculation to the kubelet.
 		// This requires that we resolve a unique launch template per max-pods value.
-		for maxPods, instanceTypes := range maxPodsToInstanceTypes {
+		// The CPU credit specification only applies to burstable instance types, so they're resolved separately as well.
+		groupsToInstanceTypes := lo.GroupBy(instanceTypes, func(instanceType *cloudprovider.InstanceType) launchTemplateGroup {
+			return launchTemplateGroup{
+				maxPods:   int(instanceType.Capacity.Pods().Value()),
+				burstable: nodeClass.Spec.CPUCreditSpecification != nil && isBurstable(nodeClass, instanceType),
+			}
+		})
+		for group, instanceTypes := range groupsToInstanceTypes {
+			maxPods := group.maxPods
 			kubeletConfig := &corev1beta1.KubeletConfiguration{}
 			if nodeClaim.Spec.KubeletConfiguration != nil {
 				if err := mergo.Merge(kubeletConfig, nodeClaim.Spec.KubeletConfiguration); err != nil {
 					return nil, err
 				}
 			}
-			if kubeletConfig.MaxPods == nil {
+			// The NodeClass's maxPodsPerInstanceType overrides the NodePool's maxPods, so it's passed down as well
+			if kubeletConfig.MaxPods == nil || len(nodeClass.Spec.MaxPodsPerInstanceType) > 0 {
 				kubeletConfig.MaxPods = lo.ToPtr(int32(maxPods))
 			}
 			resolved := &LaunchTemplate{
 				Options: options,
 				UserData: amiFamily.UserData(
 					r.defaultClusterDNS(options, kubeletConfig),
-					append(nodeClaim.Spec.Taints, nodeClaim.Spec.StartupTaints...),
+					utils.MergeTaints(nodeClaim.Spec.Taints, nodeClaim.Spec.StartupTaints, nodeClass.Spec.StartupTaints),
 					options.Labels,
 					options.CABundle,
 					instanceTypes,
-					nodeClass.Spec.UserData,
+					userData,
 				),
 				BlockDeviceMappings: nodeClass.Spec.BlockDeviceMappings,
 				MetadataOptions:     nodeClass.Spec.MetadataOptions,
 				DetailedMonitoring:  aws.BoolValue(nodeClass.Spec.DetailedMonitoring),
+				CPUCredits:          lo.Ternary(group.burstable, nodeClass.Spec.CPUCreditSpecification, nil),
+				EnclavesEnabled:     nodeClass.Spec.EnclaveOptions != nil && aws.BoolValue(nodeClass.Spec.EnclaveOptions.Enabled),
+				AMDSEVSNP:           lo.TernaryF(nodeClass.Spec.CPUOptions != nil, func() *string { return nodeClass.Spec.CPUOptions.AMDSEVSNP }, func() *string { return nil }),
+				ShutdownBehavior:    nodeClass.Spec.InstanceInitiatedShutdownBehavior,
 				AMIID:               amiID,
 				InstanceTypes:       instanceTypes,
 			}
 			if len(resolved.BlockDeviceMappings) == 0 {
 				resolved.BlockDeviceMappings = amiFamily.DefaultBlockDeviceMappings()
 			}
+			if nodeClass.Spec.EphemeralStorageSizing != nil {
+				resolved.BlockDeviceMappings = sizeEphemeralBlockDevice(amiFamily, resolved.BlockDeviceMappings, nodeClass.Spec.EphemeralStorageSizing, nodeClaim.Spec.Resources.Requests)
+			}
 			if resolved.MetadataOptions == nil {
 				resolved.MetadataOptions = amiFamily.DefaultMetadataOptions()
+			} else if resolved.MetadataOptions.HTTPProtocolIPv6 == nil {
+				// NodeClasses are defaulted without httpProtocolIPv6, since it depends on the IP family of the cluster
+				metadataOptions := resolved.MetadataOptions.DeepCopy()
+				metadataOptions.HTTPProtocolIPv6 = amiFamily.DefaultMetadataOptions().HTTPProtocolIPv6
+				resolved.MetadataOptions = metadataOptions
 			}
 			resolvedTemplates = append(resolvedTemplates, resolved)
 		}
@@ -175,6 +248,97 @@ func (r Resolver) Resolve(ctx context.Context, nodeClass *v1beta1.NodeClass, nod
 	return resolvedTemplates, nil
 }
 
+type launchTemplateGroup struct {
+	maxPods   int
+	burstable bool
+}
+
+// isBurstable returns true if the instance type is in a burstable (T family) instance category
+func isBurstable(nodeClass *v1beta1.NodeClass, instanceType *cloudprovider.InstanceType) bool {
+	return instanceType.Requirements.Get(lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.LabelInstanceCategory, v1beta1.LabelInstanceCategory)).Has("t")
+}
+
+type userDataTemplateData struct {
+	ClusterName     string
+	ClusterEndpoint string
+	CABundle        string
+	NodePool        string
+}
+
+// renderUserData renders the UserData of the NodeClass as a template if UserDataTemplating is enabled, so that the
+// UserData that's merged by the AMIFamily has the cluster and NodePool of the node substituted
+func renderUserData(nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim, options *Options) (*string, error) {
+	if nodeClass.Spec.UserData == nil || !lo.FromPtr(nodeClass.Spec.UserDataTemplating) {
+		return nodeClass.Spec.UserData, nil
+	}
+	tmpl, err := template.New("userData").Parse(*nodeClass.Spec.UserData)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template for userData, %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, userDataTemplateData{
+		ClusterName:     options.ClusterName,
+		ClusterEndpoint: options.ClusterEndpoint,
+		CABundle:        lo.FromPtr(options.CABundle),
+		NodePool:        lo.Ternary(nodeClaim.IsMachine, nodeClaim.Labels[v1alpha5.ProvisionerNameLabelKey], nodeClaim.Labels[corev1beta1.NodePoolLabelKey]),
+	}); err != nil {
+		return nil, fmt.Errorf("rendering template for userData, %w", err)
+	}
+	return lo.ToPtr(buf.String()), nil
+}
+
+// sizeEphemeralBlockDevice returns a copy of the block device mappings where the ephemeral block device is resized
+// according to the sizing formula and the ephemeral-storage requests of the node
+func sizeEphemeralBlockDevice(amiFamily AMIFamily, blockDeviceMappings []*v1beta1.BlockDeviceMapping, sizing *v1beta1.EphemeralStorageSizing, requests core.ResourceList) []*v1beta1.BlockDeviceMapping {
+	device := amiFamily.EphemeralBlockDevice()
+	if device == nil {
+		return blockDeviceMappings
+	}
+	return lo.Map(blockDeviceMappings, func(blockDeviceMapping *v1beta1.BlockDeviceMapping, _ int) *v1beta1.BlockDeviceMapping {
+		if aws.StringValue(blockDeviceMapping.DeviceName) != *device || blockDeviceMapping.EBS == nil {
+			return blockDeviceMapping
+		}
+		sized := blockDeviceMapping.DeepCopy()
+		sized.EBS.VolumeSize = lo.ToPtr(EphemeralStorageSize(sizing, lo.FromPtrOr(blockDeviceMapping.EBS.VolumeSize, *DefaultEBS.VolumeSize), requests))
+		return sized
+	})
+}
+
+// LaunchedEphemeralStorage returns the size of the ephemeral block device that a node is launched with for its requests,
+// or false if the NodeClass doesn't size ephemeral storage at launch
+func LaunchedEphemeralStorage(nodeClass *v1beta1.NodeClass, requests core.ResourceList) (resource.Quantity, bool) {
+	amiFamily := GetAMIFamily(nodeClass.Spec.AMIFamily, &Options{})
+	if nodeClass.Spec.EphemeralStorageSizing == nil || amiFamily.EphemeralBlockDevice() == nil {
+		return resource.Quantity{}, false
+	}
+	blockDeviceMappings := lo.Ternary(len(nodeClass.Spec.BlockDeviceMappings) != 0, nodeClass.Spec.BlockDeviceMappings, amiFamily.DefaultBlockDeviceMappings())
+	blockDeviceMapping, ok := lo.Find(blockDeviceMappings, func(bdm *v1beta1.BlockDeviceMapping) bool {
+		return aws.StringValue(bdm.DeviceName) == *amiFamily.EphemeralBlockDevice() && bdm.EBS != nil
+	})
+	if !ok {
+		return resource.Quantity{}, false
+	}
+	return EphemeralStorageSize(nodeClass.Spec.EphemeralStorageSizing, lo.FromPtrOr(blockDeviceMapping.EBS.VolumeSize, *DefaultEBS.VolumeSize), requests), true
+}
+
+// EphemeralStorageSize is the ephemeral-storage request plus the sizing overhead, rounded up to the next power of two
+// GiB and bounded by the sizing min and max. Sizes are bucketed so that nodes with similar requests share a launch
+// template. The size of the unsized volume is used as the min if the sizing doesn't set one.
+func EphemeralStorageSize(sizing *v1beta1.EphemeralStorageSizing, defaultSize resource.Quantity, requests core.ResourceList) resource.Quantity {
+	size := requests.StorageEphemeral().DeepCopy()
+	if sizing.Overhead != nil {
+		size.Add(*sizing.Overhead)
+	}
+	size = resource.MustParse(fmt.Sprintf("%dGi", int64(math.Pow(2, math.Ceil(math.Log2(math.Max(1, float64(size.Value())/float64(1<<30))))))))
+	if minSize := lo.FromPtrOr(sizing.MinSize, defaultSize); size.Cmp(minSize) < 0 {
+		return minSize.DeepCopy()
+	}
+	if size.Cmp(sizing.MaxSize) > 0 {
+		return sizing.MaxSize.DeepCopy()
+	}
+	return size
+}
+
 func GetAMIFamily(amiFamily *string, options *Options) AMIFamily {
 	switch aws.StringValue(amiFamily) {
 	case v1alpha1.AMIFamilyBottlerocket:
@@ -34,7 +34,7 @@ type Ubuntu struct {
 }
 
 // DefaultAMIs returns the AMI name, and Requirements, with an SSM query
-func (u Ubuntu) DefaultAMIs(version string, _ bool) []DefaultAMIOutput {
+func (u Ubuntu) DefaultAMIs(version string, _ bool, _ string) []DefaultAMIOutput {
 	return []DefaultAMIOutput{
 		{
 			Query: fmt.Sprintf("/aws/service/canonical/ubuntu/eks/20.04/%s/stable/current/%s/hvm/ebs-gp2/ami-id", version, corev1beta1.ArchitectureAmd64),
@@ -69,10 +69,7 @@ func (u Ubuntu) UserData(kubeletConfig *corev1beta1.KubeletConfiguration, taints
 
 // DefaultBlockDeviceMappings returns the default block device mappings for the AMI Family
 func (u Ubuntu) DefaultBlockDeviceMappings() []*v1beta1.BlockDeviceMapping {
-	return []*v1beta1.BlockDeviceMapping{{
-		DeviceName: u.EphemeralBlockDevice(),
-		EBS:        &DefaultEBS,
-	}}
+	return v1beta1.DefaultBlockDeviceMappings(v1beta1.AMIFamilyUbuntu)
 }
 
 func (u Ubuntu) EphemeralBlockDevice() *string {
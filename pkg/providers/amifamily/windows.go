@@ -22,7 +22,6 @@ import (
 	"github.com/aws/karpenter/pkg/apis/v1beta1"
 
 	"github.com/samber/lo"
-	"k8s.io/apimachinery/pkg/api/resource"
 
 	"github.com/aws/aws-sdk-go/aws"
 
@@ -40,12 +39,14 @@ type Windows struct {
 	*Options
 	Version string
 	Build   string
+	// Variant is the Core or Full variant of the Windows Server AMIs, which defaults to Core
+	Variant string
 }
 
-func (w Windows) DefaultAMIs(version string, _ bool) []DefaultAMIOutput {
+func (w Windows) DefaultAMIs(version string, _ bool, _ string) []DefaultAMIOutput {
 	return []DefaultAMIOutput{
 		{
-			Query: fmt.Sprintf("/aws/service/ami-windows-latest/Windows_Server-%s-English-%s-EKS_Optimized-%s/image_id", w.Version, v1alpha1.WindowsCore, version),
+			Query: fmt.Sprintf("/aws/service/ami-windows-latest/Windows_Server-%s-English-%s-EKS_Optimized-%s/image_id", w.Version, lo.Ternary(w.Variant != "", w.Variant, v1alpha1.WindowsCore), version),
 			Requirements: scheduling.NewRequirements(
 				scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, corev1beta1.ArchitectureAmd64),
 				scheduling.NewRequirement(v1.LabelOSStable, v1.NodeSelectorOpIn, string(v1.Windows)),
@@ -67,17 +68,13 @@ func (w Windows) UserData(kubeletConfig *corev1beta1.KubeletConfiguration, taint
 			CABundle:        caBundle,
 			CustomUserData:  customUserData,
 		},
+		WindowsConfiguration: w.Options.Windows,
 	}
 }
 
 // DefaultBlockDeviceMappings returns the default block device mappings for the AMI Family
 func (w Windows) DefaultBlockDeviceMappings() []*v1beta1.BlockDeviceMapping {
-	sda1EBS := DefaultEBS
-	sda1EBS.VolumeSize = lo.ToPtr(resource.MustParse("50Gi"))
-	return []*v1beta1.BlockDeviceMapping{{
-		DeviceName: w.EphemeralBlockDevice(),
-		EBS:        &sda1EBS,
-	}}
+	return v1beta1.DefaultBlockDeviceMappings(v1beta1.AMIFamilyWindows2022)
 }
 
 func (w Windows) EphemeralBlockDevice() *string {
@@ -0,0 +1,64 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ec2client
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/samber/lo"
+
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+)
+
+// Provider hands out the EC2 client that discovery for a NodeClass is made with. NodeClasses are discovered with the
+// role that they assume, and otherwise with the controller's client. A client is created once for each role and shared
+// by every NodeClass that uses it, so that credentials are cached and refreshed by the client rather than assumed on
+// every request.
+type Provider struct {
+	sync.Mutex
+	ec2api    ec2iface.EC2API
+	newEC2API func(roleARN string) ec2iface.EC2API
+	ec2apis   map[string]ec2iface.EC2API
+}
+
+func NewProvider(ec2api ec2iface.EC2API, newEC2API func(roleARN string) ec2iface.EC2API) *Provider {
+	return &Provider{
+		ec2api:    ec2api,
+		newEC2API: newEC2API,
+		ec2apis:   map[string]ec2iface.EC2API{},
+	}
+}
+
+// EC2API returns the client for the role of the NodeClass
+func (p *Provider) EC2API(nodeClass *v1beta1.NodeClass) ec2iface.EC2API {
+	key := p.Key(nodeClass)
+	if key == "" {
+		return p.ec2api
+	}
+	p.Lock()
+	defer p.Unlock()
+	if ec2api, ok := p.ec2apis[key]; ok {
+		return ec2api
+	}
+	p.ec2apis[key] = p.newEC2API(key)
+	return p.ec2apis[key]
+}
+
+// Key identifies the client of the NodeClass, and is empty for the controller's client. Resources that are described
+// with different clients are cached separately, as the same filters select different resources in another account.
+func (p *Provider) Key(nodeClass *v1beta1.NodeClass) string {
+	return lo.FromPtr(nodeClass.Spec.AssumeRoleARN)
+}
@@ -15,17 +15,25 @@ limitations under the License.
 package instance
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"math"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/patrickmn/go-cache"
 	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/multierr"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -37,12 +45,14 @@ import (
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/apis/v1beta1"
 	"github.com/aws/karpenter/pkg/batcher"
-	"github.com/aws/karpenter/pkg/cache"
+	awscache "github.com/aws/karpenter/pkg/cache"
 	awserrors "github.com/aws/karpenter/pkg/errors"
 	"github.com/aws/karpenter/pkg/providers/instancetype"
 	"github.com/aws/karpenter/pkg/providers/launchtemplate"
 	"github.com/aws/karpenter/pkg/providers/subnet"
 	"github.com/aws/karpenter/pkg/utils"
+	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
+	"github.com/aws/karpenter/pkg/utils/tracing"
 
 	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
 	"github.com/aws/karpenter-core/pkg/cloudprovider"
@@ -51,8 +61,13 @@ import (
 
 var (
 	// MaxInstanceTypes defines the number of instance type options to pass to CreateFleet
-	MaxInstanceTypes                 = 60
-	instanceTypeFlexibilityThreshold = 5 // falling back to on-demand without flexibility risks insufficient capacity errors
+	MaxInstanceTypes = 60
+	// MaxAllowedInstanceTypes is the number of instance types that EC2 accepts in the AllowedInstanceTypes of
+	// attribute-based instance type selection
+	MaxAllowedInstanceTypes = 400
+	// maxPricePercentageOverLowestPrice disables the price protection of attribute-based instance type selection
+	maxPricePercentageOverLowestPrice int64 = 999999
+	instanceTypeFlexibilityThreshold        = 5 // falling back to on-demand without flexibility risks insufficient capacity errors
 
 	instanceStateFilter = &ec2.Filter{
 		Name:   aws.String("instance-state-name"),
@@ -63,33 +78,58 @@ var (
 type Provider struct {
 	region                 string
 	ec2api                 ec2iface.EC2API
-	unavailableOfferings   *cache.UnavailableOfferings
+	unavailableOfferings   *awscache.UnavailableOfferings
 	instanceTypeProvider   *instancetype.Provider
 	subnetProvider         *subnet.Provider
 	launchTemplateProvider *launchtemplate.Provider
 	ec2Batcher             *batcher.EC2API
+	// dryRunCache holds the result of the last DryRun CreateFleet for each NodeClass, keyed by UID since NodeClasses
+	// and AWSNodeTemplates may share a name, so that a burst of launches only checks permissions once
+	dryRunCache *cache.Cache
+	// spotPlacementScoreCache holds the spot placement score of each zone ID for each set of instance types that spot
+	// instances were launched from
+	spotPlacementScoreCache *cache.Cache
+	// stoppedInstances holds the instances that were claimed to be started for a NodeClaim, so that concurrent launches
+	// don't claim the same stopped instance before DescribeInstances reflects that it's starting
+	stoppedInstances *cache.Cache
+	launchLimiter    *launchLimiter
+	mu               sync.Mutex
 }
 
-func NewProvider(ctx context.Context, region string, ec2api ec2iface.EC2API, unavailableOfferings *cache.UnavailableOfferings,
-	instanceTypeProvider *instancetype.Provider, subnetProvider *subnet.Provider, launchTemplateProvider *launchtemplate.Provider) *Provider {
+func NewProvider(ctx context.Context, region string, ec2api ec2iface.EC2API, unavailableOfferings *awscache.UnavailableOfferings,
+	instanceTypeProvider *instancetype.Provider, subnetProvider *subnet.Provider, launchTemplateProvider *launchtemplate.Provider, dryRunCache *cache.Cache,
+	spotPlacementScoreCache *cache.Cache) *Provider {
 	return &Provider{
-		region:                 region,
-		ec2api:                 ec2api,
-		unavailableOfferings:   unavailableOfferings,
-		instanceTypeProvider:   instanceTypeProvider,
-		subnetProvider:         subnetProvider,
-		launchTemplateProvider: launchTemplateProvider,
-		ec2Batcher:             batcher.EC2(ctx, ec2api),
+		region:                  region,
+		ec2api:                  ec2api,
+		unavailableOfferings:    unavailableOfferings,
+		instanceTypeProvider:    instanceTypeProvider,
+		subnetProvider:          subnetProvider,
+		launchTemplateProvider:  launchTemplateProvider,
+		ec2Batcher:              batcher.EC2(ctx, ec2api),
+		dryRunCache:             dryRunCache,
+		spotPlacementScoreCache: spotPlacementScoreCache,
+		stoppedInstances:        cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval),
+		launchLimiter:           newLaunchLimiter(),
 	}
 }
 
 func (p *Provider) Create(ctx context.Context, nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim, instanceTypes []*cloudprovider.InstanceType) (*Instance, error) {
 	instanceTypes = p.filterInstanceTypes(nodeClaim, instanceTypes)
 	instanceTypes = orderInstanceTypesByPrice(instanceTypes, scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...))
-	if len(instanceTypes) > MaxInstanceTypes {
-		instanceTypes = instanceTypes[0:MaxInstanceTypes]
+	// EC2 selects from the attributes of the instance requirements, so the fleet request doesn't grow with the number
+	// of instance types, up to the number of instance types that it allows
+	maxInstanceTypes := lo.Ternary(useInstanceRequirements(ctx, nodeClass), MaxAllowedInstanceTypes, MaxInstanceTypes)
+	if len(instanceTypes) > maxInstanceTypes {
+		instanceTypes = instanceTypes[0:maxInstanceTypes]
+	}
+	tags, err := getTags(ctx, nodeClass, nodeClaim, newTagTemplateData(nodeClass, nodeClaim))
+	if err != nil {
+		return nil, err
+	}
+	if instance, ok := p.startStoppedInstance(ctx, nodeClass, nodeClaim, instanceTypes); ok {
+		return instance, nil
 	}
-	tags := getTags(ctx, nodeClass, nodeClaim)
 	fleetInstance, err := p.launchInstance(ctx, nodeClass, nodeClaim, instanceTypes, tags)
 	if awserrors.IsLaunchTemplateNotFound(err) {
 		// retry once if launch template is not found. This allows karpenter to generate a new LT if the
@@ -99,7 +139,13 @@ func (p *Provider) Create(ctx context.Context, nodeClass *v1beta1.NodeClass, nod
 	if err != nil {
 		return nil, err
 	}
-	return NewInstanceFromFleet(fleetInstance, tags), nil
+	instance := NewInstanceFromFleet(fleetInstance, tags)
+	// Failing here would leak the instance that was just launched, so the instance is left with the tags that it was
+	// launched with
+	if err := p.tagLaunchedInstance(ctx, nodeClass, nodeClaim, instance); err != nil {
+		logging.FromContext(ctx).With("id", instance.ID).Errorf("tagging launched instance, %s", err)
+	}
+	return instance, nil
 }
 
 func (p *Provider) Link(ctx context.Context, id, provisionerName string) error {
@@ -189,21 +235,173 @@ func (p *Provider) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+// Stop stops the instance so that it can be started again for a later NodeClaim. The instance is tagged with the time
+// that it was stopped first, so that it isn't garbage collected until the stopped instance TTL expires. It's also
+// tagged with the NodePool and userData hashes of the NodeClaim, so that it's only started for NodeClaims that it would
+// have been launched with the same labels, taints, kubelet configuration and userData for.
+func (p *Provider) Stop(ctx context.Context, nodeClaim *corev1beta1.NodeClaim, id string) error {
+	tags := lo.Assign(
+		lo.PickByKeys(nodeClaim.Annotations, []string{corev1beta1.NodePoolHashAnnotationKey, v1beta1.AnnotationUserDataHash}),
+		map[string]string{v1beta1.AnnotationStoppedAt: time.Now().UTC().Format(time.RFC3339)},
+	)
+	if _, err := p.ec2api.CreateTagsWithContext(ctx, &ec2.CreateTagsInput{
+		Resources: aws.StringSlice([]string{id}),
+		Tags: lo.MapToSlice(tags, func(k, v string) *ec2.Tag {
+			return &ec2.Tag{Key: aws.String(k), Value: aws.String(v)}
+		}),
+	}); err != nil {
+		if awserrors.IsNotFound(err) {
+			return cloudprovider.NewNodeClaimNotFoundError(fmt.Errorf("tagging stopped instance, %w", err))
+		}
+		return fmt.Errorf("tagging stopped instance, %w", err)
+	}
+	if _, err := p.ec2api.StopInstancesWithContext(ctx, &ec2.StopInstancesInput{
+		InstanceIds: aws.StringSlice([]string{id}),
+	}); err != nil {
+		if awserrors.IsNotFound(err) {
+			return cloudprovider.NewNodeClaimNotFoundError(fmt.Errorf("instance already terminated"))
+		}
+		return fmt.Errorf("stopping instance, %w", err)
+	}
+	logging.FromContext(ctx).Infof("stopped instance")
+	return nil
+}
+
+// startStoppedInstance starts an instance that was stopped by stop-based consolidation rather than launching a new
+// one. Only on-demand instances of the same NodePool, NodePool hash, NodeClass hash and userData hash that are
+// compatible with the NodeClaim are started. The stopped-at tag is removed before the instance is started, so an instance that fails to start is
+// garbage collected rather than being tried again.
+func (p *Provider) startStoppedInstance(ctx context.Context, nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim, instanceTypes []*cloudprovider.InstanceType) (*Instance, bool) {
+	if !settings.FromContext(ctx).EnableStopBasedConsolidation || nodeClaim.IsMachine ||
+		lo.FromPtr(nodeClass.Spec.InstanceInitiatedShutdownBehavior) != v1beta1.InstanceInitiatedShutdownBehaviorStop ||
+		p.getCapacityType(nodeClaim, instanceTypes) != corev1beta1.CapacityTypeOnDemand {
+		return nil, false
+	}
+	instance, err := p.claimStoppedInstance(ctx, nodeClass, nodeClaim, instanceTypes)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("claiming stopped instance, %s", err)
+		return nil, false
+	}
+	if instance == nil {
+		return nil, false
+	}
+	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("id", instance.ID))
+	if _, err := p.ec2api.DeleteTagsWithContext(ctx, &ec2.DeleteTagsInput{
+		Resources: aws.StringSlice([]string{instance.ID}),
+		Tags:      []*ec2.Tag{{Key: aws.String(v1beta1.AnnotationStoppedAt)}},
+	}); err != nil {
+		logging.FromContext(ctx).Errorf("untagging stopped instance, %s", err)
+		return nil, false
+	}
+	if _, err := p.ec2api.StartInstancesWithContext(ctx, &ec2.StartInstancesInput{
+		InstanceIds: aws.StringSlice([]string{instance.ID}),
+	}); err != nil {
+		logging.FromContext(ctx).Errorf("starting stopped instance, %s", err)
+		return nil, false
+	}
+	delete(instance.Tags, v1beta1.AnnotationStoppedAt)
+	instance.State = ec2.InstanceStateNamePending
+	instance.LaunchTime = time.Now() // estimate the launch time since we just started the instance
+	if err := p.tagLaunchedInstance(ctx, nodeClass, nodeClaim, instance); err != nil {
+		logging.FromContext(ctx).Errorf("tagging started instance, %s", err)
+	}
+	logging.FromContext(ctx).With("instance-type", instance.Type, "zone", instance.Zone).Infof("started stopped instance")
+	return instance, true
+}
+
+// claimStoppedInstance returns the cheapest stopped instance that can be started for the NodeClaim, if there is one
+func (p *Provider) claimStoppedInstance(ctx context.Context, nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim, instanceTypes []*cloudprovider.InstanceType) (*Instance, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	out := &ec2.DescribeInstancesOutput{}
+	if err := p.ec2api.DescribeInstancesPagesWithContext(ctx, &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("instance-state-name"),
+				Values: aws.StringSlice([]string{ec2.InstanceStateNameStopped}),
+			},
+			{
+				Name:   aws.String("tag-key"),
+				Values: aws.StringSlice([]string{v1beta1.AnnotationStoppedAt}),
+			},
+			{
+				Name:   aws.String(fmt.Sprintf("tag:%s", corev1beta1.NodePoolLabelKey)),
+				Values: aws.StringSlice([]string{nodeClaim.Labels[corev1beta1.NodePoolLabelKey]}),
+			},
+			{
+				Name:   aws.String(fmt.Sprintf("tag:%s", corev1beta1.NodePoolHashAnnotationKey)),
+				Values: aws.StringSlice([]string{nodeClaim.Annotations[corev1beta1.NodePoolHashAnnotationKey]}),
+			},
+			{
+				Name:   aws.String(fmt.Sprintf("tag:%s", v1beta1.AnnotationNodeClassHash)),
+				Values: aws.StringSlice([]string{nodeClass.Hash()}),
+			},
+			{
+				Name:   aws.String("tag-key"),
+				Values: aws.StringSlice([]string{fmt.Sprintf("kubernetes.io/cluster/%s", settings.FromContext(ctx).ClusterName)}),
+			},
+		},
+	}, func(page *ec2.DescribeInstancesOutput, _ bool) bool {
+		out.Reservations = append(out.Reservations, page.Reservations...)
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("describing stopped instances, %w", err)
+	}
+	instances, err := instancesFromOutput(out)
+	if err != nil {
+		return nil, cloudprovider.IgnoreNodeClaimNotFoundError(err)
+	}
+	zones := scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...).Get(v1.LabelTopologyZone)
+	// instanceTypes are ordered by price, so the first instance type with a compatible stopped instance is the cheapest
+	for _, instanceType := range instanceTypes {
+		instance, ok := lo.Find(instances, func(i *Instance) bool {
+			_, claimed := p.stoppedInstances.Get(i.ID)
+			stoppedAt, err := time.Parse(time.RFC3339, i.Tags[v1beta1.AnnotationStoppedAt])
+			return !claimed && err == nil && time.Since(stoppedAt) < settings.FromContext(ctx).StoppedInstanceTTL &&
+				i.Tags[v1beta1.AnnotationUserDataHash] == nodeClass.Status.UserDataHash &&
+				i.Type == instanceType.Name && i.CapacityType == corev1beta1.CapacityTypeOnDemand && zones.Has(i.Zone) &&
+				lo.ContainsBy(instanceType.Offerings.Available(), func(o cloudprovider.Offering) bool {
+					return o.CapacityType == corev1beta1.CapacityTypeOnDemand && o.Zone == i.Zone
+				})
+		})
+		if ok {
+			p.stoppedInstances.SetDefault(instance.ID, nil)
+			return instance, nil
+		}
+	}
+	return nil, nil
+}
+
 func (p *Provider) launchInstance(ctx context.Context, nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim, instanceTypes []*cloudprovider.InstanceType, tags map[string]string) (*ec2.CreateFleetInstance, error) {
 	capacityType := p.getCapacityType(nodeClaim, instanceTypes)
-	zonalSubnets, err := p.subnetProvider.ZonalSubnetsForLaunch(ctx, nodeClass, instanceTypes, capacityType)
+	subnetsCtx, span := tracing.Start(ctx, "ResolveSubnets")
+	zonalSubnets, err := p.subnetProvider.ZonalSubnetsForLaunch(subnetsCtx, nodeClass, instanceTypes, capacityType)
+	tracing.End(span, err)
 	if err != nil {
 		return nil, fmt.Errorf("getting subnets, %w", err)
 	}
+	// Zone IDs aren't part of the offerings, so the zones are constrained by the zone IDs of their subnets
+	zoneIDs := scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...).Get(v1beta1.LabelTopologyZoneID)
+	zonalSubnets = lo.PickBy(zonalSubnets, func(_ string, subnet *ec2.Subnet) bool {
+		return zoneIDs.Has(aws.StringValue(subnet.AvailabilityZoneId))
+	})
+	if len(zonalSubnets) == 0 {
+		return nil, fmt.Errorf("no subnets found in zones with IDs %v", zoneIDs.Values())
+	}
 
 	// Get Launch Template Configs, which may differ due to GPU or Architecture requirements
-	launchTemplateConfigs, err := p.getLaunchTemplateConfigs(ctx, nodeClass, nodeClaim, instanceTypes, zonalSubnets, capacityType, tags)
+	launchTemplatesCtx, span := tracing.Start(ctx, "ResolveLaunchTemplates")
+	launchTemplateConfigs, err := p.getLaunchTemplateConfigs(launchTemplatesCtx, nodeClass, nodeClaim, instanceTypes, zonalSubnets, capacityType, tags)
+	tracing.End(span, err)
 	if err != nil {
 		return nil, fmt.Errorf("getting launch template configs, %w", err)
 	}
 	if err := p.checkODFallback(nodeClaim, instanceTypes, launchTemplateConfigs); err != nil {
 		logging.FromContext(ctx).Warn(err.Error())
 	}
+	// Overrides that are selected by attributes aren't for a single instance type, so they can't be prioritized or weighed
+	attributeBased := useInstanceRequirements(ctx, nodeClass)
 	// Create fleet
 	createFleetInput := &ec2.CreateFleetInput{
 		Type:                  aws.String(ec2.FleetTypeInstant),
@@ -215,30 +413,53 @@ func (p *Provider) launchInstance(ctx context.Context, nodeClass *v1beta1.NodeCl
 		},
 		TagSpecifications: []*ec2.TagSpecification{
 			{ResourceType: aws.String(ec2.ResourceTypeInstance), Tags: utils.MergeTags(tags)},
-			{ResourceType: aws.String(ec2.ResourceTypeVolume), Tags: utils.MergeTags(tags)},
+			{ResourceType: aws.String(ec2.ResourceTypeVolume), Tags: utils.MergeTags(tags, volumeTags(nodeClass))},
 			{ResourceType: aws.String(ec2.ResourceTypeFleet), Tags: utils.MergeTags(tags)},
 		},
 	}
 	if capacityType == corev1beta1.CapacityTypeSpot {
 		createFleetInput.SpotOptions = &ec2.SpotOptionsRequest{AllocationStrategy: aws.String(ec2.SpotAllocationStrategyPriceCapacityOptimized)}
+		if settings.FromContext(ctx).EnableSpotPlacementScores && !attributeBased {
+			if scores := p.getSpotPlacementScores(ctx, instanceTypes); len(scores) > 0 {
+				prioritizeZones(launchTemplateConfigs, zonalSubnets, scores)
+				createFleetInput.SpotOptions.AllocationStrategy = aws.String(ec2.SpotAllocationStrategyCapacityOptimizedPrioritized)
+			}
+		}
 	} else {
 		createFleetInput.OnDemandOptions = &ec2.OnDemandOptionsRequest{AllocationStrategy: aws.String(ec2.FleetOnDemandAllocationStrategyLowestPrice)}
 	}
+	if settings.FromContext(ctx).EnableWeightedCapacity && !attributeBased {
+		weighOverrides(launchTemplateConfigs, instanceTypes)
+	}
+	if lo.FromPtrOr(nodeClass.Spec.LaunchDryRun, settings.FromContext(ctx).EnableLaunchDryRun) {
+		if err := p.dryRunCreateFleet(ctx, nodeClass, createFleetInput); err != nil {
+			return nil, err
+		}
+	}
 
-	createFleetOutput, err := p.ec2Batcher.CreateFleet(ctx, createFleetInput)
+	nodePoolName := lo.Ternary(nodeClaim.IsMachine, nodeClaim.Labels[v1alpha5.ProvisionerNameLabelKey], nodeClaim.Labels[corev1beta1.NodePoolLabelKey])
+	launchLimitCtx, span := tracing.Start(ctx, "WaitForLaunchLimit")
+	release, err := p.launchLimiter.Acquire(launchLimitCtx, fmt.Sprintf("%s/%s", nodePoolName, nodeClass.Name))
+	tracing.End(span, err)
+	if err != nil {
+		return nil, err
+	}
+	createFleetCtx, span := tracing.Start(ctx, "CreateFleet", attribute.String("capacity-type", capacityType))
+	createFleetOutput, err := p.ec2Batcher.CreateFleet(createFleetCtx, createFleetInput)
+	tracing.End(span, err)
+	release()
 	p.subnetProvider.UpdateInflightIPs(createFleetInput, createFleetOutput, instanceTypes, lo.Values(zonalSubnets), capacityType)
 	if err != nil {
 		if awserrors.IsLaunchTemplateNotFound(err) {
 			for _, lt := range launchTemplateConfigs {
 				p.launchTemplateProvider.Invalidate(ctx, aws.StringValue(lt.LaunchTemplateSpecification.LaunchTemplateName), aws.StringValue(lt.LaunchTemplateSpecification.LaunchTemplateId))
 			}
-			return nil, fmt.Errorf("creating fleet %w", err)
 		}
 		var reqFailure awserr.RequestFailure
 		if errors.As(err, &reqFailure) {
-			return nil, fmt.Errorf("creating fleet %w (%s)", err, reqFailure.RequestID())
+			return nil, awserrors.NewLaunchError(fmt.Errorf("creating fleet %w (%s)", err, reqFailure.RequestID()))
 		}
-		return nil, fmt.Errorf("creating fleet %w", err)
+		return nil, awserrors.NewLaunchError(fmt.Errorf("creating fleet %w", err))
 	}
 	p.updateUnavailableOfferingsCache(ctx, createFleetOutput.Errors, capacityType)
 	if len(createFleetOutput.Instances) == 0 || len(createFleetOutput.Instances[0].InstanceIds) == 0 {
@@ -247,28 +468,188 @@ func (p *Provider) launchInstance(ctx context.Context, nodeClass *v1beta1.NodeCl
 	return createFleetOutput.Instances[0], nil
 }
 
-func getTags(ctx context.Context, nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim) map[string]string {
+// dryRunCreateFleet makes a DryRun CreateFleet with the same input as the launch so that credentials that have been
+// broken (e.g. rotated roles or SCP changes) surface as an authorization error before any real launch is attempted.
+// The result is cached per NodeClass, so the launches of a scale-up burst share the outcome of a single check.
+func (p *Provider) dryRunCreateFleet(ctx context.Context, nodeClass *v1beta1.NodeClass, createFleetInput *ec2.CreateFleetInput) error {
+	if err, ok := p.dryRunCache.Get(string(nodeClass.UID)); ok {
+		if err == nil {
+			return nil
+		}
+		return err.(error)
+	}
+	input := *createFleetInput
+	input.DryRun = aws.Bool(true)
+	if _, err := p.ec2api.CreateFleetWithContext(ctx, &input); err != nil && !awserrors.IsDryRunSucceeded(err) {
+		// Only authorization errors are cached, so that a transient failure doesn't block the launches that follow it
+		if !awserrors.IsUnauthorized(err) {
+			return fmt.Errorf("dry run creating fleet, %w", err)
+		}
+		err = awserrors.NewLaunchError(fmt.Errorf("dry run creating fleet, credentials are not authorized to launch instances, %w", err))
+		p.dryRunCache.SetDefault(string(nodeClass.UID), err)
+		return err
+	}
+	p.dryRunCache.SetDefault(string(nodeClass.UID), nil)
+	return nil
+}
+
+// tagLaunchedInstance tags the resources of a launched instance that couldn't be tagged at launch. Templated tags are
+// rendered again with the zone, instance type and capacity type that the launch resolved to, and values that differ
+// from the tags that the instance was launched with are tagged on the instance, its volumes and its primary network
+// interface. The primary network interface is tagged by the launch templates that Karpenter generates, so it's tagged
+// here with every tag when the NodeClass specifies its own launch template.
+func (p *Provider) tagLaunchedInstance(ctx context.Context, nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim, instance *Instance) error {
+	data := newTagTemplateData(nodeClass, nodeClaim)
+	data.Zone, data.InstanceType, data.CapacityType = instance.Zone, instance.Type, instance.CapacityType
+	tags, err := getTags(ctx, nodeClass, nodeClaim, data)
+	if err != nil {
+		return err
+	}
+	changed := lo.OmitBy(tags, func(k string, v string) bool { return instance.Tags[k] == v })
+	if len(changed) == 0 && nodeClass.Spec.LaunchTemplateName == nil {
+		return nil
+	}
+	out, err := p.ec2Batcher.DescribeInstances(ctx, &ec2.DescribeInstancesInput{InstanceIds: aws.StringSlice([]string{instance.ID})})
+	if err != nil {
+		return fmt.Errorf("describing instance, %w", err)
+	}
+	var volumeIDs []string
+	var networkInterfaceID *string
+	for _, reservation := range out.Reservations {
+		for _, ec2Instance := range reservation.Instances {
+			volumeIDs = append(volumeIDs, lo.FilterMap(ec2Instance.BlockDeviceMappings, func(bdm *ec2.InstanceBlockDeviceMapping, _ int) (string, bool) {
+				if bdm.Ebs == nil {
+					return "", false
+				}
+				return aws.StringValue(bdm.Ebs.VolumeId), true
+			})...)
+			if ni, ok := lo.Find(ec2Instance.NetworkInterfaces, func(ni *ec2.InstanceNetworkInterface) bool {
+				return ni.Attachment != nil && aws.Int64Value(ni.Attachment.DeviceIndex) == 0
+			}); ok {
+				networkInterfaceID = ni.NetworkInterfaceId
+			}
+		}
+	}
+	if nodeClass.Spec.LaunchTemplateName != nil && networkInterfaceID != nil {
+		if _, err := p.ec2api.CreateTagsWithContext(ctx, &ec2.CreateTagsInput{
+			Resources: []*string{networkInterfaceID},
+			Tags:      utils.MergeTags(tags),
+		}); err != nil {
+			return fmt.Errorf("tagging network interface, %w", err)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	resources := append([]string{instance.ID}, volumeIDs...)
+	if nodeClass.Spec.LaunchTemplateName == nil && networkInterfaceID != nil {
+		resources = append(resources, aws.StringValue(networkInterfaceID))
+	}
+	if _, err := p.ec2api.CreateTagsWithContext(ctx, &ec2.CreateTagsInput{
+		Resources: aws.StringSlice(resources),
+		Tags:      utils.MergeTags(changed),
+	}); err != nil {
+		return fmt.Errorf("creating tags, %w", err)
+	}
+	instance.Tags = lo.Assign(instance.Tags, changed)
+	return nil
+}
+
+// UpdateTags tags the instance, its volumes and its primary network interface with the tags of the NodeClass that
+// differ from the tags of the instance, which applies a change to the tags of the NodeClass in place when its drift
+// policy is InPlace. Tags that were removed from the NodeClass aren't removed from the instance.
+func (p *Provider) UpdateTags(ctx context.Context, nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim, instance *Instance) error {
+	return p.tagLaunchedInstance(ctx, nodeClass, nodeClaim, instance)
+}
+
+// tagTemplateData is the data that templated NodeClass tag values are rendered with, e.g. "{{ .NodePool }}" or
+// "{{ index .Labels "team" }}"
+type tagTemplateData struct {
+	NodePool     string
+	NodeClass    string
+	Zone         string
+	InstanceType string
+	CapacityType string
+	Labels       map[string]string
+	Annotations  map[string]string
+}
+
+// newTagTemplateData returns the data that's known before launch. The zone, instance type and capacity type are only
+// known if the NodeClaim's requirements constrain them to a single value.
+func newTagTemplateData(nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim) tagTemplateData {
+	return tagTemplateData{
+		NodePool:     lo.Ternary(nodeClaim.IsMachine, nodeClaim.Labels[v1alpha5.ProvisionerNameLabelKey], nodeClaim.Labels[corev1beta1.NodePoolLabelKey]),
+		NodeClass:    nodeClass.Name,
+		Zone:         nodeClaim.Labels[v1.LabelTopologyZone],
+		InstanceType: nodeClaim.Labels[v1.LabelInstanceTypeStable],
+		CapacityType: nodeClaim.Labels[corev1beta1.CapacityTypeLabelKey],
+		Labels:       lo.Assign(nodeClaim.Labels),
+		Annotations:  lo.Assign(nodeClaim.Annotations),
+	}
+}
+
+// renderTags renders the tag values that are templates. Missing label and annotation keys render as empty values.
+func renderTags(tags map[string]string, data tagTemplateData) (map[string]string, error) {
+	rendered := make(map[string]string, len(tags))
+	for k, v := range tags {
+		if !utils.IsTagTemplate(v) {
+			rendered[k] = v
+			continue
+		}
+		tmpl, err := template.New(k).Option("missingkey=zero").Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("parsing template for tag %q, %w", k, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("rendering template for tag %q, %w", k, err)
+		}
+		rendered[k] = buf.String()
+	}
+	return rendered, nil
+}
+
+// volumeTags marks the volumes of an instance as deleted on termination when every volume of the NodeClass is. Volumes
+// of a Custom AMI family without block device mappings follow the AMI's own mappings, so they're never marked.
+func volumeTags(nodeClass *v1beta1.NodeClass) map[string]string {
+	if len(nodeClass.Spec.BlockDeviceMappings) == 0 && lo.FromPtr(nodeClass.Spec.AMIFamily) == v1beta1.AMIFamilyCustom {
+		return nil
+	}
+	if lo.ContainsBy(nodeClass.Spec.BlockDeviceMappings, func(bdm *v1beta1.BlockDeviceMapping) bool {
+		return bdm.EBS != nil && !lo.FromPtrOr(bdm.EBS.DeleteOnTermination, true)
+	}) {
+		return nil
+	}
+	return map[string]string{v1beta1.VolumeDeleteOnTerminationTagKey: "true"}
+}
+
+func getTags(ctx context.Context, nodeClass *v1beta1.NodeClass, nodeClaim *corev1beta1.NodeClaim, data tagTemplateData) (map[string]string, error) {
+	nodeClassTags, err := renderTags(nodeClass.Spec.Tags, data)
+	if err != nil {
+		return nil, err
+	}
 	var overridableTags, staticTags map[string]string
 	if nodeClaim.IsMachine {
 		overridableTags = map[string]string{
 			"Name": fmt.Sprintf("%s/%s", v1alpha5.ProvisionerNameLabelKey, nodeClaim.Labels[v1alpha5.ProvisionerNameLabelKey]),
 		}
 		staticTags = map[string]string{
-			fmt.Sprintf("kubernetes.io/cluster/%s", settings.FromContext(ctx).ClusterName): "owned",
-			v1alpha5.ProvisionerNameLabelKey:                                               nodeClaim.Labels[v1alpha5.ProvisionerNameLabelKey],
-			v1alpha5.MachineManagedByAnnotationKey:                                         settings.FromContext(ctx).ClusterName,
+			v1alpha5.ProvisionerNameLabelKey:       nodeClaim.Labels[v1alpha5.ProvisionerNameLabelKey],
+			v1alpha5.MachineManagedByAnnotationKey: settings.FromContext(ctx).ClusterName,
 		}
 	} else {
 		overridableTags = map[string]string{
 			"Name": fmt.Sprintf("%s/%s", corev1beta1.NodePoolLabelKey, nodeClaim.Labels[corev1beta1.NodePoolLabelKey]),
 		}
 		staticTags = map[string]string{
-			fmt.Sprintf("kubernetes.io/cluster/%s", settings.FromContext(ctx).ClusterName): "owned",
 			corev1beta1.NodePoolLabelKey:       nodeClaim.Labels[corev1beta1.NodePoolLabelKey],
 			corev1beta1.ManagedByAnnotationKey: settings.FromContext(ctx).ClusterName,
 		}
 	}
-	return lo.Assign(overridableTags, settings.FromContext(ctx).Tags, nodeClass.Spec.Tags, staticTags)
+	// The static-field hash of the NodeClass is tagged on the instance so that static drift can be detected for
+	// instances whose NodeClaim doesn't have the hash annotation
+	return lo.Assign(overridableTags, settings.FromContext(ctx).Tags, nodeClassTags, staticTags, utils.ClusterTags(settings.FromContext(ctx).ClusterName),
+		nodeclassutil.HashAnnotation(nodeClass)), nil
 }
 
 func (p *Provider) checkODFallback(nodeClaim *corev1beta1.NodeClaim, instanceTypes []*cloudprovider.InstanceType, launchTemplateConfigs []*ec2.FleetLaunchTemplateConfigRequest) error {
@@ -302,8 +683,12 @@ func (p *Provider) getLaunchTemplateConfigs(ctx context.Context, nodeClass *v1be
 		return nil, fmt.Errorf("getting launch templates, %w", err)
 	}
 	for launchTemplateName, instanceTypes := range launchTemplates {
+		overrides := p.getOverrides(instanceTypes, zonalSubnets, scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...).Get(v1.LabelTopologyZone), capacityType)
+		if useInstanceRequirements(ctx, nodeClass) {
+			overrides = getInstanceRequirementsOverrides(nodeClass, overrides, instanceTypes)
+		}
 		launchTemplateConfig := &ec2.FleetLaunchTemplateConfigRequest{
-			Overrides: p.getOverrides(instanceTypes, zonalSubnets, scheduling.NewNodeSelectorRequirements(nodeClaim.Spec.Requirements...).Get(v1.LabelTopologyZone), capacityType),
+			Overrides: overrides,
 			LaunchTemplateSpecification: &ec2.FleetLaunchTemplateSpecificationRequest{
 				LaunchTemplateName: aws.String(launchTemplateName),
 				Version:            aws.String("$Latest"),
@@ -362,10 +747,133 @@ func (p *Provider) getOverrides(instanceTypes []*cloudprovider.InstanceType, zon
 	return overrides
 }
 
+// useInstanceRequirements returns whether the NodeClass is launched with attribute-based overrides, either because it
+// has instance requirements or because attribute-based instance selection is enabled for every NodeClass
+func useInstanceRequirements(ctx context.Context, nodeClass *v1beta1.NodeClass) bool {
+	return nodeClass.Spec.InstanceRequirements != nil || settings.FromContext(ctx).EnableAttributeBasedInstanceSelection
+}
+
+// getInstanceRequirementsOverrides collapses the overrides into a single attribute-based override per subnet, so that
+// the fleet request stays small regardless of how flexible the NodeClaim is. The instance types are passed as
+// AllowedInstanceTypes, since they're the instance types that satisfy every requirement of the NodeClaim and NodeClass,
+// including those that EC2 has no attribute for, such as the instance family, category and generation. The
+// architecture is that of the launch template's AMI, which the instance types were grouped by. The vCPU and memory
+// ranges are required, and are narrowed to those of the instance types. The instance types are capped at
+// MaxAllowedInstanceTypes when the NodeClaim is launched.
+func getInstanceRequirementsOverrides(nodeClass *v1beta1.NodeClass, overrides []*ec2.FleetLaunchTemplateOverridesRequest,
+	instanceTypes []*cloudprovider.InstanceType) []*ec2.FleetLaunchTemplateOverridesRequest {
+	if len(overrides) == 0 {
+		return nil
+	}
+	attribute := func(key string) []int64 {
+		return lo.Map(instanceTypes, func(it *cloudprovider.InstanceType, _ int) int64 {
+			value, _ := strconv.ParseInt(it.Requirements.Get(key).Any(), 10, 64)
+			return value
+		})
+	}
+	vcpus := attribute(lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.LabelInstanceCPU, v1beta1.LabelInstanceCPU))
+	memory := attribute(lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.LabelInstanceMemory, v1beta1.LabelInstanceMemory))
+	return lo.Map(lo.PartitionBy(overrides, func(o *ec2.FleetLaunchTemplateOverridesRequest) string {
+		return aws.StringValue(o.SubnetId)
+	}), func(subnetOverrides []*ec2.FleetLaunchTemplateOverridesRequest, _ int) *ec2.FleetLaunchTemplateOverridesRequest {
+		return &ec2.FleetLaunchTemplateOverridesRequest{
+			SubnetId:         subnetOverrides[0].SubnetId,
+			AvailabilityZone: subnetOverrides[0].AvailabilityZone,
+			InstanceRequirements: &ec2.InstanceRequirementsRequest{
+				AllowedInstanceTypes: lo.Map(subnetOverrides, func(o *ec2.FleetLaunchTemplateOverridesRequest, _ int) *string { return o.InstanceType }),
+				VCpuCount:            &ec2.VCpuCountRangeRequest{Min: aws.Int64(lo.Min(vcpus)), Max: aws.Int64(lo.Max(vcpus))},
+				MemoryMiB:            &ec2.MemoryMiBRequest{Min: aws.Int64(lo.Min(memory)), Max: aws.Int64(lo.Max(memory))},
+				// EC2 excludes these by default, while Karpenter considers them unless they're excluded by name
+				BareMetal:            aws.String(ec2.BareMetalIncluded),
+				BurstablePerformance: aws.String(ec2.BurstablePerformanceIncluded),
+				// The instance types were already chosen by price, so EC2's price protection would only drop candidates
+				OnDemandMaxPricePercentageOverLowestPrice: aws.Int64(maxPricePercentageOverLowestPrice),
+				SpotMaxPricePercentageOverLowestPrice:     aws.Int64(maxPricePercentageOverLowestPrice),
+			},
+		}
+	})
+}
+
+// getSpotPlacementScores returns the spot placement score, from 1 to 10, of each zone ID of the region for launching a
+// spot instance of one of the instance types. Zones aren't scored if the scores can't be retrieved, so that the launch
+// isn't blocked on them.
+func (p *Provider) getSpotPlacementScores(ctx context.Context, instanceTypes []*cloudprovider.InstanceType) map[string]int64 {
+	names := sets.List(sets.New(lo.Map(instanceTypes, func(it *cloudprovider.InstanceType, _ int) string { return it.Name })...))
+	key := strings.Join(names, ",")
+	if scores, ok := p.spotPlacementScoreCache.Get(key); ok {
+		return scores.(map[string]int64)
+	}
+	scores := map[string]int64{}
+	out, err := p.ec2api.GetSpotPlacementScoresWithContext(ctx, &ec2.GetSpotPlacementScoresInput{
+		InstanceTypes:          aws.StringSlice(names),
+		RegionNames:            aws.StringSlice([]string{p.region}),
+		SingleAvailabilityZone: aws.Bool(true),
+		TargetCapacity:         aws.Int64(1),
+	})
+	if err != nil {
+		// failures are cached as well, since they're usually caused by missing permissions or EC2's limit on the number
+		// of instance type sets that can be scored
+		logging.FromContext(ctx).Debugf("getting spot placement scores, %s", err)
+	} else {
+		for _, score := range out.SpotPlacementScores {
+			scores[aws.StringValue(score.AvailabilityZoneId)] = aws.Int64Value(score.Score)
+		}
+	}
+	p.spotPlacementScoreCache.SetDefault(key, scores)
+	return scores
+}
+
+// prioritizeZones orders the overrides by the spot placement scores of their zones, from the highest score, and gives
+// the overrides of each zone the priority of its score. The capacity-optimized-prioritized allocation strategy then
+// launches into the zone that's most likely to have spot capacity, unless its pools lack capacity.
+func prioritizeZones(launchTemplateConfigs []*ec2.FleetLaunchTemplateConfigRequest, zonalSubnets map[string]*ec2.Subnet, scores map[string]int64) {
+	zoneScores := lo.MapValues(zonalSubnets, func(subnet *ec2.Subnet, _ string) int64 {
+		return scores[aws.StringValue(subnet.AvailabilityZoneId)]
+	})
+	// zones with the same score have the same priority, and 0 is the highest priority
+	ranked := lo.Uniq(lo.Values(zoneScores))
+	sort.Slice(ranked, func(i, j int) bool { return ranked[i] > ranked[j] })
+	priorities := lo.MapValues(zoneScores, func(score int64, _ string) float64 {
+		return float64(lo.IndexOf(ranked, score))
+	})
+	for _, ltc := range launchTemplateConfigs {
+		sort.SliceStable(ltc.Overrides, func(i, j int) bool {
+			return priorities[aws.StringValue(ltc.Overrides[i].AvailabilityZone)] < priorities[aws.StringValue(ltc.Overrides[j].AvailabilityZone)]
+		})
+		for _, override := range ltc.Overrides {
+			override.Priority = aws.Float64(priorities[aws.StringValue(override.AvailabilityZone)])
+		}
+	}
+}
+
+// weighOverrides gives each override a weighted capacity of the vCPUs of its instance type relative to the smallest
+// instance type of the overrides. The launches that are batched into a single fleet request then count towards its
+// target capacity by size, so the fleet can fulfill them with fewer, larger instances. The batcher drops the weights of
+// a fleet request that isn't batched with other launches, and fails the launches that aren't fulfilled with a
+// WeightedCapacityFulfilledError.
+func weighOverrides(launchTemplateConfigs []*ec2.FleetLaunchTemplateConfigRequest, instanceTypes []*cloudprovider.InstanceType) {
+	vcpus := lo.SliceToMap(instanceTypes, func(it *cloudprovider.InstanceType) (string, int64) {
+		return it.Name, it.Capacity.Cpu().Value()
+	})
+	overrides := lo.FlatMap(launchTemplateConfigs, func(ltc *ec2.FleetLaunchTemplateConfigRequest, _ int) []*ec2.FleetLaunchTemplateOverridesRequest {
+		return ltc.Overrides
+	})
+	smallest := lo.Min(lo.Map(overrides, func(o *ec2.FleetLaunchTemplateOverridesRequest, _ int) int64 {
+		return vcpus[aws.StringValue(o.InstanceType)]
+	}))
+	if smallest <= 0 {
+		return
+	}
+	for _, override := range overrides {
+		override.WeightedCapacity = aws.Float64(float64(vcpus[aws.StringValue(override.InstanceType)]) / float64(smallest))
+	}
+}
+
 func (p *Provider) updateUnavailableOfferingsCache(ctx context.Context, errors []*ec2.CreateFleetError, capacityType string) {
 	for _, err := range errors {
 		if awserrors.IsUnfulfillableCapacity(err) {
 			p.unavailableOfferings.MarkUnavailableForFleetErr(ctx, err, capacityType)
+			InsufficientCapacityErrors.WithLabelValues(capacityType).Inc()
 		}
 	}
 }
@@ -523,10 +1031,11 @@ func combineFleetErrors(errors []*ec2.CreateFleetError) (errs error) {
 	for errorCode := range unique {
 		errs = multierr.Append(errs, fmt.Errorf(errorCode))
 	}
+	codes := lo.Map(errors, func(err *ec2.CreateFleetError, _ int) string { return aws.StringValue(err.ErrorCode) })
 	// If all the Fleet errors are ICE errors then we should wrap the combined error in the generic ICE error
 	iceErrorCount := lo.CountBy(errors, func(err *ec2.CreateFleetError) bool { return awserrors.IsUnfulfillableCapacity(err) })
 	if iceErrorCount == len(errors) {
-		return cloudprovider.NewInsufficientCapacityError(fmt.Errorf("with fleet error(s), %w", errs))
+		return awserrors.NewLaunchError(cloudprovider.NewInsufficientCapacityError(fmt.Errorf("with fleet error(s), %w", errs)), codes...)
 	}
-	return fmt.Errorf("with fleet error(s), %w", errs)
+	return awserrors.NewLaunchError(fmt.Errorf("with fleet error(s), %w", errs), codes...)
 }
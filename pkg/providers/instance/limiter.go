@@ -0,0 +1,100 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"golang.org/x/time/rate"
+
+	"github.com/aws/karpenter/pkg/apis/settings"
+)
+
+// launchLimiter limits the CreateFleet calls of each NodePool and NodeClass, so that a single runaway deployment can't
+// consume the account's EC2 API limits that every other NodePool and client in the account shares. Each NodePool and
+// NodeClass has a token bucket for its launch rate, and a semaphore for its concurrent launches. Both are sized from
+// the settings of each launch, so changes to the settings apply without a restart.
+type launchLimiter struct {
+	mu         sync.Mutex
+	limiters   map[string]*rate.Limiter
+	semaphores map[string]chan struct{}
+}
+
+func newLaunchLimiter() *launchLimiter {
+	return &launchLimiter{
+		limiters:   map[string]*rate.Limiter{},
+		semaphores: map[string]chan struct{}{},
+	}
+}
+
+// Acquire waits for the key's launch rate and concurrency to allow a launch, and returns the func that releases the
+// launch once it's done. Limits that are set to 0 aren't enforced.
+func (l *launchLimiter) Acquire(ctx context.Context, key string) (func(), error) {
+	if limiter := l.limiter(ctx, key); limiter != nil {
+		if err := limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("waiting for launch rate limit of %s, %w", key, err)
+		}
+	}
+	semaphore := l.semaphore(ctx, key)
+	if semaphore == nil {
+		return func() {}, nil
+	}
+	select {
+	case semaphore <- struct{}{}:
+		// Launches release the semaphore that they acquired, even if it's since been resized
+		return func() { <-semaphore }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for concurrent launch limit of %s, %w", key, ctx.Err())
+	}
+}
+
+func (l *launchLimiter) limiter(ctx context.Context, key string) *rate.Limiter {
+	launchesPerSecond, burst := settings.FromContext(ctx).LaunchesPerSecondPerNodePool, settings.FromContext(ctx).LaunchBurstPerNodePool
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if launchesPerSecond == 0 {
+		delete(l.limiters, key)
+		return nil
+	}
+	limiter, ok := l.limiters[key]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(launchesPerSecond), burst)
+		l.limiters[key] = limiter
+	}
+	if limiter.Limit() != rate.Limit(launchesPerSecond) {
+		limiter.SetLimit(rate.Limit(launchesPerSecond))
+	}
+	if limiter.Burst() != burst {
+		limiter.SetBurst(burst)
+	}
+	return limiter
+}
+
+func (l *launchLimiter) semaphore(ctx context.Context, key string) chan struct{} {
+	maxConcurrentLaunches := settings.FromContext(ctx).MaxConcurrentLaunchesPerNodePool
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if maxConcurrentLaunches == 0 {
+		delete(l.semaphores, key)
+		return nil
+	}
+	if semaphore, ok := l.semaphores[key]; ok && cap(semaphore) == maxConcurrentLaunches {
+		return semaphore
+	}
+	l.semaphores[key] = make(chan struct{}, maxConcurrentLaunches)
+	return l.semaphores[key]
+}
@@ -16,12 +16,21 @@ package instance_test
 
 import (
 	"context"
+	"sort"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/samber/lo"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/record"
@@ -29,6 +38,7 @@ import (
 
 	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
 	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
 	corecloudprovider "github.com/aws/karpenter-core/pkg/cloudprovider"
 	"github.com/aws/karpenter-core/pkg/events"
 	"github.com/aws/karpenter-core/pkg/operator/injection"
@@ -41,8 +51,11 @@ import (
 	"github.com/aws/karpenter/pkg/apis"
 	"github.com/aws/karpenter/pkg/apis/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
 	"github.com/aws/karpenter/pkg/cloudprovider"
+	awserrors "github.com/aws/karpenter/pkg/errors"
 	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/providers/instance"
 	"github.com/aws/karpenter/pkg/test"
 	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
 )
@@ -68,7 +81,8 @@ var _ = BeforeSuite(func() {
 	ctx = settings.ToContext(ctx, test.Settings())
 	awsEnv = test.NewEnvironment(ctx, env)
 	cloudProvider = cloudprovider.New(awsEnv.InstanceTypesProvider, awsEnv.InstanceProvider, events.NewRecorder(&record.FakeRecorder{}),
-		env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider)
+		env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider, awsEnv.QuotaProvider,
+		awsEnv.NotificationProvider)
 })
 
 var _ = AfterSuite(func() {
@@ -114,6 +128,7 @@ var _ = BeforeEach(func() {
 			},
 		},
 	})
+	awsEnv.Reset()
 })
 
 var _ = Describe("InstanceProvider", func() {
@@ -136,4 +151,649 @@ var _ = Describe("InstanceProvider", func() {
 		Expect(corecloudprovider.IsInsufficientCapacityError(err)).To(BeTrue())
 		Expect(instance).To(BeNil())
 	})
+	It("should trace each phase of a launch", func() {
+		recorder := tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		DeferCleanup(func() { otel.SetTracerProvider(trace.NewNoopTracerProvider()) })
+		ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+		instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+		Expect(err).ToNot(HaveOccurred())
+		spans := lo.SliceToMap(recorder.Ended(), func(span sdktrace.ReadOnlySpan) (string, sdktrace.ReadOnlySpan) {
+			return span.Name(), span
+		})
+		Expect(lo.Keys(spans)).To(ContainElements("ResolveSubnets", "ResolveLaunchTemplates", "ResolveSecurityGroups", "ResolveAMIs", "EnsureLaunchTemplates", "WaitForLaunchLimit", "CreateFleet"))
+		// Security groups, AMIs and the launch templates themselves are resolved as part of the launch templates
+		for _, name := range []string{"ResolveSecurityGroups", "ResolveAMIs", "EnsureLaunchTemplates"} {
+			Expect(spans[name].Parent().SpanID()).To(Equal(spans["ResolveLaunchTemplates"].SpanContext().SpanID()), name)
+		}
+	})
+	Context("Instance Lifecycle", func() {
+		It("should describe the instance that was launched until it's terminated", func() {
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			retrieved, err := awsEnv.InstanceProvider.Get(ctx, instance.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(retrieved.Type).To(Equal(instance.Type))
+			Expect(retrieved.Zone).To(Equal(instance.Zone))
+			Expect(retrieved.Tags).To(HaveKeyWithValue(v1alpha5.ProvisionerNameLabelKey, provisioner.Name))
+			instances, err := awsEnv.InstanceProvider.List(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instances).To(HaveLen(1))
+			Expect(instances[0].ID).To(Equal(instance.ID))
+
+			Expect(awsEnv.InstanceProvider.Delete(ctx, instance.ID)).To(Succeed())
+			_, err = awsEnv.InstanceProvider.Get(ctx, instance.ID)
+			Expect(corecloudprovider.IsNodeClaimNotFoundError(err)).To(BeTrue())
+			instances, err = awsEnv.InstanceProvider.List(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instances).To(BeEmpty())
+		})
+		It("should launch the instance type that the fleet was fulfilled with", func() {
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			awsEnv.EC2API.InsufficientCapacityPools.Set([]fake.CapacityPool{
+				{CapacityType: v1alpha5.CapacityTypeOnDemand, InstanceType: "m5.large", Zone: "test-zone-1a"},
+				{CapacityType: v1alpha5.CapacityTypeOnDemand, InstanceType: "m5.large", Zone: "test-zone-1b"},
+				{CapacityType: v1alpha5.CapacityTypeOnDemand, InstanceType: "m5.large", Zone: "test-zone-1c"},
+			})
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+			instanceTypes = lo.Filter(instanceTypes, func(i *corecloudprovider.InstanceType, _ int) bool {
+				return i.Name == "m5.large" || i.Name == "m5.xlarge"
+			})
+
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance.Type).To(Equal("m5.xlarge"))
+			retrieved, err := awsEnv.InstanceProvider.Get(ctx, instance.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(retrieved.Type).To(Equal("m5.xlarge"))
+		})
+		It("should no longer describe a spot instance once it's interrupted", func() {
+			machine.Spec.Requirements = []v1.NodeSelectorRequirement{{
+				Key:      v1alpha5.LabelCapacityType,
+				Operator: v1.NodeSelectorOpIn,
+				Values:   []string{v1alpha5.CapacityTypeSpot},
+			}}
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance.CapacityType).To(Equal(v1alpha5.CapacityTypeSpot))
+
+			interrupted, err := awsEnv.EC2API.InterruptSpotInstance(instance.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(aws.StringValue(interrupted.StateReason.Code)).To(Equal("Server.SpotInstanceTermination"))
+			_, err = awsEnv.InstanceProvider.Get(ctx, instance.ID)
+			Expect(corecloudprovider.IsNodeClaimNotFoundError(err)).To(BeTrue())
+			_, err = awsEnv.EC2API.InterruptSpotInstance(instance.ID)
+			Expect(err).To(HaveOccurred())
+		})
+		It("should not interrupt an on-demand instance", func() {
+			machine.Spec.Requirements = []v1.NodeSelectorRequirement{{
+				Key:      v1alpha5.LabelCapacityType,
+				Operator: v1.NodeSelectorOpIn,
+				Values:   []string{v1alpha5.CapacityTypeOnDemand},
+			}}
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = awsEnv.EC2API.InterruptSpotInstance(instance.ID)
+			Expect(err).To(HaveOccurred())
+			_, err = awsEnv.InstanceProvider.Get(ctx, instance.ID)
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+	Context("Stop-Based Consolidation", func() {
+		var nodeClass *v1beta1.NodeClass
+		var nodeClaim *corev1beta1.NodeClaim
+		var instanceTypes []*corecloudprovider.InstanceType
+		BeforeEach(func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{EnableStopBasedConsolidation: lo.ToPtr(true)}))
+			nodeTemplate.Spec.InstanceInitiatedShutdownBehavior = aws.String(v1alpha1.InstanceInitiatedShutdownBehaviorStop)
+			machine.Spec.Requirements = []v1.NodeSelectorRequirement{{
+				Key:      v1alpha5.LabelCapacityType,
+				Operator: v1.NodeSelectorOpIn,
+				Values:   []string{v1alpha5.CapacityTypeOnDemand},
+			}}
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			var err error
+			instanceTypes, err = cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+
+			nodeClass = nodeclassutil.New(nodeTemplate)
+			nodeClass.IsNodeTemplate = false
+			nodeClaim = nodeclaimutil.New(machine)
+			nodeClaim.IsMachine = false
+			nodeClaim.Labels = map[string]string{corev1beta1.NodePoolLabelKey: provisioner.Name}
+			nodeClaim.Annotations = map[string]string{corev1beta1.NodePoolHashAnnotationKey: "nodepool-hash"}
+		})
+		It("should tag the instance with the time that it was stopped", func() {
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeClass, nodeClaim, instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(awsEnv.InstanceProvider.Stop(ctx, nodeClaim, instance.ID)).To(Succeed())
+
+			stopped, err := awsEnv.InstanceProvider.Get(ctx, instance.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stopped.State).To(Equal(ec2.InstanceStateNameStopped))
+			Expect(stopped.Tags).To(HaveKey(v1beta1.AnnotationStoppedAt))
+			Expect(stopped.Tags).To(HaveKeyWithValue(corev1beta1.NodePoolHashAnnotationKey, "nodepool-hash"))
+		})
+		It("should start a stopped instance rather than launching a new one", func() {
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeClass, nodeClaim, instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(awsEnv.InstanceProvider.Stop(ctx, nodeClaim, instance.ID)).To(Succeed())
+			awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Reset()
+
+			started, err := awsEnv.InstanceProvider.Create(ctx, nodeClass, nodeClaim, instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(started.ID).To(Equal(instance.ID))
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(0))
+			Expect(awsEnv.EC2API.StartInstancesBehavior.CalledWithInput.Len()).To(Equal(1))
+			retrieved, err := awsEnv.InstanceProvider.Get(ctx, instance.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(retrieved.State).To(Equal(ec2.InstanceStateNamePending))
+			Expect(retrieved.Tags).ToNot(HaveKey(v1beta1.AnnotationStoppedAt))
+		})
+		It("should launch a new instance when stop-based consolidation is disabled", func() {
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeClass, nodeClaim, instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(awsEnv.InstanceProvider.Stop(ctx, nodeClaim, instance.ID)).To(Succeed())
+
+			ctx = settings.ToContext(ctx, test.Settings())
+			launched, err := awsEnv.InstanceProvider.Create(ctx, nodeClass, nodeClaim, instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(launched.ID).ToNot(Equal(instance.ID))
+		})
+		It("should launch a new instance when the NodeClass has changed since the instance was stopped", func() {
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeClass, nodeClaim, instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(awsEnv.InstanceProvider.Stop(ctx, nodeClaim, instance.ID)).To(Succeed())
+
+			nodeClass.Spec.Tags = map[string]string{"team": "team-a"}
+			launched, err := awsEnv.InstanceProvider.Create(ctx, nodeClass, nodeClaim, instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(launched.ID).ToNot(Equal(instance.ID))
+		})
+		It("should launch a new instance when the NodePool has changed since the instance was stopped", func() {
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeClass, nodeClaim, instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(awsEnv.InstanceProvider.Stop(ctx, nodeClaim, instance.ID)).To(Succeed())
+
+			nodeClaim.Annotations[corev1beta1.NodePoolHashAnnotationKey] = "updated-nodepool-hash"
+			launched, err := awsEnv.InstanceProvider.Create(ctx, nodeClass, nodeClaim, instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(launched.ID).ToNot(Equal(instance.ID))
+		})
+		It("should launch a new instance when the userData has changed since the instance was stopped", func() {
+			nodeClass.Status.UserDataHash = "userdata-hash"
+			nodeClaim.Annotations[v1beta1.AnnotationUserDataHash] = nodeClass.Status.UserDataHash
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeClass, nodeClaim, instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(awsEnv.InstanceProvider.Stop(ctx, nodeClaim, instance.ID)).To(Succeed())
+
+			nodeClass.Status.UserDataHash = "updated-userdata-hash"
+			launched, err := awsEnv.InstanceProvider.Create(ctx, nodeClass, nodeClaim, instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(launched.ID).ToNot(Equal(instance.ID))
+		})
+		It("should launch a new instance when the stopped instance is in a zone that the NodeClaim doesn't allow", func() {
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeClass, nodeClaim, instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(awsEnv.InstanceProvider.Stop(ctx, nodeClaim, instance.ID)).To(Succeed())
+
+			nodeClaim.Spec.Requirements = append(nodeClaim.Spec.Requirements, v1.NodeSelectorRequirement{
+				Key:      v1.LabelTopologyZone,
+				Operator: v1.NodeSelectorOpNotIn,
+				Values:   []string{instance.Zone},
+			})
+			launched, err := awsEnv.InstanceProvider.Create(ctx, nodeClass, nodeClaim, instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(launched.ID).ToNot(Equal(instance.ID))
+		})
+		It("should launch a new instance and leave the stopped instance to be garbage collected when it fails to start", func() {
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeClass, nodeClaim, instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(awsEnv.InstanceProvider.Stop(ctx, nodeClaim, instance.ID)).To(Succeed())
+
+			awsEnv.EC2API.StartInstancesBehavior.Error.Set(awserr.New("InsufficientInstanceCapacity", "Insufficient capacity.", nil))
+			launched, err := awsEnv.InstanceProvider.Create(ctx, nodeClass, nodeClaim, instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(launched.ID).ToNot(Equal(instance.ID))
+			stopped, err := awsEnv.InstanceProvider.Get(ctx, instance.ID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stopped.State).To(Equal(ec2.InstanceStateNameStopped))
+			Expect(stopped.Tags).ToNot(HaveKey(v1beta1.AnnotationStoppedAt))
+		})
+	})
+	Context("Launch Limits", func() {
+		It("should wait for the launch rate limit of the NodePool", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{LaunchesPerSecondPerNodePool: lo.ToPtr(0.001), LaunchBurstPerNodePool: lo.ToPtr(1)}))
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			timeoutCtx, cancel := context.WithTimeout(ctx, time.Second)
+			defer cancel()
+			_, err = awsEnv.InstanceProvider.Create(timeoutCtx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("waiting for launch rate limit"))
+			Expect(awsEnv.EC2API.CreateFleetBehavior.Calls()).To(Equal(1))
+		})
+		It("should limit the launch rate of each NodePool separately", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{LaunchesPerSecondPerNodePool: lo.ToPtr(0.001), LaunchBurstPerNodePool: lo.ToPtr(1)}))
+			other := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: provisioner.Spec.ProviderRef})
+			otherMachine := coretest.Machine(v1alpha5.Machine{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{v1alpha5.ProvisionerNameLabelKey: other.Name}},
+				Spec:       v1alpha5.MachineSpec{MachineTemplateRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name}},
+			})
+			ExpectApplied(ctx, env.Client, machine, otherMachine, provisioner, other, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(otherMachine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+		})
+		It("should release the concurrent launch limit once a launch is done", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{MaxConcurrentLaunchesPerNodePool: lo.ToPtr(1)}))
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+
+			for i := 0; i < 3; i++ {
+				_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(awsEnv.EC2API.CreateFleetBehavior.Calls()).To(Equal(3))
+		})
+	})
+	Context("Fault Injection", func() {
+		It("should fail a throttled launch and succeed once throttling subsides", func() {
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+
+			awsEnv.EC2API.Faults.Set(fake.FaultOptions{ThrottleProbability: 1})
+			_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("RequestLimitExceeded"))
+
+			awsEnv.EC2API.Faults.Set(fake.FaultOptions{Seed: 1, ThrottleProbability: 0.5})
+			Eventually(func() error {
+				_, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+				return err
+			}).Should(Succeed())
+		})
+		It("should mark the offerings that return insufficient capacity as unavailable", func() {
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+			instanceTypes = lo.Filter(instanceTypes, func(i *corecloudprovider.InstanceType, _ int) bool { return i.Name == "m5.xlarge" })
+
+			awsEnv.EC2API.Faults.Set(fake.FaultOptions{InsufficientCapacityProbability: 1})
+			_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(corecloudprovider.IsInsufficientCapacityError(err)).To(BeTrue())
+			for _, zone := range []string{"test-zone-1a", "test-zone-1b", "test-zone-1c"} {
+				Expect(awsEnv.UnavailableOfferingsCache.IsUnavailable("m5.xlarge", zone, v1alpha5.CapacityTypeOnDemand)).To(BeTrue())
+			}
+		})
+		It("should find a launched instance once it's visible to EC2", func() {
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+
+			awsEnv.EC2API.Faults.Set(fake.FaultOptions{ConsistencyDelay: time.Second})
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = awsEnv.InstanceProvider.Get(ctx, instance.ID)
+			Expect(corecloudprovider.IsNodeClaimNotFoundError(err)).To(BeTrue())
+			Eventually(func() error {
+				_, err := awsEnv.InstanceProvider.Get(ctx, instance.ID)
+				return err
+			}, 5*time.Second).Should(Succeed())
+		})
+	})
+	Context("Attribute-Based Instance Selection", func() {
+		BeforeEach(func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{EnableAttributeBasedInstanceSelection: lo.ToPtr(true)}))
+		})
+		It("should launch with a single instance requirements override per subnet", func() {
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+			instanceTypes = lo.Filter(instanceTypes, func(i *corecloudprovider.InstanceType, _ int) bool {
+				return i.Name == "m5.large" || i.Name == "m5.xlarge"
+			})
+
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance).ToNot(BeNil())
+
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(1))
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			for _, ltc := range createFleetInput.LaunchTemplateConfigs {
+				subnets := lo.Map(ltc.Overrides, func(o *ec2.FleetLaunchTemplateOverridesRequest, _ int) string { return aws.StringValue(o.SubnetId) })
+				Expect(subnets).To(Equal(lo.Uniq(subnets)))
+				for _, override := range ltc.Overrides {
+					Expect(override.InstanceType).To(BeNil())
+					Expect(override.InstanceRequirements).ToNot(BeNil())
+					Expect(aws.StringValueSlice(override.InstanceRequirements.AllowedInstanceTypes)).To(ConsistOf("m5.large", "m5.xlarge"))
+				}
+			}
+		})
+		It("should return an ICE error when all allowed instance types return an ICE error", func() {
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			awsEnv.EC2API.InsufficientCapacityPools.Set([]fake.CapacityPool{
+				{CapacityType: v1alpha5.CapacityTypeOnDemand, InstanceType: "m5.xlarge", Zone: "test-zone-1a"},
+				{CapacityType: v1alpha5.CapacityTypeOnDemand, InstanceType: "m5.xlarge", Zone: "test-zone-1b"},
+				{CapacityType: v1alpha5.CapacityTypeSpot, InstanceType: "m5.xlarge", Zone: "test-zone-1a"},
+				{CapacityType: v1alpha5.CapacityTypeSpot, InstanceType: "m5.xlarge", Zone: "test-zone-1b"},
+			})
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+			instanceTypes = lo.Filter(instanceTypes, func(i *corecloudprovider.InstanceType, _ int) bool { return i.Name == "m5.xlarge" })
+
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(corecloudprovider.IsInsufficientCapacityError(err)).To(BeTrue())
+			Expect(instance).To(BeNil())
+		})
+		It("should allow more instance types than are passed as overrides", func() {
+			maxInstanceTypes := instance.MaxInstanceTypes
+			instance.MaxInstanceTypes = 1
+			DeferCleanup(func() { instance.MaxInstanceTypes = maxInstanceTypes })
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+			instanceTypes = lo.Filter(instanceTypes, func(i *corecloudprovider.InstanceType, _ int) bool {
+				return i.Name == "m5.large" || i.Name == "m5.xlarge"
+			})
+
+			_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			for _, ltc := range createFleetInput.LaunchTemplateConfigs {
+				for _, override := range ltc.Overrides {
+					Expect(aws.StringValueSlice(override.InstanceRequirements.AllowedInstanceTypes)).To(ConsistOf("m5.large", "m5.xlarge"))
+				}
+			}
+		})
+	})
+	Context("Instance Requirements", func() {
+		It("should launch with the instance types that satisfy the NodeClass's instance requirements", func() {
+			nodeTemplate.Spec.InstanceRequirements = &v1alpha1.InstanceRequirements{
+				ExcludedInstanceTypes: []string{"t2.*"},
+			}
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+			instanceTypes = lo.Filter(instanceTypes, func(i *corecloudprovider.InstanceType, _ int) bool {
+				return i.Name == "m5.large" || i.Name == "m5.xlarge"
+			})
+
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance).ToNot(BeNil())
+			// Instance types in the same vCPU and memory ranges, e.g. of other families, aren't launched
+			Expect(instance.Type).To(BeElementOf("m5.large", "m5.xlarge"))
+
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(1))
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			for _, ltc := range createFleetInput.LaunchTemplateConfigs {
+				for _, override := range ltc.Overrides {
+					Expect(override.InstanceType).To(BeNil())
+					Expect(aws.StringValueSlice(override.InstanceRequirements.AllowedInstanceTypes)).To(ConsistOf("m5.large", "m5.xlarge"))
+					Expect(override.InstanceRequirements.ExcludedInstanceTypes).To(BeEmpty())
+					Expect(override.InstanceRequirements.VCpuCount).To(Equal(&ec2.VCpuCountRangeRequest{Min: aws.Int64(2), Max: aws.Int64(4)}))
+					Expect(override.InstanceRequirements.MemoryMiB).To(Equal(&ec2.MemoryMiBRequest{Min: aws.Int64(8192), Max: aws.Int64(16384)}))
+					Expect(override.InstanceRequirements.BareMetal).To(Equal(aws.String(ec2.BareMetalIncluded)))
+					Expect(override.InstanceRequirements.BurstablePerformance).To(Equal(aws.String(ec2.BurstablePerformanceIncluded)))
+				}
+			}
+		})
+		It("should only allow the cheapest instance types up to the number that EC2 allows", func() {
+			maxAllowedInstanceTypes := instance.MaxAllowedInstanceTypes
+			instance.MaxAllowedInstanceTypes = 1
+			DeferCleanup(func() { instance.MaxAllowedInstanceTypes = maxAllowedInstanceTypes })
+			nodeTemplate.Spec.InstanceRequirements = &v1alpha1.InstanceRequirements{}
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+			instanceTypes = lo.Filter(instanceTypes, func(i *corecloudprovider.InstanceType, _ int) bool {
+				return i.Name == "m5.large" || i.Name == "m5.xlarge"
+			})
+
+			_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			for _, ltc := range createFleetInput.LaunchTemplateConfigs {
+				for _, override := range ltc.Overrides {
+					Expect(aws.StringValueSlice(override.InstanceRequirements.AllowedInstanceTypes)).To(ConsistOf("m5.large"))
+				}
+			}
+		})
+	})
+	Context("Spot Placement Scores", func() {
+		BeforeEach(func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{EnableSpotPlacementScores: lo.ToPtr(true)}))
+			machine.Spec.Requirements = []v1.NodeSelectorRequirement{{
+				Key:      v1alpha5.LabelCapacityType,
+				Operator: v1.NodeSelectorOpIn,
+				Values:   []string{v1alpha5.CapacityTypeSpot},
+			}}
+			awsEnv.EC2API.GetSpotPlacementScoresBehavior.Output.Set(&ec2.GetSpotPlacementScoresOutput{
+				SpotPlacementScores: []*ec2.SpotPlacementScore{
+					{AvailabilityZoneId: aws.String("testzone1a"), Region: aws.String("us-west-2"), Score: aws.Int64(3)},
+					{AvailabilityZoneId: aws.String("testzone1b"), Region: aws.String("us-west-2"), Score: aws.Int64(9)},
+					{AvailabilityZoneId: aws.String("testzone1c"), Region: aws.String("us-west-2"), Score: aws.Int64(6)},
+				},
+			})
+		})
+		It("should order the overrides by the spot placement scores of their zones", func() {
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance.Zone).To(Equal("test-zone-1b"))
+
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(1))
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(aws.StringValue(createFleetInput.SpotOptions.AllocationStrategy)).To(Equal(ec2.SpotAllocationStrategyCapacityOptimizedPrioritized))
+			priorities := map[string]float64{"test-zone-1b": 0, "test-zone-1c": 1, "test-zone-1a": 2}
+			for _, ltc := range createFleetInput.LaunchTemplateConfigs {
+				Expect(sort.SliceIsSorted(ltc.Overrides, func(i, j int) bool {
+					return aws.Float64Value(ltc.Overrides[i].Priority) < aws.Float64Value(ltc.Overrides[j].Priority)
+				})).To(BeTrue())
+				for _, override := range ltc.Overrides {
+					Expect(aws.Float64Value(override.Priority)).To(Equal(priorities[aws.StringValue(override.AvailabilityZone)]))
+				}
+			}
+		})
+		It("should cache the spot placement scores of a set of instance types", func() {
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(awsEnv.EC2API.GetSpotPlacementScoresBehavior.CalledWithInput.Len()).To(Equal(1))
+		})
+		It("should launch with the default allocation strategy when the scores can't be retrieved", func() {
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+			awsEnv.EC2API.GetSpotPlacementScoresBehavior.Error.Set(awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation.", nil))
+
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance).ToNot(BeNil())
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(aws.StringValue(createFleetInput.SpotOptions.AllocationStrategy)).To(Equal(ec2.SpotAllocationStrategyPriceCapacityOptimized))
+		})
+		It("should not get the spot placement scores when they're disabled", func() {
+			ctx = settings.ToContext(ctx, test.Settings())
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(awsEnv.EC2API.GetSpotPlacementScoresBehavior.CalledWithInput.Len()).To(Equal(0))
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(aws.StringValue(createFleetInput.SpotOptions.AllocationStrategy)).To(Equal(ec2.SpotAllocationStrategyPriceCapacityOptimized))
+		})
+	})
+	Context("Weighted Capacity", func() {
+		It("should weigh the overrides of batched launches by the vCPUs of their instance types", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{EnableWeightedCapacity: lo.ToPtr(true)}))
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+			instanceTypes = lo.Filter(instanceTypes, func(i *corecloudprovider.InstanceType, _ int) bool {
+				return i.Name == "m5.large" || i.Name == "m5.xlarge"
+			})
+
+			wg := sync.WaitGroup{}
+			for i := 0; i < 2; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(instance).ToNot(BeNil())
+				}()
+			}
+			wg.Wait()
+
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(1))
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(aws.Int64Value(createFleetInput.TargetCapacitySpecification.TotalTargetCapacity)).To(BeNumerically("==", 2))
+			weights := map[string]float64{"m5.large": 1, "m5.xlarge": 2}
+			for _, ltc := range createFleetInput.LaunchTemplateConfigs {
+				for _, override := range ltc.Overrides {
+					Expect(aws.Float64Value(override.WeightedCapacity)).To(Equal(weights[aws.StringValue(override.InstanceType)]))
+				}
+			}
+		})
+		It("should not weigh the overrides of a launch that isn't batched", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{EnableWeightedCapacity: lo.ToPtr(true)}))
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(aws.Int64Value(createFleetInput.TargetCapacitySpecification.TotalTargetCapacity)).To(BeNumerically("==", 1))
+			for _, ltc := range createFleetInput.LaunchTemplateConfigs {
+				for _, override := range ltc.Overrides {
+					Expect(override.WeightedCapacity).To(BeNil())
+				}
+			}
+		})
+		It("should not weigh the overrides when weighted capacity is disabled", func() {
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			for _, ltc := range createFleetInput.LaunchTemplateConfigs {
+				for _, override := range ltc.Overrides {
+					Expect(override.WeightedCapacity).To(BeNil())
+				}
+			}
+		})
+	})
+	Context("Launch DryRun", func() {
+		BeforeEach(func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{EnableLaunchDryRun: lo.ToPtr(true)}))
+		})
+		It("should launch when the dry run succeeds", func() {
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance).ToNot(BeNil())
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(1))
+		})
+		It("should fail with an authorization error without launching when the dry run is unauthorized", func() {
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+			awsEnv.EC2API.NextDryRunError.Set(awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation.", nil))
+
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(awserrors.IsUnauthorized(err)).To(BeTrue())
+			Expect(instance).To(BeNil())
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(0))
+
+			// The authorization failure is cached so subsequent launches for the NodeClass short-circuit
+			instance, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(awserrors.IsUnauthorized(err)).To(BeTrue())
+			Expect(instance).To(BeNil())
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(0))
+		})
+		It("should not share a cached authorization error with a NodeClass that has the same name", func() {
+			nodeClass := test.NodeClass(v1beta1.NodeClass{ObjectMeta: metav1.ObjectMeta{Name: nodeTemplate.Name}})
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate, nodeClass)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+			awsEnv.EC2API.NextDryRunError.Set(awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation.", nil))
+
+			_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(awserrors.IsUnauthorized(err)).To(BeTrue())
+
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeClass, nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance).ToNot(BeNil())
+		})
+		It("should not cache dry run errors that aren't authorization errors", func() {
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+			awsEnv.EC2API.NextDryRunError.Set(awserr.New("RequestLimitExceeded", "Request limit exceeded.", nil))
+
+			_, err = awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).To(HaveOccurred())
+			Expect(awserrors.IsUnauthorized(err)).To(BeFalse())
+
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance).ToNot(BeNil())
+		})
+		It("should skip the dry run when the NodeClass disables it", func() {
+			nodeTemplate.Spec.LaunchDryRun = lo.ToPtr(false)
+			ExpectApplied(ctx, env.Client, machine, provisioner, nodeTemplate)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, nodepoolutil.New(provisioner))
+			Expect(err).ToNot(HaveOccurred())
+			awsEnv.EC2API.NextDryRunError.Set(awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation.", nil))
+
+			instance, err := awsEnv.InstanceProvider.Create(ctx, nodeclassutil.New(nodeTemplate), nodeclaimutil.New(machine), instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instance).ToNot(BeNil())
+		})
+	})
 })
@@ -48,7 +48,7 @@ func NewInstance(out *ec2.Instance) *Instance {
 		Type:         aws.StringValue(out.InstanceType),
 		Zone:         aws.StringValue(out.Placement.AvailabilityZone),
 		CapacityType: lo.Ternary(out.SpotInstanceRequestId != nil, corev1beta1.CapacityTypeSpot, corev1beta1.CapacityTypeOnDemand),
-		SecurityGroupIDs: lo.Map(out.SecurityGroups, func(securitygroup *ec2.GroupIdentifier, _ int) string {
+		SecurityGroupIDs: lo.Map(primarySecurityGroups(out), func(securitygroup *ec2.GroupIdentifier, _ int) string {
 			return aws.StringValue(securitygroup.GroupId)
 		}),
 		SubnetID: aws.StringValue(out.SubnetId),
@@ -57,6 +57,19 @@ func NewInstance(out *ec2.Instance) *Instance {
 
 }
 
+// primarySecurityGroups returns the security groups of the instance's primary network interface, which is the one that
+// Karpenter launches with. The instance's security groups are the union across all of its network interfaces, which
+// includes interfaces that are attached after launch (e.g. by the VPC CNI with custom networking or security groups for pods).
+func primarySecurityGroups(out *ec2.Instance) []*ec2.GroupIdentifier {
+	primary, ok := lo.Find(out.NetworkInterfaces, func(ni *ec2.InstanceNetworkInterface) bool {
+		return ni.Attachment != nil && aws.Int64Value(ni.Attachment.DeviceIndex) == 0
+	})
+	if !ok {
+		return out.SecurityGroups
+	}
+	return primary.Groups
+}
+
 func NewInstanceFromFleet(out *ec2.CreateFleetInstance, tags map[string]string) *Instance {
 	return &Instance{
 		LaunchTime:   time.Now(), // estimate the launch time since we just launched
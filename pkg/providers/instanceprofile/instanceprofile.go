@@ -0,0 +1,147 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instanceprofile
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/mitchellh/hashstructure/v2"
+	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
+	"knative.dev/pkg/logging"
+
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	awserrors "github.com/aws/karpenter/pkg/errors"
+	"github.com/aws/karpenter/pkg/utils"
+)
+
+// Provider manages the instance profiles of NodeClasses that specify a role. Each NodeClass has a single instance
+// profile whose role follows spec.role, so that launch templates keep referencing the same instance profile when the
+// role changes.
+type Provider struct {
+	region string
+	iamapi iamiface.IAMAPI
+	// cache maps the name of an instance profile to the name of its role
+	cache *cache.Cache
+}
+
+func NewProvider(region string, iamapi iamiface.IAMAPI, cache *cache.Cache) *Provider {
+	return &Provider{
+		region: region,
+		iamapi: iamapi,
+		cache:  cache,
+	}
+}
+
+// Create ensures that the instance profile of the NodeClass exists and has the NodeClass's role, and returns the name
+// of the instance profile. If the role of the instance profile isn't the NodeClass's role, it's replaced.
+func (p *Provider) Create(ctx context.Context, nodeClass *v1beta1.NodeClass) (string, error) {
+	name := GetProfileName(ctx, p.region, nodeClass)
+	role := aws.StringValue(nodeClass.Spec.Role)
+	if cached, ok := p.cache.Get(name); ok && cached.(string) == role {
+		return name, nil
+	}
+	profile, err := p.get(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if profile == nil {
+		out, err := p.iamapi.CreateInstanceProfileWithContext(ctx, &iam.CreateInstanceProfileInput{
+			InstanceProfileName: aws.String(name),
+			Tags: lo.MapToSlice(lo.Assign(settings.FromContext(ctx).Tags, utils.ClusterTags(settings.FromContext(ctx).ClusterName), map[string]string{
+				v1beta1.NodeClassTagKey: nodeClass.Name,
+			}), func(k, v string) *iam.Tag {
+				return &iam.Tag{Key: aws.String(k), Value: aws.String(v)}
+			}),
+		})
+		if err != nil {
+			return "", fmt.Errorf("creating instance profile %q, %w", name, err)
+		}
+		logging.FromContext(ctx).With("instance-profile", name).Debugf("created instance profile")
+		profile = out.InstanceProfile
+	}
+	if _, ok := lo.Find(profile.Roles, func(r *iam.Role) bool { return aws.StringValue(r.RoleName) == role }); !ok {
+		// An instance profile can only have a single role, so the previous role is removed before the new one is added
+		if err = p.removeRoles(ctx, profile); err != nil {
+			return "", err
+		}
+		if _, err = p.iamapi.AddRoleToInstanceProfileWithContext(ctx, &iam.AddRoleToInstanceProfileInput{
+			InstanceProfileName: aws.String(name),
+			RoleName:            aws.String(role),
+		}); err != nil {
+			return "", fmt.Errorf("adding role %q to instance profile %q, %w", role, name, err)
+		}
+		logging.FromContext(ctx).With("instance-profile", name, "role", role).Infof("added role to instance profile")
+	}
+	p.cache.SetDefault(name, role)
+	return name, nil
+}
+
+// Delete removes the role from the instance profile of the NodeClass and deletes the instance profile
+func (p *Provider) Delete(ctx context.Context, nodeClass *v1beta1.NodeClass) error {
+	name := GetProfileName(ctx, p.region, nodeClass)
+	profile, err := p.get(ctx, name)
+	if err != nil || profile == nil {
+		return err
+	}
+	if err = p.removeRoles(ctx, profile); err != nil {
+		return err
+	}
+	if _, err = p.iamapi.DeleteInstanceProfileWithContext(ctx, &iam.DeleteInstanceProfileInput{
+		InstanceProfileName: aws.String(name),
+	}); err != nil && !awserrors.IsNotFound(err) {
+		return fmt.Errorf("deleting instance profile %q, %w", name, err)
+	}
+	p.cache.Delete(name)
+	logging.FromContext(ctx).With("instance-profile", name).Debugf("deleted instance profile")
+	return nil
+}
+
+// get returns the instance profile, or nil if it doesn't exist
+func (p *Provider) get(ctx context.Context, name string) (*iam.InstanceProfile, error) {
+	out, err := p.iamapi.GetInstanceProfileWithContext(ctx, &iam.GetInstanceProfileInput{
+		InstanceProfileName: aws.String(name),
+	})
+	if awserrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting instance profile %q, %w", name, err)
+	}
+	return out.InstanceProfile, nil
+}
+
+func (p *Provider) removeRoles(ctx context.Context, profile *iam.InstanceProfile) error {
+	for _, role := range profile.Roles {
+		if _, err := p.iamapi.RemoveRoleFromInstanceProfileWithContext(ctx, &iam.RemoveRoleFromInstanceProfileInput{
+			InstanceProfileName: profile.InstanceProfileName,
+			RoleName:            role.RoleName,
+		}); err != nil && !awserrors.IsNotFound(err) {
+			return fmt.Errorf("removing role %q from instance profile %q, %w", aws.StringValue(role.RoleName), aws.StringValue(profile.InstanceProfileName), err)
+		}
+	}
+	return nil
+}
+
+// GetProfileName returns the name of the instance profile of the NodeClass, which is unique to the cluster, region
+// and NodeClass and fits in the 128 characters that instance profile names are limited to
+func GetProfileName(ctx context.Context, region string, nodeClass *v1beta1.NodeClass) string {
+	return fmt.Sprintf("%s_%d", settings.FromContext(ctx).ClusterName, lo.Must(hashstructure.Hash(fmt.Sprintf("%s%s", region, nodeClass.Name), hashstructure.FormatV2, nil)))
+}
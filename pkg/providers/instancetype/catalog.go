@@ -0,0 +1,90 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instancetype
+
+import (
+	"context"
+	"sort"
+
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/scheduling"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
+)
+
+// CatalogEntry is the shape of the nodes that a NodePool launches with an instance type. Tools that scale a NodePool
+// up from zero nodes can read it in the same way that the cluster autoscaler reads the node template tags of an Auto
+// Scaling group, without waiting for a node of the instance type to exist.
+type CatalogEntry struct {
+	InstanceType string            `json:"instanceType"`
+	Capacity     v1.ResourceList   `json:"capacity"`
+	Allocatable  v1.ResourceList   `json:"allocatable"`
+	Labels       map[string]string `json:"labels"`
+	Taints       []v1.Taint        `json:"taints,omitempty"`
+	// Zones and CapacityTypes are the zones and capacity types that the instance type is currently available in
+	Zones         []string `json:"zones"`
+	CapacityTypes []string `json:"capacityTypes"`
+}
+
+// Catalog returns the instance types that the NodePool can launch with the NodeClass, ordered by name. The labels of
+// each entry are the labels that have a single value once the NodePool's requirements and labels are applied to the
+// instance type, such as its instance type, architecture and instance family. GPUs and other extended resources are
+// included in the capacity and allocatable resources.
+func (p *Provider) Catalog(ctx context.Context, nodePool *corev1beta1.NodePool, nodeClass *v1beta1.NodeClass) ([]CatalogEntry, error) {
+	instanceTypes, err := p.List(ctx, nodePool.Spec.Template.Spec.KubeletConfiguration, nodeClass)
+	if err != nil {
+		return nil, err
+	}
+	requirements := scheduling.NewNodeSelectorRequirements(nodePool.Spec.Template.Spec.Requirements...)
+	requirements.Add(scheduling.NewLabelRequirements(nodePool.Spec.Template.Labels).Values()...)
+	var entries []CatalogEntry
+	for _, instanceType := range instanceTypes {
+		if requirements.Intersects(instanceType.Requirements) != nil {
+			continue
+		}
+		offerings := instanceType.Offerings.Available().Requirements(requirements)
+		if len(offerings) == 0 {
+			continue
+		}
+		// The requirements of the NodePool narrow those of the instance type, e.g. to a single capacity type
+		nodeRequirements := scheduling.NewRequirements(instanceType.Requirements.Values()...)
+		nodeRequirements.Add(requirements.Values()...)
+		labels := lo.PickBy(nodeRequirements.Labels(), func(key string, _ string) bool {
+			return nodeRequirements.Get(key).Len() == 1
+		})
+		labels[lo.Ternary(nodePool.IsProvisioner, v1alpha5.ProvisionerNameLabelKey, corev1beta1.NodePoolLabelKey)] = nodePool.Name
+		entries = append(entries, CatalogEntry{
+			InstanceType:  instanceType.Name,
+			Capacity:      instanceType.Capacity,
+			Allocatable:   instanceType.Allocatable(),
+			Labels:        labels,
+			Taints:        nodePool.Spec.Template.Spec.Taints,
+			Zones:         sortedUniq(lo.Map(offerings, func(o cloudprovider.Offering, _ int) string { return o.Zone })),
+			CapacityTypes: sortedUniq(lo.Map(offerings, func(o cloudprovider.Offering, _ int) string { return o.CapacityType })),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].InstanceType < entries[j].InstanceType })
+	return entries, nil
+}
+
+func sortedUniq(values []string) []string {
+	values = lo.Uniq(values)
+	sort.Strings(values)
+	return values
+}
@@ -0,0 +1,101 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instancetype
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/multierr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"knative.dev/pkg/logging"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/events"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	awscache "github.com/aws/karpenter/pkg/cache"
+
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+)
+
+// Controller keeps the instance type catalog and offerings of the provider up to date in the background, and
+// publishes events on the node templates whose zones gained or lost offerings
+type Controller struct {
+	kubeClient           client.Client
+	recorder             events.Recorder
+	instanceTypeProvider *Provider
+}
+
+func NewController(kubeClient client.Client, recorder events.Recorder, instanceTypeProvider *Provider) *Controller {
+	return &Controller{
+		kubeClient:           kubeClient,
+		recorder:             recorder,
+		instanceTypeProvider: instanceTypeProvider,
+	}
+}
+
+func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	errs := []error{c.instanceTypeProvider.UpdateInstanceTypes(ctx)}
+	changes, err := c.instanceTypeProvider.UpdateInstanceTypeOfferings(ctx)
+	errs = append(errs, err)
+	if len(changes) > 0 {
+		errs = append(errs, c.publishOfferingsChanges(ctx, changes))
+	}
+	// jitter the refresh so that replicas and clusters started at the same time don't call EC2 at the same time
+	return reconcile.Result{RequeueAfter: wait.Jitter(awscache.InstanceTypesRefreshInterval, 0.2)}, multierr.Combine(errs...)
+}
+
+func (c *Controller) Name() string {
+	return "instancetype"
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return corecontroller.NewSingletonManagedBy(m)
+}
+
+// publishOfferingsChanges publishes the offerings changes on the node templates that have subnets in the zone
+func (c *Controller) publishOfferingsChanges(ctx context.Context, changes []OfferingsChange) error {
+	for _, change := range changes {
+		logging.FromContext(ctx).With("zone-id", change.ZoneID, "added", change.Added, "removed", change.Removed).Debugf("discovered changes to instance type offerings")
+	}
+	nodeTemplateList := &v1alpha1.AWSNodeTemplateList{}
+	if err := c.kubeClient.List(ctx, nodeTemplateList); err != nil {
+		return fmt.Errorf("listing node templates, %w", err)
+	}
+	for i := range nodeTemplateList.Items {
+		nodeTemplate := &nodeTemplateList.Items[i]
+		zones := map[string]string{}
+		for _, subnet := range nodeTemplate.Status.Subnets {
+			if subnet.ZoneID != "" {
+				zones[subnet.ZoneID] = subnet.Zone
+			}
+		}
+		for _, change := range changes {
+			zone, ok := zones[change.ZoneID]
+			if !ok {
+				continue
+			}
+			if len(change.Added) > 0 {
+				c.recorder.Publish(OfferingsAdded(nodeTemplate, zone, change))
+			}
+			if len(change.Removed) > 0 {
+				c.recorder.Publish(OfferingsRemoved(nodeTemplate, zone, change))
+			}
+		}
+	}
+	return nil
+}
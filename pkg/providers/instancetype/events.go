@@ -0,0 +1,55 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instancetype
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/aws/karpenter-core/pkg/events"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+)
+
+// maxEventInstanceTypes is the number of instance types that are listed in an event message
+const maxEventInstanceTypes = 10
+
+func OfferingsAdded(nodeTemplate *v1alpha1.AWSNodeTemplate, zone string, change OfferingsChange) events.Event {
+	return events.Event{
+		InvolvedObject: nodeTemplate,
+		Type:           v1.EventTypeNormal,
+		Reason:         "InstanceTypeOfferingsAdded",
+		Message:        fmt.Sprintf("Instance types are now offered in zone %s (%s): %s", zone, change.ZoneID, instanceTypeList(change.Added)),
+		DedupeValues:   []string{string(nodeTemplate.UID), change.ZoneID, strings.Join(change.Added, ",")},
+	}
+}
+
+func OfferingsRemoved(nodeTemplate *v1alpha1.AWSNodeTemplate, zone string, change OfferingsChange) events.Event {
+	return events.Event{
+		InvolvedObject: nodeTemplate,
+		Type:           v1.EventTypeWarning,
+		Reason:         "InstanceTypeOfferingsRemoved",
+		Message:        fmt.Sprintf("Instance types are no longer offered in zone %s (%s): %s", zone, change.ZoneID, instanceTypeList(change.Removed)),
+		DedupeValues:   []string{string(nodeTemplate.UID), change.ZoneID, strings.Join(change.Removed, ",")},
+	}
+}
+
+func instanceTypeList(instanceTypes []string) string {
+	if len(instanceTypes) <= maxEventInstanceTypes {
+		return strings.Join(instanceTypes, ", ")
+	}
+	return fmt.Sprintf("%s and %d other(s)", strings.Join(instanceTypes[:maxEventInstanceTypes], ", "), len(instanceTypes)-maxEventInstanceTypes)
+}
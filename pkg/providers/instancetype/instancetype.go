@@ -18,12 +18,16 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"path"
+	"strings"
 	"sync"
 	"sync/atomic"
 
 	"github.com/prometheus/client_golang/prometheus"
 
 	corev1beta1 "github.com/aws/karpenter-core/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/apis/v1beta1"
 	awscache "github.com/aws/karpenter/pkg/cache"
 
@@ -33,27 +37,55 @@ import (
 	"github.com/mitchellh/hashstructure/v2"
 	"github.com/patrickmn/go-cache"
 	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"knative.dev/pkg/logging"
 
+	"github.com/aws/karpenter/pkg/providers/amifamily"
 	"github.com/aws/karpenter/pkg/providers/pricing"
+	"github.com/aws/karpenter/pkg/providers/spotadvisor"
 	"github.com/aws/karpenter/pkg/providers/subnet"
 
 	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/scheduling"
 	"github.com/aws/karpenter-core/pkg/utils/pretty"
 )
 
 const (
-	InstanceTypesCacheKey           = "types"
 	InstanceTypeZonesCacheKeyPrefix = "zones:"
+	// UnlimitedCPUCreditPricePerVCPUHour is the price of surplus CPU credits of burstable instances in unlimited mode
+	// https://aws.amazon.com/ec2/pricing/on-demand/#T2.2FT3.2FT4g_Unlimited_Mode_Pricing
+	UnlimitedCPUCreditPricePerVCPUHour        = 0.05
+	UnlimitedCPUCreditPricePerVCPUHourWindows = 0.096
 )
 
+// burstableBaselineUtilization is the baseline utilization per vCPU of the sizes of burstable instance types, which
+// their earned CPU credits pay for, see
+// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/burstable-credits-baseline-concepts.html#baseline_performance
+var burstableBaselineUtilization = map[string]float64{
+	"nano":    0.05,
+	"micro":   0.1,
+	"small":   0.2,
+	"medium":  0.2,
+	"large":   0.3,
+	"xlarge":  0.4,
+	"2xlarge": 0.4,
+}
+
+// t2BaselineUtilization overrides the baseline utilization of the T2 sizes that differ from the later generations
+var t2BaselineUtilization = map[string]float64{
+	"xlarge":  0.225,
+	"2xlarge": 0.17,
+}
+
 type Provider struct {
 	region          string
 	ec2api          ec2iface.EC2API
 	subnetProvider  *subnet.Provider
 	pricingProvider *pricing.Provider
-	// Has one cache entry for all the instance types (key: InstanceTypesCacheKey)
+	// spotAdvisorProvider scores the spot pools of each instance type by how frequently they're interrupted
+	spotAdvisorProvider *spotadvisor.Provider
 	// Has one cache entry for all the zones for each subnet selector (key: InstanceTypesZonesCacheKeyPrefix:<hash_of_selector>)
 	// Values cached *before* considering insufficient capacity errors from the unavailableOfferings cache.
 	// Fully initialized Instance Types are also cached based on the set of all instance types, zones, unavailableOfferings cache,
@@ -62,23 +94,43 @@ type Provider struct {
 	mu    sync.Mutex
 	cache *cache.Cache
 
-	unavailableOfferings *awscache.UnavailableOfferings
-	cm                   *pretty.ChangeMonitor
+	// instanceTypes is the catalog of all the instance types, which is refreshed in the background by the instance type
+	// controller rather than expiring on the request path, so that provisioning never waits on DescribeInstanceTypes
+	// after the first call
+	instanceTypesMu sync.RWMutex
+	instanceTypes   []*ec2.InstanceTypeInfo
+	// instanceTypeOfferings are the IDs of the zones of the region that each instance type is offered in, and are
+	// refreshed in the background along with the catalog
+	instanceTypeOfferings map[string]sets.Set[string]
+	// zoneIDs maps the names of the zones of the region to their IDs, which don't change for an account
+	zoneIDs map[string]string
+	// refreshMu serializes calls to DescribeInstanceTypes and DescribeInstanceTypeOfferings without blocking readers
+	// of the current catalog
+	refreshMu sync.Mutex
+
+	unavailableOfferings     *awscache.UnavailableOfferings
+	observedMemoryCapacities *awscache.ObservedMemoryCapacities
+	cm                       *pretty.ChangeMonitor
 	// instanceTypesSeqNum is a monotonically increasing change counter used to avoid the expensive hashing operation on instance types
 	instanceTypesSeqNum uint64
+	// instanceTypeOfferingsSeqNum is a monotonically increasing change counter of the instance type offerings
+	instanceTypeOfferingsSeqNum uint64
 }
 
 func NewProvider(region string, cache *cache.Cache, ec2api ec2iface.EC2API, subnetProvider *subnet.Provider,
-	unavailableOfferingsCache *awscache.UnavailableOfferings, pricingProvider *pricing.Provider) *Provider {
+	unavailableOfferingsCache *awscache.UnavailableOfferings, observedMemoryCapacities *awscache.ObservedMemoryCapacities, pricingProvider *pricing.Provider,
+	spotAdvisorProvider *spotadvisor.Provider) *Provider {
 	return &Provider{
-		ec2api:               ec2api,
-		region:               region,
-		subnetProvider:       subnetProvider,
-		pricingProvider:      pricingProvider,
-		cache:                cache,
-		unavailableOfferings: unavailableOfferingsCache,
-		cm:                   pretty.NewChangeMonitor(),
-		instanceTypesSeqNum:  0,
+		ec2api:                   ec2api,
+		region:                   region,
+		subnetProvider:           subnetProvider,
+		pricingProvider:          pricingProvider,
+		spotAdvisorProvider:      spotAdvisorProvider,
+		cache:                    cache,
+		unavailableOfferings:     unavailableOfferingsCache,
+		observedMemoryCapacities: observedMemoryCapacities,
+		cm:                       pretty.NewChangeMonitor(),
+		instanceTypesSeqNum:      0,
 	}
 }
 
@@ -88,23 +140,63 @@ func (p *Provider) List(ctx context.Context, kc *corev1beta1.KubeletConfiguratio
 	if err != nil {
 		return nil, err
 	}
+	// Filter out instance types that are globally disallowed by settings
+	instanceTypes = lo.Filter(instanceTypes, func(i *ec2.InstanceTypeInfo, _ int) bool {
+		return isAllowed(ctx, aws.StringValue(i.InstanceType))
+	})
+	// Filter out instance types that don't support the Nitro Enclaves and AMD SEV-SNP options of the NodeClass
+	instanceTypes = lo.Filter(instanceTypes, func(i *ec2.InstanceTypeInfo, _ int) bool {
+		return supportsCPUFeatures(i, nodeClass)
+	})
+	// Filter out instance types that don't encrypt traffic in transit if the NodeClass requires it
+	instanceTypes = lo.Filter(instanceTypes, func(i *ec2.InstanceTypeInfo, _ int) bool {
+		return !lo.FromPtr(nodeClass.Spec.RequireEncryptionInTransit) ||
+			(i.NetworkInfo != nil && aws.BoolValue(i.NetworkInfo.EncryptionInTransitSupported))
+	})
+	// Filter out instance types that EC2 won't select with the instance requirements of the NodeClass
+	instanceTypes = lo.Filter(instanceTypes, func(i *ec2.InstanceTypeInfo, _ int) bool {
+		return matchesInstanceRequirements(i, nodeClass.Spec.InstanceRequirements)
+	})
 	// Get Viable EC2 Purchase offerings
 	instanceTypeZones, err := p.getInstanceTypeZones(ctx, nodeClass)
 	if err != nil {
 		return nil, err
 	}
+	zoneIDs, err := p.getZoneIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	// Compute fully initialized instance types hash key
 	instanceTypeZonesHash, _ := hashstructure.Hash(instanceTypeZones, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
 	kcHash, _ := hashstructure.Hash(kc, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
-	key := fmt.Sprintf("%d-%d-%s-%016x-%016x", p.instanceTypesSeqNum, p.unavailableOfferings.SeqNum, nodeClass.UID, instanceTypeZonesHash, kcHash)
+	nodeClassHash, _ := hashstructure.Hash([]interface{}{nodeClass.Spec.MaxPodsPerInstanceType, nodeClass.Spec.CPUCreditSpecification, nodeClass.Spec.AMIFamily,
+		nodeClass.Spec.EnclaveOptions, nodeClass.Spec.CPUOptions, nodeClass.Spec.InstanceRequirements, nodeClass.Spec.RequireEncryptionInTransit}, hashstructure.FormatV2, nil)
+	vmMemoryOverheadHash, _ := hashstructure.Hash([]interface{}{settings.FromContext(ctx).VMMemoryOverheadPercent, settings.FromContext(ctx).VMMemoryOverheadPercentPerInstanceType,
+		settings.FromContext(ctx).EnableVMMemoryOverheadLearning}, hashstructure.FormatV2, nil)
+	allowedHash, _ := hashstructure.Hash([]interface{}{settings.FromContext(ctx).AllowedInstanceFamilies, settings.FromContext(ctx).ExcludedInstanceTypes,
+		settings.FromContext(ctx).MinimumInstanceGeneration},
+		hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
+	key := fmt.Sprintf("%d-%d-%d-%d-%s-%016x-%016x-%016x-%016x-%016x", p.instanceTypesSeqNum, p.unavailableOfferings.SeqNum, p.observedMemoryCapacities.SeqNum,
+		atomic.LoadUint64(&p.spotAdvisorProvider.SeqNum), nodeClass.UID,
+		instanceTypeZonesHash, kcHash, nodeClassHash, vmMemoryOverheadHash, allowedHash)
 
 	if item, ok := p.cache.Get(key); ok {
 		return item.([]*cloudprovider.InstanceType), nil
 	}
+	amiFamily := lo.FromPtrOr(nodeClass.Spec.AMIFamily, v1alpha1.AMIFamilyAL2)
+	observedMemoryCapacities := p.observedMemoryCapacities.List(amiFamily)
+	vmMemoryOverheads := vmMemoryOverheadPercentPerFamily(instanceTypes, observedMemoryCapacities)
 	// Reject any instance types that don't have any offerings due to zone
 	result := lo.Reject(lo.Map(instanceTypes, func(i *ec2.InstanceTypeInfo, _ int) *cloudprovider.InstanceType {
-		return NewInstanceType(ctx, i, kc, p.region, nodeClass, p.createOfferings(ctx, i, instanceTypeZones[aws.StringValue(i.InstanceType)]))
+		instanceType := NewInstanceType(ctx, i, kc, p.region, nodeClass, p.createOfferings(ctx, i, nodeClass, instanceTypeZones[aws.StringValue(i.InstanceType)]),
+			p.observedMemory(ctx, i, observedMemoryCapacities, vmMemoryOverheads))
+		instanceType.Requirements.Add(scheduling.NewRequirement(v1beta1.LabelTopologyZoneID, v1.NodeSelectorOpIn, lo.Uniq(lo.FilterMap(instanceType.Offerings.Available(), func(o cloudprovider.Offering, _ int) (string, bool) {
+			zoneID, ok := zoneIDs[o.Zone]
+			return zoneID, ok
+		}))...))
+		p.addSpotInterruptionRateBucket(instanceType, nodeClass)
+		return instanceType
 	}), func(i *cloudprovider.InstanceType, _ int) bool {
 		return len(i.Offerings) == 0
 	})
@@ -120,15 +212,125 @@ func (p *Provider) List(ctx context.Context, kc *corev1beta1.KubeletConfiguratio
 	return result, nil
 }
 
+// isAllowed returns true if the instance type's family matches aws.allowedInstanceFamilies (when set), the instance type
+// doesn't match aws.excludedInstanceTypes, and its generation isn't older than aws.minimumInstanceGeneration (when set)
+func isAllowed(ctx context.Context, name string) bool {
+	match := func(patterns []string, s string) bool {
+		return lo.ContainsBy(patterns, func(pattern string) bool {
+			matched, _ := path.Match(pattern, s)
+			return matched
+		})
+	}
+	family, _, _ := strings.Cut(name, ".")
+	if allowed := settings.FromContext(ctx).AllowedInstanceFamilies; len(allowed) > 0 && !match(allowed, family) {
+		return false
+	}
+	if minimum := settings.FromContext(ctx).MinimumInstanceGeneration; minimum > 0 {
+		// Instance types without a generation in their name can't be compared with the minimum, so they're excluded
+		if generation, ok := instanceGeneration(name); !ok || generation < minimum {
+			return false
+		}
+	}
+	return !match(settings.FromContext(ctx).ExcludedInstanceTypes, name)
+}
+
+// supportsCPUFeatures returns false if the NodeClass enables Nitro Enclaves or AMD SEV-SNP and the instance type
+// doesn't support them, since EC2 fails to launch such instance types with these options
+func supportsCPUFeatures(info *ec2.InstanceTypeInfo, nodeClass *v1beta1.NodeClass) bool {
+	if nodeClass.Spec.EnclaveOptions != nil && aws.BoolValue(nodeClass.Spec.EnclaveOptions.Enabled) &&
+		aws.StringValue(info.NitroEnclavesSupport) != ec2.NitroEnclavesSupportSupported {
+		return false
+	}
+	if nodeClass.Spec.CPUOptions != nil && aws.StringValue(nodeClass.Spec.CPUOptions.AMDSEVSNP) == v1beta1.AMDSEVSNPEnabled &&
+		(info.ProcessorInfo == nil || !lo.Contains(aws.StringValueSlice(info.ProcessorInfo.SupportedFeatures), ec2.SupportedAdditionalProcessorFeatureAmdSevSnp)) {
+		return false
+	}
+	return true
+}
+
+// matchesInstanceRequirements returns false if the instance type doesn't have the attributes of the NodeClass's instance
+// requirements, since EC2 only selects instance types that have them
+func matchesInstanceRequirements(info *ec2.InstanceTypeInfo, requirements *v1beta1.InstanceRequirements) bool {
+	if requirements == nil {
+		return true
+	}
+	inRange := func(r *v1beta1.InstanceRequirementsRange, v int64) bool {
+		return r == nil || (r.Min == nil || v >= *r.Min) && (r.Max == nil || v <= *r.Max)
+	}
+	devices := acceleratorDevices(info)
+	if !inRange(requirements.VCPU, aws.Int64Value(info.VCpuInfo.DefaultVCpus)) ||
+		!inRange(requirements.MemoryMiB, aws.Int64Value(info.MemoryInfo.SizeInMiB)) ||
+		!inRange(requirements.AcceleratorCount, lo.SumBy(devices, func(d acceleratorDevice) int64 { return d.count })) {
+		return false
+	}
+	if len(requirements.AcceleratorTypes) > 0 && !lo.ContainsBy(devices, func(d acceleratorDevice) bool {
+		return lo.Contains(requirements.AcceleratorTypes, d.acceleratorType)
+	}) {
+		return false
+	}
+	if len(requirements.AcceleratorManufacturers) > 0 && !lo.ContainsBy(devices, func(d acceleratorDevice) bool {
+		return lo.Contains(requirements.AcceleratorManufacturers, d.manufacturer)
+	}) {
+		return false
+	}
+	return !lo.ContainsBy(requirements.ExcludedInstanceTypes, func(pattern string) bool {
+		matched, _ := path.Match(pattern, aws.StringValue(info.InstanceType))
+		return matched
+	})
+}
+
+// addSpotInterruptionRateBucket adds the interruption frequency bucket of the spot pools of the instance type to its
+// requirements, so that frequently interrupted instance types can be excluded. Instance types that the Spot Instance
+// Advisor doesn't report, or that aren't offered as spot, don't have the label.
+func (p *Provider) addSpotInterruptionRateBucket(instanceType *cloudprovider.InstanceType, nodeClass *v1beta1.NodeClass) {
+	key := lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.LabelSpotInterruptionRateBucket, v1beta1.LabelSpotInterruptionRateBucket)
+	os := spotadvisor.OSLinux
+	if _, ok := amifamily.GetAMIFamily(nodeClass.Spec.AMIFamily, &amifamily.Options{}).(*amifamily.Windows); ok {
+		os = spotadvisor.OSWindows
+	}
+	bucket, ok := p.spotAdvisorProvider.InterruptionRateBucket(os, instanceType.Name)
+	if !ok || !lo.ContainsBy(instanceType.Offerings, func(o cloudprovider.Offering) bool { return o.CapacityType == ec2.UsageClassTypeSpot }) {
+		instanceType.Requirements.Add(scheduling.NewRequirement(key, v1.NodeSelectorOpDoesNotExist))
+		return
+	}
+	instanceType.Requirements.Add(scheduling.NewRequirement(key, v1.NodeSelectorOpIn, fmt.Sprint(bucket)))
+}
+
+// observedMemory returns the memory capacity observed on nodes of the instance type and AMI family when learning the
+// VM memory overhead is enabled. Instance types that haven't been observed are estimated from the VM memory overhead
+// observed on other sizes of their family, unless the VM memory overhead of the instance type is configured.
+func (p *Provider) observedMemory(ctx context.Context, info *ec2.InstanceTypeInfo, observedMemoryCapacities map[string]resource.Quantity,
+	vmMemoryOverheads map[string]float64) *resource.Quantity {
+	if !settings.FromContext(ctx).EnableVMMemoryOverheadLearning {
+		return nil
+	}
+	name := aws.StringValue(info.InstanceType)
+	if capacity, ok := observedMemoryCapacities[name]; ok {
+		return &capacity
+	}
+	if _, ok := instanceTypeOverride(settings.FromContext(ctx).VMMemoryOverheadPercentPerInstanceType, name); ok {
+		return nil
+	}
+	if overhead, ok := vmMemoryOverheads[strings.Split(name, ".")[0]]; ok {
+		return memoryWithOverhead(info, overhead)
+	}
+	return nil
+}
+
 func (p *Provider) LivenessProbe(req *http.Request) error {
+	// ensure we don't deadlock and nolint for the empty critical section
+	p.instanceTypesMu.Lock()
+	//nolint: staticcheck
+	p.instanceTypesMu.Unlock()
 	if err := p.subnetProvider.LivenessProbe(req); err != nil {
 		return err
 	}
 	return p.pricingProvider.LivenessProbe(req)
 }
 
-func (p *Provider) createOfferings(ctx context.Context, instanceType *ec2.InstanceTypeInfo, zones sets.Set[string]) []cloudprovider.Offering {
+func (p *Provider) createOfferings(ctx context.Context, instanceType *ec2.InstanceTypeInfo, nodeClass *v1beta1.NodeClass, zones sets.Set[string]) []cloudprovider.Offering {
 	var offerings []cloudprovider.Offering
+	surcharge := unlimitedCPUCreditSurcharge(instanceType, nodeClass)
 	for zone := range zones {
 		// while usage classes should be a distinct set, there's no guarantee of that
 		for capacityType := range sets.NewString(aws.StringValueSlice(instanceType.SupportedUsageClasses)...) {
@@ -149,7 +351,7 @@ func (p *Provider) createOfferings(ctx context.Context, instanceType *ec2.Instan
 			offerings = append(offerings, cloudprovider.Offering{
 				Zone:         zone,
 				CapacityType: capacityType,
-				Price:        price,
+				Price:        price + surcharge,
 				Available:    available,
 			})
 		}
@@ -157,6 +359,32 @@ func (p *Provider) createOfferings(ctx context.Context, instanceType *ec2.Instan
 	return offerings
 }
 
+// unlimitedCPUCreditSurcharge returns the most that a burstable instance in unlimited mode can be charged for surplus
+// CPU credits per hour, when all of its vCPUs run at full utilization. Surplus credits are only charged for the
+// utilization above the baseline that the instance earns credits for. Without it, unlimited instances appear cheaper
+// than they can actually be.
+func unlimitedCPUCreditSurcharge(instanceType *ec2.InstanceTypeInfo, nodeClass *v1beta1.NodeClass) float64 {
+	if lo.FromPtr(nodeClass.Spec.CPUCreditSpecification) != v1beta1.CPUCreditSpecificationUnlimited || !aws.BoolValue(instanceType.BurstablePerformanceSupported) {
+		return 0
+	}
+	price := UnlimitedCPUCreditPricePerVCPUHour
+	if _, ok := amifamily.GetAMIFamily(nodeClass.Spec.AMIFamily, &amifamily.Options{}).(*amifamily.Windows); ok {
+		price = UnlimitedCPUCreditPricePerVCPUHourWindows
+	}
+	return float64(aws.Int64Value(instanceType.VCpuInfo.DefaultVCpus)) * (1 - baselineUtilization(aws.StringValue(instanceType.InstanceType))) * price
+}
+
+// baselineUtilization returns the baseline utilization per vCPU of a burstable instance type, or 0 if it isn't known
+func baselineUtilization(instanceType string) float64 {
+	family, size, _ := strings.Cut(instanceType, ".")
+	if baseline, ok := t2BaselineUtilization[size]; ok && family == "t2" {
+		return baseline
+	}
+	return burstableBaselineUtilization[size]
+}
+
+// getInstanceTypeZones returns the names of the zones that each instance type is offered in, of the zones of the
+// node class's subnets
 func (p *Provider) getInstanceTypeZones(ctx context.Context, nodeClass *v1beta1.NodeClass) (map[string]sets.Set[string], error) {
 	// DO NOT REMOVE THIS LOCK ----------------------------------------------------------------------------
 	// We lock here so that multiple callers to getInstanceTypeZones do not result in cache misses and multiple
@@ -165,16 +393,21 @@ func (p *Provider) getInstanceTypeZones(ctx context.Context, nodeClass *v1beta1.
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	offerings, err := p.getInstanceTypeOfferings(ctx)
+	if err != nil {
+		return nil, err
+	}
 	subnetSelectorHash, err := hashstructure.Hash(nodeClass.Spec.SubnetSelectorTerms, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
 	if err != nil {
 		return nil, fmt.Errorf("failed to hash the subnet selector: %w", err)
 	}
-	cacheKey := fmt.Sprintf("%s%016x", InstanceTypeZonesCacheKeyPrefix, subnetSelectorHash)
+	cacheKey := fmt.Sprintf("%s%d-%016x", InstanceTypeZonesCacheKeyPrefix, atomic.LoadUint64(&p.instanceTypeOfferingsSeqNum), subnetSelectorHash)
 	if cached, ok := p.cache.Get(cacheKey); ok {
 		return cached.(map[string]sets.Set[string]), nil
 	}
 
-	// Constrain AZs from subnets
+	// Constrain AZs from subnets. Offerings are discovered by zone ID, since zone names are mapped to different
+	// physical zones in each account, and are translated to the zone names of the subnets.
 	subnets, err := p.subnetProvider.List(ctx, nodeClass)
 	if err != nil {
 		return nil, err
@@ -182,47 +415,170 @@ func (p *Provider) getInstanceTypeZones(ctx context.Context, nodeClass *v1beta1.
 	if len(subnets) == 0 {
 		return nil, nil
 	}
-	zones := sets.NewString(lo.Map(subnets, func(subnet *ec2.Subnet, _ int) string {
-		return aws.StringValue(subnet.AvailabilityZone)
-	})...)
-
-	// Get offerings from EC2
+	zones := lo.SliceToMap(subnets, func(subnet *ec2.Subnet) (string, string) {
+		return aws.StringValue(subnet.AvailabilityZoneId), aws.StringValue(subnet.AvailabilityZone)
+	})
 	instanceTypeZones := map[string]sets.Set[string]{}
-	if err := p.ec2api.DescribeInstanceTypeOfferingsPagesWithContext(ctx, &ec2.DescribeInstanceTypeOfferingsInput{LocationType: aws.String("availability-zone")},
-		func(output *ec2.DescribeInstanceTypeOfferingsOutput, lastPage bool) bool {
-			for _, offering := range output.InstanceTypeOfferings {
-				if zones.Has(aws.StringValue(offering.Location)) {
-					if _, ok := instanceTypeZones[aws.StringValue(offering.InstanceType)]; !ok {
-						instanceTypeZones[aws.StringValue(offering.InstanceType)] = sets.New[string]()
-					}
-					instanceTypeZones[aws.StringValue(offering.InstanceType)].Insert(aws.StringValue(offering.Location))
+	for instanceType, zoneIDs := range offerings {
+		for zoneID := range zoneIDs {
+			if zone, ok := zones[zoneID]; ok {
+				if _, ok := instanceTypeZones[instanceType]; !ok {
+					instanceTypeZones[instanceType] = sets.New[string]()
 				}
+				instanceTypeZones[instanceType].Insert(zone)
 			}
-			return true
-		}); err != nil {
-		return nil, fmt.Errorf("describing instance type zone offerings, %w", err)
+		}
 	}
 	if p.cm.HasChanged("zonal-offerings", nodeClass.Spec.SubnetSelectorTerms) {
-		logging.FromContext(ctx).With("zones", zones.List(), "instance-type-count", len(instanceTypeZones), "node-template", nodeClass.Name).Debugf("discovered offerings for instance types")
+		logging.FromContext(ctx).With("zones", sets.List(sets.New(lo.Values(zones)...)), "instance-type-count", len(instanceTypeZones), "node-template", nodeClass.Name).Debugf("discovered offerings for instance types")
 	}
 	p.cache.SetDefault(cacheKey, instanceTypeZones)
 	return instanceTypeZones, nil
 }
 
-// GetInstanceTypes retrieves all instance types from the ec2 DescribeInstanceTypes API using some opinionated filters
+// getInstanceTypeOfferings returns the IDs of the zones that each instance type is offered in. The offerings are only
+// fetched from EC2 on the request path if they haven't been discovered yet, after which they're kept up to date by
+// UpdateInstanceTypeOfferings.
+func (p *Provider) getInstanceTypeOfferings(ctx context.Context) (map[string]sets.Set[string], error) {
+	if offerings := p.offerings(); len(offerings) > 0 {
+		return offerings, nil
+	}
+	p.refreshMu.Lock()
+	defer p.refreshMu.Unlock()
+	// the offerings may have been discovered by another caller while we were waiting for the lock
+	if offerings := p.offerings(); len(offerings) > 0 {
+		return offerings, nil
+	}
+	if _, err := p.refreshOfferings(ctx); err != nil {
+		return nil, err
+	}
+	return p.offerings(), nil
+}
+
+func (p *Provider) offerings() map[string]sets.Set[string] {
+	p.instanceTypesMu.RLock()
+	defer p.instanceTypesMu.RUnlock()
+	return p.instanceTypeOfferings
+}
+
+// OfferingsChange is the set of instance types whose offerings appeared in or disappeared from a zone
+type OfferingsChange struct {
+	ZoneID  string
+	Added   []string
+	Removed []string
+}
+
+// UpdateInstanceTypeOfferings retrieves the offerings of all instance types in all zones of the region from the ec2
+// DescribeInstanceTypeOfferings API and replaces the current offerings with them. It returns how the offerings
+// changed in each zone, which is empty the first time the offerings are discovered.
+func (p *Provider) UpdateInstanceTypeOfferings(ctx context.Context) ([]OfferingsChange, error) {
+	p.refreshMu.Lock()
+	defer p.refreshMu.Unlock()
+	return p.refreshOfferings(ctx)
+}
+
+// refreshOfferings replaces the offerings with the offerings from EC2, and must be called while holding refreshMu
+func (p *Provider) refreshOfferings(ctx context.Context) ([]OfferingsChange, error) {
+	// All zones are described in a single batch of pages for the region, rather than once per node class
+	offerings := map[string]sets.Set[string]{}
+	if err := p.ec2api.DescribeInstanceTypeOfferingsPagesWithContext(ctx, &ec2.DescribeInstanceTypeOfferingsInput{
+		LocationType: aws.String(ec2.LocationTypeAvailabilityZoneId),
+		MaxResults:   aws.Int64(1000),
+	}, func(output *ec2.DescribeInstanceTypeOfferingsOutput, lastPage bool) bool {
+		for _, offering := range output.InstanceTypeOfferings {
+			if _, ok := offerings[aws.StringValue(offering.InstanceType)]; !ok {
+				offerings[aws.StringValue(offering.InstanceType)] = sets.New[string]()
+			}
+			offerings[aws.StringValue(offering.InstanceType)].Insert(aws.StringValue(offering.Location))
+		}
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("describing instance type zone offerings, %w", err)
+	}
+	// an empty response would make every instance type unavailable, so we keep the current offerings instead
+	if len(offerings) == 0 {
+		return nil, fmt.Errorf("no instance type offerings found using ec2.DescribeInstanceTypeOfferings")
+	}
+	p.instanceTypesMu.Lock()
+	defer p.instanceTypesMu.Unlock()
+	var changes []OfferingsChange
+	if p.instanceTypeOfferings != nil {
+		changes = diffOfferings(p.instanceTypeOfferings, offerings)
+	}
+	if p.instanceTypeOfferings == nil || len(changes) > 0 {
+		p.instanceTypeOfferings = offerings
+		atomic.AddUint64(&p.instanceTypeOfferingsSeqNum, 1)
+	}
+	return changes, nil
+}
+
+// diffOfferings returns the instance types whose offerings were added to or removed from each zone, ordered by zone ID
+func diffOfferings(previous, current map[string]sets.Set[string]) []OfferingsChange {
+	byZone := func(offerings map[string]sets.Set[string]) map[string]sets.Set[string] {
+		zones := map[string]sets.Set[string]{}
+		for instanceType, zoneIDs := range offerings {
+			for zoneID := range zoneIDs {
+				if _, ok := zones[zoneID]; !ok {
+					zones[zoneID] = sets.New[string]()
+				}
+				zones[zoneID].Insert(instanceType)
+			}
+		}
+		return zones
+	}
+	previousZones, currentZones := byZone(previous), byZone(current)
+	var changes []OfferingsChange
+	for _, zoneID := range sets.List(sets.KeySet(previousZones).Union(sets.KeySet(currentZones))) {
+		added := currentZones[zoneID].Difference(previousZones[zoneID])
+		removed := previousZones[zoneID].Difference(currentZones[zoneID])
+		if added.Len() > 0 || removed.Len() > 0 {
+			changes = append(changes, OfferingsChange{ZoneID: zoneID, Added: sets.List(added), Removed: sets.List(removed)})
+		}
+	}
+	return changes
+}
+
+// GetInstanceTypes returns all instance types from the catalog. The catalog is only fetched from EC2 on the request
+// path if it hasn't been populated yet, after which it's kept up to date by UpdateInstanceTypes.
 func (p *Provider) GetInstanceTypes(ctx context.Context) ([]*ec2.InstanceTypeInfo, error) {
+	if instanceTypes := p.catalog(); len(instanceTypes) > 0 {
+		return instanceTypes, nil
+	}
+	p.refreshMu.Lock()
+	defer p.refreshMu.Unlock()
+	// the catalog may have been populated by another caller while we were waiting for the lock
+	if instanceTypes := p.catalog(); len(instanceTypes) > 0 {
+		return instanceTypes, nil
+	}
+	if err := p.refresh(ctx); err != nil {
+		return nil, err
+	}
+	return p.catalog(), nil
+}
+
+func (p *Provider) catalog() []*ec2.InstanceTypeInfo {
+	p.instanceTypesMu.RLock()
+	defer p.instanceTypesMu.RUnlock()
+	return p.instanceTypes
+}
+
+// UpdateInstanceTypes retrieves all instance types from the ec2 DescribeInstanceTypes API using some opinionated filters
+// and replaces the catalog with them. The current catalog continues to be served while it's updated, and is kept if
+// the update fails.
+func (p *Provider) UpdateInstanceTypes(ctx context.Context) error {
 	// DO NOT REMOVE THIS LOCK ----------------------------------------------------------------------------
-	// We lock here so that multiple callers to GetInstanceTypes do not result in cache misses and multiple
-	// calls to EC2 when we could have just made one call. This lock is here because multiple callers to EC2 result
-	// in A LOT of extra memory generated from the response for simultaneous callers.
-	// TODO @joinnis: This can be made more efficient by holding a Read lock and only obtaining the Write if not in cache
-	p.mu.Lock()
-	defer p.mu.Unlock()
+	// We lock here so that multiple callers to UpdateInstanceTypes do not result in multiple calls to EC2
+	// when we could have just made one call. Multiple callers to EC2 result in A LOT of extra memory
+	// generated from the response for simultaneous callers.
+	p.refreshMu.Lock()
+	defer p.refreshMu.Unlock()
+	return p.refresh(ctx)
+}
 
-	if cached, ok := p.cache.Get(InstanceTypesCacheKey); ok {
-		return cached.([]*ec2.InstanceTypeInfo), nil
-	}
+// refresh replaces the catalog with the instance types from EC2, and must be called while holding refreshMu
+func (p *Provider) refresh(ctx context.Context) error {
 	var instanceTypes []*ec2.InstanceTypeInfo
+	seen := sets.New[string]()
 	if err := p.ec2api.DescribeInstanceTypesPagesWithContext(ctx, &ec2.DescribeInstanceTypesInput{
 		Filters: []*ec2.Filter{
 			{
@@ -234,17 +590,77 @@ func (p *Provider) GetInstanceTypes(ctx context.Context) ([]*ec2.InstanceTypeInf
 				Values: aws.StringSlice([]string{"x86_64", "arm64"}),
 			},
 		},
+		MaxResults: aws.Int64(100),
 	}, func(page *ec2.DescribeInstanceTypesOutput, lastPage bool) bool {
-		instanceTypes = append(instanceTypes, page.InstanceTypes...)
+		for _, instanceType := range page.InstanceTypes {
+			// pages can overlap if the instance types change while paginating, so we only keep the first occurrence
+			if name := aws.StringValue(instanceType.InstanceType); name != "" && !seen.Has(name) {
+				seen.Insert(name)
+				instanceTypes = append(instanceTypes, instanceType)
+			}
+		}
 		return true
 	}); err != nil {
-		return nil, fmt.Errorf("fetching instance types using ec2.DescribeInstanceTypes, %w", err)
+		return fmt.Errorf("fetching instance types using ec2.DescribeInstanceTypes, %w", err)
+	}
+	// an empty response would make every instance type unavailable, so we keep the current catalog instead
+	if len(instanceTypes) == 0 {
+		return fmt.Errorf("no instance types found using ec2.DescribeInstanceTypes")
 	}
 	if p.cm.HasChanged("instance-types", instanceTypes) {
 		logging.FromContext(ctx).With(
 			"count", len(instanceTypes)).Debugf("discovered instance types")
 	}
+	p.instanceTypesMu.Lock()
+	defer p.instanceTypesMu.Unlock()
+	p.instanceTypes = instanceTypes
 	atomic.AddUint64(&p.instanceTypesSeqNum, 1)
-	p.cache.SetDefault(InstanceTypesCacheKey, instanceTypes)
-	return instanceTypes, nil
+	return nil
+}
+
+func (p *Provider) Reset() {
+	p.instanceTypesMu.Lock()
+	defer p.instanceTypesMu.Unlock()
+	p.instanceTypes = nil
+	p.instanceTypeOfferings = nil
+	p.zoneIDs = nil
+}
+
+// ZoneID returns the ID of the zone with the name, once the zones of the region have been discovered
+func (p *Provider) ZoneID(zone string) (string, bool) {
+	p.instanceTypesMu.RLock()
+	defer p.instanceTypesMu.RUnlock()
+	zoneID, ok := p.zoneIDs[zone]
+	return zoneID, ok
+}
+
+// getZoneIDs returns the IDs of the zones of the region by name. The zones are only discovered once, since the
+// mapping of zone names to zone IDs doesn't change for an account.
+func (p *Provider) getZoneIDs(ctx context.Context) (map[string]string, error) {
+	p.instanceTypesMu.RLock()
+	zoneIDs := p.zoneIDs
+	p.instanceTypesMu.RUnlock()
+	if len(zoneIDs) > 0 {
+		return zoneIDs, nil
+	}
+	p.refreshMu.Lock()
+	defer p.refreshMu.Unlock()
+	p.instanceTypesMu.RLock()
+	zoneIDs = p.zoneIDs
+	p.instanceTypesMu.RUnlock()
+	// the zones may have been discovered by another caller while we were waiting for the lock
+	if len(zoneIDs) > 0 {
+		return zoneIDs, nil
+	}
+	output, err := p.ec2api.DescribeAvailabilityZonesWithContext(ctx, &ec2.DescribeAvailabilityZonesInput{})
+	if err != nil {
+		return nil, fmt.Errorf("describing availability zones, %w", err)
+	}
+	zoneIDs = lo.SliceToMap(output.AvailabilityZones, func(zone *ec2.AvailabilityZone) (string, string) {
+		return aws.StringValue(zone.ZoneName), aws.StringValue(zone.ZoneId)
+	})
+	p.instanceTypesMu.Lock()
+	defer p.instanceTypesMu.Unlock()
+	p.zoneIDs = zoneIDs
+	return zoneIDs, nil
 }
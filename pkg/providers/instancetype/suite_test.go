@@ -20,6 +20,7 @@ import (
 	"math"
 	"net"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -37,6 +38,7 @@ import (
 	clock "k8s.io/utils/clock/testing"
 	. "knative.dev/pkg/logging/testing"
 	"knative.dev/pkg/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
 	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
@@ -95,7 +97,8 @@ var _ = BeforeSuite(func() {
 
 	fakeClock = &clock.FakeClock{}
 	cloudProvider = cloudprovider.New(awsEnv.InstanceTypesProvider, awsEnv.InstanceProvider, events.NewRecorder(&record.FakeRecorder{}),
-		env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider)
+		env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider, awsEnv.QuotaProvider,
+		awsEnv.NotificationProvider)
 	cluster = state.NewCluster(fakeClock, env.Client, cloudProvider)
 	prov = provisioning.NewProvisioner(env.Client, env.KubernetesInterface.CoreV1(), events.NewRecorder(&record.FakeRecorder{}), cloudProvider, cluster)
 })
@@ -167,6 +170,8 @@ var _ = AfterEach(func() {
 
 var _ = Describe("Instance Types", func() {
 	It("should support individual instance type labels", func() {
+		awsEnv.SpotAdvisorAPI.Data.Set(fake.NewSpotAdvisorData("", map[string]int{"g4dn.8xlarge": 1}))
+		Expect(awsEnv.SpotAdvisorProvider.Update(ctx)).To(Succeed())
 		ExpectApplied(ctx, env.Client, provisioner, windowsProvisioner, nodeTemplate, windowsNodeTemplate)
 
 		nodeSelector := map[string]string{
@@ -197,6 +202,7 @@ var _ = Describe("Instance Types", func() {
 			v1alpha1.LabelInstanceAcceleratorName:              "inferentia",
 			v1alpha1.LabelInstanceAcceleratorManufacturer:      "aws",
 			v1alpha1.LabelInstanceAcceleratorCount:             "1",
+			v1alpha1.LabelSpotInterruptionRateBucket:           "1",
 			// Deprecated Labels
 			v1.LabelFailureDomainBetaRegion: "",
 			v1.LabelFailureDomainBetaZone:   "test-zone-1a",
@@ -256,13 +262,14 @@ var _ = Describe("Instance Types", func() {
 			"topology.ebs.csi.aws.com/zone": "test-zone-1a",
 		}
 
-		// Ensure that we're exercising all well known labels except for accelerator labels
+		// Ensure that we're exercising all well known labels except for accelerator and spot interruption rate labels
 		Expect(lo.Keys(nodeSelector)).To(ContainElements(
 			append(
 				v1alpha5.WellKnownLabels.Difference(sets.New(
 					v1alpha1.LabelInstanceAcceleratorCount,
 					v1alpha1.LabelInstanceAcceleratorName,
 					v1alpha1.LabelInstanceAcceleratorManufacturer,
+					v1alpha1.LabelSpotInterruptionRateBucket,
 					v1.LabelWindowsBuild,
 				)).UnsortedList(), lo.Keys(v1alpha5.NormalizedLabels)...)))
 
@@ -305,13 +312,14 @@ var _ = Describe("Instance Types", func() {
 			"topology.ebs.csi.aws.com/zone": "test-zone-1a",
 		}
 
-		// Ensure that we're exercising all well known labels except for gpu labels and nvme
+		// Ensure that we're exercising all well known labels except for gpu labels, nvme and spot interruption rates
 		expectedLabels := append(v1alpha5.WellKnownLabels.Difference(sets.New(
 			v1alpha1.LabelInstanceGPUCount,
 			v1alpha1.LabelInstanceGPUName,
 			v1alpha1.LabelInstanceGPUManufacturer,
 			v1alpha1.LabelInstanceGPUMemory,
 			v1alpha1.LabelInstanceLocalNVME,
+			v1alpha1.LabelSpotInterruptionRateBucket,
 			v1.LabelWindowsBuild,
 		)).UnsortedList(), lo.Keys(v1alpha5.NormalizedLabels)...)
 		Expect(lo.Keys(nodeSelector)).To(ContainElements(expectedLabels))
@@ -661,7 +669,7 @@ var _ = Describe("Instance Types", func() {
 		instanceInfo, err := awsEnv.InstanceTypesProvider.GetInstanceTypes(ctx)
 		Expect(err).To(BeNil())
 		for _, info := range instanceInfo {
-			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 			Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", 110))
 		}
 	})
@@ -669,7 +677,7 @@ var _ = Describe("Instance Types", func() {
 		instanceInfo, err := awsEnv.InstanceTypesProvider.GetInstanceTypes(ctx)
 		Expect(err).To(BeNil())
 		for _, info := range instanceInfo {
-			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 			Expect(it.Capacity.Pods().Value()).ToNot(BeNumerically("==", 110))
 		}
 	})
@@ -693,7 +701,7 @@ var _ = Describe("Instance Types", func() {
 			EnableENILimitedPodDensity: lo.ToPtr(true),
 		}))
 		for _, info := range instanceInfo {
-			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(windowsNodeTemplate), nil)
+			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(windowsNodeTemplate), nil, nil)
 			Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", 110))
 		}
 	})
@@ -727,6 +735,443 @@ var _ = Describe("Instance Types", func() {
 		}
 	})
 
+	Context("Allowed Instance Types", func() {
+		It("should only list instance types in aws.allowedInstanceFamilies", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{AllowedInstanceFamilies: []string{"m5", "t4*"}}))
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			Expect(lo.Map(instanceTypes, func(it *corecloudprovider.InstanceType, _ int) string { return it.Name })).To(ConsistOf(
+				"m5.large", "m5.metal", "m5.xlarge", "t4g.medium", "t4g.small", "t4g.xlarge",
+			))
+		})
+		It("should not list instance types in aws.excludedInstanceTypes", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{ExcludedInstanceTypes: []string{"*.metal", "t*"}}))
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			Expect(instanceTypes).ToNot(BeEmpty())
+			for _, it := range instanceTypes {
+				Expect(it.Name).ToNot(Or(Equal("m5.metal"), HavePrefix("t")))
+			}
+		})
+		It("should exclude instance types in aws.excludedInstanceTypes from aws.allowedInstanceFamilies", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+				AllowedInstanceFamilies: []string{"m5"},
+				ExcludedInstanceTypes:   []string{"m5.metal"},
+			}))
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			Expect(lo.Map(instanceTypes, func(it *corecloudprovider.InstanceType, _ int) string { return it.Name })).To(ConsistOf("m5.large", "m5.xlarge"))
+		})
+		It("should not list instance types older than aws.minimumInstanceGeneration", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{MinimumInstanceGeneration: lo.ToPtr(5)}))
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			Expect(instanceTypes).ToNot(BeEmpty())
+			for _, it := range instanceTypes {
+				generation, err := strconv.Atoi(it.Requirements.Get(v1alpha1.LabelInstanceGeneration).Any())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(generation).To(BeNumerically(">=", 5))
+			}
+		})
+		It("should launch instance types newer than a generation with the Gt operator", func() {
+			provisioner.Spec.Requirements = append(provisioner.Spec.Requirements, v1.NodeSelectorRequirement{
+				Key:      v1alpha1.LabelInstanceGeneration,
+				Operator: v1.NodeSelectorOpGt,
+				Values:   []string{"4"},
+			})
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(1))
+			call := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			for _, ltc := range call.LaunchTemplateConfigs {
+				for _, override := range ltc.Overrides {
+					Expect(aws.StringValue(override.InstanceType)).To(BeElementOf("c6g.large", "m5.large", "m5.metal", "m5.xlarge", "m6idn.32xlarge"))
+				}
+			}
+		})
+		It("should not launch instance types in aws.excludedInstanceTypes", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{ExcludedInstanceTypes: []string{"*.metal"}}))
+			// add a provisioner requirement for instance type exists to remove our default filter for metal sizes
+			provisioner.Spec.Requirements = append(provisioner.Spec.Requirements, v1.NodeSelectorRequirement{
+				Key:      v1.LabelInstanceTypeStable,
+				Operator: v1.NodeSelectorOpExists,
+			})
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod(coretest.PodOptions{
+				NodeSelector: map[string]string{
+					v1alpha1.LabelInstanceSize: "metal",
+				},
+			})
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectNotScheduled(ctx, env.Client, pod)
+		})
+	})
+
+	Context("Instance Requirements", func() {
+		It("should only list instance types that match the NodeClass's instance requirements", func() {
+			nodeTemplate.Spec.InstanceRequirements = &v1alpha1.InstanceRequirements{
+				VCPU:                  &v1alpha1.InstanceRequirementsRange{Min: aws.Int64(4), Max: aws.Int64(16)},
+				ExcludedInstanceTypes: []string{"m5.*"},
+			}
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			Expect(instanceTypes).ToNot(BeEmpty())
+			for _, it := range instanceTypes {
+				Expect(it.Name).ToNot(HavePrefix("m5."))
+				Expect(it.Capacity.Cpu().Value()).To(And(BeNumerically(">=", 4), BeNumerically("<=", 16)))
+			}
+		})
+		It("should only list instance types with the accelerators of the NodeClass's instance requirements", func() {
+			nodeTemplate.Spec.InstanceRequirements = &v1alpha1.InstanceRequirements{
+				AcceleratorTypes:         []string{v1alpha1.AcceleratorTypeGPU},
+				AcceleratorManufacturers: []string{"nvidia"},
+			}
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			Expect(instanceTypes).ToNot(BeEmpty())
+			for _, it := range instanceTypes {
+				Expect(it.Requirements.Get(v1alpha1.LabelInstanceGPUManufacturer).Any()).To(Equal("nvidia"))
+			}
+		})
+	})
+
+	Context("Encryption in Transit", func() {
+		It("should only list instance types that support encryption in transit when the NodeClass requires it", func() {
+			nodeTemplate.Spec.RequireEncryptionInTransit = aws.Bool(true)
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			Expect(instanceTypes).ToNot(BeEmpty())
+			for _, it := range instanceTypes {
+				Expect(it.Name).ToNot(HavePrefix("m5."))
+				Expect(it.Requirements.Get(v1alpha1.LabelInstanceEncryptionInTransitSupported).Any()).To(Equal("true"))
+			}
+		})
+	})
+
+	Context("Instance Type Catalog", func() {
+		It("should keep serving the catalog until it's updated", func() {
+			instanceInfo, err := awsEnv.InstanceTypesProvider.GetInstanceTypes(ctx)
+			Expect(err).To(BeNil())
+			count := len(instanceInfo)
+			awsEnv.EC2API.DescribeInstanceTypesOutput.Set(&ec2.DescribeInstanceTypesOutput{
+				InstanceTypes: []*ec2.InstanceTypeInfo{instanceInfo[0]},
+			})
+			instanceInfo, err = awsEnv.InstanceTypesProvider.GetInstanceTypes(ctx)
+			Expect(err).To(BeNil())
+			Expect(instanceInfo).To(HaveLen(count))
+
+			Expect(awsEnv.InstanceTypesProvider.UpdateInstanceTypes(ctx)).To(Succeed())
+			instanceInfo, err = awsEnv.InstanceTypesProvider.GetInstanceTypes(ctx)
+			Expect(err).To(BeNil())
+			Expect(instanceInfo).To(HaveLen(1))
+		})
+		It("should keep the catalog if the update fails", func() {
+			instanceInfo, err := awsEnv.InstanceTypesProvider.GetInstanceTypes(ctx)
+			Expect(err).To(BeNil())
+			count := len(instanceInfo)
+			awsEnv.EC2API.NextError.Set(fmt.Errorf("failed"))
+			Expect(awsEnv.InstanceTypesProvider.UpdateInstanceTypes(ctx)).ToNot(Succeed())
+			instanceInfo, err = awsEnv.InstanceTypesProvider.GetInstanceTypes(ctx)
+			Expect(err).To(BeNil())
+			Expect(instanceInfo).To(HaveLen(count))
+		})
+		It("should keep the catalog if no instance types are returned", func() {
+			instanceInfo, err := awsEnv.InstanceTypesProvider.GetInstanceTypes(ctx)
+			Expect(err).To(BeNil())
+			count := len(instanceInfo)
+			awsEnv.EC2API.DescribeInstanceTypesOutput.Set(&ec2.DescribeInstanceTypesOutput{})
+			Expect(awsEnv.InstanceTypesProvider.UpdateInstanceTypes(ctx)).ToNot(Succeed())
+			instanceInfo, err = awsEnv.InstanceTypesProvider.GetInstanceTypes(ctx)
+			Expect(err).To(BeNil())
+			Expect(instanceInfo).To(HaveLen(count))
+		})
+		It("should drop duplicate instance types across pages", func() {
+			instanceInfo, err := awsEnv.InstanceTypesProvider.GetInstanceTypes(ctx)
+			Expect(err).To(BeNil())
+			awsEnv.EC2API.DescribeInstanceTypesOutput.Set(&ec2.DescribeInstanceTypesOutput{
+				InstanceTypes: []*ec2.InstanceTypeInfo{instanceInfo[0], instanceInfo[1], instanceInfo[0]},
+			})
+			Expect(awsEnv.InstanceTypesProvider.UpdateInstanceTypes(ctx)).To(Succeed())
+			instanceInfo, err = awsEnv.InstanceTypesProvider.GetInstanceTypes(ctx)
+			Expect(err).To(BeNil())
+			Expect(instanceInfo).To(HaveLen(2))
+		})
+	})
+
+	Context("Instance Type Offerings", func() {
+		offering := func(instanceType, zoneID string) *ec2.InstanceTypeOffering {
+			return &ec2.InstanceTypeOffering{InstanceType: aws.String(instanceType), Location: aws.String(zoneID)}
+		}
+		BeforeEach(func() {
+			awsEnv.EC2API.DescribeInstanceTypeOfferingsOutput.Set(&ec2.DescribeInstanceTypeOfferingsOutput{
+				InstanceTypeOfferings: []*ec2.InstanceTypeOffering{
+					offering("m5.large", "testzone1a"),
+					offering("m5.large", "testzone1b"),
+					offering("m5.xlarge", "testzone1a"),
+				},
+			})
+		})
+		It("should discover offerings by zone ID and translate them to the zone names of the subnets", func() {
+			awsEnv.EC2API.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
+				// zone names are mapped to different zone IDs in each account
+				{SubnetId: aws.String("subnet-test1"), AvailabilityZone: aws.String("test-zone-1b"), AvailabilityZoneId: aws.String("testzone1a"), AvailableIpAddressCount: aws.Int64(100)},
+			}})
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			Expect(lo.Map(instanceTypes, func(it *corecloudprovider.InstanceType, _ int) string { return it.Name })).To(ConsistOf("m5.large", "m5.xlarge"))
+			for _, it := range instanceTypes {
+				for _, o := range it.Offerings {
+					Expect(o.Zone).To(Equal("test-zone-1b"))
+				}
+			}
+		})
+		It("should add the IDs of the zones of the offerings to the requirements", func() {
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			it, ok := lo.Find(instanceTypes, func(it *corecloudprovider.InstanceType) bool { return it.Name == "m5.large" })
+			Expect(ok).To(BeTrue())
+			Expect(it.Requirements.Get(v1alpha1.LabelTopologyZoneID).Values()).To(ConsistOf("testzone1a", "testzone1b"))
+			it, ok = lo.Find(instanceTypes, func(it *corecloudprovider.InstanceType) bool { return it.Name == "m5.xlarge" })
+			Expect(ok).To(BeTrue())
+			Expect(it.Requirements.Get(v1alpha1.LabelTopologyZoneID).Values()).To(ConsistOf("testzone1a"))
+		})
+		It("should not report changes when the offerings are first discovered", func() {
+			changes, err := awsEnv.InstanceTypesProvider.UpdateInstanceTypeOfferings(ctx)
+			Expect(err).To(BeNil())
+			Expect(changes).To(BeEmpty())
+		})
+		It("should report the offerings that appeared in and disappeared from each zone", func() {
+			_, err := awsEnv.InstanceTypesProvider.UpdateInstanceTypeOfferings(ctx)
+			Expect(err).To(BeNil())
+			awsEnv.EC2API.DescribeInstanceTypeOfferingsOutput.Set(&ec2.DescribeInstanceTypeOfferingsOutput{
+				InstanceTypeOfferings: []*ec2.InstanceTypeOffering{
+					offering("m5.large", "testzone1a"),
+					offering("m5.xlarge", "testzone1b"),
+				},
+			})
+			changes, err := awsEnv.InstanceTypesProvider.UpdateInstanceTypeOfferings(ctx)
+			Expect(err).To(BeNil())
+			Expect(changes).To(HaveLen(2))
+			Expect(changes[0].ZoneID).To(Equal("testzone1a"))
+			Expect(changes[0].Added).To(BeEmpty())
+			Expect(changes[0].Removed).To(ConsistOf("m5.xlarge"))
+			Expect(changes[1].ZoneID).To(Equal("testzone1b"))
+			Expect(changes[1].Added).To(ConsistOf("m5.xlarge"))
+			Expect(changes[1].Removed).To(ConsistOf("m5.large"))
+		})
+		It("should keep the offerings if no offerings are returned", func() {
+			_, err := awsEnv.InstanceTypesProvider.UpdateInstanceTypeOfferings(ctx)
+			Expect(err).To(BeNil())
+			awsEnv.EC2API.DescribeInstanceTypeOfferingsOutput.Set(&ec2.DescribeInstanceTypeOfferingsOutput{})
+			_, err = awsEnv.InstanceTypesProvider.UpdateInstanceTypeOfferings(ctx)
+			Expect(err).ToNot(BeNil())
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			Expect(lo.Map(instanceTypes, func(it *corecloudprovider.InstanceType, _ int) string { return it.Name })).To(ConsistOf("m5.large", "m5.xlarge"))
+		})
+		It("should publish events on the node templates with subnets in zones whose offerings changed", func() {
+			recorder := coretest.NewEventRecorder()
+			controller := instancetype.NewController(env.Client, recorder, awsEnv.InstanceTypesProvider)
+			nodeTemplate.Status.Subnets = []v1alpha1.Subnet{{ID: "subnet-test1", Zone: "test-zone-1a", ZoneID: "testzone1a"}}
+			otherNodeTemplate := test.AWSNodeTemplate()
+			otherNodeTemplate.Status.Subnets = []v1alpha1.Subnet{{ID: "subnet-test3", Zone: "test-zone-1c", ZoneID: "testzone1c"}}
+			ExpectApplied(ctx, env.Client, nodeTemplate, otherNodeTemplate)
+
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+			Expect(recorder.Events()).To(BeEmpty())
+			awsEnv.EC2API.DescribeInstanceTypeOfferingsOutput.Set(&ec2.DescribeInstanceTypeOfferingsOutput{
+				InstanceTypeOfferings: []*ec2.InstanceTypeOffering{
+					offering("m5.large", "testzone1a"),
+					offering("m5.large", "testzone1b"),
+					offering("m5.2xlarge", "testzone1a"),
+				},
+			})
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKey{})
+			Expect(recorder.Calls("InstanceTypeOfferingsAdded")).To(Equal(1))
+			Expect(recorder.Calls("InstanceTypeOfferingsRemoved")).To(Equal(1))
+			recorder.ForEachEvent(func(evt events.Event) {
+				Expect(evt.InvolvedObject.(*v1alpha1.AWSNodeTemplate).Name).To(Equal(nodeTemplate.Name))
+			})
+		})
+	})
+
+	Context("CPU Credit Specification", func() {
+		It("should add the unlimited mode surcharge to the price of burstable instance types", func() {
+			nodeTemplate.Spec.CPUCreditSpecification = lo.ToPtr(v1alpha1.CPUCreditSpecificationUnlimited)
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			for _, name := range []string{"t3.large", "m5.large"} {
+				it, ok := lo.Find(instanceTypes, func(it *corecloudprovider.InstanceType) bool { return it.Name == name })
+				Expect(ok).To(BeTrue())
+				odPrice, ok := awsEnv.PricingProvider.OnDemandPrice(name)
+				Expect(ok).To(BeTrue())
+				// t3.large has 2 vCPUs, with a baseline utilization of 30% each
+				surcharge := lo.Ternary(name == "t3.large", 2*0.7*instancetype.UnlimitedCPUCreditPricePerVCPUHour, 0)
+				for _, offering := range it.Offerings {
+					if offering.CapacityType == v1alpha5.CapacityTypeOnDemand {
+						Expect(offering.Price).To(BeNumerically("~", odPrice+surcharge))
+					}
+				}
+			}
+		})
+		It("should not add a surcharge to the price of burstable instance types in standard mode", func() {
+			nodeTemplate.Spec.CPUCreditSpecification = lo.ToPtr(v1alpha1.CPUCreditSpecificationStandard)
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			it, ok := lo.Find(instanceTypes, func(it *corecloudprovider.InstanceType) bool { return it.Name == "t3.large" })
+			Expect(ok).To(BeTrue())
+			odPrice, ok := awsEnv.PricingProvider.OnDemandPrice("t3.large")
+			Expect(ok).To(BeTrue())
+			for _, offering := range it.Offerings {
+				if offering.CapacityType == v1alpha5.CapacityTypeOnDemand {
+					Expect(offering.Price).To(Equal(odPrice))
+				}
+			}
+		})
+	})
+
+	Context("Accelerators", func() {
+		It("should count the NVIDIA L4 GPUs of g6 and gr6 instance types that don't report their GPUs", func() {
+			instances := makeFakeInstances()[:2]
+			instances[0].InstanceType = aws.String("g6.12xlarge")
+			instances[1].InstanceType = aws.String("gr6.4xlarge")
+			awsEnv.EC2API.DescribeInstanceTypesOutput.Set(&ec2.DescribeInstanceTypesOutput{InstanceTypes: instances})
+			awsEnv.EC2API.DescribeInstanceTypeOfferingsOutput.Set(&ec2.DescribeInstanceTypeOfferingsOutput{InstanceTypeOfferings: makeFakeInstanceOfferings(instances)})
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			Expect(instanceTypes).To(HaveLen(2))
+			for _, it := range instanceTypes {
+				count := lo.Ternary(it.Name == "g6.12xlarge", "4", "1")
+				Expect(it.Capacity).To(HaveKeyWithValue(v1alpha1.ResourceNVIDIAGPU, resource.MustParse(count)))
+				Expect(it.Requirements.Get(v1alpha1.LabelInstanceGPUName).Values()).To(ConsistOf("l4"))
+				Expect(it.Requirements.Get(v1alpha1.LabelInstanceGPUManufacturer).Values()).To(ConsistOf("nvidia"))
+				Expect(it.Requirements.Get(v1alpha1.LabelInstanceGPUCount).Values()).To(ConsistOf(count))
+			}
+		})
+		It("should not count inference accelerators that aren't AWS Inferentia", func() {
+			instances := makeFakeInstances()[:1]
+			instances[0].InferenceAcceleratorInfo = &ec2.InferenceAcceleratorInfo{Accelerators: []*ec2.InferenceDeviceInfo{
+				{Name: aws.String("eia2.medium"), Manufacturer: aws.String("Elastic Inference"), Count: aws.Int64(1)},
+			}}
+			awsEnv.EC2API.DescribeInstanceTypesOutput.Set(&ec2.DescribeInstanceTypesOutput{InstanceTypes: instances})
+			awsEnv.EC2API.DescribeInstanceTypeOfferingsOutput.Set(&ec2.DescribeInstanceTypeOfferingsOutput{InstanceTypeOfferings: makeFakeInstanceOfferings(instances)})
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			Expect(instanceTypes).To(HaveLen(1))
+			Expect(instanceTypes[0].Capacity).To(HaveKeyWithValue(v1alpha1.ResourceAWSNeuron, resource.MustParse("0")))
+			Expect(instanceTypes[0].Requirements.Get(v1alpha1.LabelInstanceAcceleratorName).Operator()).To(Equal(v1.NodeSelectorOpDoesNotExist))
+		})
+	})
+
+	Context("Enclave and CPU Options", func() {
+		It("should only list instance types that support Nitro Enclaves when they're enabled", func() {
+			nodeTemplate.Spec.EnclaveOptions = &v1alpha1.EnclaveOptions{Enabled: aws.Bool(true)}
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			Expect(lo.Map(instanceTypes, func(it *corecloudprovider.InstanceType, _ int) string { return it.Name })).To(ConsistOf("m5.xlarge", "m6idn.32xlarge"))
+		})
+		It("should list all instance types when Nitro Enclaves are disabled", func() {
+			nodeTemplate.Spec.EnclaveOptions = &v1alpha1.EnclaveOptions{Enabled: aws.Bool(false)}
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			Expect(lo.Map(instanceTypes, func(it *corecloudprovider.InstanceType, _ int) string { return it.Name })).To(ContainElements("m5.large", "t3.large"))
+		})
+		It("should only list instance types that support AMD SEV-SNP when it's enabled", func() {
+			instances := makeFakeInstances()
+			instances[0].ProcessorInfo.SupportedFeatures = aws.StringSlice([]string{ec2.SupportedAdditionalProcessorFeatureAmdSevSnp})
+			awsEnv.EC2API.DescribeInstanceTypesOutput.Set(&ec2.DescribeInstanceTypesOutput{InstanceTypes: instances})
+			awsEnv.EC2API.DescribeInstanceTypeOfferingsOutput.Set(&ec2.DescribeInstanceTypeOfferingsOutput{InstanceTypeOfferings: makeFakeInstanceOfferings(instances)})
+			nodeTemplate.Spec.CPUOptions = &v1alpha1.CPUOptions{AMDSEVSNP: aws.String(v1alpha1.AMDSEVSNPEnabled)}
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			Expect(lo.Map(instanceTypes, func(it *corecloudprovider.InstanceType, _ int) string { return it.Name })).To(ConsistOf(aws.StringValue(instances[0].InstanceType)))
+		})
+		It("should not cache instance types across enclave options", func() {
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			nodeTemplate.Spec.EnclaveOptions = &v1alpha1.EnclaveOptions{Enabled: aws.Bool(true)}
+			enclaveInstanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			Expect(len(enclaveInstanceTypes)).To(BeNumerically("<", len(instanceTypes)))
+		})
+	})
+
+	Context("Spot Interruption Rates", func() {
+		It("should label instance types with the interruption frequency bucket of their spot pools", func() {
+			awsEnv.SpotAdvisorAPI.Data.Set(fake.NewSpotAdvisorData("", map[string]int{"m5.large": 0, "m5.xlarge": 4}))
+			Expect(awsEnv.SpotAdvisorProvider.Update(ctx)).To(Succeed())
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			buckets := lo.SliceToMap(lo.Filter(instanceTypes, func(it *corecloudprovider.InstanceType, _ int) bool {
+				return it.Requirements.Get(v1alpha1.LabelSpotInterruptionRateBucket).Len() > 0
+			}), func(it *corecloudprovider.InstanceType) (string, []string) {
+				return it.Name, it.Requirements.Get(v1alpha1.LabelSpotInterruptionRateBucket).Values()
+			})
+			Expect(buckets).To(Equal(map[string][]string{"m5.large": {"0"}, "m5.xlarge": {"4"}}))
+		})
+		It("should not label instance types before the interruption rates are known", func() {
+			awsEnv.SpotAdvisorAPI.NextError.Set(fmt.Errorf("failed"))
+			Expect(awsEnv.SpotAdvisorProvider.Update(ctx)).ToNot(Succeed())
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			for _, it := range instanceTypes {
+				Expect(it.Requirements.Get(v1alpha1.LabelSpotInterruptionRateBucket).Operator()).To(Equal(v1.NodeSelectorOpDoesNotExist))
+			}
+		})
+		It("should relabel instance types when the interruption rates change", func() {
+			awsEnv.SpotAdvisorAPI.Data.Set(fake.NewSpotAdvisorData("", map[string]int{"m5.large": 0}))
+			Expect(awsEnv.SpotAdvisorProvider.Update(ctx)).To(Succeed())
+			_, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+
+			awsEnv.SpotAdvisorAPI.Data.Set(fake.NewSpotAdvisorData("", map[string]int{"m5.large": 3}))
+			Expect(awsEnv.SpotAdvisorProvider.Update(ctx)).To(Succeed())
+			instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			instanceType, ok := lo.Find(instanceTypes, func(it *corecloudprovider.InstanceType) bool { return it.Name == "m5.large" })
+			Expect(ok).To(BeTrue())
+			Expect(instanceType.Requirements.Get(v1alpha1.LabelSpotInterruptionRateBucket).Values()).To(ConsistOf("3"))
+		})
+		It("should exclude frequently interrupted instance types from pods that require infrequently interrupted ones", func() {
+			awsEnv.SpotAdvisorAPI.Data.Set(fake.NewSpotAdvisorData("", map[string]int{"m5.large": 4, "m5.xlarge": 1}))
+			Expect(awsEnv.SpotAdvisorProvider.Update(ctx)).To(Succeed())
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod(coretest.PodOptions{
+				NodeRequirements: []v1.NodeSelectorRequirement{{
+					Key:      v1alpha1.LabelSpotInterruptionRateBucket,
+					Operator: v1.NodeSelectorOpLt,
+					Values:   []string{"2"},
+				}},
+			})
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			node := ExpectScheduled(ctx, env.Client, pod)
+			Expect(node.Labels).To(HaveKeyWithValue(v1.LabelInstanceTypeStable, "m5.xlarge"))
+			Expect(node.Labels).To(HaveKeyWithValue(v1alpha1.LabelSpotInterruptionRateBucket, "1"))
+		})
+	})
+
+	Context("Consolidation Price Thresholds", func() {
+		It("should price offerings with their actual price when consolidation price thresholds are set", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+				ConsolidationPriceThreshold:        lo.ToPtr(0.5),
+				ConsolidationPriceThresholdPercent: lo.ToPtr(0.1),
+			}))
+			instanceInfo, err := awsEnv.InstanceTypesProvider.List(ctx, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), nodeclassutil.New(nodeTemplate))
+			Expect(err).To(BeNil())
+			for _, info := range instanceInfo {
+				odPrice, ok := awsEnv.PricingProvider.OnDemandPrice(info.Name)
+				Expect(ok).To(BeTrue())
+				for _, offering := range info.Offerings {
+					if offering.CapacityType == v1alpha5.CapacityTypeOnDemand {
+						Expect(offering.Price).To(Equal(odPrice))
+					}
+				}
+			}
+		})
+	})
+
 	Context("Overhead", func() {
 		var info *ec2.InstanceTypeInfo
 		BeforeEach(func() {
@@ -752,7 +1197,7 @@ var _ = Describe("Instance Types", func() {
 		})
 		Context("System Reserved Resources", func() {
 			It("should use defaults when no kubelet is specified", func() {
-				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Overhead.SystemReserved.Cpu().String()).To(Equal("0"))
 				Expect(it.Overhead.SystemReserved.Memory().String()).To(Equal("0"))
 				Expect(it.Overhead.SystemReserved.StorageEphemeral().String()).To(Equal("0"))
@@ -767,7 +1212,7 @@ var _ = Describe("Instance Types", func() {
 						},
 					},
 				})
-				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Overhead.SystemReserved.Cpu().String()).To(Equal("2"))
 				Expect(it.Overhead.SystemReserved.Memory().String()).To(Equal("20Gi"))
 				Expect(it.Overhead.SystemReserved.StorageEphemeral().String()).To(Equal("10Gi"))
@@ -775,7 +1220,7 @@ var _ = Describe("Instance Types", func() {
 		})
 		Context("Kube Reserved Resources", func() {
 			It("should use defaults when no kubelet is specified", func() {
-				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Overhead.KubeReserved.Cpu().String()).To(Equal("80m"))
 				Expect(it.Overhead.KubeReserved.Memory().String()).To(Equal("893Mi"))
 				Expect(it.Overhead.KubeReserved.StorageEphemeral().String()).To(Equal("1Gi"))
@@ -792,12 +1237,70 @@ var _ = Describe("Instance Types", func() {
 						v1.ResourceMemory:           resource.MustParse("10Gi"),
 						v1.ResourceEphemeralStorage: resource.MustParse("2Gi"),
 					},
-				}), "", nodeclassutil.New(nodeTemplate), nil)
+				}), "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Overhead.KubeReserved.Cpu().String()).To(Equal("2"))
 				Expect(it.Overhead.KubeReserved.Memory().String()).To(Equal("10Gi"))
 				Expect(it.Overhead.KubeReserved.StorageEphemeral().String()).To(Equal("2Gi"))
 			})
 		})
+		Context("VM Memory Overhead", func() {
+			It("should use vmMemoryOverheadPercent when no instance type override matches", func() {
+				ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+					VMMemoryOverheadPercentPerInstanceType: map[string]float64{"c5.*": 0.05},
+				}))
+				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil, nil)
+				// 16384Mi - ceil(16384Mi * 0.075)
+				Expect(it.Capacity.Memory().String()).To(Equal("15155Mi"))
+			})
+			It("should use the longest matching vmMemoryOverheadPercentPerInstanceType glob", func() {
+				ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+					VMMemoryOverheadPercentPerInstanceType: map[string]float64{"m5.*": 0.05, "m5.xlarge": 0.1, "*": 0.2},
+				}))
+				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil, nil)
+				// 16384Mi - ceil(16384Mi * 0.1)
+				Expect(it.Capacity.Memory().String()).To(Equal("14745Mi"))
+			})
+			It("should use the observed memory capacity in place of the VM memory overhead", func() {
+				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil, lo.ToPtr(resource.MustParse("15800Mi")))
+				Expect(it.Capacity.Memory().String()).To(Equal("15800Mi"))
+			})
+			It("should use the observed memory capacity from nodes when learning is enabled", func() {
+				awsEnv.ObservedMemoryCapacities.Observe(ctx, "m5.xlarge", v1alpha1.AMIFamilyAL2, resource.MustParse("15800Mi"))
+				for _, enabled := range []bool{false, true} {
+					ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+						EnableVMMemoryOverheadLearning: lo.ToPtr(enabled),
+					}))
+					instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, &v1beta1.KubeletConfiguration{}, nodeclassutil.New(nodeTemplate))
+					Expect(err).To(BeNil())
+					it, ok := lo.Find(instanceTypes, func(it *corecloudprovider.InstanceType) bool { return it.Name == "m5.xlarge" })
+					Expect(ok).To(BeTrue())
+					Expect(it.Capacity.Memory().String()).To(Equal(lo.Ternary(enabled, "15800Mi", "15155Mi")))
+				}
+			})
+			It("should only use the memory capacity observed on nodes of the same AMI family", func() {
+				ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{EnableVMMemoryOverheadLearning: lo.ToPtr(true)}))
+				awsEnv.ObservedMemoryCapacities.Observe(ctx, "m5.xlarge", v1alpha1.AMIFamilyBottlerocket, resource.MustParse("15800Mi"))
+				instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, &v1beta1.KubeletConfiguration{}, nodeclassutil.New(nodeTemplate))
+				Expect(err).To(BeNil())
+				it, ok := lo.Find(instanceTypes, func(it *corecloudprovider.InstanceType) bool { return it.Name == "m5.xlarge" })
+				Expect(ok).To(BeTrue())
+				Expect(it.Capacity.Memory().String()).To(Equal("15155Mi"))
+			})
+			It("should estimate the memory of the instance types of a family from the VM memory overhead observed on its other sizes", func() {
+				ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{EnableVMMemoryOverheadLearning: lo.ToPtr(true)}))
+				// 16384Mi - 15801Mi is an overhead of 583Mi, which is 291.5Mi of the 8192Mi of an m5.large
+				awsEnv.ObservedMemoryCapacities.Observe(ctx, "m5.xlarge", v1alpha1.AMIFamilyAL2, resource.MustParse("15801Mi"))
+				instanceTypes, err := awsEnv.InstanceTypesProvider.List(ctx, &v1beta1.KubeletConfiguration{}, nodeclassutil.New(nodeTemplate))
+				Expect(err).To(BeNil())
+				it, ok := lo.Find(instanceTypes, func(it *corecloudprovider.InstanceType) bool { return it.Name == "m5.large" })
+				Expect(ok).To(BeTrue())
+				Expect(it.Capacity.Memory().String()).To(Equal("7900Mi"))
+				// Other families use the VM memory overhead
+				it, ok = lo.Find(instanceTypes, func(it *corecloudprovider.InstanceType) bool { return it.Name == "t3.large" })
+				Expect(ok).To(BeTrue())
+				Expect(it.Capacity.Memory().String()).To(Equal("7577Mi"))
+			})
+		})
 		Context("Eviction Thresholds", func() {
 			BeforeEach(func() {
 				ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
@@ -819,7 +1322,7 @@ var _ = Describe("Instance Types", func() {
 							},
 						},
 					})
-					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 					Expect(it.Overhead.EvictionThreshold.Memory().String()).To(Equal("500Mi"))
 				})
 				It("should override eviction threshold when specified as a percentage value", func() {
@@ -836,7 +1339,7 @@ var _ = Describe("Instance Types", func() {
 							},
 						},
 					})
-					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 					Expect(it.Overhead.EvictionThreshold.Memory().Value()).To(BeNumerically("~", float64(it.Capacity.Memory().Value())*0.1, 10))
 				})
 				It("should consider the eviction threshold disabled when specified as 100%", func() {
@@ -853,7 +1356,7 @@ var _ = Describe("Instance Types", func() {
 							},
 						},
 					})
-					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 					Expect(it.Overhead.EvictionThreshold.Memory().String()).To(Equal("0"))
 				})
 				It("should used default eviction threshold for memory when evictionHard not specified", func() {
@@ -870,7 +1373,7 @@ var _ = Describe("Instance Types", func() {
 							},
 						},
 					})
-					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 					Expect(it.Overhead.EvictionThreshold.Memory().String()).To(Equal("50Mi"))
 				})
 			})
@@ -889,7 +1392,7 @@ var _ = Describe("Instance Types", func() {
 							},
 						},
 					})
-					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 					Expect(it.Overhead.EvictionThreshold.Memory().String()).To(Equal("500Mi"))
 				})
 				It("should override eviction threshold when specified as a percentage value", func() {
@@ -909,7 +1412,7 @@ var _ = Describe("Instance Types", func() {
 							},
 						},
 					})
-					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 					Expect(it.Overhead.EvictionThreshold.Memory().Value()).To(BeNumerically("~", float64(it.Capacity.Memory().Value())*0.1, 10))
 				})
 				It("should consider the eviction threshold disabled when specified as 100%", func() {
@@ -926,7 +1429,7 @@ var _ = Describe("Instance Types", func() {
 							},
 						},
 					})
-					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 					Expect(it.Overhead.EvictionThreshold.Memory().String()).To(Equal("0"))
 				})
 				It("should ignore eviction threshold when using Bottlerocket AMI", func() {
@@ -947,12 +1450,12 @@ var _ = Describe("Instance Types", func() {
 							},
 						},
 					})
-					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 					Expect(it.Overhead.EvictionThreshold.Memory().String()).To(Equal("1Gi"))
 				})
 			})
 			It("should take the default eviction threshold when none is specified", func() {
-				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, &v1beta1.KubeletConfiguration{}, "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Overhead.EvictionThreshold.Cpu().String()).To(Equal("0"))
 				Expect(it.Overhead.EvictionThreshold.Memory().String()).To(Equal("100Mi"))
 				Expect(it.Overhead.EvictionThreshold.StorageEphemeral().AsApproximateFloat64()).To(BeNumerically("~", resources.Quantity("2Gi").AsApproximateFloat64()))
@@ -974,7 +1477,7 @@ var _ = Describe("Instance Types", func() {
 						},
 					},
 				})
-				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Overhead.EvictionThreshold.Memory().String()).To(Equal("3Gi"))
 			})
 			It("should take the greater of evictionHard and evictionSoft for overhead as a value", func() {
@@ -994,7 +1497,7 @@ var _ = Describe("Instance Types", func() {
 						},
 					},
 				})
-				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Overhead.EvictionThreshold.Memory().Value()).To(BeNumerically("~", float64(it.Capacity.Memory().Value())*0.05, 10))
 			})
 			It("should take the greater of evictionHard and evictionSoft for overhead with mixed percentage/value", func() {
@@ -1014,7 +1517,7 @@ var _ = Describe("Instance Types", func() {
 						},
 					},
 				})
-				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Overhead.EvictionThreshold.Memory().Value()).To(BeNumerically("~", float64(it.Capacity.Memory().Value())*0.1, 10))
 			})
 		})
@@ -1024,11 +1527,11 @@ var _ = Describe("Instance Types", func() {
 			provisioner = test.Provisioner(coretest.ProvisionerOptions{})
 			for _, info := range instanceInfo {
 				if *info.InstanceType == "t3.large" {
-					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 					Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", 35))
 				}
 				if *info.InstanceType == "m6idn.32xlarge" {
-					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+					it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 					Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", 345))
 				}
 			}
@@ -1038,7 +1541,7 @@ var _ = Describe("Instance Types", func() {
 			Expect(err).To(BeNil())
 			provisioner = test.Provisioner(coretest.ProvisionerOptions{Kubelet: &v1alpha5.KubeletConfiguration{MaxPods: ptr.Int32(10)}})
 			for _, info := range instanceInfo {
-				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", 10))
 			}
 		})
@@ -1051,7 +1554,7 @@ var _ = Describe("Instance Types", func() {
 			Expect(err).To(BeNil())
 			provisioner = test.Provisioner(coretest.ProvisionerOptions{Kubelet: &v1alpha5.KubeletConfiguration{MaxPods: ptr.Int32(10)}})
 			for _, info := range instanceInfo {
-				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", 10))
 			}
 		})
@@ -1066,7 +1569,7 @@ var _ = Describe("Instance Types", func() {
 				return *info.InstanceType == "t3.large"
 			})
 			Expect(ok).To(Equal(true))
-			it := instancetype.NewInstanceType(ctx, t3Large, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+			it := instancetype.NewInstanceType(ctx, t3Large, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 			// t3.large
 			// maxInterfaces = 3
 			// maxIPv4PerInterface = 12
@@ -1086,7 +1589,7 @@ var _ = Describe("Instance Types", func() {
 				return *info.InstanceType == "t3.large"
 			})
 			Expect(ok).To(Equal(true))
-			it := instancetype.NewInstanceType(ctx, t3Large, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+			it := instancetype.NewInstanceType(ctx, t3Large, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 			// t3.large
 			// maxInterfaces = 3
 			// maxIPv4PerInterface = 12
@@ -1101,7 +1604,7 @@ var _ = Describe("Instance Types", func() {
 			Expect(err).To(BeNil())
 			provisioner = test.Provisioner(coretest.ProvisionerOptions{Kubelet: &v1alpha5.KubeletConfiguration{PodsPerCore: ptr.Int32(1)}})
 			for _, info := range instanceInfo {
-				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", ptr.Int64Value(info.VCpuInfo.DefaultVCpus)))
 			}
 		})
@@ -1110,7 +1613,7 @@ var _ = Describe("Instance Types", func() {
 			Expect(err).To(BeNil())
 			provisioner = test.Provisioner(coretest.ProvisionerOptions{Kubelet: &v1alpha5.KubeletConfiguration{PodsPerCore: ptr.Int32(4), MaxPods: ptr.Int32(20)}})
 			for _, info := range instanceInfo {
-				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", lo.Min([]int64{20, ptr.Int64Value(info.VCpuInfo.DefaultVCpus) * 4})))
 			}
 		})
@@ -1120,11 +1623,38 @@ var _ = Describe("Instance Types", func() {
 			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
 			provisioner = test.Provisioner(coretest.ProvisionerOptions{Kubelet: &v1alpha5.KubeletConfiguration{PodsPerCore: ptr.Int32(1)}})
 			for _, info := range instanceInfo {
-				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 				limitedPods := instancetype.ENILimitedPods(ctx, info)
 				Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", limitedPods.Value()))
 			}
 		})
+		It("should override max-pods with the longest matching maxPodsPerInstanceType glob", func() {
+			instanceInfo, err := awsEnv.InstanceTypesProvider.GetInstanceTypes(ctx)
+			Expect(err).To(BeNil())
+			nodeTemplate.Spec.MaxPodsPerInstanceType = map[string]int32{"*.large": 50, "t3.large": 60}
+			provisioner = test.Provisioner(coretest.ProvisionerOptions{Kubelet: &v1alpha5.KubeletConfiguration{MaxPods: ptr.Int32(10)}})
+			for _, info := range instanceInfo {
+				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
+				switch {
+				case *info.InstanceType == "t3.large":
+					Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", 60))
+				case strings.HasSuffix(*info.InstanceType, ".large"):
+					Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", 50))
+				default:
+					Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", 10))
+				}
+			}
+		})
+		It("should take the minimum of pods-per-core and the maxPodsPerInstanceType override", func() {
+			instanceInfo, err := awsEnv.InstanceTypesProvider.GetInstanceTypes(ctx)
+			Expect(err).To(BeNil())
+			nodeTemplate.Spec.MaxPodsPerInstanceType = map[string]int32{"*": 200}
+			provisioner = test.Provisioner(coretest.ProvisionerOptions{Kubelet: &v1alpha5.KubeletConfiguration{PodsPerCore: ptr.Int32(4)}})
+			for _, info := range instanceInfo {
+				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
+				Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", lo.Min([]int64{200, ptr.Int64Value(info.VCpuInfo.DefaultVCpus) * 4})))
+			}
+		})
 		It("should take 110 to be the default pods number when pods-per-core is 0 and AWSENILimitedPodDensity is unset", func() {
 			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
 				EnableENILimitedPodDensity: lo.ToPtr(false),
@@ -1134,7 +1664,7 @@ var _ = Describe("Instance Types", func() {
 			Expect(err).To(BeNil())
 			provisioner = test.Provisioner(coretest.ProvisionerOptions{Kubelet: &v1alpha5.KubeletConfiguration{PodsPerCore: ptr.Int32(0)}})
 			for _, info := range instanceInfo {
-				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+				it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 				Expect(it.Capacity.Pods().Value()).To(BeNumerically("==", 110))
 			}
 		})
@@ -1142,8 +1672,9 @@ var _ = Describe("Instance Types", func() {
 			awsEnv.EC2API.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{
 				Subnets: []*ec2.Subnet{
 					{
-						AvailabilityZone: aws.String("us-west-2a"),
-						SubnetId:         aws.String("subnet-12345"),
+						AvailabilityZone:   aws.String("us-west-2a"),
+						AvailabilityZoneId: aws.String("usw2-az1"),
+						SubnetId:           aws.String("subnet-12345"),
 					},
 				},
 			})
@@ -1151,19 +1682,19 @@ var _ = Describe("Instance Types", func() {
 				InstanceTypeOfferings: []*ec2.InstanceTypeOffering{
 					{
 						InstanceType: aws.String("t4g.small"),
-						Location:     aws.String("us-west-2a"),
+						Location:     aws.String("usw2-az1"),
 					},
 					{
 						InstanceType: aws.String("t4g.medium"),
-						Location:     aws.String("us-west-2a"),
+						Location:     aws.String("usw2-az1"),
 					},
 					{
 						InstanceType: aws.String("t4g.xlarge"),
-						Location:     aws.String("us-west-2a"),
+						Location:     aws.String("usw2-az1"),
 					},
 					{
 						InstanceType: aws.String("m5.large"),
-						Location:     aws.String("us-west-2a"),
+						Location:     aws.String("usw2-az1"),
 					},
 				},
 			})
@@ -1380,10 +1911,7 @@ var _ = Describe("Instance Types", func() {
 			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
 			pod := coretest.UnschedulablePod()
 			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
-			ExpectNotScheduled(ctx, env.Client, pod)
-			// include deprioritized instance types
-			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
-			// Fallback to OD
+			// Fallback to OD within the same launch
 			node := ExpectScheduled(ctx, env.Client, pod)
 			Expect(node.Labels).To(HaveKeyWithValue(v1alpha5.LabelCapacityType, v1alpha5.CapacityTypeOnDemand))
 		})
@@ -1581,6 +2109,24 @@ var _ = Describe("Instance Types", func() {
 				Expect(*ltInput.LaunchTemplateData.BlockDeviceMappings[0].Ebs.SnapshotId).To(Equal("snap-xxxxxxxx"))
 			})
 		})
+		It("should advertise the launched size as capacity when ephemeral storage sizing is enabled", func() {
+			nodeTemplate.Spec.AMIFamily = aws.String(v1alpha1.AMIFamilyBottlerocket)
+			nodeTemplate.Spec.BlockDeviceMappings = nil
+			nodeTemplate.Spec.EphemeralStorageSizing = &v1alpha1.EphemeralStorageSizing{MaxSize: resource.MustParse("200Gi")}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod(coretest.PodOptions{ResourceRequirements: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceEphemeralStorage: resource.MustParse("100Gi")},
+			}})
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			// Instance types advertise the max size so that the pod can be scheduled, but the NodeClaim has the size of the volume
+			// that was launched for it
+			node := ExpectScheduled(ctx, env.Client, pod)
+			Expect(*node.Status.Capacity.StorageEphemeral()).To(Equal(resource.MustParse("128Gi")))
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				Expect(*ltInput.LaunchTemplateData.BlockDeviceMappings[1].DeviceName).To(Equal("/dev/xvdb"))
+				Expect(*ltInput.LaunchTemplateData.BlockDeviceMappings[1].Ebs.VolumeSize).To(Equal(int64(128)))
+			})
+		})
 	})
 	Context("Metadata Options", func() {
 		It("should default metadata options on generated launch template", func() {
@@ -1691,7 +2237,7 @@ func makeFakeInstanceOfferings(instanceTypes []*ec2.InstanceTypeInfo) []*ec2.Ins
 	for _, instanceType := range instanceTypes {
 		instanceTypeOfferings = append(instanceTypeOfferings, &ec2.InstanceTypeOffering{
 			InstanceType: instanceType.InstanceType,
-			Location:     aws.String("test-zone-1a"),
+			Location:     aws.String("testzone1a"),
 		})
 	}
 	return instanceTypeOfferings
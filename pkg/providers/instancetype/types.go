@@ -18,7 +18,9 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"path"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -49,19 +51,21 @@ var (
 	instanceTypeScheme = regexp.MustCompile(`(^[a-z]+)(\-[0-9]+tb)?([0-9]+).*\.`)
 )
 
+// NewInstanceType computes the instance type's capacity and overhead. The memory capacity observed on launched
+// nodes of the instance type is used in place of the estimated VM memory overhead, if it's known.
 func NewInstanceType(ctx context.Context, info *ec2.InstanceTypeInfo, kc *corev1beta1.KubeletConfiguration,
-	region string, nodeClass *v1beta1.NodeClass, offerings cloudprovider.Offerings) *cloudprovider.InstanceType {
+	region string, nodeClass *v1beta1.NodeClass, offerings cloudprovider.Offerings, observedMemory *resource.Quantity) *cloudprovider.InstanceType {
 
 	amiFamily := amifamily.GetAMIFamily(nodeClass.Spec.AMIFamily, &amifamily.Options{})
 	return &cloudprovider.InstanceType{
 		Name:         aws.StringValue(info.InstanceType),
 		Requirements: computeRequirements(ctx, info, offerings, region, amiFamily, kc, nodeClass),
 		Offerings:    offerings,
-		Capacity:     computeCapacity(ctx, info, amiFamily, nodeClass.Spec.BlockDeviceMappings, kc, nodeClass),
+		Capacity:     computeCapacity(ctx, info, amiFamily, nodeClass.Spec.BlockDeviceMappings, kc, nodeClass, observedMemory),
 		Overhead: &cloudprovider.InstanceTypeOverhead{
-			KubeReserved:      kubeReservedResources(cpu(info), pods(ctx, info, amiFamily, kc), ENILimitedPods(ctx, info), amiFamily, kc),
+			KubeReserved:      kubeReservedResources(cpu(info), pods(ctx, info, amiFamily, kc, nodeClass), ENILimitedPods(ctx, info), amiFamily, kc),
 			SystemReserved:    systemReservedResources(kc),
-			EvictionThreshold: evictionThreshold(memory(ctx, info), ephemeralStorage(amiFamily, nodeClass.Spec.BlockDeviceMappings), amiFamily, kc),
+			EvictionThreshold: evictionThreshold(memory(ctx, info, observedMemory), ephemeralStorage(amiFamily, nodeClass.Spec.BlockDeviceMappings, nodeClass.Spec.EphemeralStorageSizing), amiFamily, kc),
 		},
 	}
 }
@@ -83,7 +87,7 @@ func computeRequirements(ctx context.Context, info *ec2.InstanceTypeInfo, offeri
 		scheduling.NewRequirement(lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.LabelInstanceCPU, v1beta1.LabelInstanceCPU), v1.NodeSelectorOpIn, fmt.Sprint(aws.Int64Value(info.VCpuInfo.DefaultVCpus))),
 		scheduling.NewRequirement(lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.LabelInstanceMemory, v1beta1.LabelInstanceMemory), v1.NodeSelectorOpIn, fmt.Sprint(aws.Int64Value(info.MemoryInfo.SizeInMiB))),
 		scheduling.NewRequirement(lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.LabelInstanceNetworkBandwidth, v1beta1.LabelInstanceNetworkBandwidth), v1.NodeSelectorOpDoesNotExist),
-		scheduling.NewRequirement(lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.LabelInstancePods, v1beta1.LabelInstancePods), v1.NodeSelectorOpIn, fmt.Sprint(pods(ctx, info, amiFamily, kc))),
+		scheduling.NewRequirement(lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.LabelInstancePods, v1beta1.LabelInstancePods), v1.NodeSelectorOpIn, fmt.Sprint(pods(ctx, info, amiFamily, kc, nodeClass))),
 		scheduling.NewRequirement(lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.LabelInstanceCategory, v1beta1.LabelInstanceCategory), v1.NodeSelectorOpDoesNotExist),
 		scheduling.NewRequirement(lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.LabelInstanceFamily, v1beta1.LabelInstanceFamily), v1.NodeSelectorOpDoesNotExist),
 		scheduling.NewRequirement(lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.LabelInstanceGeneration, v1beta1.LabelInstanceGeneration), v1.NodeSelectorOpDoesNotExist),
@@ -118,16 +122,18 @@ func computeRequirements(ctx context.Context, info *ec2.InstanceTypeInfo, offeri
 		requirements[lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.LabelInstanceNetworkBandwidth, v1beta1.LabelInstanceNetworkBandwidth)].Insert(fmt.Sprint(bandwidth))
 	}
 	// GPU Labels
-	if info.GpuInfo != nil && len(info.GpuInfo.Gpus) == 1 {
-		gpu := info.GpuInfo.Gpus[0]
+	if gpus := gpus(info); len(gpus) == 1 {
+		gpu := gpus[0]
 		requirements.Get(lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.LabelInstanceGPUName, v1beta1.LabelInstanceGPUName)).Insert(lowerKabobCase(aws.StringValue(gpu.Name)))
 		requirements.Get(lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.LabelInstanceGPUManufacturer, v1beta1.LabelInstanceGPUManufacturer)).Insert(lowerKabobCase(aws.StringValue(gpu.Manufacturer)))
 		requirements.Get(lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.LabelInstanceGPUCount, v1beta1.LabelInstanceGPUCount)).Insert(fmt.Sprint(aws.Int64Value(gpu.Count)))
-		requirements.Get(lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.LabelInstanceGPUMemory, v1beta1.LabelInstanceGPUMemory)).Insert(fmt.Sprint(aws.Int64Value(gpu.MemoryInfo.SizeInMiB)))
+		if gpu.MemoryInfo != nil {
+			requirements.Get(lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.LabelInstanceGPUMemory, v1beta1.LabelInstanceGPUMemory)).Insert(fmt.Sprint(aws.Int64Value(gpu.MemoryInfo.SizeInMiB)))
+		}
 	}
 	// Accelerators
-	if info.InferenceAcceleratorInfo != nil && len(info.InferenceAcceleratorInfo.Accelerators) == 1 {
-		accelerator := info.InferenceAcceleratorInfo.Accelerators[0]
+	if accelerators := inferenceAccelerators(info); len(accelerators) == 1 {
+		accelerator := accelerators[0]
 		requirements.Get(lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.LabelInstanceAcceleratorName, v1beta1.LabelInstanceAcceleratorName)).Insert(lowerKabobCase(aws.StringValue(accelerator.Name)))
 		requirements.Get(lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.LabelInstanceAcceleratorManufacturer, v1beta1.LabelInstanceAcceleratorManufacturer)).Insert(lowerKabobCase(aws.StringValue(accelerator.Manufacturer)))
 		requirements.Get(lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.LabelInstanceAcceleratorCount, v1beta1.LabelInstanceAcceleratorCount)).Insert(fmt.Sprint(aws.Int64Value(accelerator.Count)))
@@ -166,14 +172,24 @@ func getArchitecture(info *ec2.InstanceTypeInfo) string {
 	return fmt.Sprint(aws.StringValueSlice(info.ProcessorInfo.SupportedArchitectures)) // Unrecognized, but used for error printing
 }
 
+// instanceGeneration returns the generation in the name of the instance type, e.g. 5 for c5n.large
+func instanceGeneration(name string) (int, bool) {
+	parts := instanceTypeScheme.FindStringSubmatch(name)
+	if len(parts) != 4 {
+		return 0, false
+	}
+	generation, err := strconv.Atoi(parts[3])
+	return generation, err == nil
+}
+
 func computeCapacity(ctx context.Context, info *ec2.InstanceTypeInfo, amiFamily amifamily.AMIFamily,
-	blockDeviceMappings []*v1beta1.BlockDeviceMapping, kc *corev1beta1.KubeletConfiguration, nodeClass *v1beta1.NodeClass) v1.ResourceList {
+	blockDeviceMappings []*v1beta1.BlockDeviceMapping, kc *corev1beta1.KubeletConfiguration, nodeClass *v1beta1.NodeClass, observedMemory *resource.Quantity) v1.ResourceList {
 
 	resourceList := v1.ResourceList{
 		v1.ResourceCPU:              *cpu(info),
-		v1.ResourceMemory:           *memory(ctx, info),
-		v1.ResourceEphemeralStorage: *ephemeralStorage(amiFamily, blockDeviceMappings),
-		v1.ResourcePods:             *pods(ctx, info, amiFamily, kc),
+		v1.ResourceMemory:           *memory(ctx, info, observedMemory),
+		v1.ResourceEphemeralStorage: *ephemeralStorage(amiFamily, blockDeviceMappings, nodeClass.Spec.EphemeralStorageSizing),
+		v1.ResourcePods:             *pods(ctx, info, amiFamily, kc, nodeClass),
 		lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.ResourceAWSPodENI, v1beta1.ResourceAWSPodENI):     *awsPodENI(ctx, aws.StringValue(info.InstanceType)),
 		lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.ResourceNVIDIAGPU, v1beta1.ResourceNVIDIAGPU):     *nvidiaGPUs(info),
 		lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.ResourceAMDGPU, v1beta1.ResourceAMDGPU):           *amdGPUs(info),
@@ -191,7 +207,15 @@ func cpu(info *ec2.InstanceTypeInfo) *resource.Quantity {
 	return resources.Quantity(fmt.Sprint(*info.VCpuInfo.DefaultVCpus))
 }
 
-func memory(ctx context.Context, info *ec2.InstanceTypeInfo) *resource.Quantity {
+func memory(ctx context.Context, info *ec2.InstanceTypeInfo, observedMemory *resource.Quantity) *resource.Quantity {
+	if observedMemory != nil {
+		return lo.ToPtr(observedMemory.DeepCopy())
+	}
+	return memoryWithOverhead(info, vmMemoryOverheadPercent(ctx, aws.StringValue(info.InstanceType)))
+}
+
+// memoryWithOverhead returns the memory of the instance type that's left after the VM memory overhead
+func memoryWithOverhead(info *ec2.InstanceTypeInfo, vmMemoryOverheadPercent float64) *resource.Quantity {
 	sizeInMib := *info.MemoryInfo.SizeInMiB
 	// Gravitons have an extra 64 MiB of cma reserved memory that we can't use
 	if len(info.ProcessorInfo.SupportedArchitectures) > 0 && *info.ProcessorInfo.SupportedArchitectures[0] == "arm64" {
@@ -199,12 +223,47 @@ func memory(ctx context.Context, info *ec2.InstanceTypeInfo) *resource.Quantity
 	}
 	mem := resources.Quantity(fmt.Sprintf("%dMi", sizeInMib))
 	// Account for VM overhead in calculation
-	mem.Sub(resource.MustParse(fmt.Sprintf("%dMi", int64(math.Ceil(float64(mem.Value())*awssettings.FromContext(ctx).VMMemoryOverheadPercent/1024/1024)))))
+	mem.Sub(resource.MustParse(fmt.Sprintf("%dMi", int64(math.Ceil(float64(mem.Value())*vmMemoryOverheadPercent/1024/1024)))))
 	return mem
 }
 
+// vmMemoryOverheadPercentPerFamily derives the VM memory overhead of each instance family from the memory capacities
+// that were observed on nodes of its instance types, so that the instance types of the family that haven't been
+// observed yet are estimated from the sizes that have. The largest overhead that was observed in the family is used, so
+// that the memory of the other sizes isn't overestimated.
+func vmMemoryOverheadPercentPerFamily(infos []*ec2.InstanceTypeInfo, observed map[string]resource.Quantity) map[string]float64 {
+	overheads := map[string]float64{}
+	for _, info := range infos {
+		capacity, ok := observed[aws.StringValue(info.InstanceType)]
+		if !ok {
+			continue
+		}
+		advertised := memoryWithOverhead(info, 0)
+		if advertised.IsZero() {
+			continue
+		}
+		overhead := math.Max(0, 1-float64(capacity.Value())/float64(advertised.Value()))
+		family := strings.Split(aws.StringValue(info.InstanceType), ".")[0]
+		overheads[family] = math.Max(overheads[family], overhead)
+	}
+	return overheads
+}
+
+// vmMemoryOverheadPercent returns the VM memory overhead of the longest glob in vmMemoryOverheadPercentPerInstanceType
+// that matches the instance type name, falling back to vmMemoryOverheadPercent
+func vmMemoryOverheadPercent(ctx context.Context, name string) float64 {
+	if percent, ok := instanceTypeOverride(awssettings.FromContext(ctx).VMMemoryOverheadPercentPerInstanceType, name); ok {
+		return percent
+	}
+	return awssettings.FromContext(ctx).VMMemoryOverheadPercent
+}
+
 // Setting ephemeral-storage to be either the default value or what is defined in blockDeviceMappings
-func ephemeralStorage(amiFamily amifamily.AMIFamily, blockDeviceMappings []*v1beta1.BlockDeviceMapping) *resource.Quantity {
+func ephemeralStorage(amiFamily amifamily.AMIFamily, blockDeviceMappings []*v1beta1.BlockDeviceMapping, sizing *v1beta1.EphemeralStorageSizing) *resource.Quantity {
+	// The ephemeral block device is sized to the node's requests at launch, so advertise the largest volume that may be launched
+	if sizing != nil && amiFamily.EphemeralBlockDevice() != nil {
+		return lo.ToPtr(sizing.MaxSize)
+	}
 	if len(blockDeviceMappings) != 0 {
 		switch amiFamily.(type) {
 		case *amifamily.Custom:
@@ -238,28 +297,65 @@ func awsPodENI(ctx context.Context, name string) *resource.Quantity {
 	return resources.Quantity("0")
 }
 
-func nvidiaGPUs(info *ec2.InstanceTypeInfo) *resource.Quantity {
+// TODO: remove g6 and gr6 hardcode values once DescribeInstanceTypes contains their GPU data in every region
+// Values found from: https://aws.amazon.com/ec2/instance-types/g6/
+var l4GPUCounts = map[string]int64{
+	"g6.xlarge":   1,
+	"g6.2xlarge":  1,
+	"g6.4xlarge":  1,
+	"g6.8xlarge":  1,
+	"g6.16xlarge": 1,
+	"g6.12xlarge": 4,
+	"g6.24xlarge": 4,
+	"g6.48xlarge": 8,
+	"gr6.4xlarge": 1,
+	"gr6.8xlarge": 1,
+}
+
+// gpus returns the GPUs of the instance type, falling back to the NVIDIA L4 GPUs of the g6 and gr6 instance types
+// when DescribeInstanceTypes doesn't report them
+func gpus(info *ec2.InstanceTypeInfo) []*ec2.GpuDeviceInfo {
+	if info.GpuInfo != nil && len(info.GpuInfo.Gpus) > 0 {
+		return info.GpuInfo.Gpus
+	}
+	if count, ok := l4GPUCounts[aws.StringValue(info.InstanceType)]; ok {
+		return []*ec2.GpuDeviceInfo{{
+			Name:         aws.String("L4"),
+			Manufacturer: aws.String("NVIDIA"),
+			Count:        aws.Int64(count),
+			MemoryInfo:   &ec2.GpuDeviceMemoryInfo{SizeInMiB: aws.Int64(22888)},
+		}}
+	}
+	return nil
+}
+
+func gpuCount(info *ec2.InstanceTypeInfo, manufacturer string) *resource.Quantity {
 	count := int64(0)
-	if info.GpuInfo != nil {
-		for _, gpu := range info.GpuInfo.Gpus {
-			if *gpu.Manufacturer == "NVIDIA" {
-				count += *gpu.Count
-			}
+	for _, gpu := range gpus(info) {
+		if aws.StringValue(gpu.Manufacturer) == manufacturer {
+			count += aws.Int64Value(gpu.Count)
 		}
 	}
 	return resources.Quantity(fmt.Sprint(count))
 }
 
+func nvidiaGPUs(info *ec2.InstanceTypeInfo) *resource.Quantity {
+	return gpuCount(info, "NVIDIA")
+}
+
 func amdGPUs(info *ec2.InstanceTypeInfo) *resource.Quantity {
-	count := int64(0)
-	if info.GpuInfo != nil {
-		for _, gpu := range info.GpuInfo.Gpus {
-			if *gpu.Manufacturer == "AMD" {
-				count += *gpu.Count
-			}
-		}
+	return gpuCount(info, "AMD")
+}
+
+// inferenceAccelerators returns the AWS Inferentia accelerators of the instance type. Accelerators of other
+// manufacturers, e.g. the deprecated Elastic Inference accelerators, can't be requested by pods, so they're ignored.
+func inferenceAccelerators(info *ec2.InstanceTypeInfo) []*ec2.InferenceDeviceInfo {
+	if info.InferenceAcceleratorInfo == nil {
+		return nil
 	}
-	return resources.Quantity(fmt.Sprint(count))
+	return lo.Filter(info.InferenceAcceleratorInfo.Accelerators, func(accelerator *ec2.InferenceDeviceInfo, _ int) bool {
+		return aws.StringValue(accelerator.Manufacturer) == "AWS"
+	})
 }
 
 // TODO: remove trn1 hardcode values once DescribeInstanceTypes contains the accelerator data
@@ -272,24 +368,44 @@ func awsNeurons(info *ec2.InstanceTypeInfo) *resource.Quantity {
 		count = int64(16)
 	} else if *info.InstanceType == "trn1n.32xlarge" {
 		count = int64(16)
-	} else if info.InferenceAcceleratorInfo != nil {
-		for _, accelerator := range info.InferenceAcceleratorInfo.Accelerators {
-			count += *accelerator.Count
+	} else {
+		for _, accelerator := range inferenceAccelerators(info) {
+			count += aws.Int64Value(accelerator.Count)
 		}
 	}
 	return resources.Quantity(fmt.Sprint(count))
 }
 
 func habanaGaudis(info *ec2.InstanceTypeInfo) *resource.Quantity {
-	count := int64(0)
-	if info.GpuInfo != nil {
-		for _, gpu := range info.GpuInfo.Gpus {
-			if *gpu.Manufacturer == "Habana" {
-				count += *gpu.Count
-			}
+	return gpuCount(info, "Habana")
+}
+
+// acceleratorDevice is an accelerator of an instance type, with the type and manufacturer names that EC2's
+// attribute-based instance type selection uses
+type acceleratorDevice struct {
+	acceleratorType string
+	manufacturer    string
+	count           int64
+}
+
+func acceleratorDevices(info *ec2.InstanceTypeInfo) []acceleratorDevice {
+	manufacturer := func(name *string) string {
+		return lo.Ternary(aws.StringValue(name) == "AWS", "amazon-web-services", strings.ToLower(aws.StringValue(name)))
+	}
+	devices := lo.Map(gpus(info), func(gpu *ec2.GpuDeviceInfo, _ int) acceleratorDevice {
+		return acceleratorDevice{acceleratorType: ec2.AcceleratorTypeGpu, manufacturer: manufacturer(gpu.Manufacturer), count: aws.Int64Value(gpu.Count)}
+	})
+	if info.InferenceAcceleratorInfo != nil {
+		for _, accelerator := range info.InferenceAcceleratorInfo.Accelerators {
+			devices = append(devices, acceleratorDevice{acceleratorType: ec2.AcceleratorTypeInference, manufacturer: manufacturer(accelerator.Manufacturer), count: aws.Int64Value(accelerator.Count)})
 		}
 	}
-	return resources.Quantity(fmt.Sprint(count))
+	if info.FpgaInfo != nil {
+		for _, fpga := range info.FpgaInfo.Fpgas {
+			devices = append(devices, acceleratorDevice{acceleratorType: ec2.AcceleratorTypeFpga, manufacturer: manufacturer(fpga.Manufacturer), count: aws.Int64Value(fpga.Count)})
+		}
+	}
+	return devices
 }
 
 func ENILimitedPods(ctx context.Context, info *ec2.InstanceTypeInfo) *resource.Quantity {
@@ -390,9 +506,12 @@ func evictionThreshold(memory *resource.Quantity, storage *resource.Quantity, am
 	return lo.Assign(overhead, override)
 }
 
-func pods(ctx context.Context, info *ec2.InstanceTypeInfo, amiFamily amifamily.AMIFamily, kc *corev1beta1.KubeletConfiguration) *resource.Quantity {
+func pods(ctx context.Context, info *ec2.InstanceTypeInfo, amiFamily amifamily.AMIFamily, kc *corev1beta1.KubeletConfiguration, nodeClass *v1beta1.NodeClass) *resource.Quantity {
 	var count int64
+	maxPods, overridden := instanceTypeOverride(nodeClass.Spec.MaxPodsPerInstanceType, aws.StringValue(info.InstanceType))
 	switch {
+	case overridden:
+		count = int64(maxPods)
 	case kc != nil && kc.MaxPods != nil:
 		count = int64(ptr.Int32Value(kc.MaxPods))
 	case awssettings.FromContext(ctx).EnableENILimitedPodDensity && amiFamily.FeatureFlags().SupportsENILimitedPodDensity:
@@ -407,6 +526,25 @@ func pods(ctx context.Context, info *ec2.InstanceTypeInfo, amiFamily amifamily.A
 	return resources.Quantity(fmt.Sprint(count))
 }
 
+// instanceTypeOverride returns the value of the longest glob in overrides that matches the instance type name
+func instanceTypeOverride[T any](overrides map[string]T, name string) (T, bool) {
+	patterns := lo.Filter(lo.Keys(overrides), func(pattern string, _ int) bool {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	})
+	if len(patterns) == 0 {
+		var zero T
+		return zero, false
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	return overrides[patterns[0]], true
+}
+
 func lowerKabobCase(s string) string {
 	return strings.ToLower(strings.ReplaceAll(s, " ", "-"))
 }
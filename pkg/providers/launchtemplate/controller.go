@@ -0,0 +1,76 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package launchtemplate
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/eks/eksiface"
+	"k8s.io/client-go/rest"
+	"knative.dev/pkg/logging"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+)
+
+// Controller refreshes the cluster endpoint, CA bundle and DNS IP that nodes are bootstrapped with, so that nodes keep
+// joining the cluster after any of them changes, e.g. when the cluster's certificate authority is rotated
+type Controller struct {
+	kubeReader             client.Reader
+	eksapi                 eksiface.EKSAPI
+	restConfig             *rest.Config
+	launchTemplateProvider *Provider
+}
+
+func NewController(kubeClient client.Client, eksapi eksiface.EKSAPI, launchTemplateProvider *Provider) *Controller {
+	return &Controller{
+		kubeReader:             kubeClient,
+		eksapi:                 eksapi,
+		launchTemplateProvider: launchTemplateProvider,
+	}
+}
+
+func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	clusterEndpoint, err := ResolveClusterEndpoint(ctx, c.eksapi)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	caBundle, err := ResolveCABundle(ctx, c.restConfig)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	c.launchTemplateProvider.UpdateCluster(ctx, clusterEndpoint, caBundle)
+	// Resolving the DNS IP is best-effort, since clusters with custom DNS setups may not have a DNS service
+	if kubeDNSIP, err := ResolveKubeDNSIP(ctx, c.kubeReader); err != nil {
+		logging.FromContext(ctx).Debugf("unable to detect the IP of the cluster dns service, %s", err)
+	} else {
+		c.launchTemplateProvider.UpdateKubeDNSIP(ctx, kubeDNSIP)
+	}
+	return reconcile.Result{RequeueAfter: 5 * time.Minute}, nil
+}
+
+func (c *Controller) Name() string {
+	return "launchtemplate.cluster"
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	c.restConfig = m.GetConfig()
+	// The DNS service is read without a cache, since Karpenter is only permitted to get that one service
+	c.kubeReader = m.GetAPIReader()
+	return corecontroller.NewSingletonManagedBy(m)
+}
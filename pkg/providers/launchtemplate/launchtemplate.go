@@ -30,8 +30,11 @@ import (
 	"github.com/mitchellh/hashstructure/v2"
 	"github.com/patrickmn/go-cache"
 	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/ptr"
 
@@ -43,8 +46,10 @@ import (
 	"github.com/aws/karpenter/pkg/providers/securitygroup"
 	"github.com/aws/karpenter/pkg/providers/subnet"
 	"github.com/aws/karpenter/pkg/utils"
+	"github.com/aws/karpenter/pkg/utils/tracing"
 
 	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/scheduling"
 	"github.com/aws/karpenter-core/pkg/utils/pretty"
 )
 
@@ -64,9 +69,15 @@ type Provider struct {
 	cm                    *pretty.ChangeMonitor
 	KubeDNSIP             net.IP
 	ClusterEndpoint       string
+	kubernetesInterface   kubernetes.Interface
+	namespace             string
+
+	// clusterMu guards the cluster endpoint, CA bundle and DNS IP, which are refreshed while launch templates are created
+	clusterMu sync.RWMutex
 }
 
-func NewProvider(ctx context.Context, cache *cache.Cache, ec2api ec2iface.EC2API, amiFamily *amifamily.Resolver, securityGroupProvider *securitygroup.Provider, subnetProvider *subnet.Provider, caBundle *string, startAsync <-chan struct{}, kubeDNSIP net.IP, clusterEndpoint string) *Provider {
+func NewProvider(ctx context.Context, cache *cache.Cache, ec2api ec2iface.EC2API, amiFamily *amifamily.Resolver, securityGroupProvider *securitygroup.Provider, subnetProvider *subnet.Provider, caBundle *string, startAsync <-chan struct{}, kubeDNSIP net.IP, clusterEndpoint string,
+	kubernetesInterface kubernetes.Interface, namespace string) *Provider {
 	l := &Provider{
 		ec2api:                ec2api,
 		amiFamily:             amiFamily,
@@ -77,6 +88,8 @@ func NewProvider(ctx context.Context, cache *cache.Cache, ec2api ec2iface.EC2API
 		cm:                    pretty.NewChangeMonitor(),
 		KubeDNSIP:             kubeDNSIP,
 		ClusterEndpoint:       clusterEndpoint,
+		kubernetesInterface:   kubernetesInterface,
+		namespace:             namespace,
 	}
 	l.cache.OnEvicted(l.cachedEvictedFunc(ctx))
 	go func() {
@@ -100,26 +113,71 @@ func (p *Provider) EnsureAll(ctx context.Context, nodeClass *v1beta1.NodeClass,
 	if nodeClass.Spec.LaunchTemplateName != nil {
 		return map[string][]*cloudprovider.InstanceType{ptr.StringValue(nodeClass.Spec.LaunchTemplateName): instanceTypes}, nil
 	}
+	if nodeClass.Spec.UserDataRef != nil {
+		userData, err := p.ResolveUserData(ctx, nodeClass)
+		if err != nil {
+			return nil, err
+		}
+		nodeClass = nodeClass.DeepCopy()
+		nodeClass.Spec.UserData = userData
+	}
 	options, err := p.createAMIOptions(ctx, nodeClass, lo.Assign(nodeClaim.Labels, additionalLabels), tags)
 	if err != nil {
 		return nil, err
 	}
-	resolvedLaunchTemplates, err := p.amiFamily.Resolve(ctx, nodeClass, nodeClaim, instanceTypes, options)
+	amisCtx, span := tracing.Start(ctx, "ResolveAMIs")
+	resolvedLaunchTemplates, err := p.amiFamily.Resolve(amisCtx, nodeClass, nodeClaim, instanceTypes, options)
+	tracing.End(span, err)
 	if err != nil {
 		return nil, err
 	}
+	launchTemplatesCtx, span := tracing.Start(ctx, "EnsureLaunchTemplates", attribute.Int("launch-templates", len(resolvedLaunchTemplates)))
 	launchTemplates := map[string][]*cloudprovider.InstanceType{}
 	for _, resolvedLaunchTemplate := range resolvedLaunchTemplates {
 		// Ensure the launch template exists, or create it
-		ec2LaunchTemplate, err := p.ensureLaunchTemplate(ctx, resolvedLaunchTemplate)
+		ec2LaunchTemplate, err := p.ensureLaunchTemplate(launchTemplatesCtx, resolvedLaunchTemplate)
 		if err != nil {
+			tracing.End(span, err)
 			return nil, err
 		}
 		launchTemplates[*ec2LaunchTemplate.LaunchTemplateName] = resolvedLaunchTemplate.InstanceTypes
 	}
+	tracing.End(span, nil)
 	return launchTemplates, nil
 }
 
+// ResolveUserData reads the UserData that the NodeClass's userDataRef references from Karpenter's namespace
+func (p *Provider) ResolveUserData(ctx context.Context, nodeClass *v1beta1.NodeClass) (*string, error) {
+	ref := nodeClass.Spec.UserDataRef
+	if ref == nil {
+		return nodeClass.Spec.UserData, nil
+	}
+	switch ref.Kind {
+	case v1beta1.UserDataRefKindConfigMap:
+		configMap, err := p.kubernetesInterface.CoreV1().ConfigMaps(p.namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("getting userData configmap %s/%s, %w", p.namespace, ref.Name, err)
+		}
+		userData, ok := configMap.Data[ref.Key]
+		if !ok {
+			return nil, fmt.Errorf("userData configmap %s/%s has no key %q", p.namespace, ref.Name, ref.Key)
+		}
+		return aws.String(userData), nil
+	case v1beta1.UserDataRefKindSecret:
+		secret, err := p.kubernetesInterface.CoreV1().Secrets(p.namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("getting userData secret %s/%s, %w", p.namespace, ref.Name, err)
+		}
+		userData, ok := secret.Data[ref.Key]
+		if !ok {
+			return nil, fmt.Errorf("userData secret %s/%s has no key %q", p.namespace, ref.Name, ref.Key)
+		}
+		return aws.String(string(userData)), nil
+	default:
+		return nil, fmt.Errorf("unsupported userDataRef kind %q", ref.Kind)
+	}
+}
+
 // Invalidate deletes a launch template from cache if it exists
 func (p *Provider) Invalidate(ctx context.Context, ltName string, ltID string) {
 	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("launch-template-name", ltName, "launch-template-id", ltID))
@@ -131,6 +189,31 @@ func (p *Provider) Invalidate(ctx context.Context, ltName string, ltID string) {
 	p.cache.Delete(ltName)
 }
 
+// UpdateCluster sets the cluster endpoint and CA bundle that nodes are bootstrapped with. Launch templates are named by
+// the hash of their user data, so nodes are launched from new launch templates once either changes.
+func (p *Provider) UpdateCluster(ctx context.Context, clusterEndpoint string, caBundle *string) {
+	p.clusterMu.Lock()
+	defer p.clusterMu.Unlock()
+	if clusterEndpoint != p.ClusterEndpoint {
+		logging.FromContext(ctx).With("cluster-endpoint", clusterEndpoint).Infof("cluster endpoint changed")
+	}
+	if lo.FromPtr(caBundle) != lo.FromPtr(p.caBundle) {
+		logging.FromContext(ctx).Infof("cluster CA bundle changed")
+	}
+	p.ClusterEndpoint = clusterEndpoint
+	p.caBundle = caBundle
+}
+
+// UpdateKubeDNSIP sets the IP of the cluster's DNS service that nodes are bootstrapped with
+func (p *Provider) UpdateKubeDNSIP(ctx context.Context, kubeDNSIP net.IP) {
+	p.clusterMu.Lock()
+	defer p.clusterMu.Unlock()
+	if !kubeDNSIP.Equal(p.KubeDNSIP) {
+		logging.FromContext(ctx).With("kube-dns-ip", kubeDNSIP).Infof("cluster dns ip changed")
+	}
+	p.KubeDNSIP = kubeDNSIP
+}
+
 func launchTemplateName(options *amifamily.LaunchTemplate) string {
 	hash, err := hashstructure.Hash(options, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
 	if err != nil {
@@ -152,25 +235,40 @@ func (p *Provider) createAMIOptions(ctx context.Context, nodeClass *v1beta1.Node
 		return nil, err
 	}
 	// Get constrained security groups
-	securityGroups, err := p.securityGroupProvider.List(ctx, nodeClass)
+	securityGroupsCtx, span := tracing.Start(ctx, "ResolveSecurityGroups")
+	securityGroups, err := p.securityGroupProvider.List(securityGroupsCtx, nodeClass)
+	tracing.End(span, err)
 	if err != nil {
 		return nil, err
 	}
 	if len(securityGroups) == 0 {
 		return nil, fmt.Errorf("no security groups exist given constraints")
 	}
+	if err = p.securityGroupProvider.ValidateCount(ctx, securityGroups); err != nil {
+		return nil, err
+	}
+	p.clusterMu.RLock()
+	clusterEndpoint, caBundle, kubeDNSIP := p.ClusterEndpoint, p.caBundle, p.KubeDNSIP
+	p.clusterMu.RUnlock()
 	options := &amifamily.Options{
 		ClusterName:             settings.FromContext(ctx).ClusterName,
-		ClusterEndpoint:         p.ClusterEndpoint,
+		ClusterEndpoint:         clusterEndpoint,
 		AWSENILimitedPodDensity: settings.FromContext(ctx).EnableENILimitedPodDensity,
 		InstanceProfile:         instanceProfile,
 		SecurityGroups: lo.Map(securityGroups, func(s *ec2.SecurityGroup, _ int) v1beta1.SecurityGroup {
 			return v1beta1.SecurityGroup{ID: aws.StringValue(s.GroupId), Name: aws.StringValue(s.GroupName)}
 		}),
-		Tags:      tags,
-		Labels:    labels,
-		CABundle:  p.caBundle,
-		KubeDNSIP: p.KubeDNSIP,
+		Tags:                   tags,
+		Labels:                 labels,
+		CABundle:               caBundle,
+		KubeDNSIP:              kubeDNSIP,
+		BottlerocketSettings:   nodeClass.Spec.Bottlerocket,
+		ContainerRegistries:    nodeClass.Spec.ContainerRegistries,
+		NodeClassKubeletConfig: nodeClass.Spec.Kubelet,
+		NVIDIAContainerRuntime: nodeClass.Spec.NVIDIA != nil && lo.FromPtr(nodeClass.Spec.NVIDIA.ContainerRuntime),
+		Windows:                nodeClass.Spec.Windows,
+		PostBootstrapUserData:  lo.FromPtr(nodeClass.Spec.UserDataMergeOrder) == v1beta1.UserDataMergeOrderPostBootstrap,
+		CompressUserData:       lo.FromPtr(nodeClass.Spec.UserDataCompression) == v1beta1.UserDataCompressionGzip,
 	}
 	if ok, err := p.subnetProvider.CheckAnyPublicIPAssociations(ctx, nodeClass); err != nil {
 		return nil, err
@@ -217,6 +315,85 @@ func (p *Provider) ensureLaunchTemplate(ctx context.Context, options *amifamily.
 	return launchTemplate, nil
 }
 
+// Validate performs a DryRun launch template creation and a DryRun launch for each of the resolved AMIs of the
+// NodeClass, using the resolved subnets, security groups and instance profile, so that missing permissions or invalid
+// parameters are surfaced on the NodeClass rather than at provisioning time. Only the launch checks that the instance
+// profile's role can be passed, that the AMI and subnet can be launched with, and that instances can be launched and
+// tagged at all, e.g. under a service control policy that denies them.
+func (p *Provider) Validate(ctx context.Context, nodeClass *v1beta1.NodeClass) error {
+	// Custom launch templates aren't managed by Karpenter so there is nothing to validate
+	if nodeClass.Spec.LaunchTemplateName != nil {
+		return nil
+	}
+	instanceProfile, err := p.getInstanceProfile(ctx, nodeClass)
+	if err != nil {
+		return err
+	}
+	if len(nodeClass.Status.AMIs) == 0 || len(nodeClass.Status.Subnets) == 0 {
+		return fmt.Errorf("amis and subnets must be resolved before validation")
+	}
+	// Templated tags are only rendered at launch
+	tags := utils.MergeTags(lo.OmitBy(nodeClass.Spec.Tags, func(_ string, v string) bool { return utils.IsTagTemplate(v) }),
+		utils.ClusterTags(settings.FromContext(ctx).ClusterName), map[string]string{karpenterManagedTagKey: settings.FromContext(ctx).ClusterName})
+	securityGroupIDs := lo.Map(nodeClass.Status.SecurityGroups, func(s v1beta1.SecurityGroup, _ int) *string { return aws.String(s.ID) })
+	for _, ami := range lo.UniqBy(nodeClass.Status.AMIs, func(ami v1beta1.AMI) string { return ami.ID }) {
+		amiID := ami.ID
+		_, err := p.ec2api.CreateLaunchTemplateWithContext(ctx, &ec2.CreateLaunchTemplateInput{
+			DryRun:             aws.Bool(true),
+			LaunchTemplateName: aws.String(fmt.Sprintf(launchTemplateNameFormat, "dryrun")),
+			LaunchTemplateData: &ec2.RequestLaunchTemplateData{
+				IamInstanceProfile: &ec2.LaunchTemplateIamInstanceProfileSpecificationRequest{
+					Name: aws.String(instanceProfile),
+				},
+				ImageId: aws.String(amiID),
+				NetworkInterfaces: []*ec2.LaunchTemplateInstanceNetworkInterfaceSpecificationRequest{{
+					DeviceIndex: aws.Int64(0),
+					SubnetId:    aws.String(nodeClass.Status.Subnets[0].ID),
+					Groups:      securityGroupIDs,
+				}},
+			},
+			TagSpecifications: []*ec2.TagSpecification{
+				{ResourceType: aws.String(ec2.ResourceTypeLaunchTemplate), Tags: tags},
+			},
+		})
+		if err != nil && !awserrors.IsDryRunSucceeded(err) {
+			return fmt.Errorf("dry run creating launch template for %s, %w", amiID, err)
+		}
+		_, err = p.ec2api.RunInstancesWithContext(ctx, &ec2.RunInstancesInput{
+			DryRun:       aws.Bool(true),
+			MinCount:     aws.Int64(1),
+			MaxCount:     aws.Int64(1),
+			InstanceType: aws.String(dryRunInstanceType(ami)),
+			IamInstanceProfile: &ec2.IamInstanceProfileSpecification{
+				Name: aws.String(instanceProfile),
+			},
+			ImageId: aws.String(amiID),
+			NetworkInterfaces: []*ec2.InstanceNetworkInterfaceSpecification{{
+				DeviceIndex: aws.Int64(0),
+				SubnetId:    aws.String(nodeClass.Status.Subnets[0].ID),
+				Groups:      securityGroupIDs,
+			}},
+			TagSpecifications: []*ec2.TagSpecification{
+				{ResourceType: aws.String(ec2.ResourceTypeInstance), Tags: tags},
+				{ResourceType: aws.String(ec2.ResourceTypeVolume), Tags: tags},
+			},
+		})
+		if err != nil && !awserrors.IsDryRunSucceeded(err) {
+			return fmt.Errorf("dry run launching %s, %w", amiID, err)
+		}
+	}
+	return nil
+}
+
+// dryRunInstanceType is the instance type that the AMI is dry run launched with. It only needs to match the
+// architecture of the AMI, since the launch checks permissions rather than capacity.
+func dryRunInstanceType(ami v1beta1.AMI) string {
+	if scheduling.NewNodeSelectorRequirements(ami.Requirements...).Get(v1.LabelArchStable).Has(corev1beta1.ArchitectureArm64) {
+		return "m6g.large"
+	}
+	return "m5.large"
+}
+
 func (p *Provider) createLaunchTemplate(ctx context.Context, options *amifamily.LaunchTemplate) (*ec2.LaunchTemplate, error) {
 	userData, err := options.UserData.Script()
 	if err != nil {
@@ -233,6 +410,10 @@ func (p *Provider) createLaunchTemplate(ctx context.Context, options *amifamily.
 			Monitoring: &ec2.LaunchTemplatesMonitoringRequest{
 				Enabled: aws.Bool(options.DetailedMonitoring),
 			},
+			CreditSpecification:               lo.Ternary(options.CPUCredits != nil, &ec2.CreditSpecificationRequest{CpuCredits: options.CPUCredits}, nil),
+			EnclaveOptions:                    lo.Ternary(options.EnclavesEnabled, &ec2.LaunchTemplateEnclaveOptionsRequest{Enabled: aws.Bool(true)}, nil),
+			CpuOptions:                        lo.Ternary(options.AMDSEVSNP != nil, &ec2.LaunchTemplateCpuOptionsRequest{AmdSevSnp: options.AMDSEVSNP}, nil),
+			InstanceInitiatedShutdownBehavior: options.ShutdownBehavior,
 			// If the network interface is defined, the security groups are defined within it
 			SecurityGroupIds: lo.Ternary(networkInterface != nil, nil, lo.Map(options.SecurityGroups, func(s v1beta1.SecurityGroup, _ int) *string { return aws.String(s.ID) })),
 			UserData:         aws.String(userData),
@@ -245,13 +426,14 @@ func (p *Provider) createLaunchTemplate(ctx context.Context, options *amifamily.
 			},
 			NetworkInterfaces: networkInterface,
 			TagSpecifications: []*ec2.LaunchTemplateTagSpecificationRequest{
-				{ResourceType: aws.String(ec2.ResourceTypeNetworkInterface), Tags: utils.MergeTags(options.Tags)},
+				// CreateFleet can't tag network interfaces, so the primary network interface is tagged by the launch template
+				{ResourceType: aws.String(ec2.ResourceTypeNetworkInterface), Tags: utils.MergeTags(options.Tags, utils.ClusterTags(options.ClusterName))},
 			},
 		},
 		TagSpecifications: []*ec2.TagSpecification{
 			{
 				ResourceType: aws.String(ec2.ResourceTypeLaunchTemplate),
-				Tags:         utils.MergeTags(options.Tags, map[string]string{karpenterManagedTagKey: options.ClusterName}),
+				Tags:         utils.MergeTags(options.Tags, utils.ClusterTags(options.ClusterName), map[string]string{karpenterManagedTagKey: options.ClusterName}),
 			},
 		},
 	})
@@ -352,6 +534,13 @@ func (p *Provider) cachedEvictedFunc(ctx context.Context) func(string, interface
 }
 
 func (p *Provider) getInstanceProfile(ctx context.Context, nodeClass *v1beta1.NodeClass) (string, error) {
+	// The instance profile of a NodeClass with a role is created by the NodeClass controller
+	if nodeClass.Spec.Role != nil {
+		if nodeClass.Status.InstanceProfile == "" {
+			return "", errors.New("the instance profile of spec.role hasn't been resolved")
+		}
+		return nodeClass.Status.InstanceProfile, nil
+	}
 	if nodeClass.Spec.InstanceProfile != nil {
 		return aws.StringValue(nodeClass.Spec.InstanceProfile), nil
 	}
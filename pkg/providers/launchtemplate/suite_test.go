@@ -15,9 +15,12 @@ limitations under the License.
 package launchtemplate_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"strconv"
@@ -28,12 +31,14 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/eks"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/samber/lo"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/tools/record"
 	clock "k8s.io/utils/clock/testing"
@@ -44,10 +49,12 @@ import (
 	"github.com/aws/karpenter/pkg/apis"
 	"github.com/aws/karpenter/pkg/apis/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
 	"github.com/aws/karpenter/pkg/cloudprovider"
 	"github.com/aws/karpenter/pkg/fake"
 	"github.com/aws/karpenter/pkg/providers/amifamily/bootstrap"
 	"github.com/aws/karpenter/pkg/providers/instancetype"
+	"github.com/aws/karpenter/pkg/providers/launchtemplate"
 	"github.com/aws/karpenter/pkg/test"
 	nodeclassutil "github.com/aws/karpenter/pkg/utils/nodeclass"
 
@@ -90,7 +97,8 @@ var _ = BeforeSuite(func() {
 
 	fakeClock = &clock.FakeClock{}
 	cloudProvider = cloudprovider.New(awsEnv.InstanceTypesProvider, awsEnv.InstanceProvider, events.NewRecorder(&record.FakeRecorder{}),
-		env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider)
+		env.Client, awsEnv.AMIProvider, awsEnv.SecurityGroupProvider, awsEnv.SubnetProvider, awsEnv.QuotaProvider,
+		awsEnv.NotificationProvider)
 	cluster = state.NewCluster(fakeClock, env.Client, cloudProvider)
 	prov = provisioning.NewProvisioner(env.Client, env.KubernetesInterface.CoreV1(), events.NewRecorder(&record.FakeRecorder{}), cloudProvider, cluster)
 })
@@ -131,7 +139,7 @@ var _ = BeforeEach(func() {
 	awsEnv.Reset()
 
 	awsEnv.LaunchTemplateProvider.KubeDNSIP = net.ParseIP("10.0.100.10")
-	awsEnv.LaunchTemplateProvider.ClusterEndpoint = "https://test-cluster"
+	awsEnv.LaunchTemplateProvider.UpdateCluster(ctx, "https://test-cluster", lo.ToPtr("ca-bundle"))
 })
 
 var _ = AfterEach(func() {
@@ -173,6 +181,24 @@ var _ = Describe("LaunchTemplates", func() {
 			Expect(*launchTemplate.LaunchTemplateName).To(Equal("test-launch-template"))
 			Expect(*launchTemplate.Version).To(Equal("$Latest"))
 		})
+		It("should tag the primary network interface after launch", func() {
+			nodeTemplate.Spec.LaunchTemplateName = aws.String("test-launch-template")
+			nodeTemplate.Spec.SecurityGroupSelector = nil
+			nodeTemplate.Spec.Tags = map[string]string{"team": "ml"}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(awsEnv.EC2API.CreateTagsBehavior.CalledWithInput.Len()).To(Equal(1))
+			input := awsEnv.EC2API.CreateTagsBehavior.CalledWithInput.Pop()
+			Expect(input.Resources).To(HaveLen(1))
+			Expect(*input.Resources[0]).To(HavePrefix("eni-"))
+			ExpectTags(input.Tags, map[string]string{
+				"team": "ml",
+				fmt.Sprintf("kubernetes.io/cluster/%s", settings.FromContext(ctx).ClusterName): "owned",
+				v1alpha5.ProvisionerNameLabelKey:                                               provisioner.Name,
+			})
+		})
 	})
 	Context("Cache", func() {
 		It("should use same launch template for equivalent constraints", func() {
@@ -318,6 +344,31 @@ var _ = Describe("LaunchTemplates", func() {
 			Expect(*createFleetInput.TagSpecifications[2].ResourceType).To(Equal(ec2.ResourceTypeFleet))
 			ExpectTags(createFleetInput.TagSpecifications[2].Tags, tags)
 		})
+		It("should tag every resource with the cluster tags", func() {
+			// the cluster tags can't be overridden
+			nodeTemplate.Spec.Tags = map[string]string{v1beta1.EKSClusterNameTagKey: "other-cluster"}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			clusterName := settings.FromContext(ctx).ClusterName
+			tags := map[string]string{
+				fmt.Sprintf("kubernetes.io/cluster/%s", clusterName): "owned",
+				v1beta1.EKSClusterNameTagKey:                         clusterName,
+			}
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(createFleetInput.TagSpecifications).To(HaveLen(3))
+			for _, tagSpecification := range createFleetInput.TagSpecifications {
+				ExpectTags(tagSpecification.Tags, tags)
+			}
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				Expect(*ltInput.TagSpecifications[0].ResourceType).To(Equal(ec2.ResourceTypeLaunchTemplate))
+				ExpectTags(ltInput.TagSpecifications[0].Tags, tags)
+				Expect(*ltInput.LaunchTemplateData.TagSpecifications[0].ResourceType).To(Equal(ec2.ResourceTypeNetworkInterface))
+				ExpectTags(ltInput.LaunchTemplateData.TagSpecifications[0].Tags, tags)
+			})
+		})
 		It("should request that tags be applied to both instances and volumes", func() {
 			nodeTemplate.Spec.Tags = map[string]string{
 				"tag1": "tag1value",
@@ -341,6 +392,41 @@ var _ = Describe("LaunchTemplates", func() {
 			Expect(*createFleetInput.TagSpecifications[2].ResourceType).To(Equal(ec2.ResourceTypeFleet))
 			ExpectTags(createFleetInput.TagSpecifications[2].Tags, nodeTemplate.Spec.Tags)
 		})
+		It("should tag volumes that are deleted on termination", func() {
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(*createFleetInput.TagSpecifications[1].ResourceType).To(Equal(ec2.ResourceTypeVolume))
+			ExpectTags(createFleetInput.TagSpecifications[1].Tags, map[string]string{v1beta1.VolumeDeleteOnTerminationTagKey: "true"})
+			ExpectTagsNotFound(createFleetInput.TagSpecifications[0].Tags, map[string]string{v1beta1.VolumeDeleteOnTerminationTagKey: "true"})
+		})
+		It("should not tag volumes when any volume is retained on termination", func() {
+			nodeTemplate.Spec.BlockDeviceMappings = []*v1alpha1.BlockDeviceMapping{
+				{
+					DeviceName: aws.String("/dev/xvda"),
+					EBS: &v1alpha1.BlockDevice{
+						DeleteOnTermination: aws.Bool(true),
+						VolumeSize:          lo.ToPtr(resource.MustParse("20G")),
+					},
+				},
+				{
+					DeviceName: aws.String("/dev/xvdb"),
+					EBS: &v1alpha1.BlockDevice{
+						DeleteOnTermination: aws.Bool(false),
+						VolumeSize:          lo.ToPtr(resource.MustParse("200G")),
+					},
+				},
+			}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			Expect(*createFleetInput.TagSpecifications[1].ResourceType).To(Equal(ec2.ResourceTypeVolume))
+			ExpectTagsNotFound(createFleetInput.TagSpecifications[1].Tags, map[string]string{v1beta1.VolumeDeleteOnTerminationTagKey: "true"})
+		})
 		It("should override default tag names", func() {
 			// these tags are defaulted, so ensure users can override them
 			nodeTemplate.Spec.Tags = map[string]string{
@@ -428,6 +514,73 @@ var _ = Describe("LaunchTemplates", func() {
 			ExpectTags(createFleetInput.TagSpecifications[2].Tags, nodeTemplate.Spec.Tags)
 			ExpectTagsNotFound(createFleetInput.TagSpecifications[0].Tags, settingsTags)
 		})
+		It("should render templated tags with the provisioner, node template and labels", func() {
+			provisioner.Spec.Labels = map[string]string{"team": "ml"}
+			nodeTemplate.Spec.Tags = map[string]string{
+				"provisioner": "{{ .NodePool }}",
+				"template":    "{{ .NodeClass }}",
+				"team":        `{{ index .Labels "team" }}`,
+				"cost-center": `{{ index .Labels "cost-center" }}`,
+			}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Len()).To(Equal(1))
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			tags := map[string]string{
+				"provisioner": provisioner.Name,
+				"template":    nodeTemplate.Name,
+				"team":        "ml",
+				"cost-center": "",
+			}
+			for _, tagSpecification := range createFleetInput.TagSpecifications {
+				ExpectTags(tagSpecification.Tags, tags)
+			}
+			Expect(awsEnv.EC2API.CreateTagsBehavior.CalledWithInput.Len()).To(Equal(0))
+		})
+		It("should tag the instance with templated tags that are resolved by the launch", func() {
+			nodeTemplate.Spec.Tags = map[string]string{"zone": "{{ .Zone }}", "capacity-type": "{{ .CapacityType }}"}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			node := ExpectScheduled(ctx, env.Client, pod)
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			ExpectTags(createFleetInput.TagSpecifications[0].Tags, map[string]string{"zone": "", "capacity-type": ""})
+
+			Expect(awsEnv.EC2API.CreateTagsBehavior.CalledWithInput.Len()).To(Equal(1))
+			createTagsInput := awsEnv.EC2API.CreateTagsBehavior.CalledWithInput.Pop()
+			// the instance, its root volume and its primary network interface
+			Expect(createTagsInput.Resources).To(HaveLen(3))
+			ExpectTags(createTagsInput.Tags, map[string]string{
+				"zone":          node.Labels[v1.LabelTopologyZone],
+				"capacity-type": node.Labels[v1alpha5.LabelCapacityType],
+			})
+		})
+		It("should render templated tags at launch when the requirements resolve them", func() {
+			nodeTemplate.Spec.Tags = map[string]string{"zone": "{{ .Zone }}"}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod(coretest.PodOptions{NodeSelector: map[string]string{v1.LabelTopologyZone: "test-zone-1b"}})
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			ExpectTags(createFleetInput.TagSpecifications[0].Tags, map[string]string{"zone": "test-zone-1b"})
+			Expect(awsEnv.EC2API.CreateTagsBehavior.CalledWithInput.Len()).To(Equal(0))
+		})
+		It("should tag with the literal values of tags that can't be parsed as templates", func() {
+			nodeTemplate.Spec.Tags = map[string]string{"braces": "{{", "unterminated": "{{ .Zone"}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			for _, tagSpecification := range createFleetInput.TagSpecifications {
+				ExpectTags(tagSpecification.Tags, nodeTemplate.Spec.Tags)
+			}
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				ExpectTags(ltInput.TagSpecifications[0].Tags, nodeTemplate.Spec.Tags)
+			})
+		})
 	})
 	Context("Block Device Mappings", func() {
 		It("should default AL2 block device mappings", func() {
@@ -550,6 +703,43 @@ var _ = Describe("LaunchTemplates", func() {
 				Expect(ltInput.LaunchTemplateData.BlockDeviceMappings[1].Ebs.Iops).To(BeNil())
 			})
 		})
+		It("should size the bottlerocket second volume from the ephemeral-storage requests of the pods", func() {
+			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+			nodeTemplate.Spec.EphemeralStorageSizing = &v1alpha1.EphemeralStorageSizing{
+				MaxSize:  resource.MustParse("200Gi"),
+				Overhead: lo.ToPtr(resource.MustParse("10Gi")),
+			}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod(coretest.PodOptions{ResourceRequirements: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceEphemeralStorage: resource.MustParse("50Gi")},
+			}})
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				Expect(len(ltInput.LaunchTemplateData.BlockDeviceMappings)).To(Equal(2))
+				// Bottlerocket control volume is left as-is
+				Expect(*ltInput.LaunchTemplateData.BlockDeviceMappings[0].Ebs.VolumeSize).To(Equal(int64(4)))
+				// Bottlerocket user volume is sized to the requests plus the overhead, rounded up to the next power of two
+				Expect(*ltInput.LaunchTemplateData.BlockDeviceMappings[1].Ebs.VolumeSize).To(Equal(int64(64)))
+			})
+		})
+		It("should not size the bottlerocket second volume below the default when requests are small", func() {
+			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+			nodeTemplate.Spec.EphemeralStorageSizing = &v1alpha1.EphemeralStorageSizing{
+				MaxSize: resource.MustParse("200Gi"),
+			}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod(coretest.PodOptions{ResourceRequirements: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceEphemeralStorage: resource.MustParse("1Gi")},
+			}})
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				Expect(*ltInput.LaunchTemplateData.BlockDeviceMappings[1].Ebs.VolumeSize).To(Equal(int64(20)))
+			})
+		})
 		It("should not default block device mappings for custom AMIFamilies", func() {
 			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyCustom
 			nodeTemplate.Spec.AMISelector = map[string]string{"*": "*"}
@@ -773,7 +963,7 @@ var _ = Describe("LaunchTemplates", func() {
 			}))
 
 			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
-			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 			overhead := it.Overhead.Total()
 			Expect(overhead.Memory().String()).To(Equal("993Mi"))
 		})
@@ -784,7 +974,7 @@ var _ = Describe("LaunchTemplates", func() {
 			}))
 
 			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
-			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 			overhead := it.Overhead.Total()
 			Expect(overhead.Memory().String()).To(Equal("993Mi"))
 		})
@@ -823,7 +1013,7 @@ var _ = Describe("LaunchTemplates", func() {
 			}))
 
 			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
-			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 			overhead := it.Overhead.Total()
 			Expect(overhead.Memory().String()).To(Equal("993Mi"))
 		})
@@ -834,7 +1024,7 @@ var _ = Describe("LaunchTemplates", func() {
 			}))
 
 			nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
-			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil)
+			it := instancetype.NewInstanceType(ctx, info, nodepoolutil.NewKubeletConfiguration(provisioner.Spec.KubeletConfiguration), "", nodeclassutil.New(nodeTemplate), nil, nil)
 			overhead := it.Overhead.Total()
 			Expect(overhead.Memory().String()).To(Equal("1565Mi"))
 		})
@@ -847,7 +1037,7 @@ var _ = Describe("LaunchTemplates", func() {
 			ExpectScheduled(ctx, env.Client, pod)
 			ExpectLaunchTemplatesCreatedWithUserDataContaining("--use-max-pods false")
 		})
-		It("should specify --use-max-pods=false when not using ENI-based pod density", func() {
+		It("should specify --use-max-pods=false and maxPods=110 when not using ENI-based pod density", func() {
 			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
 				EnableENILimitedPodDensity: lo.ToPtr(false),
 			}))
@@ -856,17 +1046,17 @@ var _ = Describe("LaunchTemplates", func() {
 			pod := coretest.UnschedulablePod()
 			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
 			ExpectScheduled(ctx, env.Client, pod)
-			ExpectLaunchTemplatesCreatedWithUserDataContaining("--use-max-pods false", "--max-pods=110")
+			ExpectLaunchTemplatesCreatedWithUserDataContaining("--use-max-pods false", `"maxPods":110`)
 		})
-		It("should specify --use-max-pods=false and --max-pods user value when user specifies maxPods in Provisioner", func() {
+		It("should specify --use-max-pods=false and maxPods user value when user specifies maxPods in Provisioner", func() {
 			provisioner.Spec.KubeletConfiguration = &v1alpha5.KubeletConfiguration{MaxPods: aws.Int32(10)}
 			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
 			pod := coretest.UnschedulablePod()
 			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
 			ExpectScheduled(ctx, env.Client, pod)
-			ExpectLaunchTemplatesCreatedWithUserDataContaining("--use-max-pods false", "--max-pods=10")
+			ExpectLaunchTemplatesCreatedWithUserDataContaining("--use-max-pods false", `"maxPods":10}`)
 		})
-		It("should specify --system-reserved when overriding system reserved values", func() {
+		It("should specify systemReserved in the kubelet config file when overriding system reserved values", func() {
 			provisioner.Spec.KubeletConfiguration = &v1alpha5.KubeletConfiguration{
 				SystemReserved: v1.ResourceList{
 					v1.ResourceCPU:              resource.MustParse("500m"),
@@ -878,20 +1068,8 @@ var _ = Describe("LaunchTemplates", func() {
 			pod := coretest.UnschedulablePod()
 			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
 			ExpectScheduled(ctx, env.Client, pod)
-			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
-			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
-				userData, err := base64.StdEncoding.DecodeString(*ltInput.LaunchTemplateData.UserData)
-				Expect(err).To(BeNil())
-
-				// Check whether the arguments are there for --system-reserved
-				arg := "--system-reserved="
-				i := strings.Index(string(userData), arg)
-				rem := string(userData)[(i + len(arg)):]
-				i = strings.Index(rem, "'")
-				for k, v := range provisioner.Spec.KubeletConfiguration.SystemReserved {
-					Expect(rem[:i]).To(ContainSubstring(fmt.Sprintf("%v=%v", k.String(), v.String())))
-				}
-			})
+			ExpectLaunchTemplatesCreatedWithUserDataContaining(`"systemReserved":{"cpu":"500m","ephemeral-storage":"2Gi","memory":"1Gi"}`)
+			ExpectLaunchTemplatesCreatedWithUserDataNotContaining("--system-reserved")
 		})
 		It("should specify --kube-reserved when overriding system reserved values", func() {
 			provisioner.Spec.KubeletConfiguration = &v1alpha5.KubeletConfiguration{
@@ -920,7 +1098,7 @@ var _ = Describe("LaunchTemplates", func() {
 				}
 			})
 		})
-		It("should pass eviction hard threshold values when specified", func() {
+		It("should pass eviction hard threshold values in the kubelet config file when specified", func() {
 			provisioner.Spec.KubeletConfiguration = &v1alpha5.KubeletConfiguration{
 				EvictionHard: map[string]string{
 					"memory.available":  "10%",
@@ -932,22 +1110,10 @@ var _ = Describe("LaunchTemplates", func() {
 			pod := coretest.UnschedulablePod()
 			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
 			ExpectScheduled(ctx, env.Client, pod)
-			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
-			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
-				userData, err := base64.StdEncoding.DecodeString(*ltInput.LaunchTemplateData.UserData)
-				Expect(err).To(BeNil())
-
-				// Check whether the arguments are there for --kube-reserved
-				arg := "--eviction-hard="
-				i := strings.Index(string(userData), arg)
-				rem := string(userData)[(i + len(arg)):]
-				i = strings.Index(rem, "'")
-				for k, v := range provisioner.Spec.KubeletConfiguration.EvictionHard {
-					Expect(rem[:i]).To(ContainSubstring(fmt.Sprintf("%v<%v", k, v)))
-				}
-			})
+			ExpectLaunchTemplatesCreatedWithUserDataContaining(`"evictionHard":{"memory.available":"10%","nodefs.available":"15%","nodefs.inodesFree":"5%"}`)
+			ExpectLaunchTemplatesCreatedWithUserDataNotContaining("--eviction-hard")
 		})
-		It("should pass eviction soft threshold values when specified", func() {
+		It("should pass eviction soft threshold values in the kubelet config file when specified", func() {
 			provisioner.Spec.KubeletConfiguration = &v1alpha5.KubeletConfiguration{
 				EvictionSoft: map[string]string{
 					"memory.available":  "10%",
@@ -959,22 +1125,10 @@ var _ = Describe("LaunchTemplates", func() {
 			pod := coretest.UnschedulablePod()
 			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
 			ExpectScheduled(ctx, env.Client, pod)
-			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
-			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
-				userData, err := base64.StdEncoding.DecodeString(*ltInput.LaunchTemplateData.UserData)
-				Expect(err).To(BeNil())
-
-				// Check whether the arguments are there for --kube-reserved
-				arg := "--eviction-soft="
-				i := strings.Index(string(userData), arg)
-				rem := string(userData)[(i + len(arg)):]
-				i = strings.Index(rem, "'")
-				for k, v := range provisioner.Spec.KubeletConfiguration.EvictionSoft {
-					Expect(rem[:i]).To(ContainSubstring(fmt.Sprintf("%v<%v", k, v)))
-				}
-			})
+			ExpectLaunchTemplatesCreatedWithUserDataContaining(`"evictionSoft":{"memory.available":"10%","nodefs.available":"15%","nodefs.inodesFree":"5%"}`)
+			ExpectLaunchTemplatesCreatedWithUserDataNotContaining("--eviction-soft")
 		})
-		It("should pass eviction soft grace period values when specified", func() {
+		It("should pass eviction soft grace period values in the kubelet config file when specified", func() {
 			provisioner.Spec.KubeletConfiguration = &v1alpha5.KubeletConfiguration{
 				EvictionSoftGracePeriod: map[string]metav1.Duration{
 					"memory.available":  {Duration: time.Minute},
@@ -986,22 +1140,9 @@ var _ = Describe("LaunchTemplates", func() {
 			pod := coretest.UnschedulablePod()
 			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
 			ExpectScheduled(ctx, env.Client, pod)
-			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
-			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
-				userData, err := base64.StdEncoding.DecodeString(*ltInput.LaunchTemplateData.UserData)
-				Expect(err).To(BeNil())
-
-				// Check whether the arguments are there for --kube-reserved
-				arg := "--eviction-soft-grace-period="
-				i := strings.Index(string(userData), arg)
-				rem := string(userData)[(i + len(arg)):]
-				i = strings.Index(rem, "'")
-				for k, v := range provisioner.Spec.KubeletConfiguration.EvictionSoftGracePeriod {
-					Expect(rem[:i]).To(ContainSubstring(fmt.Sprintf("%v=%v", k, v.Duration.String())))
-				}
-			})
+			ExpectLaunchTemplatesCreatedWithUserDataContaining(`"evictionSoftGracePeriod":{"memory.available":"1m0s","nodefs.available":"3m0s","nodefs.inodesFree":"5m0s"}`)
 		})
-		It("should pass eviction max pod grace period when specified", func() {
+		It("should pass eviction max pod grace period in the kubelet config file when specified", func() {
 			provisioner.Spec.KubeletConfiguration = &v1alpha5.KubeletConfiguration{
 				EvictionMaxPodGracePeriod: aws.Int32(300),
 			}
@@ -1009,9 +1150,9 @@ var _ = Describe("LaunchTemplates", func() {
 			pod := coretest.UnschedulablePod()
 			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
 			ExpectScheduled(ctx, env.Client, pod)
-			ExpectLaunchTemplatesCreatedWithUserDataContaining(fmt.Sprintf("--eviction-max-pod-grace-period=%d", 300))
+			ExpectLaunchTemplatesCreatedWithUserDataContaining(`"evictionMaxPodGracePeriod":300`)
 		})
-		It("should specify --pods-per-core", func() {
+		It("should specify podsPerCore in the kubelet config file", func() {
 			provisioner.Spec.KubeletConfiguration = &v1alpha5.KubeletConfiguration{
 				PodsPerCore: aws.Int32(2),
 			}
@@ -1019,9 +1160,9 @@ var _ = Describe("LaunchTemplates", func() {
 			pod := coretest.UnschedulablePod()
 			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
 			ExpectScheduled(ctx, env.Client, pod)
-			ExpectLaunchTemplatesCreatedWithUserDataContaining(fmt.Sprintf("--pods-per-core=%d", 2))
+			ExpectLaunchTemplatesCreatedWithUserDataContaining(`"podsPerCore":2`)
 		})
-		It("should specify --pods-per-core with --max-pods enabled", func() {
+		It("should specify podsPerCore with maxPods enabled in the kubelet config file", func() {
 			provisioner.Spec.KubeletConfiguration = &v1alpha5.KubeletConfiguration{
 				PodsPerCore: aws.Int32(2),
 				MaxPods:     aws.Int32(100),
@@ -1030,7 +1171,52 @@ var _ = Describe("LaunchTemplates", func() {
 			pod := coretest.UnschedulablePod()
 			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
 			ExpectScheduled(ctx, env.Client, pod)
-			ExpectLaunchTemplatesCreatedWithUserDataContaining(fmt.Sprintf("--pods-per-core=%d", 2), fmt.Sprintf("--max-pods=%d", 100))
+			ExpectLaunchTemplatesCreatedWithUserDataContaining(`{"maxPods":100,"podsPerCore":2}`)
+		})
+		It("should merge the kubelet config file into the AMI's kubelet config", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+				EnableENILimitedPodDensity: lo.ToPtr(false),
+			}))
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataContaining(
+				"cat <<'EOF' > /etc/kubernetes/kubelet/kubelet-config-karpenter.json\n{\"maxPods\":110}\nEOF\n",
+				`echo "$(jq -s '.[0] + .[1]' /etc/kubernetes/kubelet/kubelet-config.json /etc/kubernetes/kubelet/kubelet-config-karpenter.json)" > /etc/kubernetes/kubelet/kubelet-config.json`,
+			)
+		})
+		It("should not write a kubelet config file when there are no kubelet settings", func() {
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataNotContaining("kubelet-config-karpenter.json")
+		})
+		It("should pass the AWSNodeTemplate kubelet configuration in the kubelet config file", func() {
+			nodeTemplate.Spec.Kubelet = &v1alpha1.KubeletConfiguration{
+				ShutdownGracePeriod:             &metav1.Duration{Duration: time.Minute * 2},
+				ShutdownGracePeriodCriticalPods: &metav1.Duration{Duration: time.Second * 30},
+				RegistryPullQPS:                 aws.Int32(10),
+				RegistryBurst:                   aws.Int32(20),
+			}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataContaining(`{"shutdownGracePeriod":"2m0s","shutdownGracePeriodCriticalPods":"30s","registryPullQPS":10,"registryBurst":20}`)
+		})
+		It("should pass the AWSNodeTemplate CPU, topology and memory manager policies in the kubelet config file", func() {
+			nodeTemplate.Spec.Kubelet = &v1alpha1.KubeletConfiguration{
+				CPUManagerPolicy:      aws.String(v1alpha1.CPUManagerPolicyStatic),
+				TopologyManagerPolicy: aws.String(v1alpha1.TopologyManagerPolicySingleNUMANode),
+				MemoryManagerPolicy:   aws.String(v1alpha1.MemoryManagerPolicyStatic),
+			}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataContaining(`{"cpuManagerPolicy":"static","topologyManagerPolicy":"single-numa-node","memoryManagerPolicy":"Static"}`)
 		})
 		It("should specify --container-runtime containerd by default", func() {
 			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
@@ -1039,6 +1225,55 @@ var _ = Describe("LaunchTemplates", func() {
 			ExpectScheduled(ctx, env.Client, pod)
 			ExpectLaunchTemplatesCreatedWithUserDataContaining("--container-runtime containerd")
 		})
+		It("should register with the AWSNodeTemplate startup taints", func() {
+			provisioner.Spec.StartupTaints = []v1.Taint{{Key: "baz", Value: "bin", Effect: v1.TaintEffectNoExecute}}
+			nodeTemplate.Spec.StartupTaints = []v1.Taint{{Key: "node.cilium.io/agent-not-ready", Value: "true", Effect: v1.TaintEffectNoExecute}}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod(coretest.PodOptions{
+				Tolerations: []v1.Toleration{{Operator: v1.TolerationOpExists}},
+			})
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataContaining(`--register-with-taints="baz=bin:NoExecute,node.cilium.io/agent-not-ready=true:NoExecute"`)
+		})
+		It("should not register an AWSNodeTemplate startup taint that the provisioner already taints with", func() {
+			provisioner.Spec.Taints = []v1.Taint{{Key: "node.cilium.io/agent-not-ready", Value: "false", Effect: v1.TaintEffectNoExecute}}
+			nodeTemplate.Spec.StartupTaints = []v1.Taint{{Key: "node.cilium.io/agent-not-ready", Value: "true", Effect: v1.TaintEffectNoExecute}}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod(coretest.PodOptions{
+				Tolerations: []v1.Toleration{{Operator: v1.TolerationOpExists}},
+			})
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataContaining(`--register-with-taints="node.cilium.io/agent-not-ready=false:NoExecute"`)
+		})
+		It("should write a containerd hosts.toml for each container registry", func() {
+			nodeTemplate.Spec.ContainerRegistries = []v1alpha1.ContainerRegistry{
+				{Registry: "docker.io", Mirrors: []string{"https://mirror.example.com", "https://backup.example.com"}},
+				{Registry: "public.ecr.aws", Mirrors: []string{"https://cache.example.com"}, Auth: aws.String("dXNlcjpwYXNz")},
+			}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataContaining(
+				"mkdir -p '/etc/containerd/certs.d/docker.io'\ncat <<'EOF' > '/etc/containerd/certs.d/docker.io/hosts.toml'\n"+
+					"[host.\"https://mirror.example.com\"]\n  capabilities = [\"pull\", \"resolve\"]\n"+
+					"[host.\"https://backup.example.com\"]\n  capabilities = [\"pull\", \"resolve\"]\nEOF\n",
+				"mkdir -p '/etc/containerd/certs.d/public.ecr.aws'\ncat <<'EOF' > '/etc/containerd/certs.d/public.ecr.aws/hosts.toml'\n"+
+					"[host.\"https://cache.example.com\"]\n  capabilities = [\"pull\", \"resolve\"]\n"+
+					"  [host.\"https://cache.example.com\".header]\n    authorization = \"Basic dXNlcjpwYXNz\"\nEOF\n/etc/eks/bootstrap.sh",
+			)
+		})
+		It("should not write containerd hosts.toml files when using dockerd", func() {
+			provisioner.Spec.KubeletConfiguration = &v1alpha5.KubeletConfiguration{ContainerRuntime: aws.String("dockerd")}
+			nodeTemplate.Spec.ContainerRegistries = []v1alpha1.ContainerRegistry{{Registry: "docker.io", Mirrors: []string{"https://mirror.example.com"}}}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataNotContaining("/etc/containerd/certs.d")
+		})
 		It("should specify dockerd if specified in the provisionerSpec", func() {
 			provisioner.Spec.KubeletConfiguration = &v1alpha5.KubeletConfiguration{ContainerRuntime: aws.String("dockerd")}
 			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
@@ -1099,7 +1334,7 @@ var _ = Describe("LaunchTemplates", func() {
 			ExpectScheduled(ctx, env.Client, pod)
 			ExpectLaunchTemplatesCreatedWithUserDataContaining("--dns-cluster-ip '10.0.100.10'")
 		})
-		It("should pass ImageGCHighThresholdPercent when specified", func() {
+		It("should pass ImageGCHighThresholdPercent in the kubelet config file when specified", func() {
 			provisioner.Spec.KubeletConfiguration = &v1alpha5.KubeletConfiguration{
 				ImageGCHighThresholdPercent: aws.Int32(50),
 			}
@@ -1107,9 +1342,9 @@ var _ = Describe("LaunchTemplates", func() {
 			pod := coretest.UnschedulablePod()
 			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
 			ExpectScheduled(ctx, env.Client, pod)
-			ExpectLaunchTemplatesCreatedWithUserDataContaining("--image-gc-high-threshold=50")
+			ExpectLaunchTemplatesCreatedWithUserDataContaining(`"imageGCHighThresholdPercent":50`)
 		})
-		It("should pass ImageGCLowThresholdPercent when specified", func() {
+		It("should pass ImageGCLowThresholdPercent in the kubelet config file when specified", func() {
 			provisioner.Spec.KubeletConfiguration = &v1alpha5.KubeletConfiguration{
 				ImageGCLowThresholdPercent: aws.Int32(50),
 			}
@@ -1117,9 +1352,9 @@ var _ = Describe("LaunchTemplates", func() {
 			pod := coretest.UnschedulablePod()
 			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
 			ExpectScheduled(ctx, env.Client, pod)
-			ExpectLaunchTemplatesCreatedWithUserDataContaining("--image-gc-low-threshold=50")
+			ExpectLaunchTemplatesCreatedWithUserDataContaining(`"imageGCLowThresholdPercent":50`)
 		})
-		It("should pass --cpu-fs-quota when specified", func() {
+		It("should pass cpuCFSQuota in the kubelet config file when specified", func() {
 			provisioner.Spec.KubeletConfiguration = &v1alpha5.KubeletConfiguration{
 				CPUCFSQuota: aws.Bool(false),
 			}
@@ -1127,7 +1362,7 @@ var _ = Describe("LaunchTemplates", func() {
 			pod := coretest.UnschedulablePod()
 			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
 			ExpectScheduled(ctx, env.Client, pod)
-			ExpectLaunchTemplatesCreatedWithUserDataContaining("--cpu-cfs-quota=false")
+			ExpectLaunchTemplatesCreatedWithUserDataContaining(`"cpuCFSQuota":false`)
 		})
 		It("should not pass any labels prefixed with the node-restriction.kubernetes.io domain", func() {
 			provisioner.Spec.Labels = lo.Assign(provisioner.Spec.Labels, map[string]string{
@@ -1180,6 +1415,91 @@ var _ = Describe("LaunchTemplates", func() {
 				Expect(err).To(BeNil())
 				ExpectLaunchTemplatesCreatedWithUserData(fmt.Sprintf(string(content), provisioner.Name))
 			})
+			It("should register with the AWSNodeTemplate startup taints", func() {
+				nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+				nodeTemplate.Spec.StartupTaints = []v1.Taint{{Key: "node.cilium.io/agent-not-ready", Value: "true", Effect: v1.TaintEffectNoExecute}}
+				ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectScheduled(ctx, env.Client, pod)
+				ExpectLaunchTemplatesCreatedWithUserDataContaining(`'node.cilium.io/agent-not-ready' = ['true:NoExecute']`)
+			})
+			It("should merge the AWSNodeTemplate Bottlerocket settings over custom user data", func() {
+				nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+				nodeTemplate.Spec.UserData = aws.String(`
+[settings.host-containers.admin]
+enabled = false
+superpowered = true
+[settings.kernel.sysctl]
+"vm.max_map_count" = "262144"
+[[settings.container-registry.mirrors]]
+registry = "docker.io"
+endpoint = ["https://old.example.com"]
+`)
+				nodeTemplate.Spec.Bottlerocket = &v1alpha1.BottlerocketSettings{
+					HostContainers:      map[string]v1alpha1.BottlerocketHostContainer{"admin": {Enabled: aws.Bool(true)}},
+					BootstrapContainers: map[string]v1alpha1.BottlerocketBootstrapContainer{"setup": {Source: aws.String("example.com/setup:latest"), Mode: aws.String("once")}},
+					KernelSysctl:        map[string]string{"net.core.somaxconn": "1024"},
+					RegistryMirrors:     []v1alpha1.BottlerocketRegistryMirror{{Registry: "docker.io", Endpoints: []string{"https://mirror.example.com"}}},
+				}
+				ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+				awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+					userData, err := base64.StdEncoding.DecodeString(*ltInput.LaunchTemplateData.UserData)
+					Expect(err).To(BeNil())
+					config := &bootstrap.BottlerocketConfig{}
+					Expect(config.UnmarshalTOML(userData)).To(Succeed())
+					Expect(config.Settings.Kubernetes.ClusterName).ToNot(BeNil())
+					Expect(config.SettingsRaw["host-containers"]).To(HaveKeyWithValue("admin", map[string]interface{}{"enabled": true, "superpowered": true}))
+					Expect(config.SettingsRaw["bootstrap-containers"]).To(HaveKeyWithValue("setup", map[string]interface{}{"source": "example.com/setup:latest", "mode": "once"}))
+					Expect(config.SettingsRaw["kernel"]).To(HaveKeyWithValue("sysctl", map[string]interface{}{"vm.max_map_count": "262144", "net.core.somaxconn": "1024"}))
+					Expect(config.SettingsRaw["container-registry"]).To(HaveKeyWithValue("mirrors", ConsistOf(map[string]interface{}{"registry": "docker.io", "endpoint": []interface{}{"https://mirror.example.com"}})))
+				})
+			})
+			It("should pass the AWSNodeTemplate kubelet configuration in the kubernetes settings", func() {
+				nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+				nodeTemplate.Spec.Kubelet = &v1alpha1.KubeletConfiguration{
+					ShutdownGracePeriod:             &metav1.Duration{Duration: time.Minute * 2},
+					ShutdownGracePeriodCriticalPods: &metav1.Duration{Duration: time.Second * 30},
+					RegistryPullQPS:                 aws.Int32(10),
+					RegistryBurst:                   aws.Int32(20),
+				}
+				ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+				awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+					userData, err := base64.StdEncoding.DecodeString(*ltInput.LaunchTemplateData.UserData)
+					Expect(err).To(BeNil())
+					config := &bootstrap.BottlerocketConfig{}
+					Expect(config.UnmarshalTOML(userData)).To(Succeed())
+					Expect(config.Settings.Kubernetes.ShutdownGracePeriod).To(Equal(aws.String("2m0s")))
+					Expect(config.Settings.Kubernetes.ShutdownGracePeriodForCriticalPods).To(Equal(aws.String("30s")))
+					Expect(config.Settings.Kubernetes.RegistryQPS).To(Equal(aws.Int(10)))
+					Expect(config.Settings.Kubernetes.RegistryBurst).To(Equal(aws.Int(20)))
+				})
+			})
+			It("should pass the AWSNodeTemplate CPU, topology and memory manager policies in the kubernetes settings", func() {
+				nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+				nodeTemplate.Spec.Kubelet = &v1alpha1.KubeletConfiguration{
+					CPUManagerPolicy:      aws.String(v1alpha1.CPUManagerPolicyStatic),
+					TopologyManagerPolicy: aws.String(v1alpha1.TopologyManagerPolicyRestricted),
+					MemoryManagerPolicy:   aws.String(v1alpha1.MemoryManagerPolicyStatic),
+				}
+				ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectScheduled(ctx, env.Client, pod)
+				ExpectLaunchTemplatesCreatedWithUserDataContaining(
+					"cpu-manager-policy = 'static'",
+					"topology-manager-policy = 'restricted'",
+					"memory-manager-policy = 'Static'",
+				)
+			})
 			It("should not bootstrap when provider ref points to a non-existent resource", func() {
 				ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
 					EnableENILimitedPodDensity: lo.ToPtr(false),
@@ -1437,25 +1757,132 @@ var _ = Describe("LaunchTemplates", func() {
 				expectedUserData := fmt.Sprintf(string(content), newProvisioner.Name)
 				ExpectLaunchTemplatesCreatedWithUserData(expectedUserData)
 			})
-			It("should merge in custom user data not in multi-part mime format", func() {
-				ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
-					EnableENILimitedPodDensity: lo.ToPtr(false),
-				}))
-
-				content, err := os.ReadFile("testdata/al2_no_mime_userdata_input.golden")
-				Expect(err).To(BeNil())
-				nodeTemplate.Spec.UserData = aws.String(string(content))
+			It("should render the custom user data as a template when userDataTemplating is enabled", func() {
+				nodeTemplate.Spec.UserData = aws.String("#!/bin/bash\necho '{{ .ClusterName }} {{ .ClusterEndpoint }} {{ .NodePool }}'")
+				nodeTemplate.Spec.UserDataTemplating = aws.Bool(true)
 				ExpectApplied(ctx, env.Client, nodeTemplate)
 				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name}})
 				ExpectApplied(ctx, env.Client, newProvisioner)
 				pod := coretest.UnschedulablePod()
 				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
 				ExpectScheduled(ctx, env.Client, pod)
-				content, err = os.ReadFile("testdata/al2_userdata_merged.golden")
-				Expect(err).To(BeNil())
-				expectedUserData := fmt.Sprintf(string(content), newProvisioner.Name)
-				ExpectLaunchTemplatesCreatedWithUserData(expectedUserData)
-			})
+				Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+				awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+					userData, err := base64.StdEncoding.DecodeString(*ltInput.LaunchTemplateData.UserData)
+					Expect(err).To(BeNil())
+					Expect(string(userData)).To(ContainSubstring(fmt.Sprintf("echo '%s https://test-cluster %s'", settings.FromContext(ctx).ClusterName, newProvisioner.Name)))
+				})
+			})
+			It("should not render the custom user data as a template when userDataTemplating isn't enabled", func() {
+				nodeTemplate.Spec.UserData = aws.String("#!/bin/bash\necho '{{ .ClusterName }}'")
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name}})
+				ExpectApplied(ctx, env.Client, newProvisioner)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+				awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+					userData, err := base64.StdEncoding.DecodeString(*ltInput.LaunchTemplateData.UserData)
+					Expect(err).To(BeNil())
+					Expect(string(userData)).To(ContainSubstring("echo '{{ .ClusterName }}'"))
+				})
+			})
+			It("should merge in custom user data read from the Secret that userDataRef references", func() {
+				secret := &v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "userdata", Namespace: "default"},
+					Data:       map[string][]byte{"bootstrap.sh": []byte("#!/bin/bash\necho 'bootstrap secret'")},
+				}
+				ExpectApplied(ctx, env.Client, secret)
+				nodeTemplate.Spec.UserDataRef = &v1alpha1.UserDataReference{Kind: v1alpha1.UserDataRefKindSecret, Name: secret.Name, Key: "bootstrap.sh"}
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name}})
+				ExpectApplied(ctx, env.Client, newProvisioner)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+				awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+					userData, err := base64.StdEncoding.DecodeString(*ltInput.LaunchTemplateData.UserData)
+					Expect(err).To(BeNil())
+					Expect(string(userData)).To(ContainSubstring("echo 'bootstrap secret'"))
+				})
+			})
+			It("should merge in custom user data after the bootstrap script when userDataMergeOrder is PostBootstrap", func() {
+				nodeTemplate.Spec.UserData = aws.String("#!/bin/bash\necho 'post bootstrap'")
+				nodeTemplate.Spec.UserDataMergeOrder = aws.String(v1alpha1.UserDataMergeOrderPostBootstrap)
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name}})
+				ExpectApplied(ctx, env.Client, newProvisioner)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+				awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+					userData, err := base64.StdEncoding.DecodeString(*ltInput.LaunchTemplateData.UserData)
+					Expect(err).To(BeNil())
+					Expect(strings.Index(string(userData), "echo 'post bootstrap'")).To(BeNumerically(">", strings.Index(string(userData), "/etc/eks/bootstrap.sh")))
+				})
+			})
+			It("should gzip compress the merged user data when userDataCompression is Gzip", func() {
+				nodeTemplate.Spec.UserData = aws.String("#!/bin/bash\n" + strings.Repeat("#", v1alpha1.UserDataMaxSize))
+				nodeTemplate.Spec.UserDataCompression = aws.String(v1alpha1.UserDataCompressionGzip)
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name}})
+				ExpectApplied(ctx, env.Client, newProvisioner)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+				awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+					Expect(len(*ltInput.LaunchTemplateData.UserData)).To(BeNumerically("<=", v1alpha1.UserDataMaxSize))
+					compressed, err := base64.StdEncoding.DecodeString(*ltInput.LaunchTemplateData.UserData)
+					Expect(err).To(BeNil())
+					reader, err := gzip.NewReader(bytes.NewReader(compressed))
+					Expect(err).To(BeNil())
+					userData, err := io.ReadAll(reader)
+					Expect(err).To(BeNil())
+					Expect(string(userData)).To(ContainSubstring("/etc/eks/bootstrap.sh"))
+				})
+			})
+			It("should not launch when the merged user data is larger than EC2 allows", func() {
+				nodeTemplate.Spec.UserData = aws.String("#!/bin/bash\n" + strings.Repeat("#", v1alpha1.UserDataMaxSize))
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name}})
+				ExpectApplied(ctx, env.Client, newProvisioner)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectNotScheduled(ctx, env.Client, pod)
+				Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(0))
+			})
+			It("should not launch when the Secret that userDataRef references doesn't exist", func() {
+				nodeTemplate.Spec.UserDataRef = &v1alpha1.UserDataReference{Kind: v1alpha1.UserDataRefKindSecret, Name: "missing", Key: "bootstrap.sh"}
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name}})
+				ExpectApplied(ctx, env.Client, newProvisioner)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectNotScheduled(ctx, env.Client, pod)
+			})
+			It("should merge in custom user data not in multi-part mime format", func() {
+				ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+					EnableENILimitedPodDensity: lo.ToPtr(false),
+				}))
+
+				content, err := os.ReadFile("testdata/al2_no_mime_userdata_input.golden")
+				Expect(err).To(BeNil())
+				nodeTemplate.Spec.UserData = aws.String(string(content))
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name}})
+				ExpectApplied(ctx, env.Client, newProvisioner)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectScheduled(ctx, env.Client, pod)
+				content, err = os.ReadFile("testdata/al2_userdata_merged.golden")
+				Expect(err).To(BeNil())
+				expectedUserData := fmt.Sprintf(string(content), newProvisioner.Name)
+				ExpectLaunchTemplatesCreatedWithUserData(expectedUserData)
+			})
 			It("should handle empty custom user data", func() {
 				ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
 					EnableENILimitedPodDensity: lo.ToPtr(false),
@@ -1515,6 +1942,35 @@ var _ = Describe("LaunchTemplates", func() {
 				ExpectScheduled(ctx, env.Client, pod)
 				ExpectLaunchTemplatesCreatedWithUserData("special user data")
 			})
+			It("should configure the NVIDIA container runtime before the userData when AMIFamily is Custom", func() {
+				nodeTemplate.Spec.UserData = aws.String("#!/bin/bash\necho 'special user data'")
+				nodeTemplate.Spec.AMISelector = map[string]string{"*": "*"}
+				nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyCustom
+				nodeTemplate.Spec.NVIDIA = &v1alpha1.NVIDIAConfiguration{ContainerRuntime: aws.Bool(true)}
+				awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{
+						Name:         aws.String(coretest.RandomName()),
+						ImageId:      aws.String("ami-123"),
+						Architecture: aws.String("x86_64"),
+						CreationDate: aws.String("2022-08-15T12:00:00Z"),
+					},
+				}})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name}})
+				ExpectApplied(ctx, env.Client, newProvisioner)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+				awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+					userData, err := base64.StdEncoding.DecodeString(*ltInput.LaunchTemplateData.UserData)
+					Expect(err).To(BeNil())
+					runtime := strings.Index(string(userData), "nvidia-ctk runtime configure --runtime=containerd --set-as-default")
+					custom := strings.Index(string(userData), "echo 'special user data'")
+					Expect(runtime).To(BeNumerically(">=", 0))
+					Expect(custom).To(BeNumerically(">", runtime))
+				})
+			})
 			It("should correctly use ami selector with specific IDs in AWSNodeTemplate", func() {
 				nodeTemplate.Spec.AMISelector = map[string]string{"aws-ids": "ami-123,ami-456"}
 				awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
@@ -1634,6 +2090,98 @@ var _ = Describe("LaunchTemplates", func() {
 					Expect("ami-456").To(Equal(*ltInput.LaunchTemplateData.ImageId))
 				})
 			})
+			It("should not launch an AMI that requires NitroTPM on instance types that don't support it", func() {
+				awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{
+						Name:         aws.String(coretest.RandomName()),
+						ImageId:      aws.String("ami-123"),
+						Architecture: aws.String("x86_64"),
+						BootMode:     aws.String(ec2.BootModeValuesLegacyBios),
+						CreationDate: aws.String("2020-01-01T12:00:00Z"),
+					},
+					{
+						Name:         aws.String(coretest.RandomName()),
+						ImageId:      aws.String("ami-456"),
+						Architecture: aws.String("x86_64"),
+						BootMode:     aws.String(ec2.BootModeValuesUefi),
+						TpmSupport:   aws.String(ec2.TpmSupportValuesV20),
+						CreationDate: aws.String("2021-01-01T12:00:00Z"),
+					},
+				}})
+				nodeTemplate.Spec.AMISelector = map[string]string{"*": "*"}
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{
+					ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+					Requirements: []v1.NodeSelectorRequirement{
+						{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.metal"}},
+					},
+				})
+				ExpectApplied(ctx, env.Client, newProvisioner)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+				awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+					Expect(*ltInput.LaunchTemplateData.ImageId).To(Equal("ami-123"))
+				})
+			})
+			It("should launch a UEFI AMI on instance types that support UEFI", func() {
+				awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{
+						Name:         aws.String(coretest.RandomName()),
+						ImageId:      aws.String("ami-123"),
+						Architecture: aws.String("x86_64"),
+						BootMode:     aws.String(ec2.BootModeValuesUefi),
+						CreationDate: aws.String("2020-01-01T12:00:00Z"),
+					},
+				}})
+				nodeTemplate.Spec.AMISelector = map[string]string{"*": "*"}
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{
+					ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+					Requirements: []v1.NodeSelectorRequirement{
+						{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.metal", "p3.8xlarge"}},
+					},
+				})
+				ExpectApplied(ctx, env.Client, newProvisioner)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectScheduled(ctx, env.Client, pod)
+				createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+				instanceTypes := sets.NewString()
+				for _, ltConfig := range createFleetInput.LaunchTemplateConfigs {
+					for _, override := range ltConfig.Overrides {
+						instanceTypes.Insert(*override.InstanceType)
+					}
+				}
+				// p3.8xlarge only supports the legacy BIOS boot mode
+				Expect(instanceTypes.List()).To(ConsistOf("m5.metal"))
+			})
+			It("should fail if no instance type can boot the amis", func() {
+				awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{
+						Name:         aws.String(coretest.RandomName()),
+						ImageId:      aws.String("ami-123"),
+						Architecture: aws.String("x86_64"),
+						BootMode:     aws.String(ec2.BootModeValuesUefi),
+						TpmSupport:   aws.String(ec2.TpmSupportValuesV20),
+						CreationDate: aws.String("2020-01-01T12:00:00Z"),
+					},
+				}})
+				nodeTemplate.Spec.AMISelector = map[string]string{"*": "*"}
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{
+					ProviderRef: &v1alpha5.MachineTemplateRef{Name: nodeTemplate.Name},
+					Requirements: []v1.NodeSelectorRequirement{
+						{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.metal", "p3.8xlarge"}},
+					},
+				})
+				ExpectApplied(ctx, env.Client, newProvisioner)
+				pod := coretest.UnschedulablePod()
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectNotScheduled(ctx, env.Client, pod)
+				Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(0))
+			})
 
 			It("should fail if no amis match selector.", func() {
 				awsEnv.EC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{}})
@@ -1776,6 +2324,151 @@ var _ = Describe("LaunchTemplates", func() {
 				Expect(err).To(BeNil())
 				ExpectLaunchTemplatesCreatedWithUserData(fmt.Sprintf(string(content), provisioner.Name))
 			})
+			It("should set up the CSI proxy and gMSA after the custom user data and before bootstrapping", func() {
+				nodeTemplate.Spec.UserData = aws.String("Write-Host 'special user data'")
+				nodeTemplate.Spec.Windows = &v1alpha1.WindowsConfiguration{
+					CSIProxy: &v1alpha1.WindowsCSIProxy{BinaryURL: aws.String("https://example.com/csi-proxy.exe")},
+					GMSA:     &v1alpha1.WindowsGMSA{PluginCLSID: "01234567-89ab-cdef-0123-456789abcdef"},
+				}
+				ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+				pod := coretest.UnschedulablePod(coretest.PodOptions{
+					NodeSelector: map[string]string{
+						v1.LabelOSStable:     string(v1.Windows),
+						v1.LabelWindowsBuild: "10.0.20348",
+					},
+				})
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+				awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+					userData, err := base64.StdEncoding.DecodeString(*ltInput.LaunchTemplateData.UserData)
+					Expect(err).To(BeNil())
+					custom := strings.Index(string(userData), "Write-Host 'special user data'")
+					download := strings.Index(string(userData), "Invoke-WebRequest -UseBasicParsing -Uri 'https://example.com/csi-proxy.exe' -OutFile $CSIProxyBinaryFile")
+					service := strings.Index(string(userData), `New-Service -Name csiproxy -BinaryPathName "$CSIProxyBinaryFile -windows-service" -StartupType Automatic`)
+					gmsa := strings.Index(string(userData), `CCG\COMClasses\{01234567-89ab-cdef-0123-456789abcdef}`)
+					bootstrap := strings.Index(string(userData), "& $EKSBootstrapScriptFile")
+					Expect(custom).To(BeNumerically(">=", 0))
+					Expect(download).To(BeNumerically(">", custom))
+					Expect(service).To(BeNumerically(">", download))
+					Expect(gmsa).To(BeNumerically(">", service))
+					Expect(bootstrap).To(BeNumerically(">", gmsa))
+				})
+			})
+			It("should use the CSI proxy binary in the AMI when no binaryURL is set", func() {
+				nodeTemplate.Spec.Windows = &v1alpha1.WindowsConfiguration{CSIProxy: &v1alpha1.WindowsCSIProxy{}}
+				ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+				pod := coretest.UnschedulablePod(coretest.PodOptions{
+					NodeSelector: map[string]string{
+						v1.LabelOSStable:     string(v1.Windows),
+						v1.LabelWindowsBuild: "10.0.20348",
+					},
+				})
+				ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+				awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+					userData, err := base64.StdEncoding.DecodeString(*ltInput.LaunchTemplateData.UserData)
+					Expect(err).To(BeNil())
+					Expect(string(userData)).To(ContainSubstring("Start-Service -Name csiproxy"))
+					Expect(string(userData)).ToNot(ContainSubstring("Invoke-WebRequest"))
+					Expect(string(userData)).ToNot(ContainSubstring("COMClasses"))
+				})
+			})
+		})
+	})
+	Context("Cluster Endpoint and CA Bundle", func() {
+		var eksapi *fake.EKSAPI
+		var controller *launchtemplate.Controller
+		BeforeEach(func() {
+			eksapi = &fake.EKSAPI{}
+			controller = launchtemplate.NewController(env.Client, eksapi, awsEnv.LaunchTemplateProvider)
+			s := test.Settings(test.SettingOptions{ClusterEndpoint: lo.ToPtr("")})
+			s.ClusterCABundle = "ca-bundle"
+			ctx = settings.ToContext(ctx, s)
+		})
+		It("should resolve the cluster endpoint from the settings", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{ClusterEndpoint: lo.ToPtr("https://api.test-cluster.k8s.local")}))
+			endpoint, err := launchtemplate.ResolveClusterEndpoint(ctx, eksapi)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(endpoint).To(Equal("https://api.test-cluster.k8s.local"))
+		})
+		It("should resolve the cluster endpoint with EKS DescribeCluster if it isn't set", func() {
+			eksapi.DescribeClusterBehaviour.Output.Set(&eks.DescribeClusterOutput{
+				Cluster: &eks.Cluster{Endpoint: lo.ToPtr("https://cluster-endpoint.test-cluster.k8s.local")},
+			})
+			endpoint, err := launchtemplate.ResolveClusterEndpoint(ctx, eksapi)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(endpoint).To(Equal("https://cluster-endpoint.test-cluster.k8s.local"))
+		})
+		It("should propagate the error if EKS DescribeCluster fails", func() {
+			eksapi.DescribeClusterBehaviour.Error.Set(fmt.Errorf("test error"))
+			_, err := launchtemplate.ResolveClusterEndpoint(ctx, eksapi)
+			Expect(err).To(HaveOccurred())
+		})
+		It("should bootstrap nodes with the refreshed cluster endpoint", func() {
+			eksapi.DescribeClusterBehaviour.Output.Set(&eks.DescribeClusterOutput{
+				Cluster: &eks.Cluster{Endpoint: lo.ToPtr("https://new-endpoint.test-cluster.k8s.local")},
+			})
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataContaining("--apiserver-endpoint 'https://new-endpoint.test-cluster.k8s.local'")
+		})
+		It("should bootstrap nodes with the refreshed CA bundle", func() {
+			eksapi.DescribeClusterBehaviour.Output.Set(&eks.DescribeClusterOutput{
+				Cluster: &eks.Cluster{Endpoint: lo.ToPtr("https://test-cluster")},
+			})
+			s := test.Settings(test.SettingOptions{ClusterEndpoint: lo.ToPtr("")})
+			s.ClusterCABundle = "new-ca-bundle"
+			ctx = settings.ToContext(ctx, s)
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataContaining("--b64-cluster-ca 'new-ca-bundle'")
+		})
+		It("should bootstrap nodes with the IP of a custom cluster dns service", func() {
+			eksapi.DescribeClusterBehaviour.Output.Set(&eks.DescribeClusterOutput{
+				Cluster: &eks.Cluster{Endpoint: lo.ToPtr("https://test-cluster")},
+			})
+			s := test.Settings(test.SettingOptions{
+				ClusterEndpoint:            lo.ToPtr(""),
+				ClusterDNSServiceName:      lo.ToPtr("coredns"),
+				ClusterDNSServiceNamespace: lo.ToPtr("kube-system"),
+			})
+			s.ClusterCABundle = "ca-bundle"
+			ctx = settings.ToContext(ctx, s)
+			dnsService := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+				Spec: v1.ServiceSpec{
+					ClusterIP: "10.0.0.53",
+					Ports:     []v1.ServicePort{{Name: "dns", Port: 53, Protocol: v1.ProtocolUDP}},
+				},
+			}
+			ExpectApplied(ctx, env.Client, dnsService)
+			DeferCleanup(func() { ExpectDeleted(ctx, env.Client, dnsService) })
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			ExpectLaunchTemplatesCreatedWithUserDataContaining("--dns-cluster-ip '10.0.0.53'")
+		})
+		It("should keep the cluster dns ip if the cluster dns service doesn't exist", func() {
+			eksapi.DescribeClusterBehaviour.Output.Set(&eks.DescribeClusterOutput{
+				Cluster: &eks.Cluster{Endpoint: lo.ToPtr("https://test-cluster")},
+			})
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(awsEnv.LaunchTemplateProvider.KubeDNSIP.String()).To(Equal("10.0.100.10"))
+		})
+		It("should fail to reconcile if the cluster endpoint can't be resolved", func() {
+			eksapi.DescribeClusterBehaviour.Error.Set(fmt.Errorf("test error"))
+			ExpectReconcileFailed(ctx, controller, types.NamespacedName{})
+			Expect(awsEnv.LaunchTemplateProvider.ClusterEndpoint).To(Equal("https://test-cluster"))
 		})
 	})
 	Context("Detailed Monitoring", func() {
@@ -1803,6 +2496,115 @@ var _ = Describe("LaunchTemplates", func() {
 			})
 		})
 	})
+	Context("CPU Credit Specification", func() {
+		var pod *v1.Pod
+		BeforeEach(func() {
+			pod = coretest.UnschedulablePod(coretest.PodOptions{
+				NodeRequirements: []v1.NodeSelectorRequirement{{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"t3.large", "m5.large"}}},
+			})
+		})
+		It("should not set a credit specification by default", func() {
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				Expect(ltInput.LaunchTemplateData.CreditSpecification).To(BeNil())
+			})
+		})
+		It("should set the credit specification only on launch templates of burstable instance types", func() {
+			nodeTemplate.Spec.CPUCreditSpecification = lo.ToPtr(v1alpha1.CPUCreditSpecificationUnlimited)
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			cpuCredits := map[string]*string{}
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				cpuCredits[*ltInput.LaunchTemplateName] = nil
+				if ltInput.LaunchTemplateData.CreditSpecification != nil {
+					cpuCredits[*ltInput.LaunchTemplateName] = ltInput.LaunchTemplateData.CreditSpecification.CpuCredits
+				}
+			})
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			instanceTypes := sets.NewString()
+			for _, ltConfig := range createFleetInput.LaunchTemplateConfigs {
+				credits, ok := cpuCredits[*ltConfig.LaunchTemplateSpecification.LaunchTemplateName]
+				Expect(ok).To(BeTrue())
+				for _, override := range ltConfig.Overrides {
+					instanceTypes.Insert(*override.InstanceType)
+					if *override.InstanceType == "t3.large" {
+						Expect(aws.StringValue(credits)).To(Equal(v1alpha1.CPUCreditSpecificationUnlimited))
+					} else {
+						Expect(credits).To(BeNil())
+					}
+				}
+			}
+			Expect(instanceTypes.List()).To(ConsistOf("t3.large", "m5.large"))
+		})
+	})
+	Context("Enclave and CPU Options", func() {
+		It("should not set enclave or CPU options by default", func() {
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				Expect(ltInput.LaunchTemplateData.EnclaveOptions).To(BeNil())
+				Expect(ltInput.LaunchTemplateData.CpuOptions).To(BeNil())
+			})
+		})
+		It("should enable Nitro Enclaves and only launch instance types that support them", func() {
+			nodeTemplate.Spec.EnclaveOptions = &v1alpha1.EnclaveOptions{Enabled: aws.Bool(true)}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				Expect(aws.BoolValue(ltInput.LaunchTemplateData.EnclaveOptions.Enabled)).To(BeTrue())
+			})
+			createFleetInput := awsEnv.EC2API.CreateFleetBehavior.CalledWithInput.Pop()
+			for _, ltConfig := range createFleetInput.LaunchTemplateConfigs {
+				for _, override := range ltConfig.Overrides {
+					Expect(aws.StringValue(override.InstanceType)).To(BeElementOf("m5.xlarge", "m6idn.32xlarge"))
+				}
+			}
+		})
+		It("should pass the AMD SEV-SNP option to the launch template", func() {
+			nodeTemplate.Spec.CPUOptions = &v1alpha1.CPUOptions{AMDSEVSNP: aws.String(v1alpha1.AMDSEVSNPDisabled)}
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				Expect(aws.StringValue(ltInput.LaunchTemplateData.CpuOptions.AmdSevSnp)).To(Equal(v1alpha1.AMDSEVSNPDisabled))
+			})
+		})
+	})
+	Context("Instance Initiated Shutdown Behavior", func() {
+		It("should not set the shutdown behavior by default", func() {
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				Expect(ltInput.LaunchTemplateData.InstanceInitiatedShutdownBehavior).To(BeNil())
+			})
+		})
+		It("should pass the shutdown behavior to the launch template", func() {
+			nodeTemplate.Spec.InstanceInitiatedShutdownBehavior = aws.String(v1alpha1.InstanceInitiatedShutdownBehaviorTerminate)
+			ExpectApplied(ctx, env.Client, provisioner, nodeTemplate)
+			pod := coretest.UnschedulablePod()
+			ExpectProvisioned(ctx, env.Client, cluster, cloudProvider, prov, pod)
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			awsEnv.EC2API.CalledWithCreateLaunchTemplateInput.ForEach(func(ltInput *ec2.CreateLaunchTemplateInput) {
+				Expect(aws.StringValue(ltInput.LaunchTemplateData.InstanceInitiatedShutdownBehavior)).To(Equal(v1alpha1.InstanceInitiatedShutdownBehaviorTerminate))
+			})
+		})
+	})
 })
 
 // ExpectTags verifies that the expected tags are a subset of the tags found
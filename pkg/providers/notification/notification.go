@@ -0,0 +1,134 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/patrickmn/go-cache"
+	"go.uber.org/multierr"
+
+	"github.com/aws/karpenter/pkg/apis/settings"
+)
+
+// Actions that are taken on the instance after the notification
+const (
+	ActionTerminate = "Terminate"
+	ActionStop      = "Stop"
+)
+
+// PreTermination is published before Karpenter terminates or stops an instance
+type PreTermination struct {
+	InstanceID string    `json:"instanceID"`
+	NodeName   string    `json:"nodeName,omitempty"`
+	NodeClaim  string    `json:"nodeClaim,omitempty"`
+	NodePool   string    `json:"nodePool,omitempty"`
+	Reason     string    `json:"reason"`
+	Action     string    `json:"action"`
+	Time       time.Time `json:"time"`
+}
+
+type Provider struct {
+	snsapi     snsiface.SNSAPI
+	httpClient *http.Client
+	// cache holds the instances that have been notified to each target, since the termination of an instance is
+	// retried until it's terminated
+	cache *cache.Cache
+}
+
+func NewProvider(snsapi snsiface.SNSAPI, httpClient *http.Client, cache *cache.Cache) *Provider {
+	return &Provider{
+		snsapi:     snsapi,
+		httpClient: httpClient,
+		cache:      cache,
+	}
+}
+
+// Enabled returns whether pre-termination notifications are published to any target
+func Enabled(ctx context.Context) bool {
+	return settings.FromContext(ctx).PreTerminationTopicARN != "" || settings.FromContext(ctx).PreTerminationWebhookURL != ""
+}
+
+// Notify publishes the notification to the SNS topic and the webhook that are configured in settings. An instance is
+// only notified to each target once.
+func (p *Provider) Notify(ctx context.Context, notification PreTermination) error {
+	raw, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("marshaling notification, %w", err)
+	}
+	var errs error
+	if topicARN := settings.FromContext(ctx).PreTerminationTopicARN; topicARN != "" {
+		errs = multierr.Append(errs, p.notifyOnce(topicARN, notification.InstanceID, func() error {
+			return p.publish(ctx, topicARN, notification, raw)
+		}))
+	}
+	if webhookURL := settings.FromContext(ctx).PreTerminationWebhookURL; webhookURL != "" {
+		errs = multierr.Append(errs, p.notifyOnce(webhookURL, notification.InstanceID, func() error {
+			return p.post(ctx, webhookURL, raw)
+		}))
+	}
+	return errs
+}
+
+func (p *Provider) notifyOnce(target, instanceID string, notify func() error) error {
+	key := fmt.Sprintf("%s/%s", target, instanceID)
+	if _, ok := p.cache.Get(key); ok {
+		return nil
+	}
+	if err := notify(); err != nil {
+		return err
+	}
+	p.cache.SetDefault(key, struct{}{})
+	return nil
+}
+
+func (p *Provider) publish(ctx context.Context, topicARN string, notification PreTermination, raw []byte) error {
+	if _, err := p.snsapi.PublishWithContext(ctx, &sns.PublishInput{
+		TopicArn: aws.String(topicARN),
+		Message:  aws.String(string(raw)),
+		// Subscriptions can filter notifications by their reason
+		MessageAttributes: map[string]*sns.MessageAttributeValue{
+			"reason": {DataType: aws.String("String"), StringValue: aws.String(notification.Reason)},
+		},
+	}); err != nil {
+		return fmt.Errorf("publishing to sns topic, %w", err)
+	}
+	return nil
+}
+
+func (p *Provider) post(ctx context.Context, webhookURL string, raw []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(raw))
+	if err != nil {
+		return fmt.Errorf("creating webhook request, %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("calling webhook, %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("calling webhook, received status %d", resp.StatusCode)
+	}
+	return nil
+}
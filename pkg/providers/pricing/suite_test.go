@@ -91,7 +91,7 @@ var _ = Describe("Pricing", func() {
 		Expect(price).To(BeNumerically(">", 0))
 	})
 	It("should return static spot data if EC2 describeSpotPriceHistory API fails", func() {
-		awsEnv.PricingAPI.NextError.Set(fmt.Errorf("failed"))
+		awsEnv.EC2API.DescribeSpotPriceHistoryError.Set(fmt.Errorf("failed"))
 		ExpectReconcileFailed(ctx, controller, types.NamespacedName{})
 		price, ok := awsEnv.PricingProvider.SpotPrice("c5.large", "test-zone-1a")
 		Expect(ok).To(BeTrue())
@@ -231,6 +231,46 @@ var _ = Describe("Pricing", func() {
 		_, ok := awsEnv.PricingProvider.SpotPrice("c99.large", "test-zone-1b")
 		Expect(ok).To(BeFalse())
 	})
+	It("should update spot pricing with the prices in effect from the spot price history", func() {
+		now := time.Now()
+		awsEnv.EC2API.SpotPriceHistory.Add(fake.NewSpotPrice("c99.large", "test-zone-1a", 1.00, now.Add(-2*time.Hour)))
+		awsEnv.EC2API.SpotPriceHistory.Add(fake.NewSpotPrice("c99.large", "test-zone-1a", 1.23, now.Add(-time.Hour)))
+		awsEnv.EC2API.SpotPriceHistory.Add(fake.NewSpotPrice("c99.large", "test-zone-1a", 2.00, now.Add(time.Hour)))
+		awsEnv.EC2API.SpotPriceHistory.Add(fake.NewSpotPrice("c99.large", "test-zone-1b", 1.50, now.Add(-3*time.Hour)))
+		awsEnv.PricingAPI.GetProductsOutput.Set(fake.NewOnDemandPrices(map[string]float64{"c98.large": 1.20, "c99.large": 1.23}))
+		updateStart := time.Now()
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Eventually(func() bool { return awsEnv.PricingProvider.SpotLastUpdated().After(updateStart) }).Should(BeTrue())
+
+		price, ok := awsEnv.PricingProvider.SpotPrice("c99.large", "test-zone-1a")
+		Expect(ok).To(BeTrue())
+		Expect(price).To(BeNumerically("==", 1.23))
+		price, ok = awsEnv.PricingProvider.SpotPrice("c99.large", "test-zone-1b")
+		Expect(ok).To(BeTrue())
+		Expect(price).To(BeNumerically("==", 1.50))
+		price, ok = awsEnv.PricingProvider.OnDemandPrice("c98.large")
+		Expect(ok).To(BeTrue())
+		Expect(price).To(BeNumerically("==", 1.20))
+	})
+	It("should keep the last spot prices if the EC2 describeSpotPriceHistory API fails", func() {
+		now := time.Now()
+		awsEnv.EC2API.SpotPriceHistory.Add(fake.NewSpotPrice("c99.large", "test-zone-1a", 1.23, now.Add(-time.Hour)))
+		awsEnv.PricingAPI.GetProductsOutput.Set(fake.NewOnDemandPrices(map[string]float64{"c98.large": 1.20, "c99.large": 1.23}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+
+		awsEnv.EC2API.SpotPriceHistory.Add(fake.NewSpotPrice("c99.large", "test-zone-1a", 0.50, time.Now()))
+		awsEnv.EC2API.DescribeSpotPriceHistoryError.Set(fmt.Errorf("failed"), fake.MaxCalls(0))
+		ExpectReconcileFailed(ctx, controller, types.NamespacedName{})
+		price, ok := awsEnv.PricingProvider.SpotPrice("c99.large", "test-zone-1a")
+		Expect(ok).To(BeTrue())
+		Expect(price).To(BeNumerically("==", 1.23))
+
+		awsEnv.EC2API.DescribeSpotPriceHistoryError.Reset()
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		price, ok = awsEnv.PricingProvider.SpotPrice("c99.large", "test-zone-1a")
+		Expect(ok).To(BeTrue())
+		Expect(price).To(BeNumerically("==", 0.50))
+	})
 	It("should query for both `Linux/UNIX` and `Linux/UNIX (Amazon VPC)`", func() {
 		// If an account supports EC2 classic, then the non-classic instance types have a product
 		// description of Linux/UNIX (Amazon VPC)
@@ -0,0 +1,59 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/aws/karpenter-core/pkg/metrics"
+)
+
+const (
+	cloudProviderSubsystem = "cloudprovider"
+
+	quotaCodeLabel    = "quota_code"
+	capacityTypeLabel = "capacity_type"
+	nodePoolLabel     = "nodepool"
+)
+
+var (
+	VCPUHeadroom = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "quota_vcpu_headroom",
+			Help:      "Number of vCPUs that can still be launched before reaching the EC2 service quota, labeled by quota code and capacity type.",
+		},
+		[]string{
+			quotaCodeLabel,
+			capacityTypeLabel,
+		})
+	QuotaConstrainedLaunches = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "quota_constrained_launches_total",
+			Help:      "Number of launches that were blocked because every candidate instance type would exceed an EC2 service quota, labeled by nodepool and capacity type.",
+		},
+		[]string{
+			nodePoolLabel,
+			capacityTypeLabel,
+		})
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(VCPUHeadroom, QuotaConstrainedLaunches)
+}
@@ -0,0 +1,267 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/servicequotas/servicequotasiface"
+	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/lo"
+	"knative.dev/pkg/logging"
+
+	"github.com/aws/karpenter-core/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/utils/pretty"
+)
+
+const (
+	serviceCode = "ec2"
+
+	quotasKey = "quotas"
+	usageKey  = "usage"
+)
+
+// Bucket is the group of instance families that share a single vCPU quota
+type Bucket string
+
+const (
+	BucketStandard  Bucket = "standard"
+	BucketG         Bucket = "g"
+	BucketP         Bucket = "p"
+	BucketX         Bucket = "x"
+	BucketF         Bucket = "f"
+	BucketInf       Bucket = "inf"
+	BucketTrn       Bucket = "trn"
+	BucketDL        Bucket = "dl"
+	BucketHPC       Bucket = "hpc"
+	BucketUntracked Bucket = ""
+)
+
+// QuotaCodes maps each capacity type and bucket to the Service Quotas code that limits the running vCPUs of that bucket
+var QuotaCodes = map[string]map[Bucket]string{
+	v1alpha5.CapacityTypeOnDemand: {
+		BucketStandard: "L-1216C47A",
+		BucketG:        "L-DB2E81BA",
+		BucketP:        "L-417A185B",
+		BucketX:        "L-7295265B",
+		BucketF:        "L-74FC7D96",
+		BucketInf:      "L-1945791B",
+		BucketTrn:      "L-2C3B7624",
+		BucketDL:       "L-6E869C2A",
+		BucketHPC:      "L-F7808C92",
+	},
+	v1alpha5.CapacityTypeSpot: {
+		BucketStandard: "L-34B43A08",
+		BucketG:        "L-3819A6DF",
+		BucketP:        "L-7212CCBC",
+		BucketX:        "L-E3A00192",
+		BucketF:        "L-88CF9481",
+		BucketInf:      "L-B5D1601B",
+		BucketTrn:      "L-6B0D517C",
+		BucketDL:       "L-85EED4F7",
+	},
+}
+
+// Provider tracks the account-level vCPU quotas for EC2 and the vCPUs that are currently consumed against them so that
+// launches can be steered away from capacity types and families that would exceed the quota. Quotas that can't be
+// resolved are treated as unlimited so that a missing servicequotas permission never blocks provisioning.
+type Provider struct {
+	sync.Mutex
+	servicequotas servicequotasiface.ServiceQuotasAPI
+	ec2api        ec2iface.EC2API
+	cache         *cache.Cache
+	cm            *pretty.ChangeMonitor
+	// inflightVCPUs is used to track vCPUs from known launched instances that may not be reflected in usage yet
+	inflightVCPUs map[string]int64
+}
+
+func NewProvider(servicequotas servicequotasiface.ServiceQuotasAPI, ec2api ec2iface.EC2API, cache *cache.Cache) *Provider {
+	return &Provider{
+		servicequotas: servicequotas,
+		ec2api:        ec2api,
+		cache:         cache,
+		cm:            pretty.NewChangeMonitor(),
+		inflightVCPUs: map[string]int64{},
+	}
+}
+
+// Headroom returns the number of vCPUs that can still be launched for the capacity type and instance type before the
+// account quota is reached. The boolean is false when the instance type isn't limited by a known quota.
+func (p *Provider) Headroom(ctx context.Context, capacityType string, instanceType string) (int64, bool) {
+	p.Lock()
+	defer p.Unlock()
+	code, ok := QuotaCodes[capacityType][BucketFor(instanceType)]
+	if !ok {
+		return 0, false
+	}
+	quotas := p.getQuotas(ctx)
+	limit, ok := quotas[code]
+	if !ok {
+		return 0, false
+	}
+	usage, err := p.getUsage(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("getting vCPU usage, %s", err)
+		return 0, false
+	}
+	headroom := lo.Max([]int64{limit - usage[code] - p.inflightVCPUs[code], 0})
+	VCPUHeadroom.With(prometheus.Labels{quotaCodeLabel: code, capacityTypeLabel: capacityType}).Set(float64(headroom))
+	return headroom, true
+}
+
+// Filter removes the offerings of each instance type whose capacity type doesn't have enough quota headroom left to
+// launch a single instance. Instance types without any remaining offerings are dropped entirely.
+func (p *Provider) Filter(ctx context.Context, nodePoolName string, instanceTypes []*cloudprovider.InstanceType) []*cloudprovider.InstanceType {
+	var result []*cloudprovider.InstanceType
+	constrained := map[string]struct{}{}
+	for _, it := range instanceTypes {
+		vcpus := it.Capacity.Cpu().Value()
+		offerings := lo.Filter(it.Offerings, func(o cloudprovider.Offering, _ int) bool {
+			headroom, ok := p.Headroom(ctx, o.CapacityType, it.Name)
+			if ok && headroom < vcpus {
+				constrained[o.CapacityType] = struct{}{}
+				return false
+			}
+			return true
+		})
+		if len(offerings) == 0 {
+			continue
+		}
+		if len(offerings) == len(it.Offerings) {
+			result = append(result, it)
+			continue
+		}
+		result = append(result, &cloudprovider.InstanceType{
+			Name:         it.Name,
+			Requirements: it.Requirements,
+			Offerings:    offerings,
+			Capacity:     it.Capacity,
+			Overhead:     it.Overhead,
+		})
+	}
+	if len(result) == 0 {
+		for capacityType := range constrained {
+			QuotaConstrainedLaunches.With(prometheus.Labels{nodePoolLabel: nodePoolName, capacityTypeLabel: capacityType}).Inc()
+		}
+	}
+	return result
+}
+
+// UpdateInflightVCPUs accounts for the vCPUs of a newly launched instance until usage is next refreshed from EC2
+func (p *Provider) UpdateInflightVCPUs(capacityType string, instanceType *cloudprovider.InstanceType) {
+	p.Lock()
+	defer p.Unlock()
+	if code, ok := QuotaCodes[capacityType][BucketFor(instanceType.Name)]; ok {
+		p.inflightVCPUs[code] += instanceType.Capacity.Cpu().Value()
+	}
+}
+
+func (p *Provider) Reset() {
+	p.Lock()
+	defer p.Unlock()
+	p.cache.Flush()
+	p.inflightVCPUs = map[string]int64{}
+}
+
+func (p *Provider) getQuotas(ctx context.Context) map[string]int64 {
+	if quotas, ok := p.cache.Get(quotasKey); ok {
+		return quotas.(map[string]int64)
+	}
+	codes := lo.SliceToMap(lo.Flatten(lo.Map(lo.Values(QuotaCodes), func(m map[Bucket]string, _ int) []string { return lo.Values(m) })),
+		func(c string) (string, struct{}) { return c, struct{}{} })
+	quotas := map[string]int64{}
+	if err := p.servicequotas.ListServiceQuotasPagesWithContext(ctx, &servicequotas.ListServiceQuotasInput{
+		ServiceCode: aws.String(serviceCode),
+	}, func(output *servicequotas.ListServiceQuotasOutput, _ bool) bool {
+		for _, q := range output.Quotas {
+			if _, ok := codes[aws.StringValue(q.QuotaCode)]; ok && q.Value != nil {
+				quotas[aws.StringValue(q.QuotaCode)] = int64(aws.Float64Value(q.Value))
+			}
+		}
+		return true
+	}); err != nil {
+		// Cache the empty result so that we don't hammer the API when we lack permissions, quotas are treated as unlimited
+		logging.FromContext(ctx).Errorf("listing service quotas, %s", err)
+	}
+	p.cache.SetDefault(quotasKey, quotas)
+	if p.cm.HasChanged(quotasKey, quotas) {
+		logging.FromContext(ctx).With("quotas", quotas).Debugf("discovered vCPU quotas")
+	}
+	return quotas
+}
+
+func (p *Provider) getUsage(ctx context.Context) (map[string]int64, error) {
+	if usage, ok := p.cache.Get(usageKey); ok {
+		return usage.(map[string]int64), nil
+	}
+	usage := map[string]int64{}
+	if err := p.ec2api.DescribeInstancesPagesWithContext(ctx, &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("instance-state-name"),
+			Values: aws.StringSlice([]string{ec2.InstanceStateNamePending, ec2.InstanceStateNameRunning}),
+		}},
+	}, func(output *ec2.DescribeInstancesOutput, _ bool) bool {
+		for _, reservation := range output.Reservations {
+			for _, instance := range reservation.Instances {
+				capacityType := lo.Ternary(aws.StringValue(instance.InstanceLifecycle) == ec2.InstanceLifecycleTypeSpot,
+					v1alpha5.CapacityTypeSpot, v1alpha5.CapacityTypeOnDemand)
+				if code, ok := QuotaCodes[capacityType][BucketFor(aws.StringValue(instance.InstanceType))]; ok && instance.CpuOptions != nil {
+					usage[code] += aws.Int64Value(instance.CpuOptions.CoreCount) * aws.Int64Value(instance.CpuOptions.ThreadsPerCore)
+				}
+			}
+		}
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("describing instances, %w", err)
+	}
+	p.cache.SetDefault(usageKey, usage)
+	// remove any previously tracked vCPUs since we just refreshed from EC2
+	p.inflightVCPUs = map[string]int64{}
+	return usage, nil
+}
+
+// BucketFor returns the quota bucket that the instance type's family is accounted against
+func BucketFor(instanceType string) Bucket {
+	family := strings.Split(instanceType, ".")[0]
+	for _, prefix := range []Bucket{BucketInf, BucketTrn, BucketDL, BucketHPC} {
+		if strings.HasPrefix(family, string(prefix)) {
+			return prefix
+		}
+	}
+	switch {
+	case strings.HasPrefix(family, "mac"), strings.HasPrefix(family, "u-"), family == "":
+		return BucketUntracked
+	case strings.HasPrefix(family, "vt"), strings.HasPrefix(family, "g"):
+		return BucketG
+	case strings.HasPrefix(family, "p"):
+		return BucketP
+	case strings.HasPrefix(family, "x"):
+		return BucketX
+	case strings.HasPrefix(family, "f"):
+		return BucketF
+	case strings.ContainsAny(family[:1], "acdhimrtz"):
+		return BucketStandard
+	}
+	return BucketUntracked
+}
@@ -0,0 +1,175 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	. "knative.dev/pkg/logging/testing"
+
+	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	corefake "github.com/aws/karpenter-core/pkg/cloudprovider/fake"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	awscache "github.com/aws/karpenter/pkg/cache"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/providers/quota"
+)
+
+var ctx context.Context
+var ec2api *fake.EC2API
+var servicequotasapi *fake.ServiceQuotasAPI
+var quotaProvider *quota.Provider
+
+func TestAWS(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Provider/Quota")
+}
+
+var _ = BeforeSuite(func() {
+	ec2api = &fake.EC2API{}
+	servicequotasapi = &fake.ServiceQuotasAPI{}
+	quotaProvider = quota.NewProvider(servicequotasapi, ec2api, cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval))
+})
+
+var _ = BeforeEach(func() {
+	ec2api.Reset()
+	servicequotasapi.Reset()
+	quotaProvider.Reset()
+})
+
+var _ = Describe("Quota", func() {
+	Context("Buckets", func() {
+		DescribeTable("should map instance families to quota buckets",
+			func(instanceType string, expected quota.Bucket) {
+				Expect(quota.BucketFor(instanceType)).To(Equal(expected))
+			},
+			Entry("general purpose", "m5.large", quota.BucketStandard),
+			Entry("burstable", "t3.micro", quota.BucketStandard),
+			Entry("storage optimized", "i4i.xlarge", quota.BucketStandard),
+			Entry("gpu", "g5.xlarge", quota.BucketG),
+			Entry("video transcoding", "vt1.3xlarge", quota.BucketG),
+			Entry("accelerated", "p4d.24xlarge", quota.BucketP),
+			Entry("memory optimized", "x2idn.16xlarge", quota.BucketX),
+			Entry("fpga", "f1.2xlarge", quota.BucketF),
+			Entry("inferentia", "inf2.xlarge", quota.BucketInf),
+			Entry("trainium", "trn1.2xlarge", quota.BucketTrn),
+			Entry("habana", "dl1.24xlarge", quota.BucketDL),
+			Entry("hpc", "hpc6a.48xlarge", quota.BucketHPC),
+			Entry("mac", "mac2.metal", quota.BucketUntracked),
+			Entry("high memory", "u-6tb1.metal", quota.BucketUntracked),
+		)
+	})
+	Context("Headroom", func() {
+		It("should treat quotas as unlimited when service quotas can't be listed", func() {
+			servicequotasapi.NextError.Set(fmt.Errorf("access denied"))
+			_, ok := quotaProvider.Headroom(ctx, "on-demand", "m5.large")
+			Expect(ok).To(BeFalse())
+		})
+		It("should treat instance types without a known quota as unlimited", func() {
+			setQuotas(fake.NewServiceQuota("L-1216C47A", 32))
+			_, ok := quotaProvider.Headroom(ctx, "on-demand", "mac2.metal")
+			Expect(ok).To(BeFalse())
+		})
+		It("should subtract running vCPUs from the quota", func() {
+			setQuotas(fake.NewServiceQuota("L-1216C47A", 32), fake.NewServiceQuota("L-34B43A08", 16))
+			storeInstance("m5.2xlarge", ec2.InstanceStateNameRunning, "", 4, 2)
+			storeInstance("c5.xlarge", ec2.InstanceStateNamePending, "", 2, 2)
+			storeInstance("m5.large", ec2.InstanceStateNameRunning, ec2.InstanceLifecycleTypeSpot, 1, 2)
+			storeInstance("m5.large", ec2.InstanceStateNameStopped, "", 1, 2)
+
+			headroom, ok := quotaProvider.Headroom(ctx, "on-demand", "m5.large")
+			Expect(ok).To(BeTrue())
+			Expect(headroom).To(BeNumerically("==", 20))
+			headroom, ok = quotaProvider.Headroom(ctx, "spot", "m5.large")
+			Expect(ok).To(BeTrue())
+			Expect(headroom).To(BeNumerically("==", 14))
+		})
+		It("should account for inflight vCPUs until usage is refreshed", func() {
+			setQuotas(fake.NewServiceQuota("L-1216C47A", 32))
+			headroom, ok := quotaProvider.Headroom(ctx, "on-demand", "m5.large")
+			Expect(ok).To(BeTrue())
+			Expect(headroom).To(BeNumerically("==", 32))
+
+			quotaProvider.UpdateInflightVCPUs("on-demand", newInstanceType("m5.4xlarge", "16"))
+			headroom, ok = quotaProvider.Headroom(ctx, "on-demand", "m5.large")
+			Expect(ok).To(BeTrue())
+			Expect(headroom).To(BeNumerically("==", 16))
+		})
+		It("should never return negative headroom", func() {
+			setQuotas(fake.NewServiceQuota("L-1216C47A", 4))
+			storeInstance("m5.2xlarge", ec2.InstanceStateNameRunning, "", 4, 2)
+			headroom, ok := quotaProvider.Headroom(ctx, "on-demand", "m5.large")
+			Expect(ok).To(BeTrue())
+			Expect(headroom).To(BeNumerically("==", 0))
+		})
+	})
+	Context("Filter", func() {
+		It("should not filter anything when quotas are unknown", func() {
+			instanceTypes := []*cloudprovider.InstanceType{newInstanceType("m5.large", "2"), newInstanceType("g5.xlarge", "4")}
+			Expect(quotaProvider.Filter(ctx, "default", instanceTypes)).To(Equal(instanceTypes))
+		})
+		It("should remove offerings for capacity types without enough headroom", func() {
+			setQuotas(fake.NewServiceQuota("L-1216C47A", 8), fake.NewServiceQuota("L-34B43A08", 2))
+			filtered := quotaProvider.Filter(ctx, "default", []*cloudprovider.InstanceType{newInstanceType("m5.xlarge", "4")})
+			Expect(filtered).To(HaveLen(1))
+			Expect(lo.Uniq(lo.Map(filtered[0].Offerings, func(o cloudprovider.Offering, _ int) string { return o.CapacityType }))).To(ConsistOf("on-demand"))
+		})
+		It("should remove instance types that exceed the quota for every capacity type", func() {
+			setQuotas(fake.NewServiceQuota("L-DB2E81BA", 0), fake.NewServiceQuota("L-3819A6DF", 0))
+			filtered := quotaProvider.Filter(ctx, "default", []*cloudprovider.InstanceType{newInstanceType("m5.large", "2"), newInstanceType("g5.xlarge", "4")})
+			Expect(lo.Map(filtered, func(it *cloudprovider.InstanceType, _ int) string { return it.Name })).To(ConsistOf("m5.large"))
+		})
+		It("should return no instance types when the nodepool is fully quota-constrained", func() {
+			setQuotas(fake.NewServiceQuota("L-1216C47A", 1), fake.NewServiceQuota("L-34B43A08", 1))
+			Expect(quotaProvider.Filter(ctx, "default", []*cloudprovider.InstanceType{newInstanceType("m5.large", "2")})).To(BeEmpty())
+		})
+	})
+})
+
+func setQuotas(quotas ...*servicequotas.ServiceQuota) {
+	servicequotasapi.ListServiceQuotasOutput.Set(&servicequotas.ListServiceQuotasOutput{Quotas: quotas})
+}
+
+func storeInstance(instanceType, state, lifecycle string, coreCount, threadsPerCore int64) {
+	instance := &ec2.Instance{
+		InstanceId:   aws.String(coretest.RandomName()),
+		InstanceType: aws.String(instanceType),
+		State:        &ec2.InstanceState{Name: aws.String(state)},
+		CpuOptions:   &ec2.CpuOptions{CoreCount: aws.Int64(coreCount), ThreadsPerCore: aws.Int64(threadsPerCore)},
+	}
+	if lifecycle != "" {
+		instance.InstanceLifecycle = aws.String(lifecycle)
+	}
+	ec2api.Instances.Store(aws.StringValue(instance.InstanceId), instance)
+}
+
+func newInstanceType(name string, cpu string) *cloudprovider.InstanceType {
+	return corefake.NewInstanceType(corefake.InstanceTypeOptions{
+		Name:      name,
+		Resources: v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu)},
+	})
+}
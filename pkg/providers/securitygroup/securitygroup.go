@@ -17,35 +17,39 @@ package securitygroup
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
-	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/mitchellh/hashstructure/v2"
 	"github.com/patrickmn/go-cache"
 	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"knative.dev/pkg/logging"
 
 	"github.com/aws/karpenter-core/pkg/utils/functional"
 	"github.com/aws/karpenter-core/pkg/utils/pretty"
+	"github.com/aws/karpenter/pkg/apis/settings"
 	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/providers/ec2client"
 )
 
 type Provider struct {
 	sync.Mutex
-	ec2api ec2iface.EC2API
-	cache  *cache.Cache
-	cm     *pretty.ChangeMonitor
+	ec2clientProvider *ec2client.Provider
+	cache             *cache.Cache
+	cm                *pretty.ChangeMonitor
 }
 
 const TTL = 5 * time.Minute
 
-func NewProvider(ec2api ec2iface.EC2API, cache *cache.Cache) *Provider {
+func NewProvider(ec2clientProvider *ec2client.Provider, cache *cache.Cache) *Provider {
 	return &Provider{
-		ec2api: ec2api,
-		cm:     pretty.NewChangeMonitor(),
+		ec2clientProvider: ec2clientProvider,
+		cm:                pretty.NewChangeMonitor(),
 		// TODO: Remove cache for v1beta1, utilize resolved security groups from the AWSNodeTemplate.status
 		cache: cache,
 	}
@@ -61,10 +65,15 @@ func (p *Provider) List(ctx context.Context, nodeClass *v1beta1.NodeClass) ([]*e
 	if len(filterSets) == 0 {
 		return []*ec2.SecurityGroup{}, nil
 	}
-	securityGroups, err := p.getSecurityGroups(ctx, filterSets)
+	securityGroups, err := p.getSecurityGroups(ctx, nodeClass, filterSets)
 	if err != nil {
 		return nil, err
 	}
+	// Security groups are ordered by ID so that launch templates don't change with the order that EC2 describes them in
+	securityGroups = append([]*ec2.SecurityGroup{}, securityGroups...)
+	sort.Slice(securityGroups, func(i, j int) bool {
+		return aws.StringValue(securityGroups[i].GroupId) < aws.StringValue(securityGroups[j].GroupId)
+	})
 	if p.cm.HasChanged(fmt.Sprintf("security-groups/%t/%s", nodeClass.IsNodeTemplate, nodeClass.Name), securityGroups) {
 		logging.FromContext(ctx).
 			With("security-groups", lo.Map(securityGroups, func(s *ec2.SecurityGroup, _ int) string {
@@ -75,17 +84,56 @@ func (p *Provider) List(ctx context.Context, nodeClass *v1beta1.NodeClass) ([]*e
 	return securityGroups, nil
 }
 
-func (p *Provider) getSecurityGroups(ctx context.Context, filterSets [][]*ec2.Filter) ([]*ec2.SecurityGroup, error) {
+// ValidateVPC returns an error naming the security groups that aren't in the VPC of the subnets. EC2 rejects launches
+// that mix them, so catching this while resolving the NodeClass turns an opaque launch failure into a clear status.
+// Security groups and subnets whose VPC isn't known are not considered.
+func (p *Provider) ValidateVPC(securityGroups []*ec2.SecurityGroup, subnets []*ec2.Subnet) error {
+	vpcIDs := sets.New(lo.FilterMap(subnets, func(s *ec2.Subnet, _ int) (string, bool) {
+		return aws.StringValue(s.VpcId), aws.StringValue(s.VpcId) != ""
+	})...)
+	if vpcIDs.Len() == 0 {
+		return nil
+	}
+	mismatched := lo.Filter(securityGroups, func(sg *ec2.SecurityGroup, _ int) bool {
+		return aws.StringValue(sg.VpcId) != "" && !vpcIDs.Has(aws.StringValue(sg.VpcId))
+	})
+	if len(mismatched) == 0 {
+		return nil
+	}
+	sort.Slice(mismatched, func(i, j int) bool {
+		return aws.StringValue(mismatched[i].GroupId) < aws.StringValue(mismatched[j].GroupId)
+	})
+	return fmt.Errorf("security groups %s are not in the VPC of the selected subnets %v", strings.Join(lo.Map(mismatched, func(sg *ec2.SecurityGroup, _ int) string {
+		return fmt.Sprintf("%s (%s)", aws.StringValue(sg.GroupId), aws.StringValue(sg.VpcId))
+	}), ", "), sets.List(vpcIDs))
+}
+
+// ValidateCount returns an error if there are more security groups than can be attached to a network interface. EC2
+// would fail every launch with them, and dropping some of them would silently change the instances' network access.
+func (p *Provider) ValidateCount(ctx context.Context, securityGroups []*ec2.SecurityGroup) error {
+	if limit := settings.FromContext(ctx).MaxSecurityGroupsPerNetworkInterface; len(securityGroups) > limit {
+		return fmt.Errorf("%d security groups %v exceed the limit of %d security groups per network interface (aws.maxSecurityGroupsPerNetworkInterface)",
+			len(securityGroups), lo.Map(securityGroups, func(sg *ec2.SecurityGroup, _ int) string { return aws.StringValue(sg.GroupId) }), limit)
+	}
+	return nil
+}
+
+func (p *Provider) getSecurityGroups(ctx context.Context, nodeClass *v1beta1.NodeClass, filterSets [][]*ec2.Filter) ([]*ec2.SecurityGroup, error) {
 	hash, err := hashstructure.Hash(filterSets, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
 	if err != nil {
 		return nil, err
 	}
-	if sg, ok := p.cache.Get(fmt.Sprint(hash)); ok {
+	key := fmt.Sprint(hash)
+	if clientKey := p.ec2clientProvider.Key(nodeClass); clientKey != "" {
+		key = fmt.Sprintf("%s/%s", key, clientKey)
+	}
+	if sg, ok := p.cache.Get(key); ok {
 		return sg.([]*ec2.SecurityGroup), nil
 	}
+	ec2api := p.ec2clientProvider.EC2API(nodeClass)
 	securityGroups := map[string]*ec2.SecurityGroup{}
 	for _, filters := range filterSets {
-		output, err := p.ec2api.DescribeSecurityGroupsWithContext(ctx, &ec2.DescribeSecurityGroupsInput{Filters: filters})
+		output, err := ec2api.DescribeSecurityGroupsWithContext(ctx, &ec2.DescribeSecurityGroupsInput{Filters: filters})
 		if err != nil {
 			return nil, fmt.Errorf("describing security groups %+v, %w", filterSets, err)
 		}
@@ -93,7 +141,7 @@ func (p *Provider) getSecurityGroups(ctx context.Context, filterSets [][]*ec2.Fi
 			securityGroups[lo.FromPtr(output.SecurityGroups[i].GroupId)] = output.SecurityGroups[i]
 		}
 	}
-	p.cache.SetDefault(fmt.Sprint(hash), lo.Values(securityGroups))
+	p.cache.SetDefault(key, lo.Values(securityGroups))
 	return lo.Values(securityGroups), nil
 }
 
@@ -104,6 +152,12 @@ func getFilterSets(terms []v1beta1.SecurityGroupSelectorTerm) (res [][]*ec2.Filt
 		switch {
 		case term.ID != "":
 			idFilter.Values = append(idFilter.Values, aws.String(term.ID))
+		case term.Name != "" && term.VPCID != "":
+			// Names are only unique within a VPC, so a name scoped to a VPC can't share the batched name filter
+			res = append(res, []*ec2.Filter{
+				{Name: aws.String("group-name"), Values: []*string{aws.String(term.Name)}},
+				{Name: aws.String("vpc-id"), Values: []*string{aws.String(term.VPCID)}},
+			})
 		case term.Name != "":
 			nameFilter.Values = append(nameFilter.Values, aws.String(term.Name))
 		default:
@@ -121,6 +175,12 @@ func getFilterSets(terms []v1beta1.SecurityGroupSelectorTerm) (res [][]*ec2.Filt
 					})
 				}
 			}
+			if term.VPCID != "" {
+				filters = append(filters, &ec2.Filter{
+					Name:   aws.String("vpc-id"),
+					Values: []*string{aws.String(term.VPCID)},
+				})
+			}
 			res = append(res, filters)
 		}
 	}
@@ -16,6 +16,7 @@ package securitygroup_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -268,6 +269,112 @@ var _ = Describe("SecurityGroupProvider", func() {
 			},
 		}, securityGroups)
 	})
+	It("should discover security groups with the role that the NodeClass assumes", func() {
+		awsEnv.DiscoveryEC2API.DescribeSecurityGroupsOutput.Set(&ec2.DescribeSecurityGroupsOutput{SecurityGroups: []*ec2.SecurityGroup{
+			{GroupName: aws.String("shared-sgName"), GroupId: aws.String("sg-shared")},
+		}})
+		nodeClass.Spec.AssumeRoleARN = aws.String("arn:aws:iam::210987654321:role/discovery")
+		securityGroups, err := awsEnv.SecurityGroupProvider.List(ctx, nodeClass)
+		Expect(err).To(BeNil())
+		ExpectConsistsOfSecurityGroups([]*ec2.SecurityGroup{
+			{
+				GroupId:   aws.String("sg-shared"),
+				GroupName: aws.String("shared-sgName"),
+			},
+		}, securityGroups)
+	})
+	It("should discover security groups by name scoped to a vpc", func() {
+		nodeClass.Spec.SecurityGroupSelectorTerms = []v1beta1.SecurityGroupSelectorTerm{
+			{
+				Name:  "securityGroup-test1",
+				VPCID: "vpc-test1",
+			},
+			{
+				Name:  "securityGroup-test2",
+				VPCID: "vpc-test2",
+			},
+		}
+		securityGroups, err := awsEnv.SecurityGroupProvider.List(ctx, nodeClass)
+		Expect(err).To(BeNil())
+		ExpectConsistsOfSecurityGroups([]*ec2.SecurityGroup{
+			{
+				GroupId:   aws.String("sg-test1"),
+				GroupName: aws.String("securityGroup-test1"),
+			},
+		}, securityGroups)
+	})
+	It("should discover security groups by tags scoped to a vpc", func() {
+		nodeClass.Spec.SecurityGroupSelectorTerms = []v1beta1.SecurityGroupSelectorTerm{
+			{
+				Tags:  map[string]string{"TestTag": "*"},
+				VPCID: "vpc-test2",
+			},
+		}
+		securityGroups, err := awsEnv.SecurityGroupProvider.List(ctx, nodeClass)
+		Expect(err).To(BeNil())
+		Expect(securityGroups).To(BeEmpty())
+	})
+	It("should order security groups by ID", func() {
+		awsEnv.EC2API.DescribeSecurityGroupsOutput.Set(&ec2.DescribeSecurityGroupsOutput{SecurityGroups: []*ec2.SecurityGroup{
+			{GroupName: aws.String("test-sgName-3"), GroupId: aws.String("sg-test3")},
+			{GroupName: aws.String("test-sgName-1"), GroupId: aws.String("sg-test1")},
+			{GroupName: aws.String("test-sgName-2"), GroupId: aws.String("sg-test2")},
+		}})
+		securityGroups, err := awsEnv.SecurityGroupProvider.List(ctx, nodeClass)
+		Expect(err).To(BeNil())
+		Expect(lo.Map(securityGroups, func(sg *ec2.SecurityGroup, _ int) string { return aws.StringValue(sg.GroupId) })).To(Equal([]string{"sg-test1", "sg-test2", "sg-test3"}))
+	})
+	Context("ValidateCount", func() {
+		var securityGroups []*ec2.SecurityGroup
+		BeforeEach(func() {
+			securityGroups = lo.Times(6, func(i int) *ec2.SecurityGroup {
+				return &ec2.SecurityGroup{GroupId: aws.String(fmt.Sprintf("sg-test%d", i))}
+			})
+		})
+		It("should succeed when the security groups fit on a network interface", func() {
+			Expect(awsEnv.SecurityGroupProvider.ValidateCount(ctx, securityGroups[:5])).To(Succeed())
+		})
+		It("should fail when there are more security groups than fit on a network interface", func() {
+			err := awsEnv.SecurityGroupProvider.ValidateCount(ctx, securityGroups)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("6 security groups"))
+			Expect(err.Error()).To(ContainSubstring("limit of 5"))
+		})
+		It("should use the configured limit", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{MaxSecurityGroupsPerNetworkInterface: lo.ToPtr(6)}))
+			Expect(awsEnv.SecurityGroupProvider.ValidateCount(ctx, securityGroups)).To(Succeed())
+		})
+	})
+	Context("ValidateVPC", func() {
+		var subnets []*ec2.Subnet
+		BeforeEach(func() {
+			subnets = []*ec2.Subnet{
+				{SubnetId: aws.String("subnet-test1"), VpcId: aws.String("vpc-test1")},
+				{SubnetId: aws.String("subnet-test2"), VpcId: aws.String("vpc-test1")},
+			}
+		})
+		It("should succeed when the security groups are in the vpc of the subnets", func() {
+			securityGroups, err := awsEnv.SecurityGroupProvider.List(ctx, nodeClass)
+			Expect(err).To(BeNil())
+			Expect(awsEnv.SecurityGroupProvider.ValidateVPC(securityGroups, subnets)).To(Succeed())
+		})
+		It("should fail with the security groups that are in another vpc", func() {
+			securityGroups := []*ec2.SecurityGroup{
+				{GroupId: aws.String("sg-test1"), VpcId: aws.String("vpc-test1")},
+				{GroupId: aws.String("sg-test2"), VpcId: aws.String("vpc-test2")},
+			}
+			err := awsEnv.SecurityGroupProvider.ValidateVPC(securityGroups, subnets)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("sg-test2 (vpc-test2)"))
+			Expect(err.Error()).ToNot(ContainSubstring("sg-test1"))
+		})
+		It("should ignore security groups and subnets without a vpc", func() {
+			securityGroups := []*ec2.SecurityGroup{{GroupId: aws.String("sg-test1")}}
+			Expect(awsEnv.SecurityGroupProvider.ValidateVPC(securityGroups, subnets)).To(Succeed())
+			securityGroups = []*ec2.SecurityGroup{{GroupId: aws.String("sg-test1"), VpcId: aws.String("vpc-test2")}}
+			Expect(awsEnv.SecurityGroupProvider.ValidateVPC(securityGroups, []*ec2.Subnet{{SubnetId: aws.String("subnet-test1")}})).To(Succeed())
+		})
+	})
 })
 
 func ExpectConsistsOfSecurityGroups(expected, actual []*ec2.SecurityGroup) {
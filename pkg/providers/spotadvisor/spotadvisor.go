@@ -0,0 +1,177 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spotadvisor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/samber/lo"
+	"knative.dev/pkg/logging"
+
+	"github.com/aws/karpenter-core/pkg/utils/pretty"
+)
+
+// URL is the dataset of the Spot Instance Advisor, https://aws.amazon.com/ec2/spot/instance-advisor/, which AWS
+// publishes and refreshes throughout the day
+const URL = "https://spot-bid-advisor.s3.amazonaws.com/spot-advisor-data.json"
+
+// The operating systems that the dataset reports the interruption frequency of
+const (
+	OSLinux   = "Linux"
+	OSWindows = "Windows"
+)
+
+// Data is the part of the Spot Instance Advisor dataset that reports the interruption frequency of each instance type
+type Data struct {
+	// Ranges are the buckets of the interruption frequency, e.g. "<5%" or ">20%", ordered by their index
+	Ranges []Range `json:"ranges"`
+	// SpotAdvisor is the bucket of each instance type, by region and operating system
+	SpotAdvisor map[string]map[string]map[string]Advice `json:"spot_advisor"`
+}
+
+type Range struct {
+	Index int    `json:"index"`
+	Label string `json:"label"`
+}
+
+type Advice struct {
+	// InterruptionRate is the index of the range of the interruption frequency over the last month
+	InterruptionRate int `json:"r"`
+	// Savings is the percentage of savings over on-demand
+	Savings int `json:"s"`
+}
+
+// API gets the Spot Instance Advisor dataset
+type API interface {
+	GetData(context.Context) (*Data, error)
+}
+
+type api struct {
+	client *http.Client
+	url    string
+}
+
+func NewAPI(client *http.Client) API {
+	return &api{client: client, url: URL}
+}
+
+func (a *api) GetData(ctx context.Context) (*Data, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting %s, %s", a.url, resp.Status)
+	}
+	data := &Data{}
+	if err := json.NewDecoder(resp.Body).Decode(data); err != nil {
+		return nil, fmt.Errorf("decoding %s, %w", a.url, err)
+	}
+	return data, nil
+}
+
+// Provider provides the interruption frequency of the spot pools of each instance type in the region, which is scored
+// with the index of its bucket in the Spot Instance Advisor, from 0 for the least frequently interrupted pools. There is
+// no static dataset, so instance types have no score until the first update succeeds, and the last scores are retained
+// if an update fails.
+type Provider struct {
+	api    API
+	region string
+	cm     *pretty.ChangeMonitor
+
+	mu          sync.RWMutex
+	buckets     map[string]map[string]int
+	ranges      []Range
+	lastUpdated time.Time
+	// SeqNum is incremented each time the scores change, so that the instance types that carry them are recomputed
+	SeqNum uint64
+}
+
+func NewProvider(api API, region string) *Provider {
+	return &Provider{
+		api:     api,
+		region:  region,
+		cm:      pretty.NewChangeMonitor(),
+		buckets: map[string]map[string]int{},
+	}
+}
+
+// InterruptionRateBucket returns the index of the interruption frequency bucket of the spot pools of the instance type
+// for the operating system, or false if the Spot Instance Advisor doesn't report it
+func (p *Provider) InterruptionRateBucket(os string, instanceType string) (int, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	bucket, ok := p.buckets[os][instanceType]
+	return bucket, ok
+}
+
+// Ranges returns the labels of the buckets, e.g. "<5%", by their index
+func (p *Provider) Ranges() map[int]string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return lo.SliceToMap(p.ranges, func(r Range) (int, string) { return r.Index, r.Label })
+}
+
+// LastUpdated returns the time that the scores were last updated
+func (p *Provider) LastUpdated() time.Time {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.lastUpdated
+}
+
+func (p *Provider) Update(ctx context.Context) error {
+	data, err := p.api.GetData(ctx)
+	if err != nil {
+		return fmt.Errorf("getting spot advisor data, %w", err)
+	}
+	advice, ok := data.SpotAdvisor[p.region]
+	if !ok {
+		return fmt.Errorf("spot advisor data has no instance types in region %s", p.region)
+	}
+	buckets := lo.MapValues(advice, func(instanceTypes map[string]Advice, _ string) map[string]int {
+		return lo.MapValues(instanceTypes, func(a Advice, _ string) int { return a.InterruptionRate })
+	})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastUpdated = time.Now()
+	p.ranges = data.Ranges
+	if p.cm.HasChanged("spot-advisor", buckets) {
+		p.buckets = buckets
+		atomic.AddUint64(&p.SeqNum, 1)
+		logging.FromContext(ctx).With("instance-type-count", len(buckets[OSLinux])).Debugf("updated spot interruption rates")
+	}
+	return nil
+}
+
+func (p *Provider) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.buckets = map[string]map[string]int{}
+	p.ranges = nil
+	p.lastUpdated = time.Time{}
+	p.cm = pretty.NewChangeMonitor()
+}
@@ -0,0 +1,125 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spotadvisor_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+	. "knative.dev/pkg/logging/testing"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/settings"
+	"github.com/aws/karpenter-core/pkg/operator/injection"
+	"github.com/aws/karpenter-core/pkg/operator/options"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/settings"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/providers/spotadvisor"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var stop context.CancelFunc
+var opts options.Options
+var env *coretest.Environment
+var awsEnv *test.Environment
+var controller *spotadvisor.Controller
+
+func TestAWS(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "SpotAdvisor")
+}
+
+var _ = BeforeSuite(func() {
+	env = coretest.NewEnvironment(scheme.Scheme, coretest.WithCRDs(apis.CRDs...))
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+	ctx, stop = context.WithCancel(ctx)
+	awsEnv = test.NewEnvironment(ctx, env)
+	controller = spotadvisor.NewController(awsEnv.SpotAdvisorProvider)
+})
+
+var _ = AfterSuite(func() {
+	stop()
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	ctx = injection.WithOptions(ctx, opts)
+	ctx = coresettings.ToContext(ctx, coretest.Settings())
+	ctx = settings.ToContext(ctx, test.Settings())
+
+	awsEnv.Reset()
+})
+
+var _ = Describe("SpotAdvisor", func() {
+	It("should update the interruption rate buckets of the instance types in the region", func() {
+		awsEnv.SpotAdvisorAPI.Data.Set(fake.NewSpotAdvisorData("", map[string]int{"m5.large": 0, "c5.large": 3}))
+		updateStart := time.Now()
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(awsEnv.SpotAdvisorProvider.LastUpdated()).To(BeTemporally(">=", updateStart))
+
+		bucket, ok := awsEnv.SpotAdvisorProvider.InterruptionRateBucket(spotadvisor.OSLinux, "m5.large")
+		Expect(ok).To(BeTrue())
+		Expect(bucket).To(Equal(0))
+		bucket, ok = awsEnv.SpotAdvisorProvider.InterruptionRateBucket(spotadvisor.OSLinux, "c5.large")
+		Expect(ok).To(BeTrue())
+		Expect(bucket).To(Equal(3))
+		Expect(awsEnv.SpotAdvisorProvider.Ranges()).To(HaveKeyWithValue(3, "15-20%"))
+	})
+	It("should not report instance types that aren't in the dataset", func() {
+		awsEnv.SpotAdvisorAPI.Data.Set(fake.NewSpotAdvisorData("", map[string]int{"m5.large": 0}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		_, ok := awsEnv.SpotAdvisorProvider.InterruptionRateBucket(spotadvisor.OSLinux, "c5.large")
+		Expect(ok).To(BeFalse())
+		_, ok = awsEnv.SpotAdvisorProvider.InterruptionRateBucket(spotadvisor.OSWindows, "m5.large")
+		Expect(ok).To(BeFalse())
+	})
+	It("should keep the last interruption rate buckets if the update fails", func() {
+		awsEnv.SpotAdvisorAPI.Data.Set(fake.NewSpotAdvisorData("", map[string]int{"m5.large": 2}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		awsEnv.SpotAdvisorAPI.NextError.Set(fmt.Errorf("failed"))
+		ExpectReconcileFailed(ctx, controller, types.NamespacedName{})
+		bucket, ok := awsEnv.SpotAdvisorProvider.InterruptionRateBucket(spotadvisor.OSLinux, "m5.large")
+		Expect(ok).To(BeTrue())
+		Expect(bucket).To(Equal(2))
+	})
+	It("should fail to update if the dataset doesn't include the region", func() {
+		awsEnv.SpotAdvisorAPI.Data.Set(fake.NewSpotAdvisorData("us-west-2", map[string]int{"m5.large": 0}))
+		ExpectReconcileFailed(ctx, controller, types.NamespacedName{})
+		_, ok := awsEnv.SpotAdvisorProvider.InterruptionRateBucket(spotadvisor.OSLinux, "m5.large")
+		Expect(ok).To(BeFalse())
+	})
+	It("should only change the sequence number when the interruption rate buckets change", func() {
+		awsEnv.SpotAdvisorAPI.Data.Set(fake.NewSpotAdvisorData("", map[string]int{"m5.large": 0}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		seqNum := awsEnv.SpotAdvisorProvider.SeqNum
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(awsEnv.SpotAdvisorProvider.SeqNum).To(Equal(seqNum))
+		awsEnv.SpotAdvisorAPI.Data.Set(fake.NewSpotAdvisorData("", map[string]int{"m5.large": 1}))
+		ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+		Expect(awsEnv.SpotAdvisorProvider.SeqNum).To(BeNumerically(">", seqNum))
+	})
+})
@@ -17,8 +17,11 @@ package subnet
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"net/http"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -29,7 +32,9 @@ import (
 	"github.com/samber/lo"
 	"knative.dev/pkg/logging"
 
+	"github.com/aws/karpenter/pkg/apis/settings"
 	"github.com/aws/karpenter/pkg/apis/v1beta1"
+	"github.com/aws/karpenter/pkg/providers/ec2client"
 
 	"github.com/aws/karpenter-core/pkg/cloudprovider"
 	"github.com/aws/karpenter-core/pkg/utils/functional"
@@ -38,21 +43,24 @@ import (
 
 type Provider struct {
 	sync.RWMutex
-	ec2api      ec2iface.EC2API
-	cache       *cache.Cache
-	cm          *pretty.ChangeMonitor
-	inflightIPs map[string]int64
+	ec2clientProvider *ec2client.Provider
+	cache             *cache.Cache
+	cm                *pretty.ChangeMonitor
+	inflightIPs       map[string]int64
+	roundRobinTurns   map[string]int
 }
 
-func NewProvider(ec2api ec2iface.EC2API, cache *cache.Cache) *Provider {
+func NewProvider(ec2clientProvider *ec2client.Provider, cache *cache.Cache) *Provider {
 	return &Provider{
-		ec2api: ec2api,
-		cm:     pretty.NewChangeMonitor(),
+		ec2clientProvider: ec2clientProvider,
+		cm:                pretty.NewChangeMonitor(),
 		// TODO: Remove cache for v1beta1, utilize resolved subnet from the AWSNodeTemplate.status
 		// Subnets are sorted on AvailableIpAddressCount, descending order
 		cache: cache,
 		// inflightIPs is used to track IPs from known launched instances
 		inflightIPs: map[string]int64{},
+		// roundRobinTurns counts the launches into each zone for aws.subnetSelectionStrategy=roundRobin
+		roundRobinTurns: map[string]int{},
 	}
 }
 
@@ -67,14 +75,24 @@ func (p *Provider) List(ctx context.Context, nodeClass *v1beta1.NodeClass) ([]*e
 	if err != nil {
 		return nil, err
 	}
-	if subnets, ok := p.cache.Get(fmt.Sprint(hash)); ok {
+	key := fmt.Sprint(hash)
+	privateOnly := lo.FromPtr(nodeClass.Spec.SubnetPolicy) == v1beta1.SubnetPolicyPrivateOnly
+	if privateOnly {
+		key = fmt.Sprintf("%s/%s", key, v1beta1.SubnetPolicyPrivateOnly)
+	}
+	// The same filters select different subnets depending on the region and account that they're described in
+	if clientKey := p.ec2clientProvider.Key(nodeClass); clientKey != "" {
+		key = fmt.Sprintf("%s/%s", key, clientKey)
+	}
+	if subnets, ok := p.cache.Get(key); ok {
 		return subnets.([]*ec2.Subnet), nil
 	}
+	ec2api := p.ec2clientProvider.EC2API(nodeClass)
 
 	// Ensure that all the subnets that are returned here are unique
 	subnets := map[string]*ec2.Subnet{}
 	for _, filters := range filterSets {
-		output, err := p.ec2api.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{Filters: filters})
+		output, err := ec2api.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{Filters: filters})
 		if err != nil {
 			return nil, fmt.Errorf("describing subnets %s, %w", pretty.Concise(filters), err)
 		}
@@ -83,7 +101,17 @@ func (p *Provider) List(ctx context.Context, nodeClass *v1beta1.NodeClass) ([]*e
 			delete(p.inflightIPs, lo.FromPtr(output.Subnets[i].SubnetId)) // remove any previously tracked IP addresses since we just refreshed from EC2
 		}
 	}
-	p.cache.SetDefault(fmt.Sprint(hash), lo.Values(subnets))
+	// Subnets can be excluded by their tags, without changing the selectors of every NodeClass that selects them
+	subnets = lo.OmitBy(subnets, func(_ string, s *ec2.Subnet) bool {
+		value, _ := tagValue(s, v1beta1.SubnetExcludeTagKey)
+		return strings.EqualFold(value, "true")
+	})
+	if privateOnly {
+		if subnets, err = p.filterPrivate(ctx, ec2api, subnets); err != nil {
+			return nil, err
+		}
+	}
+	p.cache.SetDefault(key, lo.Values(subnets))
 	if p.cm.HasChanged(fmt.Sprintf("subnets/%t/%s", nodeClass.IsNodeTemplate, nodeClass.Name), subnets) {
 		logging.FromContext(ctx).
 			With("subnets", lo.Map(lo.Values(subnets), func(s *ec2.Subnet, _ int) string {
@@ -94,6 +122,51 @@ func (p *Provider) List(ctx context.Context, nodeClass *v1beta1.NodeClass) ([]*e
 	return lo.Values(subnets), nil
 }
 
+// filterPrivate removes subnets that either assign public IPv4 addresses on launch or whose route table, explicitly
+// associated or inherited from the VPC's main route table, has a route to an internet gateway
+func (p *Provider) filterPrivate(ctx context.Context, ec2api ec2iface.EC2API, subnets map[string]*ec2.Subnet) (map[string]*ec2.Subnet, error) {
+	subnets = lo.OmitBy(subnets, func(_ string, s *ec2.Subnet) bool { return aws.BoolValue(s.MapPublicIpOnLaunch) })
+	if len(subnets) == 0 {
+		return subnets, nil
+	}
+	vpcIDs := lo.Uniq(lo.Map(lo.Values(subnets), func(s *ec2.Subnet, _ int) string { return aws.StringValue(s.VpcId) }))
+	subnetRouteTables := map[string]*ec2.RouteTable{}
+	mainRouteTables := map[string]*ec2.RouteTable{}
+	if err := ec2api.DescribeRouteTablesPagesWithContext(ctx, &ec2.DescribeRouteTablesInput{
+		Filters: []*ec2.Filter{{Name: aws.String("vpc-id"), Values: aws.StringSlice(vpcIDs)}},
+	}, func(output *ec2.DescribeRouteTablesOutput, _ bool) bool {
+		for _, routeTable := range output.RouteTables {
+			for _, association := range routeTable.Associations {
+				if aws.BoolValue(association.Main) {
+					mainRouteTables[aws.StringValue(routeTable.VpcId)] = routeTable
+				} else if association.SubnetId != nil {
+					subnetRouteTables[aws.StringValue(association.SubnetId)] = routeTable
+				}
+			}
+		}
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("describing route tables for vpcs %v, %w", vpcIDs, err)
+	}
+	return lo.OmitBy(subnets, func(id string, s *ec2.Subnet) bool {
+		routeTable, ok := subnetRouteTables[id]
+		if !ok {
+			routeTable = mainRouteTables[aws.StringValue(s.VpcId)]
+		}
+		return hasInternetGatewayRoute(routeTable)
+	}), nil
+}
+
+func hasInternetGatewayRoute(routeTable *ec2.RouteTable) bool {
+	if routeTable == nil {
+		return false
+	}
+	_, ok := lo.Find(routeTable.Routes, func(r *ec2.Route) bool {
+		return strings.HasPrefix(aws.StringValue(r.GatewayId), "igw-")
+	})
+	return ok
+}
+
 // CheckAnyPublicIPAssociations returns a bool indicating whether all referenced subnets assign public IPv4 addresses to EC2 instances created therein
 func (p *Provider) CheckAnyPublicIPAssociations(ctx context.Context, nodeClass *v1beta1.NodeClass) (bool, error) {
 	subnets, err := p.List(ctx, nodeClass)
@@ -106,33 +179,23 @@ func (p *Provider) CheckAnyPublicIPAssociations(ctx context.Context, nodeClass *
 	return ok, nil
 }
 
-// ZonalSubnetsForLaunch returns a mapping of zone to the subnet with the most available IP addresses and deducts the passed ips from the available count
+// ZonalSubnetsForLaunch returns a mapping of zone to the subnet that aws.subnetSelectionStrategy selects and deducts the passed ips from the available count
 func (p *Provider) ZonalSubnetsForLaunch(ctx context.Context, nodeClass *v1beta1.NodeClass, instanceTypes []*cloudprovider.InstanceType, capacityType string) (map[string]*ec2.Subnet, error) {
 	subnets, err := p.List(ctx, nodeClass)
 	if err != nil {
 		return nil, err
 	}
 	if len(subnets) == 0 {
+		if lo.FromPtr(nodeClass.Spec.SubnetPolicy) == v1beta1.SubnetPolicyPrivateOnly {
+			return nil, fmt.Errorf("no private subnets matched selector %v", nodeClass.Spec.SubnetSelectorTerms)
+		}
 		return nil, fmt.Errorf("no subnets matched selector %v", nodeClass.Spec.SubnetSelectorTerms)
 	}
 	p.Lock()
 	defer p.Unlock()
-	// sort subnets in ascending order of available IP addresses and populate map with most available subnet per AZ
 	zonalSubnets := map[string]*ec2.Subnet{}
-	sort.Slice(subnets, func(i, j int) bool {
-		iIPs := aws.Int64Value(subnets[i].AvailableIpAddressCount)
-		jIPs := aws.Int64Value(subnets[j].AvailableIpAddressCount)
-		// override ip count from ec2.Subnet if we've tracked launches
-		if ips, ok := p.inflightIPs[*subnets[i].SubnetId]; ok {
-			iIPs = ips
-		}
-		if ips, ok := p.inflightIPs[*subnets[j].SubnetId]; ok {
-			jIPs = ips
-		}
-		return iIPs < jIPs
-	})
-	for _, subnet := range subnets {
-		zonalSubnets[*subnet.AvailabilityZone] = subnet
+	for zone, zoneSubnets := range lo.GroupBy(subnets, func(s *ec2.Subnet) string { return aws.StringValue(s.AvailabilityZone) }) {
+		zonalSubnets[zone] = p.selectSubnet(ctx, zone, zoneSubnets, p.minPods(instanceTypes, zone, capacityType))
 	}
 	for _, subnet := range zonalSubnets {
 		predictedIPsUsed := p.minPods(instanceTypes, *subnet.AvailabilityZone, capacityType)
@@ -145,6 +208,70 @@ func (p *Provider) ZonalSubnetsForLaunch(ctx context.Context, nodeClass *v1beta1
 	return zonalSubnets, nil
 }
 
+// selectSubnet returns the subnet of the zone that aws.subnetSelectionStrategy selects. Subnets are only selected
+// between the subnets with the highest priority that have enough available IP addresses for the launch. If none have
+// enough, the subnet with the most available IP addresses is selected.
+func (p *Provider) selectSubnet(ctx context.Context, zone string, subnets []*ec2.Subnet, predictedIPsUsed int64) *ec2.Subnet {
+	availableIPs := func(subnet *ec2.Subnet) int64 {
+		// override ip count from ec2.Subnet if we've tracked launches
+		if ips, ok := p.inflightIPs[*subnet.SubnetId]; ok {
+			return ips
+		}
+		return aws.Int64Value(subnet.AvailableIpAddressCount)
+	}
+	// sort subnets in descending order of available IP addresses, and by ID so that the order of subnets with the
+	// same number of available IP addresses is stable
+	sort.Slice(subnets, func(i, j int) bool {
+		if iIPs, jIPs := availableIPs(subnets[i]), availableIPs(subnets[j]); iIPs != jIPs {
+			return iIPs > jIPs
+		}
+		return aws.StringValue(subnets[i].SubnetId) < aws.StringValue(subnets[j].SubnetId)
+	})
+	candidates := lo.Filter(subnets, func(subnet *ec2.Subnet, _ int) bool {
+		return availableIPs(subnet) > 0 && availableIPs(subnet) >= predictedIPsUsed
+	})
+	if len(candidates) == 0 {
+		return subnets[0]
+	}
+	highest := lo.Max(lo.Map(candidates, func(subnet *ec2.Subnet, _ int) int { return priority(subnet) }))
+	candidates = lo.Filter(candidates, func(subnet *ec2.Subnet, _ int) bool { return priority(subnet) == highest })
+	switch settings.FromContext(ctx).SubnetSelectionStrategy {
+	case settings.SubnetSelectionStrategyRoundRobin:
+		// candidates are ordered by ID so that each subnet takes its turn however its available IP addresses change
+		sort.Slice(candidates, func(i, j int) bool {
+			return aws.StringValue(candidates[i].SubnetId) < aws.StringValue(candidates[j].SubnetId)
+		})
+		turn := p.roundRobinTurns[zone]
+		p.roundRobinTurns[zone] = turn + 1
+		return candidates[turn%len(candidates)]
+	case settings.SubnetSelectionStrategyRandom:
+		return candidates[rand.Intn(len(candidates))] //nolint:gosec
+	default:
+		return candidates[0]
+	}
+}
+
+// priority returns the priority that the subnet is tagged with, or 0 if it isn't tagged with an integer priority
+func priority(subnet *ec2.Subnet) int {
+	value, ok := tagValue(subnet, v1beta1.SubnetPriorityTagKey)
+	if !ok {
+		return 0
+	}
+	p, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0
+	}
+	return p
+}
+
+func tagValue(subnet *ec2.Subnet, key string) (string, bool) {
+	tag, ok := lo.Find(subnet.Tags, func(t *ec2.Tag) bool { return aws.StringValue(t.Key) == key })
+	if !ok {
+		return "", false
+	}
+	return aws.StringValue(tag.Value), true
+}
+
 // UpdateInflightIPs is used to refresh the in-memory IP usage by adding back unused IPs after a CreateFleet response is returned
 func (p *Provider) UpdateInflightIPs(createFleetInput *ec2.CreateFleetInput, createFleetOutput *ec2.CreateFleetOutput, instanceTypes []*cloudprovider.InstanceType,
 	subnets []*ec2.Subnet, capacityType string) {
@@ -238,6 +365,16 @@ func getFilterSets(terms []v1beta1.SubnetSelectorTerm) (res [][]*ec2.Filter) {
 			idFilter.Values = append(idFilter.Values, aws.String(term.ID))
 		default:
 			var filters []*ec2.Filter
+			for _, filter := range []lo.Entry[string, string]{
+				{Key: "availability-zone", Value: term.Zone},
+				{Key: "availability-zone-id", Value: term.ZoneID},
+				{Key: "cidr-block", Value: term.CIDR},
+				{Key: "owner-id", Value: term.OwnerAccountID},
+			} {
+				if filter.Value != "" {
+					filters = append(filters, &ec2.Filter{Name: aws.String(filter.Key), Values: []*string{aws.String(filter.Value)}})
+				}
+			}
 			for k, v := range term.Tags {
 				if v == "*" {
 					filters = append(filters, &ec2.Filter{
@@ -112,6 +112,51 @@ var _ = Describe("SubnetProvider", func() {
 				},
 			}, subnets)
 		})
+		It("should discover subnets by zone", func() {
+			nodeClass.Spec.SubnetSelectorTerms = []v1beta1.SubnetSelectorTerm{{Zone: "test-zone-1b"}}
+			subnets, err := awsEnv.SubnetProvider.List(ctx, nodeClass)
+			Expect(err).To(BeNil())
+			Expect(lo.Map(subnets, func(s *ec2.Subnet, _ int) string { return lo.FromPtr(s.SubnetId) })).To(ConsistOf("subnet-test2"))
+		})
+		It("should discover subnets by zone ID", func() {
+			nodeClass.Spec.SubnetSelectorTerms = []v1beta1.SubnetSelectorTerm{{ZoneID: "testzone1c"}}
+			subnets, err := awsEnv.SubnetProvider.List(ctx, nodeClass)
+			Expect(err).To(BeNil())
+			Expect(lo.Map(subnets, func(s *ec2.Subnet, _ int) string { return lo.FromPtr(s.SubnetId) })).To(ConsistOf("subnet-test3"))
+		})
+		It("should discover subnets by CIDR", func() {
+			nodeClass.Spec.SubnetSelectorTerms = []v1beta1.SubnetSelectorTerm{{CIDR: "10.0.1.0/24"}}
+			subnets, err := awsEnv.SubnetProvider.List(ctx, nodeClass)
+			Expect(err).To(BeNil())
+			Expect(lo.Map(subnets, func(s *ec2.Subnet, _ int) string { return lo.FromPtr(s.SubnetId) })).To(ConsistOf("subnet-test1"))
+		})
+		It("should discover subnets by owner account ID and tags", func() {
+			nodeClass.Spec.SubnetSelectorTerms = []v1beta1.SubnetSelectorTerm{{OwnerAccountID: "123456789012", Tags: map[string]string{"foo": "bar"}}}
+			subnets, err := awsEnv.SubnetProvider.List(ctx, nodeClass)
+			Expect(err).To(BeNil())
+			Expect(lo.Map(subnets, func(s *ec2.Subnet, _ int) string { return lo.FromPtr(s.SubnetId) })).To(ConsistOf("subnet-test1", "subnet-test2"))
+		})
+		It("should discover subnets with the role that the NodeClass assumes", func() {
+			awsEnv.DiscoveryEC2API.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
+				{
+					SubnetId:                lo.ToPtr("subnet-shared"),
+					AvailabilityZone:        lo.ToPtr("test-zone-1a"),
+					AvailableIpAddressCount: lo.ToPtr[int64](100),
+					Tags:                    []*ec2.Tag{{Key: lo.ToPtr("foo"), Value: lo.ToPtr("bar")}},
+				},
+			}})
+			nodeClass.Spec.SubnetSelectorTerms = []v1beta1.SubnetSelectorTerm{{Tags: map[string]string{"foo": "bar"}}}
+			nodeClass.Spec.AssumeRoleARN = lo.ToPtr("arn:aws:iam::210987654321:role/discovery")
+			subnets, err := awsEnv.SubnetProvider.List(ctx, nodeClass)
+			Expect(err).To(BeNil())
+			Expect(lo.Map(subnets, func(s *ec2.Subnet, _ int) string { return lo.FromPtr(s.SubnetId) })).To(ConsistOf("subnet-shared"))
+
+			// The same selector is discovered separately with the controller's credentials
+			nodeClass.Spec.AssumeRoleARN = nil
+			subnets, err = awsEnv.SubnetProvider.List(ctx, nodeClass)
+			Expect(err).To(BeNil())
+			Expect(lo.Map(subnets, func(s *ec2.Subnet, _ int) string { return lo.FromPtr(s.SubnetId) })).To(ConsistOf("subnet-test1", "subnet-test2", "subnet-test3"))
+		})
 		It("should discover subnets by IDs", func() {
 			nodeClass.Spec.SubnetSelectorTerms = []v1beta1.SubnetSelectorTerm{
 				{
@@ -220,6 +265,130 @@ var _ = Describe("SubnetProvider", func() {
 			}, subnets)
 		})
 	})
+	Context("SubnetPolicy", func() {
+		It("should exclude subnets that map public IPs on launch", func() {
+			nodeClass.Spec.SubnetPolicy = aws.String(v1beta1.SubnetPolicyPrivateOnly)
+			subnets, err := awsEnv.SubnetProvider.List(ctx, nodeClass)
+			Expect(err).To(BeNil())
+			Expect(lo.Map(subnets, func(s *ec2.Subnet, _ int) string { return aws.StringValue(s.SubnetId) })).To(ConsistOf("subnet-test1", "subnet-test3"))
+		})
+		It("should exclude subnets with an explicit route to an internet gateway", func() {
+			awsEnv.EC2API.DescribeRouteTablesOutput.Set(&ec2.DescribeRouteTablesOutput{RouteTables: []*ec2.RouteTable{
+				{
+					RouteTableId: aws.String("rtb-test1"),
+					Associations: []*ec2.RouteTableAssociation{{SubnetId: aws.String("subnet-test3")}},
+					Routes:       []*ec2.Route{{DestinationCidrBlock: aws.String("0.0.0.0/0"), GatewayId: aws.String("igw-test1")}},
+				},
+			}})
+			nodeClass.Spec.SubnetPolicy = aws.String(v1beta1.SubnetPolicyPrivateOnly)
+			subnets, err := awsEnv.SubnetProvider.List(ctx, nodeClass)
+			Expect(err).To(BeNil())
+			Expect(lo.Map(subnets, func(s *ec2.Subnet, _ int) string { return aws.StringValue(s.SubnetId) })).To(ConsistOf("subnet-test1"))
+		})
+		It("should exclude subnets that inherit a main route table with a route to an internet gateway", func() {
+			awsEnv.EC2API.DescribeRouteTablesOutput.Set(&ec2.DescribeRouteTablesOutput{RouteTables: []*ec2.RouteTable{
+				{
+					RouteTableId: aws.String("rtb-main"),
+					Associations: []*ec2.RouteTableAssociation{{Main: aws.Bool(true)}},
+					Routes:       []*ec2.Route{{DestinationCidrBlock: aws.String("0.0.0.0/0"), GatewayId: aws.String("igw-test1")}},
+				},
+				{
+					RouteTableId: aws.String("rtb-private"),
+					Associations: []*ec2.RouteTableAssociation{{SubnetId: aws.String("subnet-test1")}},
+					Routes:       []*ec2.Route{{DestinationCidrBlock: aws.String("0.0.0.0/0"), NatGatewayId: aws.String("nat-test1")}},
+				},
+			}})
+			nodeClass.Spec.SubnetPolicy = aws.String(v1beta1.SubnetPolicyPrivateOnly)
+			subnets, err := awsEnv.SubnetProvider.List(ctx, nodeClass)
+			Expect(err).To(BeNil())
+			Expect(lo.Map(subnets, func(s *ec2.Subnet, _ int) string { return aws.StringValue(s.SubnetId) })).To(ConsistOf("subnet-test1"))
+		})
+		It("should not exclude public subnets when the subnet policy is any", func() {
+			nodeClass.Spec.SubnetPolicy = aws.String(v1beta1.SubnetPolicyAny)
+			subnets, err := awsEnv.SubnetProvider.List(ctx, nodeClass)
+			Expect(err).To(BeNil())
+			Expect(subnets).To(HaveLen(3))
+		})
+		It("should fail to find subnets for launch when every subnet is public", func() {
+			nodeClass.Spec.SubnetSelectorTerms = []v1beta1.SubnetSelectorTerm{{ID: "subnet-test2"}}
+			nodeClass.Spec.SubnetPolicy = aws.String(v1beta1.SubnetPolicyPrivateOnly)
+			_, err := awsEnv.SubnetProvider.ZonalSubnetsForLaunch(ctx, nodeClass, nil, "on-demand")
+			Expect(err).To(MatchError(ContainSubstring("no private subnets matched selector")))
+		})
+	})
+	Context("SubnetSelectionStrategy", func() {
+		BeforeEach(func() {
+			awsEnv.EC2API.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
+				{SubnetId: lo.ToPtr("subnet-test1"), AvailabilityZone: lo.ToPtr("test-zone-1a"), AvailableIpAddressCount: lo.ToPtr[int64](100)},
+				{SubnetId: lo.ToPtr("subnet-test2"), AvailabilityZone: lo.ToPtr("test-zone-1a"), AvailableIpAddressCount: lo.ToPtr[int64](50)},
+				{SubnetId: lo.ToPtr("subnet-test3"), AvailabilityZone: lo.ToPtr("test-zone-1a"), AvailableIpAddressCount: lo.ToPtr[int64](0)},
+			}})
+			nodeClass.Spec.SubnetSelectorTerms = []v1beta1.SubnetSelectorTerm{{ID: "subnet-test1"}, {ID: "subnet-test2"}, {ID: "subnet-test3"}}
+		})
+		launchSubnets := func(launches int) []string {
+			var subnetIDs []string
+			for i := 0; i < launches; i++ {
+				zonalSubnets, err := awsEnv.SubnetProvider.ZonalSubnetsForLaunch(ctx, nodeClass, nil, "on-demand")
+				Expect(err).ToNot(HaveOccurred())
+				subnetIDs = append(subnetIDs, aws.StringValue(zonalSubnets["test-zone-1a"].SubnetId))
+			}
+			return subnetIDs
+		}
+		It("should launch into the subnet with the most available IP addresses by default", func() {
+			Expect(launchSubnets(4)).To(HaveEach("subnet-test1"))
+		})
+		It("should launch into each subnet with available IP addresses in turn", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{SubnetSelectionStrategy: lo.ToPtr(settings.SubnetSelectionStrategyRoundRobin)}))
+			subnetIDs := launchSubnets(4)
+			Expect(subnetIDs).To(ContainElements("subnet-test1", "subnet-test2"))
+			Expect(subnetIDs).ToNot(ContainElement("subnet-test3"))
+			for i := 1; i < len(subnetIDs); i++ {
+				Expect(subnetIDs[i]).ToNot(Equal(subnetIDs[i-1]))
+			}
+		})
+		It("should launch into random subnets with available IP addresses", func() {
+			ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{SubnetSelectionStrategy: lo.ToPtr(settings.SubnetSelectionStrategyRandom)}))
+			subnetIDs := launchSubnets(50)
+			Expect(subnetIDs).To(ContainElements("subnet-test1", "subnet-test2"))
+			Expect(subnetIDs).ToNot(ContainElement("subnet-test3"))
+		})
+	})
+	Context("Subnet Tags", func() {
+		It("should exclude subnets that are tagged to be excluded", func() {
+			awsEnv.EC2API.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
+				{SubnetId: lo.ToPtr("subnet-test1"), AvailabilityZone: lo.ToPtr("test-zone-1a"), AvailableIpAddressCount: lo.ToPtr[int64](100),
+					Tags: []*ec2.Tag{{Key: lo.ToPtr(v1beta1.SubnetExcludeTagKey), Value: lo.ToPtr("true")}}},
+				{SubnetId: lo.ToPtr("subnet-test2"), AvailabilityZone: lo.ToPtr("test-zone-1a"), AvailableIpAddressCount: lo.ToPtr[int64](100),
+					Tags: []*ec2.Tag{{Key: lo.ToPtr(v1beta1.SubnetExcludeTagKey), Value: lo.ToPtr("false")}}},
+			}})
+			nodeClass.Spec.SubnetSelectorTerms = []v1beta1.SubnetSelectorTerm{{ID: "subnet-test1"}, {ID: "subnet-test2"}}
+			subnets, err := awsEnv.SubnetProvider.List(ctx, nodeClass)
+			Expect(err).To(BeNil())
+			Expect(lo.Map(subnets, func(s *ec2.Subnet, _ int) string { return aws.StringValue(s.SubnetId) })).To(ConsistOf("subnet-test2"))
+		})
+		It("should launch into the subnet with the highest priority that has available IP addresses", func() {
+			awsEnv.EC2API.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
+				{SubnetId: lo.ToPtr("subnet-test1"), AvailabilityZone: lo.ToPtr("test-zone-1a"), AvailableIpAddressCount: lo.ToPtr[int64](100)},
+				{SubnetId: lo.ToPtr("subnet-test2"), AvailabilityZone: lo.ToPtr("test-zone-1a"), AvailableIpAddressCount: lo.ToPtr[int64](10),
+					Tags: []*ec2.Tag{{Key: lo.ToPtr(v1beta1.SubnetPriorityTagKey), Value: lo.ToPtr("10")}}},
+				{SubnetId: lo.ToPtr("subnet-test3"), AvailabilityZone: lo.ToPtr("test-zone-1a"), AvailableIpAddressCount: lo.ToPtr[int64](0),
+					Tags: []*ec2.Tag{{Key: lo.ToPtr(v1beta1.SubnetPriorityTagKey), Value: lo.ToPtr("20")}}},
+				{SubnetId: lo.ToPtr("subnet-test4"), AvailabilityZone: lo.ToPtr("test-zone-1b"), AvailableIpAddressCount: lo.ToPtr[int64](10),
+					Tags: []*ec2.Tag{{Key: lo.ToPtr(v1beta1.SubnetPriorityTagKey), Value: lo.ToPtr("-1")}}},
+				{SubnetId: lo.ToPtr("subnet-test5"), AvailabilityZone: lo.ToPtr("test-zone-1b"), AvailableIpAddressCount: lo.ToPtr[int64](5),
+					Tags: []*ec2.Tag{{Key: lo.ToPtr(v1beta1.SubnetPriorityTagKey), Value: lo.ToPtr("high")}}},
+			}})
+			nodeClass.Spec.SubnetSelectorTerms = []v1beta1.SubnetSelectorTerm{
+				{ID: "subnet-test1"}, {ID: "subnet-test2"}, {ID: "subnet-test3"}, {ID: "subnet-test4"}, {ID: "subnet-test5"},
+			}
+			zonalSubnets, err := awsEnv.SubnetProvider.ZonalSubnetsForLaunch(ctx, nodeClass, nil, "on-demand")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.MapValues(zonalSubnets, func(s *ec2.Subnet, _ string) string { return aws.StringValue(s.SubnetId) })).To(Equal(map[string]string{
+				"test-zone-1a": "subnet-test2",
+				"test-zone-1b": "subnet-test5",
+			}))
+		})
+	})
 	Context("CheckAnyPublicIPAssociations", func() {
 		It("should note that no subnets assign a public IPv4 address to EC2 instances on launch", func() {
 			nodeClass.Spec.SubnetSelectorTerms = []v1beta1.SubnetSelectorTerm{
@@ -17,17 +17,25 @@ package version
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/aws/aws-sdk-go/service/eks/eksiface"
 	"github.com/patrickmn/go-cache"
 	"k8s.io/client-go/kubernetes"
 	"knative.dev/pkg/logging"
 
 	"github.com/aws/karpenter-core/pkg/utils/pretty"
+	"github.com/aws/karpenter/pkg/apis/settings"
 )
 
 const (
 	kubernetesVersionCacheKey = "kubernetesVersion"
+	// MaxKubeletVersionSkew is the most minor versions that the kubelet of a node that Karpenter launches can be behind
+	// the control plane
+	MaxKubeletVersionSkew = 2
 )
 
 // Provider get the APIServer version. This will be initialized at start up and allows karpenter to have an understanding of the cluster version
@@ -37,28 +45,82 @@ type Provider struct {
 	cache               *cache.Cache
 	cm                  *pretty.ChangeMonitor
 	kubernetesInterface kubernetes.Interface
+	eksapi              eksiface.EKSAPI
 }
 
-func NewProvider(kubernetesInterface kubernetes.Interface, cache *cache.Cache) *Provider {
+func NewProvider(kubernetesInterface kubernetes.Interface, eksapi eksiface.EKSAPI, cache *cache.Cache) *Provider {
 	return &Provider{
 		cm:                  pretty.NewChangeMonitor(),
 		cache:               cache,
 		kubernetesInterface: kubernetesInterface,
+		eksapi:              eksapi,
 	}
 }
 
+// Get returns the version of the control plane, which is read from EKS rather than from the API server when
+// aws.kubernetesVersionSource is eks, e.g. when the API server is behind a proxy that reports another version
 func (p *Provider) Get(ctx context.Context) (string, error) {
 	if version, ok := p.cache.Get(kubernetesVersionCacheKey); ok {
 		return version.(string), nil
 	}
-	serverVersion, err := p.kubernetesInterface.Discovery().ServerVersion()
-	if err != nil {
-		return "", err
+	var version string
+	if settings.FromContext(ctx).KubernetesVersionSource == settings.KubernetesVersionSourceEKS {
+		output, err := p.eksapi.DescribeClusterWithContext(ctx, &eks.DescribeClusterInput{Name: aws.String(settings.FromContext(ctx).ClusterName)})
+		if err != nil {
+			return "", fmt.Errorf("describing cluster, %w", err)
+		}
+		if output == nil || output.Cluster == nil || aws.StringValue(output.Cluster.Version) == "" {
+			return "", fmt.Errorf("describing cluster, cluster %q has no version", settings.FromContext(ctx).ClusterName)
+		}
+		version = aws.StringValue(output.Cluster.Version)
+	} else {
+		serverVersion, err := p.kubernetesInterface.Discovery().ServerVersion()
+		if err != nil {
+			return "", err
+		}
+		version = fmt.Sprintf("%s.%s", serverVersion.Major, strings.TrimSuffix(serverVersion.Minor, "+"))
 	}
-	version := fmt.Sprintf("%s.%s", serverVersion.Major, strings.TrimSuffix(serverVersion.Minor, "+"))
 	p.cache.SetDefault(kubernetesVersionCacheKey, version)
 	if p.cm.HasChanged("kubernetes-version", version) {
 		logging.FromContext(ctx).With("version", version).Debugf("discovered kubernetes version")
 	}
 	return version, nil
 }
+
+// ValidateKubeletVersion returns an error if a kubelet of the version isn't supported by a control plane of the
+// version under the version skew policy, because it's newer than the control plane or more than MaxKubeletVersionSkew
+// minor versions behind it
+func ValidateKubeletVersion(controlPlaneVersion, kubeletVersion string) error {
+	controlPlaneMajor, controlPlaneMinor, err := parse(controlPlaneVersion)
+	if err != nil {
+		return err
+	}
+	kubeletMajor, kubeletMinor, err := parse(kubeletVersion)
+	if err != nil {
+		return err
+	}
+	if kubeletMajor != controlPlaneMajor || kubeletMinor > controlPlaneMinor {
+		return fmt.Errorf("kubelet version %s is newer than the control plane version %s", kubeletVersion, controlPlaneVersion)
+	}
+	if controlPlaneMinor-kubeletMinor > MaxKubeletVersionSkew {
+		return fmt.Errorf("kubelet version %s is more than %d minor versions behind the control plane version %s", kubeletVersion, MaxKubeletVersionSkew, controlPlaneVersion)
+	}
+	return nil
+}
+
+// parse returns the major and minor versions of a version such as 1.27
+func parse(version string) (int, int, error) {
+	parts := strings.Split(version, ".")
+	if len(parts) < 2 {
+		return 0, 0, fmt.Errorf("parsing version %q", version)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("parsing version %q, %w", version, err)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("parsing version %q, %w", version, err)
+	}
+	return major, minor, nil
+}
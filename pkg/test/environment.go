@@ -17,19 +17,27 @@ package test
 import (
 	"context"
 	"net"
+	"net/http"
 
 	"knative.dev/pkg/ptr"
+	"knative.dev/pkg/system"
 
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/patrickmn/go-cache"
 
 	awscache "github.com/aws/karpenter/pkg/cache"
 	"github.com/aws/karpenter/pkg/fake"
 	"github.com/aws/karpenter/pkg/providers/amifamily"
+	"github.com/aws/karpenter/pkg/providers/ec2client"
 	"github.com/aws/karpenter/pkg/providers/instance"
+	"github.com/aws/karpenter/pkg/providers/instanceprofile"
 	"github.com/aws/karpenter/pkg/providers/instancetype"
 	"github.com/aws/karpenter/pkg/providers/launchtemplate"
+	"github.com/aws/karpenter/pkg/providers/notification"
 	"github.com/aws/karpenter/pkg/providers/pricing"
+	"github.com/aws/karpenter/pkg/providers/quota"
 	"github.com/aws/karpenter/pkg/providers/securitygroup"
+	"github.com/aws/karpenter/pkg/providers/spotadvisor"
 	"github.com/aws/karpenter/pkg/providers/subnet"
 	"github.com/aws/karpenter/pkg/providers/version"
 
@@ -40,54 +48,86 @@ import (
 
 type Environment struct {
 	// API
-	EC2API     *fake.EC2API
-	SSMAPI     *fake.SSMAPI
-	PricingAPI *fake.PricingAPI
+	EC2API *fake.EC2API
+	// DiscoveryEC2API serves the requests of NodeClasses that are discovered in another region or with an assumed role
+	DiscoveryEC2API  *fake.EC2API
+	SSMAPI           *fake.SSMAPI
+	EKSAPI           *fake.EKSAPI
+	PricingAPI       *fake.PricingAPI
+	SpotAdvisorAPI   *fake.SpotAdvisorAPI
+	ServiceQuotasAPI *fake.ServiceQuotasAPI
+	IAMAPI           *fake.IAMAPI
+	SNSAPI           *fake.SNSAPI
 
 	// Cache
 	EC2Cache                  *cache.Cache
 	KubernetesVersionCache    *cache.Cache
 	InstanceTypeCache         *cache.Cache
 	UnavailableOfferingsCache *awscache.UnavailableOfferings
+	ObservedMemoryCapacities  *awscache.ObservedMemoryCapacities
 	LaunchTemplateCache       *cache.Cache
 	SubnetCache               *cache.Cache
 	SecurityGroupCache        *cache.Cache
+	QuotaCache                *cache.Cache
+	LaunchDryRunCache         *cache.Cache
+	SpotPlacementScoreCache   *cache.Cache
+	InstanceProfileCache      *cache.Cache
+	NotificationCache         *cache.Cache
 
 	// Providers
-	InstanceTypesProvider  *instancetype.Provider
-	InstanceProvider       *instance.Provider
-	SubnetProvider         *subnet.Provider
-	SecurityGroupProvider  *securitygroup.Provider
-	PricingProvider        *pricing.Provider
-	AMIProvider            *amifamily.Provider
-	AMIResolver            *amifamily.Resolver
-	VersionProvider        *version.Provider
-	LaunchTemplateProvider *launchtemplate.Provider
+	EC2ClientProvider       *ec2client.Provider
+	InstanceTypesProvider   *instancetype.Provider
+	InstanceProvider        *instance.Provider
+	SubnetProvider          *subnet.Provider
+	SecurityGroupProvider   *securitygroup.Provider
+	PricingProvider         *pricing.Provider
+	SpotAdvisorProvider     *spotadvisor.Provider
+	AMIProvider             *amifamily.Provider
+	AMIResolver             *amifamily.Resolver
+	VersionProvider         *version.Provider
+	LaunchTemplateProvider  *launchtemplate.Provider
+	QuotaProvider           *quota.Provider
+	InstanceProfileProvider *instanceprofile.Provider
+	NotificationProvider    *notification.Provider
 }
 
 func NewEnvironment(ctx context.Context, env *coretest.Environment) *Environment {
 	// API
 	ec2api := &fake.EC2API{}
+	discoveryEC2API := &fake.EC2API{}
 	ssmapi := &fake.SSMAPI{}
+	eksapi := &fake.EKSAPI{}
 
 	// cache
 	ec2Cache := cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval)
 	kubernetesVersionCache := cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval)
 	instanceTypeCache := cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval)
 	unavailableOfferingsCache := awscache.NewUnavailableOfferings()
+	observedMemoryCapacities := awscache.NewObservedMemoryCapacities()
 	launchTemplateCache := cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval)
 	subnetCache := cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval)
 	securityGroupCache := cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval)
+	quotaCache := cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval)
+	launchDryRunCache := cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval)
+	spotPlacementScoreCache := cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval)
+	instanceProfileCache := cache.New(awscache.DefaultTTL, awscache.DefaultCleanupInterval)
+	notificationCache := cache.New(awscache.PreTerminationNotificationTTL, awscache.DefaultCleanupInterval)
 	fakePricingAPI := &fake.PricingAPI{}
+	fakeSpotAdvisorAPI := &fake.SpotAdvisorAPI{}
+	fakeServiceQuotasAPI := &fake.ServiceQuotasAPI{}
+	fakeIAMAPI := &fake.IAMAPI{}
+	fakeSNSAPI := &fake.SNSAPI{}
 
 	// Providers
 	pricingProvider := pricing.NewProvider(ctx, fakePricingAPI, ec2api, "")
-	subnetProvider := subnet.NewProvider(ec2api, subnetCache)
-	securityGroupProvider := securitygroup.NewProvider(ec2api, securityGroupCache)
-	versionProvider := version.NewProvider(env.KubernetesInterface, kubernetesVersionCache)
-	amiProvider := amifamily.NewProvider(versionProvider, ssmapi, ec2api, ec2Cache)
+	spotAdvisorProvider := spotadvisor.NewProvider(fakeSpotAdvisorAPI, "")
+	ec2clientProvider := ec2client.NewProvider(ec2api, func(string) ec2iface.EC2API { return discoveryEC2API })
+	subnetProvider := subnet.NewProvider(ec2clientProvider, subnetCache)
+	securityGroupProvider := securitygroup.NewProvider(ec2clientProvider, securityGroupCache)
+	versionProvider := version.NewProvider(env.KubernetesInterface, eksapi, kubernetesVersionCache)
+	amiProvider := amifamily.NewProvider(versionProvider, ssmapi, ec2api, ec2clientProvider, ec2Cache)
 	amiResolver := amifamily.New(amiProvider)
-	instanceTypesProvider := instancetype.NewProvider("", instanceTypeCache, ec2api, subnetProvider, unavailableOfferingsCache, pricingProvider)
+	instanceTypesProvider := instancetype.NewProvider("", instanceTypeCache, ec2api, subnetProvider, unavailableOfferingsCache, observedMemoryCapacities, pricingProvider, spotAdvisorProvider)
 	launchTemplateProvider :=
 		launchtemplate.NewProvider(
 			ctx,
@@ -100,7 +140,12 @@ func NewEnvironment(ctx context.Context, env *coretest.Environment) *Environment
 			make(chan struct{}),
 			net.ParseIP("10.0.100.10"),
 			"https://test-cluster",
+			env.KubernetesInterface,
+			system.Namespace(),
 		)
+	quotaProvider := quota.NewProvider(fakeServiceQuotasAPI, ec2api, quotaCache)
+	instanceProfileProvider := instanceprofile.NewProvider("", fakeIAMAPI, instanceProfileCache)
+	notificationProvider := notification.NewProvider(fakeSNSAPI, http.DefaultClient, notificationCache)
 	instanceProvider :=
 		instance.NewProvider(ctx,
 			"",
@@ -109,12 +154,20 @@ func NewEnvironment(ctx context.Context, env *coretest.Environment) *Environment
 			instanceTypesProvider,
 			subnetProvider,
 			launchTemplateProvider,
+			launchDryRunCache,
+			spotPlacementScoreCache,
 		)
 
 	return &Environment{
-		EC2API:     ec2api,
-		SSMAPI:     ssmapi,
-		PricingAPI: fakePricingAPI,
+		EC2API:           ec2api,
+		DiscoveryEC2API:  discoveryEC2API,
+		SSMAPI:           ssmapi,
+		EKSAPI:           eksapi,
+		PricingAPI:       fakePricingAPI,
+		SpotAdvisorAPI:   fakeSpotAdvisorAPI,
+		ServiceQuotasAPI: fakeServiceQuotasAPI,
+		IAMAPI:           fakeIAMAPI,
+		SNSAPI:           fakeSNSAPI,
 
 		EC2Cache:                  ec2Cache,
 		KubernetesVersionCache:    kubernetesVersionCache,
@@ -122,33 +175,59 @@ func NewEnvironment(ctx context.Context, env *coretest.Environment) *Environment
 		LaunchTemplateCache:       launchTemplateCache,
 		SubnetCache:               subnetCache,
 		SecurityGroupCache:        securityGroupCache,
+		QuotaCache:                quotaCache,
+		LaunchDryRunCache:         launchDryRunCache,
+		SpotPlacementScoreCache:   spotPlacementScoreCache,
+		InstanceProfileCache:      instanceProfileCache,
+		NotificationCache:         notificationCache,
 		UnavailableOfferingsCache: unavailableOfferingsCache,
-
-		InstanceTypesProvider:  instanceTypesProvider,
-		InstanceProvider:       instanceProvider,
-		SubnetProvider:         subnetProvider,
-		SecurityGroupProvider:  securityGroupProvider,
-		PricingProvider:        pricingProvider,
-		AMIProvider:            amiProvider,
-		AMIResolver:            amiResolver,
-		VersionProvider:        versionProvider,
-		LaunchTemplateProvider: launchTemplateProvider,
+		ObservedMemoryCapacities:  observedMemoryCapacities,
+
+		EC2ClientProvider:       ec2clientProvider,
+		InstanceTypesProvider:   instanceTypesProvider,
+		InstanceProvider:        instanceProvider,
+		SubnetProvider:          subnetProvider,
+		SecurityGroupProvider:   securityGroupProvider,
+		PricingProvider:         pricingProvider,
+		SpotAdvisorProvider:     spotAdvisorProvider,
+		AMIProvider:             amiProvider,
+		AMIResolver:             amiResolver,
+		VersionProvider:         versionProvider,
+		LaunchTemplateProvider:  launchTemplateProvider,
+		QuotaProvider:           quotaProvider,
+		InstanceProfileProvider: instanceProfileProvider,
+		NotificationProvider:    notificationProvider,
 	}
 }
 
 func (env *Environment) Reset() {
 	env.EC2API.Reset()
+	env.DiscoveryEC2API.Reset()
 	env.SSMAPI.Reset()
+	env.EKSAPI.Reset()
 	env.PricingAPI.Reset()
 	env.PricingProvider.Reset()
+	env.SpotAdvisorAPI.Reset()
+	env.SpotAdvisorProvider.Reset()
+	env.ServiceQuotasAPI.Reset()
+	env.IAMAPI.Reset()
+	env.SNSAPI.Reset()
+	env.QuotaProvider.Reset()
+	env.InstanceTypesProvider.Reset()
 
 	env.EC2Cache.Flush()
 	env.KubernetesVersionCache.Flush()
 	env.InstanceTypeCache.Flush()
 	env.UnavailableOfferingsCache.Flush()
+	env.ObservedMemoryCapacities.Flush()
 	env.LaunchTemplateCache.Flush()
 	env.SubnetCache.Flush()
 	env.SecurityGroupCache.Flush()
+	env.QuotaCache.Flush()
+	env.LaunchDryRunCache.Flush()
+	env.SpotPlacementScoreCache.Flush()
+	env.InstanceProfileCache.Flush()
+	env.NotificationCache.Flush()
 
 	mfs, err := crmetrics.Registry.Gather()
 	if err != nil {
@@ -64,6 +64,7 @@ func ExpectSubnetStatusEqual(subnets1 []v1alpha1.Subnet, subnets2 []v1beta1.Subn
 	for i := range subnets1 {
 		Expect(subnets1[i].ID).To(Equal(subnets2[i].ID))
 		Expect(subnets1[i].Zone).To(Equal(subnets2[i].Zone))
+		Expect(subnets1[i].AvailableIPAddressCount).To(Equal(subnets2[i].AvailableIPAddressCount))
 	}
 }
 
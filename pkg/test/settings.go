@@ -16,6 +16,7 @@ package test
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/imdario/mergo"
 	"github.com/samber/lo"
@@ -24,16 +25,56 @@ import (
 )
 
 type SettingOptions struct {
-	ClusterName                *string
-	ClusterEndpoint            *string
-	DefaultInstanceProfile     *string
-	EnablePodENI               *bool
-	EnableENILimitedPodDensity *bool
-	IsolatedVPC                *bool
-	VMMemoryOverheadPercent    *float64
-	InterruptionQueueName      *string
-	Tags                       map[string]string
-	ReservedENIs               *int
+	ClusterName                            *string
+	ClusterEndpoint                        *string
+	ClusterDNSServiceName                  *string
+	ClusterDNSServiceNamespace             *string
+	DefaultInstanceProfile                 *string
+	EnablePodENI                           *bool
+	EnableENILimitedPodDensity             *bool
+	IsolatedVPC                            *bool
+	VMMemoryOverheadPercent                *float64
+	VMMemoryOverheadPercentPerInstanceType map[string]float64
+	EnableVMMemoryOverheadLearning         *bool
+	InterruptionQueueName                  *string
+	Tags                                   map[string]string
+	ReservedENIs                           *int
+	EnableAttributeBasedInstanceSelection  *bool
+	EnableLaunchDryRun                     *bool
+	ConsolidationPriceThreshold            *float64
+	ConsolidationPriceThresholdPercent     *float64
+	AllowedInstanceFamilies                []string
+	ExcludedInstanceTypes                  []string
+	MinimumInstanceGeneration              *int
+	UseFIPSEndpoint                        *bool
+	UseDualStackEndpoint                   *bool
+	Endpoints                              map[string]string
+	EnableNodeTemplateMigration            *bool
+	StandbyRefreshInterval                 *time.Duration
+	GarbageCollectionGracePeriod           *time.Duration
+	EnableResourceGarbageCollection        *bool
+	ResourceGarbageCollectionDryRun        *bool
+	EnableStopBasedConsolidation           *bool
+	StoppedInstanceTTL                     *time.Duration
+	EnableNodeRoleRegistration             *bool
+	CredentialsSource                      *string
+	MaxConcurrentLaunchesPerNodePool       *int
+	LaunchesPerSecondPerNodePool           *float64
+	LaunchBurstPerNodePool                 *int
+	EnableSpotPlacementScores              *bool
+	EnableWeightedCapacity                 *bool
+	SubnetSelectionStrategy                *string
+	MaxSecurityGroupsPerNetworkInterface   *int
+	KubernetesVersionSource                *string
+	EnableInstanceTypeCatalog              *bool
+	InterruptionDeadLetterQueueName        *string
+	InterruptionMaxReceiveCount            *int
+	PreTerminationTopicARN                 *string
+	PreTerminationWebhookURL               *string
+	EnableInstanceStatusChecks             *bool
+	ReplaceImpairedInstances               *bool
+	CloudWatchMetricsNamespace             *string
+	TracingEndpoint                        *string
 }
 
 func Settings(overrides ...SettingOptions) *awssettings.Settings {
@@ -46,6 +87,8 @@ func Settings(overrides ...SettingOptions) *awssettings.Settings {
 	return &awssettings.Settings{
 		ClusterName:                lo.FromPtrOr(options.ClusterName, "test-cluster"),
 		ClusterEndpoint:            lo.FromPtrOr(options.ClusterEndpoint, "https://test-cluster"),
+		ClusterDNSServiceName:      lo.FromPtrOr(options.ClusterDNSServiceName, "kube-dns"),
+		ClusterDNSServiceNamespace: lo.FromPtrOr(options.ClusterDNSServiceNamespace, "kube-system"),
 		DefaultInstanceProfile:     lo.FromPtrOr(options.DefaultInstanceProfile, "test-instance-profile"),
 		EnablePodENI:               lo.FromPtrOr(options.EnablePodENI, true),
 		EnableENILimitedPodDensity: lo.FromPtrOr(options.EnableENILimitedPodDensity, true),
@@ -54,5 +97,44 @@ func Settings(overrides ...SettingOptions) *awssettings.Settings {
 		InterruptionQueueName:      lo.FromPtrOr(options.InterruptionQueueName, ""),
 		Tags:                       options.Tags,
 		ReservedENIs:               lo.FromPtrOr(options.ReservedENIs, 0),
+
+		EnableAttributeBasedInstanceSelection:  lo.FromPtrOr(options.EnableAttributeBasedInstanceSelection, false),
+		EnableLaunchDryRun:                     lo.FromPtrOr(options.EnableLaunchDryRun, false),
+		VMMemoryOverheadPercentPerInstanceType: options.VMMemoryOverheadPercentPerInstanceType,
+		EnableVMMemoryOverheadLearning:         lo.FromPtrOr(options.EnableVMMemoryOverheadLearning, false),
+		ConsolidationPriceThreshold:            lo.FromPtrOr(options.ConsolidationPriceThreshold, 0),
+		ConsolidationPriceThresholdPercent:     lo.FromPtrOr(options.ConsolidationPriceThresholdPercent, 0),
+		AllowedInstanceFamilies:                options.AllowedInstanceFamilies,
+		ExcludedInstanceTypes:                  options.ExcludedInstanceTypes,
+		MinimumInstanceGeneration:              lo.FromPtrOr(options.MinimumInstanceGeneration, 0),
+		UseFIPSEndpoint:                        lo.FromPtrOr(options.UseFIPSEndpoint, false),
+		UseDualStackEndpoint:                   lo.FromPtrOr(options.UseDualStackEndpoint, false),
+		Endpoints:                              options.Endpoints,
+		EnableNodeTemplateMigration:            lo.FromPtrOr(options.EnableNodeTemplateMigration, false),
+		StandbyRefreshInterval:                 lo.FromPtrOr(options.StandbyRefreshInterval, 0),
+		GarbageCollectionGracePeriod:           lo.FromPtrOr(options.GarbageCollectionGracePeriod, 30*time.Second),
+		EnableResourceGarbageCollection:        lo.FromPtrOr(options.EnableResourceGarbageCollection, false),
+		ResourceGarbageCollectionDryRun:        lo.FromPtrOr(options.ResourceGarbageCollectionDryRun, false),
+		EnableStopBasedConsolidation:           lo.FromPtrOr(options.EnableStopBasedConsolidation, false),
+		StoppedInstanceTTL:                     lo.FromPtrOr(options.StoppedInstanceTTL, time.Hour),
+		EnableNodeRoleRegistration:             lo.FromPtrOr(options.EnableNodeRoleRegistration, false),
+		CredentialsSource:                      lo.FromPtrOr(options.CredentialsSource, awssettings.CredentialsSourceAuto),
+		MaxConcurrentLaunchesPerNodePool:       lo.FromPtrOr(options.MaxConcurrentLaunchesPerNodePool, 0),
+		LaunchesPerSecondPerNodePool:           lo.FromPtrOr(options.LaunchesPerSecondPerNodePool, 0),
+		LaunchBurstPerNodePool:                 lo.FromPtrOr(options.LaunchBurstPerNodePool, 10),
+		EnableSpotPlacementScores:              lo.FromPtrOr(options.EnableSpotPlacementScores, false),
+		EnableWeightedCapacity:                 lo.FromPtrOr(options.EnableWeightedCapacity, false),
+		SubnetSelectionStrategy:                lo.FromPtrOr(options.SubnetSelectionStrategy, awssettings.SubnetSelectionStrategyMostAvailableIPs),
+		MaxSecurityGroupsPerNetworkInterface:   lo.FromPtrOr(options.MaxSecurityGroupsPerNetworkInterface, 5),
+		KubernetesVersionSource:                lo.FromPtrOr(options.KubernetesVersionSource, awssettings.KubernetesVersionSourceAPIServer),
+		EnableInstanceTypeCatalog:              lo.FromPtrOr(options.EnableInstanceTypeCatalog, false),
+		InterruptionDeadLetterQueueName:        lo.FromPtrOr(options.InterruptionDeadLetterQueueName, ""),
+		InterruptionMaxReceiveCount:            lo.FromPtrOr(options.InterruptionMaxReceiveCount, 5),
+		PreTerminationTopicARN:                 lo.FromPtrOr(options.PreTerminationTopicARN, ""),
+		PreTerminationWebhookURL:               lo.FromPtrOr(options.PreTerminationWebhookURL, ""),
+		EnableInstanceStatusChecks:             lo.FromPtrOr(options.EnableInstanceStatusChecks, false),
+		ReplaceImpairedInstances:               lo.FromPtrOr(options.ReplaceImpairedInstances, false),
+		CloudWatchMetricsNamespace:             lo.FromPtrOr(options.CloudWatchMetricsNamespace, ""),
+		TracingEndpoint:                        lo.FromPtrOr(options.TracingEndpoint, ""),
 	}
 }
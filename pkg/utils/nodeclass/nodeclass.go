@@ -37,26 +37,54 @@ func New(nodeTemplate *v1alpha1.AWSNodeTemplate) *v1beta1.NodeClass {
 		TypeMeta:   nodeTemplate.TypeMeta,
 		ObjectMeta: nodeTemplate.ObjectMeta,
 		Spec: v1beta1.NodeClassSpec{
-			SubnetSelectorTerms:           NewSubnetSelectorTerms(nodeTemplate.Spec.SubnetSelector),
-			OriginalSubnetSelector:        nodeTemplate.Spec.SubnetSelector,
-			SecurityGroupSelectorTerms:    NewSecurityGroupSelectorTerms(nodeTemplate.Spec.SecurityGroupSelector),
-			OriginalSecurityGroupSelector: nodeTemplate.Spec.SecurityGroupSelector,
-			AMISelectorTerms:              NewAMISelectorTerms(nodeTemplate.Spec.AMISelector),
-			OriginalAMISelector:           nodeTemplate.Spec.AMISelector,
-			AMIFamily:                     nodeTemplate.Spec.AMIFamily,
-			UserData:                      nodeTemplate.Spec.UserData,
-			Tags:                          nodeTemplate.Spec.Tags,
-			BlockDeviceMappings:           NewBlockDeviceMappings(nodeTemplate.Spec.BlockDeviceMappings),
-			DetailedMonitoring:            nodeTemplate.Spec.DetailedMonitoring,
-			MetadataOptions:               NewMetadataOptions(nodeTemplate.Spec.MetadataOptions),
-			Context:                       nodeTemplate.Spec.Context,
-			LaunchTemplateName:            nodeTemplate.Spec.LaunchTemplateName,
-			InstanceProfile:               nodeTemplate.Spec.InstanceProfile,
+			SubnetSelectorTerms:               NewSubnetSelectorTerms(nodeTemplate.Spec.SubnetSelector),
+			OriginalSubnetSelector:            nodeTemplate.Spec.SubnetSelector,
+			SubnetPolicy:                      nodeTemplate.Spec.SubnetPolicy,
+			SecurityGroupSelectorTerms:        NewSecurityGroupSelectorTerms(nodeTemplate.Spec.SecurityGroupSelector),
+			OriginalSecurityGroupSelector:     nodeTemplate.Spec.SecurityGroupSelector,
+			AMISelectorTerms:                  NewAMISelectorTerms(nodeTemplate.Spec.AMISelector),
+			OriginalAMISelector:               nodeTemplate.Spec.AMISelector,
+			AMIFamily:                         nodeTemplate.Spec.AMIFamily,
+			UserData:                          nodeTemplate.Spec.UserData,
+			UserDataTemplating:                nodeTemplate.Spec.UserDataTemplating,
+			UserDataRef:                       NewUserDataReference(nodeTemplate.Spec.UserDataRef),
+			UserDataMergeOrder:                nodeTemplate.Spec.UserDataMergeOrder,
+			UserDataCompression:               nodeTemplate.Spec.UserDataCompression,
+			Tags:                              nodeTemplate.Spec.Tags,
+			BlockDeviceMappings:               NewBlockDeviceMappings(nodeTemplate.Spec.BlockDeviceMappings),
+			EphemeralStorageSizing:            NewEphemeralStorageSizing(nodeTemplate.Spec.EphemeralStorageSizing),
+			DetailedMonitoring:                nodeTemplate.Spec.DetailedMonitoring,
+			CPUCreditSpecification:            nodeTemplate.Spec.CPUCreditSpecification,
+			EnclaveOptions:                    NewEnclaveOptions(nodeTemplate.Spec.EnclaveOptions),
+			CPUOptions:                        NewCPUOptions(nodeTemplate.Spec.CPUOptions),
+			RequireEncryptionInTransit:        nodeTemplate.Spec.RequireEncryptionInTransit,
+			AMIMaxAge:                         nodeTemplate.Spec.AMIMaxAge,
+			InstanceInitiatedShutdownBehavior: nodeTemplate.Spec.InstanceInitiatedShutdownBehavior,
+			StartupTaints:                     nodeTemplate.Spec.StartupTaints,
+			RequireSSMAgent:                   nodeTemplate.Spec.RequireSSMAgent,
+			RegistrationTTL:                   nodeTemplate.Spec.RegistrationTTL,
+			Bottlerocket:                      NewBottlerocketSettings(nodeTemplate.Spec.Bottlerocket),
+			ContainerRegistries:               NewContainerRegistries(nodeTemplate.Spec.ContainerRegistries),
+			Kubelet:                           NewKubeletConfiguration(nodeTemplate.Spec.Kubelet),
+			MaxPodsPerInstanceType:            nodeTemplate.Spec.MaxPodsPerInstanceType,
+			NVIDIA:                            NewNVIDIAConfiguration(nodeTemplate.Spec.NVIDIA),
+			Windows:                           NewWindowsConfiguration(nodeTemplate.Spec.Windows),
+			InstanceRequirements:              NewInstanceRequirements(nodeTemplate.Spec.InstanceRequirements),
+			AssumeRoleARN:                     nodeTemplate.Spec.AssumeRoleARN,
+			LaunchDryRun:                      nodeTemplate.Spec.LaunchDryRun,
+			DeletionPolicy:                    nodeTemplate.Spec.DeletionPolicy,
+			MetadataOptions:                   NewMetadataOptions(nodeTemplate.Spec.MetadataOptions),
+			Context:                           nodeTemplate.Spec.Context,
+			LaunchTemplateName:                nodeTemplate.Spec.LaunchTemplateName,
+			InstanceProfile:                   nodeTemplate.Spec.InstanceProfile,
 		},
 		Status: v1beta1.NodeClassStatus{
-			Subnets:        NewSubnets(nodeTemplate.Status.Subnets),
-			SecurityGroups: NewSecurityGroups(nodeTemplate.Status.SecurityGroups),
-			AMIs:           NewAMIs(nodeTemplate.Status.AMIs),
+			Subnets:         NewSubnets(nodeTemplate.Status.Subnets),
+			SecurityGroups:  NewSecurityGroups(nodeTemplate.Status.SecurityGroups),
+			AMIs:            NewAMIs(nodeTemplate.Status.AMIs),
+			InstanceProfile: nodeTemplate.Status.InstanceProfile,
+			UserDataHash:    nodeTemplate.Status.UserDataHash,
+			Conditions:      nodeTemplate.Status.Conditions,
 		},
 		IsNodeTemplate: true,
 	}
@@ -196,14 +224,139 @@ func NewMetadataOptions(mo *v1alpha1.MetadataOptions) *v1beta1.MetadataOptions {
 	}
 }
 
+func NewEphemeralStorageSizing(sizing *v1alpha1.EphemeralStorageSizing) *v1beta1.EphemeralStorageSizing {
+	if sizing == nil {
+		return nil
+	}
+	return &v1beta1.EphemeralStorageSizing{
+		MinSize:  sizing.MinSize,
+		MaxSize:  sizing.MaxSize,
+		Overhead: sizing.Overhead,
+	}
+}
+
+func NewBottlerocketSettings(settings *v1alpha1.BottlerocketSettings) *v1beta1.BottlerocketSettings {
+	if settings == nil {
+		return nil
+	}
+	return &v1beta1.BottlerocketSettings{
+		HostContainers: lo.MapValues(settings.HostContainers, func(c v1alpha1.BottlerocketHostContainer, _ string) v1beta1.BottlerocketHostContainer {
+			return v1beta1.BottlerocketHostContainer(c)
+		}),
+		BootstrapContainers: lo.MapValues(settings.BootstrapContainers, func(c v1alpha1.BottlerocketBootstrapContainer, _ string) v1beta1.BottlerocketBootstrapContainer {
+			return v1beta1.BottlerocketBootstrapContainer(c)
+		}),
+		KernelSysctl: settings.KernelSysctl,
+		RegistryMirrors: lo.Map(settings.RegistryMirrors, func(m v1alpha1.BottlerocketRegistryMirror, _ int) v1beta1.BottlerocketRegistryMirror {
+			return v1beta1.BottlerocketRegistryMirror(m)
+		}),
+	}
+}
+
+func NewContainerRegistries(registries []v1alpha1.ContainerRegistry) []v1beta1.ContainerRegistry {
+	if registries == nil {
+		return nil
+	}
+	return lo.Map(registries, func(r v1alpha1.ContainerRegistry, _ int) v1beta1.ContainerRegistry {
+		return v1beta1.ContainerRegistry(r)
+	})
+}
+
+func NewKubeletConfiguration(kubelet *v1alpha1.KubeletConfiguration) *v1beta1.KubeletConfiguration {
+	if kubelet == nil {
+		return nil
+	}
+	return &v1beta1.KubeletConfiguration{
+		ShutdownGracePeriod:             kubelet.ShutdownGracePeriod,
+		ShutdownGracePeriodCriticalPods: kubelet.ShutdownGracePeriodCriticalPods,
+		RegistryPullQPS:                 kubelet.RegistryPullQPS,
+		RegistryBurst:                   kubelet.RegistryBurst,
+		CPUManagerPolicy:                kubelet.CPUManagerPolicy,
+		TopologyManagerPolicy:           kubelet.TopologyManagerPolicy,
+		MemoryManagerPolicy:             kubelet.MemoryManagerPolicy,
+	}
+}
+
+func NewNVIDIAConfiguration(nvidia *v1alpha1.NVIDIAConfiguration) *v1beta1.NVIDIAConfiguration {
+	if nvidia == nil {
+		return nil
+	}
+	return &v1beta1.NVIDIAConfiguration{
+		Driver:           nvidia.Driver,
+		ContainerRuntime: nvidia.ContainerRuntime,
+	}
+}
+
+func NewUserDataReference(ref *v1alpha1.UserDataReference) *v1beta1.UserDataReference {
+	if ref == nil {
+		return nil
+	}
+	return &v1beta1.UserDataReference{
+		Kind: ref.Kind,
+		Name: ref.Name,
+		Key:  ref.Key,
+	}
+}
+
+func NewWindowsConfiguration(windows *v1alpha1.WindowsConfiguration) *v1beta1.WindowsConfiguration {
+	if windows == nil {
+		return nil
+	}
+	out := &v1beta1.WindowsConfiguration{Variant: windows.Variant}
+	if windows.CSIProxy != nil {
+		out.CSIProxy = &v1beta1.WindowsCSIProxy{BinaryURL: windows.CSIProxy.BinaryURL}
+	}
+	if windows.GMSA != nil {
+		out.GMSA = &v1beta1.WindowsGMSA{PluginCLSID: windows.GMSA.PluginCLSID}
+	}
+	return out
+}
+
+func NewInstanceRequirements(requirements *v1alpha1.InstanceRequirements) *v1beta1.InstanceRequirements {
+	if requirements == nil {
+		return nil
+	}
+	return &v1beta1.InstanceRequirements{
+		VCPU:                     NewInstanceRequirementsRange(requirements.VCPU),
+		MemoryMiB:                NewInstanceRequirementsRange(requirements.MemoryMiB),
+		AcceleratorCount:         NewInstanceRequirementsRange(requirements.AcceleratorCount),
+		AcceleratorTypes:         requirements.AcceleratorTypes,
+		AcceleratorManufacturers: requirements.AcceleratorManufacturers,
+		ExcludedInstanceTypes:    requirements.ExcludedInstanceTypes,
+	}
+}
+
+func NewInstanceRequirementsRange(r *v1alpha1.InstanceRequirementsRange) *v1beta1.InstanceRequirementsRange {
+	if r == nil {
+		return nil
+	}
+	return &v1beta1.InstanceRequirementsRange{Min: r.Min, Max: r.Max}
+}
+
+func NewEnclaveOptions(enclaveOptions *v1alpha1.EnclaveOptions) *v1beta1.EnclaveOptions {
+	if enclaveOptions == nil {
+		return nil
+	}
+	return &v1beta1.EnclaveOptions{Enabled: enclaveOptions.Enabled}
+}
+
+func NewCPUOptions(cpuOptions *v1alpha1.CPUOptions) *v1beta1.CPUOptions {
+	if cpuOptions == nil {
+		return nil
+	}
+	return &v1beta1.CPUOptions{AMDSEVSNP: cpuOptions.AMDSEVSNP}
+}
+
 func NewSubnets(subnets []v1alpha1.Subnet) []v1beta1.Subnet {
 	if subnets == nil {
 		return nil
 	}
 	return lo.Map(subnets, func(s v1alpha1.Subnet, _ int) v1beta1.Subnet {
 		return v1beta1.Subnet{
-			ID:   s.ID,
-			Zone: s.Zone,
+			ID:                      s.ID,
+			Zone:                    s.Zone,
+			ZoneID:                  s.ZoneID,
+			AvailableIPAddressCount: s.AvailableIPAddressCount,
 		}
 	})
 }
@@ -269,7 +422,23 @@ func PatchStatus(ctx context.Context, c client.Client, stored, nodeClass *v1beta
 func HashAnnotation(nodeClass *v1beta1.NodeClass) map[string]string {
 	if nodeClass.IsNodeTemplate {
 		nodeTemplate := nodetemplateutil.New(nodeClass)
-		return map[string]string{v1alpha1.AnnotationNodeTemplateHash: nodeTemplate.Hash()}
+		return map[string]string{
+			v1alpha1.AnnotationNodeTemplateHash:            nodeTemplate.Hash(),
+			v1alpha1.AnnotationNodeTemplateHashWithoutTags: nodeTemplate.HashWithoutTags(),
+		}
+	}
+	return map[string]string{
+		v1beta1.AnnotationNodeClassHash:            nodeClass.Hash(),
+		v1beta1.AnnotationNodeClassHashWithoutTags: nodeClass.HashWithoutTags(),
+	}
+}
+
+// DriftPolicy returns the drift policy of the field that the NodeClass is annotated with, which defaults to Replace.
+// The annotation is validated by the webhook, so a policy that can't be parsed also defaults to Replace.
+func DriftPolicy(nodeClass *v1beta1.NodeClass, field string) string {
+	policies, err := v1beta1.ParseDriftPolicies(nodeClass.Annotations[lo.Ternary(nodeClass.IsNodeTemplate, v1alpha1.AnnotationDriftPolicy, v1beta1.AnnotationDriftPolicy)])
+	if err != nil {
+		return v1beta1.DriftPolicyReplace
 	}
-	return map[string]string{v1beta1.AnnotationNodeClassHash: nodeClass.Hash()}
+	return lo.ValueOr(policies, field, v1beta1.DriftPolicyReplace)
 }
@@ -105,12 +105,14 @@ var _ = Describe("NodeClassUtils", func() {
 		nodeTemplate.Status = v1alpha1.AWSNodeTemplateStatus{
 			Subnets: []v1alpha1.Subnet{
 				{
-					ID:   "test-subnet-id",
-					Zone: "test-zone-1a",
+					ID:                      "test-subnet-id",
+					Zone:                    "test-zone-1a",
+					AvailableIPAddressCount: 50,
 				},
 				{
-					ID:   "test-subnet-id2",
-					Zone: "test-zone-1b",
+					ID:                      "test-subnet-id2",
+					Zone:                    "test-zone-1b",
+					AvailableIPAddressCount: 100,
 				},
 			},
 			SecurityGroups: []v1alpha1.SecurityGroup{
@@ -433,6 +435,7 @@ var _ = Describe("NodeClassUtils", func() {
 		Expect(convertedNodeTemplate.Status.SecurityGroups).To(Equal(nodeTemplate.Status.SecurityGroups))
 		Expect(convertedNodeTemplate.Status.Subnets).To(Equal(nodeTemplate.Status.Subnets))
 		Expect(convertedNodeTemplate.Status.AMIs).To(Equal(nodeTemplate.Status.AMIs))
+		Expect(convertedNodeTemplate.Status.InstanceProfile).To(Equal(nodeTemplate.Status.InstanceProfile))
 	})
 	It("should retrieve a NodeClass with a get call", func() {
 		nodeClass := test.NodeClass()
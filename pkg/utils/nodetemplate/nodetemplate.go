@@ -26,12 +26,17 @@ func New(nodeClass *v1beta1.NodeClass) *v1alpha1.AWSNodeTemplate {
 		TypeMeta:   nodeClass.TypeMeta,
 		ObjectMeta: nodeClass.ObjectMeta,
 		Spec: v1alpha1.AWSNodeTemplateSpec{
-			UserData: nodeClass.Spec.UserData,
+			UserData:            nodeClass.Spec.UserData,
+			UserDataTemplating:  nodeClass.Spec.UserDataTemplating,
+			UserDataRef:         NewUserDataReference(nodeClass.Spec.UserDataRef),
+			UserDataMergeOrder:  nodeClass.Spec.UserDataMergeOrder,
+			UserDataCompression: nodeClass.Spec.UserDataCompression,
 			AWS: v1alpha1.AWS{
 				AMIFamily:             nodeClass.Spec.AMIFamily,
 				Context:               nodeClass.Spec.Context,
 				InstanceProfile:       nodeClass.Spec.InstanceProfile,
 				SubnetSelector:        nodeClass.Spec.OriginalSubnetSelector,
+				SubnetPolicy:          nodeClass.Spec.SubnetPolicy,
 				SecurityGroupSelector: nodeClass.Spec.OriginalSecurityGroupSelector,
 				Tags:                  nodeClass.Spec.Tags,
 				LaunchTemplate: v1alpha1.LaunchTemplate{
@@ -40,13 +45,36 @@ func New(nodeClass *v1beta1.NodeClass) *v1alpha1.AWSNodeTemplate {
 					BlockDeviceMappings: NewBlockDeviceMappings(nodeClass.Spec.BlockDeviceMappings),
 				},
 			},
-			AMISelector:        nodeClass.Spec.OriginalAMISelector,
-			DetailedMonitoring: nodeClass.Spec.DetailedMonitoring,
+			AMISelector:                       nodeClass.Spec.OriginalAMISelector,
+			DetailedMonitoring:                nodeClass.Spec.DetailedMonitoring,
+			CPUCreditSpecification:            nodeClass.Spec.CPUCreditSpecification,
+			EnclaveOptions:                    NewEnclaveOptions(nodeClass.Spec.EnclaveOptions),
+			CPUOptions:                        NewCPUOptions(nodeClass.Spec.CPUOptions),
+			RequireEncryptionInTransit:        nodeClass.Spec.RequireEncryptionInTransit,
+			AMIMaxAge:                         nodeClass.Spec.AMIMaxAge,
+			InstanceInitiatedShutdownBehavior: nodeClass.Spec.InstanceInitiatedShutdownBehavior,
+			StartupTaints:                     nodeClass.Spec.StartupTaints,
+			RequireSSMAgent:                   nodeClass.Spec.RequireSSMAgent,
+			RegistrationTTL:                   nodeClass.Spec.RegistrationTTL,
+			Bottlerocket:                      NewBottlerocketSettings(nodeClass.Spec.Bottlerocket),
+			ContainerRegistries:               NewContainerRegistries(nodeClass.Spec.ContainerRegistries),
+			Kubelet:                           NewKubeletConfiguration(nodeClass.Spec.Kubelet),
+			MaxPodsPerInstanceType:            nodeClass.Spec.MaxPodsPerInstanceType,
+			NVIDIA:                            NewNVIDIAConfiguration(nodeClass.Spec.NVIDIA),
+			Windows:                           NewWindowsConfiguration(nodeClass.Spec.Windows),
+			InstanceRequirements:              NewInstanceRequirements(nodeClass.Spec.InstanceRequirements),
+			AssumeRoleARN:                     nodeClass.Spec.AssumeRoleARN,
+			LaunchDryRun:                      nodeClass.Spec.LaunchDryRun,
+			DeletionPolicy:                    nodeClass.Spec.DeletionPolicy,
+			EphemeralStorageSizing:            NewEphemeralStorageSizing(nodeClass.Spec.EphemeralStorageSizing),
 		},
 		Status: v1alpha1.AWSNodeTemplateStatus{
-			Subnets:        NewSubnets(nodeClass.Status.Subnets),
-			SecurityGroups: NewSecurityGroups(nodeClass.Status.SecurityGroups),
-			AMIs:           NewAMIs(nodeClass.Status.AMIs),
+			Subnets:         NewSubnets(nodeClass.Status.Subnets),
+			SecurityGroups:  NewSecurityGroups(nodeClass.Status.SecurityGroups),
+			AMIs:            NewAMIs(nodeClass.Status.AMIs),
+			InstanceProfile: nodeClass.Status.InstanceProfile,
+			UserDataHash:    nodeClass.Status.UserDataHash,
+			Conditions:      nodeClass.Status.Conditions,
 		},
 	}
 }
@@ -98,14 +126,139 @@ func NewMetadataOptions(mo *v1beta1.MetadataOptions) *v1alpha1.MetadataOptions {
 	}
 }
 
+func NewEphemeralStorageSizing(sizing *v1beta1.EphemeralStorageSizing) *v1alpha1.EphemeralStorageSizing {
+	if sizing == nil {
+		return nil
+	}
+	return &v1alpha1.EphemeralStorageSizing{
+		MinSize:  sizing.MinSize,
+		MaxSize:  sizing.MaxSize,
+		Overhead: sizing.Overhead,
+	}
+}
+
+func NewBottlerocketSettings(settings *v1beta1.BottlerocketSettings) *v1alpha1.BottlerocketSettings {
+	if settings == nil {
+		return nil
+	}
+	return &v1alpha1.BottlerocketSettings{
+		HostContainers: lo.MapValues(settings.HostContainers, func(c v1beta1.BottlerocketHostContainer, _ string) v1alpha1.BottlerocketHostContainer {
+			return v1alpha1.BottlerocketHostContainer(c)
+		}),
+		BootstrapContainers: lo.MapValues(settings.BootstrapContainers, func(c v1beta1.BottlerocketBootstrapContainer, _ string) v1alpha1.BottlerocketBootstrapContainer {
+			return v1alpha1.BottlerocketBootstrapContainer(c)
+		}),
+		KernelSysctl: settings.KernelSysctl,
+		RegistryMirrors: lo.Map(settings.RegistryMirrors, func(m v1beta1.BottlerocketRegistryMirror, _ int) v1alpha1.BottlerocketRegistryMirror {
+			return v1alpha1.BottlerocketRegistryMirror(m)
+		}),
+	}
+}
+
+func NewContainerRegistries(registries []v1beta1.ContainerRegistry) []v1alpha1.ContainerRegistry {
+	if registries == nil {
+		return nil
+	}
+	return lo.Map(registries, func(r v1beta1.ContainerRegistry, _ int) v1alpha1.ContainerRegistry {
+		return v1alpha1.ContainerRegistry(r)
+	})
+}
+
+func NewKubeletConfiguration(kubelet *v1beta1.KubeletConfiguration) *v1alpha1.KubeletConfiguration {
+	if kubelet == nil {
+		return nil
+	}
+	return &v1alpha1.KubeletConfiguration{
+		ShutdownGracePeriod:             kubelet.ShutdownGracePeriod,
+		ShutdownGracePeriodCriticalPods: kubelet.ShutdownGracePeriodCriticalPods,
+		RegistryPullQPS:                 kubelet.RegistryPullQPS,
+		RegistryBurst:                   kubelet.RegistryBurst,
+		CPUManagerPolicy:                kubelet.CPUManagerPolicy,
+		TopologyManagerPolicy:           kubelet.TopologyManagerPolicy,
+		MemoryManagerPolicy:             kubelet.MemoryManagerPolicy,
+	}
+}
+
+func NewNVIDIAConfiguration(nvidia *v1beta1.NVIDIAConfiguration) *v1alpha1.NVIDIAConfiguration {
+	if nvidia == nil {
+		return nil
+	}
+	return &v1alpha1.NVIDIAConfiguration{
+		Driver:           nvidia.Driver,
+		ContainerRuntime: nvidia.ContainerRuntime,
+	}
+}
+
+func NewUserDataReference(ref *v1beta1.UserDataReference) *v1alpha1.UserDataReference {
+	if ref == nil {
+		return nil
+	}
+	return &v1alpha1.UserDataReference{
+		Kind: ref.Kind,
+		Name: ref.Name,
+		Key:  ref.Key,
+	}
+}
+
+func NewWindowsConfiguration(windows *v1beta1.WindowsConfiguration) *v1alpha1.WindowsConfiguration {
+	if windows == nil {
+		return nil
+	}
+	out := &v1alpha1.WindowsConfiguration{Variant: windows.Variant}
+	if windows.CSIProxy != nil {
+		out.CSIProxy = &v1alpha1.WindowsCSIProxy{BinaryURL: windows.CSIProxy.BinaryURL}
+	}
+	if windows.GMSA != nil {
+		out.GMSA = &v1alpha1.WindowsGMSA{PluginCLSID: windows.GMSA.PluginCLSID}
+	}
+	return out
+}
+
+func NewInstanceRequirements(requirements *v1beta1.InstanceRequirements) *v1alpha1.InstanceRequirements {
+	if requirements == nil {
+		return nil
+	}
+	return &v1alpha1.InstanceRequirements{
+		VCPU:                     NewInstanceRequirementsRange(requirements.VCPU),
+		MemoryMiB:                NewInstanceRequirementsRange(requirements.MemoryMiB),
+		AcceleratorCount:         NewInstanceRequirementsRange(requirements.AcceleratorCount),
+		AcceleratorTypes:         requirements.AcceleratorTypes,
+		AcceleratorManufacturers: requirements.AcceleratorManufacturers,
+		ExcludedInstanceTypes:    requirements.ExcludedInstanceTypes,
+	}
+}
+
+func NewInstanceRequirementsRange(r *v1beta1.InstanceRequirementsRange) *v1alpha1.InstanceRequirementsRange {
+	if r == nil {
+		return nil
+	}
+	return &v1alpha1.InstanceRequirementsRange{Min: r.Min, Max: r.Max}
+}
+
+func NewEnclaveOptions(enclaveOptions *v1beta1.EnclaveOptions) *v1alpha1.EnclaveOptions {
+	if enclaveOptions == nil {
+		return nil
+	}
+	return &v1alpha1.EnclaveOptions{Enabled: enclaveOptions.Enabled}
+}
+
+func NewCPUOptions(cpuOptions *v1beta1.CPUOptions) *v1alpha1.CPUOptions {
+	if cpuOptions == nil {
+		return nil
+	}
+	return &v1alpha1.CPUOptions{AMDSEVSNP: cpuOptions.AMDSEVSNP}
+}
+
 func NewSubnets(subnets []v1beta1.Subnet) []v1alpha1.Subnet {
 	if subnets == nil {
 		return nil
 	}
 	return lo.Map(subnets, func(s v1beta1.Subnet, _ int) v1alpha1.Subnet {
 		return v1alpha1.Subnet{
-			ID:   s.ID,
-			Zone: s.Zone,
+			ID:                      s.ID,
+			Zone:                    s.Zone,
+			ZoneID:                  s.ZoneID,
+			AvailableIPAddressCount: s.AvailableIPAddressCount,
 		}
 	})
 }
@@ -100,12 +100,14 @@ var _ = Describe("NodeTemplateUtils", func() {
 		nodeClass.Status = v1beta1.NodeClassStatus{
 			Subnets: []v1beta1.Subnet{
 				{
-					ID:   "test-subnet-id",
-					Zone: "test-zone-1a",
+					ID:                      "test-subnet-id",
+					Zone:                    "test-zone-1a",
+					AvailableIPAddressCount: 50,
 				},
 				{
-					ID:   "test-subnet-id2",
-					Zone: "test-zone-1b",
+					ID:                      "test-subnet-id2",
+					Zone:                    "test-zone-1b",
+					AvailableIPAddressCount: 100,
 				},
 			},
 			SecurityGroups: []v1beta1.SecurityGroup{
@@ -17,16 +17,31 @@ package utils
 import (
 	"fmt"
 	"regexp"
+	"strings"
+	"text/template"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
 )
 
 var (
 	instanceIDRegex = regexp.MustCompile(`aws:///(?P<AZ>.*)/(?P<InstanceID>.*)`)
 )
 
+// IsTagTemplate returns true if the tag value is a Go template that's rendered at launch. Values that contain "{{" but
+// can't be parsed as a template are literal values.
+func IsTagTemplate(value string) bool {
+	if !strings.Contains(value, "{{") {
+		return false
+	}
+	_, err := template.New("").Parse(value)
+	return err == nil
+}
+
 // ParseInstanceID parses the provider ID stored on the node to get the instance ID
 // associated with a node
 func ParseInstanceID(providerID string) (string, error) {
@@ -49,3 +64,20 @@ func MergeTags(tags ...map[string]string) []*ec2.Tag {
 		return &ec2.Tag{Key: aws.String(k), Value: aws.String(v)}
 	})
 }
+
+// ClusterTags returns the tags that every resource Karpenter creates for the cluster is tagged with, regardless of
+// the NodeClass and global tags
+func ClusterTags(clusterName string) map[string]string {
+	return map[string]string{
+		fmt.Sprintf("kubernetes.io/cluster/%s", clusterName): "owned",
+		v1beta1.EKSClusterNameTagKey:                         clusterName,
+	}
+}
+
+// MergeTaints takes a variadic list of taints and merges them together, dropping taints with a key and effect that
+// an earlier taint already has since the kubelet refuses to register with duplicates
+func MergeTaints(taints ...[]v1.Taint) []v1.Taint {
+	return lo.UniqBy(lo.Flatten(taints), func(taint v1.Taint) string {
+		return fmt.Sprintf("%s:%s", taint.Key, taint.Effect)
+	})
+}
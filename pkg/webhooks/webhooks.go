@@ -28,6 +28,7 @@ import (
 	corev1alpha5 "github.com/aws/karpenter-core/pkg/apis/v1alpha5"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter/pkg/apis/v1beta1"
 )
 
 func NewWebhooks() []knativeinjection.ControllerConstructor {
@@ -59,5 +60,6 @@ func NewCRDValidationWebhook(ctx context.Context, _ configmap.Watcher) *controll
 
 var Resources = map[schema.GroupVersionKind]resourcesemantics.GenericCRD{
 	v1alpha1.SchemeGroupVersion.WithKind("AWSNodeTemplate"): &v1alpha1.AWSNodeTemplate{},
+	v1beta1.SchemeGroupVersion.WithKind("NodeClass"):        &v1beta1.NodeClass{},
 	corev1alpha5.SchemeGroupVersion.WithKind("Provisioner"): &v1alpha5.Provisioner{},
 }
@@ -83,6 +83,8 @@ func main() {
 		op.AMIProvider,
 		op.SecurityGroupProvider,
 		op.SubnetProvider,
+		op.QuotaProvider,
+		op.NotificationProvider,
 	)
 	raw := &runtime.RawExtension{}
 	lo.Must0(raw.UnmarshalJSON(lo.Must(json.Marshal(&v1alpha1.AWS{